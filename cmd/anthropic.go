@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var anthropicModelKey string
+
+var anthropicCmd = &cobra.Command{
+	Use:   "anthropic",
+	Short: "Use an Anthropic Claude model.",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		cfg, err = config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+
+		task, _ := cmd.Flags().GetString("task")
+		modelCfg, ok := cfg.Anthropic.Models[anthropicModelKey]
+		if !ok {
+			HandleError(fmt.Errorf("model key '%s' not found in config for Anthropic", anthropicModelKey))
+		}
+		req := ai.ClaudeRequest{
+			URL:         modelCfg.Apiurl,
+			APIKey:      modelCfg.Apikey,
+			Model:       modelCfg.Model,
+			MaxTokens:   modelCfg.MaxTokens,
+			Temperature: modelCfg.Temperature,
+		}
+		if req.URL == "" {
+			req.URL = cfg.Anthropic.Apiurl
+		}
+		if req.APIKey == "" {
+			req.APIKey = cfg.Anthropic.Apikey
+		}
+		client := ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions("anthropic", anthropicModelKey))
+		response, err := ai.CallClaude(client, task, req)
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("provider.response"), response)
+	},
+}
+
+func init() {
+	anthropicCmd.Flags().String("task", "", "The task to perform.")
+	anthropicCmd.Flags().StringVar(&anthropicModelKey, "model", "", "The Anthropic model key to use (from config).")
+	anthropicCmd.MarkFlagRequired("task")
+	anthropicCmd.MarkFlagRequired("model")
+	rootCmd.AddCommand(anthropicCmd)
+}
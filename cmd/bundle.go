@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ai-team/config"
+	"ai-team/pkg/cli"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/runs"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Share a chain with the roles and tools it uses as a single file.",
+}
+
+var bundleExportCmd = &cobra.Command{
+	Use:   "export <chain>",
+	Short: "Write a chain, its roles and the config tools to a bundle file.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+		if out == "" {
+			out = args[0] + ".bundle.yaml"
+		}
+		path, err := config.ResolvePath(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read config file: "+path, err))
+		}
+		bundle, err := config.ExportBundle(data, args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		if err := os.WriteFile(out, bundle, 0644); err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to write bundle: "+out, err))
+		}
+		fmt.Println(i18n.T("bundle.exported", args[0], out))
+	},
+}
+
+var bundleImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Merge a bundle into the config file, asking about conflicting entries.",
+	Long: `Adds the chain, roles and tools of a bundle to the config file. For each
+entry that differs from the local one of the same name, the difference is
+shown and you are asked whether to replace it; --overwrite replaces and
+--keep keeps all of them without asking.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		keep, _ := cmd.Flags().GetBool("keep")
+		if overwrite && keep {
+			HandleError(errors.New(errors.ErrCodeConfig, "--overwrite and --keep are mutually exclusive", nil))
+		}
+
+		bundle, err := os.ReadFile(args[0])
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read bundle: "+args[0], err))
+		}
+		path, err := config.ResolvePath(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read config file: "+path, err))
+		}
+		result, err := config.ImportBundle(data, bundle, importResolver(newUI(configuredUI(data), ""), overwrite, keep))
+		if err != nil {
+			HandleError(err)
+		}
+		if !writeImport(path, result) {
+			return
+		}
+		fmt.Println(i18n.T("bundle.imported", result.Chain, path))
+	},
+}
+
+// importResolver answers the conflicts of an import: with --overwrite or
+// --keep, or else by showing the difference and asking.
+func importResolver(ui cli.UI, overwrite, keep bool) func(config.BundleConflict) (bool, error) {
+	return func(c config.BundleConflict) (bool, error) {
+		switch {
+		case overwrite:
+			return true, nil
+		case keep:
+			return false, nil
+		}
+		fmt.Println(i18n.T("bundle.differs", c.Section, c.Name))
+		if err := ui.ShowDiff(runs.DiffLines(c.Local, c.Incoming)); err != nil {
+			return false, err
+		}
+		return ui.Confirm(i18n.T("bundle.replace", c.Section, c.Name))
+	}
+}
+
+// writeImport prints the outcome of an import and writes the merged config
+// to path, keeping its permissions. It reports whether anything changed.
+func writeImport(path string, result config.BundleImport) bool {
+	for _, group := range []struct {
+		key     string
+		entries []string
+	}{{"bundle.added", result.Added}, {"bundle.replaced", result.Replaced}, {"bundle.kept", result.Kept}, {"bundle.unchanged_entries", result.Unchanged}} {
+		if len(group.entries) > 0 {
+			fmt.Println(i18n.T(group.key, strings.Join(group.entries, ", ")))
+		}
+	}
+	if !result.Changed() {
+		fmt.Println(i18n.T("bundle.unchanged", path))
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		HandleError(errors.New(errors.ErrCodeConfig, "failed to stat config file: "+path, err))
+	}
+	if err := os.WriteFile(path, result.Data, info.Mode().Perm()); err != nil {
+		HandleError(errors.New(errors.ErrCodeConfig, "failed to write config file: "+path, err))
+	}
+	return true
+}
+
+// configuredUI returns the ui setting of a config document, which import
+// reads without loading it.
+func configuredUI(data []byte) string {
+	var settings struct {
+		UI string `yaml:"ui"`
+	}
+	yaml.Unmarshal(data, &settings)
+	return settings.UI
+}
+
+func init() {
+	bundleExportCmd.Flags().StringP("out", "o", "", "Bundle file (default <chain>.bundle.yaml)")
+	bundleImportCmd.Flags().Bool("overwrite", false, "Replace conflicting local entries without asking")
+	bundleImportCmd.Flags().Bool("keep", false, "Keep conflicting local entries without asking")
+	bundleCmd.AddCommand(bundleExportCmd, bundleImportCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
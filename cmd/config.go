@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/runs"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Maintain the config file.",
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the config file to the current schema version.",
+	Run: func(cmd *cobra.Command, args []string) {
+		write, _ := cmd.Flags().GetBool("write")
+
+		path, err := config.ResolvePath(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read config file: "+path, err))
+		}
+		result, err := config.Migrate(data)
+		if err != nil {
+			HandleError(err)
+		}
+		if !result.Changed() {
+			fmt.Println(i18n.T("config.already_current", path, result.To))
+			return
+		}
+		fmt.Println(i18n.T("config.migrating", path, result.From, result.To))
+		for _, note := range result.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+		fmt.Print(runs.DiffLines(string(data), string(result.Data)))
+		if !write {
+			fmt.Println(i18n.T("config.write_hint"))
+			return
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to stat config file: "+path, err))
+		}
+		if err := os.WriteFile(path, result.Data, info.Mode().Perm()); err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to write config file: "+path, err))
+		}
+		fmt.Println(i18n.T("common.wrote", path))
+	},
+}
+
+func init() {
+	configMigrateCmd.Flags().Bool("write", false, "Rewrite the config file in place (comments are kept).")
+	configCmd.AddCommand(configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
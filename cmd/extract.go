@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var extractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "Report which tool-call extraction strategies handle captured model outputs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, _ := cmd.Flags().GetStringArray("file")
+		provider, _ := cmd.Flags().GetString("provider")
+		roleName, _ := cmd.Flags().GetString("role")
+		strategies, _ := cmd.Flags().GetStringArray("strategy")
+		if len(paths) == 0 {
+			HandleError(fmt.Errorf("--file is required"))
+		}
+
+		var order []string
+		localCfg, err := config.LoadConfig(cfgFile)
+		if err != nil && roleName != "" {
+			HandleError(err)
+		} else if err != nil {
+			logrus.Debugf("No config loaded, using the built-in extraction order: %v", err)
+		}
+		if roleName != "" {
+			role, ok := localCfg.Roles[roleName]
+			if !ok {
+				HandleError(fmt.Errorf("role '%s' not found in config", roleName))
+			}
+			if provider == "" {
+				provider = role.Provider
+			}
+			order = role.Extraction
+			if len(order) == 0 {
+				order = localCfg.Extraction.For(role.Provider)
+			}
+		} else if err == nil {
+			order = localCfg.Extraction.For(provider)
+		}
+		if len(order) == 0 {
+			order = ai.DefaultExtractionStrategies
+		}
+		if len(strategies) == 0 {
+			strategies = mergeStrategies(ai.DefaultExtractionStrategies, order)
+		}
+
+		files, err := sampleFiles(paths)
+		if err != nil {
+			HandleError(err)
+		}
+		registry := tools.NewToolRegistry()
+		tools.RegisterDefaultTools(registry)
+		if err := tools.RegisterConfigTools(registry, localCfg.Tools); err != nil {
+			HandleError(err)
+		}
+		missed := 0
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				HandleError(err)
+			}
+			raw := string(data)
+			sampleProvider := provider
+			if sampleProvider == "" {
+				sampleProvider = ai.DetectProvider(raw)
+			}
+			results, err := ai.TryStrategies(registry, sampleProvider, raw, strategies)
+			if err != nil {
+				HandleError(err)
+			}
+			fmt.Println(file)
+			for _, r := range results {
+				found := "-"
+				if r.ToolCall != nil {
+					found = r.ToolCall.Name
+				}
+				fmt.Printf("  %-20s %s\n", r.Strategy, found)
+			}
+			chosen, err := ai.TryStrategies(registry, sampleProvider, raw, order)
+			if err != nil {
+				HandleError(err)
+			}
+			winner := ""
+			for _, r := range chosen {
+				if r.ToolCall != nil {
+					winner = r.Strategy
+					fmt.Println("  " + i18n.T("extract.chosen", r.ToolCall.Name, r.Strategy))
+					break
+				}
+			}
+			if winner == "" {
+				missed++
+				fmt.Println("  " + i18n.T("extract.none"))
+			}
+		}
+		fmt.Println(i18n.T("extract.summary", len(files), len(files)-missed, missed))
+		if missed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// mergeStrategies returns base followed by the strategies of extra not in it.
+func mergeStrategies(base, extra []string) []string {
+	merged := append([]string(nil), base...)
+	seen := make(map[string]bool, len(base))
+	for _, s := range base {
+		seen[s] = true
+	}
+	for _, s := range extra {
+		if !seen[s] {
+			seen[s] = true
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// sampleFiles returns the files of paths; directories contribute the regular
+// files directly inside them, sorted.
+func sampleFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				found = append(found, filepath.Join(p, e.Name()))
+			}
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+func init() {
+	extractCmd.Flags().StringArray("file", nil, "Captured model output, or a directory of them (repeatable).")
+	extractCmd.Flags().String("provider", "", "Provider of the outputs, for provider_native (default: detected from each output, or the role's provider).")
+	extractCmd.Flags().String("role", "", "Report the strategy chosen by this role's configured extraction order.")
+	extractCmd.Flags().StringArray("strategy", nil, "Strategy to try (repeatable; default: the built-in strategies and the configured order).")
+	rootCmd.AddCommand(extractCmd)
+}
@@ -6,6 +6,7 @@ import (
 
 	"ai-team/config"
 	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
 
 	"github.com/spf13/cobra"
 )
@@ -23,7 +24,7 @@ var geminiCmd = &cobra.Command{
 			HandleError(err)
 		}
 
-		client := &http.Client{}
+		client := ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions("gemini", geminiModelKey))
 
 		if listGeminiModels {
 			apiKey := cfg.Gemini.Apikey
@@ -32,7 +33,7 @@ var geminiCmd = &cobra.Command{
 			if err != nil {
 				HandleError(err)
 			}
-			fmt.Println("Available Gemini Models:")
+			fmt.Println(i18n.T("provider.models"))
 			for _, model := range models {
 				fmt.Println("-", model)
 			}
@@ -56,11 +57,11 @@ var geminiCmd = &cobra.Command{
 		if apiURL == "" {
 			apiURL = cfg.Gemini.Apiurl
 		}
-		response, err := ai.CallGemini(client, task, modelCfg.Model, apiURL, apiKey, cfg.Tools)
+		response, err := ai.CallGemini(client, task, modelCfg.Model, apiURL, apiKey, cfg.Tools, modelCfg.Generation())
 		if err != nil {
 			HandleError(err)
 		}
-		fmt.Println("Response:", response)
+		fmt.Println(i18n.T("provider.response"), response)
 	},
 }
 
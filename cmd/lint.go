@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"ai-team/config"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/roles"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [chain-name...]",
+	Short: "Check chains for role inputs that are missing or undeclared.",
+	Run: func(cmd *cobra.Command, args []string) {
+		localCfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+
+		chains := args
+		if len(chains) == 0 {
+			for name := range localCfg.Chains {
+				chains = append(chains, name)
+			}
+			sort.Strings(chains)
+		}
+
+		var problems []string
+		for _, name := range chains {
+			problems = append(problems, roles.LintChain(&localCfg, name)...)
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println(i18n.T("lint.ok", len(chains)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/memory"
+
+	"github.com/spf13/cobra"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Inspect the facts roles remembered across runs.",
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List remembered facts.",
+	Run: func(cmd *cobra.Command, args []string) {
+		tag, _ := cmd.Flags().GetString("tag")
+		query, _ := cmd.Flags().GetString("query")
+		store := memoryStore()
+		var facts []memory.Fact
+		var err error
+		if query != "" {
+			facts, err = store.Recall(query, tag, -1)
+		} else {
+			facts, err = store.List()
+		}
+		if err != nil {
+			HandleError(err)
+		}
+		listed := 0
+		for _, f := range facts {
+			if tag != "" && !f.HasTag(tag) {
+				continue
+			}
+			listed++
+			text := []rune(strings.ReplaceAll(f.Text, "\n", " "))
+			if len(text) > 80 {
+				text = append(text[:77], []rune("...")...)
+			}
+			entry := i18n.T("memory.entry", f.ID, f.Updated.Format("2006-01-02"), string(text))
+			if len(f.Tags) > 0 {
+				entry += "  [" + strings.Join(f.Tags, ", ") + "]"
+			}
+			fmt.Println(entry)
+		}
+		if listed == 0 {
+			fmt.Println(i18n.T("memory.none", store.Path))
+		}
+	},
+}
+
+var memoryShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a remembered fact and the run and role that stored it.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid fact ID '%s'", args[0]), err))
+		}
+		fact, err := memoryStore().Get(id)
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Print(memory.Show(fact))
+	},
+}
+
+var memoryClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Forget remembered facts: those with --tag, or all of them with --all.",
+	Run: func(cmd *cobra.Command, args []string) {
+		tag, _ := cmd.Flags().GetString("tag")
+		all, _ := cmd.Flags().GetBool("all")
+		if tag == "" && !all {
+			fmt.Println(i18n.T("memory.clear_hint"))
+			return
+		}
+		store := memoryStore()
+		removed, err := store.Clear(tag)
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("memory.cleared", removed, store.Path))
+	},
+}
+
+// memoryStore returns the memory store of the config.
+func memoryStore() *memory.Store {
+	localCfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return memory.NewStore("")
+	}
+	store := memory.NewStore(localCfg.Memory.Path)
+	store.MaxFacts = localCfg.Memory.MaxFacts
+	return store
+}
+
+func init() {
+	memoryListCmd.Flags().String("tag", "", "Only list facts with this tag.")
+	memoryListCmd.Flags().String("query", "", "Only list facts containing words of the query, best matches first.")
+	memoryClearCmd.Flags().String("tag", "", "Forget only the facts with this tag.")
+	memoryClearCmd.Flags().Bool("all", false, "Forget every fact.")
+	memoryCmd.AddCommand(memoryListCmd)
+	memoryCmd.AddCommand(memoryShowCmd)
+	memoryCmd.AddCommand(memoryClearCmd)
+	rootCmd.AddCommand(memoryCmd)
+}
@@ -6,6 +6,7 @@ import (
 
 	"ai-team/config"
 	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
 
 	"github.com/spf13/cobra"
 )
@@ -35,12 +36,12 @@ var ollamaCmd = &cobra.Command{
 		if apiURL == "" {
 			apiURL = cfg.Ollama.Apiurl
 		}
-		client := &http.Client{}
-		response, err := ai.CallOllama(client, task, apiURL, modelCfg.Model, cfg.Tools)
+		client := ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions("ollama", modelKey))
+		response, err := ai.CallOllama(client, task, apiURL, modelCfg.Model, cfg.Tools, modelCfg.Generation())
 		if err != nil {
 			HandleError(err)
 		}
-		fmt.Println("Response:", response)
+		fmt.Println(i18n.T("provider.response"), response)
 	},
 }
 
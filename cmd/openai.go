@@ -6,6 +6,7 @@ import (
 
 	"ai-team/config"
 	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
 
 	"github.com/spf13/cobra"
 )
@@ -39,12 +40,12 @@ var openaiCmd = &cobra.Command{
 		if apiURL == "" {
 			apiURL = cfg.OpenAI.DefaultApiurl
 		}
-		client := &http.Client{}
-		response, err := ai.CallOpenAI(client, task, apiURL, apiKey)
+		client := ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions("openai", modelKey))
+		response, err := ai.CallOpenAI(client, task, apiURL, apiKey, modelCfg.Generation())
 		if err != nil {
 			HandleError(err)
 		}
-		fmt.Println("Response:", response)
+		fmt.Println(i18n.T("provider.response"), response)
 	},
 }
 
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"ai-team/config"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/tools"
+
+	"github.com/spf13/cobra"
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Report file writes interrupted by a crash and optionally revert them.",
+	Long: `Lists the file modifications recorded in the write journal that never
+finished, typically because ai-team was killed mid-write, with the state of
+each file compared to its original. --revert restores the original contents
+(or removes files that did not exist); --clear accepts the files as they are.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		revert, _ := cmd.Flags().GetBool("revert")
+		clear, _ := cmd.Flags().GetBool("clear")
+
+		path := ""
+		if localCfg, err := config.LoadConfig(cfgFile); err == nil {
+			path = localCfg.Journal.Path
+		}
+		journal := tools.NewJournal(path)
+		entries, err := journal.Incomplete()
+		if err != nil {
+			HandleError(err)
+		}
+		if len(entries) == 0 {
+			fmt.Println(i18n.T("recover.none", journal.Path))
+			return
+		}
+		for _, e := range entries {
+			fmt.Println(i18n.T("recover.entry", e.Time.Format("2006-01-02 15:04:05"), e.State(), e.Op, e.Path, e.PID))
+		}
+		switch {
+		case revert:
+			failed := 0
+			for _, e := range entries {
+				if err := journal.Revert(e); err != nil {
+					fmt.Println(i18n.T("recover.revert_failed", e.Path, err))
+					failed++
+				}
+			}
+			fmt.Println(i18n.T("recover.reverted", len(entries)-failed, len(entries)))
+		case clear:
+			for _, e := range entries {
+				journal.End(e.ID)
+			}
+			fmt.Println(i18n.T("recover.cleared", len(entries)))
+		default:
+			fmt.Println(i18n.T("recover.hint"))
+			return
+		}
+		if err := journal.Compact(); err != nil {
+			HandleError(err)
+		}
+	},
+}
+
+func init() {
+	recoverCmd.Flags().Bool("revert", false, "Restore the files of interrupted writes to their original contents.")
+	recoverCmd.Flags().Bool("clear", false, "Forget interrupted writes, keeping the files as they are.")
+	rootCmd.AddCommand(recoverCmd)
+}
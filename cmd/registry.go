@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/registry"
+
+	"github.com/spf13/cobra"
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage the git repositories shared roles and chains are loaded from.",
+	Long: `Roles and chains can be loaded from a registry with
+	from: registry://<registry>/<name>@<version>
+Registries are listed, with the checksums of the definitions in use, in
+` + registry.DefaultFile + `, which is meant to be committed.`,
+}
+
+var registryAddCmd = &cobra.Command{
+	Use:   "add <git-url>",
+	Short: "Clone a registry and add it to the project.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = registry.NameFromURL(args[0])
+		}
+		client, err := registry.Open("")
+		if err != nil {
+			HandleError(err)
+		}
+		if err := client.Add(name, args[0]); err != nil {
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("registry.added", name, name))
+	},
+}
+
+var registryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registries and pinned definitions of the project.",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := registry.Open("")
+		if err != nil {
+			HandleError(err)
+		}
+		sources := client.Registries()
+		if len(sources) == 0 {
+			fmt.Println(i18n.T("registry.none"))
+			return
+		}
+		names := make([]string, 0, len(sources))
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-20s %s\n", name, sources[name].URL)
+		}
+		if pins := client.Pins(); len(pins) > 0 {
+			fmt.Printf("\n%s\n", i18n.T("registry.pinned"))
+			for _, pin := range pins {
+				fmt.Printf("  %s\n", pin)
+			}
+		}
+	},
+}
+
+var registryUpdateCmd = &cobra.Command{
+	Use:   "update [name...]",
+	Short: "Fetch registries and re-pin the definitions they provide.",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := registry.Open("")
+		if err != nil {
+			HandleError(err)
+		}
+		names := args
+		if len(names) == 0 {
+			for name := range client.Registries() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			if err := client.Update(name); err != nil {
+				HandleError(err)
+			}
+			fmt.Println(i18n.T("registry.updated", name))
+		}
+	},
+}
+
+func init() {
+	registryAddCmd.Flags().String("name", "", "Name used in references (default: the repository name)")
+	registryCmd.AddCommand(registryAddCmd, registryListCmd, registryUpdateCmd)
+	rootCmd.AddCommand(registryCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/runs"
+
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize recent chain runs for a team update.",
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		format, _ := cmd.Flags().GetString("format")
+		outPath, _ := cmd.Flags().GetString("out")
+		top, _ := cmd.Flags().GetInt("top")
+		labelFlags, _ := cmd.Flags().GetStringArray("label")
+
+		now := time.Now()
+		since, err := runs.ParseSince(sinceFlag, now)
+		if err != nil {
+			HandleError(err)
+		}
+		filter, err := runs.ParseLabels(labelFlags)
+		if err != nil {
+			HandleError(err)
+		}
+		records, err := runsStore().List()
+		if err != nil {
+			HandleError(err)
+		}
+		report, err := runs.ExportReport(runs.Summarize(records, since, now, filter, top), format)
+		if err != nil {
+			HandleError(err)
+		}
+		if outPath == "" {
+			fmt.Print(report)
+			return
+		}
+		if err := os.WriteFile(outPath, []byte(report), 0644); err != nil {
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("report.written", outPath))
+	},
+}
+
+func init() {
+	reportCmd.Flags().String("since", "7d", "Start of the period: a duration such as 7d, 2w or 36h, or a date such as 2024-05-01.")
+	reportCmd.Flags().String("format", "md", "Report format: md or json.")
+	reportCmd.Flags().String("out", "", "Write the report to a file instead of stdout.")
+	reportCmd.Flags().Int("top", runs.DefaultReportTop, "How many failing steps and edited files to list (0 lists all).")
+	reportCmd.Flags().StringArray("label", nil, "Only include runs with this key=value label (repeatable; an empty value matches any value).")
+	rootCmd.AddCommand(reportCmd)
+}
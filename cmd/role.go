@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"ai-team/config"
-	"ai-team/pkg/cli"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/render"
 	"ai-team/pkg/roles"
+	"ai-team/pkg/types"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +21,12 @@ var roleCmd = &cobra.Command{
 	Short: "Execute a role.",
 	Run: func(cmd *cobra.Command, args []string) {
 		interactive, _ := cmd.Flags().GetBool("interactive")
+		resumePath, _ := cmd.Flags().GetString("resume")
+		interactive = interactive || resumePath != ""
+		var stream io.Writer
+		if streamOutput, _ := cmd.Flags().GetBool("stream"); streamOutput {
+			stream = os.Stdout
+		}
 
 		if interactive {
 			localCfg, err := config.LoadConfig(cfgFile)
@@ -31,20 +42,50 @@ var roleCmd = &cobra.Command{
 			yes, _ := cmd.Flags().GetBool("yes")
 			editor, _ := cmd.Flags().GetString("editor")
 
+			policy, err := loadPolicy(cmd, localCfg.PolicyFile)
+			if err != nil {
+				HandleError(err)
+			}
+
 			session := &roles.Session{
 				DryRun:        dryRun,
 				Model:         model,
 				MaxIterations: maxIterations,
 				ContextFile:   contextFile,
-				UI:            &cli.DefaultUI{Editor: editor},
+				UI:            newUI(localCfg.UI, editor),
 				Config:        &localCfg,
 				TranscriptPath: transcriptPath,
 				Yes:           yes,
+				HistoryPath:   localCfg.InputHistoryPath,
+				Policy:        policy,
+				Notifier:      newNotifier(cmd, localCfg),
+				Stream:        stream,
+			}
+			if resumePath != "" {
+				checkpoint, err := roles.LoadSessionCheckpoint(resumePath)
+				if err != nil {
+					HandleError(err)
+				}
+				session.Resume = checkpoint
+				session.RunID = checkpoint.RunID
+				if session.Model == "" {
+					session.Model = checkpoint.Model
+				}
 			}
 
 			roles.StartSession(session)
 		} else {
-			fmt.Printf("cfgFile in roleCmd: %s\n", cfgFile)
+			output, _ := cmd.Flags().GetString("output")
+			if output != "text" && output != "json" {
+				HandleError(fmt.Errorf("invalid --output %q (expected text or json)", output))
+			}
+			if output == "json" && stream != nil {
+				HandleError(fmt.Errorf("--stream cannot be combined with --output json"))
+			}
+			helpInputs, _ := cmd.Flags().GetBool("help-inputs")
+			if output == "text" && !helpInputs {
+				fmt.Printf("cfgFile in roleCmd: %s\n", cfgFile)
+			}
 			localCfg, err := config.LoadConfig(cfgFile)
 			if err != nil {
 				HandleError(err)
@@ -61,35 +102,80 @@ var roleCmd = &cobra.Command{
 				HandleError(fmt.Errorf("role not found: %s", roleName))
 				return
 			}
+			if helpInputs {
+				fmt.Print(roles.FormatInputHelp(roleName, role))
+				return
+			}
 
 			inputs := make(map[string]interface{})
 			for _, input := range args[1:] {
 				parts := strings.SplitN(input, "=", 2)
 				if len(parts) != 2 {
-					HandleError(fmt.Errorf("invalid input format: %s", input))
+					inputError(roleName, role, fmt.Errorf("invalid input format: %s (expected name=value)", input))
 					return
 				}
 				inputs[parts[0]] = parts[1]
 			}
+			inputs, err = roles.ResolveInputs(role, inputs)
+			if err != nil {
+				inputError(roleName, role, err)
+			}
+
+			if output == "json" {
+				result, err := roles.RunRole(role, inputs, &localCfg, "")
+				if err != nil {
+					HandleError(err)
+				}
+				data, err := render.Stdout().JSON(result)
+				if err != nil {
+					HandleError(err)
+				}
+				fmt.Println(data)
+				return
+			}
 
-			output, err := roles.ExecuteRole(role, inputs, &localCfg, "")
+			if stream != nil {
+				// The text is shown as it arrives instead of the extracted response.
+				ctx := ai.WithStream(context.Background(), ai.StreamWriter(stream))
+				_, err := roles.ExecuteRoleContext(ctx, role, inputs, &localCfg, "")
+				fmt.Println()
+				if err != nil {
+					HandleError(err)
+				}
+				return
+			}
+
+			response, err := roles.ExecuteRole(role, inputs, &localCfg, "")
 			if err != nil {
 				HandleError(err)
 			}
-			fmt.Println(output)
+			fmt.Println(response)
 		}
 	},
 }
 
+// inputError shows the role's inputs before reporting err, so a first run
+// with missing or malformed inputs explains how to call the role.
+func inputError(name string, role types.Role, err error) {
+	fmt.Fprintln(os.Stderr, roles.FormatInputHelp(name, role))
+	HandleError(err)
+}
+
 func init() {
 	roleCmd.Flags().Bool("interactive", false, "Enable interactive mode.")
 	roleCmd.Flags().Bool("dry-run", false, "Enable dry-run mode.")
 	roleCmd.Flags().String("model", "", "The model to use.")
 	roleCmd.Flags().Int("max-iterations", 5, "The maximum number of iterations.")
 	roleCmd.Flags().String("context-file", "", "The path to a context file.")
-	roleCmd.Flags().String("transcript", "", "Path to a file to save the session transcript.")
+	roleCmd.Flags().String("transcript", "", "Path to a file to save the session transcript ({run_id} is replaced by the session's run ID).")
+	roleCmd.Flags().String("policy", "", "Approval policy file (YAML) for tool calls; overrides --yes.")
 	roleCmd.Flags().Bool("yes", false, "Automatically approve all tool calls without prompting.")
+	roleCmd.Flags().String("notify-on-complete", "", "Notify when a tool call needs approval: off, bell or desktop (flag takes precedence over config)")
 	roleCmd.Flags().String("editor", "", "Specify the editor to use for editing tool calls.")
+	roleCmd.Flags().String("resume", "", "Continue an interactive session from the checkpoint it saved at --max-iterations (implies --interactive).")
+	roleCmd.Flags().Bool("stream", false, "Print the model's output as it arrives instead of waiting for the whole response.")
+	roleCmd.Flags().Bool("help-inputs", false, "Show the role's inputs with their descriptions and an example call, then exit.")
+	roleCmd.Flags().String("output", "text", "Output format for non-interactive mode: text, or json for {\"text\", \"tool_call\", \"raw\"}.")
 	rootCmd.AddCommand(roleCmd)
 
 	// Add completion for role names
@@ -2,12 +2,26 @@ package cmd
 
 import (
 	"ai-team/config"
+	"ai-team/pkg/cleanup"
+	"ai-team/pkg/cli"
+	"ai-team/pkg/diag"
 	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/notify"
+	"ai-team/pkg/render"
 	"ai-team/pkg/roles"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+	"ai-team/pkg/usage"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -16,6 +30,13 @@ import (
 var cfgFile string
 var logFileFlag string
 var cfg config.Config
+var pprofAddr string
+var pprofAllowRemote bool
+var runtimeMetricsInterval time.Duration
+var diagServer *diag.Server
+var uiMode string
+var keepTemp bool
+var resources *cleanup.Tracker
 
 var rootCmd = &cobra.Command{
 	Use:   "ai-team",
@@ -25,6 +46,26 @@ var rootCmd = &cobra.Command{
 			cmd.Help()
 		}
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		resources = cleanup.New(keepTemp)
+		cleanup.SetDefault(resources)
+		cleanupOnSignal()
+		ensureConfig(cmd)
+		if pprofAddr == "" && runtimeMetricsInterval <= 0 {
+			return
+		}
+		var err error
+		diagServer, err = diag.Start(pprofAddr, runtimeMetricsInterval, pprofAllowRemote)
+		if err != nil {
+			HandleError(err)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if diagServer != nil {
+			diagServer.Stop()
+		}
+		resources.Cleanup()
+	},
 }
 
 var runChainCmd = &cobra.Command{
@@ -39,26 +80,7 @@ var runChainCmd = &cobra.Command{
 			HandleError(err)
 		}
 
-		// Determine log file path (flag takes precedence)
-		logFilePath := logFileFlag
-		if logFilePath == "" {
-			logFilePath = localCfg.LogFilePath
-		}
-		if logFilePath != "" {
-			// Open log file for append
-			var logFile *os.File
-			logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to open log file %s: %v\n", logFilePath, err)
-				os.Exit(1)
-			}
-			// Multi-writer: file + stdout if LogStdout is true
-			if localCfg.LogStdout {
-				logrus.SetOutput(io.MultiWriter(os.Stdout, logFile))
-			} else {
-				logrus.SetOutput(logFile)
-			}
-		}
+		openChainLog(localCfg)
 
 		chainName := args[0]
 		inputStr, _ := cmd.Flags().GetString("input")
@@ -70,7 +92,6 @@ var runChainCmd = &cobra.Command{
 		}
 
 		// Parse input string into a map for chain command
-		// TODO: implement interactive CLI for chain command
 		initialInput := make(map[string]interface{})
 		if inputStr != "" {
 			parts := strings.Split(inputStr, "=")
@@ -81,37 +102,219 @@ var runChainCmd = &cobra.Command{
 			}
 		}
 
-		// Prefer flag over config
-		logFilePath = localCfg.LogFilePath
-
-		var result map[string]interface{}
-		result, err = roles.ExecuteChain(
-			targetChain,
-			initialInput,
-			&localCfg,
-			logFilePath, // Pass logFilePath
-		)
-		if err != nil {
-			HandleError(err)
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			e, err := roles.EstimateChain(targetChain, initialInput, &localCfg)
+			if err != nil {
+				HandleError(err)
+			}
+			fmt.Print(roles.FormatEstimate(e))
+			return
 		}
 
-		logrus.Info("Chain execution complete. Final context:")
-		for k, v := range result {
-			logrus.Infof("  %s: %v", k, v)
+		var resume *runs.Checkpoint
+		resumePath, _ := cmd.Flags().GetString("resume")
+		if resumePath != "" {
+			if inputStr != "" {
+				HandleError(errors.New(errors.ErrCodeConfig, "--input cannot be combined with --resume; the checkpoint holds the run's input", nil))
+			}
+			resume, err = runs.LoadCheckpoint(resumePath)
+			if err != nil {
+				HandleError(err)
+			}
+			if resume.Chain != chainName {
+				HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("checkpoint %s is of chain '%s', not '%s'", resumePath, resume.Chain, chainName), nil))
+			}
+			initialInput = resume.Input
 		}
+		runChain(cmd, localCfg, chainName, targetChain, initialInput, resume, resumePath)
 	},
 }
 
 func init() {
 	logrus.SetLevel(logrus.DebugLevel)
+	logrus.AddHook(roles.RunIDHook{})
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ai-team.yaml)")
+	rootCmd.PersistentFlags().StringVar(&pprofAddr, "pprof", "", "Serve pprof endpoints on this address while the command runs (e.g. 'localhost:6060')")
+	rootCmd.PersistentFlags().BoolVar(&pprofAllowRemote, "pprof-allow-remote", false, "Allow --pprof on an address reachable from other machines")
+	rootCmd.PersistentFlags().StringVar(&uiMode, "ui", "", "Terminal UI: 'default', or 'plain' for screen readers and dumb terminals (flag takes precedence over config)")
+	rootCmd.PersistentFlags().BoolVar(&keepTemp, "keep-temp", false, "Keep temporary files (patches, hook manifests, editor buffers) instead of removing them when the run ends, for debugging")
+	rootCmd.PersistentFlags().DurationVar(&runtimeMetricsInterval, "runtime-metrics", 0, "Log goroutine and memory metrics at this interval while the command runs (e.g. '30s')")
 	runChainCmd.Flags().String("input", "", "Initial input for the chain (e.g., 'problem=design a new feature')")
+	runChainCmd.Flags().String("policy", "", "Approval policy file (YAML) deciding which tool calls are allowed, denied or need confirmation")
+	runChainCmd.Flags().Bool("json", false, "Print the run result and timing summary as JSON")
+	runChainCmd.Flags().Bool("dump-context-after-step", false, "Print the chain context (secrets redacted) to stderr after every step")
+	runChainCmd.Flags().Bool("estimate", false, "Print the estimated token use and cost of the chain instead of running it")
+	runChainCmd.Flags().String("notify-on-complete", "", "Notify when the chain finishes or needs approval: off, bell or desktop (flag takes precedence over config)")
+	runChainCmd.Flags().StringArray("label", nil, "Label the run with key=value (repeatable), stored with the run record and sent with metrics events and webhooks")
+	runChainCmd.Flags().String("since", "", "Re-run after this earlier run (ID or unique prefix), reusing the model output of steps whose prompt and input are unchanged")
+	runChainCmd.Flags().String("resume", "", "Continue a run that stopped midway from its checkpoint file, skipping the steps it completed")
+	runChainCmd.Flags().StringArray("checkpoint", nil, "Save a named checkpoint, called like the step, after this step (repeatable); start a new run from it with 'runs branch <run-id>@<step>'")
+	runChainCmd.Flags().Bool("interactive", false, "Run the chain step by step: inspect each output, approve, edit or skip tool calls, edit the context or a step's input between steps, or abort")
+	runChainCmd.Flags().String("transcript", "", "With --interactive, save the decisions of the run to this file ({run_id} is replaced by the run ID), also when it is aborted")
+	runChainCmd.Flags().Bool("simulate", false, "Return scripted results for tools listed under simulation.tools instead of executing them")
+	runChainCmd.Flags().Bool("dry-run", false, "Execute no tools or hook commands; each call gets the simulated result set under simulation.results (skipped, echo, fixture or run)")
 	runChainCmd.Flags().StringVar(&logFileFlag, "logFile", "", "Path to a file to log role calls (e.g., 'role_calls.log') (flag takes precedence over config)")
 	rootCmd.AddCommand(runChainCmd)
 	// Register roleCmd from cmd/role.go only
 	// roleCmd is imported and registered in its own init()
 }
 
+// openChainLog sends the log to the file set with --logFile or in the config,
+// if any.
+func openChainLog(localCfg config.Config) {
+	// Determine log file path (flag takes precedence)
+	logFilePath := logFileFlag
+	if logFilePath == "" {
+		logFilePath = localCfg.LogFilePath
+	}
+	if logFilePath != "" {
+		// Open log file for append
+		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file %s: %v\n", logFilePath, err)
+			os.Exit(1)
+		}
+		// Multi-writer: file + stdout if LogStdout is true
+		if localCfg.LogStdout {
+			logrus.SetOutput(io.MultiWriter(os.Stdout, logFile))
+		} else {
+			logrus.SetOutput(logFile)
+		}
+	}
+}
+
+// runChain runs targetChain, the chain chainName of localCfg, with the
+// run-chain flags of cmd, continuing from resume when set. resumePath is
+// the file resume was read from, removed once the run completes; it is
+// empty for branches of a named checkpoint, which are kept.
+func runChain(cmd *cobra.Command, localCfg config.Config, chainName string, targetChain types.RoleChain, initialInput map[string]interface{}, resume *runs.Checkpoint, resumePath string) {
+	logFilePath := localCfg.LogFilePath
+	if simulate, _ := cmd.Flags().GetBool("simulate"); simulate {
+		localCfg.Simulation.Enabled = true
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		localCfg.Simulation.DryRun = true
+	}
+
+	policy, err := loadPolicy(cmd, localCfg.PolicyFile)
+	if err != nil {
+		HandleError(err)
+	}
+
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	notifier := newNotifier(cmd, localCfg)
+	ui := newUI(localCfg.UI, "")
+	confirm := ui.Confirm
+	if notifier != nil {
+		ask := confirm
+		confirm = func(prompt string) (bool, error) {
+			notifier.Notify(i18n.T("notify.title"), i18n.T("notify.approval", prompt))
+			return ask(prompt)
+		}
+	}
+	var dumpContext io.Writer
+	if dump, _ := cmd.Flags().GetBool("dump-context-after-step"); dump {
+		dumpContext = os.Stderr
+	}
+	labelFlags, _ := cmd.Flags().GetStringArray("label")
+	labels, err := runs.ParseLabels(labelFlags)
+	if err != nil {
+		HandleError(err)
+	}
+	checkpointSteps, _ := cmd.Flags().GetStringArray("checkpoint")
+	for _, step := range checkpointSteps {
+		if _, ok := targetChain.StepIndex(step); !ok || step == types.GotoEnd {
+			HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("--checkpoint %s: chain '%s' has no such step", step, chainName), nil))
+		}
+		if !runs.ValidCheckpointName(step) {
+			HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("--checkpoint %s: the step name cannot name a checkpoint; set checkpoint on the step instead", step), nil))
+		}
+	}
+	store := runs.NewStore(localCfg.RunsDir)
+	var since *runs.Record
+	if sinceID, _ := cmd.Flags().GetString("since"); sinceID != "" {
+		since, err = store.Load(sinceID)
+		if err != nil {
+			HandleError(err)
+		}
+		if since.Chain != chainName {
+			HandleError(errors.New(errors.ErrCodeRole, fmt.Sprintf("run %s is a run of chain '%s', not '%s'", since.ID, since.Chain, chainName), nil))
+		}
+	}
+	run := runs.NewRecord(chainName, initialInput)
+	run.Labels = labels
+	var session *roles.ChainSession
+	if interactive, _ := cmd.Flags().GetBool("interactive"); interactive {
+		transcriptPath, _ := cmd.Flags().GetString("transcript")
+		session = roles.NewChainSession(ui, chainName, run.ID, transcriptPath)
+	}
+	if !jsonOutput {
+		fmt.Println(i18n.T("run.id", run.ID))
+		if since != nil {
+			fmt.Println(i18n.T("run.since", since.ID))
+		}
+		if resume != nil && resume.Name != "" {
+			fmt.Println(i18n.T("run.branched", resume.RunID, resume.Name, resume.NextStep))
+		} else if resume != nil {
+			fmt.Println(i18n.T("run.resumed", resume.RunID, resume.NextStep))
+		}
+	}
+
+	opts := roles.ChainOptions{
+		LogFilePath:     logFilePath,
+		Run:             run,
+		Store:           store,
+		Since:           since,
+		Checkpoint:      store.CheckpointPath(run.ID),
+		Resume:          resume,
+		Confirm:         confirm,
+		Policy:          policy,
+		DumpContext:     dumpContext,
+		Resources:       resources,
+		CheckpointSteps: checkpointSteps,
+	}
+	if session != nil {
+		opts.Interactive = session
+	}
+	var result map[string]interface{}
+	started := time.Now()
+	result, err = roles.ExecuteChainWithOptions(targetChain, initialInput, &localCfg, opts)
+	elapsed := time.Since(started).Round(time.Second)
+	if session != nil {
+		if transcriptErr := session.WriteTranscript(); transcriptErr != nil {
+			logrus.Warn(transcriptErr)
+		}
+	}
+	if err != nil {
+		notifier.Finished(i18n.T("notify.title"), i18n.T("notify.chain_failed", chainName, elapsed), elapsed)
+	} else {
+		notifier.Finished(i18n.T("notify.title"), i18n.T("notify.chain_done", chainName, elapsed), elapsed)
+	}
+	if jsonOutput {
+		printRunJSON(run, result)
+	} else {
+		fmt.Printf("\n%s\n%s", i18n.T("run.timing"), runs.FormatTiming(run.ComputeTiming()))
+		fmt.Println(i18n.T("run.usage", usage.Default.Totals(localCfg.Currency())))
+		fmt.Print(usage.Default.Report(localCfg.Currency()))
+	}
+	if len(run.Checkpoints) > 0 && !jsonOutput {
+		fmt.Println(i18n.T("run.checkpoints", strings.Join(run.Checkpoints, ", "), run.ID))
+	}
+	if _, statErr := os.Stat(store.CheckpointPath(run.ID)); statErr == nil {
+		fmt.Fprintln(os.Stderr, i18n.T("run.resume_hint", chainName, store.CheckpointPath(run.ID)))
+	} else if resumePath != "" {
+		os.Remove(resumePath)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	logrus.Info("Chain execution complete. Final context:")
+	for k, v := range result {
+		logrus.Infof("  %s: %v", k, v)
+	}
+}
+
 func ExecuteCmd() { // Renamed to ExecuteCmd
 	if err := rootCmd.Execute(); err != nil {
 		HandleError(err)
@@ -120,14 +323,92 @@ func ExecuteCmd() { // Renamed to ExecuteCmd
 
 // HandleError handles errors by printing them to stderr and exiting.
 func HandleError(err error) {
+	r := render.Stderr()
 	if e, ok := err.(*errors.Error); ok {
-		logrus.Errorf("Error: %s (code: %d)", e.Message, e.Code)
+		logrus.Error(r.Paint(render.Error, fmt.Sprintf("Error: %s (code: %d)", e.Message, e.Code)))
 		if e.Err != nil {
-			logrus.Errorf("  Caused by: %v", e.Err)
+			logrus.Error(r.Paint(render.Error, fmt.Sprintf("  Caused by: %v", e.Err)))
 		}
 	} else {
-		logrus.Errorf("An unexpected error occurred: %v", err)
+		logrus.Error(r.Paint(render.Error, fmt.Sprintf("An unexpected error occurred: %v", err)))
 	}
 	// Still exit after logging
+	resources.Cleanup()
 	os.Exit(1)
 }
+
+// cleanupOnSignal kills the tracked child processes and removes temporary
+// files when the command is interrupted. Commands run in process groups of
+// their own, so they no longer receive the terminal's Ctrl-C themselves.
+func cleanupOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logrus.Warnf("Received %s, cleaning up", sig)
+		resources.Cleanup()
+		os.Exit(130)
+	}()
+}
+
+// printRunJSON writes the outcome of a chain run, including its timing summary, to stdout.
+func printRunJSON(run *runs.Record, result map[string]interface{}) {
+	out := map[string]interface{}{
+		"run_id":         run.ID,
+		"status":         run.Status,
+		"result":         result,
+		"timing":         run.ComputeTiming(),
+		"usage":          usage.Default.Summary(),
+		"cost":           run.Cost,
+		"usage_by_role":  usage.Default.Roles(),
+		"usage_by_chain": usage.Default.Chains(),
+	}
+	if run.Error != "" {
+		out["error"] = run.Error
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		HandleError(errors.New(errors.ErrCodeUnknown, "failed to marshal run output", err))
+	}
+	fmt.Println(string(data))
+}
+
+// loadPolicy loads the approval policy named by --policy, falling back to the
+// configured policy file. It returns nil when neither is set.
+func loadPolicy(cmd *cobra.Command, configured string) (*tools.Policy, error) {
+	path, _ := cmd.Flags().GetString("policy")
+	if path == "" {
+		path = configured
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return tools.LoadPolicy(path)
+}
+
+// newUI returns the UI selected by --ui or, when the flag is unset, by the
+// configured mode.
+func newUI(configured, editor string) cli.UI {
+	mode := uiMode
+	if mode == "" {
+		mode = configured
+	}
+	ui, err := cli.New(mode, editor)
+	if err != nil {
+		HandleError(err)
+	}
+	return ui
+}
+
+// newNotifier returns the notifier selected by --notify-on-complete or, when
+// the flag is unset, by the config; nil when notifications are off.
+func newNotifier(cmd *cobra.Command, localCfg config.Config) *notify.Notifier {
+	mode, _ := cmd.Flags().GetString("notify-on-complete")
+	if mode == "" {
+		mode = localCfg.Notify.OnComplete
+	}
+	if !notify.Valid(mode) {
+		HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid --notify-on-complete '%s' (expected one of %s)", mode, strings.Join(notify.Modes, ", ")), nil))
+	}
+	return notify.New(mode, localCfg.Notify.After)
+}
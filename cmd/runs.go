@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Inspect recorded chain runs.",
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded chain runs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		labelFlags, _ := cmd.Flags().GetStringArray("label")
+		filter, err := runs.ParseLabels(labelFlags)
+		if err != nil {
+			HandleError(err)
+		}
+		store := runsStore()
+		records, err := store.List()
+		if err != nil {
+			HandleError(err)
+		}
+		listed := 0
+		for _, r := range records {
+			if !r.HasLabels(filter) {
+				continue
+			}
+			listed++
+			entry := i18n.T("runs.entry", r.ID, r.Status, r.Chain, r.StartedAt.Format(time.RFC3339), len(r.Steps))
+			if len(r.Labels) > 0 {
+				entry += "  " + runs.FormatLabels(r.Labels)
+			}
+			fmt.Println(entry)
+		}
+		if listed == 0 {
+			fmt.Println(i18n.T("runs.none", store.Dir))
+		}
+	},
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show a run's status, cost and the changes it made.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		record, err := runsStore().Load(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Print(runs.Show(record))
+	},
+}
+
+var runsExportCmd = &cobra.Command{
+	Use:   "export <run-id>",
+	Short: "Export a run as a Markdown or HTML report.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		outPath, _ := cmd.Flags().GetString("out")
+
+		record, err := runsStore().Load(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		report, err := runs.Export(record, format)
+		if err != nil {
+			HandleError(err)
+		}
+		if outPath == "" {
+			fmt.Print(report)
+			return
+		}
+		outPath = runs.ExpandRunID(outPath, record.ID)
+		if err := os.WriteFile(outPath, []byte(report), 0644); err != nil {
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("runs.exported", record.ID, outPath))
+	},
+}
+
+var runsContextCmd = &cobra.Command{
+	Use:   "context <run-id>",
+	Short: "Print the chain context recorded after a step of a run.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		step, _ := cmd.Flags().GetInt("step")
+		iteration, _ := cmd.Flags().GetInt("iteration")
+
+		record, err := runsStore().Load(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		if step <= 0 {
+			if len(record.Steps) == 0 {
+				HandleError(errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run %s has no steps", record.ID), nil))
+			}
+			step = record.Steps[len(record.Steps)-1].Index + 1
+		}
+		context, err := record.ContextAt(step-1, iteration-1)
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Print(runs.FormatContext(context))
+	},
+}
+
+var runsDiffCmd = &cobra.Command{
+	Use:   "diff <run-a> <run-b>",
+	Short: "Diff the rendered prompts of two runs step by step.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		store := runsStore()
+		a, err := store.Load(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		b, err := store.Load(args[1])
+		if err != nil {
+			HandleError(err)
+		}
+		diffs := runs.DiffPrompts(a, b)
+		if jsonOutput {
+			data, err := json.MarshalIndent(diffs, "", "  ")
+			if err != nil {
+				HandleError(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Print(render.Stdout().Diff(runs.FormatPromptDiffs(a, b, diffs)))
+	},
+}
+
+var runsBranchCmd = &cobra.Command{
+	Use:   "branch <run-id>@<checkpoint>",
+	Short: "Start a new run from a named checkpoint of a run, with changed input.",
+	Long: `Starts a new run of the chain from a named checkpoint of an earlier run,
+saved after a step with checkpoint set or run with --checkpoint. The steps
+before the checkpoint are not run again; the new run continues with the
+context they left, with the values of --input set in it and in the run's
+input. The earlier run and its checkpoint are kept, so several branches can
+start from the same point.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, name, err := runs.ParseCheckpointRef(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		localCfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		openChainLog(localCfg)
+		cp, err := runs.NewStore(localCfg.RunsDir).LoadNamedCheckpoint(id, name)
+		if err != nil {
+			HandleError(err)
+		}
+		targetChain, ok := localCfg.Chains[cp.Chain]
+		if !ok {
+			HandleError(errors.New(errors.ErrCodeRole, fmt.Sprintf("role chain '%s' not found in config", cp.Chain), nil))
+		}
+		inputFlags, _ := cmd.Flags().GetStringArray("input")
+		input := map[string]interface{}{}
+		for _, kv := range inputFlags {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || strings.TrimSpace(k) == "" {
+				HandleError(errors.New(errors.ErrCodeRole, fmt.Sprintf("invalid input '%s'. Expected key=value", kv), nil))
+			}
+			input[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		branch := cp.Branch(input)
+		runChain(cmd, localCfg, cp.Chain, targetChain, branch.Input, branch, "")
+	},
+}
+
+var runsBugReportCmd = &cobra.Command{
+	Use:   "bug-report <run-id>",
+	Short: "Pack a run with its config, logs and environment for an issue report.",
+	Long: `Writes a zip archive to attach to an issue about a failed run: the run
+record, the config file, the run's log and step logs, its checkpoint, any
+--transcript files, and the versions and OS in use. API keys, values of
+secret-looking keys such as api_key or token, and the values of secret
+environment variables are replaced by [REDACTED]. Review the archive before
+sharing it, as prompts and outputs are included as they were.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outPath, _ := cmd.Flags().GetString("out")
+		transcripts, _ := cmd.Flags().GetStringArray("transcript")
+		noConfig, _ := cmd.Flags().GetBool("no-config")
+
+		localCfg, cfgErr := config.LoadConfig(cfgFile)
+		store := runs.NewStore(localCfg.RunsDir)
+		record, err := store.Load(args[0])
+		if err != nil {
+			HandleError(err)
+		}
+		report := runs.BugReport{
+			Record:      record,
+			Files:       map[string]string{},
+			Environment: bugReportEnvironment(),
+			Secrets:     runs.EnvSecrets(os.Environ()),
+		}
+		if path, err := config.ResolvePath(cfgFile); err == nil && !noConfig {
+			report.Environment["config_path"] = path
+			if data, err := os.ReadFile(path); err == nil {
+				report.Config = data
+			}
+		}
+		if cfgErr != nil {
+			report.Environment["config_error"] = cfgErr.Error()
+		}
+		if localCfg.LogFilePath != "" {
+			logPath := runs.ExpandRunID(localCfg.LogFilePath, record.ID)
+			report.Files["logs/"+filepath.Base(logPath)] = logPath
+		}
+		if localCfg.Logging.StepOutput == types.StepLogFiles {
+			for name, path := range runs.StepLogFiles(localCfg.Logging.Dir, record.ID) {
+				report.Files[name] = path
+			}
+		}
+		checkpoint := store.CheckpointPath(record.ID)
+		if _, err := os.Stat(checkpoint); err == nil {
+			report.Files["checkpoint.json"] = checkpoint
+		}
+		for _, t := range transcripts {
+			t = runs.ExpandRunID(t, record.ID)
+			report.Files["transcripts/"+filepath.Base(t)] = t
+		}
+
+		outPath = runs.ExpandRunID(outPath, record.ID)
+		out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeUnknown, "failed to create bug report: "+outPath, err))
+		}
+		names, err := runs.WriteBugReport(out, report)
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = errors.New(errors.ErrCodeUnknown, "failed to write bug report: "+outPath, closeErr)
+		}
+		if err != nil {
+			os.Remove(outPath)
+			HandleError(err)
+		}
+		fmt.Println(i18n.T("runs.bug_report_written", record.ID, outPath, strings.Join(names, ", ")))
+		fmt.Println(i18n.T("runs.bug_report_review"))
+	},
+}
+
+// bugReportEnvironment describes the build and the machine for a bug report.
+func bugReportEnvironment() map[string]string {
+	env := map[string]string{
+		"go_version":     runtime.Version(),
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"cpus":           strconv.Itoa(runtime.NumCPU()),
+		"config_version": strconv.Itoa(config.CurrentVersion),
+		"generated_at":   time.Now().Format(time.RFC3339),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		env["ai_team_version"] = info.Main.Version
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+				env["ai_team_"+strings.TrimPrefix(s.Key, "vcs.")] = s.Value
+			}
+		}
+	}
+	// The distribution, on Linux.
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				env["os_release"] = strings.Trim(v, `"`)
+			}
+		}
+	}
+	return env
+}
+
+// runsStore returns the run store configured in the config file, falling back
+// to the default directory when no config can be loaded.
+func runsStore() *runs.Store {
+	localCfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return runs.NewStore("")
+	}
+	return runs.NewStore(localCfg.RunsDir)
+}
+
+func init() {
+	runsListCmd.Flags().StringArray("label", nil, "Only list runs with this key=value label (repeatable; an empty value matches any value).")
+	runsExportCmd.Flags().String("format", "md", "Report format: md or html.")
+	runsExportCmd.Flags().String("out", "", "Write the report to a file instead of stdout ({run_id} is replaced by the run ID).")
+	runsContextCmd.Flags().Int("step", 0, "Step number (1-based; default: the last step run).")
+	runsContextCmd.Flags().Int("iteration", 0, "Loop iteration of the step (1-based; default: the last one).")
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsShowCmd)
+	runsCmd.AddCommand(runsExportCmd)
+	runsCmd.AddCommand(runsContextCmd)
+	runsDiffCmd.Flags().Bool("json", false, "Print the per-step diffs as JSON.")
+	runsCmd.AddCommand(runsDiffCmd)
+	runsBranchCmd.Flags().StringArray("input", nil, "Set key=value in the input and context of the new run (repeatable).")
+	runsBranchCmd.Flags().StringArray("checkpoint", nil, "Save a named checkpoint, called like the step, after this step (repeatable).")
+	runsBranchCmd.Flags().StringArray("label", nil, "Label the run with key=value (repeatable).")
+	runsBranchCmd.Flags().Bool("json", false, "Print the run result and timing summary as JSON.")
+	runsBranchCmd.Flags().Bool("interactive", false, "Run the remaining steps step by step, as with run-chain --interactive.")
+	runsBranchCmd.Flags().String("transcript", "", "With --interactive, save the decisions of the run to this file ({run_id} is replaced by the run ID).")
+	runsBranchCmd.Flags().Bool("dry-run", false, "Execute no tools or hook commands, as with run-chain --dry-run.")
+	runsBranchCmd.Flags().String("policy", "", "Approval policy file (YAML) deciding which tool calls are allowed, denied or need confirmation.")
+	runsBranchCmd.Flags().StringVar(&logFileFlag, "logFile", "", "Path to a file to log role calls (flag takes precedence over config).")
+	runsCmd.AddCommand(runsBranchCmd)
+	runsBugReportCmd.Flags().String("out", "ai-team-bug-{run_id}.zip", "Archive to write ({run_id} is replaced by the run ID).")
+	runsBugReportCmd.Flags().StringArray("transcript", nil, "Add a session or chain transcript ({run_id} is replaced by the run ID; repeatable).")
+	runsBugReportCmd.Flags().Bool("no-config", false, "Leave the config file out of the archive.")
+	runsCmd.AddCommand(runsBugReportCmd)
+	rootCmd.AddCommand(runsCmd)
+}
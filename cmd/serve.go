@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"ai-team/config"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/server"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve roles and chains over HTTP, with progress as server-sent events.",
+	Run: func(cmd *cobra.Command, args []string) {
+		localCfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		policy, err := loadPolicy(cmd, localCfg.PolicyFile)
+		if err != nil {
+			HandleError(err)
+		}
+		addr, _ := cmd.Flags().GetString("addr")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("AI_TEAM_SERVE_TOKEN")
+		}
+
+		srv := &server.Server{
+			Config: &localCfg,
+			Store:  runs.NewStore(localCfg.RunsDir),
+			Policy: policy,
+			Token:  token,
+		}
+		if err := srv.ListenAndServe(context.Background(), addr); err != nil {
+			HandleError(err)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().String("token", "", "Require this bearer token on every request (default $AI_TEAM_SERVE_TOKEN); needed for addresses other than loopback")
+	serveCmd.Flags().String("policy", "", "Approval policy file (YAML) deciding which tool calls are allowed; calls needing confirmation are denied")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ai-team/config"
+	"ai-team/pkg/cli"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter config file with guided setup.",
+	Run: func(cmd *cobra.Command, args []string) {
+		path := cfgFile
+		if path == "" {
+			var err error
+			if path, err = config.DefaultSetupPath(); err != nil {
+				HandleError(err)
+			}
+		}
+		if err := guidedSetup(&cli.PlainUI{}, path); err != nil {
+			HandleError(err)
+		}
+	},
+}
+
+// needsConfig reports whether cmd reads the config file. Help, completion
+// and the config, bundle, registry and report commands work without one.
+func needsConfig(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return false // ai-team without a command prints help
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd,
+			"config", "bundle", "registry", "report":
+			return false
+		}
+	}
+	return true
+}
+
+// ensureConfig offers the guided setup when cmd needs a config file and none
+// is found. Without a terminal to ask on, it fails with a hint instead.
+func ensureConfig(cmd *cobra.Command) {
+	if !needsConfig(cmd) || !config.Missing(cfgFile) {
+		return
+	}
+	if !cli.IsTerminal(os.Stdin) {
+		HandleError(errors.New(errors.ErrCodeConfig, i18n.T("setup.missing_noninteractive"), nil))
+	}
+	path, err := config.DefaultSetupPath()
+	if err != nil {
+		HandleError(err)
+	}
+	ui := &cli.PlainUI{}
+	fmt.Println(i18n.T("setup.missing"))
+	if ok, err := ui.Confirm(i18n.T("setup.offer", path)); err != nil || !ok {
+		HandleError(errors.New(errors.ErrCodeConfig, i18n.T("setup.missing_noninteractive"), nil))
+	}
+	if err := guidedSetup(ui, path); err != nil {
+		HandleError(err)
+	}
+}
+
+// guidedSetup asks for a provider, its API key and a model and writes the
+// starter config to path.
+func guidedSetup(ui *cli.PlainUI, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("%s already exists; edit it or remove it first", path), nil)
+	}
+	names := make([]string, len(config.SetupProviders))
+	for i, p := range config.SetupProviders {
+		names[i] = p.Name
+	}
+	fmt.Println(i18n.T("setup.provider"))
+	name, err := ui.PromptSelect(names)
+	if err != nil {
+		return err
+	}
+	var setup config.Setup
+	for _, p := range config.SetupProviders {
+		if p.Name == name {
+			setup.Provider = p
+		}
+	}
+	if setup.Provider.NeedsKey {
+		setup.APIKey, err = cli.ReadSecret(i18n.T("setup.api_key", name) + " ")
+		if err != nil {
+			return errors.New(errors.ErrCodeConfig, "failed to read the API key", err)
+		}
+		if setup.APIKey == "" {
+			fmt.Println(i18n.T("setup.api_key_empty", path))
+		}
+	}
+	model, err := ui.PromptLine(i18n.T("setup.model", setup.Provider.Model) + " ")
+	if err != nil {
+		return err
+	}
+	setup.Model = strings.TrimSpace(model)
+	if err := config.WriteSetup(path, setup); err != nil {
+		return err
+	}
+	fmt.Println(i18n.T("common.wrote", path))
+	fmt.Println(i18n.T("setup.next"))
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configInitCmd)
+}
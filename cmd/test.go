@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"ai-team/config"
+	"ai-team/pkg/chaintest"
+	"ai-team/pkg/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [path...]",
+	Short: "Run chain tests (*.test.yaml) against scripted model outputs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		localCfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		paths := args
+		if len(paths) == 0 {
+			paths = []string{"chains"}
+		}
+		files, err := chaintest.Find(paths)
+		if err != nil {
+			HandleError(err)
+		}
+		if len(files) == 0 {
+			fmt.Println(i18n.T("test.none", paths))
+			os.Exit(1)
+		}
+
+		failed := 0
+		for _, file := range files {
+			test, err := chaintest.LoadFile(file)
+			if err != nil {
+				HandleError(err)
+			}
+			result, err := chaintest.Run(&localCfg, test)
+			if err != nil {
+				HandleError(err)
+			}
+			if result.Passed() {
+				fmt.Println(i18n.T("test.pass", test.Name))
+				continue
+			}
+			failed++
+			fmt.Println(i18n.T("test.fail", test.Name, file))
+			for _, failure := range result.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+		}
+		fmt.Println(i18n.T("test.summary", len(files)-failed, failed))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/tools"
+
+	"github.com/spf13/cobra"
+)
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Manage the config tools.",
+}
+
+var toolsImportCmd = &cobra.Command{
+	Use:   "import <functions.json>",
+	Short: "Add OpenAI function definitions to the config tools.",
+	Long: `Converts OpenAI function definitions (a list of tools or functions, an
+object with "tools" or "functions", or a single function) to config tools.
+Each function runs the command template given with --command name=template,
+or else posts its arguments to --endpoint, where {name} stands for the
+function name. Tools that differ from the local ones of the same name are
+handled like conflicts of bundle import.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		keep, _ := cmd.Flags().GetBool("keep")
+		if overwrite && keep {
+			HandleError(errors.New(errors.ErrCodeConfig, "--overwrite and --keep are mutually exclusive", nil))
+		}
+		mapping := config.OpenAIToolMapping{Commands: map[string]string{}}
+		mapping.Endpoint, _ = cmd.Flags().GetString("endpoint")
+		commands, _ := cmd.Flags().GetStringArray("command")
+		for _, c := range commands {
+			name, template, ok := strings.Cut(c, "=")
+			if !ok || name == "" || template == "" {
+				HandleError(errors.New(errors.ErrCodeConfig, fmt.Sprintf("--command must be name=template, got '%s'", c), nil))
+			}
+			mapping.Commands[name] = template
+		}
+
+		definitions, err := os.ReadFile(args[0])
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read function definitions: "+args[0], err))
+		}
+		functions, err := config.ParseOpenAIFunctions(definitions)
+		if err != nil {
+			HandleError(err)
+		}
+		path, err := config.ResolvePath(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			HandleError(errors.New(errors.ErrCodeConfig, "failed to read config file: "+path, err))
+		}
+		result, err := config.ImportOpenAIFunctions(data, functions, mapping, importResolver(newUI(configuredUI(data), ""), overwrite, keep))
+		if err != nil {
+			HandleError(err)
+		}
+		if writeImport(path, result) {
+			fmt.Println(i18n.T("tools.imported", len(functions), path))
+		}
+	},
+}
+
+var toolsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the tool schemas as a function-calling API receives them.",
+	Long: `Prints the built-in and config tools as the JSON that native tool calling
+sends: the tools array of OpenAI, the functionDeclarations of Gemini or the
+tool blocks of Anthropic. --mcp connects to the configured MCP servers to
+include their tools too.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		withMCP, _ := cmd.Flags().GetBool("mcp")
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			HandleError(err)
+		}
+		registry := tools.NewToolRegistry()
+		tools.RegisterDefaultTools(registry)
+		if err := tools.RegisterConfigTools(registry, cfg.Tools); err != nil {
+			HandleError(err)
+		}
+		if withMCP {
+			clients := tools.ConnectMCPServers(context.Background(), cfg.MCPServers, registry)
+			defer clients.Close()
+		}
+		data, err := registry.ExportSchemas(format)
+		if err != nil {
+			HandleError(err)
+		}
+		fmt.Println(string(data))
+	},
+}
+
+func init() {
+	toolsExportCmd.Flags().String("format", tools.SchemaFormatOpenAI, "Schema format: "+strings.Join(tools.SchemaFormats, ", "))
+	toolsExportCmd.Flags().Bool("mcp", false, "Include the tools of the configured MCP servers")
+	toolsImportCmd.Flags().StringArray("command", nil, "Command template of a function, as name=template (repeatable)")
+	toolsImportCmd.Flags().String("endpoint", "", "URL to post the arguments of functions without --command to; {name} is the function name")
+	toolsImportCmd.Flags().Bool("overwrite", false, "Replace conflicting local tools without asking")
+	toolsImportCmd.Flags().Bool("keep", false, "Keep conflicting local tools without asking")
+	toolsCmd.AddCommand(toolsImportCmd, toolsExportCmd)
+	rootCmd.AddCommand(toolsCmd)
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"ai-team/pkg/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the bundle format this build writes and reads.
+const BundleVersion = 1
+
+// Bundle sections, named after the config sections they merge into.
+const (
+	bundleChains = "chains"
+	bundleRoles  = "roles"
+	bundleTools  = "tools"
+)
+
+// BundleConflict is a bundle entry that differs from the local entry of the
+// same name. Local and Incoming are the two definitions as YAML.
+type BundleConflict struct {
+	Section  string // "chains", "roles" or "tools"
+	Name     string
+	Local    string
+	Incoming string
+}
+
+// BundleImport describes the outcome of ImportBundle. Entries are listed as
+// section.name.
+type BundleImport struct {
+	Chain     string
+	Added     []string
+	Replaced  []string
+	Kept      []string // Conflicts resolved in favor of the local entry
+	Unchanged []string
+	Data      []byte // The merged config document
+}
+
+// Changed reports whether the import added or replaced anything.
+func (r BundleImport) Changed() bool {
+	return len(r.Added) > 0 || len(r.Replaced) > 0
+}
+
+// ExportBundle returns a bundle of the named chain from a config document: the
+// chain, the roles its steps and on_success hook use, and the config tools.
+// Prompts are part of the roles, so the bundle is self-contained.
+func ExportBundle(data []byte, chain string) ([]byte, error) {
+	root, _, err := parseConfigDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	chainNode := mappingValue(mappingValue(root, bundleChains), chain)
+	if chainNode == nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' not found", chain), nil)
+	}
+
+	out := &yaml.Node{Kind: yaml.MappingNode}
+	header := &yaml.Node{Kind: yaml.MappingNode}
+	appendMapping(header, "version", intNode(BundleVersion))
+	appendMapping(header, "config_version", intNode(CurrentVersion))
+	appendMapping(header, "chain", strNode(chain))
+	appendMapping(out, "bundle", header)
+
+	chains := &yaml.Node{Kind: yaml.MappingNode}
+	appendMapping(chains, chain, chainNode)
+	appendMapping(out, bundleChains, chains)
+
+	localRoles := mappingValue(root, bundleRoles)
+	roles := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range chainRoles(chainNode) {
+		role := mappingValue(localRoles, name)
+		if role == nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' uses undefined role '%s'", chain, name), nil)
+		}
+		appendMapping(roles, name, role)
+	}
+	if len(roles.Content) > 0 {
+		appendMapping(out, bundleRoles, roles)
+	}
+	if tools := mappingValue(root, bundleTools); tools != nil && len(tools.Content) > 0 {
+		appendMapping(out, bundleTools, tools)
+	}
+	return encodeYAML(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{out}})
+}
+
+// ImportBundle merges a bundle into a config document. New entries are added;
+// for each entry that differs from the local one, resolve decides whether the
+// bundle's replaces it. The document is migrated to CurrentVersion first.
+func ImportBundle(data, bundle []byte, resolve func(BundleConflict) (bool, error)) (BundleImport, error) {
+	var result BundleImport
+	var bdoc yaml.Node
+	if err := yaml.Unmarshal(bundle, &bdoc); err != nil {
+		return result, errors.New(errors.ErrCodeConfig, "failed to parse bundle", err)
+	}
+	if len(bdoc.Content) == 0 || bdoc.Content[0].Kind != yaml.MappingNode {
+		return result, errors.New(errors.ErrCodeConfig, "bundle is empty", nil)
+	}
+	broot := bdoc.Content[0]
+	header := mappingValue(broot, "bundle")
+	if header == nil {
+		return result, errors.New(errors.ErrCodeConfig, "not an ai-team bundle: missing bundle header", nil)
+	}
+	if v := intValue(mappingValue(header, "version")); v > BundleVersion {
+		return result, errors.New(errors.ErrCodeConfig, fmt.Sprintf("bundle version %d is newer than this build supports (%d)", v, BundleVersion), nil)
+	}
+	if v := intValue(mappingValue(header, "config_version")); v > CurrentVersion {
+		return result, errors.New(errors.ErrCodeConfig, fmt.Sprintf("bundle was exported from config version %d, newer than this build supports (%d)", v, CurrentVersion), nil)
+	}
+	if chain := mappingValue(header, "chain"); chain != nil {
+		result.Chain = chain.Value
+	}
+
+	root, doc, err := parseConfigDocument(data)
+	if err != nil {
+		return result, err
+	}
+	for _, section := range []string{bundleRoles, bundleChains} {
+		incoming := mappingValue(broot, section)
+		if incoming == nil {
+			continue
+		}
+		local := mappingValue(root, section)
+		if local == nil {
+			local = &yaml.Node{Kind: yaml.MappingNode}
+			appendMapping(root, section, local)
+		}
+		for i := 0; i+1 < len(incoming.Content); i += 2 {
+			name, node := incoming.Content[i].Value, incoming.Content[i+1]
+			existing := mappingValue(local, name)
+			if err := mergeEntry(&result, resolve, section, name, existing, node, func() {
+				if existing != nil {
+					*existing = *node
+				} else {
+					appendMapping(local, name, node)
+				}
+			}); err != nil {
+				return result, err
+			}
+		}
+	}
+	if incoming := mappingValue(broot, bundleTools); incoming != nil {
+		if err := mergeTools(&result, resolve, root, incoming); err != nil {
+			return result, err
+		}
+	}
+
+	result.Data = data
+	if result.Changed() {
+		if result.Data, err = encodeYAML(doc); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// mergeEntry applies one bundle entry with set, asking resolve when a
+// different local entry exists, and records the outcome.
+func mergeEntry(result *BundleImport, resolve func(BundleConflict) (bool, error), section, name string, existing, incoming *yaml.Node, set func()) error {
+	label := section + "." + name
+	if existing == nil {
+		set()
+		result.Added = append(result.Added, label)
+		return nil
+	}
+	if sameYAML(existing, incoming) {
+		result.Unchanged = append(result.Unchanged, label)
+		return nil
+	}
+	localYAML, _ := encodeYAML(existing)
+	incomingYAML, _ := encodeYAML(incoming)
+	replace, err := resolve(BundleConflict{Section: section, Name: name, Local: string(localYAML), Incoming: string(incomingYAML)})
+	if err != nil {
+		return err
+	}
+	if !replace {
+		result.Kept = append(result.Kept, label)
+		return nil
+	}
+	set()
+	result.Replaced = append(result.Replaced, label)
+	return nil
+}
+
+// mergeTools merges a sequence of incoming tools into the tools of root,
+// matching them by name.
+func mergeTools(result *BundleImport, resolve func(BundleConflict) (bool, error), root, incoming *yaml.Node) error {
+	local := mappingValue(root, bundleTools)
+	if local == nil {
+		local = &yaml.Node{Kind: yaml.SequenceNode}
+		appendMapping(root, bundleTools, local)
+	}
+	for _, node := range incoming.Content {
+		name := scalarValue(mappingValue(node, "name"))
+		var existing *yaml.Node
+		for _, t := range local.Content {
+			if scalarValue(mappingValue(t, "name")) == name {
+				existing = t
+			}
+		}
+		if err := mergeEntry(result, resolve, bundleTools, name, existing, node, func() {
+			if existing != nil {
+				*existing = *node
+			} else {
+				local.Content = append(local.Content, node)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseConfigDocument parses a config document migrated to CurrentVersion and
+// returns its root mapping and the document.
+func parseConfigDocument(data []byte) (*yaml.Node, *yaml.Node, error) {
+	migrated, err := Migrate(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(migrated.Data, &doc); err != nil {
+		return nil, nil, errors.New(errors.ErrCodeConfig, "failed to parse config", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New(errors.ErrCodeConfig, "config is not a mapping", nil)
+	}
+	return doc.Content[0], &doc, nil
+}
+
+// chainRoles returns the roles a chain node uses, in order of first use.
+func chainRoles(chain *yaml.Node) []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(node *yaml.Node) {
+		if name := scalarValue(mappingValue(node, "role")); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if steps := mappingValue(chain, "steps"); steps != nil {
+		for _, step := range steps.Content {
+			add(step)
+		}
+	}
+	add(mappingValue(chain, "on_success"))
+	return names
+}
+
+func sameYAML(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func appendMapping(mapping *yaml.Node, key string, value *yaml.Node) {
+	mapping.Content = append(mapping.Content, strNode(key), value)
+}
+
+func strNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func intNode(value int) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(value)}
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func intValue(node *yaml.Node) int {
+	v, _ := strconv.Atoi(scalarValue(node))
+	return v
+}
+
+func encodeYAML(node *yaml.Node) ([]byte, error) {
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, "failed to encode YAML", err)
+	}
+	enc.Close()
+	return out.Bytes(), nil
+}
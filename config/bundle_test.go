@@ -0,0 +1,113 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const bundleSource = `version: 1
+roles:
+  coder:
+    model_provider: openai
+    model_name: gpt-4
+    prompt: "Write {{.task}}" # the coder prompt
+  reviewer:
+    model_provider: openai
+    model_name: gpt-4
+    prompt: "Review {{.code}}"
+  unused:
+    model_provider: openai
+    model_name: gpt-4
+    prompt: "Unused"
+tools:
+  - name: lint
+    command_template: "golangci-lint run"
+chains:
+  build:
+    steps:
+      - role: coder
+      - role: reviewer
+`
+
+func TestExportBundle(t *testing.T) {
+	bundle, err := ExportBundle([]byte(bundleSource), "build")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct {
+		Bundle struct {
+			Version int    `yaml:"version"`
+			Chain   string `yaml:"chain"`
+		} `yaml:"bundle"`
+		Chains map[string]interface{}   `yaml:"chains"`
+		Roles  map[string]interface{}   `yaml:"roles"`
+		Tools  []map[string]interface{} `yaml:"tools"`
+	}
+	if err := yaml.Unmarshal(bundle, &got); err != nil {
+		t.Fatalf("bundle is not YAML: %v\n%s", err, bundle)
+	}
+	if got.Bundle.Version != BundleVersion || got.Bundle.Chain != "build" || len(got.Chains) != 1 {
+		t.Errorf("unexpected header or chains: %+v", got)
+	}
+	if len(got.Roles) != 2 || got.Roles["unused"] != nil {
+		t.Errorf("expected only the coder and reviewer roles, got %v", got.Roles)
+	}
+	if len(got.Tools) != 1 {
+		t.Errorf("expected the config tools, got %v", got.Tools)
+	}
+	if !strings.Contains(string(bundle), "# the coder prompt") {
+		t.Errorf("expected comments to be kept:\n%s", bundle)
+	}
+	if _, err := ExportBundle([]byte(bundleSource), "missing"); err == nil {
+		t.Error("expected error for an unknown chain")
+	}
+}
+
+func TestImportBundle(t *testing.T) {
+	bundle, err := ExportBundle([]byte(bundleSource), "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := `version: 1
+roles:
+  reviewer:
+    model_provider: ollama
+    model_name: llama2
+    prompt: "Review {{.code}}"
+`
+	var conflicts []string
+	result, err := ImportBundle([]byte(local), bundle, func(c BundleConflict) (bool, error) {
+		conflicts = append(conflicts, c.Section+"."+c.Name)
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(conflicts, ",") != "roles.reviewer" || strings.Join(result.Kept, ",") != "roles.reviewer" {
+		t.Errorf("expected a kept conflict on the reviewer, got %v / %+v", conflicts, result)
+	}
+	if strings.Join(result.Added, ",") != "roles.coder,chains.build,tools.lint" {
+		t.Errorf("unexpected added entries: %v", result.Added)
+	}
+	if !strings.Contains(string(result.Data), "llama2") || !strings.Contains(string(result.Data), "Write {{.task}}") {
+		t.Errorf("expected the local reviewer and the imported coder:\n%s", result.Data)
+	}
+
+	// Importing again changes nothing; replacing takes the bundle's reviewer.
+	again, err := ImportBundle(result.Data, bundle, func(BundleConflict) (bool, error) { return true, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Added) != 0 || strings.Join(again.Replaced, ",") != "roles.reviewer" {
+		t.Errorf("unexpected second import: %+v", again)
+	}
+	if strings.Contains(string(again.Data), "llama2") {
+		t.Errorf("expected the reviewer to be replaced:\n%s", again.Data)
+	}
+
+	if _, err := ImportBundle([]byte(local), []byte("roles: {}\n"), nil); err == nil {
+		t.Error("expected error for a file without a bundle header")
+	}
+}
@@ -1,9 +1,21 @@
 package config
 
 import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/cli"
 	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/notify"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
 	"ai-team/pkg/types" // Import types package
+	"ai-team/pkg/usage"
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -11,25 +23,113 @@ import (
 
 // Config holds the configuration for the application.
 type Config struct {
+	Version int `mapstructure:"version"` // Config schema version, see CurrentVersion
+
 	OpenAI struct {
-		DefaultApiurl string                 `mapstructure:"default_apiurl"`
-		Apikey        string                 `mapstructure:"apikey"`
-		Models        map[string]ModelConfig `mapstructure:"models"`
+		DefaultApiurl string                  `mapstructure:"default_apiurl"`
+		Apikey        string                  `mapstructure:"apikey"`
+		ExtraHeaders  map[string]string       `mapstructure:"extra_headers"` // Added to every request, e.g. OpenAI-Organization
+		QueryParams   map[string]string       `mapstructure:"query_params"`  // Added to every request URL, e.g. api-version
+		Attribution   types.AttributionConfig `mapstructure:"attribution"`   // Gateway cost attribution metadata
+		Models        map[string]ModelConfig  `mapstructure:"models"`
 	} `mapstructure:"openai"`
 	Gemini struct {
-		Apikey string                 `mapstructure:"apikey"`
-		Apiurl string                 `mapstructure:"apiurl"`
-		Models map[string]ModelConfig `mapstructure:"models"`
+		Apikey       string                  `mapstructure:"apikey"`
+		Apiurl       string                  `mapstructure:"apiurl"`
+		GeneratePath string                  `mapstructure:"generate_path"` // Path template for generateContent, e.g. "models/{model}:generateContent"
+		ModelsPath   string                  `mapstructure:"models_path"`   // Path for listing models, e.g. "v1/models"
+		ExtraHeaders map[string]string       `mapstructure:"extra_headers"`
+		QueryParams  map[string]string       `mapstructure:"query_params"`
+		Attribution  types.AttributionConfig `mapstructure:"attribution"`
+		Models       map[string]ModelConfig  `mapstructure:"models"`
 	} `mapstructure:"gemini"`
+	Anthropic struct { // Claude models via the Messages API
+		Apikey       string                  `mapstructure:"apikey"`
+		Apiurl       string                  `mapstructure:"apiurl"` // Default https://api.anthropic.com
+		ExtraHeaders map[string]string       `mapstructure:"extra_headers"`
+		QueryParams  map[string]string       `mapstructure:"query_params"`
+		Attribution  types.AttributionConfig `mapstructure:"attribution"`
+		Models       map[string]ModelConfig  `mapstructure:"models"`
+	} `mapstructure:"anthropic"`
 	Ollama struct {
-		Apiurl string                 `mapstructure:"apiurl"`
-		Models map[string]ModelConfig `mapstructure:"models"`
+		Apiurl       string                  `mapstructure:"apiurl"`
+		ExtraHeaders map[string]string       `mapstructure:"extra_headers"`
+		QueryParams  map[string]string       `mapstructure:"query_params"`
+		Attribution  types.AttributionConfig `mapstructure:"attribution"`
+		Models       map[string]ModelConfig  `mapstructure:"models"`
 	} `mapstructure:"ollama"`
-	LogFilePath string                     `mapstructure:"log_file_path"`
-	LogStdout   bool                       `mapstructure:"log_stdout"`
-	Tools       []types.ConfigurableTool   `mapstructure:"tools"`
-	Roles       map[string]types.Role      `mapstructure:"roles"`
-	Chains      map[string]types.RoleChain `mapstructure:"chains"`
+	Custom struct { // Endpoints described by request templates instead of a built-in API
+		Apikey       string                  `mapstructure:"apikey"`
+		Apiurl       string                  `mapstructure:"apiurl"`
+		ExtraHeaders map[string]string       `mapstructure:"extra_headers"`
+		QueryParams  map[string]string       `mapstructure:"query_params"`
+		Attribution  types.AttributionConfig `mapstructure:"attribution"`
+		Models       map[string]ModelConfig  `mapstructure:"models"`
+	} `mapstructure:"custom"`
+	Providers        map[string]ProviderConfig  `mapstructure:"providers"` // Providers added with ai.RegisterProvider, by name
+	LogFilePath      string                     `mapstructure:"log_file_path"`
+	InputHistoryPath string                     `mapstructure:"input_history_path"` // Values entered in interactive sessions, per role
+	LogStdout        bool                       `mapstructure:"log_stdout"`
+	RunsDir          string                     `mapstructure:"runs_dir"`      // where chain run records are stored
+	DocumentsDir     string                     `mapstructure:"documents_dir"` // where save_document stores documents (default docs)
+	Tools            []types.ConfigurableTool   `mapstructure:"tools"`
+	Roles            map[string]types.Role      `mapstructure:"roles"`
+	Chains           map[string]types.RoleChain `mapstructure:"chains"`
+	Cache            CacheConfig                `mapstructure:"cache"`
+	Quota            types.ToolQuota            `mapstructure:"quota"`          // Per-run tool limits (chains may override)
+	Dedup            types.DedupConfig          `mapstructure:"dedup"`          // Skip repeated identical tool calls in chains
+	Simulation       types.SimulationConfig     `mapstructure:"simulation"`     // Scripted tool results for dry runs
+	AutoApprove      types.AutoApproveConfig    `mapstructure:"auto_approve"`   // Guardrails for interactive --yes
+	MaxIterations    types.MaxIterationsConfig  `mapstructure:"max_iterations"` // What interactive sessions do at --max-iterations
+	Guardrail        types.GuardrailConfig      `mapstructure:"guardrail"`      // Reviewer role for destructive calls of unattended runs
+	Sandbox          types.SandboxConfig        `mapstructure:"sandbox"`        // Restrictions on the commands of run_command
+	HTTPRequest      types.HTTPRequestConfig    `mapstructure:"http_request"`   // Allowed domains and limits of the http_request tool
+	Extraction       types.ExtractionConfig     `mapstructure:"extraction"`     // Order of tool-call extraction strategies
+	PolicyFile       string                     `mapstructure:"policy_file"`    // Default approval policy (overridden by --policy)
+	Ignore           []string                   `mapstructure:"ignore"`         // Extra .ai-teamignore patterns tools may not access
+	Responses        types.ResponseLimits       `mapstructure:"responses"`      // Memory and size limits for provider responses
+	Pricing          types.PricingConfig        `mapstructure:"pricing"`        // Token prices used for cost estimates and summaries
+	WorkspaceLock    types.WorkspaceLockConfig  `mapstructure:"workspace_lock"` // Serializes file writes of concurrent runs
+	Journal          types.JournalConfig        `mapstructure:"journal"`        // Records file writes so interrupted ones can be recovered
+	Heartbeat        types.HeartbeatConfig      `mapstructure:"heartbeat"`      // Progress reports and stall alerts for long steps
+	Logging          types.LoggingConfig        `mapstructure:"logging"`        // Prefixing, buffering or files for the log lines of chain steps
+	Env              types.EnvConfig            `mapstructure:"env"`            // Environment of commands run by tools and hooks
+	MCPServers       types.MCPServers           `mapstructure:"mcp_servers"`    // Model Context Protocol servers whose tools chains and sessions can call
+	PostProcess      types.PostProcessors       `mapstructure:"post_process"`   // Result post-processors of built-in tools, by snake_case name
+	Locale           string                     `mapstructure:"locale"`         // Language of CLI messages, e.g. "de"; defaults to LC_ALL, LC_MESSAGES or LANG
+	UI               string                     `mapstructure:"ui"`             // Terminal UI: "default" or "plain" for screen readers (overridden by --ui)
+	Theme            types.ThemeConfig          `mapstructure:"theme"`          // Colors of diffs, tool-call JSON, role names and errors
+	Notify           types.NotifyConfig         `mapstructure:"notify"`         // Bell or desktop notifications (overridden by --notify-on-complete)
+	Retry            types.RetryConfig          `mapstructure:"retry"`          // Backoff for throttled and failed provider requests, and rate limits
+	Changelog        types.ChangelogConfig      `mapstructure:"changelog"`      // Summary of the files each chain run changed
+	Memory           types.MemoryConfig         `mapstructure:"memory"`         // Facts kept across runs by remember and recall
+	Retrieval        types.RetrievalConfig      `mapstructure:"retrieval"`      // Embeddings index searched by chain steps with retrieve
+}
+
+// CacheConfig configures response caching.
+type CacheConfig struct {
+	Semantic SemanticCacheConfig `mapstructure:"semantic"`
+}
+
+// SemanticCacheConfig configures the embeddings-based response cache. When
+// enabled, a prompt whose embedding is within Threshold (cosine similarity) of
+// a previously answered prompt for the same provider/model reuses that answer.
+type SemanticCacheConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	Threshold      float64 `mapstructure:"threshold"`       // e.g. 0.95
+	Path           string  `mapstructure:"path"`            // persisted cache file
+	EmbeddingModel string  `mapstructure:"embedding_model"` // OpenAI embedding model, e.g. text-embedding-3-small
+}
+
+// ProviderConfig configures a provider added with ai.RegisterProvider, which
+// has no section of its own.
+type ProviderConfig struct {
+	Apikey       string                  `mapstructure:"apikey"`
+	Apiurl       string                  `mapstructure:"apiurl"`
+	ExtraHeaders map[string]string       `mapstructure:"extra_headers"`
+	QueryParams  map[string]string       `mapstructure:"query_params"`
+	Attribution  types.AttributionConfig `mapstructure:"attribution"`
+	Models       map[string]ModelConfig  `mapstructure:"models"`
 }
 
 type ModelConfig struct {
@@ -38,6 +138,23 @@ type ModelConfig struct {
 	MaxTokens   int     `mapstructure:"max_tokens"`
 	Apikey      string  `mapstructure:"apikey"` // Model-specific API key
 	Apiurl      string  `mapstructure:"apiurl"` // Model-specific API URL
+
+	ExtraHeaders map[string]string `mapstructure:"extra_headers"` // Merged over the provider's extra_headers
+	QueryParams  map[string]string `mapstructure:"query_params"`  // Merged over the provider's query_params
+
+	// Custom provider only: Go template for the request body and dotted path
+	// to the response text (e.g. "choices.0.message.content").
+	RequestTemplate string `mapstructure:"request_template"`
+	ResponsePath    string `mapstructure:"response_path"`
+
+	// NativeTools offers the chain's tools to OpenAI (through the Chat
+	// Completions API) and Gemini models as functions, so tool calls come back
+	// structured instead of being extracted from the text.
+	NativeTools bool `mapstructure:"native_tools"`
+
+	// Price overrides the pricing table for this model, e.g. for a gateway or
+	// custom endpoint with its own rates.
+	Price *types.ModelPrice `mapstructure:"price"`
 	// ... other model parameters ...
 }
 
@@ -58,6 +175,12 @@ func LoadConfig(configPath string) (Config, error) {
 			return Config{}, errors.New(errors.ErrCodeConfig, "failed to read config file: "+viper.ConfigFileUsed(), err)
 		}
 	}
+	if err := migrateLoaded(); err != nil {
+		return Config{}, err
+	}
+	if err := resolveRegistryRefs(); err != nil {
+		return Config{}, err
+	}
 
 	viper.AutomaticEnv() // Allow env var overrides
 	viper.SetEnvPrefix("AI_TEAM")
@@ -65,22 +188,281 @@ func LoadConfig(configPath string) (Config, error) {
 	// Set sensible defaults
 	viper.SetDefault("LogStdout", true)
 	viper.SetDefault("Ollama.APIURL", "http://localhost:11434")
+	viper.SetDefault("gemini.generate_path", "models/{model}:generateContent")
+	viper.SetDefault("gemini.models_path", "v1/models")
+	viper.SetDefault("anthropic.apiurl", ai.DefaultClaudeAPIURL)
+	viper.SetDefault("cache.semantic.path", ".ai-team/semantic_cache.json")
+	viper.SetDefault("input_history_path", ".ai-team/input_history.json")
+	viper.SetDefault("auto_approve.max_destructive", 20)
+	viper.SetDefault("auto_approve.snapshot", true)
+	viper.SetDefault("max_iterations.action", types.MaxIterationsAsk)
+	viper.SetDefault("max_iterations.extend_by", 10)
+	viper.SetDefault("max_iterations.checkpoint", ".ai-team/sessions/{run_id}.json")
+	viper.SetDefault("auto_approve.refuse_commands", []string{`\brm\s+-[a-zA-Z]*r`, `\bgit\s+(push|reset\s+--hard|clean)\b`, `\bsudo\b`, `\|\s*(ba|z)?sh\b`})
+	viper.SetDefault("cache.semantic.embedding_model", "text-embedding-3-small")
+	viper.SetDefault("workspace_lock.enabled", true)
+	viper.SetDefault("workspace_lock.wait", "30s")
+	viper.SetDefault("journal.enabled", true)
+	viper.SetDefault("heartbeat.after", "1m")
+	viper.SetDefault("heartbeat.interval", "30s")
+	viper.SetDefault("logging.step_output", types.StepLogPrefix)
+	viper.SetDefault("logging.dir", ".ai-team/logs")
+	viper.SetDefault("retry.max_attempts", 3)
+	viper.SetDefault("retry.initial_delay", "1s")
+	viper.SetDefault("retry.max_delay", "30s")
+	viper.SetDefault("changelog.max_diff", 20000)
+	viper.SetDefault("memory.path", ".ai-team/memory.json")
+	viper.SetDefault("retrieval.provider", "openai")
+	viper.SetDefault("retrieval.index", ".ai-team/index.json")
 	// ...add more defaults as needed...
 
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return Config{}, errors.New(errors.ErrCodeConfig, "failed to unmarshal config: "+viper.ConfigFileUsed(), err)
 	}
+	config.normalizeURLs()
 	if err := config.Validate(); err != nil {
 		return Config{}, err
 	}
+	i18n.SetLocale(config.Locale)
+	theme, _ := render.NewTheme(config.Theme) // Checked by Validate
+	render.SetTheme(theme)
 	return config, nil
 }
 
+// apiURLs returns every configured API URL keyed by its config location.
+func (c *Config) apiURLs() map[string]string {
+	urls := map[string]string{
+		"openai.default_apiurl": c.OpenAI.DefaultApiurl,
+		"gemini.apiurl":         c.Gemini.Apiurl,
+		"anthropic.apiurl":      c.Anthropic.Apiurl,
+		"ollama.apiurl":         c.Ollama.Apiurl,
+		"custom.apiurl":         c.Custom.Apiurl,
+	}
+	for name, m := range c.OpenAI.Models {
+		urls["openai.models."+name+".apiurl"] = m.Apiurl
+	}
+	for name, m := range c.Gemini.Models {
+		urls["gemini.models."+name+".apiurl"] = m.Apiurl
+	}
+	for name, m := range c.Anthropic.Models {
+		urls["anthropic.models."+name+".apiurl"] = m.Apiurl
+	}
+	for name, m := range c.Ollama.Models {
+		urls["ollama.models."+name+".apiurl"] = m.Apiurl
+	}
+	for name, m := range c.Custom.Models {
+		urls["custom.models."+name+".apiurl"] = m.Apiurl
+	}
+	for provider, p := range c.Providers {
+		urls["providers."+provider+".apiurl"] = p.Apiurl
+		for name, m := range p.Models {
+			urls["providers."+provider+".models."+name+".apiurl"] = m.Apiurl
+		}
+	}
+	return urls
+}
+
+// RequestOptions returns the extra headers and query parameters for requests
+// to a provider ("openai", "gemini", "anthropic", "ollama" or "custom") and model key, with model
+// settings taking precedence. Values may reference environment variables
+// such as ${GATEWAY_TOKEN}.
+func (c *Config) RequestOptions(provider, model string) ai.RequestOptions {
+	var headers, query map[string]string
+	var models map[string]ModelConfig
+	var attribution types.AttributionConfig
+	switch provider {
+	case "openai":
+		headers, query, models, attribution = c.OpenAI.ExtraHeaders, c.OpenAI.QueryParams, c.OpenAI.Models, c.OpenAI.Attribution
+	case "gemini":
+		headers, query, models, attribution = c.Gemini.ExtraHeaders, c.Gemini.QueryParams, c.Gemini.Models, c.Gemini.Attribution
+	case "anthropic":
+		headers, query, models, attribution = c.Anthropic.ExtraHeaders, c.Anthropic.QueryParams, c.Anthropic.Models, c.Anthropic.Attribution
+	case "ollama":
+		headers, query, models, attribution = c.Ollama.ExtraHeaders, c.Ollama.QueryParams, c.Ollama.Models, c.Ollama.Attribution
+	case "custom":
+		headers, query, models, attribution = c.Custom.ExtraHeaders, c.Custom.QueryParams, c.Custom.Models, c.Custom.Attribution
+	default:
+		p := c.Providers[provider]
+		headers, query, models, attribution = p.ExtraHeaders, p.QueryParams, p.Models, p.Attribution
+	}
+	m := models[model]
+	opts := ai.RequestOptions{
+		Headers:     mergeExpanded(headers, m.ExtraHeaders),
+		Query:       mergeExpanded(query, m.QueryParams),
+		Attribution: attribution,
+		Usage:       usage.Default,
+		UsageLabel:  provider + "/" + model,
+		Retry:       c.Retry,
+		Limiter:     ai.RateLimiterFor(provider, c.Retry.RateLimits[provider]),
+		Responses:   c.Responses,
+	}
+	if provider == "gemini" {
+		opts.GeminiGeneratePath, opts.GeminiModelsPath = c.Gemini.GeneratePath, c.Gemini.ModelsPath
+	}
+	return opts
+}
+
+// Generation returns the sampling settings sent with requests to the model.
+func (m ModelConfig) Generation() ai.Generation {
+	return ai.Generation{Temperature: m.Temperature, MaxTokens: m.MaxTokens}
+}
+
+// LookupModel returns the config of model under provider.
+func (c *Config) LookupModel(provider, model string) (ModelConfig, bool) {
+	var models map[string]ModelConfig
+	switch provider {
+	case "openai":
+		models = c.OpenAI.Models
+	case "gemini":
+		models = c.Gemini.Models
+	case "anthropic":
+		models = c.Anthropic.Models
+	case "ollama":
+		models = c.Ollama.Models
+	case "custom":
+		models = c.Custom.Models
+	default:
+		models = c.Providers[provider].Models
+	}
+	m, ok := models[model]
+	return m, ok
+}
+
+// ProviderRequest returns the request settings of model under provider: the
+// model's API URL and key, falling back to the provider's, its generation
+// settings and its custom request template. ok is false when the model is
+// not configured.
+func (c *Config) ProviderRequest(provider, model string) (req ai.ProviderRequest, ok bool) {
+	m, ok := c.LookupModel(provider, model)
+	if !ok {
+		return ai.ProviderRequest{}, false
+	}
+	apiURL, apiKey := c.ProviderEndpoint(provider)
+	if m.Apiurl != "" {
+		apiURL = m.Apiurl
+	}
+	if m.Apikey != "" {
+		apiKey = m.Apikey
+	}
+	return ai.ProviderRequest{
+		Model:           m.Model,
+		APIURL:          apiURL,
+		APIKey:          apiKey,
+		Generation:      m.Generation(),
+		NativeTools:     m.NativeTools,
+		RequestTemplate: m.RequestTemplate,
+		ResponsePath:    m.ResponsePath,
+	}, true
+}
+
+// ProviderEndpoint returns the API URL and key configured for provider.
+func (c *Config) ProviderEndpoint(provider string) (apiURL, apiKey string) {
+	switch provider {
+	case "openai":
+		return c.OpenAI.DefaultApiurl, c.OpenAI.Apikey
+	case "gemini":
+		return c.Gemini.Apiurl, c.Gemini.Apikey
+	case "anthropic":
+		return c.Anthropic.Apiurl, c.Anthropic.Apikey
+	case "ollama":
+		return c.Ollama.Apiurl, ""
+	case "custom":
+		return c.Custom.Apiurl, c.Custom.Apikey
+	}
+	return c.Providers[provider].Apiurl, c.Providers[provider].Apikey
+}
+
+// Price returns the token price of provider/model: the model's own price if
+// set, else the pricing table entry. Table keys are matched case-insensitively
+// since viper lowercases map keys.
+func (c *Config) Price(provider, model string) (types.ModelPrice, bool) {
+	if m, ok := c.LookupModel(provider, model); ok && m.Price != nil {
+		return *m.Price, true
+	}
+	p, ok := c.Pricing.Models[strings.ToLower(provider+"/"+model)]
+	return p, ok
+}
+
+// Currency returns the currency costs are reported in.
+func (c *Config) Currency() string {
+	if c.Pricing.Currency == "" {
+		return "USD"
+	}
+	return c.Pricing.Currency
+}
+
+func validAttribute(attr string) bool {
+	for _, a := range types.AttributionAttributes {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeExpanded merges maps left to right, expanding environment variables.
+func mergeExpanded(maps ...map[string]string) map[string]string {
+	var out map[string]string
+	for _, m := range maps {
+		for k, v := range m {
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[k] = os.ExpandEnv(v)
+		}
+	}
+	return out
+}
+
+// normalizeURLs trims whitespace and trailing slashes from API URLs.
+func (c *Config) normalizeURLs() {
+	c.OpenAI.DefaultApiurl = ai.NormalizeAPIURL(c.OpenAI.DefaultApiurl)
+	c.Gemini.Apiurl = ai.NormalizeAPIURL(c.Gemini.Apiurl)
+	c.Anthropic.Apiurl = ai.NormalizeAPIURL(c.Anthropic.Apiurl)
+	c.Ollama.Apiurl = ai.NormalizeAPIURL(c.Ollama.Apiurl)
+	c.Custom.Apiurl = ai.NormalizeAPIURL(c.Custom.Apiurl)
+	all := []map[string]ModelConfig{c.OpenAI.Models, c.Gemini.Models, c.Anthropic.Models, c.Ollama.Models, c.Custom.Models}
+	for name, p := range c.Providers {
+		p.Apiurl = ai.NormalizeAPIURL(p.Apiurl)
+		c.Providers[name] = p
+		all = append(all, p.Models)
+	}
+	for _, models := range all {
+		for name, m := range models {
+			m.Apiurl = ai.NormalizeAPIURL(m.Apiurl)
+			models[name] = m
+		}
+	}
+}
+
 // Validate checks for required config fields
 func (c *Config) Validate() error {
-	if c.OpenAI.Apikey == "" && c.Gemini.Apikey == "" && c.Ollama.Apiurl == "" {
-		return errors.New(errors.ErrCodeConfig, "at least one API configuration must be set (OpenAI, Gemini, or Ollama)", nil)
+	if c.OpenAI.Apikey == "" && c.Gemini.Apikey == "" && c.Anthropic.Apikey == "" && c.Ollama.Apiurl == "" && len(c.Custom.Models) == 0 && len(c.Providers) == 0 {
+		return errors.New(errors.ErrCodeConfig, "at least one API configuration must be set (OpenAI, Gemini, Anthropic, Ollama or custom)", nil)
+	}
+
+	attributions := map[string]types.AttributionConfig{"openai": c.OpenAI.Attribution, "gemini": c.Gemini.Attribution, "anthropic": c.Anthropic.Attribution, "ollama": c.Ollama.Attribution, "custom": c.Custom.Attribution}
+	for name, p := range c.Providers {
+		attributions["providers."+name] = p.Attribution
+	}
+	for provider, a := range attributions {
+		for _, mapping := range []map[string]string{a.Headers, a.BodyFields} {
+			for name, attr := range mapping {
+				if !validAttribute(attr) {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("%s.attribution maps '%s' to unknown attribute '%s' (expected user, team or run_id)", provider, name, attr), nil)
+				}
+			}
+		}
+	}
+
+	for key, u := range c.apiURLs() {
+		if u == "" {
+			continue
+		}
+		if _, err := ai.ParseAPIURL(u); err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("%s is invalid", key), err)
+		}
 	}
 
 	// Validate OpenAI models
@@ -101,45 +483,393 @@ func (c *Config) Validate() error {
 			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("Gemini model '%s' has invalid max_tokens", name), nil)
 		}
 	}
+	// Validate Anthropic models
+	for name, m := range c.Anthropic.Models {
+		if m.Model == "" {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("Anthropic model '%s' missing 'model' field", name), nil)
+		}
+		if m.MaxTokens <= 0 {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("Anthropic model '%s' has invalid max_tokens", name), nil)
+		}
+	}
 	// Validate Ollama models
 	for name, m := range c.Ollama.Models {
 		if m.Model == "" {
 			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("Ollama model '%s' missing 'model' field", name), nil)
 		}
 	}
+	// Validate custom models
+	for name, m := range c.Custom.Models {
+		if m.Apiurl == "" && c.Custom.Apiurl == "" {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("custom model '%s' has no apiurl", name), nil)
+		}
+		if m.RequestTemplate == "" {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("custom model '%s' missing 'request_template' field", name), nil)
+		}
+		if _, err := ai.ParseCustomTemplate(m.RequestTemplate); err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("custom model '%s' has an invalid request_template", name), err)
+		}
+	}
 
 	for _, tool := range c.Tools {
 		logrus.Debugf("Validating tool: %+v", tool)
 		if tool.Name == "" {
 			return errors.New(errors.ErrCodeConfig, "tool must have a Name", nil)
 		}
-		if tool.CommandTemplate == "" {
-			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' must have a CommandTemplate", tool.Name), nil)
+		if (tool.CommandTemplate == "") == (tool.Endpoint == "") {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' must have either a command_template or an endpoint", tool.Name), nil)
+		}
+		if tool.Endpoint != "" && !strings.HasPrefix(tool.Endpoint, "http://") && !strings.HasPrefix(tool.Endpoint, "https://") {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' endpoint must be an http(s) URL", tool.Name), nil)
 		}
 		for _, arg := range tool.Arguments {
 			if arg.Name == "" || arg.Type == "" {
 				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' has argument with missing name or type", tool.Name), nil)
 			}
+			if arg.Default != nil {
+				if err := tools.ValidateValue(tools.ConfigArgumentSchema(arg), arg.Name, arg.Default); err != nil {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' has an invalid default", tool.Name), err)
+				}
+			}
+		}
+		if tool.CommandTemplate != "" {
+			if _, err := tools.ParseCommandTemplate(tool); err != nil {
+				return err
+			}
+		}
+		if tool.PostProcess != nil {
+			if _, err := tools.NewPostProcessors(types.PostProcessors{tool.Name: *tool.PostProcess}); err != nil {
+				return err
+			}
 		}
 	}
+	if _, err := tools.NewPostProcessors(c.PostProcess); err != nil {
+		return err
+	}
 
 	for name, role := range c.Roles {
 		if role.Model == "" {
 			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' must have a Model", name), nil)
 		}
+		if role.MaxContinuations < -1 {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' max_continuations must be -1 (off) or more", name), nil)
+		}
+		seen := make(map[string]bool, len(role.Inputs))
+		for _, in := range role.Inputs {
+			if in.Name == "" {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' has an input with no name", name), nil)
+			}
+			if seen[in.Name] {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' declares input '%s' more than once", name, in.Name), nil)
+			}
+			seen[in.Name] = true
+			switch in.Type {
+			case "", types.InputTypeString, types.InputTypeInt, types.InputTypeNumber, types.InputTypeBool:
+			default:
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' input '%s' has invalid type '%s'", name, in.Name, in.Type), nil)
+			}
+			if in.Default != "" {
+				if _, err := in.ParseValue(in.Default); err != nil {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("role '%s' has an invalid default", name), err)
+				}
+			}
+		}
+	}
+
+	switch c.Dedup.Scope {
+	case "", "run", "step":
+	default:
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("dedup.scope must be 'run' or 'step', got '%s'", c.Dedup.Scope), nil)
+	}
+
+	for _, pattern := range c.AutoApprove.RefuseCommands {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("auto_approve.refuse_commands has invalid pattern '%s'", pattern), err)
+		}
+	}
+
+	switch c.MaxIterations.Action {
+	case "", types.MaxIterationsAsk, types.MaxIterationsSummarize, types.MaxIterationsCheckpoint, types.MaxIterationsStop:
+	default:
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("max_iterations.action must be %s, %s, %s or %s, got '%s'", types.MaxIterationsAsk, types.MaxIterationsSummarize, types.MaxIterationsCheckpoint, types.MaxIterationsStop, c.MaxIterations.Action), nil)
+	}
+	if c.MaxIterations.ExtendBy < 0 {
+		return errors.New(errors.ErrCodeConfig, "max_iterations.extend_by must not be negative", nil)
+	}
+
+	if c.Changelog.Role != "" {
+		if _, ok := c.Roles[c.Changelog.Role]; !ok {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("changelog.role '%s' is not a configured role", c.Changelog.Role), nil)
+		}
+	}
+	if c.Changelog.MaxDiff < 0 {
+		return errors.New(errors.ErrCodeConfig, "changelog.max_diff must not be negative", nil)
+	}
+	if c.Memory.Load < 0 {
+		return errors.New(errors.ErrCodeConfig, "memory.load must not be negative", nil)
+	}
+	if err := c.validateRetrieval(); err != nil {
+		return err
+	}
+	if c.Guardrail.Role != "" {
+		if _, ok := c.Roles[c.Guardrail.Role]; !ok {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("guardrail.role '%s' is not a configured role", c.Guardrail.Role), nil)
+		}
+	}
+
+	for _, domain := range c.HTTPRequest.AllowedDomains {
+		if domain == "" || strings.Contains(domain, "/") {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("http_request.allowed_domains has invalid domain '%s' (expected a host such as docs.example.com or *.example.com)", domain), nil)
+		}
+	}
+	if c.HTTPRequest.MaxBytes < 0 || c.HTTPRequest.Timeout < 0 {
+		return errors.New(errors.ErrCodeConfig, "http_request.max_bytes and http_request.timeout must not be negative", nil)
+	}
+
+	if c.Responses.MemoryBytes < 0 || c.Responses.MaxBytes < 0 {
+		return errors.New(errors.ErrCodeConfig, "responses.memory_bytes and responses.max_bytes must not be negative", nil)
+	}
+
+	if c.Simulation.Enabled || c.Simulation.DryRun {
+		for name, fixtures := range c.Simulation.Tools {
+			for i, f := range fixtures {
+				for arg, pattern := range f.Match {
+					if _, err := regexp.Compile(pattern); err != nil {
+						return errors.New(errors.ErrCodeConfig, fmt.Sprintf("simulation tool '%s' fixture %d has invalid match for '%s'", name, i, arg), err)
+					}
+				}
+			}
+		}
+	}
+
+	for name, mode := range c.Simulation.Results {
+		switch mode {
+		case types.SimulateSkipped, types.SimulateEcho, types.SimulateRun:
+		case types.SimulateFixture:
+			if len(c.Simulation.Tools[name]) == 0 {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("simulation.results.%s is fixture, but simulation.tools has no fixtures for it", name), nil)
+			}
+		default:
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("simulation.results.%s must be %s, %s, %s or %s, got '%s'", name, types.SimulateSkipped, types.SimulateEcho, types.SimulateFixture, types.SimulateRun, mode), nil)
+		}
+	}
+	switch c.Simulation.DefaultResult {
+	case "", types.SimulateSkipped, types.SimulateEcho, types.SimulateRun:
+	default:
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("simulation.default_result must be %s, %s or %s, got '%s'", types.SimulateSkipped, types.SimulateEcho, types.SimulateRun, c.Simulation.DefaultResult), nil)
+	}
+
+	if c.Cache.Semantic.Enabled {
+		if c.Cache.Semantic.Threshold <= 0 || c.Cache.Semantic.Threshold > 1 {
+			return errors.New(errors.ErrCodeConfig, "cache.semantic.threshold must be in (0, 1]", nil)
+		}
+		if c.OpenAI.Apikey == "" {
+			return errors.New(errors.ErrCodeConfig, "cache.semantic requires an OpenAI API key for embeddings", nil)
+		}
+	}
+
+	if c.Dedup.Similar.Enabled {
+		if c.Dedup.Similar.Threshold <= 0 || c.Dedup.Similar.Threshold > 1 {
+			return errors.New(errors.ErrCodeConfig, "dedup.similar.threshold must be in (0, 1]", nil)
+		}
+		if c.OpenAI.Apikey == "" {
+			return errors.New(errors.ErrCodeConfig, "dedup.similar requires an OpenAI API key for embeddings", nil)
+		}
+	}
+
+	switch c.Logging.StepOutput {
+	case "", types.StepLogPrefix, types.StepLogBuffer, types.StepLogFiles:
+	default:
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("logging.step_output must be %s, %s or %s, got '%s'", types.StepLogPrefix, types.StepLogBuffer, types.StepLogFiles, c.Logging.StepOutput), nil)
+	}
+	if c.Heartbeat.After > 0 && c.Heartbeat.Interval <= 0 {
+		return errors.New(errors.ErrCodeConfig, "heartbeat.interval must be positive", nil)
+	}
+	if hook := c.Heartbeat.Webhook; hook != "" && !strings.HasPrefix(hook, "http://") && !strings.HasPrefix(hook, "https://") {
+		return errors.New(errors.ErrCodeConfig, "heartbeat.webhook must be an http(s) URL", nil)
+	}
+	if err := validateEnv("env", c.Env); err != nil {
+		return err
+	}
+	for name, server := range c.MCPServers {
+		if (server.Command == "") == (server.URL == "") {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("mcp_servers.%s needs either command or url", name), nil)
+		}
+		if server.URL != "" && !strings.HasPrefix(server.URL, "http://") && !strings.HasPrefix(server.URL, "https://") {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("mcp_servers.%s.url must be an http(s) URL", name), nil)
+		}
+		if server.Timeout < 0 {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("mcp_servers.%s.timeout must not be negative", name), nil)
+		}
+	}
+	if c.Locale != "" && !i18n.Supported(c.Locale) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("locale '%s' is not supported (available: %s)", c.Locale, strings.Join(i18n.Locales(), ", ")), nil)
+	}
+	switch c.UI {
+	case "", cli.ModeDefault, cli.ModePlain:
+	default:
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("ui must be '%s' or '%s', got '%s'", cli.ModeDefault, cli.ModePlain, c.UI), nil)
+	}
+	if _, err := render.NewTheme(c.Theme); err != nil {
+		return err
+	}
+	if !notify.Valid(c.Notify.OnComplete) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("notify.on_complete must be one of %s, got '%s'", strings.Join(notify.Modes, ", "), c.Notify.OnComplete), nil)
+	}
+	if c.Notify.After < 0 {
+		return errors.New(errors.ErrCodeConfig, "notify.after must not be negative", nil)
+	}
+	if c.Retry.MaxAttempts < 0 || c.Retry.InitialDelay < 0 || c.Retry.MaxDelay < 0 {
+		return errors.New(errors.ErrCodeConfig, "retry.max_attempts, retry.initial_delay and retry.max_delay must not be negative", nil)
+	}
+	for provider, perMinute := range c.Retry.RateLimits {
+		if perMinute < 0 {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("retry.rate_limits.%s must not be negative", provider), nil)
+		}
 	}
 
 	// Validate chains: referenced roles must exist
 	for cname, chain := range c.Chains {
+		if err := validateCheckpoints(cname, chain); err != nil {
+			return err
+		}
 		for _, step := range chain.Steps {
+			if target := step.ErrorTarget(); target != "" {
+				if _, ok := chain.StepIndex(target); !ok {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has invalid on_error '%s': not a policy or a step", cname, step.Role, step.OnError), nil)
+				}
+			}
+			if err := validateBranch(cname, chain, step); err != nil {
+				return err
+			}
+			switch step.OutputMode {
+			case "", types.OutputModeReplace, types.OutputModeAppend:
+			default:
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has invalid output_mode '%s'", cname, step.Role, step.OutputMode), nil)
+			}
+			for _, hook := range append(append([]types.StepHook{}, step.Before...), step.After...) {
+				set := 0
+				for _, field := range []string{hook.Command, hook.Tool, hook.Script} {
+					if field != "" {
+						set++
+					}
+				}
+				if set != 1 {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has a hook that must set exactly one of command, tool or script", cname, step.Role), nil)
+				}
+			}
 			if step.Role != "" {
 				if _, ok := c.Roles[step.Role]; !ok {
 					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' references undefined role '%s'", cname, step.Role), nil)
 				}
 			}
 		}
+		if err := validateEnv(fmt.Sprintf("chain '%s' env", cname), chain.Env); err != nil {
+			return err
+		}
+		if _, err := CompileScripts(cname, chain); err != nil {
+			return err
+		}
+		if hook := chain.OnSuccess; hook != nil {
+			if hook.Role == "" && hook.Command == "" {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' on_success must set role or command", cname), nil)
+			}
+			if hook.Role != "" {
+				if _, ok := c.Roles[hook.Role]; !ok {
+					return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' on_success references undefined role '%s'", cname, hook.Role), nil)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateBranch checks the when and goto fields of step. A goto without a
+// template must name a step of chain or end.
+func validateBranch(cname string, chain types.RoleChain, step types.ChainRole) error {
+	for field, value := range map[string]string{"when": step.When, "goto": step.Goto} {
+		if _, err := template.New(field).Parse(value); err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has an invalid %s template", cname, step.Key(), field), err)
+		}
+	}
+	if step.Goto != "" && !strings.Contains(step.Goto, "{{") {
+		if _, ok := chain.StepIndex(strings.TrimSpace(step.Goto)); !ok {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has goto '%s', which is not a step or %s", cname, step.Key(), step.Goto, types.GotoEnd), nil)
+		}
+	}
+	return nil
+}
+
+// validateRetrieval checks the retrieval settings, and that the embeddings
+// provider is configured when a chain step retrieves.
+func (c *Config) validateRetrieval() error {
+	r := c.Retrieval
+	if r.ChunkLines < 0 || r.MaxFileSize < 0 || r.TopK < 0 {
+		return errors.New(errors.ErrCodeConfig, "retrieval.chunk_lines, retrieval.max_file_size and retrieval.top_k must not be negative", nil)
+	}
+	provider := c.RetrievalProvider()
+	adapter, _ := ai.Provider(provider)
+	if _, ok := adapter.(ai.EmbeddingAdapter); !ok {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("retrieval.provider must be one of %s, got '%s'", strings.Join(ai.EmbeddingProviders(), ", "), r.Provider), nil)
+	}
+	apiURL, apiKey := c.ProviderEndpoint(provider)
+	configured := apiKey != "" || provider == "ollama" && apiURL != ""
+	for cname, chain := range c.Chains {
+		for _, step := range chain.Steps {
+			if step.Retrieve == nil {
+				continue
+			}
+			if step.Retrieve.TopK < 0 {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has a negative retrieve.top_k", cname, step.Key()), nil)
+			}
+			if !configured {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' retrieves, but the %s provider of retrieval is not configured", cname, step.Key(), c.RetrievalProvider()), nil)
+			}
+		}
+	}
+	return nil
+}
+
+// RetrievalProvider returns the embeddings provider of retrieval.
+func (c *Config) RetrievalProvider() string {
+	if c.Retrieval.Provider == "" {
+		return "openai"
+	}
+	return c.Retrieval.Provider
+}
+
+// validateCheckpoints checks that the named checkpoints of chain have valid,
+// distinct names.
+func validateCheckpoints(cname string, chain types.RoleChain) error {
+	seen := map[string]string{}
+	for _, step := range chain.Steps {
+		if step.Checkpoint == "" {
+			continue
+		}
+		if !runs.ValidCheckpointName(step.Checkpoint) {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' step '%s' has invalid checkpoint '%s': use letters, digits, '.', '_' and '-'", cname, step.Key(), step.Checkpoint), nil)
+		}
+		if other, ok := seen[step.Checkpoint]; ok {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' steps '%s' and '%s' both have checkpoint '%s'", cname, other, step.Key(), step.Checkpoint), nil)
+		}
+		seen[step.Checkpoint] = step.Key()
 	}
+	return nil
+}
 
+// envName matches valid environment variable names.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnv checks that every var of env is a NAME=value entry; where names
+// the section in errors.
+func validateEnv(where string, env types.EnvConfig) error {
+	for _, kv := range env.Vars {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || !envName.MatchString(name) {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("%s var '%s' must be NAME=value", where, kv), nil)
+		}
+	}
 	return nil
 }
 
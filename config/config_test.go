@@ -2,9 +2,14 @@ package config
 
 import (
 	"ai-team/pkg/types"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
@@ -42,3 +47,451 @@ chains:
 		t.Errorf("unexpected input for step 0: %+v", chain.Steps[0].Input)
 	}
 }
+
+func TestValidate_APIURLs(t *testing.T) {
+	cfg := Config{}
+	cfg.Gemini.Apikey = "key"
+	cfg.Gemini.Apiurl = "https://generativelanguage.googleapis.com/v1beta/"
+	cfg.Gemini.Models = map[string]ModelConfig{"flash": {Model: "gemini-2.5-flash", MaxTokens: 100, Apiurl: "https://proxy.local/gemini/ "}}
+	cfg.normalizeURLs()
+	if cfg.Gemini.Apiurl != "https://generativelanguage.googleapis.com/v1beta" || cfg.Gemini.Models["flash"].Apiurl != "https://proxy.local/gemini" {
+		t.Fatalf("expected trailing slashes to be trimmed, got %q and %q", cfg.Gemini.Apiurl, cfg.Gemini.Models["flash"].Apiurl)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg.Gemini.Models["flash"] = ModelConfig{Model: "gemini-2.5-flash", MaxTokens: 100, Apiurl: "proxy.local/gemini"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for API URL without scheme")
+	}
+}
+
+func TestRequestOptions_MergesModelOverProvider(t *testing.T) {
+	t.Setenv("GATEWAY_TOKEN", "tok")
+	cfg := Config{}
+	cfg.OpenAI.ExtraHeaders = map[string]string{"x-gateway-auth": "${GATEWAY_TOKEN}", "x-team": "core"}
+	cfg.OpenAI.QueryParams = map[string]string{"api-version": "1"}
+	cfg.OpenAI.Models = map[string]ModelConfig{"gpt": {ExtraHeaders: map[string]string{"x-team": "ml"}, QueryParams: map[string]string{"api-version": "2"}}}
+
+	opts := cfg.RequestOptions("openai", "gpt")
+	if opts.Headers["x-gateway-auth"] != "tok" || opts.Headers["x-team"] != "ml" {
+		t.Errorf("unexpected headers %v", opts.Headers)
+	}
+	if opts.Query["api-version"] != "2" {
+		t.Errorf("unexpected query %v", opts.Query)
+	}
+	if other := cfg.RequestOptions("gemini", ""); len(other.Headers) != 0 || len(other.Query) != 0 {
+		t.Errorf("expected no headers or query for an unconfigured provider, got %+v", other)
+	}
+}
+
+func TestProviderRequest(t *testing.T) {
+	cfg := Config{}
+	cfg.Gemini.Apikey, cfg.Gemini.Apiurl = "provider-key", "https://gemini.example.com"
+	cfg.Gemini.Models = map[string]ModelConfig{
+		"flash": {Model: "gemini-1.5-flash", Temperature: 0.5, MaxTokens: 256, NativeTools: true},
+		"own":   {Model: "gemini-pro", Apikey: "model-key", Apiurl: "https://own.example.com"},
+	}
+	cfg.Providers = map[string]ProviderConfig{
+		"echo": {Apiurl: "https://echo.example.com", ExtraHeaders: map[string]string{"x-team": "core"}, Models: map[string]ModelConfig{"e1": {Model: "echo-1"}}},
+	}
+
+	req, ok := cfg.ProviderRequest("gemini", "flash")
+	if !ok || req.Model != "gemini-1.5-flash" || req.APIKey != "provider-key" || req.APIURL != "https://gemini.example.com" {
+		t.Errorf("expected the provider's key and URL, got %+v", req)
+	}
+	if req.Generation.Temperature != 0.5 || req.Generation.MaxTokens != 256 || !req.NativeTools {
+		t.Errorf("expected the model's settings, got %+v", req)
+	}
+	if req, _ := cfg.ProviderRequest("gemini", "own"); req.APIKey != "model-key" || req.APIURL != "https://own.example.com" {
+		t.Errorf("expected the model's key and URL, got %+v", req)
+	}
+	if req, ok := cfg.ProviderRequest("echo", "e1"); !ok || req.Model != "echo-1" || req.APIURL != "https://echo.example.com" {
+		t.Errorf("expected a model of the providers section, got %+v", req)
+	}
+	if opts := cfg.RequestOptions("echo", "e1"); opts.Headers["x-team"] != "core" {
+		t.Errorf("expected headers of the providers section, got %v", opts.Headers)
+	}
+	if _, ok := cfg.ProviderRequest("gemini", "missing"); ok {
+		t.Error("expected no request for an unconfigured model")
+	}
+}
+
+func TestValidate_AttributionAttributes(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Ollama.Attribution.Headers = map[string]string{"x-litellm-user": "user"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Ollama.Attribution.BodyFields = map[string]string{"metadata.project": "project"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for unknown attribution attribute")
+	}
+}
+
+func TestValidate_CustomModels(t *testing.T) {
+	cfg := Config{}
+	cfg.Custom.Apiurl = "https://llm.internal/generate"
+	cfg.Custom.Models = map[string]ModelConfig{"internal": {RequestTemplate: `{"input": {{json .prompt}}}`, ResponsePath: "output.text"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg.Custom.Models["internal"] = ModelConfig{RequestTemplate: `{"input": {{json .prompt}`}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for invalid request_template")
+	}
+	cfg.Custom.Models["internal"] = ModelConfig{}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for missing request_template")
+	}
+	cfg.Custom.Apiurl = ""
+	cfg.Custom.Models["internal"] = ModelConfig{RequestTemplate: "{}"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for custom model without apiurl")
+	}
+}
+
+func TestPrice(t *testing.T) {
+	var cfg Config
+	cfg.Pricing.Models = map[string]types.ModelPrice{"openai/gpt-4": {Input: 30, Output: 60}}
+	cfg.Custom.Models = map[string]ModelConfig{"gw": {Price: &types.ModelPrice{Input: 1, Output: 2}}}
+
+	if p, ok := cfg.Price("openai", "GPT-4"); !ok || p.Input != 30 {
+		t.Errorf("expected the table price, got %+v (%v)", p, ok)
+	}
+	if p, ok := cfg.Price("custom", "gw"); !ok || p.Output != 2 {
+		t.Errorf("expected the model's own price, got %+v (%v)", p, ok)
+	}
+	if _, ok := cfg.Price("ollama", "llama"); ok {
+		t.Error("expected no price for an unpriced model")
+	}
+	if cfg.Currency() != "USD" {
+		t.Errorf("expected USD by default, got %s", cfg.Currency())
+	}
+}
+
+func TestValidate_Env(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Env.Vars = []string{"GITHUB_TOKEN=${GH_TOKEN}", "EMPTY="}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Env.Vars = []string{"GITHUB_TOKEN"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a var without a value")
+	}
+	cfg.Env.Vars = nil
+	cfg.Chains = map[string]types.RoleChain{"ci": {Env: types.EnvConfig{Vars: []string{"1BAD=x"}}}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for an invalid chain var name")
+	}
+}
+
+func TestValidate_MCPServers(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.MCPServers = types.MCPServers{
+		"fs":  {Command: "mcp-server-filesystem", Args: []string{"."}},
+		"web": {URL: "https://mcp.example.com/sse"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.MCPServers["both"] = types.MCPServerConfig{Command: "x", URL: "https://mcp.example.com/sse"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a server with command and url")
+	}
+	cfg.MCPServers["both"] = types.MCPServerConfig{URL: "ws://mcp.example.com"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a non-http url")
+	}
+}
+
+func TestLoadConfig_ToolArgumentSchema(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `version: 1
+ollama:
+  apiurl: http://localhost:11434
+tools:
+  - name: tail_log
+    command_template: "tail -n {{.lines}} {{.level}}.log"
+    arguments:
+      - name: level
+        type: string
+        required: true
+        enum: [debug, info]
+      - name: lines
+        type: int
+        default: 20
+        minimum: 1
+        maximum: 500
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	level, lines := cfg.Tools[0].Arguments[0], cfg.Tools[0].Arguments[1]
+	if !level.Required || len(level.Enum) != 2 || lines.Minimum == nil || *lines.Maximum != 500 {
+		t.Errorf("arguments = %+v, %+v", level, lines)
+	}
+
+	viper.Reset()
+	if err := os.WriteFile(path, []byte(strings.Replace(data, "default: 20", "default: 1000", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "invalid default") {
+		t.Errorf("expected an error for a default above the maximum, got %v", err)
+	}
+}
+
+func TestLoadConfig_RoleFinalAnswerSchema(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `version: 1
+ollama:
+  apiurl: http://localhost:11434
+  models:
+    llama:
+      model: llama3.1
+roles:
+  reviewer:
+    model_provider: ollama
+    model_name: llama
+    prompt: Review.
+    final_answer:
+      type: object
+      required: [verdict]
+      properties:
+        verdict: {type: string, enum: [approve, reject]}
+        notes: {type: array, items: {type: string}}
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schema := cfg.Roles["reviewer"].FinalAnswer
+	if schema == nil || schema.Type != "object" || len(schema.Required) != 1 || len(schema.Properties["verdict"].Enum) != 2 || schema.Properties["notes"].Items.Type != "string" {
+		t.Errorf("final_answer = %+v", schema)
+	}
+}
+
+func TestValidate_UI(t *testing.T) {
+	cfg := Config{UI: "plain"}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.UI = "fancy"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for an unknown ui mode")
+	}
+}
+
+func TestValidate_Theme(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Theme = types.ThemeConfig{Mode: "light", Colors: map[string]string{"diff_added": "bold #00aa00"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Theme.Colors["diff_added"] = "greenish"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for an invalid color")
+	}
+}
+
+func TestValidate_Anthropic(t *testing.T) {
+	cfg := Config{}
+	cfg.Anthropic.Apikey = "sk-ant"
+	cfg.Anthropic.Models = map[string]ModelConfig{"sonnet": {Model: "claude-sonnet-4-5", MaxTokens: 4096}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts := cfg.RequestOptions("anthropic", "sonnet"); opts.UsageLabel != "anthropic/sonnet" {
+		t.Errorf("unexpected request options %+v", opts)
+	}
+	cfg.Anthropic.Models = map[string]ModelConfig{"sonnet": {Model: "claude-sonnet-4-5"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a model without max_tokens")
+	}
+}
+
+func TestValidate_Notify(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Notify = types.NotifyConfig{OnComplete: "desktop", After: time.Minute}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Notify.OnComplete = "email"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for an unknown notification mode")
+	}
+}
+
+func TestValidate_Scripts(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	chain := types.RoleChain{
+		Scripts: map[string]string{"trim": "output = output.strip()"},
+		Steps: []types.ChainRole{{
+			OutputScript: "trim",
+			LoopScript:   "stop = 'DONE' in output",
+			Before:       []types.StepHook{{Script: "count = 0"}},
+		}},
+	}
+	cfg.Chains = map[string]types.RoleChain{"ci": chain}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	programs, err := CompileScripts("ci", chain)
+	if err != nil || len(programs) != 3 || programs["trim"].Name() != "trim" {
+		t.Errorf("CompileScripts = %v, %v; want the named script and two inline ones", programs, err)
+	}
+
+	chain.Steps[0].InputScript = "if input:\nx = 1"
+	cfg.Chains["ci"] = chain
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "input_script:2") {
+		t.Errorf("expected a compile error naming the script, got %v", err)
+	}
+	chain.Steps[0].InputScript = ""
+	chain.Steps[0].Before = []types.StepHook{{Script: "x = 1", Command: "true"}}
+	cfg.Chains["ci"] = chain
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a hook with both a script and a command")
+	}
+}
+
+func TestValidate_Branching(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Roles = map[string]types.Role{"coder": {Provider: "ollama", Model: "llama3", Prompt: "code"}}
+	valid := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "coder", OnError: "fixer", Goto: "{{if .done}}end{{end}}"},
+		{Name: "fixer", Role: "coder", When: "{{.tests}} == 'fail'", Goto: "coder"},
+	}}
+	cfg.Chains = map[string]types.RoleChain{"ci": valid}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, step := range map[string]types.ChainRole{
+		"unknown goto":     {Role: "coder", Goto: "deploy"},
+		"unknown on_error": {Role: "coder", OnError: "retry"},
+		"bad when":         {Role: "coder", When: "{{.tests"},
+	} {
+		cfg.Chains["ci"] = types.RoleChain{Steps: []types.ChainRole{step}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestValidate_Changelog(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Roles = map[string]types.Role{"scribe": {Provider: "ollama", Model: "llama3", Prompt: "Summarize {{.diff}}"}}
+	cfg.Changelog.Role = "scribe"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Changelog.Role = "writer"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "changelog.role 'writer'") {
+		t.Fatalf("expected error for an unknown changelog role, got %v", err)
+	}
+}
+
+func TestValidate_SimulationResults(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Simulation.DryRun = true
+	cfg.Simulation.Results = map[string]string{"run_command": types.SimulateEcho, "read_file": types.SimulateRun}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Simulation.Results["write_file"] = types.SimulateFixture
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "simulation.results.write_file is fixture") {
+		t.Fatalf("expected error for a fixture result without fixtures, got %v", err)
+	}
+	cfg.Simulation.Results["write_file"] = "pretend"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "simulation.results.write_file must be") {
+		t.Fatalf("expected error for an unknown result mode, got %v", err)
+	}
+	delete(cfg.Simulation.Results, "write_file")
+	cfg.Simulation.DefaultResult = types.SimulateFixture
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "simulation.default_result") {
+		t.Fatalf("expected error for a fixture default result, got %v", err)
+	}
+}
+
+func TestValidate_Checkpoints(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Roles = map[string]types.Role{"coder": {Provider: "ollama", Model: "llama3", Prompt: "Code"}}
+	cfg.Chains = map[string]types.RoleChain{"build": {Steps: []types.ChainRole{
+		{Name: "design", Role: "coder", Checkpoint: "designed"},
+		{Name: "code", Role: "coder", Checkpoint: "coded"},
+	}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Chains["build"].Steps[1].Checkpoint = "designed"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "both have checkpoint 'designed'") {
+		t.Fatalf("expected error for a duplicate checkpoint, got %v", err)
+	}
+	cfg.Chains["build"].Steps[1].Checkpoint = "after/code"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid checkpoint") {
+		t.Fatalf("expected error for an invalid checkpoint name, got %v", err)
+	}
+}
+
+func TestValidate_Retrieval(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Roles = map[string]types.Role{"coder": {Provider: "ollama", Model: "llama3", Prompt: "Code"}}
+	cfg.Chains = map[string]types.RoleChain{"build": {Steps: []types.ChainRole{
+		{Role: "coder", Retrieve: &types.RetrieveStep{TopK: 3}},
+	}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "the openai provider of retrieval is not configured") {
+		t.Fatalf("expected error for unconfigured OpenAI embeddings, got %v", err)
+	}
+	cfg.Retrieval.Provider = "ollama"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Retrieval.Provider = "anthropic"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "retrieval.provider must be") {
+		t.Fatalf("expected error for a provider without embeddings, got %v", err)
+	}
+	cfg.Retrieval.Provider = "ollama"
+	cfg.Chains["build"].Steps[0].Retrieve.TopK = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "negative retrieve.top_k") {
+		t.Fatalf("expected error for a negative top_k, got %v", err)
+	}
+}
+
+func TestValidate_Guardrail(t *testing.T) {
+	cfg := Config{}
+	cfg.Ollama.Apiurl = "http://localhost:11434"
+	cfg.Roles = map[string]types.Role{"reviewer": {Provider: "ollama", Model: "llama3", Prompt: "Review {{.tool_call}}"}}
+	cfg.Guardrail.Role = "reviewer"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Guardrail.Role = "safety"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "guardrail.role 'safety'") {
+		t.Fatalf("expected error for an unknown guardrail role, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"ai-team/pkg/errors"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version this build reads. Configs
+// without a version field are version 0.
+const CurrentVersion = 1
+
+// Migration upgrades a config document from version From to From+1 in place.
+// Apply returns a note for each change it made.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(root *yaml.Node) ([]string, error)
+}
+
+// migrations are applied in order; each must have From equal to its index.
+var migrations = []Migration{
+	{From: 0, Description: "chain lists become maps and role inputs maps become lists", Apply: migrateV0},
+}
+
+// MigrationResult describes the outcome of Migrate.
+type MigrationResult struct {
+	From  int
+	To    int
+	Notes []string
+	Data  []byte // The migrated document (the input when nothing changed)
+}
+
+// Changed reports whether any migration ran.
+func (r MigrationResult) Changed() bool {
+	return r.From != r.To
+}
+
+// Migrate upgrades a YAML config document to CurrentVersion. Comments are kept
+// and the document is re-indented with two spaces.
+func Migrate(data []byte) (MigrationResult, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return MigrationResult{}, errors.New(errors.ErrCodeConfig, "failed to parse config for migration", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return MigrationResult{Data: data}, nil
+	}
+	root := doc.Content[0]
+	version := 0
+	if node := mappingValue(root, "version"); node != nil {
+		v, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return MigrationResult{}, errors.New(errors.ErrCodeConfig, fmt.Sprintf("config version '%s' is not a number", node.Value), err)
+		}
+		version = v
+	}
+	result := MigrationResult{From: version, To: version, Data: data}
+	if version > CurrentVersion {
+		return result, errors.New(errors.ErrCodeConfig, fmt.Sprintf("config version %d is newer than this build supports (%d)", version, CurrentVersion), nil)
+	}
+	if version == CurrentVersion {
+		return result, nil
+	}
+	for _, m := range migrations[version:] {
+		notes, err := m.Apply(root)
+		if err != nil {
+			return result, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to migrate config from version %d (%s)", m.From, m.Description), err)
+		}
+		result.Notes = append(result.Notes, notes...)
+	}
+	setVersion(root, CurrentVersion)
+	result.To = CurrentVersion
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return result, errors.New(errors.ErrCodeConfig, "failed to encode migrated config", err)
+	}
+	enc.Close()
+	result.Data = out.Bytes()
+	return result, nil
+}
+
+// migrateLoaded runs the file viper just read through Migrate so older layouts
+// keep loading; the file itself is only rewritten by 'config migrate --write'.
+func migrateLoaded() error {
+	path := viper.ConfigFileUsed()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to read config file: "+path, err)
+	}
+	result, err := Migrate(data)
+	if err != nil {
+		return err
+	}
+	if len(result.Notes) == 0 {
+		return nil
+	}
+	logrus.Warnf("Config %s uses schema version %d; migrated to %d in memory. Run 'ai-team config migrate --write' to update the file.", path, result.From, result.To)
+	if err := viper.ReadConfig(bytes.NewReader(result.Data)); err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to read migrated config: "+path, err)
+	}
+	return nil
+}
+
+// migrateV0 upgrades configs written before the version field existed.
+func migrateV0(root *yaml.Node) ([]string, error) {
+	notes, err := migrateChainList(root)
+	if err != nil {
+		return nil, err
+	}
+	inputNotes, err := migrateRoleInputMaps(root)
+	if err != nil {
+		return nil, err
+	}
+	return append(notes, inputNotes...), nil
+}
+
+// migrateChainList converts
+//
+//	chains:
+//	  - name: review
+//	    steps: [...]
+//
+// to a map keyed by chain name.
+func migrateChainList(root *yaml.Node) ([]string, error) {
+	chains := mappingValue(root, "chains")
+	if chains == nil || chains.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	converted := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: chains.HeadComment, LineComment: chains.LineComment}
+	var notes []string
+	for i, item := range chains.Content {
+		nameNode := mappingValue(item, "name")
+		if item.Kind != yaml.MappingNode || nameNode == nil || nameNode.Value == "" {
+			return nil, fmt.Errorf("chains[%d] has no name", i)
+		}
+		if mappingValue(converted, nameNode.Value) != nil {
+			return nil, fmt.Errorf("chain '%s' is listed more than once", nameNode.Value)
+		}
+		removeMappingKey(item, "name")
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: nameNode.Value, HeadComment: item.HeadComment}
+		item.HeadComment = ""
+		converted.Content = append(converted.Content, key, item)
+		notes = append(notes, fmt.Sprintf("chains[%d] became chains.%s", i, nameNode.Value))
+	}
+	*chains = *converted
+	return notes, nil
+}
+
+// migrateRoleInputMaps converts role inputs keyed by name, either
+//
+//	inputs:
+//	  file: the file to review
+//	  lang: {description: language, default: go}
+//
+// to the list form with a name field on each entry.
+func migrateRoleInputMaps(root *yaml.Node) ([]string, error) {
+	roles := mappingValue(root, "roles")
+	if roles == nil || roles.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var notes []string
+	for i := 0; i+1 < len(roles.Content); i += 2 {
+		roleName, role := roles.Content[i].Value, roles.Content[i+1]
+		inputs := mappingValue(role, "inputs")
+		if inputs == nil || inputs.Kind != yaml.MappingNode {
+			continue
+		}
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: inputs.HeadComment, LineComment: inputs.LineComment}
+		for j := 0; j+1 < len(inputs.Content); j += 2 {
+			key, value := inputs.Content[j], inputs.Content[j+1]
+			name := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.Value}
+			var entry *yaml.Node
+			switch value.Kind {
+			case yaml.ScalarNode:
+				entry = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				if value.Tag != "!!null" && value.Value != "" {
+					desc := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "description"}
+					entry.Content = append(entry.Content, desc, value)
+				}
+			case yaml.MappingNode:
+				entry = value
+				removeMappingKey(entry, "name")
+			default:
+				return nil, fmt.Errorf("roles.%s.inputs.%s must be a description or a mapping", roleName, key.Value)
+			}
+			nameKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"}
+			entry.Content = append([]*yaml.Node{nameKey, name}, entry.Content...)
+			entry.HeadComment, entry.LineComment = key.HeadComment, key.LineComment
+			list.Content = append(list.Content, entry)
+		}
+		*inputs = *list
+		notes = append(notes, fmt.Sprintf("roles.%s.inputs became a list", roleName))
+	}
+	return notes, nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func removeMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// setVersion sets the version field, adding it as the first key if missing.
+func setVersion(root *yaml.Node, version int) {
+	value := strconv.Itoa(version)
+	if node := mappingValue(root, "version"); node != nil {
+		node.Value, node.Tag = value, "!!int"
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version", LineComment: "config schema version"}
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	root.Content = append([]*yaml.Node{key, val}, root.Content...)
+}
+
+// ResolvePath returns the file LoadConfig reads for configPath: configPath
+// itself, or the first config.yaml in the working directory or $HOME/.ai-team.
+func ResolvePath(configPath string) (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	candidates := []string{"config.yaml"}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".ai-team", "config.yaml"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New(errors.ErrCodeConfig, "no config.yaml found in . or $HOME/.ai-team", nil)
+}
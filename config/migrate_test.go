@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateV0(t *testing.T) {
+	data := `# team config
+roles:
+  reviewer:
+    prompt: review {{.file}}
+    inputs:
+      file: the file to review # required
+      lang: {description: language, default: go}
+chains:
+  # the review chain
+  - name: review
+    steps:
+      - name: reviewer
+`
+	result, err := Migrate([]byte(data))
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if result.From != 0 || result.To != CurrentVersion || !result.Changed() {
+		t.Fatalf("unexpected versions: %+v", result)
+	}
+	if len(result.Notes) != 2 {
+		t.Errorf("expected 2 notes, got %v", result.Notes)
+	}
+	out := string(result.Data)
+	for _, want := range []string{"# team config", "# the review chain", "# required", "version: 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("migrated config lacks %q:\n%s", want, out)
+		}
+	}
+
+	var cfg struct {
+		Version int `yaml:"version"`
+		Roles   map[string]struct {
+			Inputs []struct {
+				Name        string `yaml:"name"`
+				Description string `yaml:"description"`
+				Default     string `yaml:"default"`
+			} `yaml:"inputs"`
+		} `yaml:"roles"`
+		Chains map[string]struct {
+			Steps []struct {
+				Name string `yaml:"name"`
+			} `yaml:"steps"`
+		} `yaml:"chains"`
+	}
+	if err := yaml.Unmarshal(result.Data, &cfg); err != nil {
+		t.Fatalf("migrated config does not parse: %v\n%s", err, out)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("version = %d", cfg.Version)
+	}
+	inputs := cfg.Roles["reviewer"].Inputs
+	if len(inputs) != 2 || inputs[0].Name != "file" || inputs[0].Description != "the file to review" || inputs[1].Name != "lang" || inputs[1].Default != "go" {
+		t.Errorf("unexpected inputs: %+v", inputs)
+	}
+	if steps := cfg.Chains["review"].Steps; len(steps) != 1 || steps[0].Name != "reviewer" {
+		t.Errorf("unexpected chains: %+v", cfg.Chains)
+	}
+}
+
+func TestMigrateCurrentVersionUnchanged(t *testing.T) {
+	data := "version: 1\nchains: {}\n"
+	result, err := Migrate([]byte(data))
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if result.Changed() || string(result.Data) != data {
+		t.Errorf("expected no change, got %+v", result)
+	}
+}
+
+func TestMigrateRejectsNewerVersion(t *testing.T) {
+	if _, err := Migrate([]byte("version: 99\n")); err == nil {
+		t.Fatal("expected an error for a newer config version")
+	}
+}
+
+func TestMigrateChainWithoutName(t *testing.T) {
+	if _, err := Migrate([]byte("chains:\n  - steps: []\n")); err == nil {
+		t.Fatal("expected an error for an unnamed chain")
+	}
+}
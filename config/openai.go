@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAIFunction is a function definition in the format of OpenAI's function
+// calling API.
+type OpenAIFunction struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Parameters  types.JSONSchema `json:"parameters"`
+}
+
+// OpenAIToolMapping says how imported functions run: with the command
+// template of their name in Commands, or else by posting their arguments to
+// Endpoint, in which {name} stands for the function name.
+type OpenAIToolMapping struct {
+	Commands map[string]string
+	Endpoint string
+}
+
+// ParseOpenAIFunctions reads OpenAI function definitions: a list of tools
+// ({"type": "function", "function": {...}}) or of functions, an object with
+// such a list under "tools" or "functions", or a single function.
+func ParseOpenAIFunctions(data []byte) ([]OpenAIFunction, error) {
+	var entries []json.RawMessage
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, "failed to parse function definitions", err)
+		}
+	} else {
+		var wrapper struct {
+			Tools     []json.RawMessage `json:"tools"`
+			Functions []json.RawMessage `json:"functions"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, "failed to parse function definitions", err)
+		}
+		entries = append(wrapper.Tools, wrapper.Functions...)
+		if len(entries) == 0 {
+			entries = []json.RawMessage{trimmed}
+		}
+	}
+
+	functions := make([]OpenAIFunction, 0, len(entries))
+	for i, entry := range entries {
+		var tool struct {
+			Type     string          `json:"type"`
+			Function *OpenAIFunction `json:"function"`
+		}
+		if err := json.Unmarshal(entry, &tool); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to parse function definition %d", i+1), err)
+		}
+		fn := tool.Function
+		if fn == nil {
+			if tool.Type != "" && tool.Type != "function" {
+				return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("definition %d is a '%s' tool; only functions can be imported", i+1, tool.Type), nil)
+			}
+			fn = &OpenAIFunction{}
+			if err := json.Unmarshal(entry, fn); err != nil {
+				return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to parse function definition %d", i+1), err)
+			}
+		}
+		if fn.Name == "" {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("function definition %d has no name", i+1), nil)
+		}
+		if fn.Parameters.Type != "" && fn.Parameters.Type != "object" {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("parameters of function '%s' must be an object schema", fn.Name), nil)
+		}
+		functions = append(functions, *fn)
+	}
+	if len(functions) == 0 {
+		return nil, errors.New(errors.ErrCodeConfig, "no function definitions found", nil)
+	}
+	return functions, nil
+}
+
+// ConfigTool converts fn to a config tool run as mapping says. Each parameter
+// becomes an argument, in name order; schema keywords that arguments lack,
+// such as minLength, are dropped.
+func (fn OpenAIFunction) ConfigTool(mapping OpenAIToolMapping) (types.ConfigurableTool, error) {
+	tool := types.ConfigurableTool{Name: fn.Name, Description: fn.Description}
+	if command, ok := mapping.Commands[fn.Name]; ok {
+		tool.CommandTemplate = command
+	} else if mapping.Endpoint != "" {
+		tool.Endpoint = strings.ReplaceAll(mapping.Endpoint, "{name}", fn.Name)
+	} else {
+		return tool, errors.New(errors.ErrCodeConfig, fmt.Sprintf("function '%s' has no command template and no endpoint is set", fn.Name), nil)
+	}
+
+	required := map[string]bool{}
+	for _, name := range fn.Parameters.Required {
+		required[name] = true
+	}
+	for name, prop := range fn.Parameters.Properties {
+		if prop == nil {
+			prop = &types.JSONSchema{}
+		}
+		arg := types.ToolArgument{
+			Name:        name,
+			Type:        prop.Type,
+			Description: prop.Description,
+			Required:    required[name],
+			Enum:        prop.Enum,
+			Default:     prop.Default,
+			Minimum:     prop.Minimum,
+			Maximum:     prop.Maximum,
+			Items:       prop.Items,
+			Properties:  prop.Properties,
+		}
+		if arg.Type == "" {
+			arg.Type = "string"
+		}
+		tool.Arguments = append(tool.Arguments, arg)
+	}
+	sort.Slice(tool.Arguments, func(i, j int) bool { return tool.Arguments[i].Name < tool.Arguments[j].Name })
+
+	if tool.CommandTemplate != "" {
+		if _, err := tools.ParseCommandTemplate(tool); err != nil {
+			return tool, err
+		}
+	} else if !strings.HasPrefix(tool.Endpoint, "http://") && !strings.HasPrefix(tool.Endpoint, "https://") {
+		return tool, errors.New(errors.ErrCodeConfig, fmt.Sprintf("endpoint of function '%s' must be an http(s) URL", fn.Name), nil)
+	}
+	return tool, nil
+}
+
+// ImportOpenAIFunctions adds functions to the tools of a config document as
+// mapping says, like ImportBundle adds the tools of a bundle: for each tool
+// that differs from the local one of the same name, resolve decides whether
+// the imported one replaces it.
+func ImportOpenAIFunctions(data []byte, functions []OpenAIFunction, mapping OpenAIToolMapping, resolve func(BundleConflict) (bool, error)) (BundleImport, error) {
+	var result BundleImport
+	names := map[string]bool{}
+	for _, fn := range functions {
+		names[fn.Name] = true
+	}
+	for name := range mapping.Commands {
+		if !names[name] {
+			return result, errors.New(errors.ErrCodeConfig, fmt.Sprintf("a command template is given for '%s', which is not among the functions", name), nil)
+		}
+	}
+
+	incoming := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, fn := range functions {
+		tool, err := fn.ConfigTool(mapping)
+		if err != nil {
+			return result, err
+		}
+		node, err := configNode(reflect.ValueOf(tool))
+		if err != nil {
+			return result, err
+		}
+		incoming.Content = append(incoming.Content, node)
+	}
+
+	root, doc, err := parseConfigDocument(data)
+	if err != nil {
+		return result, err
+	}
+	if err := mergeTools(&result, resolve, root, incoming); err != nil {
+		return result, err
+	}
+	result.Data = data
+	if result.Changed() {
+		if result.Data, err = encodeYAML(doc); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// configNode encodes v as YAML under the mapstructure names of its fields,
+// leaving out zero values, so that it loads back as the same config.
+func configNode(v reflect.Value) (*yaml.Node, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+		}
+		return configNode(v.Elem())
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" || v.Field(i).IsZero() {
+				continue
+			}
+			child, err := configNode(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			appendMapping(node, name, child)
+		}
+		return node, nil
+	case reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			child, err := configNode(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			appendMapping(node, fmt.Sprint(key), child)
+		}
+		return node, nil
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			child, err := configNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	}
+	var node yaml.Node
+	if err := node.Encode(v.Interface()); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, "failed to encode YAML", err)
+	}
+	return &node, nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const openAIFunctions = `[
+  {
+    "type": "function",
+    "function": {
+      "name": "get_weather",
+      "description": "Gets the weather of a city.",
+      "parameters": {
+        "type": "object",
+        "properties": {
+          "city": {"type": "string", "description": "City name."},
+          "unit": {"type": "string", "enum": ["celsius", "fahrenheit"], "default": "celsius"},
+          "days": {"type": ["integer", "null"], "minimum": 1, "maximum": 7}
+        },
+        "required": ["city"]
+      }
+    }
+  },
+  {
+    "type": "function",
+    "function": {
+      "name": "search_code",
+      "parameters": {"type": "object", "properties": {"pattern": {"type": "string"}}}
+    }
+  }
+]`
+
+func TestParseOpenAIFunctions(t *testing.T) {
+	for name, data := range map[string]string{
+		"tools":            openAIFunctions,
+		"functions object": `{"functions": [{"name": "a"}, {"name": "b", "parameters": {"type": "object"}}]}`,
+		"single":           `{"name": "a", "description": "One function."}`,
+	} {
+		functions, err := ParseOpenAIFunctions([]byte(data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if functions[0].Name == "" || (name != "single" && len(functions) != 2) {
+			t.Errorf("%s: got %+v", name, functions)
+		}
+	}
+	for data, want := range map[string]string{
+		`[{"type": "code_interpreter"}]`:                  "only functions",
+		`[{"description": "no name"}]`:                    "no name",
+		`{"name": "a", "parameters": {"type": "string"}}`: "object schema",
+		`[]`: "no function definitions",
+	} {
+		if _, err := ParseOpenAIFunctions([]byte(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseOpenAIFunctions(%s) = %v, want an error about %q", data, err, want)
+		}
+	}
+}
+
+func TestImportOpenAIFunctions(t *testing.T) {
+	functions, err := ParseOpenAIFunctions([]byte(openAIFunctions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := `version: 1
+ollama:
+  apiurl: http://localhost:11434
+tools:
+  - name: search_code # greps the repository
+    command_template: "grep -rn -- {{.pattern}} ."
+    arguments:
+      - name: pattern
+        type: string
+`
+	mapping := OpenAIToolMapping{
+		Commands: map[string]string{"search_code": "git grep -n -- {{.pattern}}"},
+		Endpoint: "http://localhost:8080/tools/{name}",
+	}
+	var conflicts []string
+	result, err := ImportOpenAIFunctions([]byte(local), functions, mapping, func(c BundleConflict) (bool, error) {
+		conflicts = append(conflicts, c.Section+"."+c.Name)
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(result.Added, ",") != "tools.get_weather" || strings.Join(result.Kept, ",") != "tools.search_code" || len(conflicts) != 1 {
+		t.Errorf("unexpected result %+v, conflicts %v", result, conflicts)
+	}
+	if !strings.Contains(string(result.Data), "# greps the repository") {
+		t.Errorf("expected comments kept:\n%s", result.Data)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, result.Data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("imported config does not load: %v\n%s", err, result.Data)
+	}
+	weather := cfg.Tools[1]
+	if weather.Endpoint != "http://localhost:8080/tools/get_weather" || len(weather.Arguments) != 3 {
+		t.Fatalf("unexpected tool %+v", weather)
+	}
+	city, days, unit := weather.Arguments[0], weather.Arguments[1], weather.Arguments[2]
+	if city.Name != "city" || !city.Required || days.Type != "integer" || days.Maximum == nil || *days.Maximum != 7 || len(unit.Enum) != 2 || unit.Default != "celsius" {
+		t.Errorf("unexpected arguments %+v", weather.Arguments)
+	}
+
+	if _, err := ImportOpenAIFunctions([]byte(local), functions[:1], OpenAIToolMapping{}, nil); err == nil || !strings.Contains(err.Error(), "no endpoint") {
+		t.Errorf("expected an unmapped function refused, got %v", err)
+	}
+	if _, err := ImportOpenAIFunctions([]byte(local), functions, OpenAIToolMapping{Commands: map[string]string{"get_weather": "weather {{.town}}"}, Endpoint: "http://x/{name}"}, nil); err == nil {
+		t.Error("expected a template with undeclared arguments refused")
+	}
+}
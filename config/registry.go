@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/registry"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveRegistryRefs replaces the roles and chains whose from key is a
+// registry reference with the fetched definition. Keys set next to from are
+// applied over the definition, e.g. to pick another model.
+func resolveRegistryRefs() error {
+	var client *registry.Client
+	// The config sections have the names of the registry directories.
+	for _, kind := range []string{registry.KindRole, registry.KindChain} {
+		for name, v := range viper.GetStringMap(kind) {
+			local, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			from, _ := local["from"].(string)
+			if from == "" {
+				continue
+			}
+			ref, err := registry.ParseRef(from)
+			if err != nil {
+				return err
+			}
+			if client == nil {
+				if client, err = registry.Open(""); err != nil {
+					return err
+				}
+			}
+			data, err := client.Fetch(kind, ref)
+			if err != nil {
+				return err
+			}
+			def := map[string]interface{}{}
+			if err := yaml.Unmarshal(data, &def); err != nil {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid definition in %s", ref), err)
+			}
+			for k, v := range local {
+				def[k] = v
+			}
+			viper.Set(kind+"."+name, def)
+		}
+	}
+	return nil
+}
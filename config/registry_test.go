@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"ai-team/pkg/registry"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadConfig_RegistryRoles(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, "roles"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "roles", "reviewer.yaml"), []byte("model_provider: openai\nmodel_name: gpt-4\nprompt: Review {{.code}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "."}, {"commit", "--quiet", "-m", "reviewer"}, {"tag", "v1"}} {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	work := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(work, "cache"))
+	wd, _ := os.Getwd()
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	client, err := registry.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Add("community", repo); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgPath := filepath.Join(work, "config.yaml")
+	yamlData := `
+version: 1
+openai:
+  apiurl: https://api.openai.com/v1
+  apikey: test
+roles:
+  reviewer:
+    from: registry://community/reviewer@v1
+    model_name: gpt-4o
+`
+	if err := os.WriteFile(cfgPath, []byte(yamlData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	defer viper.Reset()
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	role := cfg.Roles["reviewer"]
+	if role.Prompt != "Review {{.code}}" || role.Provider != "openai" {
+		t.Errorf("expected the registry definition, got %+v", role)
+	}
+	if role.Model != "gpt-4o" || role.From != "registry://community/reviewer@v1" {
+		t.Errorf("expected local keys to override the definition, got %+v", role)
+	}
+	if reopened, _ := registry.Open(""); len(reopened.Pins()) != 1 {
+		t.Errorf("expected one pinned definition, got %v", reopened.Pins())
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"ai-team/pkg/errors"
+	"ai-team/pkg/script"
+	"ai-team/pkg/types"
+	"fmt"
+	"sort"
+)
+
+// CompileScripts compiles every script a chain references, keyed by the
+// reference as written in the chain. A reference is the name of a script in
+// the chain's scripts section or, when no script has that name, inline source.
+func CompileScripts(cname string, chain types.RoleChain) (map[string]*script.Program, error) {
+	programs := map[string]*script.Program{}
+	compile := func(ref, where string) error {
+		if ref == "" || programs[ref] != nil {
+			return nil
+		}
+		name, src := where, ref
+		if shared, ok := chain.Scripts[ref]; ok {
+			name, src = ref, shared
+		}
+		prog, err := script.Compile(name, src)
+		if err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain '%s' has an invalid script", cname), err)
+		}
+		programs[ref] = prog
+		return nil
+	}
+	names := make([]string, 0, len(chain.Scripts))
+	for name := range chain.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := compile(name, name); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range chain.Steps {
+		key := step.Name
+		if key == "" {
+			key = step.Role
+		}
+		for field, ref := range map[string]string{"input_script": step.InputScript, "output_script": step.OutputScript, "loop_script": step.LoopScript} {
+			if err := compile(ref, key+"."+field); err != nil {
+				return nil, err
+			}
+		}
+		for _, hook := range append(append([]types.StepHook{}, step.Before...), step.After...) {
+			if err := compile(hook.Script, key+".hook"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return programs, nil
+}
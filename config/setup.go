@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ai-team/pkg/ai"
+	"ai-team/pkg/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetupProvider is a provider the guided setup offers.
+type SetupProvider struct {
+	Name     string // Config section, e.g. openai
+	APIURL   string
+	Model    string // Suggested model of the starter role
+	NeedsKey bool
+}
+
+// SetupProviders are the providers the guided setup offers, in the order it
+// lists them.
+var SetupProviders = []SetupProvider{
+	{Name: "openai", APIURL: "https://api.openai.com/v1", Model: "gpt-4o-mini", NeedsKey: true},
+	{Name: "gemini", APIURL: "https://generativelanguage.googleapis.com", Model: "gemini-2.5-flash", NeedsKey: true},
+	{Name: "anthropic", APIURL: ai.DefaultClaudeAPIURL, Model: "claude-sonnet-4-5", NeedsKey: true},
+	{Name: "ollama", APIURL: "http://localhost:11434", Model: "llama3.1"},
+}
+
+// Setup holds the answers of the guided setup.
+type Setup struct {
+	Provider SetupProvider
+	APIKey   string
+	Model    string // Provider's model name; Provider.Model when empty
+}
+
+// Missing reports whether no config file would be found for configPath: it
+// is empty and neither ./config.yaml nor $HOME/.ai-team/config.yaml exists.
+// A configPath given explicitly is never missing; reading it reports the
+// error.
+func Missing(configPath string) bool {
+	if configPath != "" {
+		return false
+	}
+	_, err := ResolvePath("")
+	return err != nil
+}
+
+// DefaultSetupPath returns the file the guided setup writes,
+// $HOME/.ai-team/config.yaml.
+func DefaultSetupPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New(errors.ErrCodeConfig, "failed to find the home directory", err)
+	}
+	return filepath.Join(home, ".ai-team", "config.yaml"), nil
+}
+
+// YAML returns the starter config of s: the provider with its model and an
+// assistant role using it.
+func (s Setup) YAML() string {
+	model := s.Model
+	if model == "" {
+		model = s.Provider.Model
+	}
+	// Viper splits keys at dots, so e.g. gemini-2.5-flash is configured as
+	// gemini-2-5-flash.
+	key := strings.ReplaceAll(model, ".", "-")
+	urlKey := "apiurl"
+	if s.Provider.Name == "openai" {
+		urlKey = "default_apiurl"
+	}
+	var b strings.Builder
+	b.WriteString("# Written by the ai-team guided setup. See the README for all settings.\n")
+	fmt.Fprintf(&b, "version: %d\n", CurrentVersion)
+	fmt.Fprintf(&b, "%s:\n", s.Provider.Name)
+	fmt.Fprintf(&b, "  %s: %s\n", urlKey, yamlScalar(s.Provider.APIURL))
+	if s.Provider.NeedsKey {
+		fmt.Fprintf(&b, "  apikey: %s\n", yamlScalar(s.APIKey))
+	}
+	b.WriteString("  models:\n")
+	fmt.Fprintf(&b, "    %s:\n", yamlScalar(key))
+	fmt.Fprintf(&b, "      model: %s\n", yamlScalar(model))
+	b.WriteString("      temperature: 0.2\n")
+	b.WriteString("      max_tokens: 4096\n")
+	b.WriteString(`roles:
+  assistant:
+    model_provider: ` + s.Provider.Name + `
+    model_name: ` + yamlScalar(key) + `
+    prompt: |
+      You are a helpful programming assistant.
+      Task: {{.task}}
+    inputs:
+      - name: task
+        description: What the assistant should do
+        required: true
+`)
+	return b.String()
+}
+
+// yamlScalar returns s as a YAML scalar, quoted when needed.
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// WriteSetup writes the starter config of s to path. The file holds the API
+// key, so only the user may read it; an existing file is not overwritten.
+func WriteSetup(path string, s Setup) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to create config directory: "+filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to create config file: "+path, err)
+	}
+	if _, err := f.WriteString(s.YAML()); err != nil {
+		f.Close()
+		return errors.New(errors.ErrCodeConfig, "failed to write config file: "+path, err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to write config file: "+path, err)
+	}
+	return nil
+}
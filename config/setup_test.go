@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestWriteSetup_LoadsAsConfig(t *testing.T) {
+	for _, provider := range SetupProviders {
+		t.Run(provider.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".ai-team", "config.yaml")
+			setup := Setup{Provider: provider, APIKey: "sk-#not: a comment"}
+			if err := WriteSetup(path, setup); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("mode = %o, want 600", perm)
+			}
+
+			viper.Reset()
+			defer viper.Reset()
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("starter config does not load: %v\n%s", err, setup.YAML())
+			}
+			role, ok := cfg.Roles["assistant"]
+			if !ok || role.Provider != provider.Name {
+				t.Fatalf("assistant role = %+v", role)
+			}
+			req, ok := cfg.ProviderRequest(role.Provider, role.Model)
+			if !ok {
+				t.Fatalf("model %s not configured", role.Model)
+			}
+			if req.Model != provider.Model {
+				t.Errorf("model = %q, want %q", req.Model, provider.Model)
+			}
+			if provider.NeedsKey && req.APIKey != setup.APIKey {
+				t.Errorf("API key = %q, want %q", req.APIKey, setup.APIKey)
+			}
+		})
+	}
+}
+
+func TestWriteSetup_KeepsExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSetup(path, Setup{Provider: SetupProviders[0]}); err == nil {
+		t.Fatal("expected an error for an existing file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "version: 1\n" {
+		t.Errorf("existing file changed: %q", data)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("HOME", t.TempDir())
+	if !Missing("") {
+		t.Error("expected config to be missing")
+	}
+	if Missing("explicit.yaml") {
+		t.Error("an explicit --config path is never missing")
+	}
+	if err := os.WriteFile("config.yaml", []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if Missing("") {
+		t.Error("config.yaml in the working directory not found")
+	}
+}
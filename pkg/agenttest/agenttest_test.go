@@ -0,0 +1,71 @@
+package agenttest
+
+import (
+	"fmt"
+	"testing"
+
+	"ai-team/pkg/ai"
+)
+
+func TestUI_ScriptedAnswers(t *testing.T) {
+	ui := &UI{Confirms: []bool{true}, Selections: []string{"coder"}, Lines: []string{"add"}}
+	if ok, _ := ui.Confirm("Start?"); !ok {
+		t.Error("expected scripted yes")
+	}
+	if ok, _ := ui.Confirm("Again?"); ok {
+		t.Error("expected exhausted confirms to answer no")
+	}
+	if choice, err := ui.PromptSelect([]string{"architect", "coder"}); err != nil || choice != "coder" {
+		t.Errorf("unexpected selection %q, %v", choice, err)
+	}
+	if _, err := ui.PromptSelect([]string{"coder"}); err == nil {
+		t.Error("expected error for exhausted selections")
+	}
+	if line, _ := ui.PromptLine("task"); line != "add" {
+		t.Errorf("unexpected line %q", line)
+	}
+	if edited, _ := ui.OpenEditor("draft"); edited != "draft" {
+		t.Errorf("expected unchanged content, got %q", edited)
+	}
+	_ = ui.Pager("output")
+	if asked := fmt.Sprint(ui.Asked()); asked != "[Start? Again? task]" {
+		t.Errorf("unexpected questions %s", asked)
+	}
+	if paged := ui.Paged(); len(paged) != 1 || paged[0] != "output" {
+		t.Errorf("unexpected paged content %q", paged)
+	}
+}
+
+func TestInstallProvider(t *testing.T) {
+	p := &Provider{Replies: []string{"first", "second"}}
+	t.Run("installed", func(t *testing.T) {
+		InstallProvider(t, p)
+		for _, want := range []string{"first", "second", "second"} {
+			got, err := ai.CallOpenAIFunc(nil, "prompt", "", "", ai.Generation{})
+			if err != nil || got != want {
+				t.Errorf("got %q, %v; want %q", got, err, want)
+			}
+		}
+	})
+	if p.Calls() != 3 {
+		t.Errorf("expected 3 calls, got %d", p.Calls())
+	}
+	if _, err := ai.CallOpenAIFunc(nil, "prompt", "http://mock", "", ai.Generation{}); err != nil {
+		t.Errorf("expected the original function to be restored, got %v", err)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile(t, "a/b.txt", "hello")
+	if got := ws.ReadFile(t, "a/b.txt"); got != "hello" {
+		t.Errorf("unexpected content %q", got)
+	}
+	cfg := ws.Config(nil, nil)
+	if _, ok := cfg.Gemini.Models[MockModel]; !ok || cfg.RunsDir != ws.Path(".ai-team/runs") {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if out := CaptureStdout(t, func() { fmt.Print("captured") }); out != "captured" {
+		t.Errorf("unexpected stdout %q", out)
+	}
+}
@@ -0,0 +1,78 @@
+// Package agenttest helps Go programs that embed ai-team write integration
+// tests without network access or a terminal: an in-memory UI, scripted model
+// providers and temporary workspaces.
+package agenttest
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"ai-team/pkg/ai"
+	"ai-team/pkg/types"
+)
+
+// Provider is a scripted model provider. Each call returns the next reply;
+// the last reply repeats once the list is exhausted. Respond, when set, takes
+// precedence over Replies.
+type Provider struct {
+	Replies []string
+	Respond func(prompt string) (string, error)
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+// Prompts returns the prompts received so far, in order.
+func (p *Provider) Prompts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.prompts...)
+}
+
+// Calls returns the number of calls received so far.
+func (p *Provider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.prompts)
+}
+
+func (p *Provider) reply(prompt string) (string, error) {
+	p.mu.Lock()
+	n := len(p.prompts)
+	p.prompts = append(p.prompts, prompt)
+	p.mu.Unlock()
+	if p.Respond != nil {
+		return p.Respond(prompt)
+	}
+	if len(p.Replies) == 0 {
+		return "", fmt.Errorf("agenttest: provider has no replies")
+	}
+	if n >= len(p.Replies) {
+		n = len(p.Replies) - 1
+	}
+	return p.Replies[n], nil
+}
+
+// InstallProvider routes all Gemini, OpenAI, Ollama and custom provider calls
+// to p until the test ends. Tests using it must not run in parallel.
+func InstallProvider(t testing.TB, p *Provider) {
+	t.Helper()
+	origGemini, origOpenAI, origOllama, origCustom := ai.CallGeminiFunc, ai.CallOpenAIFunc, ai.CallOllamaFunc, ai.CallCustomFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return p.reply(prompt)
+	}
+	ai.CallOpenAIFunc = func(_ *http.Client, prompt, apiURL, apiKey string, _ ai.Generation) (string, error) {
+		return p.reply(prompt)
+	}
+	ai.CallOllamaFunc = func(_ *http.Client, prompt, apiURL, model string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return p.reply(prompt)
+	}
+	ai.CallCustomFunc = func(_ *http.Client, prompt string, _ ai.CustomRequest) (string, error) {
+		return p.reply(prompt)
+	}
+	t.Cleanup(func() {
+		ai.CallGeminiFunc, ai.CallOpenAIFunc, ai.CallOllamaFunc, ai.CallCustomFunc = origGemini, origOpenAI, origOllama, origCustom
+	})
+}
@@ -0,0 +1,135 @@
+package agenttest
+
+import (
+	"fmt"
+	"sync"
+
+	"ai-team/pkg/cli"
+)
+
+// UI is an in-memory cli.UI. It answers from scripted queues and records what
+// it was asked and shown. When a queue is exhausted Confirm answers "no",
+// PromptLine returns "", OpenEditor returns the content unchanged and
+// PromptSelect fails.
+type UI struct {
+	Confirms   []bool   // Answers to Confirm
+	Selections []string // Answers to PromptSelect
+	Lines      []string // Answers to PromptLine
+	Edits      []string // Results of OpenEditor
+
+	mu     sync.Mutex
+	asked  []string
+	paged  []string
+	shown  []interface{}
+	diffs  []string
+	nextCf int
+	nextSl int
+	nextLn int
+	nextEd int
+}
+
+var _ cli.UI = (*UI)(nil)
+
+// Confirm answers the next scripted confirmation.
+func (u *UI) Confirm(prompt string) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.asked = append(u.asked, prompt)
+	if u.nextCf >= len(u.Confirms) {
+		return false, nil
+	}
+	u.nextCf++
+	return u.Confirms[u.nextCf-1], nil
+}
+
+// PromptSelect returns the next scripted selection, which must be one of options.
+func (u *UI) PromptSelect(options []string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.nextSl >= len(u.Selections) {
+		return "", fmt.Errorf("agenttest: no scripted selection for %v", options)
+	}
+	choice := u.Selections[u.nextSl]
+	u.nextSl++
+	for _, o := range options {
+		if o == choice {
+			return choice, nil
+		}
+	}
+	return "", fmt.Errorf("agenttest: scripted selection %q is not one of %v", choice, options)
+}
+
+// PromptLine returns the next scripted line.
+func (u *UI) PromptLine(label string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.asked = append(u.asked, label)
+	if u.nextLn >= len(u.Lines) {
+		return "", nil
+	}
+	u.nextLn++
+	return u.Lines[u.nextLn-1], nil
+}
+
+// OpenEditor returns the next scripted edit.
+func (u *UI) OpenEditor(content string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.nextEd >= len(u.Edits) {
+		return content, nil
+	}
+	u.nextEd++
+	return u.Edits[u.nextEd-1], nil
+}
+
+// Pager records content instead of paging it.
+func (u *UI) Pager(content string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.paged = append(u.paged, content)
+	return nil
+}
+
+// PrettyJSON records obj instead of printing it.
+func (u *UI) PrettyJSON(obj interface{}) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.shown = append(u.shown, obj)
+	return nil
+}
+
+// ShowDiff records diff instead of printing it.
+func (u *UI) ShowDiff(diff string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.diffs = append(u.diffs, diff)
+	return nil
+}
+
+// Asked returns the Confirm prompts and PromptLine labels, in order.
+func (u *UI) Asked() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.asked...)
+}
+
+// Paged returns the content passed to Pager, in order.
+func (u *UI) Paged() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.paged...)
+}
+
+// Shown returns the values passed to PrettyJSON, in order.
+func (u *UI) Shown() []interface{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]interface{}(nil), u.shown...)
+}
+
+// Diffs returns the diffs passed to ShowDiff, in order.
+func (u *UI) Diffs() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.diffs...)
+}
@@ -0,0 +1,102 @@
+package agenttest
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/types"
+)
+
+// MockModel is the model name Workspace.Config defines for every provider.
+const MockModel = "mock"
+
+// Workspace is a temporary directory that is the working directory for the
+// duration of a test, so tools writing relative paths stay inside it.
+type Workspace struct {
+	Dir string
+}
+
+// NewWorkspace creates an empty workspace and changes into it until the test
+// ends. Tests using it must not run in parallel.
+func NewWorkspace(t testing.TB) *Workspace {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(previous) })
+	return &Workspace{Dir: dir}
+}
+
+// Path returns the absolute path of a file in the workspace.
+func (w *Workspace) Path(rel string) string {
+	return filepath.Join(w.Dir, rel)
+}
+
+// WriteFile creates a file in the workspace, including parent directories.
+func (w *Workspace) WriteFile(t testing.TB, rel, content string) {
+	t.Helper()
+	path := w.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+}
+
+// ReadFile returns the content of a file in the workspace, failing the test
+// if it cannot be read.
+func (w *Workspace) ReadFile(t testing.TB, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(w.Path(rel))
+	if err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	return string(data)
+}
+
+// Config returns a configuration with a MockModel for each provider, run
+// records stored inside the workspace and the given roles and chains. Calls
+// to the models only succeed once a Provider is installed.
+func (w *Workspace) Config(roles map[string]types.Role, chains map[string]types.RoleChain) *config.Config {
+	cfg := &config.Config{Roles: roles, Chains: chains}
+	cfg.Gemini.Apiurl = "http://agenttest.invalid"
+	cfg.Gemini.Models = map[string]config.ModelConfig{MockModel: {Model: MockModel}}
+	cfg.OpenAI.DefaultApiurl = "http://agenttest.invalid/v1"
+	cfg.OpenAI.Models = map[string]config.ModelConfig{MockModel: {Model: MockModel}}
+	cfg.Ollama.Apiurl = "http://agenttest.invalid"
+	cfg.Ollama.Models = map[string]config.ModelConfig{MockModel: {Model: MockModel}}
+	cfg.RunsDir = w.Path(".ai-team/runs")
+	return cfg
+}
+
+// CaptureStdout runs fn and returns what it wrote to os.Stdout.
+func CaptureStdout(t testing.TB, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	defer func() { os.Stdout = orig }()
+	fn()
+	w.Close()
+	return <-out
+}
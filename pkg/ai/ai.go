@@ -4,10 +4,8 @@ import (
 	"ai-team/pkg/errors"
 	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 
@@ -19,19 +17,78 @@ import (
 // AIClient abstracts provider-specific logic for chat and embedding.
 type AIClient interface {
 	ChatCompletion(task string) (string, error)
-	// Add more methods as needed, e.g. Embedding, Image, etc.
+	// ChatCompletionStream is ChatCompletion with the generated text passed to
+	// onText as it arrives.
+	ChatCompletionStream(task string, onText StreamFunc) (string, error)
+	// Embedding returns the embedding vector of text.
+	Embedding(text string) ([]float32, error)
+}
+
+// Generation holds the sampling settings of a model, sent with every request
+// to it. Zero values leave the provider's default.
+type Generation struct {
+	Temperature float32
+	MaxTokens   int
+}
+
+// DefaultOpenAIMaxTokens is the completion limit of CallOpenAI and
+// StreamOpenAI requests for models without max_tokens.
+const DefaultOpenAIMaxTokens = 100
+
+func (g Generation) temperature() *float32 {
+	if g.Temperature == 0 {
+		return nil
+	}
+	t := g.Temperature
+	return &t
+}
+
+// geminiConfig returns the generationConfig of a Gemini request, or nil.
+func (g Generation) geminiConfig() *types.GeminiGenerationConfig {
+	if g == (Generation{}) {
+		return nil
+	}
+	return &types.GeminiGenerationConfig{Temperature: g.temperature(), MaxOutputTokens: g.MaxTokens}
+}
+
+// ollamaOptions returns the options of an Ollama request, or nil.
+func (g Generation) ollamaOptions() *types.OllamaOptions {
+	if g == (Generation{}) {
+		return nil
+	}
+	return &types.OllamaOptions{Temperature: g.temperature(), NumPredict: g.MaxTokens}
+}
+
+// openAIBody returns the fields of a completions request for task.
+func (g Generation) openAIBody(task string) map[string]interface{} {
+	maxTokens := g.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = DefaultOpenAIMaxTokens
+	}
+	body := map[string]interface{}{
+		"model":      "text-davinci-003",
+		"prompt":     task,
+		"max_tokens": maxTokens,
+	}
+	if t := g.temperature(); t != nil {
+		body["temperature"] = *t
+	}
+	return body
 }
 
 // OpenAIClient implements AIClient for OpenAI.
 type OpenAIClient struct {
-	Client *http.Client
-	APIURL string
-	APIKey string
-	Model  string
+	Client     *http.Client
+	APIURL     string
+	APIKey     string
+	Model      string
+	Generation Generation
+
+	EmbeddingModel string // Model of Embedding calls (default text-embedding-3-small)
 }
 
 func (c *OpenAIClient) ChatCompletion(task string) (string, error) {
-	return CallOpenAI(c.Client, task, c.APIURL, c.APIKey)
+	return CallOpenAI(c.Client, task, c.APIURL, c.APIKey, c.Generation)
 }
 
 // GeminiClient implements AIClient for Gemini.
@@ -41,10 +98,13 @@ type GeminiClient struct {
 	APIKey            string
 	Model             string
 	ConfigurableTools []types.ConfigurableTool
+	Generation        Generation
+
+	EmbeddingModel string // Model of Embedding calls (default text-embedding-004)
 }
 
 func (c *GeminiClient) ChatCompletion(task string) (string, error) {
-	return CallGemini(c.Client, task, c.Model, c.APIURL, c.APIKey, c.ConfigurableTools)
+	return CallGemini(c.Client, task, c.Model, c.APIURL, c.APIKey, c.ConfigurableTools, c.Generation)
 }
 
 // OllamaClient implements AIClient for Ollama.
@@ -53,10 +113,13 @@ type OllamaClient struct {
 	APIURL            string
 	Model             string
 	ConfigurableTools []types.ConfigurableTool
+	Generation        Generation
+
+	EmbeddingModel string // Model of Embedding calls (default nomic-embed-text)
 }
 
 func (c *OllamaClient) ChatCompletion(task string) (string, error) {
-	return CallOllama(c.Client, task, c.APIURL, c.Model, c.ConfigurableTools)
+	return CallOllama(c.Client, task, c.APIURL, c.Model, c.ConfigurableTools, c.Generation)
 }
 
 // CallGeminiFunc allows mocking of CallGemini in tests
@@ -65,7 +128,10 @@ var CallGeminiFunc = CallGemini
 // CallOpenAIFunc allows mocking of CallOpenAI in tests
 var CallOpenAIFunc = CallOpenAI
 
-func CallOpenAI(client *http.Client, task string, apiURL string, apiKey string) (string, error) {
+// CallOllamaFunc allows mocking of CallOllama in tests
+var CallOllamaFunc = CallOllama
+
+func CallOpenAI(client *http.Client, task string, apiURL string, apiKey string, gen Generation) (string, error) {
 	logrus.Info("Calling OpenAI API...")
 
 	// Mock response for testing
@@ -74,13 +140,12 @@ func CallOpenAI(client *http.Client, task string, apiURL string, apiKey string)
 	}
 
 	// Construct a simple request body (keep it flexible -- callers can pass a provider-specific apiURL)
-	requestBody := strings.NewReader(`{
-		"model": "text-davinci-003",
-		"prompt": "` + task + `",
-		"max_tokens": 100
-	}`)
+	bodyBytes, err := json.Marshal(gen.openAIBody(task))
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to marshal openai request body", err)
+	}
 
-	req, err := http.NewRequest("POST", apiURL, requestBody)
+	req, err := http.NewRequest("POST", apiURL, strings.NewReader(string(bodyBytes)))
 	if err != nil {
 		return "", errors.New(errors.ErrCodeAPI, "failed to create openai request", err)
 	}
@@ -94,9 +159,9 @@ func CallOpenAI(client *http.Client, task string, apiURL string, apiKey string)
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, readErr := io.ReadAll(resp.Body)
+	bodyString, readErr := readResponseBody(resp, "openai")
 	if readErr != nil {
-		return "", errors.New(errors.ErrCodeAPI, "failed to read openai response body", readErr)
+		return "", readErr
 	}
 
 	// If non-200, try to surface an API error message
@@ -106,18 +171,23 @@ func CallOpenAI(client *http.Client, task string, apiURL string, apiKey string)
 				Message string `json:"message"`
 			} `json:"error"`
 		}
-		if err := json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&apiErr); err == nil && apiErr.Error.Message != "" {
+		if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&apiErr); err == nil && apiErr.Error.Message != "" {
 			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("OpenAI API error: %s", apiErr.Error.Message), nil)
 		}
 		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("OpenAI API returned status %d", resp.StatusCode), nil)
 	}
 
-	bodyString := string(bodyBytes)
-	logger.DebugPrintf("Raw OpenAI response: %s", bodyString)
+	logger.DebugPrintf("Raw OpenAI response: %s", logPreview(bodyString))
 	return bodyString, nil
 }
 
-func CallGemini(client *http.Client, task string, model string, apiURL string, apiKey string, configurableTools []types.ConfigurableTool) (string, error) {
+func CallGemini(client *http.Client, task string, model string, apiURL string, apiKey string, configurableTools []types.ConfigurableTool, gen Generation) (string, error) {
+	return callGemini(client, nil, task, model, apiURL, apiKey, nil, gen)
+}
+
+// callGemini sends task to the generateContent method after the turns of
+// history, offering the model tools when there are any.
+func callGemini(client *http.Client, history []types.Message, task string, model string, apiURL string, apiKey string, geminiTools []types.GeminiTool, gen Generation) (string, error) {
 	logrus.Infof("Calling Gemini API with model: %s", model)
 
 	// Mock response for testing
@@ -126,7 +196,10 @@ func CallGemini(client *http.Client, task string, model string, apiURL string, a
 	}
 
 	// Construct the full API URL with the model
-	fullAPIURL := fmt.Sprintf("%s/models/%s:generateContent", apiURL, model)
+	fullAPIURL, err := JoinURL(apiURL, geminiPath(geminiGeneratePath(client), model))
+	if err != nil {
+		return "", err
+	}
 
 	// Escape the task string for JSON
 	request := types.GeminiRequest{
@@ -137,6 +210,22 @@ func CallGemini(client *http.Client, task string, model string, apiURL string, a
 				},
 			},
 		},
+		Tools:            geminiTools,
+		GenerationConfig: gen.geminiConfig(),
+	}
+	if len(history) > 0 {
+		system, turns := chatTurns(history, task)
+		request.Contents = make([]types.GeminiContent, len(turns))
+		for i, turn := range turns {
+			role := "user"
+			if turn.Role == types.MessageAssistant {
+				role = "model"
+			}
+			request.Contents[i] = types.GeminiContent{Role: role, Parts: []types.GeminiPart{{Text: turn.Content}}}
+		}
+		if system != "" {
+			request.SystemInstruction = &types.GeminiContent{Parts: []types.GeminiPart{{Text: system}}}
+		}
 	}
 	bodyBytes, err := json.Marshal(request)
 	if err != nil {
@@ -151,7 +240,9 @@ func CallGemini(client *http.Client, task string, model string, apiURL string, a
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.URL.RawQuery = "key=" + apiKey
+	query := req.URL.Query()
+	query.Set("key", apiKey)
+	req.URL.RawQuery = query.Encode()
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -160,9 +251,9 @@ func CallGemini(client *http.Client, task string, model string, apiURL string, a
 	defer resp.Body.Close()
 
 	// Read the response body once to allow for multiple decodes
-	bodyBytes, readErr := io.ReadAll(resp.Body)
+	bodyString, readErr := readResponseBody(resp, "gemini")
 	if readErr != nil {
-		return "", errors.New(errors.ErrCodeAPI, "failed to read gemini response body", readErr)
+		return "", readErr
 	}
 
 	// Check for API errors first (e.g., non-200 status code with error message)
@@ -172,14 +263,13 @@ func CallGemini(client *http.Client, task string, model string, apiURL string, a
 				Message string `json:"message"`
 			} `json:"error"`
 		}
-		if err := json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&apiError); err == nil && apiError.Error.Message != "" {
+		if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&apiError); err == nil && apiError.Error.Message != "" {
 			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Gemini API error: %s", apiError.Error.Message), nil)
 		}
 		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Gemini API returned status %d", resp.StatusCode), nil)
 	}
 
-	bodyString := string(bodyBytes)
-	logger.DebugPrintf("Raw Gemini response: %s\n", bodyString)
+	logger.DebugPrintf("Raw Gemini response: %s\n", logPreview(bodyString))
 
 	// Do not extract or execute tool calls here; just return the raw model response
 	return bodyString, nil
@@ -192,19 +282,22 @@ var (
 	ApplyPatchFunc = tools.ApplyPatch
 )
 
-func CallOllama(client *http.Client, task string, apiURL string, model string, tools []types.ConfigurableTool) (string, error) {
+func CallOllama(client *http.Client, task string, apiURL string, model string, tools []types.ConfigurableTool, gen Generation) (string, error) {
+	return callOllama(client, nil, task, apiURL, model, gen)
+}
+
+// callOllama sends task to the chat endpoint after the messages of history.
+func callOllama(client *http.Client, history []types.Message, task string, apiURL string, model string, gen Generation) (string, error) {
 	logrus.Info("Calling Ollama API...")
 	var reqBody = types.OllamaRequest{
-		Model: model,
-		Messages: []struct {
+		Model:   model,
+		Options: gen.ollamaOptions(),
+	}
+	for _, m := range chatMessages(history, task) {
+		reqBody.Messages = append(reqBody.Messages, struct {
 			Role    string `json:"role"`
 			Content string `json:"content"`
-		}{
-			{
-				Role:    "user",
-				Content: task,
-			},
-		},
+		}{Role: m.Role, Content: m.Content})
 	}
 	bodyStr, err := json.Marshal(reqBody)
 	if err != nil {
@@ -226,38 +319,43 @@ func CallOllama(client *http.Client, task string, apiURL string, model string, t
 	defer resp.Body.Close()
 
 	logger.DebugPrintf("Ollama response status: %s", resp.Status)
-	var bodyBytes, readErr = io.ReadAll(resp.Body)
+	bodyString, readErr := readResponseBody(resp, "ollama")
 	if readErr != nil {
-		return "", errors.New(errors.ErrCodeAPI, "failed to read ollama response body", readErr)
+		return "", readErr
 	}
-	logger.DebugPrintf("Ollama response body: %s", string(bodyBytes))
+	logger.DebugPrintf("Ollama response body: %s", logPreview(bodyString))
 
 	if resp.StatusCode != http.StatusOK {
 		// Try to decode a possible structured error
 		var apiErr struct {
 			Error string `json:"error"`
 		}
-		if err := json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&apiErr); err == nil && apiErr.Error != "" {
+		if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&apiErr); err == nil && apiErr.Error != "" {
 			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Ollama API error: %s", apiErr.Error), nil)
 		}
 		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Ollama API returned status %d", resp.StatusCode), nil)
 	}
 
-	return string(bodyBytes), nil
+	return bodyString, nil
 }
 
 // ListGeminiModels lists available Gemini models.
 func ListGeminiModels(client *http.Client, apiURL string, apiKey string) ([]string, error) {
 	logrus.Info("Listing Gemini models...")
 
-	fullAPIURL := fmt.Sprintf("%s/v1/models", apiURL)
+	fullAPIURL, err := JoinURL(apiURL, geminiModelsPath(client))
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := http.NewRequest("GET", fullAPIURL, nil)
 	if err != nil {
 		return nil, errors.New(errors.ErrCodeAPI, "failed to create gemini list models request", err)
 	}
 
-	req.URL.RawQuery = "key=" + apiKey
+	query := req.URL.Query()
+	query.Set("key", apiKey)
+	req.URL.RawQuery = query.Encode()
 
 	resp, err := client.Do(req)
 	if err != nil {
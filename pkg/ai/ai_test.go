@@ -19,7 +19,7 @@ func TestCallOpenAI(t *testing.T) {
 
 	client := server.Client()
 
-	resp, err := CallOpenAI(client, "write a hello world program in Go", server.URL, "test_api_key")
+	resp, err := CallOpenAI(client, "write a hello world program in Go", server.URL, "test_api_key", Generation{})
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -43,7 +43,7 @@ func TestCallGemini(t *testing.T) {
 
 	client := server.Client()
 
-	resp, err := CallGemini(client, "write a hello world program in Go", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{})
+	resp, err := CallGemini(client, "write a hello world program in Go", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -94,7 +94,7 @@ func TestCallGemini_ToolCall(t *testing.T) {
 
 	client := server.Client()
 
-	resp, err := CallGemini(client, "write a file", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{})
+	resp, err := CallGemini(client, "write a file", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -138,7 +138,7 @@ func TestCallGemini_ModelSelection(t *testing.T) {
 
 	client := server.Client()
 
-	_, err := CallGemini(client, "test task", expectedModel, server.URL, "test_api_key", []types.ConfigurableTool{})
+	_, err := CallGemini(client, "test task", expectedModel, server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -179,7 +179,7 @@ func TestCallGemini_APIError(t *testing.T) {
 
 	client := server.Client()
 
-	_, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{})
+	_, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 	if err == nil {
 		t.Error("expected an error, got nil")
 	}
@@ -197,7 +197,7 @@ func TestCallGemini_MalformedJSON(t *testing.T) {
 
 	client := server.Client()
 
-	resp, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{})
+	resp, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -213,7 +213,7 @@ func TestCallGemini_NetworkError(t *testing.T) {
 
 	client := server.Client()
 
-	_, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{})
+	_, err := CallGemini(client, "test task", "gemini-pro", server.URL, "test_api_key", []types.ConfigurableTool{}, Generation{})
 	if err == nil {
 		t.Error("expected a network error, got nil")
 	}
@@ -231,7 +231,7 @@ func TestCallOllama(t *testing.T) {
 
 	client := server.Client()
 
-	resp, err := CallOllama(client, "write a hello world program in Go", server.URL, "test-model", nil)
+	resp, err := CallOllama(client, "write a hello world program in Go", server.URL, "test-model", nil, Generation{})
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -247,3 +247,56 @@ func TestCallOllama(t *testing.T) {
 		t.Errorf("expected response 'Hello, world!', got %q", or.Response)
 	}
 }
+
+func TestGeneration_RequestBodies(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		switch {
+		case strings.Contains(r.URL.Path, "generateContent"):
+			fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+		case r.URL.Path == "/api/generate":
+			fmt.Fprint(w, `{"response":"ok"}`)
+		default:
+			fmt.Fprint(w, `{"choices":[{"text":"ok"}]}`)
+		}
+	}))
+	defer server.Close()
+	gen := Generation{Temperature: 0.25, MaxTokens: 512}
+
+	if _, err := CallOpenAI(server.Client(), "task", server.URL, "key", gen); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["max_tokens"] != float64(512) || got["temperature"] != 0.25 {
+		t.Errorf("expected max_tokens and temperature in the OpenAI body, got %v", got)
+	}
+	if _, err := CallOpenAI(server.Client(), "task", server.URL, "key", Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := got["temperature"]; ok || got["max_tokens"] != float64(DefaultOpenAIMaxTokens) {
+		t.Errorf("expected the default max_tokens and no temperature, got %v", got)
+	}
+
+	if _, err := CallGemini(server.Client(), "task", "gemini-pro", server.URL, "key", nil, gen); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config, _ := got["generationConfig"].(map[string]interface{})
+	if config["temperature"] != 0.25 || config["maxOutputTokens"] != float64(512) {
+		t.Errorf("expected generationConfig in the Gemini body, got %v", got)
+	}
+	if _, err := CallGemini(server.Client(), "task", "gemini-pro", server.URL, "key", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := got["generationConfig"]; ok {
+		t.Errorf("expected no generationConfig without settings, got %v", got)
+	}
+
+	if _, err := CallOllama(server.Client(), "task", server.URL, "llama", nil, gen); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options, _ := got["options"].(map[string]interface{})
+	if options["temperature"] != 0.25 || options["num_predict"] != float64(512) {
+		t.Errorf("expected options in the Ollama body, got %v", got)
+	}
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/logger"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultClaudeAPIURL is the Anthropic API used when no apiurl is configured.
+const DefaultClaudeAPIURL = "https://api.anthropic.com"
+
+// ClaudeAPIVersion is sent as the anthropic-version header.
+const ClaudeAPIVersion = "2023-06-01"
+
+// ClaudeMessagesPath is joined onto the API URL for Messages API requests.
+const ClaudeMessagesPath = "v1/messages"
+
+// ClaudeRequest describes a Messages API call to an Anthropic Claude model.
+type ClaudeRequest struct {
+	URL         string // API base URL (DefaultClaudeAPIURL when empty)
+	APIKey      string
+	Model       string
+	MaxTokens   int // Required by the Messages API
+	Temperature float32
+
+	// StaticPrompt is the start of the prompt that is the same on every call of
+	// the role, when the role enables prompt caching. It is sent as a separate
+	// content block marked with cache_control.
+	StaticPrompt string
+
+	// History is sent as the earlier turns of the conversation.
+	History []types.Message
+}
+
+// ClaudeClient implements AIClient for Anthropic Claude.
+type ClaudeClient struct {
+	Client      *http.Client
+	APIURL      string
+	APIKey      string
+	Model       string
+	MaxTokens   int
+	Temperature float32
+}
+
+func (c *ClaudeClient) ChatCompletion(task string) (string, error) {
+	return CallClaude(c.Client, task, ClaudeRequest{URL: c.APIURL, APIKey: c.APIKey, Model: c.Model, MaxTokens: c.MaxTokens, Temperature: c.Temperature})
+}
+
+// CallClaudeFunc allows mocking of CallClaude in tests
+var CallClaudeFunc = CallClaude
+
+type claudeContent struct {
+	Type         string            `json:"type"`
+	Text         string            `json:"text"`
+	CacheControl map[string]string `json:"cache_control,omitempty"`
+}
+
+type claudeMessage struct {
+	Role    string          `json:"role"`
+	Content []claudeContent `json:"content"`
+}
+
+type claudeMessagesRequest struct {
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens"`
+	Temperature *float32        `json:"temperature,omitempty"`
+	System      string          `json:"system,omitempty"`
+	Messages    []claudeMessage `json:"messages"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+// CallClaude sends prompt as a user message to the Messages API and returns
+// the raw response.
+func CallClaude(client *http.Client, prompt string, req ClaudeRequest) (string, error) {
+	logrus.Infof("Calling Anthropic API with model: %s", req.Model)
+
+	// Mock response for testing
+	if req.URL == "http://mock" {
+		return `{"type":"message","role":"assistant","content":[{"type":"text","text":"mock response"}],"stop_reason":"end_turn"}`, nil
+	}
+
+	httpReq, err := newClaudeRequest(prompt, req, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to send anthropic request", err)
+	}
+	defer resp.Body.Close()
+
+	bodyString, readErr := readResponseBody(resp, "anthropic")
+	if readErr != nil {
+		return "", readErr
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&apiErr); err == nil && apiErr.Error.Message != "" {
+			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Anthropic API error (%s): %s", apiErr.Error.Type, apiErr.Error.Message), nil)
+		}
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("Anthropic API returned status %d", resp.StatusCode), nil)
+	}
+
+	logger.DebugPrintf("Raw Anthropic response: %s", logPreview(bodyString))
+	return bodyString, nil
+}
+
+// newClaudeRequest builds the Messages API request for prompt.
+func newClaudeRequest(prompt string, req ClaudeRequest, stream bool) (*http.Request, error) {
+	apiURL := req.URL
+	if apiURL == "" {
+		apiURL = DefaultClaudeAPIURL
+	}
+	fullAPIURL, err := JoinURL(apiURL, ClaudeMessagesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []claudeContent
+	if req.StaticPrompt != "" && strings.HasPrefix(prompt, req.StaticPrompt) {
+		content = append(content, claudeContent{Type: "text", Text: req.StaticPrompt, CacheControl: map[string]string{"type": "ephemeral"}})
+		if rest := strings.TrimPrefix(prompt, req.StaticPrompt); rest != "" {
+			content = append(content, claudeContent{Type: "text", Text: rest})
+		}
+	} else {
+		content = []claudeContent{{Type: "text", Text: prompt}}
+	}
+	system, turns := chatTurns(req.History, "")
+	var messages []claudeMessage
+	for _, turn := range turns {
+		messages = append(messages, claudeMessage{Role: turn.Role, Content: []claudeContent{{Type: "text", Text: turn.Content}}})
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == "user" {
+		messages[n-1].Content = append(messages[n-1].Content, content...)
+	} else {
+		messages = append(messages, claudeMessage{Role: "user", Content: content})
+	}
+	request := claudeMessagesRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		System:    system,
+		Messages:  messages,
+		Stream:    stream,
+	}
+	if req.Temperature != 0 {
+		request.Temperature = &req.Temperature
+	}
+	bodyBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to marshal anthropic request body", err)
+	}
+	logger.DebugPrintf("Anthropic request body: %s", logPreview(string(bodyBytes)))
+
+	httpReq, err := http.NewRequest("POST", fullAPIURL, strings.NewReader(string(bodyBytes)))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to create anthropic request", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", req.APIKey)
+	httpReq.Header.Set("anthropic-version", ClaudeAPIVersion)
+	return httpReq, nil
+}
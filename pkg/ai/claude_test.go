@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallClaude(t *testing.T) {
+	var got claudeMessagesRequest
+	var headers http.Header
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers, path = r.Header, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"type":"message","content":[{"type":"text","text":"Hello, world!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":4}}`)
+	}))
+	defer server.Close()
+
+	resp, err := CallClaude(server.Client(), "write hello world", ClaudeRequest{URL: server.URL + "/", APIKey: "sk-ant", Model: "claude-sonnet-4-5", MaxTokens: 1024})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, reason, ok := ResponseText("anthropic", resp); !ok || text != "Hello, world!" || reason != "end_turn" {
+		t.Errorf("unexpected response %q, %q, %v", text, reason, ok)
+	}
+	if path != "/v1/messages" {
+		t.Errorf("request path = %s", path)
+	}
+	if headers.Get("x-api-key") != "sk-ant" || headers.Get("anthropic-version") != ClaudeAPIVersion {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if got.Model != "claude-sonnet-4-5" || got.MaxTokens != 1024 || got.Temperature != nil {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content[0].Text != "write hello world" {
+		t.Errorf("unexpected messages %+v", got.Messages)
+	}
+}
+
+func TestCallClaude_CachesStaticPrompt(t *testing.T) {
+	var got claudeMessagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"content":[]}`)
+	}))
+	defer server.Close()
+
+	_, err := CallClaude(server.Client(), "Static rules.\nReview a.go", ClaudeRequest{URL: server.URL, Model: "m", MaxTokens: 1, Temperature: 0.2, StaticPrompt: "Static rules.\n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := got.Messages[0].Content
+	if len(content) != 2 || content[0].CacheControl["type"] != "ephemeral" || content[1].Text != "Review a.go" || content[1].CacheControl != nil {
+		t.Errorf("unexpected content blocks %+v", content)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.2 {
+		t.Errorf("expected temperature 0.2, got %v", got.Temperature)
+	}
+}
+
+func TestCallClaude_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	}))
+	defer server.Close()
+
+	_, err := CallClaude(server.Client(), "task", ClaudeRequest{URL: server.URL, Model: "m", MaxTokens: 1})
+	if err == nil || !strings.Contains(err.Error(), "Anthropic API error (authentication_error): invalid x-api-key") {
+		t.Errorf("expected API error message, got %v", err)
+	}
+}
+
+func TestClaudeClient_ChatCompletion(t *testing.T) {
+	var c AIClient = &ClaudeClient{Client: http.DefaultClient, APIURL: "http://mock", Model: "m", MaxTokens: 1}
+	resp, err := c.ChatCompletion("hi")
+	if err != nil || !strings.Contains(resp, "mock response") {
+		t.Errorf("unexpected mock response %q, %v", resp, err)
+	}
+}
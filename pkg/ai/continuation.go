@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxContinuationOverlap bounds how much repeated text is trimmed where a
+// continuation starts by restating the end of the previous output.
+const maxContinuationOverlap = 500
+
+// ResponseText extracts the generated text and finish reason from a raw
+// provider response. ok is false when the response is not in a known format.
+func ResponseText(provider, raw string) (text, finishReason string, ok bool) {
+	adapter, found := Provider(provider)
+	if !found {
+		return "", "", false
+	}
+	return adapter.ParseResponse(raw)
+}
+
+// bodyText is ResponseText for a decoded response. It also reads the delta of
+// an OpenAI stream chunk.
+func bodyText(provider string, body map[string]interface{}) (text, finishReason string, ok bool) {
+	adapter, found := Provider(provider)
+	if !found {
+		return "", "", false
+	}
+	if b, isBody := adapter.(bodyAdapter); isBody {
+		return b.parseBody(body)
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return "", "", false
+	}
+	return adapter.ParseResponse(string(raw))
+}
+
+// Truncated reports whether a raw response stopped at the output token limit
+// (Gemini MAX_TOKENS, OpenAI/Ollama "length", Anthropic "max_tokens").
+func Truncated(provider, raw string) bool {
+	_, reason, ok := ResponseText(provider, raw)
+	return ok && (reason == "MAX_TOKENS" || reason == "length" || reason == "max_tokens")
+}
+
+// WithText returns raw with its generated text replaced by text, keeping the
+// provider's response format so callers can parse it as usual.
+func WithText(provider, raw, text string) string {
+	adapter, _ := Provider(provider)
+	b, ok := adapter.(bodyAdapter)
+	if !ok {
+		return raw
+	}
+	body, ok := decodeBody(raw)
+	if !ok || !b.setText(body, text) {
+		return raw
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
+
+// ContinuationPrompt asks the model to carry on from output it was cut off in.
+func ContinuationPrompt(prompt, output string) string {
+	return prompt + "\n\nYour previous response was cut off because it reached the output length limit. Here it is so far:\n\n" +
+		output + "\n\nContinue exactly where it stops. Do not repeat any of it and do not add any preamble."
+}
+
+// JoinContinuation appends next to previous, dropping text at the start of
+// next that repeats the end of previous.
+func JoinContinuation(previous, next string) string {
+	limit := len(next)
+	if len(previous) < limit {
+		limit = len(previous)
+	}
+	if limit > maxContinuationOverlap {
+		limit = maxContinuationOverlap
+	}
+	for n := limit; n > 0; n-- {
+		if strings.HasSuffix(previous, next[:n]) {
+			return previous + next[n:]
+		}
+	}
+	return previous + next
+}
+
+func firstElement(v interface{}) (map[string]interface{}, bool) {
+	list, _ := v.([]interface{})
+	if len(list) == 0 {
+		return nil, false
+	}
+	m, ok := list[0].(map[string]interface{})
+	return m, ok
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResponseText_Providers(t *testing.T) {
+	cases := []struct {
+		provider, raw, text, reason string
+	}{
+		{"gemini", `{"candidates":[{"content":{"parts":[{"text":"a"},{"text":"b"}]},"finishReason":"MAX_TOKENS"}]}`, "ab", "MAX_TOKENS"},
+		{"openai", `{"choices":[{"text":"hello","finish_reason":"length"}]}`, "hello", "length"},
+		{"openai", `{"choices":[{"message":{"content":"hi"},"finish_reason":"stop"}]}`, "hi", "stop"},
+		{"ollama", `{"message":{"content":"yo"},"done_reason":"length"}`, "yo", "length"},
+		{"anthropic", `{"content":[{"type":"text","text":"a"},{"type":"tool_use","name":"x"},{"type":"text","text":"b"}],"stop_reason":"max_tokens"}`, "ab", "max_tokens"},
+	}
+	for _, c := range cases {
+		text, reason, ok := ResponseText(c.provider, c.raw)
+		if !ok || text != c.text || reason != c.reason {
+			t.Errorf("%s: got (%q, %q, %v), want (%q, %q)", c.provider, text, reason, ok, c.text, c.reason)
+		}
+	}
+	if _, _, ok := ResponseText("gemini", "not json"); ok {
+		t.Error("expected non-JSON response to be unrecognized")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	if !Truncated("gemini", `{"candidates":[{"content":{"parts":[{"text":"x"}]},"finishReason":"MAX_TOKENS"}]}`) {
+		t.Error("expected MAX_TOKENS to be truncated")
+	}
+	if Truncated("gemini", `{"candidates":[{"content":{"parts":[{"text":"x"}]},"finishReason":"STOP"}]}`) {
+		t.Error("expected STOP not to be truncated")
+	}
+	if !Truncated("anthropic", `{"content":[{"type":"text","text":"x"}],"stop_reason":"max_tokens"}`) {
+		t.Error("expected max_tokens to be truncated")
+	}
+}
+
+func TestWithText_KeepsFormat(t *testing.T) {
+	raw := `{"candidates":[{"content":{"parts":[{"text":"old"}],"role":"model"},"finishReason":"STOP"}]}`
+	out := WithText("gemini", raw, "new text")
+	text, reason, ok := ResponseText("gemini", out)
+	if !ok || text != "new text" || reason != "STOP" {
+		t.Fatalf("unexpected rewritten response %s", out)
+	}
+	if !strings.Contains(out, `"role":"model"`) {
+		t.Errorf("expected other fields to be kept, got %s", out)
+	}
+}
+
+func TestJoinContinuation_TrimsOverlap(t *testing.T) {
+	if got := JoinContinuation("func main() {\n\tfmt.Println(", "\tfmt.Println(\"hi\")\n}"); got != "func main() {\n\tfmt.Println(\"hi\")\n}" {
+		t.Errorf("unexpected join %q", got)
+	}
+	if got := JoinContinuation("abc", "def"); got != "abcdef" {
+		t.Errorf("unexpected join %q", got)
+	}
+}
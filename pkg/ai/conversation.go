@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+type conversationKey struct{}
+
+// WithConversation returns a context whose role calls send the messages of
+// conv before their prompt and add the prompt and answer to it.
+func WithConversation(ctx context.Context, conv *types.Conversation) context.Context {
+	return context.WithValue(ctx, conversationKey{}, conv)
+}
+
+// ConversationFrom returns the conversation set by WithConversation, or nil.
+func ConversationFrom(ctx context.Context) *types.Conversation {
+	conv, _ := ctx.Value(conversationKey{}).(*types.Conversation)
+	return conv
+}
+
+// CallGeminiChatFunc allows mocking of CallGeminiChat in tests
+var CallGeminiChatFunc = CallGeminiChat
+
+// CallOllamaChatFunc allows mocking of CallOllamaChat in tests
+var CallOllamaChatFunc = CallOllamaChat
+
+// CallGeminiChat is CallGemini sending history as earlier turns of the
+// conversation. Schemas, when given, are declared as functions as by
+// CallGeminiTools.
+func CallGeminiChat(client *http.Client, history []types.Message, task string, model string, apiURL string, apiKey string, schemas []tools.ToolSchema, gen Generation) (string, error) {
+	return callGemini(client, history, task, model, apiURL, apiKey, tools.GeminiTools(schemas), gen)
+}
+
+// CallOllamaChat is CallOllama sending history as earlier chat messages.
+func CallOllamaChat(client *http.Client, history []types.Message, task string, apiURL string, model string, gen Generation) (string, error) {
+	return callOllama(client, history, task, apiURL, model, gen)
+}
+
+// HistoryPrompt returns prompt preceded by history rendered as text, for APIs
+// that take a single prompt. Without history it returns prompt unchanged.
+func HistoryPrompt(history []types.Message, prompt string) string {
+	if len(history) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n\n")
+	for _, m := range history {
+		label := m.Role
+		if m.Role == types.MessageTool {
+			label = "tool " + m.Name
+		}
+		fmt.Fprintf(&b, "[%s]\n%s\n\n", label, m.Content)
+	}
+	b.WriteString("[user]\n")
+	b.WriteString(prompt)
+	return b.String()
+}
+
+// chatTurns converts history followed by prompt to alternating user and
+// assistant turns, as the Anthropic and Gemini APIs require: tool results
+// become user turns naming the tool, and consecutive turns of one role are
+// merged. System messages are joined and returned separately. An empty
+// prompt is left out.
+func chatTurns(history []types.Message, prompt string) (system string, turns []types.Message) {
+	var systems []string
+	add := func(role, content string) {
+		if n := len(turns); n > 0 && turns[n-1].Role == role {
+			turns[n-1].Content += "\n\n" + content
+			return
+		}
+		turns = append(turns, types.Message{Role: role, Content: content})
+	}
+	for _, m := range history {
+		switch m.Role {
+		case types.MessageSystem:
+			systems = append(systems, m.Content)
+		case types.MessageAssistant:
+			add(types.MessageAssistant, m.Content)
+		case types.MessageTool:
+			add(types.MessageUser, fmt.Sprintf("Result of tool %s:\n%s", m.Name, m.Content))
+		default:
+			add(types.MessageUser, m.Content)
+		}
+	}
+	if prompt != "" {
+		add(types.MessageUser, prompt)
+	}
+	return strings.Join(systems, "\n\n"), turns
+}
+
+// chatMessages returns history followed by prompt as chat messages with the
+// system prompt, if any, first.
+func chatMessages(history []types.Message, prompt string) []types.Message {
+	system, turns := chatTurns(history, prompt)
+	if system == "" {
+		return turns
+	}
+	return append([]types.Message{{Role: types.MessageSystem, Content: system}}, turns...)
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+// sampleHistory is a conversation of one tool call.
+func sampleHistory() []types.Message {
+	conv := &types.Conversation{}
+	conv.Add(types.MessageSystem, "Be brief.")
+	conv.Add(types.MessageUser, "List the repo.")
+	conv.Add(types.MessageAssistant, `{"tool_call": {"name": "list_dir"}}`)
+	conv.AddToolResult("list_dir", "main.go")
+	return conv.History()
+}
+
+func TestChatTurns(t *testing.T) {
+	system, turns := chatTurns(sampleHistory(), "Now read main.go.")
+	if system != "Be brief." {
+		t.Errorf("expected the system prompt apart, got %q", system)
+	}
+	if len(turns) != 3 || turns[0].Role != types.MessageUser || turns[1].Role != types.MessageAssistant || turns[2].Role != types.MessageUser {
+		t.Fatalf("expected alternating turns, got %+v", turns)
+	}
+	if turns[2].Content != "Result of tool list_dir:\nmain.go\n\nNow read main.go." {
+		t.Errorf("expected the tool result merged into the next user turn, got %q", turns[2].Content)
+	}
+	if _, turns := chatTurns(nil, ""); len(turns) != 0 {
+		t.Errorf("expected no turns, got %+v", turns)
+	}
+}
+
+func TestHistoryPrompt(t *testing.T) {
+	if got := HistoryPrompt(nil, "hi"); got != "hi" {
+		t.Errorf("expected the prompt unchanged without history, got %q", got)
+	}
+	got := HistoryPrompt(sampleHistory(), "Now read main.go.")
+	for _, want := range []string{"[system]\nBe brief.", "[tool list_dir]\nmain.go", "[user]\nNow read main.go."} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in %q", want, got)
+		}
+	}
+}
+
+func TestWithConversation(t *testing.T) {
+	if ConversationFrom(context.Background()) != nil {
+		t.Error("expected no conversation by default")
+	}
+	conv := &types.Conversation{}
+	if ConversationFrom(WithConversation(context.Background(), conv)) != conv {
+		t.Error("expected the conversation set on the context")
+	}
+}
+
+func TestCallGeminiChat(t *testing.T) {
+	var got types.GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"candidates":[{"content":{"parts":[{"text":"package main"}]}}]}`)
+	}))
+	defer server.Close()
+
+	if _, err := CallGeminiChat(server.Client(), sampleHistory(), "Now read main.go.", "gemini-2.5-flash", server.URL, "key", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Contents) != 3 || got.Contents[0].Role != "user" || got.Contents[1].Role != "model" || got.Contents[2].Role != "user" {
+		t.Errorf("unexpected contents %+v", got.Contents)
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("expected the system prompt as system instruction, got %+v", got.SystemInstruction)
+	}
+	if got.Tools != nil {
+		t.Errorf("expected no tools, got %+v", got.Tools)
+	}
+}
+
+func TestCallOllamaChat(t *testing.T) {
+	var got types.OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`)
+	}))
+	defer server.Close()
+
+	if _, err := CallOllamaChat(server.Client(), sampleHistory(), "Now read main.go.", server.URL, "llama3", Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Messages) != 4 || got.Messages[0].Role != "system" || got.Messages[2].Role != "assistant" || !strings.HasSuffix(got.Messages[3].Content, "Now read main.go.") {
+		t.Errorf("unexpected messages %+v", got.Messages)
+	}
+}
+
+func TestCallClaude_History(t *testing.T) {
+	var got claudeMessagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"content":[]}`)
+	}))
+	defer server.Close()
+
+	req := ClaudeRequest{URL: server.URL, Model: "m", MaxTokens: 1, StaticPrompt: "Static rules.\n", History: sampleHistory()}
+	if _, err := CallClaude(server.Client(), "Static rules.\nNow read main.go.", req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.System != "Be brief." || len(got.Messages) != 3 || got.Messages[1].Role != "assistant" {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	last := got.Messages[2].Content
+	if len(last) != 3 || last[0].Text != "Result of tool list_dir:\nmain.go" || last[1].CacheControl["type"] != "ephemeral" || last[2].Text != "Now read main.go." {
+		t.Errorf("expected the tool result and the prompt blocks in the last user turn, got %+v", last)
+	}
+}
+
+func TestCallOpenAITools_History(t *testing.T) {
+	var got openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	if _, err := CallOpenAITools(server.Client(), "Now read main.go.", OpenAIToolsRequest{URL: server.URL, Model: "gpt-4o", History: sampleHistory()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Messages) != 4 || got.Messages[0].Role != "system" || got.Messages[1].Content != "List the repo." || got.Messages[3].Role != "user" {
+		t.Errorf("unexpected messages %+v", got.Messages)
+	}
+}
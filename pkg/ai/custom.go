@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/logger"
+)
+
+// CustomRequest describes a call to an endpoint configured under the "custom"
+// provider.
+type CustomRequest struct {
+	URL          string
+	APIKey       string
+	Model        string
+	BodyTemplate string // Go template for the JSON request body; see ParseCustomTemplate
+	ResponsePath string // Dotted path to the text in the response, e.g. "choices.0.message.content"
+
+	// StaticPrompt is the start of the prompt that is the same on every call of
+	// the role and CacheKey identifies it, when the role enables prompt caching.
+	StaticPrompt string
+	CacheKey     string
+}
+
+// CallCustomFunc allows mocking of CallCustom in tests
+var CallCustomFunc = CallCustom
+
+// ParseCustomTemplate parses a custom request body template. Templates can use
+// {{.prompt}}, {{.model}} and {{.api_key}}, and {{json .prompt}} to insert a
+// value as a JSON literal. For prompt caching, {{.static_prompt}} and
+// {{.dynamic_prompt}} split the prompt into its cacheable start and the rest
+// ("" and the whole prompt when caching is off), and {{.cache_key}} names the
+// static part.
+func ParseCustomTemplate(text string) (*template.Template, error) {
+	return template.New("request").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+// CallCustom renders req.BodyTemplate with the prompt, POSTs it to req.URL and
+// returns the text found at req.ResponsePath (the whole body when empty).
+func CallCustom(client *http.Client, prompt string, req CustomRequest) (string, error) {
+	tmpl, err := ParseCustomTemplate(req.BodyTemplate)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to parse custom request template", err)
+	}
+	var body bytes.Buffer
+	data := map[string]interface{}{
+		"prompt":         prompt,
+		"model":          req.Model,
+		"api_key":        req.APIKey,
+		"static_prompt":  req.StaticPrompt,
+		"dynamic_prompt": strings.TrimPrefix(prompt, req.StaticPrompt),
+		"cache_key":      req.CacheKey,
+	}
+	if err := tmpl.Execute(&body, data); err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to render custom request template", err)
+	}
+	logger.DebugPrintf("Custom request body: %s", logPreview(body.String()))
+
+	httpReq, err := http.NewRequest("POST", req.URL, &body)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to create custom request", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to send custom request", err)
+	}
+	defer resp.Body.Close()
+
+	bodyString, err := readResponseBody(resp, "custom")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("custom endpoint returned status %d: %s", resp.StatusCode, logPreview(bodyString)), nil)
+	}
+	logger.DebugPrintf("Raw custom response: %s", logPreview(bodyString))
+	if req.ResponsePath == "" {
+		return bodyString, nil
+	}
+	return ExtractResponsePath(bodyString, req.ResponsePath)
+}
+
+// ExtractResponsePath returns the value at a dotted path in a JSON document.
+// Numeric segments index arrays ("choices.0.text"). String values are returned
+// as-is and other values as JSON.
+func ExtractResponsePath(body, path string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "custom response is not valid JSON", err)
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("custom response has no '%s' at '%s'", segment, path), nil)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("custom response has no index '%s' at '%s'", segment, path), nil)
+			}
+			v = node[i]
+		default:
+			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("custom response has no '%s' at '%s'", segment, path), nil)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to encode custom response value", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallCustom(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		w.Write([]byte(`{"result": {"outputs": [{"text": "hello"}]}}`))
+	}))
+	defer server.Close()
+
+	text, err := CallCustom(server.Client(), "say \"hi\"\nplease", CustomRequest{
+		URL:          server.URL,
+		Model:        "llm-7b",
+		BodyTemplate: `{"model": "{{.model}}", "input": {{json .prompt}}}`,
+		ResponsePath: "result.outputs.0.text",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "hello" {
+		t.Errorf("expected extracted text, got %q", text)
+	}
+	if body["model"] != "llm-7b" || body["input"] != "say \"hi\"\nplease" {
+		t.Errorf("unexpected request body %v", body)
+	}
+}
+
+func TestCallCustom_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := CallCustom(server.Client(), "x", CustomRequest{URL: server.URL, BodyTemplate: "{}"}); err == nil {
+		t.Fatal("expected error for non-2xx status")
+	}
+}
+
+func TestExtractResponsePath(t *testing.T) {
+	body := `{"a": {"list": [{"n": 1.50}, "two"], "obj": {"k": true}}}`
+	cases := map[string]string{
+		"a.list.1": "two",
+		"a.list.0": `{"n":1.50}`,
+		"a.obj":    `{"k":true}`,
+	}
+	for path, want := range cases {
+		if got, err := ExtractResponsePath(body, path); err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"a.missing", "a.list.5", "a.list.x", "a.list.1.deeper"} {
+		if _, err := ExtractResponsePath(body, path); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestCallCustom_StaticPrompt(t *testing.T) {
+	var body struct {
+		System []struct {
+			Text         string            `json:"text"`
+			CacheControl map[string]string `json:"cache_control"`
+		} `json:"system"`
+		User string `json:"user"`
+		Key  string `json:"key"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, err := CallCustom(server.Client(), "You review code.\nReview main.go", CustomRequest{
+		URL:          server.URL,
+		BodyTemplate: `{"system": [{"text": {{json .static_prompt}}, "cache_control": {"type": "ephemeral"}}], "user": {{json .dynamic_prompt}}, "key": "{{.cache_key}}"}`,
+		StaticPrompt: "You review code.\n",
+		CacheKey:     "k1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(body.System) != 1 || body.System[0].Text != "You review code.\n" || body.User != "Review main.go" || body.Key != "k1" {
+		t.Errorf("unexpected request body %+v", body)
+	}
+}
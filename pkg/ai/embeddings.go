@@ -0,0 +1,258 @@
+package ai
+
+import (
+	"ai-team/pkg/errors"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/logger"
+)
+
+// CallOpenAIEmbeddingFunc allows mocking of CallOpenAIEmbedding in tests
+var CallOpenAIEmbeddingFunc = CallOpenAIEmbedding
+
+// CallGeminiEmbeddingFunc allows mocking of CallGeminiEmbedding in tests
+var CallGeminiEmbeddingFunc = CallGeminiEmbedding
+
+// CallOllamaEmbeddingFunc allows mocking of CallOllamaEmbedding in tests
+var CallOllamaEmbeddingFunc = CallOllamaEmbedding
+
+// GeminiEmbedPath is the path template of Gemini embedContent requests.
+const GeminiEmbedPath = "models/{model}:embedContent"
+
+// OllamaEmbeddingsPath is joined onto the Ollama server URL for embeddings.
+const OllamaEmbeddingsPath = "api/embeddings"
+
+// CallOpenAIEmbedding requests an embedding vector for text from an
+// OpenAI-compatible /embeddings endpoint. apiURL is the API base URL.
+func CallOpenAIEmbedding(client *http.Client, text string, model string, apiURL string, apiKey string) ([]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to marshal openai embedding request", err)
+	}
+	fullAPIURL, err := JoinURL(apiURL, "embeddings")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", fullAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to create openai embedding request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	bodyBytes, err := sendEmbeddingRequest(client, req, "openai", "OpenAI")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(bodyBytes, &parsed); err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to decode openai embedding response", err)
+	}
+	if len(parsed.Data) == 0 {
+		return nil, errors.New(errors.ErrCodeAPI, "openai embedding response contained no data", nil)
+	}
+	return parsed.Data[0].Embedding, nil
+}
+
+// EmbeddingRequest is the settings of an embeddings call.
+type EmbeddingRequest struct {
+	Model  string // Empty for the provider's default embedding model
+	APIURL string
+	APIKey string
+}
+
+// EmbeddingAdapter is implemented by the adapters of providers with an
+// embeddings API.
+type EmbeddingAdapter interface {
+	// Embed returns the embedding vector of text.
+	Embed(client *http.Client, text string, req EmbeddingRequest) ([]float32, error)
+}
+
+// Embed returns the embedding vector of text from the embeddings API of the
+// provider registered as name.
+func Embed(name string, client *http.Client, text string, req EmbeddingRequest) ([]float32, error) {
+	adapter, _ := Provider(name)
+	embedder, ok := adapter.(EmbeddingAdapter)
+	if !ok {
+		return nil, errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s does not provide embeddings; use %s", name, strings.Join(EmbeddingProviders(), ", ")), nil)
+	}
+	return embedder.Embed(client, text, req)
+}
+
+// EmbeddingProviders returns the names of the registered providers with an
+// embeddings API, sorted.
+func EmbeddingProviders() []string {
+	var names []string
+	for _, name := range Providers() {
+		if adapter, _ := Provider(name); adapter != nil {
+			if _, ok := adapter.(EmbeddingAdapter); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ProviderEmbedder implements cache.Embedder with the embeddings API of a
+// registered provider.
+type ProviderEmbedder struct {
+	Provider string
+	Client   *http.Client
+	Request  EmbeddingRequest
+}
+
+func (e ProviderEmbedder) Embed(text string) ([]float32, error) {
+	return Embed(e.Provider, e.Client, text, e.Request)
+}
+
+// OpenAIEmbedder implements cache.Embedder using an OpenAI-compatible API.
+type OpenAIEmbedder struct {
+	Client *http.Client
+	APIURL string
+	APIKey string
+	Model  string
+}
+
+func (e *OpenAIEmbedder) Embed(text string) ([]float32, error) {
+	return CallOpenAIEmbeddingFunc(e.Client, text, e.Model, e.APIURL, e.APIKey)
+}
+
+// CallGeminiEmbedding requests an embedding vector for text from Gemini's
+// embedContent endpoint. apiURL is the API base URL.
+func CallGeminiEmbedding(client *http.Client, text string, model string, apiURL string, apiKey string) ([]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":   "models/" + model,
+		"content": map[string]interface{}{"parts": []map[string]string{{"text": text}}},
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to marshal gemini embedding request", err)
+	}
+	fullAPIURL, err := JoinURL(apiURL, geminiPath(GeminiEmbedPath, model))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", fullAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to create gemini embedding request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	query := req.URL.Query()
+	query.Set("key", apiKey)
+	req.URL.RawQuery = query.Encode()
+
+	bodyBytes, err := sendEmbeddingRequest(client, req, "gemini", "Gemini")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Embedding struct {
+			Values []float32 `json:"values"`
+		} `json:"embedding"`
+	}
+	if err := json.Unmarshal(bodyBytes, &parsed); err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to decode gemini embedding response", err)
+	}
+	if len(parsed.Embedding.Values) == 0 {
+		return nil, errors.New(errors.ErrCodeAPI, "gemini embedding response contained no values", nil)
+	}
+	return parsed.Embedding.Values, nil
+}
+
+// CallOllamaEmbedding requests an embedding vector for text from Ollama's
+// /api/embeddings endpoint. apiURL is the server URL; a path to another API
+// endpoint, such as /api/generate, is replaced.
+func CallOllamaEmbedding(client *http.Client, text string, model string, apiURL string) ([]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":  model,
+		"prompt": text,
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to marshal ollama embedding request", err)
+	}
+	if i := strings.Index(apiURL, "/api/"); i >= 0 {
+		apiURL = apiURL[:i]
+	}
+	fullAPIURL, err := JoinURL(apiURL, OllamaEmbeddingsPath)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", fullAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to create ollama embedding request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	bodyBytes, err := sendEmbeddingRequest(client, req, "ollama", "Ollama")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(bodyBytes, &parsed); err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, "failed to decode ollama embedding response", err)
+	}
+	if len(parsed.Embedding) == 0 {
+		return nil, errors.New(errors.ErrCodeAPI, "ollama embedding response contained no embedding", nil)
+	}
+	return parsed.Embedding, nil
+}
+
+// sendEmbeddingRequest sends req and returns the body of a successful
+// response. provider names the API in lower case for error messages, title
+// in log and status messages.
+func sendEmbeddingRequest(client *http.Client, req *http.Request, provider, title string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to send %s embedding request", provider), err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to read %s embedding response body", provider), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s embeddings API returned status %d", title, resp.StatusCode), nil)
+	}
+	logger.DebugPrintf("Raw %s embedding response length: %d", title, len(bodyBytes))
+	return bodyBytes, nil
+}
+
+// embeddingModel returns the model used for embeddings: model, or the
+// provider's default embedding model.
+func embeddingModel(model, fallback string) string {
+	if model != "" {
+		return model
+	}
+	return fallback
+}
+
+func (c *OpenAIClient) Embedding(text string) ([]float32, error) {
+	return Embed("openai", c.Client, text, EmbeddingRequest{Model: c.EmbeddingModel, APIURL: c.APIURL, APIKey: c.APIKey})
+}
+
+func (c *GeminiClient) Embedding(text string) ([]float32, error) {
+	return Embed("gemini", c.Client, text, EmbeddingRequest{Model: c.EmbeddingModel, APIURL: c.APIURL, APIKey: c.APIKey})
+}
+
+func (c *OllamaClient) Embedding(text string) ([]float32, error) {
+	return Embed("ollama", c.Client, text, EmbeddingRequest{Model: c.EmbeddingModel, APIURL: c.APIURL})
+}
+
+// Embedding fails: the Anthropic API has no embeddings endpoint.
+func (c *ClaudeClient) Embedding(text string) ([]float32, error) {
+	return Embed("anthropic", c.Client, text, EmbeddingRequest{APIURL: c.APIURL, APIKey: c.APIKey})
+}
@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientEmbedding(t *testing.T) {
+	var got map[string]interface{}
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			fmt.Fprintln(w, `{"data":[{"embedding":[0.1,0.2]}]}`)
+		case "/v1beta/models/text-embedding-004:embedContent":
+			fmt.Fprintln(w, `{"embedding":{"values":[0.3,0.4]}}`)
+		case "/api/embeddings":
+			fmt.Fprintln(w, `{"embedding":[0.5,0.6]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		client AIClient
+		path   string
+		query  string
+		body   map[string]interface{}
+		want   float32
+	}{
+		{"openai", &OpenAIClient{Client: server.Client(), APIURL: server.URL + "/v1", APIKey: "sk", Model: "gpt-4o"}, "/v1/embeddings", "", map[string]interface{}{"model": "text-embedding-3-small", "input": "hello"}, 0.1},
+		{"gemini", &GeminiClient{Client: server.Client(), APIURL: server.URL + "/v1beta", APIKey: "key", Model: "gemini-2.5-flash"}, "/v1beta/models/text-embedding-004:embedContent", "key=key", map[string]interface{}{"model": "models/text-embedding-004", "content": map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": "hello"}}}}, 0.3},
+		{"ollama", &OllamaClient{Client: server.Client(), APIURL: server.URL + "/api/generate", Model: "llama3", EmbeddingModel: "mxbai-embed-large"}, "/api/embeddings", "", map[string]interface{}{"model": "mxbai-embed-large", "prompt": "hello"}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vec, err := tt.client.Embedding("hello")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(vec) != 2 || vec[0] != tt.want {
+				t.Errorf("unexpected embedding %v", vec)
+			}
+			if path != tt.path || query != tt.query {
+				t.Errorf("request to %s?%s", path, query)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.body) {
+				t.Errorf("unexpected request body %v", got)
+			}
+		})
+	}
+}
+
+func TestClientEmbedding_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"embedding":{"values":[]}}`)
+	}))
+	defer server.Close()
+
+	if _, err := (&GeminiClient{Client: server.Client(), APIURL: server.URL}).Embedding("hello"); err == nil {
+		t.Error("expected an error for an empty embedding")
+	}
+	if _, err := (&ClaudeClient{}).Embedding("hello"); err == nil {
+		t.Error("expected an error from the Claude client")
+	}
+}
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"encoding/json"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// StrategyResult is the outcome of one extraction strategy on a response.
+type StrategyResult struct {
+	Strategy string
+	ToolCall *types.ToolCall // nil when the strategy found no valid tool-call
+}
+
+// TryStrategies extracts the tool-call of a provider response with each
+// strategy on its own, to see which of them handle a captured model output.
+// Strategies other than provider_native read the response's text when it is
+// a provider response body.
+func TryStrategies(reg *tools.ToolRegistry, provider, raw string, strategies []string) ([]StrategyResult, error) {
+	text := raw
+	if t, _, ok := ResponseText(provider, raw); ok {
+		text = t
+	}
+	results := make([]StrategyResult, 0, len(strategies))
+	for _, strategy := range strategies {
+		extractor, err := NewToolCallExtractor(reg, provider, []string{strategy})
+		if err != nil {
+			return nil, err
+		}
+		tc, _, _ := extractor.ExtractResponse(raw, text)
+		results = append(results, StrategyResult{Strategy: strategy, ToolCall: tc})
+	}
+	return results, nil
+}
+
+// DetectProvider guesses the provider of a captured response body from its
+// shape: "openai" for choices, "gemini" for candidates, else "".
+func DetectProvider(raw string) string {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		return ""
+	}
+	if _, ok := body["choices"]; ok {
+		return "openai"
+	}
+	if _, ok := body["candidates"]; ok {
+		return "gemini"
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/tools"
+)
+
+// The corpus in testdata/extract holds captured model outputs named
+// <tool>--<description>; each must yield a call of that tool.
+func TestExtractionCorpus(t *testing.T) {
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	files, err := filepath.Glob(filepath.Join("testdata", "extract", "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no corpus samples: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _, _ := strings.Cut(filepath.Base(file), "--")
+		raw := string(data)
+		provider := DetectProvider(raw)
+		extractor, err := NewToolCallExtractor(reg, provider, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := raw
+		if t2, _, ok := ResponseText(provider, raw); ok {
+			text = t2
+		}
+		tc, strategy, err := extractor.ExtractResponse(raw, text)
+		if err != nil || tc == nil || toSnakeCaseLocal(tc.Name) != want {
+			t.Errorf("%s: expected a %s call, got %+v via %q (%v)", file, want, tc, strategy, err)
+		}
+	}
+}
+
+func TestExtractionStrategy(t *testing.T) {
+	for _, name := range []string{"bogus", "regex:(", "regex:tool (\\w+)"} {
+		if _, err := ExtractionStrategy(name, ""); err == nil {
+			t.Errorf("expected an error for %q", name)
+		}
+	}
+	extractor, err := NewToolCallExtractor(nil, "", []string{`regex:CALL (?P<name>\w+) (?P<args>\{.*\})`, "inline_json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tc, strategy, err := extractor.ExtractToolCall(`CALL read_file {"file_path": "a.go"}`)
+	if err != nil || tc.Name != "read_file" || tc.Arguments["file_path"] != "a.go" || !strings.HasPrefix(strategy, "regex:") {
+		t.Errorf("unexpected result %+v via %q (%v)", tc, strategy, err)
+	}
+	extractor, _ = NewToolCallExtractor(nil, "", []string{"json_block"})
+	if tc, _, err := extractor.ExtractToolCall(`{"tool_call": {"name": "x"}}`); err == nil {
+		t.Errorf("expected inline JSON ignored without inline_json, got %+v", tc)
+	}
+}
+
+func TestTryStrategies(t *testing.T) {
+	raw := `{"choices":[{"message":{"content":"{\"tool_call\": {\"name\": \"list_dir\"}}","tool_calls":[{"type":"function","function":{"name":"read_file","arguments":"{}"}}]}}]}`
+	results, err := TryStrategies(nil, DetectProvider(raw), raw, []string{"provider_native", "inline_json", "yaml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].ToolCall == nil || results[0].ToolCall.Name != "read_file" {
+		t.Errorf("expected the native call, got %+v", results[0].ToolCall)
+	}
+	if results[1].ToolCall == nil || results[1].ToolCall.Name != "list_dir" {
+		t.Errorf("expected the call in the message text, got %+v", results[1].ToolCall)
+	}
+	if results[2].ToolCall != nil {
+		t.Errorf("expected no yaml call, got %+v", results[2].ToolCall)
+	}
+}
@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CallGeminiToolsFunc allows mocking of CallGeminiTools in tests
+var CallGeminiToolsFunc = CallGeminiTools
+
+// CallGeminiTools is CallGemini with schemas declared as functions the model
+// may call. NativeToolCall reads the call from the response.
+func CallGeminiTools(client *http.Client, task string, model string, apiURL string, apiKey string, schemas []tools.ToolSchema, gen Generation) (string, error) {
+	return callGemini(client, nil, task, model, apiURL, apiKey, tools.GeminiTools(schemas), gen)
+}
+
+// geminiToolCall returns the first function call of a generateContent
+// response, or nil when there is none.
+func geminiToolCall(response string) (*types.ToolCall, error) {
+	var body types.GeminiResponse
+	if err := json.Unmarshal([]byte(response), &body); err != nil || len(body.Candidates) == 0 {
+		return nil, nil
+	}
+	var calls []*types.GeminiFunctionCall
+	for _, part := range body.Candidates[0].Content.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	if len(calls) > 1 {
+		logrus.Warnf("Gemini returned %d function calls; executing only the first (%s)", len(calls), calls[0].Name)
+	}
+	args := calls[0].Args
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return &types.ToolCall{Name: calls[0].Name, Arguments: args}, nil
+}
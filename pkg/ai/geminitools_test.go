@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+func TestCallGeminiTools(t *testing.T) {
+	var got types.GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Saving."},{"functionCall":{"name":"write_file","args":{"file_path":"a.txt","content":"hi"}}}]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	resp, err := CallGeminiTools(server.Client(), "save hi", "gemini-2.5-flash", server.URL, "key", reg.ListTools(), Generation{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Contents) != 1 || got.Contents[0].Parts[0].Text != "save hi" {
+		t.Errorf("unexpected contents %+v", got.Contents)
+	}
+	if len(got.Tools) != 1 {
+		t.Fatalf("expected one tool with the declarations, got %+v", got.Tools)
+	}
+	var writeFile, listDir *types.GeminiFunctionDeclaration
+	for i, d := range got.Tools[0].FunctionDeclarations {
+		switch d.Name {
+		case "write_file":
+			writeFile = &got.Tools[0].FunctionDeclarations[i]
+		case "list_dir":
+			listDir = &got.Tools[0].FunctionDeclarations[i]
+		}
+	}
+	if writeFile == nil || listDir == nil {
+		t.Fatalf("expected write_file and list_dir among the declarations, got %+v", got.Tools[0].FunctionDeclarations)
+	}
+	params := writeFile.Parameters
+	if params["type"] != "OBJECT" || fmt.Sprint(params["required"]) != "[file_path content]" {
+		t.Errorf("unexpected parameters %v", params)
+	}
+	if prop := params["properties"].(map[string]interface{})["file_path"].(map[string]interface{}); prop["type"] != "STRING" {
+		t.Errorf("unexpected file_path property %v", prop)
+	}
+	if prop := listDir.Parameters["properties"].(map[string]interface{})["recursive"].(map[string]interface{}); prop["type"] != "BOOLEAN" {
+		t.Errorf("unexpected recursive property %v", prop)
+	}
+
+	tc, err := NativeToolCall("gemini", resp)
+	if err != nil || tc == nil {
+		t.Fatalf("expected a tool call, got %v, %v", tc, err)
+	}
+	if tc.Name != "write_file" || tc.Arguments["file_path"] != "a.txt" || tc.Arguments["content"] != "hi" {
+		t.Errorf("unexpected tool call %+v", tc)
+	}
+	if text, _, _ := ResponseText("gemini", resp); text != "Saving." {
+		t.Errorf("expected the text parts as text, got %q", text)
+	}
+}
+
+func TestGeminiToolCall(t *testing.T) {
+	if tc, err := NativeToolCall("gemini", `{"candidates":[{"content":{"parts":[{"text":"{\"tool_call\":{\"name\":\"x\"}}"}]}}]}`); tc != nil || err != nil {
+		t.Errorf("expected text to be left to extraction, got %v, %v", tc, err)
+	}
+	tc, err := NativeToolCall("gemini", `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"list_dir"}},{"functionCall":{"name":"ReadFile","args":{}}}]}}]}`)
+	if err != nil || tc == nil || tc.Name != "list_dir" || tc.Arguments == nil || len(tc.Arguments) != 0 {
+		t.Errorf("expected the first call with empty arguments, got %+v, %v", tc, err)
+	}
+}
@@ -0,0 +1,33 @@
+package ai
+
+import (
+	"context"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+type toolsKey struct{}
+
+// WithTools returns a context offering the tools of registry to providers
+// that support native tool calling.
+func WithTools(ctx context.Context, registry *tools.ToolRegistry) context.Context {
+	return context.WithValue(ctx, toolsKey{}, registry)
+}
+
+// ToolsFrom returns the registry set by WithTools, or nil.
+func ToolsFrom(ctx context.Context) *tools.ToolRegistry {
+	registry, _ := ctx.Value(toolsKey{}).(*tools.ToolRegistry)
+	return registry
+}
+
+// NativeToolCall returns the tool call in a response of a provider's native
+// tool-calling API, or nil when the response has none and the tool call, if
+// any, has to be extracted from the text.
+func NativeToolCall(provider, response string) (*types.ToolCall, error) {
+	adapter, found := Provider(provider)
+	if !found {
+		return nil, nil
+	}
+	return adapter.ParseToolCalls(response)
+}
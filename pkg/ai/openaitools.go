@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/logger"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OpenAIChatPath is joined onto OpenAI API URLs that do not already name the
+// Chat Completions endpoint.
+const OpenAIChatPath = "chat/completions"
+
+// OpenAIToolsRequest describes a Chat Completions call that offers the model
+// tools through the function-calling API.
+type OpenAIToolsRequest struct {
+	URL         string
+	APIKey      string
+	Model       string
+	MaxTokens   int
+	Temperature float32
+	Tools       []tools.ToolSchema // Sent as functions; none sends a plain chat request
+	History     []types.Message    // Sent as the earlier messages of the conversation
+}
+
+// CallOpenAIToolsFunc allows mocking of CallOpenAITools in tests
+var CallOpenAIToolsFunc = CallOpenAITools
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model             string              `json:"model"`
+	Messages          []openAIChatMessage `json:"messages"`
+	MaxTokens         int                 `json:"max_tokens,omitempty"`
+	Temperature       *float32            `json:"temperature,omitempty"`
+	Tools             []tools.OpenAITool  `json:"tools,omitempty"`
+	ParallelToolCalls *bool               `json:"parallel_tool_calls,omitempty"`
+}
+
+// openAIChatURL returns the Chat Completions endpoint for apiURL: the URL
+// itself when it names the endpoint, the chat variant of a legacy completions
+// URL, or OpenAIChatPath joined onto a base URL.
+func openAIChatURL(apiURL string) (string, error) {
+	trimmed := strings.TrimRight(apiURL, "/")
+	switch {
+	case strings.HasSuffix(trimmed, "/"+OpenAIChatPath):
+		return trimmed, nil
+	case strings.HasSuffix(trimmed, "/completions"):
+		return strings.TrimSuffix(trimmed, "completions") + OpenAIChatPath, nil
+	}
+	return JoinURL(apiURL, OpenAIChatPath)
+}
+
+// CallOpenAITools sends prompt as a user message to the Chat Completions API
+// with req.Tools as functions the model may call, one at a time, and returns
+// the raw response. NativeToolCall reads the call from it.
+func CallOpenAITools(client *http.Client, prompt string, req OpenAIToolsRequest) (string, error) {
+	logrus.Infof("Calling OpenAI API with model %s and %d tool(s)", req.Model, len(req.Tools))
+
+	// Mock response for testing
+	if req.URL == "http://mock" {
+		return `{"choices":[{"message":{"role":"assistant","content":"mock response"},"finish_reason":"stop"}]}`, nil
+	}
+
+	fullAPIURL, err := openAIChatURL(req.URL)
+	if err != nil {
+		return "", err
+	}
+	request := openAIChatRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+	}
+	for _, m := range chatMessages(req.History, prompt) {
+		request.Messages = append(request.Messages, openAIChatMessage{Role: m.Role, Content: m.Content})
+	}
+	if req.Temperature != 0 {
+		request.Temperature = &req.Temperature
+	}
+	if len(req.Tools) > 0 {
+		parallel := false // The chain executes one tool call per iteration
+		request.Tools = tools.OpenAITools(req.Tools)
+		request.ParallelToolCalls = &parallel
+	}
+	bodyBytes, err := json.Marshal(request)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to marshal openai request body", err)
+	}
+	logger.DebugPrintf("OpenAI request body: %s", logPreview(string(bodyBytes)))
+
+	httpReq, err := http.NewRequest("POST", fullAPIURL, strings.NewReader(string(bodyBytes)))
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to create openai request", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to send openai request", err)
+	}
+	defer resp.Body.Close()
+
+	bodyString, readErr := readResponseBody(resp, "openai")
+	if readErr != nil {
+		return "", readErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&apiErr); err == nil && apiErr.Error.Message != "" {
+			return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("OpenAI API error: %s", apiErr.Error.Message), nil)
+		}
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("OpenAI API returned status %d", resp.StatusCode), nil)
+	}
+
+	logger.DebugPrintf("Raw OpenAI response: %s", logPreview(bodyString))
+	return bodyString, nil
+}
+
+// openAIToolCall returns the first tool call of a Chat Completions response,
+// or nil when there is none.
+func openAIToolCall(response string) (*types.ToolCall, error) {
+	var body struct {
+		Choices []struct {
+			Message struct {
+				ToolCalls []struct {
+					Type     string `json:"type"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(response), &body); err != nil || len(body.Choices) == 0 {
+		return nil, nil
+	}
+	calls := body.Choices[0].Message.ToolCalls
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	if len(calls) > 1 {
+		logrus.Warnf("OpenAI returned %d tool calls; executing only the first (%s)", len(calls), calls[0].Function.Name)
+	}
+	call := calls[0].Function
+	args := map[string]interface{}{}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return nil, errors.New(errors.ErrCodeAPI, fmt.Sprintf("invalid arguments of tool call '%s'", call.Name), err)
+		}
+	}
+	return &types.ToolCall{Name: call.Name, Arguments: args}, nil
+}
@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/tools"
+)
+
+func TestCallOpenAITools(t *testing.T) {
+	var got map[string]interface{}
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"write_file","arguments":"{\"file_path\":\"a.txt\",\"content\":\"hi\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":7}}`)
+	}))
+	defer server.Close()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	resp, err := CallOpenAITools(server.Client(), "save hi", OpenAIToolsRequest{URL: server.URL + "/v1", APIKey: "sk", Model: "gpt-4o", Tools: reg.ListTools()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "/v1/chat/completions" || auth != "Bearer sk" {
+		t.Errorf("unexpected request to %s with %q", path, auth)
+	}
+	if got["model"] != "gpt-4o" || got["parallel_tool_calls"] != false {
+		t.Errorf("unexpected request %v", got)
+	}
+	messages := got["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["content"] != "save hi" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	var writeFile map[string]interface{}
+	for _, tool := range got["tools"].([]interface{}) {
+		function := tool.(map[string]interface{})["function"].(map[string]interface{})
+		if function["name"] == "write_file" {
+			writeFile = function
+		}
+	}
+	if writeFile == nil {
+		t.Fatalf("expected write_file among the tools, got %v", got["tools"])
+	}
+	params := writeFile["parameters"].(map[string]interface{})
+	if params["type"] != "object" || fmt.Sprint(params["required"]) != "[file_path content]" {
+		t.Errorf("unexpected parameters %v", params)
+	}
+	if prop := params["properties"].(map[string]interface{})["file_path"].(map[string]interface{}); prop["type"] != "string" || prop["description"] == "" {
+		t.Errorf("unexpected file_path property %v", prop)
+	}
+
+	tc, err := NativeToolCall("openai", resp)
+	if err != nil || tc == nil {
+		t.Fatalf("expected a tool call, got %v, %v", tc, err)
+	}
+	if tc.Name != "write_file" || tc.Arguments["file_path"] != "a.txt" || tc.Arguments["content"] != "hi" {
+		t.Errorf("unexpected tool call %+v", tc)
+	}
+}
+
+func TestCallOpenAITools_WithoutTools(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"choices":[{"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	resp, err := CallOpenAITools(server.Client(), "hi", OpenAIToolsRequest{URL: server.URL, Model: "gpt-4o", Temperature: 0.5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := got["tools"]; ok {
+		t.Errorf("expected no tools in the request, got %v", got)
+	}
+	if _, ok := got["parallel_tool_calls"]; ok || got["temperature"] != 0.5 {
+		t.Errorf("unexpected request %v", got)
+	}
+	if text, _, _ := ResponseText("openai", resp); text != "Hello" {
+		t.Errorf("expected the message content as text, got %q", text)
+	}
+	if tc, err := NativeToolCall("openai", resp); tc != nil || err != nil {
+		t.Errorf("expected no tool call, got %v, %v", tc, err)
+	}
+}
+
+func TestCallOpenAITools_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, `{"error":{"message":"Invalid schema for function 'x'"}}`)
+	}))
+	defer server.Close()
+
+	_, err := CallOpenAITools(server.Client(), "hi", OpenAIToolsRequest{URL: server.URL})
+	if err == nil || !strings.Contains(err.Error(), "Invalid schema for function 'x'") {
+		t.Errorf("expected the API error message, got %v", err)
+	}
+}
+
+func TestOpenAIChatURL(t *testing.T) {
+	cases := map[string]string{
+		"https://api.openai.com/v1":                           "https://api.openai.com/v1/chat/completions",
+		"https://api.openai.com/v1/":                          "https://api.openai.com/v1/chat/completions",
+		"https://api.openai.com/v1/completions":               "https://api.openai.com/v1/chat/completions",
+		"https://gw.example.com/openai/v1/chat/completions":   "https://gw.example.com/openai/v1/chat/completions",
+		"https://r.openai.azure.com/openai/deployments/gpt4o": "https://r.openai.azure.com/openai/deployments/gpt4o/chat/completions",
+	}
+	for in, want := range cases {
+		if got, err := openAIChatURL(in); err != nil || got != want {
+			t.Errorf("openAIChatURL(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestNativeToolCall(t *testing.T) {
+	if tc, err := NativeToolCall("gemini", `{"choices":[{"message":{"tool_calls":[{"function":{"name":"x"}}]}}]}`); tc != nil || err != nil {
+		t.Errorf("expected providers without native tool calling to be skipped, got %v, %v", tc, err)
+	}
+	if tc, err := NativeToolCall("openai", "not json"); tc != nil || err != nil {
+		t.Errorf("expected no tool call in text, got %v, %v", tc, err)
+	}
+	tc, err := NativeToolCall("openai", `{"choices":[{"message":{"tool_calls":[{"type":"function","function":{"name":"ListDir","arguments":""}},{"type":"function","function":{"name":"ReadFile","arguments":"{}"}}]}}]}`)
+	if err != nil || tc == nil || tc.Name != "ListDir" || len(tc.Arguments) != 0 {
+		t.Errorf("expected the first call with no arguments, got %+v, %v", tc, err)
+	}
+	if _, err := NativeToolCall("openai", `{"choices":[{"message":{"tool_calls":[{"function":{"name":"x","arguments":"{oops"}}]}}]}`); err == nil {
+		t.Error("expected an error for malformed arguments")
+	}
+}
+
+func TestWithTools(t *testing.T) {
+	if ToolsFrom(context.Background()) != nil {
+		t.Error("expected no tools by default")
+	}
+	reg := tools.NewToolRegistry()
+	if ToolsFrom(WithTools(context.Background(), reg)) != reg {
+		t.Error("expected the registry set on the context")
+	}
+}
@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// ProviderRequest is one model call: the prompt, the conversation so far, the
+// model's settings and the tools to offer it.
+type ProviderRequest struct {
+	Prompt  string          // Latest user message
+	History []types.Message // Earlier turns, oldest first
+
+	Model      string // Provider's model name, e.g. gpt-4o
+	APIURL     string
+	APIKey     string
+	Generation Generation
+
+	// Tools are described to providers that take them alongside the prompt.
+	Tools []types.ConfigurableTool
+	// NativeTools offers Schemas through the provider's function-calling API.
+	NativeTools bool
+	Schemas     []tools.ToolSchema
+
+	// StaticPrompt is the start of the prompt that is the same on every call
+	// of the role and CacheKey identifies it, when the role enables prompt
+	// caching.
+	StaticPrompt string
+	CacheKey     string
+
+	// Custom provider only: request body template and response text path.
+	RequestTemplate string
+	ResponsePath    string
+}
+
+// ProviderCall sends a request prepared by BuildRequest and returns the raw
+// response. A non-nil onText receives the generated text, as it arrives when
+// the provider streams.
+type ProviderCall func(client *http.Client, onText StreamFunc) (string, error)
+
+// ProviderAdapter is what ai-team knows about a provider's API: how to call
+// its models and how to read their responses. Adapters are registered with
+// RegisterProvider under the name roles use as model_provider.
+type ProviderAdapter interface {
+	// BuildRequest prepares the call of req.
+	BuildRequest(req ProviderRequest) ProviderCall
+	// ParseResponse extracts the generated text and finish reason from a raw
+	// response. ok is false when the response is not in the provider's format.
+	ParseResponse(raw string) (text, finishReason string, ok bool)
+	// ParseToolCalls returns the tool call of a response to a native
+	// tool-calling request, or nil when the tool call, if any, has to be
+	// extracted from the text.
+	ParseToolCalls(raw string) (*types.ToolCall, error)
+	// ParseUsage returns the prompt and completion token counts reported in a
+	// raw response. ok is false when the response has none.
+	ParseUsage(raw string) (input, output int, ok bool)
+}
+
+// bodyAdapter is implemented by adapters whose responses are JSON objects, so
+// streamed chunks can be read and continuations joined without re-encoding.
+type bodyAdapter interface {
+	// parseBody is ParseResponse for a decoded response or stream chunk.
+	parseBody(body map[string]interface{}) (text, finishReason string, ok bool)
+	// setText replaces the generated text of a decoded response. It returns
+	// false when the response has no place for it.
+	setText(body map[string]interface{}, text string) bool
+}
+
+var providers = struct {
+	sync.RWMutex
+	adapters map[string]ProviderAdapter
+}{adapters: map[string]ProviderAdapter{}}
+
+// RegisterProvider makes adapter the adapter of provider name, replacing any
+// registered before.
+func RegisterProvider(name string, adapter ProviderAdapter) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.adapters[name] = adapter
+}
+
+// Provider returns the adapter registered for name.
+func Provider(name string) (ProviderAdapter, bool) {
+	providers.RLock()
+	defer providers.RUnlock()
+	adapter, ok := providers.adapters[name]
+	return adapter, ok
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	names := make([]string, 0, len(providers.adapters))
+	for name := range providers.adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeBody decodes a raw JSON object response.
+func decodeBody(raw string) (map[string]interface{}, bool) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(strings.NewReader(raw)).Decode(&body); err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// onResponseText passes the text of a complete response to onText, for
+// providers called without streaming.
+func onResponseText(adapter ProviderAdapter, response string, err error, onText StreamFunc) {
+	if err != nil || onText == nil {
+		return
+	}
+	if text, _, _ := adapter.ParseResponse(response); text != "" {
+		onText(text)
+	}
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/types"
+)
+
+// anthropicAdapter calls the Anthropic Messages API.
+type anthropicAdapter struct{}
+
+func init() {
+	RegisterProvider("anthropic", anthropicAdapter{})
+}
+
+func (anthropicAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	claude := ClaudeRequest{
+		URL:          req.APIURL,
+		APIKey:       req.APIKey,
+		Model:        req.Model,
+		MaxTokens:    req.Generation.MaxTokens,
+		Temperature:  req.Generation.Temperature,
+		StaticPrompt: req.StaticPrompt,
+		History:      req.History,
+	}
+	return func(client *http.Client, onText StreamFunc) (string, error) {
+		if onText != nil {
+			return StreamClaudeFunc(client, req.Prompt, claude, onText)
+		}
+		return CallClaudeFunc(client, req.Prompt, claude)
+	}
+}
+
+func (a anthropicAdapter) ParseResponse(raw string) (string, string, bool) {
+	body, ok := decodeBody(raw)
+	if !ok {
+		return "", "", false
+	}
+	return a.parseBody(body)
+}
+
+func (anthropicAdapter) parseBody(body map[string]interface{}) (string, string, bool) {
+	blocks, found := body["content"].([]interface{})
+	if !found {
+		return "", "", false
+	}
+	var b strings.Builder
+	for _, c := range blocks {
+		if block, isMap := c.(map[string]interface{}); isMap && block["type"] == "text" {
+			s, _ := block["text"].(string)
+			b.WriteString(s)
+		}
+	}
+	reason, _ := body["stop_reason"].(string)
+	return b.String(), reason, true
+}
+
+func (anthropicAdapter) setText(body map[string]interface{}, text string) bool {
+	body["content"] = []interface{}{map[string]interface{}{"type": "text", "text": text}}
+	return true
+}
+
+// mergeChunk reads a Messages API stream event: message_start carries the
+// response without its content, content_block_delta the text and
+// message_delta the stop reason and output token usage.
+func (anthropicAdapter) mergeChunk(body, event map[string]interface{}) string {
+	switch event["type"] {
+	case "message_start":
+		if message, isMap := event["message"].(map[string]interface{}); isMap {
+			for k, v := range message {
+				body[k] = v
+			}
+		}
+	case "content_block_delta":
+		delta, _ := event["delta"].(map[string]interface{})
+		text, _ := delta["text"].(string)
+		return text
+	case "message_delta":
+		if delta, isMap := event["delta"].(map[string]interface{}); isMap && delta["stop_reason"] != nil {
+			body["stop_reason"] = delta["stop_reason"]
+		}
+		if usage, isMap := event["usage"].(map[string]interface{}); isMap {
+			merged, _ := body["usage"].(map[string]interface{})
+			if merged == nil {
+				merged = map[string]interface{}{}
+				body["usage"] = merged
+			}
+			for k, v := range usage {
+				merged[k] = v
+			}
+		}
+	}
+	return ""
+}
+
+func (anthropicAdapter) finishBody(body map[string]interface{}) {
+	body["content"] = []interface{}{}
+}
+
+// ParseToolCalls returns nil: Claude models are not offered native tools.
+func (anthropicAdapter) ParseToolCalls(string) (*types.ToolCall, error) {
+	return nil, nil
+}
+
+// ParseUsage counts cached prompt tokens, which Anthropic reports separately
+// from input_tokens, as input.
+func (anthropicAdapter) ParseUsage(raw string) (int, int, bool) {
+	var body struct {
+		Usage struct {
+			InputTokens              int `json:"input_tokens"`
+			OutputTokens             int `json:"output_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(strings.NewReader(raw)).Decode(&body); err != nil {
+		return 0, 0, false
+	}
+	input := body.Usage.InputTokens + body.Usage.CacheCreationInputTokens + body.Usage.CacheReadInputTokens
+	output := body.Usage.OutputTokens
+	return input, output, input > 0 || output > 0
+}
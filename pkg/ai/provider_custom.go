@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"net/http"
+
+	"ai-team/pkg/types"
+)
+
+// customAdapter calls endpoints described by a request template. CallCustom
+// already returns the generated text, so responses carry no metadata.
+type customAdapter struct{}
+
+func init() {
+	RegisterProvider("custom", customAdapter{})
+}
+
+// BuildRequest sends the conversation as text. Custom endpoints do not stream;
+// onText gets the whole response.
+func (customAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	custom := CustomRequest{
+		URL:          req.APIURL,
+		APIKey:       req.APIKey,
+		Model:        req.Model,
+		BodyTemplate: req.RequestTemplate,
+		ResponsePath: req.ResponsePath,
+		StaticPrompt: req.StaticPrompt,
+		CacheKey:     req.CacheKey,
+	}
+	return func(client *http.Client, onText StreamFunc) (string, error) {
+		response, err := CallCustomFunc(client, HistoryPrompt(req.History, req.Prompt), custom)
+		if onText != nil && err == nil {
+			onText(response)
+		}
+		return response, err
+	}
+}
+
+func (customAdapter) ParseResponse(string) (string, string, bool) {
+	return "", "", false
+}
+
+func (customAdapter) ParseToolCalls(string) (*types.ToolCall, error) {
+	return nil, nil
+}
+
+func (customAdapter) ParseUsage(string) (int, int, bool) {
+	return 0, 0, false
+}
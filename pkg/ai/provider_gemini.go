@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/types"
+)
+
+// geminiAdapter calls Gemini's generateContent API.
+type geminiAdapter struct{}
+
+func init() {
+	RegisterProvider("gemini", geminiAdapter{})
+}
+
+// BuildRequest sends conversations and native tool calls as one request, and
+// streams other calls when onText is set.
+func (a geminiAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	return func(client *http.Client, onText StreamFunc) (string, error) {
+		if req.NativeTools || len(req.History) > 0 {
+			var response string
+			var err error
+			if len(req.History) > 0 {
+				response, err = CallGeminiChatFunc(client, req.History, req.Prompt, req.Model, req.APIURL, req.APIKey, req.Schemas, req.Generation)
+			} else {
+				response, err = CallGeminiToolsFunc(client, req.Prompt, req.Model, req.APIURL, req.APIKey, req.Schemas, req.Generation)
+			}
+			onResponseText(a, response, err, onText)
+			return response, err
+		}
+		if onText != nil {
+			return StreamGeminiFunc(client, req.Prompt, req.Model, req.APIURL, req.APIKey, req.Tools, req.Generation, onText)
+		}
+		return CallGeminiFunc(client, req.Prompt, req.Model, req.APIURL, req.APIKey, req.Tools, req.Generation)
+	}
+}
+
+func (a geminiAdapter) ParseResponse(raw string) (string, string, bool) {
+	body, ok := decodeBody(raw)
+	if !ok {
+		return "", "", false
+	}
+	return a.parseBody(body)
+}
+
+func (geminiAdapter) parseBody(body map[string]interface{}) (string, string, bool) {
+	candidate, found := firstElement(body["candidates"])
+	if !found {
+		return "", "", false
+	}
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
+	var b strings.Builder
+	for _, p := range parts {
+		if part, isMap := p.(map[string]interface{}); isMap {
+			s, _ := part["text"].(string)
+			b.WriteString(s)
+		}
+	}
+	reason, _ := candidate["finishReason"].(string)
+	return b.String(), reason, true
+}
+
+func (geminiAdapter) setText(body map[string]interface{}, text string) bool {
+	candidate, found := firstElement(body["candidates"])
+	if !found {
+		return false
+	}
+	content, _ := candidate["content"].(map[string]interface{})
+	if content == nil {
+		content = map[string]interface{}{}
+		candidate["content"] = content
+	}
+	content["parts"] = []interface{}{map[string]interface{}{"text": text}}
+	return true
+}
+
+func (a geminiAdapter) mergeChunk(body, chunk map[string]interface{}) string {
+	return mergeBodyChunk(a, body, chunk)
+}
+
+// finishBody adds the candidate no chunk carried.
+func (geminiAdapter) finishBody(body map[string]interface{}) {
+	if _, found := firstElement(body["candidates"]); !found {
+		body["candidates"] = []interface{}{map[string]interface{}{}}
+	}
+}
+
+func (geminiAdapter) ParseToolCalls(raw string) (*types.ToolCall, error) {
+	return geminiToolCall(raw)
+}
+
+func (geminiAdapter) ParseUsage(raw string) (int, int, bool) {
+	var body struct {
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.NewDecoder(strings.NewReader(raw)).Decode(&body); err != nil {
+		return 0, 0, false
+	}
+	input, output := body.UsageMetadata.PromptTokenCount, body.UsageMetadata.CandidatesTokenCount
+	return input, output, input > 0 || output > 0
+}
+
+// Embed calls the embedContent method.
+func (geminiAdapter) Embed(client *http.Client, text string, req EmbeddingRequest) ([]float32, error) {
+	return CallGeminiEmbeddingFunc(client, text, embeddingModel(req.Model, "text-embedding-004"), req.APIURL, req.APIKey)
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/types"
+)
+
+// ollamaAdapter calls Ollama's generate API, or its chat API for
+// conversations.
+type ollamaAdapter struct{}
+
+func init() {
+	RegisterProvider("ollama", ollamaAdapter{})
+}
+
+// BuildRequest sends conversations as one chat request, and streams other
+// calls when onText is set.
+func (a ollamaAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	return func(client *http.Client, onText StreamFunc) (string, error) {
+		if len(req.History) > 0 {
+			response, err := CallOllamaChatFunc(client, req.History, req.Prompt, req.APIURL, req.Model, req.Generation)
+			onResponseText(a, response, err, onText)
+			return response, err
+		}
+		if onText != nil {
+			return StreamOllamaFunc(client, req.Prompt, req.APIURL, req.Model, req.Tools, req.Generation, onText)
+		}
+		return CallOllamaFunc(client, req.Prompt, req.APIURL, req.Model, req.Tools, req.Generation)
+	}
+}
+
+func (a ollamaAdapter) ParseResponse(raw string) (string, string, bool) {
+	body, ok := decodeBody(raw)
+	if !ok {
+		return "", "", false
+	}
+	return a.parseBody(body)
+}
+
+func (ollamaAdapter) parseBody(body map[string]interface{}) (string, string, bool) {
+	reason, _ := body["done_reason"].(string)
+	if message, isMap := body["message"].(map[string]interface{}); isMap {
+		s, _ := message["content"].(string)
+		return s, reason, true
+	}
+	s, found := body["response"].(string)
+	return s, reason, found
+}
+
+func (ollamaAdapter) setText(body map[string]interface{}, text string) bool {
+	if message, isMap := body["message"].(map[string]interface{}); isMap {
+		message["content"] = text
+	} else {
+		body["response"] = text
+	}
+	return true
+}
+
+func (a ollamaAdapter) mergeChunk(body, chunk map[string]interface{}) string {
+	return mergeBodyChunk(a, body, chunk)
+}
+
+// finishBody adds the message no chunk carried.
+func (a ollamaAdapter) finishBody(body map[string]interface{}) {
+	if _, _, ok := a.parseBody(body); !ok {
+		body["message"] = map[string]interface{}{"role": "assistant"}
+	}
+}
+
+// ParseToolCalls returns nil: Ollama models are not offered native tools.
+func (ollamaAdapter) ParseToolCalls(string) (*types.ToolCall, error) {
+	return nil, nil
+}
+
+func (ollamaAdapter) ParseUsage(raw string) (int, int, bool) {
+	var body struct {
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := json.NewDecoder(strings.NewReader(raw)).Decode(&body); err != nil {
+		return 0, 0, false
+	}
+	return body.PromptEvalCount, body.EvalCount, body.PromptEvalCount > 0 || body.EvalCount > 0
+}
+
+// Embed calls the /api/embeddings endpoint.
+func (ollamaAdapter) Embed(client *http.Client, text string, req EmbeddingRequest) ([]float32, error) {
+	return CallOllamaEmbeddingFunc(client, text, embeddingModel(req.Model, "nomic-embed-text"), req.APIURL)
+}
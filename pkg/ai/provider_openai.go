@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/types"
+)
+
+// openAIAdapter calls OpenAI's completions API, or the Chat Completions API
+// for models with native tools.
+type openAIAdapter struct{}
+
+func init() {
+	RegisterProvider("openai", openAIAdapter{})
+}
+
+// BuildRequest sends the conversation as messages to models with native
+// tools; other models get it as text.
+func (a openAIAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	return func(client *http.Client, onText StreamFunc) (string, error) {
+		if req.NativeTools {
+			response, err := CallOpenAIToolsFunc(client, req.Prompt, OpenAIToolsRequest{
+				URL:         req.APIURL,
+				APIKey:      req.APIKey,
+				Model:       req.Model,
+				MaxTokens:   req.Generation.MaxTokens,
+				Temperature: req.Generation.Temperature,
+				Tools:       req.Schemas,
+				History:     req.History,
+			})
+			onResponseText(a, response, err, onText)
+			return response, err
+		}
+		prompt := HistoryPrompt(req.History, req.Prompt)
+		if onText != nil {
+			return StreamOpenAIFunc(client, prompt, req.APIURL, req.APIKey, req.Generation, onText)
+		}
+		return CallOpenAIFunc(client, prompt, req.APIURL, req.APIKey, req.Generation)
+	}
+}
+
+func (a openAIAdapter) ParseResponse(raw string) (string, string, bool) {
+	body, ok := decodeBody(raw)
+	if !ok {
+		return "", "", false
+	}
+	return a.parseBody(body)
+}
+
+// parseBody also reads the delta of a stream chunk.
+func (openAIAdapter) parseBody(body map[string]interface{}) (string, string, bool) {
+	choice, found := firstElement(body["choices"])
+	if !found {
+		return "", "", false
+	}
+	reason, _ := choice["finish_reason"].(string)
+	if message, isMap := choice["message"].(map[string]interface{}); isMap {
+		s, _ := message["content"].(string)
+		return s, reason, true
+	}
+	if delta, isMap := choice["delta"].(map[string]interface{}); isMap {
+		s, _ := delta["content"].(string)
+		return s, reason, true
+	}
+	s, _ := choice["text"].(string)
+	return s, reason, true
+}
+
+func (openAIAdapter) setText(body map[string]interface{}, text string) bool {
+	choice, found := firstElement(body["choices"])
+	if !found {
+		return false
+	}
+	if message, isMap := choice["message"].(map[string]interface{}); isMap {
+		message["content"] = text
+	} else {
+		choice["text"] = text
+	}
+	return true
+}
+
+func (a openAIAdapter) mergeChunk(body, chunk map[string]interface{}) string {
+	return mergeBodyChunk(a, body, chunk)
+}
+
+// finishBody drops the delta of the last chunk, so setText stores the text as
+// a completion's, or adds the choice no chunk carried.
+func (openAIAdapter) finishBody(body map[string]interface{}) {
+	if choice, found := firstElement(body["choices"]); found {
+		delete(choice, "delta")
+	} else {
+		body["choices"] = []interface{}{map[string]interface{}{}}
+	}
+}
+
+func (openAIAdapter) ParseToolCalls(raw string) (*types.ToolCall, error) {
+	return openAIToolCall(raw)
+}
+
+func (openAIAdapter) ParseUsage(raw string) (int, int, bool) {
+	var body struct {
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(strings.NewReader(raw)).Decode(&body); err != nil {
+		return 0, 0, false
+	}
+	input, output := body.Usage.PromptTokens, body.Usage.CompletionTokens
+	return input, output, input > 0 || output > 0
+}
+
+// Embed calls the OpenAI-compatible /embeddings endpoint.
+func (openAIAdapter) Embed(client *http.Client, text string, req EmbeddingRequest) ([]float32, error) {
+	return CallOpenAIEmbeddingFunc(client, text, embeddingModel(req.Model, "text-embedding-3-small"), req.APIURL, req.APIKey)
+}
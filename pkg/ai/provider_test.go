@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+// echoAdapter answers every prompt with it, upper-cased, and reports one
+// token per word.
+type echoAdapter struct{}
+
+func (echoAdapter) BuildRequest(req ProviderRequest) ProviderCall {
+	return func(_ *http.Client, onText StreamFunc) (string, error) {
+		response := "echo:" + strings.ToUpper(req.Prompt)
+		if onText != nil {
+			onText(response)
+		}
+		return response, nil
+	}
+}
+
+func (echoAdapter) ParseResponse(raw string) (string, string, bool) {
+	text, ok := strings.CutPrefix(raw, "echo:")
+	return text, "stop", ok
+}
+
+func (echoAdapter) ParseToolCalls(raw string) (*types.ToolCall, error) {
+	if name, ok := strings.CutPrefix(raw, "echo:CALL "); ok {
+		return &types.ToolCall{Name: strings.ToLower(name), Arguments: map[string]interface{}{}}, nil
+	}
+	return nil, nil
+}
+
+func (echoAdapter) ParseUsage(raw string) (int, int, bool) {
+	n := len(strings.Fields(raw))
+	return n, n, true
+}
+
+// embeddingEchoAdapter is an echoAdapter with embeddings: the length of the
+// text and of the model name.
+type embeddingEchoAdapter struct{ echoAdapter }
+
+func (embeddingEchoAdapter) Embed(_ *http.Client, text string, req EmbeddingRequest) ([]float32, error) {
+	return []float32{float32(len(text)), float32(len(req.Model))}, nil
+}
+
+func TestRegisterProvider(t *testing.T) {
+	RegisterProvider("echo", echoAdapter{})
+	defer func() {
+		providers.Lock()
+		delete(providers.adapters, "echo")
+		providers.Unlock()
+	}()
+
+	adapter, ok := Provider("echo")
+	if !ok {
+		t.Fatal("expected the registered adapter")
+	}
+	var streamed string
+	response, err := adapter.BuildRequest(ProviderRequest{Prompt: "call list_dir"})(nil, func(s string) { streamed += s })
+	if err != nil || response != "echo:CALL LIST_DIR" || streamed != response {
+		t.Fatalf("unexpected response %q (streamed %q), error %v", response, streamed, err)
+	}
+	if text, _, ok := ResponseText("echo", response); !ok || text != "CALL LIST_DIR" {
+		t.Errorf("expected ResponseText to use the adapter, got %q", text)
+	}
+	if in, out, ok := TokenUsage("echo", response); !ok || in != 2 || out != 2 {
+		t.Errorf("expected TokenUsage to use the adapter, got %d/%d", in, out)
+	}
+	if tc, err := NativeToolCall("echo", response); err != nil || tc == nil || tc.Name != "list_dir" {
+		t.Errorf("expected NativeToolCall to use the adapter, got %+v, %v", tc, err)
+	}
+	if WithText("echo", response, "x") != response {
+		t.Error("expected WithText to keep responses that are not JSON objects")
+	}
+	if !strings.Contains(strings.Join(Providers(), ","), "anthropic,custom,echo,gemini,ollama,openai") {
+		t.Errorf("expected the built-in and registered providers, got %q", Providers())
+	}
+}
+
+func TestProviderAdapters_Parse(t *testing.T) {
+	tests := []struct {
+		provider, raw, text string
+		input, output       int
+	}{
+		{"openai", `{"choices":[{"text":"hi","finish_reason":"length"}],"usage":{"prompt_tokens":3,"completion_tokens":5}}`, "hi", 3, 5},
+		{"gemini", `{"candidates":[{"content":{"parts":[{"text":"h"},{"text":"i"}]}}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":1}}`, "hi", 4, 1},
+		{"ollama", `{"message":{"content":"hi"},"prompt_eval_count":2,"eval_count":7}`, "hi", 2, 7},
+		{"anthropic", `{"content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":1,"cache_read_input_tokens":10,"output_tokens":2}}`, "hi", 11, 2},
+	}
+	for _, tt := range tests {
+		adapter, ok := Provider(tt.provider)
+		if !ok {
+			t.Fatalf("expected a built-in %s adapter", tt.provider)
+		}
+		if text, _, ok := adapter.ParseResponse(tt.raw); !ok || text != tt.text {
+			t.Errorf("%s: expected text %q, got %q", tt.provider, tt.text, text)
+		}
+		if in, out, ok := adapter.ParseUsage(tt.raw); !ok || in != tt.input || out != tt.output {
+			t.Errorf("%s: expected usage %d/%d, got %d/%d", tt.provider, tt.input, tt.output, in, out)
+		}
+		if text, _, _ := ResponseText(tt.provider, WithText(tt.provider, tt.raw, "replaced")); text != "replaced" {
+			t.Errorf("%s: expected WithText to replace the text, got %q", tt.provider, text)
+		}
+	}
+}
+
+func TestProviderAdapters_BuildRequest(t *testing.T) {
+	origGemini, origOpenAI := CallGeminiFunc, CallOpenAIFunc
+	defer func() { CallGeminiFunc, CallOpenAIFunc = origGemini, origOpenAI }()
+	var gotGemini, gotOpenAI string
+	var gotGen Generation
+	CallGeminiFunc = func(_ *http.Client, prompt, model, _, _ string, _ []types.ConfigurableTool, gen Generation) (string, error) {
+		gotGemini, gotGen = model+":"+prompt, gen
+		return `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`, nil
+	}
+	CallOpenAIFunc = func(_ *http.Client, prompt, _, _ string, _ Generation) (string, error) {
+		gotOpenAI = prompt
+		return `{"choices":[{"text":"ok"}]}`, nil
+	}
+
+	gemini, _ := Provider("gemini")
+	req := ProviderRequest{Prompt: "task", Model: "gemini-pro", Generation: Generation{MaxTokens: 64}}
+	if _, err := gemini.BuildRequest(req)(nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotGemini != "gemini-pro:task" || gotGen.MaxTokens != 64 {
+		t.Errorf("unexpected Gemini call %q with %+v", gotGemini, gotGen)
+	}
+
+	openai, _ := Provider("openai")
+	req.History = []types.Message{{Role: "user", Content: "earlier"}}
+	if _, err := openai.BuildRequest(req)(nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(gotOpenAI, "earlier") || !strings.HasSuffix(gotOpenAI, "task") {
+		t.Errorf("expected the history flattened into the OpenAI prompt, got %q", gotOpenAI)
+	}
+}
+
+func TestRegisterProvider_Embeddings(t *testing.T) {
+	RegisterProvider("echo", embeddingEchoAdapter{})
+	defer func() {
+		providers.Lock()
+		delete(providers.adapters, "echo")
+		providers.Unlock()
+	}()
+
+	if got := strings.Join(EmbeddingProviders(), ","); got != "echo,gemini,ollama,openai" {
+		t.Errorf("expected the providers with embeddings, got %q", got)
+	}
+	vec, err := ProviderEmbedder{Provider: "echo", Request: EmbeddingRequest{Model: "tiny"}}.Embed("hello")
+	if err != nil || len(vec) != 2 || vec[0] != 5 || vec[1] != 4 {
+		t.Errorf("expected the adapter's embedding, got %v, %v", vec, err)
+	}
+	if _, err := Embed("anthropic", nil, "hello", EmbeddingRequest{}); err == nil || !strings.Contains(err.Error(), "use echo, gemini, ollama, openai") {
+		t.Errorf("expected an error naming the providers with embeddings, got %v", err)
+	}
+}
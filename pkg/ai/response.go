@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Defaults used when the response limits leave a field at zero.
+const (
+	DefaultResponseMemoryBytes = 4 << 20  // 4 MiB
+	DefaultResponseMaxBytes    = 64 << 20 // 64 MiB
+)
+
+type responseLimitsKey struct{}
+
+// responseLimitsOf returns the limits RequestOptions.Responses set for the
+// request of resp, or zero limits.
+func responseLimitsOf(resp *http.Response) types.ResponseLimits {
+	if resp.Request == nil {
+		return types.ResponseLimits{}
+	}
+	limits, _ := resp.Request.Context().Value(responseLimitsKey{}).(types.ResponseLimits)
+	return limits
+}
+
+// maxLoggedResponse is how much of a response body debug logs include.
+const maxLoggedResponse = 2048
+
+// ResponseBuffer collects a response body in memory up to MemoryLimit bytes
+// and spills everything to a temporary file beyond that.
+type ResponseBuffer struct {
+	MemoryLimit int64
+	Dir         string // Directory for the spill file (default: system temp dir)
+
+	mem  strings.Builder
+	file *os.File
+	n    int64
+}
+
+// Write appends p, moving the buffered data to disk once MemoryLimit is exceeded.
+func (b *ResponseBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.n+int64(len(p)) > b.MemoryLimit {
+		f, err := os.CreateTemp(b.Dir, "ai-team-response-*")
+		if err != nil {
+			return 0, err
+		}
+		if _, err := io.WriteString(f, b.mem.String()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return 0, err
+		}
+		b.file = f
+		b.mem = strings.Builder{}
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+	b.n += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written.
+func (b *ResponseBuffer) Len() int64 {
+	return b.n
+}
+
+// Spilled reports whether the data was moved to disk.
+func (b *ResponseBuffer) Spilled() bool {
+	return b.file != nil
+}
+
+// Path returns the spill file, or "" when the data is in memory.
+func (b *ResponseBuffer) Path() string {
+	if b.file == nil {
+		return ""
+	}
+	return b.file.Name()
+}
+
+// String returns the buffered data. Spilled data is read back into a single
+// allocation of the exact size, so the whole data is then in memory once.
+func (b *ResponseBuffer) String() (string, error) {
+	if b.file == nil {
+		return b.mem.String(), nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	var s strings.Builder
+	s.Grow(int(b.n))
+	if _, err := io.Copy(&s, b.file); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// Close closes the spill file but keeps it on disk.
+func (b *ResponseBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	return b.file.Close()
+}
+
+// Remove closes and deletes the spill file, if any.
+func (b *ResponseBuffer) Remove() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// readResponseBody reads resp.Body within the limits set for its request.
+// Bodies larger than the memory limit pass through a spill file instead of a
+// growing in-memory buffer, and are read back once complete; bodies larger
+// than the maximum are rejected and kept on disk for inspection.
+func readResponseBody(resp *http.Response, provider string) (string, error) {
+	limits := responseLimitsOf(resp)
+	memLimit := limits.MemoryBytes
+	if memLimit <= 0 {
+		memLimit = DefaultResponseMemoryBytes
+	}
+	maxBytes := maxResponseBytes(limits)
+	buf := &ResponseBuffer{MemoryLimit: memLimit, Dir: limits.SpillDir}
+	if resp.ContentLength > 0 && resp.ContentLength <= memLimit {
+		buf.mem.Grow(int(resp.ContentLength))
+	}
+	if _, err := io.Copy(buf, io.LimitReader(resp.Body, maxBytes+1)); err != nil {
+		buf.Remove()
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to read %s response body", provider), err)
+	}
+	if buf.Len() > maxBytes {
+		msg := fmt.Sprintf("%s response exceeds %d bytes", provider, maxBytes)
+		if buf.Spilled() {
+			buf.Close()
+			msg += fmt.Sprintf("; the first %d bytes were saved to %s", buf.Len(), buf.Path())
+		}
+		return "", errors.New(errors.ErrCodeAPI, msg, nil)
+	}
+	defer buf.Remove()
+	body, err := buf.String()
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to read %s response body", provider), err)
+	}
+	return body, nil
+}
+
+// maxResponseBytes returns the maximum response size of limits.
+func maxResponseBytes(limits types.ResponseLimits) int64 {
+	if limits.MaxBytes > 0 {
+		return limits.MaxBytes
+	}
+	return DefaultResponseMaxBytes
+}
+
+// logPreview shortens a response body for debug logging.
+func logPreview(body string) string {
+	if len(body) <= maxLoggedResponse {
+		return body
+	}
+	return fmt.Sprintf("%s... (%d bytes total)", body[:maxLoggedResponse], len(body))
+}
@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestResponseBuffer_SpillsToDisk(t *testing.T) {
+	buf := &ResponseBuffer{MemoryLimit: 8, Dir: t.TempDir()}
+	io.WriteString(buf, "hello")
+	if buf.Spilled() {
+		t.Fatal("expected small write to stay in memory")
+	}
+	io.WriteString(buf, " large world")
+	if !buf.Spilled() {
+		t.Fatal("expected buffer to spill past the memory limit")
+	}
+	path := buf.Path()
+	got, err := buf.String()
+	if err != nil || got != "hello large world" {
+		t.Fatalf("String() = %q, %v", got, err)
+	}
+	if err := buf.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected spill file to be removed, stat err: %v", err)
+	}
+}
+
+// limitedClient returns a client of server reading responses within limits.
+func limitedClient(server *httptest.Server, limits types.ResponseLimits) *http.Client {
+	return WithRequestOptions(server.Client(), RequestOptions{Responses: limits})
+}
+
+func TestCallOllama_LargeResponseSpills(t *testing.T) {
+	dir := t.TempDir()
+	body := `{"message":{"content":"` + strings.Repeat("x", 1000) + `"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	resp, err := CallOllama(limitedClient(server, types.ResponseLimits{MemoryBytes: 64, SpillDir: dir}), "task", server.URL, "llama", nil, Generation{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp != body {
+		t.Errorf("expected full body back, got %d bytes", len(resp))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected spill file to be cleaned up, found %d", len(entries))
+	}
+}
+
+func TestCallGemini_RejectsOversizedResponse(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("y", 500))
+	}))
+	defer server.Close()
+
+	_, err := CallGemini(limitedClient(server, types.ResponseLimits{MemoryBytes: 16, MaxBytes: 100, SpillDir: dir}), "task", "gemini-pro", server.URL, "key", nil, Generation{})
+	if err == nil || !strings.Contains(err.Error(), "exceeds 100 bytes") {
+		t.Fatalf("expected size error, got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected partial body to be kept on disk, found %d files", len(entries))
+	}
+}
+
+func TestLogPreview(t *testing.T) {
+	if got := logPreview("short"); got != "short" {
+		t.Errorf("expected short body unchanged, got %q", got)
+	}
+	long := strings.Repeat("z", maxLoggedResponse+10)
+	if got := logPreview(long); !strings.HasSuffix(got, "bytes total)") || len(got) > maxLoggedResponse+40 {
+		t.Errorf("expected truncated preview, got %d bytes", len(got))
+	}
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/pkg/logger"
+	"ai-team/pkg/types"
+)
+
+// RateLimiter spaces out the requests sent to one provider so that no more
+// than a set number start per minute. It is safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // Earliest start of the next request
+}
+
+// NewRateLimiter returns a limiter allowing perMinute requests a minute, or
+// nil when perMinute is not positive.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next request may start, or returns ctx's error when
+// ctx is done first. A nil limiter never waits.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+	return sleep(ctx, time.Until(start))
+}
+
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = map[string]*RateLimiter{}
+)
+
+// RateLimiterFor returns the limiter shared by all requests to provider,
+// allowing perMinute requests a minute, or nil when perMinute is not
+// positive. Runs of the same process share a provider's limit.
+func RateLimiterFor(provider string, perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	key := provider + "/" + strconv.Itoa(perMinute)
+	if l, ok := rateLimiters[key]; ok {
+		return l
+	}
+	l := NewRateLimiter(perMinute)
+	rateLimiters[key] = l
+	return l
+}
+
+// retryTransport resends requests that failed with a network error, 429 Too
+// Many Requests or a 5xx status, waiting with exponential backoff and jitter
+// or as long as a Retry-After header asks.
+type retryTransport struct {
+	Base    http.RoundTripper
+	Retry   types.RetryConfig
+	Limiter *RateLimiter
+}
+
+// Default backoff of retryTransport when the config leaves it unset.
+const (
+	defaultRetryInitialDelay = time.Second
+	defaultRetryMaxDelay     = 30 * time.Second
+)
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attempts := max(t.Retry.MaxAttempts, 1)
+	if attempts > 1 && req.Body != nil && req.GetBody == nil {
+		// Keep the body so that it can be sent again.
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(ctx)
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	}
+	for attempt := 1; ; attempt++ {
+		if err := t.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		resp, err := t.Base.RoundTrip(r)
+		if attempt == attempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		delay, ok := t.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+		}
+		logger.From(ctx).Warnf("Request to %s failed (%s); retrying in %s (attempt %d/%d)", req.URL.Host, reason, delay.Round(time.Millisecond), attempt+1, attempts)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delay returns how long to wait before the attempt after attempt. A
+// Retry-After header longer than max_delay is not waited for; ok is false
+// then and the response is returned as it is.
+func (t *retryTransport) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	initial, limit := t.Retry.InitialDelay, t.Retry.MaxDelay
+	if initial <= 0 {
+		initial = defaultRetryInitialDelay
+	}
+	if limit <= 0 {
+		limit = defaultRetryMaxDelay
+	}
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return after, after <= limit
+		}
+	}
+	backoff := initial << (attempt - 1)
+	if backoff <= 0 || backoff > limit {
+		backoff = limit
+	}
+	// Full jitter between half and all of the backoff keeps concurrent
+	// runs from retrying in step.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)), true
+}
+
+// retryable reports whether a request that got resp or err is worth sending
+// again.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
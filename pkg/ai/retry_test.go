@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-team/pkg/types"
+)
+
+func TestWithRequestOptions_Retry(t *testing.T) {
+	var bodies []string
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		status := statuses[len(bodies)-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":{"content":"ok"}}`))
+	}))
+	defer server.Close()
+
+	usage := &recordedCalls{}
+	client := WithRequestOptions(server.Client(), RequestOptions{
+		Usage:      usage,
+		UsageLabel: "ollama/llama",
+		Retry:      types.RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond},
+	})
+	if _, err := CallOllama(client, "task", server.URL, "llama", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 3 || bodies[2] == "" || bodies[2] != bodies[0] {
+		t.Errorf("expected the same body sent three times, got %q", bodies)
+	}
+	if len(usage.calls) != 1 {
+		t.Errorf("expected one recorded call, got %v", usage.calls)
+	}
+}
+
+func TestRetryTransport_GivesUp(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/throttled":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := WithRequestOptions(server.Client(), RequestOptions{Retry: types.RetryConfig{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Second}})
+	for path, want := range map[string]int{"/down": 2, "/bad": 1, "/throttled": 1} {
+		hits = 0
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		resp.Body.Close()
+		if hits != want {
+			t.Errorf("%s: expected %d attempts, got %d", path, want, hits)
+		}
+	}
+}
+
+func TestRetryTransport_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := WithContext(WithRequestOptions(server.Client(), RequestOptions{Retry: types.RetryConfig{MaxAttempts: 5, InitialDelay: time.Minute, MaxDelay: time.Minute}}), ctx)
+	start := time.Now()
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the retry wait to stop early, took %s", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"7":                             7 * time.Second,
+		"Fri, 02 Jan 2026 03:04:35 GMT": 30 * time.Second,
+		"Fri, 02 Jan 2026 03:00:00 GMT": 0,
+	} {
+		if got, ok := retryAfter(value, now); !ok || got != want {
+			t.Errorf("retryAfter(%q) = %s, %v; want %s", value, got, ok, want)
+		}
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Error("expected an invalid Retry-After to be ignored")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(3000) // One request every 20ms
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected 4 requests to take at least 60ms, took %s", elapsed)
+	}
+	if RateLimiterFor("openai", 60) != RateLimiterFor("openai", 60) || RateLimiterFor("openai", 0) != nil {
+		t.Error("expected one shared limiter per provider and limit")
+	}
+}
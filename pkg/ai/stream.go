@@ -0,0 +1,363 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/logger"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StreamFunc receives generated text as it arrives from a provider.
+type StreamFunc func(text string)
+
+// StreamWriter returns a StreamFunc that writes the text to w.
+func StreamWriter(w io.Writer) StreamFunc {
+	return func(text string) {
+		io.WriteString(w, text)
+	}
+}
+
+type streamKey struct{}
+
+// WithStream returns ctx asking provider calls made with it to stream their
+// output to fn.
+func WithStream(ctx context.Context, fn StreamFunc) context.Context {
+	return context.WithValue(ctx, streamKey{}, fn)
+}
+
+// StreamFrom returns the StreamFunc carried by ctx, or nil when the response
+// should be read in one piece.
+func StreamFrom(ctx context.Context) StreamFunc {
+	fn, _ := ctx.Value(streamKey{}).(StreamFunc)
+	return fn
+}
+
+// ChatCompletionStream implements AIClient.
+func (c *OpenAIClient) ChatCompletionStream(task string, onText StreamFunc) (string, error) {
+	return StreamOpenAI(c.Client, task, c.APIURL, c.APIKey, c.Generation, onText)
+}
+
+// ChatCompletionStream implements AIClient.
+func (c *GeminiClient) ChatCompletionStream(task string, onText StreamFunc) (string, error) {
+	return StreamGemini(c.Client, task, c.Model, c.APIURL, c.APIKey, c.ConfigurableTools, c.Generation, onText)
+}
+
+// ChatCompletionStream implements AIClient.
+func (c *OllamaClient) ChatCompletionStream(task string, onText StreamFunc) (string, error) {
+	return StreamOllama(c.Client, task, c.APIURL, c.Model, c.ConfigurableTools, c.Generation, onText)
+}
+
+// ChatCompletionStream implements AIClient.
+func (c *ClaudeClient) ChatCompletionStream(task string, onText StreamFunc) (string, error) {
+	return StreamClaude(c.Client, task, ClaudeRequest{URL: c.APIURL, APIKey: c.APIKey, Model: c.Model, MaxTokens: c.MaxTokens, Temperature: c.Temperature}, onText)
+}
+
+// StreamOpenAIFunc allows mocking of StreamOpenAI in tests
+var StreamOpenAIFunc = StreamOpenAI
+
+// StreamGeminiFunc allows mocking of StreamGemini in tests
+var StreamGeminiFunc = StreamGemini
+
+// StreamOllamaFunc allows mocking of StreamOllama in tests
+var StreamOllamaFunc = StreamOllama
+
+// StreamClaudeFunc allows mocking of StreamClaude in tests
+var StreamClaudeFunc = StreamClaude
+
+// The Stream functions make the same request as their Call counterparts with
+// streaming turned on. Text is passed to onText as it arrives; the returned
+// response is assembled from the stream in the provider's non-streaming
+// format, so it can be parsed like the response of a Call function.
+
+// StreamOpenAI is CallOpenAI reading the response as server-sent events.
+func StreamOpenAI(client *http.Client, task string, apiURL string, apiKey string, gen Generation, onText StreamFunc) (string, error) {
+	logrus.Info("Streaming from OpenAI API...")
+	if apiURL == "http://mock" {
+		onText("mock response")
+		return `{"choices":[{"text":"mock response"}]}`, nil
+	}
+	fields := gen.openAIBody(task)
+	fields["stream"] = true
+	fields["stream_options"] = map[string]interface{}{"include_usage": true}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to marshal openai request body", err)
+	}
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to create openai request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	return stream(client, req, "openai", readSSE, onText)
+}
+
+// StreamGemini is CallGemini using the streamGenerateContent method, which
+// the generate path is rewritten to.
+func StreamGemini(client *http.Client, task string, model string, apiURL string, apiKey string, configurableTools []types.ConfigurableTool, gen Generation, onText StreamFunc) (string, error) {
+	logrus.Infof("Streaming from Gemini API with model: %s", model)
+	if apiURL == "http://mock" {
+		onText("mock response")
+		return `{"candidates":[{"content":{"parts":[{"text":"mock response"}]}}]}`, nil
+	}
+	path := strings.Replace(geminiGeneratePath(client), ":generateContent", ":streamGenerateContent", 1)
+	fullAPIURL, err := JoinURL(apiURL, geminiPath(path, model))
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(types.GeminiRequest{
+		Contents:         []types.GeminiContent{{Parts: []types.GeminiPart{{Text: task}}}},
+		GenerationConfig: gen.geminiConfig(),
+	})
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to marshal gemini request body", err)
+	}
+	logger.DebugPrintf("Gemini request body: %s", string(body))
+	req, err := http.NewRequest("POST", fullAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to create gemini request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	query := req.URL.Query()
+	query.Set("key", apiKey)
+	query.Set("alt", "sse")
+	req.URL.RawQuery = query.Encode()
+	return stream(client, req, "gemini", readSSE, onText)
+}
+
+// StreamOllama is CallOllama reading the response as newline-delimited JSON.
+func StreamOllama(client *http.Client, task string, apiURL string, model string, tools []types.ConfigurableTool, gen Generation, onText StreamFunc) (string, error) {
+	logrus.Info("Streaming from Ollama API...")
+	reqBody := types.OllamaRequest{Model: model, Stream: true, Options: gen.ollamaOptions()}
+	reqBody.Messages = append(reqBody.Messages, struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}{Role: "user", Content: task})
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to marshal ollama request body", err)
+	}
+	logger.DebugPrintf("Ollama request body: %s", string(body))
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to create ollama request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return stream(client, req, "ollama", readNDJSON, onText)
+}
+
+// StreamClaude is CallClaude reading the response as server-sent events.
+func StreamClaude(client *http.Client, prompt string, req ClaudeRequest, onText StreamFunc) (string, error) {
+	logrus.Infof("Streaming from Anthropic API with model: %s", req.Model)
+	if req.URL == "http://mock" {
+		onText("mock response")
+		return `{"type":"message","role":"assistant","content":[{"type":"text","text":"mock response"}],"stop_reason":"end_turn"}`, nil
+	}
+	httpReq, err := newClaudeRequest(prompt, req, true)
+	if err != nil {
+		return "", err
+	}
+	return stream(client, httpReq, "anthropic", readSSE, onText)
+}
+
+// stream sends req and passes each chunk read from the response by read to a
+// streamAssembler.
+func stream(client *http.Client, req *http.Request, provider string, read func(io.Reader, func([]byte) error) error, onText StreamFunc) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to send %s request", provider), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyString, readErr := readResponseBody(resp, provider)
+		if readErr != nil {
+			return "", readErr
+		}
+		var body map[string]interface{}
+		if json.Unmarshal([]byte(bodyString), &body) == nil {
+			if err := chunkError(provider, body); err != nil {
+				return "", err
+			}
+		}
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s API returned status %d", providerNames[provider], resp.StatusCode), nil)
+	}
+
+	adapter, _ := Provider(provider)
+	streamer, ok := adapter.(streamAdapter)
+	if !ok {
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s responses cannot be streamed", provider), nil)
+	}
+	maxBytes := maxResponseBytes(responseLimitsOf(resp))
+	limited := &io.LimitedReader{R: resp.Body, N: maxBytes + 1}
+	a := &streamAssembler{provider: provider, adapter: streamer, onText: onText, body: map[string]interface{}{}}
+	if err := read(limited, a.add); err != nil {
+		if _, isAPIErr := err.(*errors.Error); isAPIErr {
+			return "", err
+		}
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to read %s response stream", provider), err)
+	}
+	if limited.N <= 0 {
+		return "", errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s response exceeds %d bytes", provider, maxBytes), nil)
+	}
+	response := a.response()
+	logger.DebugPrintf("Assembled %s stream response: %s", provider, logPreview(response))
+	return response, nil
+}
+
+// providerNames are the provider names used in API error messages.
+var providerNames = map[string]string{
+	"openai":    "OpenAI",
+	"gemini":    "Gemini",
+	"ollama":    "Ollama",
+	"anthropic": "Anthropic",
+}
+
+// chunkError returns the error reported in a response body or stream chunk,
+// worded like the errors of the Call functions, or nil.
+func chunkError(provider string, body map[string]interface{}) error {
+	name := providerNames[provider]
+	switch e := body["error"].(type) {
+	case string:
+		if e != "" {
+			return errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s API error: %s", name, e), nil)
+		}
+	case map[string]interface{}:
+		message, _ := e["message"].(string)
+		if message == "" {
+			return nil
+		}
+		if kind, _ := e["type"].(string); provider == "anthropic" {
+			return errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s API error (%s): %s", name, kind, message), nil)
+		}
+		return errors.New(errors.ErrCodeAPI, fmt.Sprintf("%s API error: %s", name, message), nil)
+	}
+	return nil
+}
+
+// streamAdapter is implemented by adapters whose responses can be assembled
+// from a stream of JSON chunks.
+type streamAdapter interface {
+	bodyAdapter
+	// mergeChunk merges a decoded stream chunk into the response body being
+	// assembled and returns the chunk's text.
+	mergeChunk(body, chunk map[string]interface{}) string
+	// finishBody readies an assembled body for setText.
+	finishBody(body map[string]interface{})
+}
+
+// mergeBodyChunk is mergeChunk for providers whose stream chunks are partial
+// responses: the chunk's fields replace those of body.
+func mergeBodyChunk(adapter bodyAdapter, body, chunk map[string]interface{}) string {
+	text, _, _ := adapter.parseBody(chunk)
+	for k, v := range chunk {
+		if list, isList := v.([]interface{}); v == nil || isList && len(list) == 0 {
+			continue // e.g. the empty choices of OpenAI's usage chunk
+		}
+		body[k] = v
+	}
+	return text
+}
+
+// streamAssembler collects the text of stream chunks and merges their other
+// fields (finish reason, token usage) into one response body.
+type streamAssembler struct {
+	provider string
+	adapter  streamAdapter
+	onText   StreamFunc
+	text     strings.Builder
+	body     map[string]interface{}
+}
+
+func (a *streamAssembler) add(data []byte) error {
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return errors.New(errors.ErrCodeAPI, fmt.Sprintf("invalid %s stream chunk: %s", a.provider, logPreview(string(data))), err)
+	}
+	if err := chunkError(a.provider, chunk); err != nil {
+		return err
+	}
+	if text := a.adapter.mergeChunk(a.body, chunk); text != "" {
+		a.text.WriteString(text)
+		a.onText(text)
+	}
+	return nil
+}
+
+// response returns the assembled response.
+func (a *streamAssembler) response() string {
+	a.adapter.finishBody(a.body)
+	a.adapter.setText(a.body, a.text.String())
+	raw, err := json.Marshal(a.body)
+	if err != nil {
+		return a.text.String()
+	}
+	return string(raw)
+}
+
+// readSSE calls fn with the data of each server-sent event in r, until the
+// stream ends or sends the OpenAI "[DONE]" marker.
+func readSSE(r io.Reader, fn func([]byte) error) error {
+	reader := bufio.NewReader(r)
+	var data bytes.Buffer
+	dispatch := func() (bool, error) {
+		if data.Len() == 0 {
+			return false, nil
+		}
+		defer data.Reset()
+		if bytes.Equal(data.Bytes(), []byte("[DONE]")) {
+			return true, nil
+		}
+		return false, fn(data.Bytes())
+	}
+	for {
+		line, readErr := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+		} else if value, isData := strings.CutPrefix(line, "data:"); isData {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		} // Event names, IDs and comments are not needed
+		if readErr == io.EOF {
+			_, err := dispatch()
+			return err
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// readNDJSON calls fn with each non-empty line of r.
+func readNDJSON(r io.Reader, fn func([]byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// streamServer serves body with contentType and records the request.
+func streamServer(t *testing.T, contentType, body string, got *map[string]interface{}, path *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path != nil {
+			*path = r.URL.Path + "?" + r.URL.RawQuery
+		}
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func collect(chunks *[]string) StreamFunc {
+	return func(text string) { *chunks = append(*chunks, text) }
+}
+
+func TestStreamOpenAI(t *testing.T) {
+	var got map[string]interface{}
+	server := streamServer(t, "text/event-stream", `data: {"choices":[{"index":0,"text":"Hel","finish_reason":null}]}
+
+data: {"choices":[{"index":0,"text":"lo","finish_reason":"length"}]}
+
+: keep-alive
+data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}
+
+data: [DONE]
+
+`, &got, nil)
+	var chunks []string
+	resp, err := StreamOpenAI(server.Client(), "say hello", server.URL, "key", Generation{}, collect(&chunks))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(chunks, "|") != "Hel|lo" {
+		t.Errorf("unexpected chunks %q", chunks)
+	}
+	if text, reason, ok := ResponseText("openai", resp); !ok || text != "Hello" || reason != "length" {
+		t.Errorf("unexpected response %q, %q, %v from %s", text, reason, ok, resp)
+	}
+	if in, out, ok := TokenUsage("openai", resp); !ok || in != 5 || out != 2 {
+		t.Errorf("unexpected usage %d/%d/%v", in, out, ok)
+	}
+	if got["stream"] != true || got["prompt"] != "say hello" {
+		t.Errorf("unexpected request %v", got)
+	}
+}
+
+func TestStreamOpenAI_ChatDeltas(t *testing.T) {
+	server := streamServer(t, "text/event-stream", "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"}}]}\n\ndata: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n", nil, nil)
+	resp, err := StreamOpenAI(server.Client(), "x", server.URL, "key", Generation{}, func(string) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, reason, _ := ResponseText("openai", resp); text != "Hi" || reason != "stop" {
+		t.Errorf("unexpected response %q, %q from %s", text, reason, resp)
+	}
+}
+
+func TestStreamGemini(t *testing.T) {
+	var path string
+	server := streamServer(t, "text/event-stream", `data: {"candidates":[{"content":{"parts":[{"text":"a"}],"role":"model"}}]}
+
+data: {"candidates":[{"content":{"parts":[{"text":"b"}],"role":"model"},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2}}
+`, nil, &path)
+	var chunks []string
+	resp, err := StreamGemini(server.Client(), "x", "gemini-pro", server.URL, "key", nil, Generation{}, collect(&chunks))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(path, "/models/gemini-pro:streamGenerateContent?") || !strings.Contains(path, "alt=sse") {
+		t.Errorf("unexpected request path %s", path)
+	}
+	if text, _, _ := ResponseText("gemini", resp); text != "ab" || len(chunks) != 2 {
+		t.Errorf("unexpected response %q, chunks %q", text, chunks)
+	}
+	if !Truncated("gemini", resp) {
+		t.Error("expected the finish reason of the last chunk")
+	}
+	if in, out, ok := TokenUsage("gemini", resp); !ok || in != 3 || out != 2 {
+		t.Errorf("unexpected usage %d/%d/%v", in, out, ok)
+	}
+}
+
+func TestStreamOllama(t *testing.T) {
+	var got map[string]interface{}
+	server := streamServer(t, "application/x-ndjson", `{"message":{"role":"assistant","content":"foo"},"done":false}
+{"message":{"role":"assistant","content":"bar"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":4,"eval_count":2}
+`, &got, nil)
+	var chunks []string
+	resp, err := StreamOllama(server.Client(), "x", server.URL, "llama3", nil, Generation{}, collect(&chunks))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, reason, _ := ResponseText("ollama", resp); text != "foobar" || reason != "stop" {
+		t.Errorf("unexpected response %q, %q", text, reason)
+	}
+	if in, out, ok := TokenUsage("ollama", resp); !ok || in != 4 || out != 2 {
+		t.Errorf("unexpected usage %d/%d/%v", in, out, ok)
+	}
+	if got["stream"] != true || len(chunks) != 2 {
+		t.Errorf("unexpected request %v or chunks %q", got, chunks)
+	}
+}
+
+func TestStreamClaude(t *testing.T) {
+	var got map[string]interface{}
+	server := streamServer(t, "text/event-stream", `event: message_start
+data: {"type":"message_start","message":{"type":"message","role":"assistant","content":[],"usage":{"input_tokens":7,"output_tokens":1}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":3}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`, &got, nil)
+	var chunks []string
+	resp, err := StreamClaude(server.Client(), "x", ClaudeRequest{URL: server.URL, Model: "m", MaxTokens: 3}, collect(&chunks))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, reason, _ := ResponseText("anthropic", resp); text != "Hello there" || reason != "max_tokens" {
+		t.Errorf("unexpected response %q, %q from %s", text, reason, resp)
+	}
+	if in, out, ok := TokenUsage("anthropic", resp); !ok || in != 7 || out != 3 {
+		t.Errorf("unexpected usage %d/%d/%v", in, out, ok)
+	}
+	if got["stream"] != true || len(chunks) != 2 {
+		t.Errorf("unexpected request %v or chunks %q", got, chunks)
+	}
+}
+
+func TestStream_Errors(t *testing.T) {
+	server := streamServer(t, "text/event-stream", `event: content_block_delta
+data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`, nil, nil)
+	_, err := StreamClaude(server.Client(), "x", ClaudeRequest{URL: server.URL, Model: "m", MaxTokens: 1}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "Anthropic API error (overloaded_error): Overloaded") {
+		t.Errorf("expected the stream error, got %v", err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"model 'x' not found"}`)
+	}))
+	defer failing.Close()
+	_, err = StreamOllama(failing.Client(), "x", failing.URL, "x", nil, Generation{}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "Ollama API error: model 'x' not found") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	var events []string
+	err := readSSE(strings.NewReader("id: 1\ndata: a\ndata: b\n\n\n:comment\ndata:c"), func(data []byte) error {
+		events = append(events, string(data))
+		return nil
+	})
+	if err != nil || strings.Join(events, "|") != "a\nb|c" {
+		t.Errorf("unexpected events %q, %v", events, err)
+	}
+}
@@ -6,20 +6,176 @@ import (
 	"regexp"
 	"strings"
 
+	"ai-team/pkg/errors"
 	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
-// YAMLHandler extracts tool-calls from YAML blocks (future extensibility).
+// YAMLHandler extracts tool-calls from ```yaml blocks, or from a response
+// that is a YAML document starting with tool_call:.
 type YAMLHandler struct{}
 
 func (h *YAMLHandler) Name() string { return "yaml_block" }
 
+var yamlBlock = regexp.MustCompile("(?s)```ya?ml\\s*\n(.*?)```")
+
 func (h *YAMLHandler) Extract(s string) (*types.ToolCall, error) {
-	// Example: look for ```yaml ... ``` blocks (not implemented, placeholder)
-	return nil, fmt.Errorf("YAML handler not implemented")
+	doc := ""
+	if matches := yamlBlock.FindStringSubmatch(s); len(matches) == 2 {
+		doc = matches[1]
+	} else if strings.HasPrefix(strings.TrimSpace(s), "tool_call:") {
+		doc = s
+	} else {
+		return nil, fmt.Errorf("no yaml block found")
+	}
+	var body struct {
+		ToolCall  *types.ToolCall        `yaml:"tool_call"`
+		Name      string                 `yaml:"name"`
+		Arguments map[string]interface{} `yaml:"arguments"`
+	}
+	if err := yaml.Unmarshal([]byte(doc), &body); err != nil {
+		return nil, fmt.Errorf("invalid yaml block: %w", err)
+	}
+	if body.ToolCall != nil && body.ToolCall.Name != "" {
+		return body.ToolCall, nil
+	}
+	if body.Name != "" {
+		return &types.ToolCall{Name: body.Name, Arguments: body.Arguments}, nil
+	}
+	return nil, fmt.Errorf("no valid tool_call in yaml")
+}
+
+// RegexHandler extracts tool-calls with a configured regular expression. The
+// group named "name" is the tool name; the group "args", if any, is a JSON
+// object of arguments, and other named groups are string arguments.
+type RegexHandler struct {
+	Pattern *regexp.Regexp
+}
+
+func (h *RegexHandler) Name() string { return "regex:" + h.Pattern.String() }
+
+func (h *RegexHandler) Extract(s string) (*types.ToolCall, error) {
+	matches := h.Pattern.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf("pattern did not match")
+	}
+	tc := &types.ToolCall{Arguments: map[string]interface{}{}}
+	for i, group := range h.Pattern.SubexpNames() {
+		switch group {
+		case "":
+		case "name":
+			tc.Name = strings.TrimSpace(matches[i])
+		case "args":
+			if strings.TrimSpace(matches[i]) == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(matches[i]), &tc.Arguments); err != nil {
+				return nil, fmt.Errorf("invalid args group: %w", err)
+			}
+		default:
+			tc.Arguments[group] = matches[i]
+		}
+	}
+	if tc.Name == "" {
+		return nil, fmt.Errorf("pattern matched no tool name")
+	}
+	return tc, nil
+}
+
+// JSONRecursiveHandler parses a response that is JSON as a whole and searches
+// its string fields for a tool-call, e.g. the text part of a Gemini response.
+type JSONRecursiveHandler struct{}
+
+func (h *JSONRecursiveHandler) Name() string { return "json_recursive" }
+
+func (h *JSONRecursiveHandler) Extract(s string) (*types.ToolCall, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("response is not json")
+	}
+	if _, found := findToolCallInJSON(raw); found != nil {
+		return found, nil
+	}
+	return nil, fmt.Errorf("no tool_call in json fields")
+}
+
+// ProviderNativeHandler reads the tool-call of a provider's native
+// tool-calling API from the raw response.
+type ProviderNativeHandler struct {
+	Provider string
+}
+
+func (h *ProviderNativeHandler) Name() string { return "provider_native" }
+
+func (h *ProviderNativeHandler) Extract(s string) (*types.ToolCall, error) {
+	tc, err := h.ExtractRaw(s)
+	if err == nil && tc == nil {
+		return nil, fmt.Errorf("no native tool call")
+	}
+	return tc, err
+}
+
+func (h *ProviderNativeHandler) ExtractRaw(raw string) (*types.ToolCall, error) {
+	return NativeToolCall(h.Provider, raw)
+}
+
+// RawResponseHandler is a handler reading the raw provider response rather
+// than its text. ExtractRaw returns nil without error when the response holds
+// no tool-call.
+type RawResponseHandler interface {
+	ExtractRaw(raw string) (*types.ToolCall, error)
+}
+
+// DefaultExtractionStrategies is the order tool-calls are extracted in when
+// neither the role nor its provider configures one.
+var DefaultExtractionStrategies = []string{"provider_native", "json_recursive", "json_block", "inline_json", "yaml"}
+
+// ExtractionStrategy returns the handler of a strategy name: provider_native,
+// json_recursive, json_block, inline_json, yaml or regex:<pattern>.
+func ExtractionStrategy(name, provider string) (ToolCallFormatHandler, error) {
+	if pattern, ok := strings.CutPrefix(name, "regex:"); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid extraction strategy %q", name), err)
+		}
+		if re.SubexpIndex("name") < 0 {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("extraction strategy %q has no (?P<name>...) group", name), nil)
+		}
+		return &RegexHandler{Pattern: re}, nil
+	}
+	switch name {
+	case "provider_native":
+		return &ProviderNativeHandler{Provider: provider}, nil
+	case "json_recursive":
+		return &JSONRecursiveHandler{}, nil
+	case "json_block", "json_code_block":
+		return &JSONCodeBlockHandler{}, nil
+	case "inline_json":
+		return &InlineJSONHandler{}, nil
+	case "yaml", "yaml_block":
+		return &YAMLHandler{}, nil
+	}
+	return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("unknown extraction strategy %q: expected provider_native, json_recursive, json_block, inline_json, yaml or regex:<pattern>", name), nil)
+}
+
+// NewToolCallExtractor returns an extractor trying strategies in order, or
+// DefaultExtractionStrategies when none are given.
+func NewToolCallExtractor(reg *tools.ToolRegistry, provider string, strategies []string) (*ToolCallExtractor, error) {
+	if len(strategies) == 0 {
+		strategies = DefaultExtractionStrategies
+	}
+	e := &ToolCallExtractor{Registry: reg}
+	for _, name := range strategies {
+		h, err := ExtractionStrategy(name, provider)
+		if err != nil {
+			return nil, err
+		}
+		e.Handlers = append(e.Handlers, h)
+	}
+	return e, nil
 }
 
 type ToolCallExtractorInterface interface {
@@ -81,37 +237,32 @@ func parseToolCallJSON(jsonStr string) (*types.ToolCall, error) {
 	return nil, fmt.Errorf("no valid tool_call in json")
 }
 
-// ExtractToolCall runs all handlers and returns the first valid tool-call.
+// ExtractToolCall runs all handlers on s and returns the first valid
+// tool-call with the name of the handler that found it.
 func (e *ToolCallExtractor) ExtractToolCall(s string) (*types.ToolCall, string, error) {
-	log := logrus.WithField("component", "ToolCallExtractor")
-
-	// 1. Try to parse the whole response as JSON (object or array)
-	var raw interface{}
-	if err := json.Unmarshal([]byte(s), &raw); err == nil {
-		// Recursively search for tool-call JSON in all string fields
-		if _, found := findToolCallInJSON(raw); found != nil {
-			log.Infof("Found tool-call in parsed JSON structure: tool=%s", found.Name)
-			// Normalize payload before validation to canonical names (snake_case lower)
-			norm := normalizeToolCall(found)
-			log.Debugf("Original tool-call: name=%s args=%+v", found.Name, found.Arguments)
-			log.Debugf("Normalized tool-call: name=%s args=%+v", norm.Name, norm.Arguments)
-			if e.Registry != nil {
-				if err := e.Registry.ValidateToolCall(tools.ToolCall{Name: norm.Name, Arguments: norm.Arguments}); err != nil {
-					log.Warnf("Schema validation failed for tool-call: %s: %v", norm.Name, err)
-				} else {
-					// return original shape for downstream but indicate we used normalized name for validation
-					return found, "json_recursive", nil
-				}
-			} else {
-				return found, "json_recursive", nil
-			}
-		}
-	}
+	return e.ExtractResponse(s, s)
+}
 
-	// 2. Try all format handlers (legacy)
+// ExtractResponse is ExtractToolCall for a provider response: handlers that
+// read the raw response get raw, the others its text.
+func (e *ToolCallExtractor) ExtractResponse(raw, text string) (*types.ToolCall, string, error) {
+	log := logrus.WithField("component", "ToolCallExtractor")
 	for _, h := range e.Handlers {
 		log.Debugf("Trying handler: %s", h.Name())
-		tc, err := h.Extract(s)
+		var tc *types.ToolCall
+		var err error
+		if rh, ok := h.(RawResponseHandler); ok {
+			// Native calls are shaped by the schemas sent to the provider, so
+			// they are returned unvalidated, as the provider made them.
+			if tc, err = rh.ExtractRaw(raw); err != nil {
+				log.Warnf("Ignoring tool call of handler '%s': %v", h.Name(), err)
+			} else if tc != nil {
+				log.Infof("Handler '%s' succeeded: tool=%s", h.Name(), tc.Name)
+				return tc, h.Name(), nil
+			}
+			continue
+		}
+		tc, err = h.Extract(text)
 		if err == nil && tc != nil {
 			log.Infof("Handler '%s' succeeded: tool=%s", h.Name(), tc.Name)
 			// Normalize before validation
@@ -163,9 +314,10 @@ func findToolCallInJSON(v interface{}) (*types.ToolCall, *types.ToolCall) {
 func NewDefaultToolCallExtractor(reg *tools.ToolRegistry) ToolCallExtractorInterface {
 	return &ToolCallExtractor{
 		Handlers: []ToolCallFormatHandler{
+			&JSONRecursiveHandler{},
 			&JSONCodeBlockHandler{},
 			&InlineJSONHandler{},
-			&YAMLHandler{}, // Pluggable for future
+			&YAMLHandler{},
 		},
 		Registry: reg,
 	}
@@ -0,0 +1,230 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"ai-team/pkg/types"
+)
+
+// DefaultCostHeader is the response header LiteLLM uses to report a call's cost.
+const DefaultCostHeader = "x-litellm-response-cost"
+
+// RequestOptions are extra headers and query parameters added to every
+// request a client sends, e.g. for enterprise API gateways, plus optional
+// cost attribution and usage tracking.
+type RequestOptions struct {
+	Headers map[string]string
+	Query   map[string]string
+
+	Attribution types.AttributionConfig
+	RunID       string // Value of the "run_id" attribute
+
+	Usage      CallRecorder // Records each call and any gateway-reported cost
+	UsageLabel string       // e.g. "gemini/flash"
+
+	// PromptCacheKey, when set, is sent as the prompt_cache_key body field so
+	// OpenAI routes requests sharing a static prompt prefix to the same cache.
+	PromptCacheKey string
+
+	Retry   types.RetryConfig // Resending of throttled and failed requests
+	Limiter *RateLimiter      // Optional: spaces out the requests to the provider
+
+	Responses types.ResponseLimits // Buffering of the response bodies
+
+	// Gemini endpoint paths used instead of GeminiGeneratePath and
+	// GeminiModelsPath for the calls made with the client, when set.
+	GeminiGeneratePath string
+	GeminiModelsPath   string
+}
+
+// CallRecorder records provider calls and the cost a gateway reported for
+// them. ctx is the context of the request.
+type CallRecorder interface {
+	Record(ctx context.Context, label string, cost float64, reported bool)
+}
+
+// Empty reports whether the options change nothing.
+func (o RequestOptions) Empty() bool {
+	return len(o.Headers) == 0 && len(o.Query) == 0 && len(o.Attribution.Headers) == 0 &&
+		len(o.Attribution.BodyFields) == 0 && o.Usage == nil && o.PromptCacheKey == "" &&
+		o.Retry.MaxAttempts <= 1 && o.Limiter == nil && o.Responses == (types.ResponseLimits{}) &&
+		o.GeminiGeneratePath == "" && o.GeminiModelsPath == ""
+}
+
+// bodyFields returns the JSON body fields to set, mapped to attribute names
+// of attrs, including the prompt cache key.
+func (o RequestOptions) bodyFields(attrs map[string]string) map[string]string {
+	if o.PromptCacheKey == "" {
+		return o.Attribution.BodyFields
+	}
+	fields := map[string]string{"prompt_cache_key": "prompt_cache_key"}
+	for field, attr := range o.Attribution.BodyFields {
+		fields[field] = attr
+	}
+	attrs["prompt_cache_key"] = o.PromptCacheKey
+	return fields
+}
+
+// attributes returns the attribution values by attribute name.
+func (o RequestOptions) attributes() map[string]string {
+	return map[string]string{
+		"user":   os.ExpandEnv(o.Attribution.User),
+		"team":   os.ExpandEnv(o.Attribution.Team),
+		"run_id": o.RunID,
+	}
+}
+
+// requestOptionsTransport applies RequestOptions before delegating to Base.
+type requestOptionsTransport struct {
+	Base    http.RoundTripper
+	Options RequestOptions
+}
+
+func (t *requestOptionsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.Options.Responses != (types.ResponseLimits{}) {
+		ctx = context.WithValue(ctx, responseLimitsKey{}, t.Options.Responses)
+	}
+	r := req.Clone(ctx)
+	for k, v := range t.Options.Headers {
+		r.Header.Set(k, v)
+	}
+	if len(t.Options.Query) > 0 {
+		query := r.URL.Query()
+		for k, v := range t.Options.Query {
+			query.Set(k, v)
+		}
+		r.URL.RawQuery = query.Encode()
+	}
+	attrs := t.Options.attributes()
+	for header, attr := range t.Options.Attribution.Headers {
+		if v := attrs[attr]; v != "" {
+			r.Header.Set(header, v)
+		}
+	}
+	if fields := t.Options.bodyFields(attrs); len(fields) > 0 && r.Body != nil {
+		if err := setBodyFields(r, fields, attrs); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := t.Base.RoundTrip(r)
+	if err == nil && t.Options.Usage != nil {
+		header := t.Options.Attribution.CostHeader
+		if header == "" {
+			header = DefaultCostHeader
+		}
+		cost, parseErr := strconv.ParseFloat(strings.TrimSpace(resp.Header.Get(header)), 64)
+		t.Options.Usage.Record(r.Context(), t.Options.UsageLabel, cost, parseErr == nil)
+	}
+	return resp, err
+}
+
+// setBodyFields adds attribution values to a JSON object request body. Bodies
+// that are not JSON objects are sent unchanged.
+func setBodyFields(r *http.Request, fields map[string]string, attrs map[string]string) error {
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	var body map[string]interface{}
+	if json.Unmarshal(data, &body) == nil && body != nil {
+		for field, attr := range fields {
+			if v := attrs[attr]; v != "" {
+				setDotted(body, field, v)
+			}
+		}
+		if encoded, err := json.Marshal(body); err == nil {
+			data = encoded
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	return nil
+}
+
+// setDotted sets body["a"]["b"] = value for field "a.b", creating objects as needed.
+func setDotted(body map[string]interface{}, field, value string) {
+	parts := strings.Split(field, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := body[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			body[p] = next
+		}
+		body = next
+	}
+	body[parts[len(parts)-1]] = value
+}
+
+// WithRequestOptions returns a copy of client that adds opts to every request.
+// Headers set by opts replace headers of the same name set by the caller.
+// Retries and rate limiting happen below the options, so a call is recorded
+// once however often it is sent.
+func WithRequestOptions(client *http.Client, opts RequestOptions) *http.Client {
+	if opts.Empty() {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.Retry.MaxAttempts > 1 || opts.Limiter != nil {
+		base = &retryTransport{Base: base, Retry: opts.Retry, Limiter: opts.Limiter}
+	}
+	wrapped := *client
+	wrapped.Transport = &requestOptionsTransport{Base: base, Options: opts}
+	return &wrapped
+}
+
+// requestOptionsOf returns the options client was given by WithRequestOptions.
+func requestOptionsOf(client *http.Client) RequestOptions {
+	if client == nil {
+		return RequestOptions{}
+	}
+	rt := client.Transport
+	for {
+		switch t := rt.(type) {
+		case *requestOptionsTransport:
+			return t.Options
+		case *contextTransport:
+			rt = t.Base
+		default:
+			return RequestOptions{}
+		}
+	}
+}
+
+// contextTransport sends every request with Context, so cancelling it aborts
+// requests made through clients whose callers do not take a context.
+type contextTransport struct {
+	Base    http.RoundTripper
+	Context context.Context
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.Base.RoundTrip(req.WithContext(t.Context))
+}
+
+// WithContext returns a copy of client whose requests are cancelled when ctx
+// is done. A context that is never done leaves client unchanged.
+func WithContext(client *http.Client, ctx context.Context) *http.Client {
+	if ctx == nil || ctx.Done() == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &contextTransport{Base: base, Context: ctx}
+	return &wrapped
+}
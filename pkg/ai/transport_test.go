@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestWithRequestOptions_AddsHeadersAndQuery(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"choices":[{"text":"ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := WithRequestOptions(server.Client(), RequestOptions{
+		Headers: map[string]string{"OpenAI-Organization": "org-1", "Authorization": "Bearer gateway"},
+		Query:   map[string]string{"api-version": "2024-06-01"},
+	})
+	if _, err := CallOpenAI(client, "task", server.URL+"/deployments/x?existing=1", "key", Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Header.Get("OpenAI-Organization") != "org-1" {
+		t.Errorf("expected organization header, got %v", got.Header)
+	}
+	if got.Header.Get("Authorization") != "Bearer gateway" {
+		t.Errorf("expected configured header to override, got %q", got.Header.Get("Authorization"))
+	}
+	if got.URL.Query().Get("api-version") != "2024-06-01" || got.URL.Query().Get("existing") != "1" {
+		t.Errorf("unexpected query %q", got.URL.RawQuery)
+	}
+}
+
+func TestWithRequestOptions_EmptyKeepsClient(t *testing.T) {
+	client := &http.Client{}
+	if WithRequestOptions(client, RequestOptions{}) != client {
+		t.Error("expected the same client when no options are set")
+	}
+}
+
+func TestWithRequestOptions_AttributionAndCost(t *testing.T) {
+	var gotHeader string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("x-litellm-team")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("x-litellm-response-cost", "0.0125")
+		w.Write([]byte(`{"message":{"content":"ok"}}`))
+	}))
+	defer server.Close()
+
+	usage := &recordedCalls{}
+	client := WithRequestOptions(server.Client(), RequestOptions{
+		Attribution: types.AttributionConfig{
+			User:       "alice",
+			Team:       "platform",
+			Headers:    map[string]string{"x-litellm-team": "team"},
+			BodyFields: map[string]string{"user": "user", "metadata.run_id": "run_id"},
+		},
+		RunID:      "run-42",
+		Usage:      usage,
+		UsageLabel: "ollama/llama",
+	})
+	if _, err := CallOllama(client, "task", server.URL, "llama", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotHeader != "platform" {
+		t.Errorf("expected team header, got %q", gotHeader)
+	}
+	if gotBody["user"] != "alice" || gotBody["model"] != "llama" {
+		t.Errorf("expected user body field alongside the request, got %v", gotBody)
+	}
+	if metadata, _ := gotBody["metadata"].(map[string]interface{}); metadata["run_id"] != "run-42" {
+		t.Errorf("expected nested run_id, got %v", gotBody["metadata"])
+	}
+	if len(usage.calls) != 1 || usage.calls[0] != "ollama/llama 0.0125" {
+		t.Errorf("unexpected recorded calls %q", usage.calls)
+	}
+}
+
+// recordedCalls is a CallRecorder listing calls as "label cost", with "?"
+// for unreported costs.
+type recordedCalls struct{ calls []string }
+
+func (r *recordedCalls) Record(_ context.Context, label string, cost float64, reported bool) {
+	if !reported {
+		r.calls = append(r.calls, label+" ?")
+		return
+	}
+	r.calls = append(r.calls, fmt.Sprintf("%s %v", label, cost))
+}
+
+func TestWithRequestOptions_PromptCacheKey(t *testing.T) {
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"message":{"content":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client := WithRequestOptions(server.Client(), RequestOptions{PromptCacheKey: "ai-team-0123"})
+	if _, err := CallOllama(client, "task", server.URL, "llama", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotBody["prompt_cache_key"] != "ai-team-0123" || gotBody["model"] != "llama" {
+		t.Errorf("expected prompt_cache_key alongside the request, got %v", gotBody)
+	}
+}
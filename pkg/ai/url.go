@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ai-team/pkg/errors"
+)
+
+// Gemini endpoint paths, relative to the configured API URL. "{model}" is
+// replaced with the model name. Config overrides them per client
+// (gemini.generate_path and gemini.models_path) for gateways that use
+// different routes.
+var (
+	GeminiGeneratePath = "models/{model}:generateContent"
+	GeminiModelsPath   = "v1/models"
+)
+
+// ParseAPIURL checks that raw is an absolute http(s) URL with a host.
+func ParseAPIURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid API URL '%s'", raw), err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("API URL '%s' must start with http:// or https://", raw), nil)
+	}
+	if u.Host == "" {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("API URL '%s' has no host", raw), nil)
+	}
+	return u, nil
+}
+
+// NormalizeAPIURL trims surrounding whitespace and trailing slashes.
+func NormalizeAPIURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if u, err := url.Parse(raw); err == nil && u.RawQuery == "" && u.Fragment == "" {
+		return strings.TrimRight(raw, "/")
+	}
+	return raw
+}
+
+// JoinURL appends path elements to base, keeping any path prefix and query
+// string base already has (e.g. from a proxy or API gateway).
+func JoinURL(base string, elem ...string) (string, error) {
+	u, err := ParseAPIURL(base)
+	if err != nil {
+		return "", err
+	}
+	return u.JoinPath(elem...).String(), nil
+}
+
+// geminiGeneratePath returns the generate path of the calls made with client.
+func geminiGeneratePath(client *http.Client) string {
+	if p := requestOptionsOf(client).GeminiGeneratePath; p != "" {
+		return p
+	}
+	return GeminiGeneratePath
+}
+
+// geminiModelsPath returns the models path of the calls made with client.
+func geminiModelsPath(client *http.Client) string {
+	if p := requestOptionsOf(client).GeminiModelsPath; p != "" {
+		return p
+	}
+	return GeminiModelsPath
+}
+
+// geminiPath fills the model into a Gemini path template.
+func geminiPath(template, model string) string {
+	return strings.ReplaceAll(template, "{model}", model)
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJoinURL(t *testing.T) {
+	cases := []struct{ base, elem, want string }{
+		{"https://api.example.com/v1beta", "models/gemini-pro:generateContent", "https://api.example.com/v1beta/models/gemini-pro:generateContent"},
+		{"https://api.example.com/v1beta/", "models/x:generateContent", "https://api.example.com/v1beta/models/x:generateContent"},
+		{"https://gw.example.com/proxy/openai?api-version=2", "embeddings", "https://gw.example.com/proxy/openai/embeddings?api-version=2"},
+	}
+	for _, c := range cases {
+		got, err := JoinURL(c.base, c.elem)
+		if err != nil || got != c.want {
+			t.Errorf("JoinURL(%q, %q) = %q, %v; want %q", c.base, c.elem, got, err, c.want)
+		}
+	}
+	if _, err := JoinURL("api.example.com/v1", "models"); err == nil {
+		t.Error("expected error for URL without scheme")
+	}
+}
+
+func TestNormalizeAPIURL(t *testing.T) {
+	if got := NormalizeAPIURL(" https://api.example.com/v1// "); got != "https://api.example.com/v1" {
+		t.Errorf("unexpected normalized URL %q", got)
+	}
+	if got := NormalizeAPIURL("https://gw.example.com/?k=v"); got != "https://gw.example.com/?k=v" {
+		t.Errorf("expected URL with query to be kept, got %q", got)
+	}
+}
+
+func TestCallGemini_GatewayPrefixAndQuery(t *testing.T) {
+	var gotPath, gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		w.Write([]byte(`{"candidates":[]}`))
+	}))
+	defer server.Close()
+
+	if _, err := CallGemini(server.Client(), "task", "gemini-pro", server.URL+"/gateway/v1beta/?tenant=a", "secret", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/gateway/v1beta/models/gemini-pro:generateContent" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotQuery != "key=secret&tenant=a" {
+		t.Errorf("expected gateway query to be kept alongside key, got %q", gotQuery)
+	}
+}
+
+func TestCallGemini_GeneratePathPerClient(t *testing.T) {
+	paths := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Write([]byte(`{"candidates":[]}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	custom := WithContext(WithRequestOptions(server.Client(), RequestOptions{GeminiGeneratePath: "v2/{model}/generate"}), ctx)
+	if _, err := CallGemini(custom, "task", "gemini-pro", server.URL, "secret", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := CallGemini(server.Client(), "task", "gemini-pro", server.URL, "secret", nil, Generation{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := <-paths; got != "/v2/gemini-pro/generate" {
+		t.Errorf("expected the client's generate path, got %q", got)
+	}
+	if got := <-paths; got != "/models/gemini-pro:generateContent" {
+		t.Errorf("expected the default generate path for other clients, got %q", got)
+	}
+	if GeminiGeneratePath != "models/{model}:generateContent" {
+		t.Errorf("expected the default generate path to stay unchanged, got %q", GeminiGeneratePath)
+	}
+}
@@ -0,0 +1,16 @@
+package ai
+
+// TokenUsage returns the prompt and completion token counts a provider
+// reported in its raw response. ok is false when the response has none.
+func TokenUsage(provider, raw string) (input, output int, ok bool) {
+	adapter, found := Provider(provider)
+	if !found {
+		return 0, 0, false
+	}
+	return adapter.ParseUsage(raw)
+}
+
+// EstimateTokens approximates the token count of s at about 4 characters per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
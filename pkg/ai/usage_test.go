@@ -0,0 +1,24 @@
+package ai
+
+import "testing"
+
+func TestTokenUsage(t *testing.T) {
+	tests := []struct {
+		provider, raw string
+		in, out       int
+		ok            bool
+	}{
+		{"openai", `{"usage":{"prompt_tokens":12,"completion_tokens":5}}`, 12, 5, true},
+		{"gemini", `{"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":3}}`, 7, 3, true},
+		{"ollama", `{"prompt_eval_count":4,"eval_count":9}`, 4, 9, true},
+		{"anthropic", `{"usage":{"input_tokens":3,"cache_creation_input_tokens":100,"cache_read_input_tokens":50,"output_tokens":8}}`, 153, 8, true},
+		{"openai", `{"choices":[]}`, 0, 0, false},
+		{"custom", `not json`, 0, 0, false},
+	}
+	for _, tt := range tests {
+		in, out, ok := TokenUsage(tt.provider, tt.raw)
+		if in != tt.in || out != tt.out || ok != tt.ok {
+			t.Errorf("TokenUsage(%s, %s) = %d, %d, %v", tt.provider, tt.raw, in, out, ok)
+		}
+	}
+}
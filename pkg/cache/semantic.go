@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+)
+
+// Embedder turns text into an embedding vector.
+type Embedder interface {
+	Embed(text string) ([]float32, error)
+}
+
+// SemanticEntry is a single cached prompt/response pair with its embedding.
+type SemanticEntry struct {
+	Scope     string    `json:"scope"` // e.g. "gemini/gemini-2.5-flash"; entries only match within a scope
+	Prompt    string    `json:"prompt"`
+	Response  string    `json:"response"`
+	Embedding []float32 `json:"embedding"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SemanticCache returns cached responses for prompts that are semantically
+// close to a previously answered prompt.
+type SemanticCache struct {
+	Embedder  Embedder
+	Threshold float64 // minimum cosine similarity for a hit (0..1)
+	Path      string  // optional JSON file used to persist entries
+
+	mu      sync.Mutex
+	entries []SemanticEntry
+}
+
+// NewSemanticCache creates a cache and loads any entries persisted at path.
+func NewSemanticCache(embedder Embedder, threshold float64, path string) (*SemanticCache, error) {
+	c := &SemanticCache{Embedder: embedder, Threshold: threshold, Path: path}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to read semantic cache %s", path), err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to parse semantic cache %s", path), err)
+	}
+	return c, nil
+}
+
+// Lookup embeds the prompt and returns the best cached entry within scope whose
+// similarity is at or above the threshold. The embedding is returned so callers
+// can pass it to Store without embedding the prompt twice.
+func (c *SemanticCache) Lookup(scope, prompt string) (*SemanticEntry, float64, []float32, error) {
+	vec, err := c.Embedder.Embed(prompt)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var best *SemanticEntry
+	bestScore := -1.0
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.Scope != scope {
+			continue
+		}
+		score := CosineSimilarity(vec, e.Embedding)
+		if score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil || bestScore < c.Threshold {
+		return nil, bestScore, vec, nil
+	}
+	hit := *best
+	return &hit, bestScore, vec, nil
+}
+
+// Store records a prompt/response pair and persists the cache if a path is set.
+// If embedding is nil the prompt is embedded first.
+func (c *SemanticCache) Store(scope, prompt, response string, embedding []float32) error {
+	if embedding == nil {
+		vec, err := c.Embedder.Embed(prompt)
+		if err != nil {
+			return err
+		}
+		embedding = vec
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, SemanticEntry{
+		Scope:     scope,
+		Prompt:    prompt,
+		Response:  response,
+		Embedding: embedding,
+		CreatedAt: time.Now(),
+	})
+	return c.saveLocked()
+}
+
+// Len returns the number of cached entries.
+func (c *SemanticCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *SemanticCache) saveLocked() error {
+	if c.Path == "" {
+		return nil
+	}
+	if dir := filepath.Dir(c.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to create cache directory %s", dir), err)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to marshal semantic cache", err)
+	}
+	if err := os.WriteFile(c.Path, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write semantic cache %s", c.Path), err)
+	}
+	return nil
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, or 0 when
+// they differ in length or either is a zero vector.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
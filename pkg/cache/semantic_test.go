@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wordEmbedder produces a bag-of-words vector over a fixed vocabulary.
+type wordEmbedder struct {
+	vocab []string
+	calls int
+}
+
+func (w *wordEmbedder) Embed(text string) ([]float32, error) {
+	w.calls++
+	vec := make([]float32, len(w.vocab))
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		for i, v := range w.vocab {
+			if v == word {
+				vec[i]++
+			}
+		}
+	}
+	return vec, nil
+}
+
+func TestSemanticCache_HitAndMiss(t *testing.T) {
+	emb := &wordEmbedder{vocab: []string{"analyze", "the", "main", "go", "file", "write", "tests"}}
+	c, err := NewSemanticCache(emb, 0.9, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Store("gemini/flash", "analyze the main go file", "analysis", nil); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	entry, score, _, err := c.Lookup("gemini/flash", "analyze the main go file please")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if entry == nil || entry.Response != "analysis" {
+		t.Fatalf("expected cache hit, got %v (score %.3f)", entry, score)
+	}
+
+	entry, _, _, _ = c.Lookup("gemini/flash", "write tests")
+	if entry != nil {
+		t.Fatalf("expected miss for dissimilar prompt, got %v", entry)
+	}
+
+	entry, _, _, _ = c.Lookup("openai/gpt-4", "analyze the main go file")
+	if entry != nil {
+		t.Fatalf("expected miss for different scope, got %v", entry)
+	}
+}
+
+func TestSemanticCache_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "semantic.json")
+	emb := &wordEmbedder{vocab: []string{"hello", "world"}}
+	c, err := NewSemanticCache(emb, 0.99, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Store("s", "hello world", "hi", nil); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	reloaded, err := NewSemanticCache(emb, 0.99, path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloaded.Len() != 1 {
+		t.Fatalf("expected 1 persisted entry, got %d", reloaded.Len())
+	}
+	entry, _, _, _ := reloaded.Lookup("s", "hello world")
+	if entry == nil || entry.Response != "hi" {
+		t.Fatalf("expected persisted hit, got %v", entry)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if s := CosineSimilarity([]float32{1, 0}, []float32{1, 0}); s < 0.999 {
+		t.Errorf("expected ~1 for identical vectors, got %f", s)
+	}
+	if s := CosineSimilarity([]float32{1, 0}, []float32{0, 1}); s != 0 {
+		t.Errorf("expected 0 for orthogonal vectors, got %f", s)
+	}
+	if s := CosineSimilarity([]float32{1}, []float32{1, 2}); s != 0 {
+		t.Errorf("expected 0 for mismatched lengths, got %f", s)
+	}
+}
@@ -0,0 +1,471 @@
+// Package chaintest runs chain tests: YAML files that script the model output
+// of each chain step and state the tool calls, context values and iterations
+// the run must produce. Tests run in a temporary workspace and never call a
+// live model, so chain changes can be checked like code (ai-team test).
+package chaintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/roles"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MockModel is the model every role of a tested chain is switched to.
+const MockModel = "chaintest"
+
+// Test is a chain test file, e.g. chains/fix-loop.test.yaml.
+type Test struct {
+	Name  string                 `yaml:"name"`  // Defaults to the file name
+	Chain string                 `yaml:"chain"` // Chain of the config under test
+	Input map[string]interface{} `yaml:"input"`
+	// Files are created in the workspace before the run, by relative path.
+	Files map[string]string `yaml:"files"`
+	// Responses are the model outputs of each step by step name (or role when
+	// the step has no name), one per iteration.
+	Responses map[string][]string `yaml:"responses"`
+	// Simulate scripts tool results as the simulation section does.
+	Simulate map[string][]types.ToolFixture `yaml:"simulate"`
+	Expect   Expect                         `yaml:"expect"`
+
+	Path string `yaml:"-"` // File the test was loaded from
+}
+
+// Expect is what a chain test checks after the run.
+type Expect struct {
+	// ToolCalls must occur in this order; other calls may come between them.
+	ToolCalls []ExpectedCall `yaml:"tool_calls"`
+	// Context maps dotted keys of the final context, e.g. steps.coder.output,
+	// to regular expressions their value must match. Values that are not
+	// strings are matched as JSON.
+	Context map[string]string `yaml:"context"`
+	// Iterations is how many iterations each step must run, by step name, to
+	// check when loops exit.
+	Iterations map[string]int `yaml:"iterations"`
+	// Error, when set, is text the chain's error must contain; otherwise the
+	// chain must succeed.
+	Error string `yaml:"error"`
+}
+
+// ExpectedCall matches a tool call of the run.
+type ExpectedCall struct {
+	Step string            `yaml:"step"` // Optional: step that must make the call
+	Tool string            `yaml:"tool"`
+	Args map[string]string `yaml:"args"` // Argument name -> regular expression
+}
+
+// Result is the outcome of one test.
+type Result struct {
+	Test     *Test
+	Failures []string
+	Run      *runs.Record
+}
+
+// Passed reports whether the test met all its expectations.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// LoadFile reads a chain test.
+func LoadFile(path string) (*Test, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to read chain test %s", path), err)
+	}
+	var test Test
+	if err := yaml.Unmarshal(data, &test); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to parse chain test %s", path), err)
+	}
+	test.Path = path
+	if test.Name == "" {
+		test.Name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".yml"), ".yaml")
+	}
+	if test.Chain == "" {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain test %s names no chain", path), nil)
+	}
+	return &test, nil
+}
+
+// IsTestFile reports whether name is a chain test file name: *.test.yaml or
+// *.test.yml.
+func IsTestFile(name string) bool {
+	return strings.HasSuffix(name, ".test.yaml") || strings.HasSuffix(name, ".test.yml")
+}
+
+// Find returns the chain test files of paths, in order. Directories are
+// searched recursively for test files; files are taken as given.
+func Find(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to read chain tests in %s", p), err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && IsTestFile(d.Name()) {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to read chain tests in %s", p), err)
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// Run runs test against the chains and roles of cfg. Every role is switched
+// to a mock model answering from test.Responses, and the run happens in a
+// temporary directory that is the working directory until Run returns.
+// Run replaces process-wide provider hooks, so tests must not run
+// concurrently. An error means the test could not be run; unmet
+// expectations are Result.Failures.
+func Run(cfg *config.Config, test *Test) (*Result, error) {
+	chain, ok := cfg.Chains[test.Chain]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain test %s: chain '%s' not found in config", test.Name, test.Chain), nil)
+	}
+	steps := make(map[string]bool, len(chain.Steps))
+	for _, step := range chain.Steps {
+		steps[stepName(step)] = true
+	}
+	for _, name := range sortedKeys(test.Responses) {
+		if !steps[name] {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain test %s: responses name unknown step '%s' of chain %s", test.Name, name, test.Chain), nil)
+		}
+	}
+	for _, name := range sortedKeys(test.Expect.Iterations) {
+		if !steps[name] {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain test %s: iterations name unknown step '%s' of chain %s", test.Name, name, test.Chain), nil)
+		}
+	}
+	if _, err := tools.NewSimulator(test.Simulate); err != nil {
+		return nil, err
+	}
+
+	restore, err := enterWorkspace(test.Files)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("chain test %s: failed to prepare workspace", test.Name), err)
+	}
+	defer restore()
+
+	model := &mockModel{responses: test.Responses}
+	defer model.install()()
+
+	result := &Result{Test: test, Run: runs.NewRecord(test.Chain, test.Input)}
+	input := make(map[string]interface{}, len(test.Input))
+	for k, v := range test.Input {
+		input[k] = v
+	}
+	output, runErr := roles.ExecuteChainWithOptions(chain, input, testConfig(cfg, test), roles.ChainOptions{
+		Run: result.Run,
+		MetricsHook: func(event string, fields map[string]interface{}) {
+			if event == "step_iteration" {
+				step, _ := fields["step"].(string)
+				iteration, _ := fields["iteration"].(int)
+				model.at(step, iteration)
+			}
+		},
+	})
+
+	result.Failures = append(result.Failures, model.failures()...)
+	switch {
+	case test.Expect.Error != "" && runErr == nil:
+		result.Failures = append(result.Failures, fmt.Sprintf("expected the chain to fail with %q, but it succeeded", test.Expect.Error))
+	case test.Expect.Error != "" && !strings.Contains(runErr.Error(), test.Expect.Error):
+		result.Failures = append(result.Failures, fmt.Sprintf("expected the chain to fail with %q, got: %v", test.Expect.Error, runErr))
+	case test.Expect.Error == "" && runErr != nil:
+		result.Failures = append(result.Failures, fmt.Sprintf("chain failed: %v", runErr))
+	}
+	result.Failures = append(result.Failures, checkToolCalls(test.Expect.ToolCalls, result.Run.Steps)...)
+	result.Failures = append(result.Failures, checkIterations(test.Expect.Iterations, result.Run.Steps)...)
+	result.Failures = append(result.Failures, checkContext(test.Expect.Context, output)...)
+	return result, nil
+}
+
+// testConfig returns cfg with every role on MockModel, the tools of the test
+// simulated and the features that call models or reuse earlier output off.
+func testConfig(cfg *config.Config, test *Test) *config.Config {
+	c := *cfg
+	c.Roles = make(map[string]types.Role, len(cfg.Roles))
+	for key, role := range cfg.Roles {
+		role.Provider = "gemini"
+		role.Model = MockModel
+		c.Roles[key] = role
+	}
+	c.Gemini.Apiurl = "http://chaintest.invalid"
+	c.Gemini.Models = map[string]config.ModelConfig{MockModel: {Model: MockModel}}
+	c.Cache = config.CacheConfig{}
+	c.Guardrail = types.GuardrailConfig{}
+	c.Heartbeat = types.HeartbeatConfig{}
+	c.Simulation = types.SimulationConfig{Enabled: len(test.Simulate) > 0, Tools: test.Simulate}
+	c.RunsDir = ""
+	c.LogFilePath = ""
+	return &c
+}
+
+// enterWorkspace creates a temporary directory holding files and changes
+// into it. The returned function changes back and removes the directory.
+func enterWorkspace(files map[string]string) (func(), error) {
+	dir, err := os.MkdirTemp("", "ai-team-chaintest-")
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	restore := func() {
+		_ = os.Chdir(previous)
+		os.RemoveAll(dir)
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return restore, nil
+}
+
+// mockModel answers model calls with the scripted response of the step and
+// iteration reported by the chain's step_iteration events.
+type mockModel struct {
+	responses map[string][]string
+
+	mu        sync.Mutex
+	step      string
+	iteration int
+	missing   []string
+}
+
+func (m *mockModel) at(step string, iteration int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.step, m.iteration = step, iteration
+}
+
+func (m *mockModel) reply() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	replies := m.responses[m.step]
+	if m.iteration >= len(replies) {
+		msg := fmt.Sprintf("no response for iteration %d of step %s (%d given)", m.iteration+1, m.step, len(replies))
+		m.missing = append(m.missing, msg)
+		return "", fmt.Errorf("chaintest: %s", msg)
+	}
+	return replies[m.iteration], nil
+}
+
+func (m *mockModel) failures() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.missing...)
+}
+
+// install routes Gemini calls to m and returns the function restoring them.
+func (m *mockModel) install() func() {
+	origCall, origChat := ai.CallGeminiFunc, ai.CallGeminiChatFunc
+	ai.CallGeminiFunc = func(_ *http.Client, _, _, _, _ string, _ []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return m.reply()
+	}
+	ai.CallGeminiChatFunc = func(_ *http.Client, _ []types.Message, _, _, _, _ string, _ []tools.ToolSchema, _ ai.Generation) (string, error) {
+		return m.reply()
+	}
+	return func() {
+		ai.CallGeminiFunc, ai.CallGeminiChatFunc = origCall, origChat
+	}
+}
+
+// checkToolCalls reports the expected calls not found in order among the
+// tool calls of steps.
+func checkToolCalls(expected []ExpectedCall, steps []runs.StepRecord) []string {
+	var failures []string
+	next := 0
+	for _, want := range expected {
+		found := false
+		for ; next < len(steps); next++ {
+			if callMatches(want, steps[next]) {
+				found = true
+				next++
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected tool call %s not made (made: %s)", describeCall(want), strings.Join(madeCalls(steps), ", ")))
+			return failures
+		}
+	}
+	return failures
+}
+
+func callMatches(want ExpectedCall, step runs.StepRecord) bool {
+	if step.ToolCall == nil || step.ToolCall.Name != want.Tool {
+		return false
+	}
+	if want.Step != "" && recordStep(step) != want.Step {
+		return false
+	}
+	for name, pattern := range want.Args {
+		value, ok := step.ToolCall.Arguments[name]
+		if !ok {
+			return false
+		}
+		if matched, err := regexp.MatchString(pattern, valueString(value)); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func describeCall(call ExpectedCall) string {
+	desc := call.Tool
+	if call.Step != "" {
+		desc = call.Step + ": " + desc
+	}
+	if len(call.Args) > 0 {
+		args := make([]string, 0, len(call.Args))
+		for _, name := range sortedKeys(call.Args) {
+			args = append(args, fmt.Sprintf("%s=~%q", name, call.Args[name]))
+		}
+		desc += "(" + strings.Join(args, ", ") + ")"
+	}
+	return desc
+}
+
+func madeCalls(steps []runs.StepRecord) []string {
+	made := []string{}
+	for _, step := range steps {
+		if step.ToolCall != nil {
+			made = append(made, recordStep(step)+": "+step.ToolCall.Name)
+		}
+	}
+	if len(made) == 0 {
+		return []string{"none"}
+	}
+	return made
+}
+
+// checkIterations reports steps that ran a different number of iterations.
+func checkIterations(expected map[string]int, steps []runs.StepRecord) []string {
+	counts := make(map[string]int)
+	for _, step := range steps {
+		counts[recordStep(step)]++
+	}
+	var failures []string
+	for _, name := range sortedKeys(expected) {
+		if counts[name] != expected[name] {
+			failures = append(failures, fmt.Sprintf("expected step %s to run %d iteration(s), ran %d", name, expected[name], counts[name]))
+		}
+	}
+	return failures
+}
+
+// checkContext reports context keys missing from output or not matching.
+func checkContext(expected map[string]string, output map[string]interface{}) []string {
+	var failures []string
+	for _, key := range sortedKeys(expected) {
+		pattern := expected[key]
+		value, ok := lookup(output, key)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("expected context key %s, not set", key))
+			continue
+		}
+		matched, err := regexp.MatchString(pattern, valueString(value))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid pattern %q for context key %s: %v", pattern, key, err))
+		} else if !matched {
+			failures = append(failures, fmt.Sprintf("expected context key %s to match %q, got %q", key, pattern, valueString(value)))
+		}
+	}
+	return failures
+}
+
+// lookup returns the value of a dotted key in a nested map.
+func lookup(m map[string]interface{}, key string) (interface{}, bool) {
+	var value interface{} = m
+	for _, part := range strings.Split(key, ".") {
+		current, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = current[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func valueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// stepName is the name the chain reports a step by: its name, or its role.
+func stepName(step types.ChainRole) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return step.Role
+}
+
+func recordStep(step runs.StepRecord) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return step.Role
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,142 @@
+package chaintest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/types"
+)
+
+func testChainConfig() *config.Config {
+	cfg := &config.Config{
+		Roles: map[string]types.Role{
+			"coder":    {Provider: "openai", Model: "gpt-4", Prompt: "Fix {{.task}}"},
+			"reviewer": {Provider: "anthropic", Model: "claude", Prompt: "Review"},
+		},
+		Chains: map[string]types.RoleChain{
+			"fix": {Steps: []types.ChainRole{
+				{Role: "coder", Input: map[string]interface{}{"task": "{{.task}}"}, Loop: true, LoopCondition: "{{.tool_call.name}} == 'write_file'"},
+				{Name: "review", Role: "reviewer", OutputKey: "verdict"},
+			}},
+		},
+	}
+	return cfg
+}
+
+const passingTest = `
+chain: fix
+input:
+  task: main.go
+files:
+  main.go: "package main\n"
+responses:
+  coder:
+    - '{"tool_call": {"name": "read_file", "arguments": {"file_path": "main.go"}}}'
+    - '{"tool_call": {"name": "write_file", "arguments": {"file_path": "main.go", "content": "package main\n\nfunc main() {}\n"}}}'
+  review:
+    - LGTM
+expect:
+  tool_calls:
+    - step: coder
+      tool: read_file
+    - tool: write_file
+      args:
+        file_path: ^main\.go$
+  iterations:
+    coder: 2
+    review: 1
+  context:
+    verdict: LGTM
+    steps.coder.output: func main
+`
+
+func writeTest(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_Passing(t *testing.T) {
+	test, err := LoadFile(writeTest(t, "fix.test.yaml", passingTest))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if test.Name != "fix.test" {
+		t.Errorf("expected the name from the file, got %q", test.Name)
+	}
+	wd, _ := os.Getwd()
+	result, err := Run(testChainConfig(), test)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("expected the test to pass, got %q", result.Failures)
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("expected the working directory restored, got %s", now)
+	}
+	if _, err := os.Stat("main.go"); err == nil {
+		t.Error("expected the test's files written to a temporary workspace")
+	}
+}
+
+func TestRun_Failures(t *testing.T) {
+	content := strings.NewReplacer("coder: 2", "coder: 3", "verdict: LGTM", "verdict: ^rejected", "tool: write_file", "tool: apply_patch").Replace(passingTest)
+	content = strings.Replace(content, "    - LGTM\n", "", 1)
+	test, err := LoadFile(writeTest(t, "fix.test.yaml", content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := Run(testChainConfig(), test)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failures := strings.Join(result.Failures, "\n")
+	for _, want := range []string{
+		"no response for iteration 1 of step review",
+		"expected tool call apply_patch",
+		"expected step coder to run 3 iteration(s), ran 2",
+		"expected context key verdict",
+	} {
+		if !strings.Contains(failures, want) {
+			t.Errorf("expected a failure containing %q, got:\n%s", want, failures)
+		}
+	}
+}
+
+func TestRun_UnknownStep(t *testing.T) {
+	test := &Test{Name: "typo", Chain: "fix", Responses: map[string][]string{"coders": {"x"}}}
+	if _, err := Run(testChainConfig(), test); err == nil || !strings.Contains(err.Error(), "unknown step 'coders'") {
+		t.Errorf("expected an unknown step error, got %v", err)
+	}
+	test = &Test{Name: "missing", Chain: "deploy"}
+	if _, err := Run(testChainConfig(), test); err == nil || !strings.Contains(err.Error(), "chain 'deploy' not found") {
+		t.Errorf("expected an unknown chain error, got %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.test.yaml", "a/a.test.yml", "notes.yaml"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("chain: fix\n"), 0644)
+	}
+	files, err := Find([]string{dir, filepath.Join(dir, "notes.yaml")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a/a.test.yml"), filepath.Join(dir, "b.test.yaml"), filepath.Join(dir, "notes.yaml")}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected files %q", files)
+	}
+	if _, err := LoadFile(writeTest(t, "x.test.yaml", "input: {}\n")); err == nil {
+		t.Error("expected an error for a test without a chain")
+	}
+}
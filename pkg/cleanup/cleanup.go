@@ -0,0 +1,158 @@
+// Package cleanup tracks the temporary files and child processes of a run so
+// that nothing is left behind when the run ends: commands run in their own
+// process group, which is killed as a whole on timeout or cancellation, and
+// whatever is still tracked at the end is removed or killed by Cleanup.
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Tracker holds the resources of one run. A nil *Tracker is valid: files are
+// removed as soon as they are released and nothing is left to clean up.
+type Tracker struct {
+	Keep bool // Leave temporary files in place for debugging (--keep-temp)
+
+	mu     sync.Mutex
+	files  map[string]struct{}
+	groups map[int]struct{} // Process group IDs that may still have members
+}
+
+// New returns an empty tracker.
+func New(keep bool) *Tracker {
+	return &Tracker{Keep: keep, files: map[string]struct{}{}, groups: map[int]struct{}{}}
+}
+
+type trackerKey struct{}
+
+// With returns ctx carrying t for the tools run with it.
+func With(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+var (
+	defaultMu sync.RWMutex
+	fallback  *Tracker
+)
+
+// SetDefault selects the tracker From returns for contexts that carry none,
+// typically the tracker of the whole command.
+func SetDefault(t *Tracker) {
+	defaultMu.Lock()
+	fallback = t
+	defaultMu.Unlock()
+}
+
+// From returns the tracker carried by ctx, or the default tracker.
+func From(ctx context.Context) *Tracker {
+	if t, _ := ctx.Value(trackerKey{}).(*Tracker); t != nil {
+		return t
+	}
+	return Default()
+}
+
+// Default returns the tracker selected with SetDefault, or nil.
+func Default() *Tracker {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return fallback
+}
+
+// CreateTemp creates a temporary file as os.CreateTemp does and tracks it
+// until it is released.
+func (t *Tracker) CreateTemp(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil || t == nil {
+		return f, err
+	}
+	t.mu.Lock()
+	t.files[f.Name()] = struct{}{}
+	t.mu.Unlock()
+	return f, nil
+}
+
+// Release removes a temporary file that is no longer needed. With Keep set
+// the file stays, and is reported by Cleanup.
+func (t *Tracker) Release(path string) {
+	if t != nil && t.Keep {
+		return
+	}
+	if t != nil {
+		t.mu.Lock()
+		delete(t.files, path)
+		t.mu.Unlock()
+	}
+	os.Remove(path)
+}
+
+// CombinedOutput runs cmd like cmd.CombinedOutput, in a process group of its
+// own. When cmd was created with exec.CommandContext, cancelling the context
+// kills the whole group, so children of a shell die with it. A group whose
+// leader exits while other members are still running, such as a command put
+// in the background, is killed by Cleanup.
+func (t *Tracker) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	setProcessGroup(cmd)
+	if cmd.Cancel != nil {
+		cmd.Cancel = func() error { return killGroup(cmd.Process.Pid) }
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	pgid := cmd.Process.Pid
+	if t != nil {
+		t.mu.Lock()
+		t.groups[pgid] = struct{}{}
+		t.mu.Unlock()
+	}
+	err := cmd.Wait()
+	if t != nil && !groupAlive(pgid) {
+		t.mu.Lock()
+		delete(t.groups, pgid)
+		t.mu.Unlock()
+	}
+	return out.Bytes(), err
+}
+
+// Cleanup kills the process groups still running and removes the temporary
+// files not yet released, or lists them when Keep is set. It may be called
+// more than once.
+func (t *Tracker) Cleanup() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	groups, files := t.groups, t.files
+	t.groups, t.files = map[int]struct{}{}, map[string]struct{}{}
+	t.mu.Unlock()
+
+	for pgid := range groups {
+		if groupAlive(pgid) {
+			logrus.Warnf("Killing leftover processes of group %d", pgid)
+			killGroup(pgid)
+		}
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if t.Keep {
+			logrus.Infof("Keeping temporary file %s", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Failed to remove temporary file %s: %v", path, err)
+		}
+	}
+}
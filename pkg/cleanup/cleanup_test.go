@@ -0,0 +1,123 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker_TempFiles(t *testing.T) {
+	dir := t.TempDir()
+	tr := New(false)
+	released, err := tr.CreateTemp(dir, "a-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	released.Close()
+	leftover, _ := tr.CreateTemp(dir, "b-*")
+	leftover.Close()
+
+	tr.Release(released.Name())
+	if _, err := os.Stat(released.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed on release", released.Name())
+	}
+	if _, err := os.Stat(leftover.Name()); err != nil {
+		t.Fatalf("expected %s to exist until cleanup: %v", leftover.Name(), err)
+	}
+	tr.Cleanup()
+	if _, err := os.Stat(leftover.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed by cleanup", leftover.Name())
+	}
+}
+
+func TestTracker_Keep(t *testing.T) {
+	tr := New(true)
+	f, _ := tr.CreateTemp(t.TempDir(), "kept-*")
+	f.Close()
+	tr.Release(f.Name())
+	tr.Cleanup()
+	if _, err := os.Stat(f.Name()); err != nil {
+		t.Errorf("expected %s to be kept: %v", f.Name(), err)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tr *Tracker
+	f, err := tr.CreateTemp(t.TempDir(), "x-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	tr.Release(f.Name())
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", f.Name())
+	}
+	out, err := tr.CombinedOutput(exec.Command("echo", "hi"))
+	if err != nil || strings.TrimSpace(string(out)) != "hi" {
+		t.Errorf("unexpected output %q, %v", out, err)
+	}
+	tr.Cleanup()
+}
+
+func TestFrom(t *testing.T) {
+	if From(context.Background()) != nil {
+		t.Fatal("expected no tracker")
+	}
+	def := New(false)
+	SetDefault(def)
+	defer SetDefault(nil)
+	if From(context.Background()) != def {
+		t.Error("expected the default tracker")
+	}
+	own := New(false)
+	if From(With(context.Background(), own)) != own {
+		t.Error("expected the tracker of the context")
+	}
+}
+
+// waitGone waits for the process group to disappear.
+func waitGone(t *testing.T, pgid int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if !groupAlive(pgid) {
+			return
+		}
+	}
+	t.Errorf("process group %d is still running", pgid)
+}
+
+func TestTracker_CancelKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", "-c", "sleep 30 & sleep 30")
+	cmd.WaitDelay = time.Second
+	started := time.Now()
+	New(false).CombinedOutput(cmd)
+	if time.Since(started) > 10*time.Second {
+		t.Fatal("command was not stopped")
+	}
+	waitGone(t, cmd.Process.Pid)
+}
+
+func TestTracker_CleanupKillsBackgroundProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported")
+	}
+	tr := New(false)
+	cmd := exec.Command("bash", "-c", "sleep 30 >/dev/null 2>&1 &")
+	if _, err := tr.CombinedOutput(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !groupAlive(cmd.Process.Pid) {
+		t.Fatal("expected the background sleep to be running")
+	}
+	tr.Cleanup()
+	waitGone(t, cmd.Process.Pid)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package cleanup
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Without process groups only the command itself can be killed.
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func groupAlive(pid int) bool { return false }
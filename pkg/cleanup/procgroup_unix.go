@@ -0,0 +1,24 @@
+//go:build unix
+
+package cleanup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func killGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// groupAlive reports whether the process group still has members.
+func groupAlive(pgid int) bool {
+	return syscall.Kill(-pgid, 0) == nil
+}
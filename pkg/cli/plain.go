@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+)
+
+// UI modes selectable with --ui or the ui config setting.
+const (
+	ModeDefault = "default"
+	ModePlain   = "plain"
+)
+
+// New returns the UI for mode: DefaultUI for "" or ModeDefault, PlainUI for
+// ModePlain. editor is the editor command used by OpenEditor.
+func New(mode, editor string) (UI, error) {
+	switch mode {
+	case "", ModeDefault:
+		return &DefaultUI{Editor: editor}, nil
+	case ModePlain:
+		return &PlainUI{DefaultUI: DefaultUI{Editor: editor}}, nil
+	}
+	return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("unknown ui mode '%s' (expected %s or %s)", mode, ModeDefault, ModePlain), nil)
+}
+
+// PlainUI is a UI for screen readers and dumb terminals: line-based prompts,
+// numbered menus, no colors or cursor movement, and output printed in place
+// instead of paged. Editing still opens the editor of DefaultUI.
+type PlainUI struct {
+	DefaultUI
+	In  io.Reader // Defaults to os.Stdin
+	Out io.Writer // Defaults to os.Stdout
+
+	reader *bufio.Reader
+}
+
+var _ UI = (*PlainUI)(nil)
+
+func (ui *PlainUI) out() io.Writer {
+	if ui.Out == nil {
+		return os.Stdout
+	}
+	return ui.Out
+}
+
+// readLine reads one line of input without its line ending.
+func (ui *PlainUI) readLine() (string, error) {
+	if ui.reader == nil {
+		in := ui.In
+		if in == nil {
+			in = os.Stdin
+		}
+		ui.reader = bufio.NewReader(in)
+	}
+	line, err := ui.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// PromptSelect prints the options as a numbered list and reads the number, or
+// the text, of the chosen one. Invalid answers are asked again.
+func (ui *PlainUI) PromptSelect(options []string) (string, error) {
+	w := ui.out()
+	fmt.Fprintln(w, i18n.T("ui.select_option"))
+	for i, option := range options {
+		fmt.Fprintf(w, "%d. %s\n", i+1, option)
+	}
+	for {
+		fmt.Fprint(w, i18n.T("ui.select_number", len(options))+" ")
+		answer, err := ui.readLine()
+		if err != nil {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer)
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		for _, option := range options {
+			if strings.EqualFold(answer, option) {
+				return option, nil
+			}
+		}
+		fmt.Fprintln(w, i18n.T("ui.select_invalid", answer))
+	}
+}
+
+// Confirm asks a yes/no question until it is answered with yes or no.
+func (ui *PlainUI) Confirm(prompt string) (bool, error) {
+	w := ui.out()
+	for {
+		fmt.Fprintf(w, "%s %s: ", prompt, i18n.T("confirm.suffix"))
+		answer, err := ui.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case i18n.IsYes(answer):
+			return true, nil
+		case i18n.IsNo(answer):
+			return false, nil
+		}
+		fmt.Fprintln(w, i18n.T("confirm.invalid"))
+	}
+}
+
+// PromptLine prints label and reads a single line of input.
+func (ui *PlainUI) PromptLine(label string) (string, error) {
+	fmt.Fprint(ui.out(), label)
+	return ui.readLine()
+}
+
+// Pager prints content between start and end markers instead of paging it.
+func (ui *PlainUI) Pager(content string) error {
+	w := ui.out()
+	fmt.Fprintln(w, i18n.T("ui.output_start"))
+	fmt.Fprintln(w, strings.TrimSuffix(content, "\n"))
+	fmt.Fprintln(w, i18n.T("ui.output_end"))
+	return nil
+}
+
+// PrettyJSON prints obj as indented JSON.
+func (ui *PlainUI) PrettyJSON(obj interface{}) error {
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ui.out(), string(b))
+	return nil
+}
+
+// ShowDiff reads a unified diff out in words: each changed line is prefixed
+// with "Added" or "Removed" and each hunk is announced with its line number.
+func (ui *PlainUI) ShowDiff(diff string) error {
+	w := ui.out()
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			fmt.Fprintln(w, i18n.T("diff.old_file", strings.TrimPrefix(line, "--- ")))
+		case strings.HasPrefix(line, "+++ "):
+			fmt.Fprintln(w, i18n.T("diff.new_file", strings.TrimPrefix(line, "+++ ")))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintln(w, i18n.T("diff.hunk", hunkStart(line)))
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintln(w, i18n.T("diff.added", line[1:]))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintln(w, i18n.T("diff.removed", line[1:]))
+		case strings.HasPrefix(line, " "):
+			fmt.Fprintln(w, i18n.T("diff.unchanged", line[1:]))
+		default:
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// hunkStart returns the first line of the new file in a hunk header such as
+// "@@ -3,4 +3,5 @@", or "?" when it cannot be parsed.
+func hunkStart(header string) string {
+	for _, field := range strings.Fields(header) {
+		if strings.HasPrefix(field, "+") {
+			start, _, _ := strings.Cut(field[1:], ",")
+			return start
+		}
+	}
+	return "?"
+}
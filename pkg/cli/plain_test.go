@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/i18n"
+)
+
+func newPlainUI(input string) (*PlainUI, *bytes.Buffer) {
+	i18n.SetLocale("en")
+	var out bytes.Buffer
+	return &PlainUI{In: strings.NewReader(input), Out: &out}, &out
+}
+
+func TestPlainUI_PromptSelect(t *testing.T) {
+	ui, out := newPlainUI("7\nfoo\n2\n")
+	choice, err := ui.PromptSelect([]string{"Approve", "Reject"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if choice != "Reject" {
+		t.Errorf("choice = %q, want Reject", choice)
+	}
+	for _, want := range []string{"1. Approve\n", "2. Reject\n", "'7'", "'foo'"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	ui, _ = newPlainUI("approve\n")
+	if choice, _ := ui.PromptSelect([]string{"Approve", "Reject"}); choice != "Approve" {
+		t.Errorf("choice by text = %q, want Approve", choice)
+	}
+}
+
+func TestPlainUI_Confirm(t *testing.T) {
+	ui, out := newPlainUI("maybe\nno\n")
+	ok, err := ui.Confirm("Continue?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("expected no")
+	}
+	if strings.Count(out.String(), "Continue?") != 2 {
+		t.Errorf("expected the question to be asked again:\n%s", out.String())
+	}
+
+	ui, _ = newPlainUI("")
+	if _, err := ui.Confirm("Continue?"); err == nil {
+		t.Error("expected an error at end of input")
+	}
+}
+
+func TestPlainUI_Pager(t *testing.T) {
+	ui, out := newPlainUI("")
+	if err := ui.Pager("line 1\nline 2\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := i18n.T("ui.output_start") + "\nline 1\nline 2\n" + i18n.T("ui.output_end") + "\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestPlainUI_ShowDiff(t *testing.T) {
+	ui, out := newPlainUI("")
+	diff := "--- a.go\n+++ a.go\n@@ -3,2 +3,2 @@\n keep\n-old\n+new\n"
+	if err := ui.ShowDiff(diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		i18n.T("diff.hunk", "3"),
+		i18n.T("diff.unchanged", "keep"),
+		i18n.T("diff.removed", "old"),
+		i18n.T("diff.added", "new"),
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Error("plain output must not contain escape sequences")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if ui, err := New("", "vi"); err != nil || ui.(*DefaultUI).Editor != "vi" {
+		t.Errorf("New(\"\") = %v, %v", ui, err)
+	}
+	if _, err := New(ModePlain, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := New("fancy", ""); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/mattn/go-tty"
+)
+
+// ReadSecret prints label and reads a line from the terminal without echoing
+// it, for API keys and other secrets.
+func ReadSecret(label string) (string, error) {
+	t, err := tty.Open()
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+	fmt.Fprint(t.Output(), label)
+	secret, err := t.ReadPasswordNoEcho()
+	return strings.TrimSpace(secret), err
+}
+
+// IsTerminal reports whether f is a terminal, so the user can be asked for
+// input.
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -1,7 +1,7 @@
 package cli
 
 import (
-	"encoding/json"
+	"bufio"
 
 	"fmt"
 
@@ -13,6 +13,10 @@ import (
 
 	"strings"
 
+	"ai-team/pkg/cleanup"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/render"
+
 	"github.com/c-bata/go-prompt"
 )
 
@@ -26,7 +30,7 @@ type DefaultUI struct{
 
 func (ui *DefaultUI) PromptSelect(options []string) (string, error) {
 
-	fmt.Println("Please select an option:")
+	fmt.Println(i18n.T("ui.select_option"))
 
 	completer := func(d prompt.Document) []prompt.Suggest {
 
@@ -44,7 +48,7 @@ func (ui *DefaultUI) PromptSelect(options []string) (string, error) {
 
 	selected := prompt.Input("> ", completer,
 
-		prompt.OptionTitle("Select an option"),
+		prompt.OptionTitle(i18n.T("ui.select_title")),
 
 		prompt.OptionPrefixTextColor(prompt.Yellow),
 
@@ -61,7 +65,7 @@ func (ui *DefaultUI) PromptSelect(options []string) (string, error) {
 
 func (ui *DefaultUI) Confirm(prompt string) (bool, error) {
 
-	fmt.Printf("%s [y/n]: ", prompt)
+	fmt.Printf("%s %s: ", prompt, i18n.T("confirm.suffix"))
 
 	var response string
 
@@ -73,9 +77,7 @@ func (ui *DefaultUI) Confirm(prompt string) (bool, error) {
 
 	}
 
-	response = strings.ToLower(strings.TrimSpace(response))
-
-	return response == "y" || response == "yes", nil
+	return i18n.IsYes(response), nil
 
 }
 
@@ -91,7 +93,8 @@ func (ui *DefaultUI) OpenEditor(content string) (string, error) {
 		editor = "vim"
 	}
 
-	file, err := ioutil.TempFile(os.TempDir(), "ai-team-editor-")
+	temps := cleanup.Default()
+	file, err := temps.CreateTemp(os.TempDir(), "ai-team-editor-")
 
 	if err != nil {
 
@@ -99,7 +102,7 @@ func (ui *DefaultUI) OpenEditor(content string) (string, error) {
 
 	}
 
-	defer os.Remove(file.Name())
+	defer temps.Release(file.Name())
 
 	if _, err := file.WriteString(content); err != nil {
 
@@ -139,6 +142,24 @@ func (ui *DefaultUI) OpenEditor(content string) (string, error) {
 
 }
 
+// PromptLine prints label and reads a single line of input.
+
+func (ui *DefaultUI) PromptLine(label string) (string, error) {
+
+	fmt.Print(label)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && line == "" {
+
+		return "", err
+
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+
+}
+
 // Pager displays the given content in a pager.
 
 func (ui *DefaultUI) Pager(content string) error {
@@ -153,11 +174,11 @@ func (ui *DefaultUI) Pager(content string) error {
 
 }
 
-// PrettyJSON prints the given object as pretty-printed JSON.
+// PrettyJSON prints the given object as pretty-printed JSON in the colors of the theme.
 
 func (ui *DefaultUI) PrettyJSON(obj interface{}) error {
 
-	b, err := json.MarshalIndent(obj, "", "  ")
+	out, err := render.Stdout().JSON(obj)
 
 	if err != nil {
 
@@ -165,7 +186,17 @@ func (ui *DefaultUI) PrettyJSON(obj interface{}) error {
 
 	}
 
-	fmt.Println(string(b))
+	fmt.Println(out)
+
+	return nil
+
+}
+
+// ShowDiff prints a unified diff in the colors of the theme.
+
+func (ui *DefaultUI) ShowDiff(diff string) error {
+
+	fmt.Println(render.Stdout().Diff(diff))
 
 	return nil
 
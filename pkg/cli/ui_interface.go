@@ -5,6 +5,8 @@ type UI interface {
 	PromptSelect(options []string) (string, error)
 	Confirm(prompt string) (bool, error)
 	OpenEditor(content string) (string, error)
+	PromptLine(label string) (string, error)
 	Pager(content string) error
 	PrettyJSON(obj interface{}) error
+	ShowDiff(diff string) error
 }
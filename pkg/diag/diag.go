@@ -0,0 +1,141 @@
+package diag
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Server exposes pprof endpoints and periodically logs runtime metrics for
+// long-running commands.
+type Server struct {
+	Addr string // Address the pprof endpoints listen on; empty when disabled
+
+	listener net.Listener
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Stats is a snapshot of runtime metrics.
+type Stats struct {
+	Goroutines int
+	HeapAlloc  uint64
+	HeapInuse  uint64
+	Sys        uint64
+	NumGC      uint32
+}
+
+// ReadStats returns the current runtime metrics.
+func ReadStats() Stats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Stats{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+		HeapInuse:  m.HeapInuse,
+		Sys:        m.Sys,
+		NumGC:      m.NumGC,
+	}
+}
+
+// Start serves pprof on addr (if not empty) and logs runtime metrics every
+// interval (if positive). Use "127.0.0.1:0" to pick a free port. pprof
+// exposes the command line and memory of the process, so addr must be a
+// loopback address unless allowRemote is set.
+func Start(addr string, interval time.Duration, allowRemote bool) (*Server, error) {
+	s := &Server{stop: make(chan struct{})}
+	if addr != "" {
+		if !allowRemote && !loopback(addr) {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("refusing to serve pprof on %s, which is not a loopback address; use e.g. localhost:6060, or pass --pprof-allow-remote", addr), nil)
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to listen for pprof on %s", addr), err)
+		}
+		s.listener = listener
+		s.Addr = listener.Addr().String()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := http.Serve(listener, mux); err != nil && s.running() {
+				logrus.Warnf("pprof server stopped: %v", err)
+			}
+		}()
+		logrus.Infof("pprof endpoints available at http://%s/debug/pprof/", s.Addr)
+	}
+	if interval > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					logStats(ReadStats())
+				case <-s.stop:
+					return
+				}
+			}
+		}()
+	}
+	return s, nil
+}
+
+// loopback reports whether addr only listens on the loopback interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) running() bool {
+	select {
+	case <-s.stop:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop shuts down the pprof listener and the metrics logger.
+func (s *Server) Stop() {
+	if !s.running() {
+		return
+	}
+	close(s.stop)
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.wg.Wait()
+}
+
+func logStats(st Stats) {
+	logrus.WithFields(logrus.Fields{
+		"goroutines": st.Goroutines,
+		"heap_alloc": st.HeapAlloc,
+		"heap_inuse": st.HeapInuse,
+		"sys":        st.Sys,
+		"num_gc":     st.NumGC,
+	}).Info("Runtime metrics")
+}
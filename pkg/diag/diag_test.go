@@ -0,0 +1,75 @@
+package diag
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStart_ServesPprof(t *testing.T) {
+	s, err := Start("127.0.0.1:0", 0, false)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop()
+
+	resp, err := http.Get("http://" + s.Addr + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine profile") {
+		t.Fatalf("unexpected response %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestStop_EndsMetricsLoop(t *testing.T) {
+	s, err := Start("", 10*time.Millisecond, false)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if s.Addr != "" {
+		t.Errorf("expected no pprof address, got %q", s.Addr)
+	}
+}
+
+func TestReadStats(t *testing.T) {
+	st := ReadStats()
+	if st.Goroutines < 1 || st.Sys == 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestStart_RefusesRemoteAddressUnlessAllowed(t *testing.T) {
+	for _, addr := range []string{":0", "0.0.0.0:0", "[::]:0", "example.com:6060", "no-port"} {
+		if s, err := Start(addr, 0, false); err == nil {
+			s.Stop()
+			t.Errorf("expected pprof on %q to be refused", addr)
+		}
+	}
+	for _, addr := range []string{"localhost:0", "[::1]:0"} {
+		if !loopback(addr) {
+			t.Errorf("expected %q to count as loopback", addr)
+		}
+	}
+	s, err := Start("0.0.0.0:0", 0, true)
+	if err != nil {
+		t.Fatalf("expected an allowed remote address to be served, got %v", err)
+	}
+	s.Stop()
+}
@@ -17,6 +17,11 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
 }
 
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // New creates a new custom error.
 func New(code int, message string, err error) *Error {
 	return &Error{
@@ -0,0 +1,31 @@
+// Package filelock takes locks between processes through lock files, so
+// concurrent ai-team runs can take turns on shared files.
+package filelock
+
+import (
+	"fmt"
+	"time"
+)
+
+// pollInterval is how often Lock tries again while another process holds
+// the lock.
+const pollInterval = 50 * time.Millisecond
+
+// Lock takes the lock at path, waiting up to wait for another process to
+// release it. The returned func releases it.
+func Lock(path string, wait time.Duration) (func(), error) {
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := TryLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			return func() { Release(path, f) }, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s is held by another process", path)
+		}
+		time.Sleep(pollInterval)
+	}
+}
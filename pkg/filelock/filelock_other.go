@@ -0,0 +1,24 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// Without flock the lock is the file itself, created exclusively. A lock
+// left behind by a process that died stays until it is removed by hand, as
+// taking it over cannot be made atomic.
+
+// TryLock creates path exclusively, returning nil when it already exists.
+func TryLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// Release removes the lock file.
+func Release(path string, f *os.File) {
+	f.Close()
+	os.Remove(path)
+}
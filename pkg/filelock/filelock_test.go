@@ -0,0 +1,30 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_WaitsForHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.lock")
+	release, err := Lock(path, time.Second)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if f, err := TryLock(path); err != nil || f != nil {
+		t.Fatalf("expected the held lock to be refused, got %v, %v", f, err)
+	}
+	if _, err := Lock(path, 100*time.Millisecond); err == nil {
+		t.Fatalf("expected Lock to give up while the lock is held")
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		release()
+	}()
+	again, err := Lock(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+	again()
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+)
+
+// TryLock opens path and takes an exclusive flock on it, returning nil when
+// another process holds the lock. Locking is atomic, so two processes that
+// find a dead holder's file cannot both take it.
+func TryLock(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, nil
+			}
+			return nil, err
+		}
+		// The previous holder removes the file on release; a lock on a file
+		// removed after we opened it guards nothing, so open the new one.
+		if info, err := os.Stat(path); err == nil {
+			if own, err := f.Stat(); err == nil && os.SameFile(info, own) {
+				return f, nil
+			}
+		}
+		f.Close()
+	}
+}
+
+// Release removes the lock file while still holding its lock, then unlocks
+// it.
+func Release(path string, f *os.File) {
+	os.Remove(path)
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
@@ -0,0 +1,145 @@
+// Package i18n holds the message catalog of user-facing CLI strings. The
+// locale comes from the config's locale setting or, when unset, from
+// LC_ALL, LC_MESSAGES or LANG; messages missing from a locale fall back to
+// English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is used when no supported locale is configured or detected.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var (
+	mu       sync.RWMutex
+	catalogs = loadCatalogs()
+	current  = Detect()
+)
+
+func loadCatalogs() map[string]map[string]string {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := map[string]map[string]string{}
+	for _, e := range entries {
+		data, err := localeFiles.ReadFile("locales/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = messages
+	}
+	return catalogs
+}
+
+// Locales returns the supported locales, sorted.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for l := range catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supported reports whether locale, e.g. "de" or "de_DE.UTF-8", has a catalog.
+func Supported(locale string) bool {
+	_, ok := catalogs[normalize(locale)]
+	return ok
+}
+
+// normalize reduces a POSIX locale such as de_DE.UTF-8 to its language.
+func normalize(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// Detect returns the supported locale named by LC_ALL, LC_MESSAGES or LANG,
+// the first one that is set, or DefaultLocale.
+func Detect() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			if Supported(v) {
+				return normalize(v)
+			}
+			break
+		}
+	}
+	return DefaultLocale
+}
+
+// SetLocale selects the catalog used by T. An empty locale selects the
+// detected one; an unsupported locale selects DefaultLocale.
+func SetLocale(locale string) {
+	if locale == "" {
+		locale = Detect()
+	}
+	if !Supported(locale) {
+		locale = DefaultLocale
+	}
+	mu.Lock()
+	current = normalize(locale)
+	mu.Unlock()
+}
+
+// Locale returns the selected locale.
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T returns the message for key in the selected locale, formatted with args
+// as by fmt.Sprintf. An unknown key is returned as is.
+func T(key string, args ...interface{}) string {
+	format, ok := catalogs[Locale()][key]
+	if !ok {
+		if format, ok = catalogs[DefaultLocale][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// IsYes reports whether answer accepts a yes/no question in the selected
+// locale ("y" and "yes" are always accepted).
+func IsYes(answer string) bool {
+	return isAnswer(answer, "confirm.yes_answers", "y", "yes")
+}
+
+// IsNo reports whether answer declines a yes/no question in the selected
+// locale ("n" and "no" are always accepted).
+func IsNo(answer string) bool {
+	return isAnswer(answer, "confirm.no_answers", "n", "no")
+}
+
+func isAnswer(answer, key string, always ...string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for _, a := range append(strings.Split(T(key), ","), always...) {
+		if answer == strings.TrimSpace(a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs_Complete(t *testing.T) {
+	en := catalogs[DefaultLocale]
+	for _, locale := range Locales() {
+		messages := catalogs[locale]
+		for key, msg := range en {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: missing %s", locale, key)
+				continue
+			}
+			if got, want := verb.FindAllString(translated, -1), verb.FindAllString(msg, -1); len(got) != len(want) {
+				t.Errorf("%s: %s has verbs %v, want %v", locale, key, got, want)
+			}
+		}
+		for key := range messages {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s: %s is not in the English catalog", locale, key)
+			}
+		}
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	defer SetLocale(DefaultLocale)
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	SetLocale("")
+	if Locale() != "de" {
+		t.Fatalf("expected de from LANG, got %s", Locale())
+	}
+	if got := T("run.id", "01ABC"); got != "Lauf-ID: 01ABC" {
+		t.Errorf("unexpected German message %q", got)
+	}
+	if !IsYes("Ja") || !IsYes("y") || IsYes("n") {
+		t.Error("expected ja and y to be yes in German")
+	}
+
+	SetLocale("fr")
+	if Locale() != DefaultLocale {
+		t.Errorf("expected an unsupported locale to fall back to en, got %s", Locale())
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("expected an unknown key to be returned as is, got %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunLogger writes the log lines of one chain run. Each step logs through its
+// own StepLogger, so lines of steps running at the same time do not
+// interleave unreadably: they are prefixed with the step, held until the step
+// ends, or written to a file per step, as configured.
+type RunLogger struct {
+	RunID string
+	mode  string
+	dir   string // Step log files of the run, in StepLogFiles mode
+
+	mu  sync.Mutex // Serializes writes to out
+	out io.Writer
+}
+
+// NewRunLogger returns the logger of run runID, writing to the standard
+// logger's output. In StepLogFiles mode step logs go to cfg.Dir/runID.
+func NewRunLogger(runID string, cfg types.LoggingConfig) *RunLogger {
+	r := &RunLogger{RunID: runID, mode: cfg.StepOutput, out: logrus.StandardLogger().Out}
+	if r.mode == "" {
+		r.mode = types.StepLogPrefix
+	}
+	if r.mode == types.StepLogFiles {
+		r.dir = filepath.Join(cfg.Dir, runID)
+	}
+	return r
+}
+
+// Write writes p to the run's output, after any buffered step that is
+// flushing.
+func (r *RunLogger) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.out.Write(p)
+}
+
+// StepLogger is the logger of one chain step.
+type StepLogger struct {
+	*logrus.Entry
+	Path string // Log file of the step in StepLogFiles mode
+
+	run    *RunLogger
+	buffer *bytes.Buffer
+	file   *os.File
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Step returns the logger of the step with the given index and name. Close
+// must be called when the step ends. If its log file cannot be created, the
+// step logs to the run's output with a warning.
+func (r *RunLogger) Step(index int, name string) *StepLogger {
+	std := logrus.StandardLogger()
+	s := &StepLogger{run: r}
+	logger := &logrus.Logger{
+		Out:       r,
+		Hooks:     std.Hooks,
+		Formatter: &prefixFormatter{prefix: "[" + name + "] ", base: std.Formatter},
+		Level:     std.GetLevel(),
+		ExitFunc:  std.ExitFunc,
+	}
+	switch r.mode {
+	case types.StepLogBuffer:
+		s.buffer = &bytes.Buffer{}
+		logger.Out = s.buffer
+	case types.StepLogFiles:
+		path := filepath.Join(r.dir, fmt.Sprintf("%02d-%s.log", index+1, unsafeFileChars.ReplaceAllString(name, "_")))
+		file, err := openLogFile(path)
+		if err != nil {
+			logrus.Warnf("Step %s logs to the run output: %v", name, err)
+			break
+		}
+		s.file, s.Path = file, path
+		logger.Out = file
+		logger.Formatter = std.Formatter
+	}
+	s.Entry = logger.WithFields(logrus.Fields{"run_id": r.RunID, "step": name})
+	return s
+}
+
+func openLogFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// Close writes out the lines a buffering step held, in one piece, or closes
+// the step's log file. Closing again does nothing.
+func (s *StepLogger) Close() error {
+	switch {
+	case s.buffer != nil:
+		if s.buffer.Len() == 0 {
+			return nil
+		}
+		_, err := s.run.Write(s.buffer.Bytes())
+		s.buffer.Reset()
+		return err
+	case s.file != nil:
+		err := s.file.Close()
+		s.file = nil
+		return err
+	}
+	return nil
+}
+
+// prefixFormatter formats entries with base and starts every line with prefix.
+type prefixFormatter struct {
+	prefix string
+	base   logrus.Formatter
+}
+
+func (f *prefixFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	out, err := f.base.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.SplitAfter(out, []byte("\n"))
+	var b bytes.Buffer
+	for _, line := range lines {
+		if len(line) > 0 {
+			b.WriteString(f.prefix)
+			b.Write(line)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose log lines go to entry.
+func WithLogger(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
+
+// From returns the logger set by WithLogger, or the standard logger.
+func From(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
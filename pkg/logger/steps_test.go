@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	std := logrus.StandardLogger()
+	origOut, origFormatter := std.Out, std.Formatter
+	std.SetOutput(&out)
+	std.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableColors: true})
+	t.Cleanup(func() {
+		std.SetOutput(origOut)
+		std.SetFormatter(origFormatter)
+	})
+	return &out
+}
+
+func TestRunLogger_Prefix(t *testing.T) {
+	out := captureOutput(t)
+	step := NewRunLogger("run-1", types.LoggingConfig{}).Step(0, "coder")
+	step.Infof("wrote %s", "main.go")
+	step.Close()
+	if got := out.String(); !strings.HasPrefix(got, "[coder] level=info msg=\"wrote main.go\"") || !strings.Contains(got, "run_id=run-1 step=coder") {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestRunLogger_Buffer(t *testing.T) {
+	out := captureOutput(t)
+	run := NewRunLogger("run-1", types.LoggingConfig{StepOutput: types.StepLogBuffer})
+	a, b := run.Step(0, "a"), run.Step(1, "b")
+	a.Info("a1")
+	b.Info("b1")
+	a.Info("a2")
+	if out.Len() != 0 {
+		t.Fatalf("expected buffered steps to hold their lines, got %q", out.String())
+	}
+	b.Close()
+	a.Close()
+	a.Close()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "msg=b1") || !strings.Contains(lines[1], "msg=a1") || !strings.Contains(lines[2], "msg=a2") {
+		t.Errorf("expected each step's lines together, got %q", lines)
+	}
+}
+
+func TestRunLogger_Files(t *testing.T) {
+	out := captureOutput(t)
+	dir := t.TempDir()
+	step := NewRunLogger("run-1", types.LoggingConfig{StepOutput: types.StepLogFiles, Dir: dir}).Step(2, "fix tests")
+	logThrough(step)
+	if err := step.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if step.Path != filepath.Join(dir, "run-1", "03-fix_tests.log") {
+		t.Errorf("unexpected log file %s", step.Path)
+	}
+	data, err := os.ReadFile(step.Path)
+	if err != nil || !strings.Contains(string(data), "msg=hello") || strings.HasPrefix(string(data), "[") {
+		t.Errorf("expected the line in the step's file without a prefix, got %q (%v)", data, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing on the run output, got %q", out.String())
+	}
+}
+
+// logThrough logs "hello" through a context carrying the step's logger.
+func logThrough(step *StepLogger) {
+	From(WithLogger(context.Background(), step.Entry)).Info("hello")
+}
+
+func TestFrom_Default(t *testing.T) {
+	if From(context.Background()).Logger != logrus.StandardLogger() {
+		t.Error("expected the standard logger without a step logger")
+	}
+}
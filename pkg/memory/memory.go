@@ -0,0 +1,370 @@
+// Package memory keeps facts roles learn about a project across runs, so later
+// runs and chains can recall them.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/filelock"
+)
+
+// DefaultPath is the file facts are kept in when no memory.path is
+// configured.
+const DefaultPath = ".ai-team/memory.json"
+
+// lockWait is how long a change waits for another process's lock on the
+// store.
+const lockWait = 10 * time.Second
+
+// DefaultRecallLimit is how many facts Recall returns when no limit is given.
+const DefaultRecallLimit = 10
+
+// DefaultMaxFacts is how many facts a store keeps when MaxFacts is not set.
+const DefaultMaxFacts = 1000
+
+// MaxFactLength is the most characters of text a fact may have. Together
+// with MaxFacts it bounds the file rewritten on every change.
+const MaxFactLength = 2000
+
+// Fact is one remembered piece of project knowledge, e.g. "tests run with
+// make test".
+type Fact struct {
+	ID      int       `json:"id"`
+	Key     string    `json:"key,omitempty"` // Optional name; remembering a key again replaces its fact
+	Text    string    `json:"text"`
+	Tags    []string  `json:"tags,omitempty"`
+	RunID   string    `json:"run_id,omitempty"`
+	Role    string    `json:"role,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// HasTag reports whether the fact is tagged tag, ignoring case.
+func (f Fact) HasTag(tag string) bool {
+	for _, t := range f.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// file is the layout of the store's JSON file.
+type file struct {
+	NextID int    `json:"next_id"`
+	Facts  []Fact `json:"facts"`
+}
+
+// Store keeps facts in a JSON file at Path. Every operation reads the file
+// afresh, so facts remembered by other processes are seen; writes replace the
+// file only once the new version is complete. Changes hold a lock on
+// Path+".lock" from reading the file to replacing it, so concurrent runs do
+// not lose each other's facts. The store keeps at most MaxFacts facts
+// (DefaultMaxFacts when 0), forgetting the least recently updated ones, so
+// the file rewritten stays small.
+type Store struct {
+	Path     string
+	MaxFacts int
+
+	mu sync.Mutex
+}
+
+// NewStore returns a store keeping facts at path, or DefaultPath.
+func NewStore(path string) *Store {
+	if path == "" {
+		path = DefaultPath
+	}
+	return &Store{Path: path}
+}
+
+func (s *Store) load() (*file, error) {
+	f := &file{NextID: 1}
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to read memory %s", s.Path), err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to parse memory %s", s.Path), err)
+	}
+	return f, nil
+}
+
+// update runs change on the stored facts while holding the store's locks,
+// and saves them when change reports a change.
+func (s *Store) update(change func(f *file) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to create memory directory %s", filepath.Dir(s.Path)), err)
+	}
+	unlock, err := filelock.Lock(s.Path+".lock", lockWait)
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to lock memory %s", s.Path), err)
+	}
+	defer unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if !change(f) {
+		return nil
+	}
+	return s.save(f)
+}
+
+// save writes f to a temporary file next to Path and renames it over Path.
+func (s *Store) save(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to encode memory", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write memory %s", s.Path), err)
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), s.Path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write memory %s", s.Path), err)
+	}
+	return nil
+}
+
+// Remember stores fact, whose Text must be set. A fact with the Key of a
+// stored one replaces it, keeping its ID and creation time; updated reports
+// that case.
+func (s *Store) Remember(fact Fact) (stored Fact, updated bool, err error) {
+	fact.Text = strings.TrimSpace(fact.Text)
+	if fact.Text == "" {
+		return Fact{}, false, errors.New(errors.ErrCodeTool, "a fact needs text", nil)
+	}
+	if len(fact.Text) > MaxFactLength {
+		return Fact{}, false, errors.New(errors.ErrCodeTool, fmt.Sprintf("a fact may have at most %d characters, got %d; remember a summary", MaxFactLength, len(fact.Text)), nil)
+	}
+	err = s.update(func(f *file) bool {
+		now := time.Now().UTC()
+		fact.Created, fact.Updated = now, now
+		if fact.Key != "" {
+			for i, old := range f.Facts {
+				if old.Key == fact.Key {
+					fact.ID, fact.Created = old.ID, old.Created
+					f.Facts[i] = fact
+					updated = true
+					return true
+				}
+			}
+		}
+		fact.ID = f.NextID
+		f.NextID++
+		f.Facts = append(f.Facts, fact)
+		s.trim(f)
+		return true
+	})
+	if err != nil {
+		return Fact{}, false, err
+	}
+	return fact, updated, nil
+}
+
+// trim forgets the least recently updated facts beyond MaxFacts.
+func (s *Store) trim(f *file) {
+	max := s.MaxFacts
+	if max <= 0 {
+		max = DefaultMaxFacts
+	}
+	if len(f.Facts) <= max {
+		return
+	}
+	sort.Slice(f.Facts, func(i, j int) bool { return newer(f.Facts[i], f.Facts[j]) })
+	f.Facts = f.Facts[:max]
+	sort.Slice(f.Facts, func(i, j int) bool { return f.Facts[i].ID < f.Facts[j].ID })
+}
+
+// Recall returns up to limit facts (DefaultRecallLimit when 0, all when
+// negative) matching query and, when set, tagged tag. A fact matches when its text, key
+// or tags contain any word of query; facts matching more words come first,
+// then the most recently updated. An empty query matches every fact.
+func (s *Store) Recall(query, tag string, limit int) ([]Fact, error) {
+	facts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = DefaultRecallLimit
+	}
+	words := strings.Fields(strings.ToLower(query))
+	type scored struct {
+		fact  Fact
+		score int
+	}
+	var matches []scored
+	for _, f := range facts {
+		if tag != "" && !f.HasTag(tag) {
+			continue
+		}
+		haystack := strings.ToLower(f.Key + " " + f.Text + " " + strings.Join(f.Tags, " "))
+		score := 0
+		for _, w := range words {
+			if strings.Contains(haystack, w) {
+				score++
+			}
+		}
+		if len(words) > 0 && score == 0 {
+			continue
+		}
+		matches = append(matches, scored{f, score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return newer(matches[i].fact, matches[j].fact)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	result := make([]Fact, len(matches))
+	for i, m := range matches {
+		result[i] = m.fact
+	}
+	return result, nil
+}
+
+// Recent returns the limit most recently updated facts with one of tags, or
+// any facts when tags is empty.
+func (s *Store) Recent(limit int, tags []string) ([]Fact, error) {
+	facts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Fact
+	for _, f := range facts {
+		tagged := len(tags) == 0
+		for _, tag := range tags {
+			tagged = tagged || f.HasTag(tag)
+		}
+		if tagged {
+			matches = append(matches, f)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return newer(matches[i], matches[j]) })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// newer reports whether a was updated after b; of facts updated at the same
+// time, the later remembered one counts as newer.
+func newer(a, b Fact) bool {
+	if !a.Updated.Equal(b.Updated) {
+		return a.Updated.After(b.Updated)
+	}
+	return a.ID > b.ID
+}
+
+// List returns every stored fact in the order they were first remembered.
+func (s *Store) List() ([]Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(f.Facts, func(i, j int) bool { return f.Facts[i].ID < f.Facts[j].ID })
+	return f.Facts, nil
+}
+
+// Get returns the fact with the given ID.
+func (s *Store) Get(id int) (Fact, error) {
+	facts, err := s.List()
+	if err != nil {
+		return Fact{}, err
+	}
+	for _, f := range facts {
+		if f.ID == id {
+			return f, nil
+		}
+	}
+	return Fact{}, errors.New(errors.ErrCodeConfig, fmt.Sprintf("no fact %d in %s", id, s.Path), nil)
+}
+
+// Clear removes the facts tagged tag, or every fact when tag is empty, and
+// returns how many were removed. IDs are not reused.
+func (s *Store) Clear(tag string) (int, error) {
+	removed := 0
+	err := s.update(func(f *file) bool {
+		kept := f.Facts[:0]
+		for _, fact := range f.Facts {
+			if tag != "" && !fact.HasTag(tag) {
+				kept = append(kept, fact)
+			}
+		}
+		removed = len(f.Facts) - len(kept)
+		f.Facts = kept
+		return removed > 0
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Format renders facts as a Markdown list, one fact per line with its tags,
+// for a role's prompt.
+func Format(facts []Fact) string {
+	var b strings.Builder
+	for _, f := range facts {
+		b.WriteString("- ")
+		b.WriteString(strings.ReplaceAll(f.Text, "\n", " "))
+		if len(f.Tags) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(f.Tags, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Show renders a fact with its origin for the terminal.
+func Show(f Fact) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Fact:    %d\n", f.ID)
+	if f.Key != "" {
+		fmt.Fprintf(&b, "Key:     %s\n", f.Key)
+	}
+	if len(f.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags:    %s\n", strings.Join(f.Tags, ", "))
+	}
+	if f.Role != "" {
+		fmt.Fprintf(&b, "Role:    %s\n", f.Role)
+	}
+	if f.RunID != "" {
+		fmt.Fprintf(&b, "Run:     %s\n", f.RunID)
+	}
+	fmt.Fprintf(&b, "Created: %s\n", f.Created.Format(time.RFC3339))
+	if !f.Updated.Equal(f.Created) {
+		fmt.Fprintf(&b, "Updated: %s\n", f.Updated.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "\n%s\n", f.Text)
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStore_RememberRecall(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "memory.json"))
+	if _, _, err := store.Remember(Fact{Text: "  "}); err == nil {
+		t.Error("expected an error for a fact without text")
+	}
+	first, _, err := store.Remember(Fact{Text: "Tests run with make test", Tags: []string{"build"}, Key: "tests", RunID: "01RUN", Role: "coder"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Remember(Fact{Text: "The API uses JSON over HTTP", Tags: []string{"api"}})
+	updated, wasUpdated, err := store.Remember(Fact{Text: "Tests run with go test ./...", Tags: []string{"build"}, Key: "tests"})
+	if err != nil || !wasUpdated || updated.ID != first.ID || !updated.Created.Equal(first.Created) {
+		t.Fatalf("expected the keyed fact replaced in place, got %+v (updated %v, %v)", updated, wasUpdated, err)
+	}
+
+	// A new store on the same file sees the facts.
+	reopened := NewStore(store.Path)
+	facts, err := reopened.Recall("go tests", "", 0)
+	if err != nil || len(facts) != 1 || facts[0].Text != "Tests run with go test ./..." {
+		t.Errorf("expected the updated fact, got %+v, %v", facts, err)
+	}
+	api, _ := reopened.Recall("", "API", 0)
+	if len(api) != 1 || api[0].ID != 2 {
+		t.Errorf("expected the fact tagged api, got %+v", api)
+	}
+	if recent, _ := reopened.Recent(1, nil); len(recent) != 1 || recent[0].ID != first.ID {
+		t.Errorf("expected the most recently updated fact, got %+v", recent)
+	}
+	if text := Format(api); text != "- The API uses JSON over HTTP [api]\n" {
+		t.Errorf("unexpected formatted facts %q", text)
+	}
+	if shown := Show(updated); !strings.Contains(shown, "Key:     tests") || !strings.Contains(shown, "go test ./...") {
+		t.Errorf("unexpected fact display %q", shown)
+	}
+
+	if removed, err := reopened.Clear("build"); err != nil || removed != 1 {
+		t.Errorf("expected one fact cleared, got %d, %v", removed, err)
+	}
+	if _, err := reopened.Get(first.ID); err == nil {
+		t.Error("expected the cleared fact gone")
+	}
+	next, _, _ := reopened.Remember(Fact{Text: "Releases are tagged vX.Y.Z"})
+	if next.ID != 3 {
+		t.Errorf("expected IDs not to be reused, got %d", next.ID)
+	}
+	if removed, _ := reopened.Clear(""); removed != 2 {
+		t.Errorf("expected every fact cleared, got %d", removed)
+	}
+}
+
+func TestStore_ConcurrentStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		// Separate stores share no mutex, like separate runs.
+		s := NewStore(path)
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				if _, _, err := s.Remember(Fact{Text: fmt.Sprintf("fact %d", n)}); err != nil {
+					t.Error(err)
+				}
+			}(i*10 + j)
+		}
+	}
+	wg.Wait()
+	facts, err := NewStore(path).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facts) != 40 || facts[39].ID != 40 {
+		t.Errorf("expected 40 facts with distinct IDs, got %d", len(facts))
+	}
+	if temps, _ := filepath.Glob(path + ".*.tmp"); len(temps) != 0 {
+		t.Errorf("expected no temporary files left, got %v", temps)
+	}
+}
+
+func TestStore_BoundsFacts(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "memory.json"))
+	store.MaxFacts = 3
+	if _, _, err := store.Remember(Fact{Text: strings.Repeat("x", MaxFactLength+1)}); err == nil {
+		t.Error("expected an error for a fact longer than MaxFactLength")
+	}
+	for i := 1; i <= 5; i++ {
+		if _, _, err := store.Remember(Fact{Text: fmt.Sprintf("fact %d", i)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	facts, err := store.List()
+	if err != nil || len(facts) != 3 || facts[0].ID != 3 || facts[2].ID != 5 {
+		t.Fatalf("expected the 3 most recent facts kept, got %+v, %v", facts, err)
+	}
+}
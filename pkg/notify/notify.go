@@ -0,0 +1,120 @@
+// Package notify tells the user that a run finished or waits for approval,
+// so they can switch to other work while a long chain runs.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notification modes of notify_on_complete.
+const (
+	ModeOff     = "off"
+	ModeBell    = "bell"    // Terminal bell and a summary line on stderr
+	ModeDesktop = "desktop" // Desktop notification, falling back to the bell
+)
+
+// Modes lists the valid notify_on_complete values.
+var Modes = []string{ModeOff, ModeBell, ModeDesktop}
+
+// Notifier delivers notifications in one mode.
+type Notifier struct {
+	Mode  string
+	After time.Duration // Runs shorter than this finish without a notification
+	Out   io.Writer     // Bell and summary output; defaults to os.Stderr
+
+	// run executes a desktop notification command; replaced in tests.
+	run func(name string, args ...string) error
+}
+
+// New returns a notifier for mode, or nil when mode is empty or ModeOff. A nil
+// *Notifier is valid and notifies nothing.
+func New(mode string, after time.Duration) *Notifier {
+	if mode == "" || mode == ModeOff {
+		return nil
+	}
+	return &Notifier{Mode: mode, After: after}
+}
+
+// Valid reports whether mode is a notify_on_complete value; empty is valid.
+func Valid(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range Modes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify delivers title and message. Desktop notifications that cannot be
+// shown fall back to the bell.
+func (n *Notifier) Notify(title, message string) {
+	if n == nil {
+		return
+	}
+	if n.Mode == ModeDesktop {
+		name, args := desktopCommand(title, message)
+		if name != "" {
+			err := n.runner()(name, args...)
+			if err == nil {
+				return
+			}
+			logrus.Debugf("Desktop notification failed, ringing the bell instead: %v", err)
+		}
+	}
+	fmt.Fprintf(n.out(), "\a%s: %s\n", title, message)
+}
+
+// Finished notifies that a run which took elapsed is over, unless it took
+// less than After.
+func (n *Notifier) Finished(title, message string, elapsed time.Duration) {
+	if n == nil || elapsed < n.After {
+		return
+	}
+	n.Notify(title, message)
+}
+
+func (n *Notifier) out() io.Writer {
+	if n.Out == nil {
+		return os.Stderr
+	}
+	return n.Out
+}
+
+func (n *Notifier) runner() func(string, ...string) error {
+	if n.run != nil {
+		return n.run
+	}
+	return func(name string, args ...string) error {
+		return exec.Command(name, args...).Run()
+	}
+}
+
+// desktopCommand returns the command showing a desktop notification on this
+// platform, or "" when there is none.
+func desktopCommand(title, message string) (string, []string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "osascript", []string{"-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))}
+	case "windows":
+		return "", nil
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return "", nil
+	}
+	return "notify-send", []string{"--app-name=ai-team", title, message}
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
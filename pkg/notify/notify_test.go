@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_Off(t *testing.T) {
+	for _, mode := range []string{"", ModeOff} {
+		if n := New(mode, 0); n != nil {
+			t.Errorf("New(%q) = %v, want nil", mode, n)
+		}
+	}
+	var n *Notifier
+	n.Notify("title", "ignored") // A nil notifier does nothing
+	n.Finished("title", "ignored", time.Hour)
+}
+
+func TestNotify_Bell(t *testing.T) {
+	var out bytes.Buffer
+	n := New(ModeBell, 0)
+	n.Out = &out
+	n.Notify("ai-team", "Chain review finished in 20m0s")
+	if got := out.String(); got != "\aai-team: Chain review finished in 20m0s\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestNotify_DesktopFallsBackToBell(t *testing.T) {
+	var out bytes.Buffer
+	var calls int
+	n := New(ModeDesktop, 0)
+	n.Out = &out
+	n.run = func(name string, args ...string) error {
+		calls++
+		return errors.New("no notification daemon")
+	}
+	n.Notify("ai-team", "Approval needed: write_file")
+	if !strings.HasPrefix(out.String(), "\a") {
+		t.Errorf("expected the bell after a failed desktop notification, got %q (%d calls)", out.String(), calls)
+	}
+}
+
+func TestFinished_After(t *testing.T) {
+	var out bytes.Buffer
+	n := New(ModeBell, time.Minute)
+	n.Out = &out
+	n.Finished("ai-team", "quick", 10*time.Second)
+	if out.Len() != 0 {
+		t.Errorf("short run notified: %q", out.String())
+	}
+	n.Finished("ai-team", "slow", 2*time.Minute)
+	if !strings.Contains(out.String(), "slow") {
+		t.Errorf("long run not notified: %q", out.String())
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, mode := range []string{"", ModeOff, ModeBell, ModeDesktop} {
+		if !Valid(mode) {
+			t.Errorf("Valid(%q) = false", mode)
+		}
+	}
+	if Valid("email") {
+		t.Error("Valid(email) = true")
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	if got := appleScriptString(`say "hi" \ bye`); got != `"say \"hi\" \\ bye"` {
+		t.Errorf("appleScriptString = %s", got)
+	}
+}
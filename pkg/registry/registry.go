@@ -0,0 +1,360 @@
+// Package registry fetches shared role and chain definitions from git
+// repositories. A definition is referenced as registry://<registry>/<name>@<version>,
+// where version is any git revision (usually a tag). Registry repositories keep
+// definitions in roles/<name>.yaml and chains/<name>.yaml.
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/errors"
+)
+
+// Scheme prefixes registry references.
+const Scheme = "registry://"
+
+// DefaultFile lists the registries of a project and the pinned checksums of
+// the definitions it uses. It is meant to be committed.
+const DefaultFile = ".ai-team/registries.json"
+
+// Definition kinds, which are also the directories they live in.
+const (
+	KindRole  = "roles"
+	KindChain = "chains"
+)
+
+// Ref is a parsed registry reference.
+type Ref struct {
+	Registry string
+	Name     string
+	Version  string // Git revision; empty means the default branch
+}
+
+// String returns the reference in registry://registry/name@version form.
+func (r Ref) String() string {
+	s := Scheme + r.Registry + "/" + r.Name
+	if r.Version != "" {
+		s += "@" + r.Version
+	}
+	return s
+}
+
+var (
+	refPattern  = regexp.MustCompile(`^registry://([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)(?:@([A-Za-z0-9_./+-]+))?$`)
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// validName reports whether name may name a registry or definition. Names
+// become directories in the cache, so "." and ".." are refused.
+func validName(name string) bool {
+	return namePattern.MatchString(name) && name != "." && name != ".."
+}
+
+// validURL reports whether url may be passed to git clone. URLs starting with
+// "-" would be read as options.
+func validURL(url string) bool {
+	return url != "" && !strings.HasPrefix(url, "-")
+}
+
+// IsRef reports whether s is meant as a registry reference.
+func IsRef(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// ParseRef parses a registry://registry/name[@version] reference.
+func ParseRef(s string) (Ref, error) {
+	m := refPattern.FindStringSubmatch(s)
+	if m == nil || !validName(m[1]) || !validName(m[2]) {
+		return Ref{}, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid registry reference '%s', expected registry://<registry>/<name>@<version>", s), nil)
+	}
+	return Ref{Registry: m[1], Name: m[2], Version: m[3]}, nil
+}
+
+// Source is a registered repository.
+type Source struct {
+	URL string `json:"url"`
+}
+
+// Pin records the checksum of a definition when it was first fetched, so a
+// moved tag or rewritten history is noticed instead of silently used.
+type Pin struct {
+	Commit string `json:"commit"`
+	SHA256 string `json:"sha256"`
+}
+
+type file struct {
+	Registries map[string]Source `json:"registries"`
+	Pins       map[string]Pin    `json:"pins,omitempty"`
+}
+
+// Client reads the registries file and fetches definitions through local
+// clones in CacheDir.
+type Client struct {
+	Path     string
+	CacheDir string
+
+	mu   sync.Mutex
+	data file
+}
+
+// DefaultCacheDir returns where registries are cloned: ai-team/registries in
+// the user cache directory, or .ai-team/registries when there is none.
+func DefaultCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "ai-team", "registries")
+	}
+	return filepath.Join(".ai-team", "registries")
+}
+
+// Open reads the registries file at path (DefaultFile when empty). A missing
+// file is an empty list of registries.
+func Open(path string) (*Client, error) {
+	if path == "" {
+		path = DefaultFile
+	}
+	c := &Client{Path: path, CacheDir: DefaultCacheDir()}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(errors.ErrCodeConfig, "failed to read registries file "+path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &c.data); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, "failed to parse registries file "+path, err)
+		}
+	}
+	if c.data.Registries == nil {
+		c.data.Registries = map[string]Source{}
+	}
+	for name, src := range c.data.Registries {
+		if !validName(name) {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid registry name '%s' in %s", name, path), nil)
+		}
+		if !validURL(src.URL) {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid URL '%s' for registry '%s' in %s", src.URL, name, path), nil)
+		}
+	}
+	if c.data.Pins == nil {
+		c.data.Pins = map[string]Pin{}
+	}
+	return c, nil
+}
+
+// NameFromURL derives a registry name from a git URL, e.g. "community" for
+// https://github.com/acme/community.git.
+func NameFromURL(url string) string {
+	name := path.Base(strings.TrimSuffix(strings.TrimRight(url, "/"), ".git"))
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Add clones the repository at url and registers it as name.
+func (c *Client) Add(name, url string) error {
+	if !validName(name) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid registry name '%s'", name), nil)
+	}
+	if !validURL(url) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid registry URL '%s'", url), nil)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.data.Registries[name]; ok && existing.URL != url {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("registry '%s' is already registered for %s", name, existing.URL), nil)
+	}
+	dir := c.cloneDir(name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := c.clone(url, dir); err != nil {
+			return err
+		}
+	}
+	c.data.Registries[name] = Source{URL: url}
+	return c.save()
+}
+
+// Registries returns the registered repositories by name.
+func (c *Client) Registries() map[string]Source {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]Source, len(c.data.Registries))
+	for name, s := range c.data.Registries {
+		out[name] = s
+	}
+	return out
+}
+
+// Pins returns the pinned references, sorted.
+func (c *Client) Pins() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	refs := make([]string, 0, len(c.data.Pins))
+	for ref := range c.data.Pins {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Update fetches the latest revisions of the named registry and drops its
+// pins, so the next load pins the definitions as they are now.
+func (c *Client) Update(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	src, ok := c.data.Registries[name]
+	if !ok {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("unknown registry '%s'", name), nil)
+	}
+	if err := c.ensureClone(name, src); err != nil {
+		return err
+	}
+	if err := c.fetch(name); err != nil {
+		return err
+	}
+	prefix := Scheme + name + "/"
+	for ref := range c.data.Pins {
+		if strings.HasPrefix(ref, prefix) {
+			delete(c.data.Pins, ref)
+		}
+	}
+	return c.save()
+}
+
+// Fetch returns the YAML definition of kind (KindRole or KindChain) that ref
+// points to. The first fetch of a reference pins its checksum in the
+// registries file; later fetches fail when the content no longer matches.
+func (c *Client) Fetch(kind string, ref Ref) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	src, ok := c.data.Registries[ref.Registry]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("unknown registry '%s' in %s; add it with 'ai-team registry add'", ref.Registry, ref), nil)
+	}
+	if err := c.ensureClone(ref.Registry, src); err != nil {
+		return nil, err
+	}
+	rev := ref.Version
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commit, err := c.resolve(ref.Registry, rev)
+	if err != nil {
+		// The revision may be newer than the clone.
+		if fetchErr := c.fetch(ref.Registry); fetchErr != nil {
+			return nil, fetchErr
+		}
+		if commit, err = c.resolve(ref.Registry, rev); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("registry '%s' has no version '%s'", ref.Registry, ref.Version), err)
+		}
+	}
+	data, err := c.git(ref.Registry, "show", commit+":"+kind+"/"+ref.Name+".yaml")
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("registry '%s' has no %s/%s.yaml at %s", ref.Registry, kind, ref.Name, rev), err)
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	key := ref.String() + " " + kind
+	if pin, ok := c.data.Pins[key]; ok {
+		if pin.SHA256 != checksum {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("%s changed since it was pinned (commit %s, now %s); run 'ai-team registry update %s' to accept the new content", ref, short(pin.Commit), short(commit), ref.Registry), nil)
+		}
+		return data, nil
+	}
+	c.data.Pins[key] = Pin{Commit: commit, SHA256: checksum}
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func short(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func (c *Client) cloneDir(name string) string {
+	return filepath.Join(c.CacheDir, name)
+}
+
+// ensureClone clones a registry missing from the cache, e.g. on a fresh
+// machine that checked out a project with a registries file.
+func (c *Client) ensureClone(name string, src Source) error {
+	dir := c.cloneDir(name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	return c.clone(src.URL, dir)
+}
+
+func (c *Client) clone(url, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to create registry cache "+filepath.Dir(dir), err)
+	}
+	out, err := exec.Command("git", "clone", "--quiet", "--no-checkout", "--", url, dir).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to clone registry %s: %s", url, strings.TrimSpace(string(out))), err)
+	}
+	return nil
+}
+
+func (c *Client) fetch(name string) error {
+	if _, err := c.git(name, "fetch", "--quiet", "--tags", "--force", "origin", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to fetch registry '%s'", name), err)
+	}
+	return nil
+}
+
+// resolve returns the commit of rev, preferring the remote branch of that
+// name over the clone's stale local one.
+func (c *Client) resolve(name, rev string) (string, error) {
+	var err error
+	for _, candidate := range []string{"refs/remotes/origin/" + rev, rev} {
+		var out []byte
+		if out, err = c.git(name, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", err
+}
+
+func (c *Client) git(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", c.cloneDir(name)}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) save() error {
+	data, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to encode registries file", err)
+	}
+	if dir := filepath.Dir(c.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.New(errors.ErrCodeConfig, "failed to create "+dir, err)
+		}
+	}
+	if err := os.WriteFile(c.Path, append(data, '\n'), 0644); err != nil {
+		return errors.New(errors.ErrCodeConfig, "failed to write registries file "+c.Path, err)
+	}
+	return nil
+}
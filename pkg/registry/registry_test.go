@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a git repository with a reviewer role tagged v1.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	git(t, dir, "init", "--quiet", "--initial-branch=main")
+	commitFile(t, dir, "roles/reviewer.yaml", "model_provider: openai\nmodel_name: gpt-4\nprompt: Review {{.code}}\n")
+	git(t, dir, "tag", "v1")
+	return dir
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "update "+name)
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	ref, err := ParseRef("registry://community/reviewer@v1.2")
+	if err != nil || ref != (Ref{Registry: "community", Name: "reviewer", Version: "v1.2"}) {
+		t.Fatalf("got %+v, %v", ref, err)
+	}
+	if ref.String() != "registry://community/reviewer@v1.2" {
+		t.Errorf("unexpected String() %q", ref.String())
+	}
+	for _, bad := range []string{"registry://community", "https://example.com/reviewer", "registry://a/b@", "registry://../reviewer", "registry://a/.."} {
+		if _, err := ParseRef(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if got := NameFromURL("git@github.com:acme/community.git"); got != "community" {
+		t.Errorf("expected community, got %q", got)
+	}
+}
+
+func TestClient_FetchPinsChecksums(t *testing.T) {
+	repo := newRepo(t)
+	work := t.TempDir()
+	client, err := Open(filepath.Join(work, "registries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.CacheDir = filepath.Join(work, "cache")
+	if err := client.Add("community", repo); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	ref := Ref{Registry: "community", Name: "reviewer", Version: "v1"}
+	data, err := client.Fetch(KindRole, ref)
+	if err != nil || !strings.Contains(string(data), "Review {{.code}}") {
+		t.Fatalf("fetch: %q, %v", data, err)
+	}
+	if _, err := client.Fetch(KindChain, ref); err == nil {
+		t.Error("expected error for a missing chain definition")
+	}
+
+	// Moving the tag upstream has no effect until the registry is updated.
+	commitFile(t, repo, "roles/reviewer.yaml", "model_provider: openai\nmodel_name: gpt-4\nprompt: Nitpick {{.code}}\n")
+	git(t, repo, "tag", "--force", "v1")
+	client, err = Open(client.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.CacheDir = filepath.Join(work, "cache")
+	if len(client.Pins()) != 1 {
+		t.Fatalf("expected the pin to be saved, got %v", client.Pins())
+	}
+	if data, err = client.Fetch(KindRole, ref); err != nil || !strings.Contains(string(data), "Review") {
+		t.Fatalf("expected the cached definition, got %q, %v", data, err)
+	}
+	if err := client.Update("community"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if data, err = client.Fetch(KindRole, ref); err != nil || !strings.Contains(string(data), "Nitpick") {
+		t.Fatalf("expected the updated definition, got %q, %v", data, err)
+	}
+
+	// Content that differs from the pin, e.g. after history was rewritten, is refused.
+	key := ref.String() + " " + KindRole
+	pin := client.data.Pins[key]
+	pin.SHA256 = "0000"
+	client.data.Pins[key] = pin
+	if _, err := client.Fetch(KindRole, ref); err == nil || !strings.Contains(err.Error(), "changed since it was pinned") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestClient_FetchUnknownRegistry(t *testing.T) {
+	client, err := Open(filepath.Join(t.TempDir(), "registries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Fetch(KindRole, Ref{Registry: "nope", Name: "reviewer"}); err == nil || !strings.Contains(err.Error(), "registry add") {
+		t.Fatalf("expected an unknown registry error, got %v", err)
+	}
+}
+
+func TestClient_RejectsUnsafeNamesAndURLs(t *testing.T) {
+	dir := t.TempDir()
+	client, err := Open(filepath.Join(dir, "registries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.CacheDir = filepath.Join(dir, "cache")
+	for _, c := range []struct{ name, url string }{
+		{"..", "https://example.com/x.git"},
+		{".", "https://example.com/x.git"},
+		{"x", "--upload-pack=touch " + filepath.Join(dir, "pwned")},
+	} {
+		if err := client.Add(c.name, c.url); err == nil {
+			t.Errorf("expected Add(%q, %q) to be refused", c.name, c.url)
+		}
+	}
+
+	for _, content := range []string{
+		`{"registries": {"..": {"url": "https://example.com/x.git"}}}`,
+		`{"registries": {"x": {"url": "--upload-pack=touch pwned"}}}`,
+	} {
+		path := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(path); err == nil {
+			t.Errorf("expected Open to refuse %s", content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Errorf("expected the URL never to reach git as an option")
+	}
+}
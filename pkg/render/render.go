@@ -0,0 +1,292 @@
+// Package render styles terminal output with the configured theme. Colors are
+// left out when NO_COLOR is set, the theme mode is "none" or the output is
+// not a terminal, so rendered text can always be printed as is.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Element is a kind of output the theme assigns a color to.
+type Element string
+
+// Themed elements. Their names are the keys of theme.colors in the config.
+const (
+	DiffAdded   Element = "diff_added"
+	DiffRemoved Element = "diff_removed"
+	DiffHeader  Element = "diff_header" // ---/+++ file lines
+	DiffHunk    Element = "diff_hunk"   // @@ hunk headers
+	JSONKey     Element = "json_key"
+	JSONString  Element = "json_string"
+	JSONLiteral Element = "json_literal" // Numbers, booleans and null
+	Role        Element = "role"
+	Error       Element = "error"
+	Status      Element = "status" // Status line of interactive sessions
+)
+
+// Theme modes.
+const (
+	ModeAuto  = "auto"
+	ModeDark  = "dark"
+	ModeLight = "light"
+	ModeNone  = "none"
+)
+
+// Built-in palettes for dark and light terminal backgrounds.
+var palettes = map[string]map[Element]string{
+	ModeDark: {
+		DiffAdded:   "green",
+		DiffRemoved: "red",
+		DiffHeader:  "bold",
+		DiffHunk:    "cyan",
+		JSONKey:     "bright-blue",
+		JSONString:  "green",
+		JSONLiteral: "yellow",
+		Role:        "bold magenta",
+		Error:       "bold red",
+		Status:      "faint",
+	},
+	ModeLight: {
+		DiffAdded:   "green",
+		DiffRemoved: "red",
+		DiffHeader:  "bold",
+		DiffHunk:    "blue",
+		JSONKey:     "blue",
+		JSONString:  "green",
+		JSONLiteral: "magenta",
+		Role:        "bold magenta",
+		Error:       "bold red",
+		Status:      "faint",
+	},
+}
+
+var colorCodes = map[string]int{
+	"black": 30, "red": 31, "green": 32, "yellow": 33,
+	"blue": 34, "magenta": 35, "cyan": 36, "white": 37, "gray": 90,
+}
+
+var attributeCodes = map[string]int{
+	"bold": 1, "faint": 2, "italic": 3, "underline": 4,
+}
+
+// Theme is a resolved theme: the SGR escape parameters of each element.
+type Theme struct {
+	Mode  string // ModeDark, ModeLight or ModeNone
+	codes map[Element]string
+}
+
+// NewTheme resolves a theme config. In ModeAuto (or an empty mode) the
+// palette follows the terminal background reported by COLORFGBG.
+func NewTheme(cfg types.ThemeConfig) (*Theme, error) {
+	mode := cfg.Mode
+	switch mode {
+	case "", ModeAuto:
+		mode = Detect()
+	case ModeDark, ModeLight, ModeNone:
+	default:
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("theme.mode must be %s, %s, %s or %s, got '%s'", ModeAuto, ModeDark, ModeLight, ModeNone, cfg.Mode), nil)
+	}
+	theme := &Theme{Mode: mode, codes: map[Element]string{}}
+	palette := palettes[mode]
+	if palette == nil {
+		palette = palettes[ModeDark] // Colors of "none" are still validated
+	}
+	colors := map[Element]string{}
+	for element, color := range palette {
+		colors[element] = color
+	}
+	for name, color := range cfg.Colors {
+		element := Element(name)
+		if _, ok := palettes[ModeDark][element]; !ok {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("theme.colors: unknown element '%s' (expected one of %s)", name, strings.Join(elementNames(), ", ")), nil)
+		}
+		colors[element] = color
+	}
+	for element, color := range colors {
+		code, err := ParseColor(color)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, "invalid theme.colors."+string(element), err)
+		}
+		theme.codes[element] = code
+	}
+	return theme, nil
+}
+
+func elementNames() []string {
+	var names []string
+	for element := range palettes[ModeDark] {
+		names = append(names, string(element))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColor returns the SGR parameters of a color such as "red",
+// "bold bright-blue", "208" (a 256-color index) or "#ff8800". An empty color
+// means plain text.
+func ParseColor(color string) (string, error) {
+	var params []string
+	for _, word := range strings.Fields(strings.ToLower(color)) {
+		if code, ok := attributeCodes[word]; ok {
+			params = append(params, strconv.Itoa(code))
+			continue
+		}
+		if code, ok := colorCodes[strings.TrimPrefix(word, "bright-")]; ok {
+			if strings.HasPrefix(word, "bright-") && code < 90 {
+				code += 60
+			}
+			params = append(params, strconv.Itoa(code))
+			continue
+		}
+		if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+			params = append(params, "38;5;"+word)
+			continue
+		}
+		if len(word) == 7 && word[0] == '#' {
+			if rgb, err := strconv.ParseUint(word[1:], 16, 32); err == nil {
+				params = append(params, fmt.Sprintf("38;2;%d;%d;%d", rgb>>16, rgb>>8&0xff, rgb&0xff))
+				continue
+			}
+		}
+		return "", fmt.Errorf("invalid color '%s'", word)
+	}
+	return strings.Join(params, ";"), nil
+}
+
+// Detect returns ModeLight when COLORFGBG reports a light background and
+// ModeDark otherwise.
+func Detect() string {
+	fields := strings.Split(os.Getenv("COLORFGBG"), ";")
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err == nil && (bg == 7 || bg >= 9 && bg <= 15) {
+		return ModeLight
+	}
+	return ModeDark
+}
+
+var (
+	mu      sync.RWMutex
+	current = defaultTheme()
+)
+
+func defaultTheme() *Theme {
+	theme, _ := NewTheme(types.ThemeConfig{})
+	return theme
+}
+
+// SetTheme selects the theme used by Stdout and Stderr.
+func SetTheme(theme *Theme) {
+	mu.Lock()
+	current = theme
+	mu.Unlock()
+}
+
+// Renderer styles text for one output.
+type Renderer struct {
+	Theme *Theme
+	Color bool // Whether escape sequences are written at all
+}
+
+// For returns a renderer for f using the selected theme. It writes colors
+// only when f is a terminal, NO_COLOR is unset and the mode is not "none".
+func For(f *os.File) Renderer {
+	mu.RLock()
+	theme := current
+	mu.RUnlock()
+	return Renderer{Theme: theme, Color: theme.Mode != ModeNone && isTerminal(f)}
+}
+
+// Stdout returns the renderer for standard output.
+func Stdout() Renderer { return For(os.Stdout) }
+
+// Stderr returns the renderer for standard error.
+func Stderr() Renderer { return For(os.Stderr) }
+
+func isTerminal(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Paint returns text in the color of element.
+func (r Renderer) Paint(element Element, text string) string {
+	if !r.Color || r.Theme == nil || r.Theme.codes[element] == "" {
+		return text
+	}
+	return "\033[" + r.Theme.codes[element] + "m" + text + "\033[0m"
+}
+
+// Diff colors the lines of a unified diff.
+func (r Renderer) Diff(diff string) string {
+	if !r.Color {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = r.Paint(DiffHeader, line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = r.Paint(DiffAdded, line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = r.Paint(DiffRemoved, line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = r.Paint(DiffHunk, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JSON returns obj as indented JSON with keys, strings and literals colored.
+func (r Renderer) JSON(obj interface{}) (string, error) {
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if !r.Color {
+		return string(b), nil
+	}
+	var out bytes.Buffer
+	for i := 0; i < len(b); {
+		switch c := b[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(b) && b[end] != '"' {
+				if b[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			element := JSONString
+			if rest := bytes.TrimLeft(b[end:], " "); len(rest) > 0 && rest[0] == ':' {
+				element = JSONKey
+			}
+			out.WriteString(r.Paint(element, string(b[i:end])))
+			i = end
+		case c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z':
+			end := i
+			for end < len(b) && !strings.ContainsRune(",]} \n", rune(b[end])) {
+				end++
+			}
+			out.WriteString(r.Paint(JSONLiteral, string(b[i:end])))
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String(), nil
+}
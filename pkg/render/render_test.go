@@ -0,0 +1,111 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func colored(t *testing.T, cfg types.ThemeConfig) Renderer {
+	t.Helper()
+	theme, err := NewTheme(cfg)
+	if err != nil {
+		t.Fatalf("NewTheme: %v", err)
+	}
+	return Renderer{Theme: theme, Color: true}
+}
+
+func TestParseColor(t *testing.T) {
+	for color, want := range map[string]string{
+		"red":              "31",
+		"bold bright-blue": "1;94",
+		"208":              "38;5;208",
+		"#ff8800":          "38;2;255;136;0",
+		"":                 "",
+	} {
+		got, err := ParseColor(color)
+		if err != nil || got != want {
+			t.Errorf("ParseColor(%q) = %q, %v; want %q", color, got, err, want)
+		}
+	}
+	for _, color := range []string{"purple", "256", "#ff88"} {
+		if _, err := ParseColor(color); err == nil {
+			t.Errorf("ParseColor(%q): expected an error", color)
+		}
+	}
+}
+
+func TestNewTheme(t *testing.T) {
+	r := colored(t, types.ThemeConfig{Mode: ModeDark, Colors: map[string]string{"role": "underline cyan"}})
+	if got := r.Paint(Role, "coder"); got != "\033[4;36mcoder\033[0m" {
+		t.Errorf("Paint = %q", got)
+	}
+	r = colored(t, types.ThemeConfig{Mode: ModeLight})
+	if got := r.Paint(JSONLiteral, "1"); got != "\033[35m1\033[0m" {
+		t.Errorf("light Paint = %q", got)
+	}
+	if _, err := NewTheme(types.ThemeConfig{Mode: "sepia"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if _, err := NewTheme(types.ThemeConfig{Colors: map[string]string{"prompt": "red"}}); err == nil {
+		t.Error("expected an error for an unknown element")
+	}
+	if _, err := NewTheme(types.ThemeConfig{Colors: map[string]string{"error": "purple"}}); err == nil {
+		t.Error("expected an error for an invalid color")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for value, want := range map[string]string{"": ModeDark, "15;0": ModeDark, "0;15": ModeLight, "0;default;7": ModeLight, "7;8": ModeDark} {
+		t.Setenv("COLORFGBG", value)
+		if got := Detect(); got != want {
+			t.Errorf("COLORFGBG=%q: Detect() = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestRenderer_NoColor(t *testing.T) {
+	r := Renderer{Theme: defaultTheme()}
+	if got := r.Diff("+a\n-b"); got != "+a\n-b" {
+		t.Errorf("Diff = %q", got)
+	}
+	got, err := r.JSON(map[string]int{"a": 1})
+	if err != nil || got != "{\n  \"a\": 1\n}" {
+		t.Errorf("JSON = %q, %v", got, err)
+	}
+
+	theme, _ := NewTheme(types.ThemeConfig{Mode: ModeNone})
+	SetTheme(theme)
+	defer SetTheme(defaultTheme())
+	if Stdout().Color {
+		t.Error("mode none must disable colors")
+	}
+}
+
+func TestRenderer_Diff(t *testing.T) {
+	r := colored(t, types.ThemeConfig{Mode: ModeDark})
+	got := r.Diff("--- a\n+++ b\n@@ -1 +1 @@\n same\n-old\n+new\n")
+	want := "\033[1m--- a\033[0m\n\033[1m+++ b\033[0m\n\033[36m@@ -1 +1 @@\033[0m\n same\n\033[31m-old\033[0m\n\033[32m+new\033[0m\n"
+	if got != want {
+		t.Errorf("Diff =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRenderer_JSON(t *testing.T) {
+	r := colored(t, types.ThemeConfig{Mode: ModeDark})
+	got, err := r.JSON(map[string]interface{}{"name": "a \"b\": c", "n": -1.5, "ok": true, "x": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\033[94m\"name\"\033[0m: \033[32m\"a \\\"b\\\": c\"\033[0m",
+		"\033[94m\"n\"\033[0m: \033[33m-1.5\033[0m,",
+		"\033[33mtrue\033[0m",
+		"\033[33mnull\033[0m",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("JSON misses %q:\n%q", want, got)
+		}
+	}
+}
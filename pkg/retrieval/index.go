@@ -0,0 +1,261 @@
+// Package retrieval keeps an embeddings index of repository files, so chain
+// steps can put the parts of the repository closest to their task into the
+// prompt.
+package retrieval
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/pkg/cache"
+	"ai-team/pkg/errors"
+)
+
+// DefaultPath is the file the index is kept in when no retrieval.index is
+// configured.
+const DefaultPath = ".ai-team/index.json"
+
+// Defaults of Options and of the number of matches a search returns.
+const (
+	DefaultChunkLines  = 60
+	DefaultMaxFileSize = 100000
+	DefaultTopK        = 5
+)
+
+// Options controls how Sync splits files.
+type Options struct {
+	ChunkLines  int   // Lines per chunk (default DefaultChunkLines)
+	MaxFileSize int64 // Larger files are left out (default DefaultMaxFileSize)
+}
+
+// Chunk is a run of lines of a file with its embedding.
+type Chunk struct {
+	Start  int       `json:"start"` // First line, from 1
+	End    int       `json:"end"`   // Last line
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// File is an indexed file. Size and ModTime tell whether it needs to be
+// read again, Hash whether it needs to be embedded again.
+type File struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"` // SHA-256 of the content
+	Chunks  []Chunk   `json:"chunks"`
+}
+
+// Match is a chunk found by Search.
+type Match struct {
+	Path  string  `json:"path"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"` // Cosine similarity to the query
+}
+
+// Stats counts the files a Sync changed in the index.
+type Stats struct {
+	Embedded int // Files new or changed since the last sync
+	Removed  int // Files no longer present
+}
+
+// Index maps slash-separated paths to their embedded chunks. Vectors of
+// different embedding models cannot be compared, so an index loaded for
+// another model starts empty.
+type Index struct {
+	Path string `json:"-"` // Optional JSON file the index is persisted to
+
+	Model string           `json:"model"`
+	Files map[string]*File `json:"files"`
+
+	mu sync.Mutex
+}
+
+// Load reads the index persisted at path for embeddings of model. A missing
+// file, or one written for another model, gives an empty index.
+func Load(path, model string) (*Index, error) {
+	x := &Index{Path: path, Model: model, Files: map[string]*File{}}
+	if path == "" {
+		return x, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to read index %s", path), err)
+	}
+	var stored Index
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to parse index %s", path), err)
+	}
+	if stored.Model == model && stored.Files != nil {
+		x.Files = stored.Files
+	}
+	return x, nil
+}
+
+// Sync brings the index up to date with paths, relative to root: files new or
+// changed since the last sync are embedded, files not among paths dropped.
+// Binary files and files above opts.MaxFileSize are left out. The index is
+// saved when it changed.
+func (x *Index) Sync(root string, paths []string, embedder cache.Embedder, opts Options) (Stats, error) {
+	if opts.ChunkLines <= 0 {
+		opts.ChunkLines = DefaultChunkLines
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var stats Stats
+	present := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil || info.IsDir() || info.Size() > opts.MaxFileSize {
+			continue
+		}
+		present[path] = true
+		old := x.Files[path]
+		if old != nil && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			delete(present, path)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		f := &File{Size: info.Size(), ModTime: info.ModTime(), Hash: hex.EncodeToString(sum[:])}
+		if old != nil && old.Hash == f.Hash {
+			f.Chunks = old.Chunks
+			x.Files[path] = f
+			continue
+		}
+		for _, c := range split(string(data), opts.ChunkLines) {
+			vec, err := embedder.Embed(path + "\n" + c.Text)
+			if err != nil {
+				if saveErr := x.saveLocked(); saveErr != nil {
+					return stats, saveErr
+				}
+				return stats, errors.New(errors.ErrCodeAPI, fmt.Sprintf("failed to embed %s", path), err)
+			}
+			c.Vector = vec
+			f.Chunks = append(f.Chunks, c)
+		}
+		x.Files[path] = f
+		stats.Embedded++
+	}
+	for path := range x.Files {
+		if !present[path] {
+			delete(x.Files, path)
+			stats.Removed++
+		}
+	}
+	if stats == (Stats{}) {
+		return stats, nil
+	}
+	return stats, x.saveLocked()
+}
+
+// split cuts text into chunks of up to lines lines, leaving out blank ones.
+func split(text string, lines int) []Chunk {
+	all := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(all); start += lines {
+		end := start + lines
+		if end > len(all) {
+			end = len(all)
+		}
+		body := strings.Join(all[start:end], "\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{Start: start + 1, End: end, Text: body})
+	}
+	return chunks
+}
+
+// Search returns the k chunks most similar to the query vector, best first.
+func (x *Index) Search(query []float32, k int) []Match {
+	if k <= 0 {
+		k = DefaultTopK
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var matches []Match
+	for path, f := range x.Files {
+		for _, c := range f.Chunks {
+			matches = append(matches, Match{Path: path, Start: c.Start, End: c.End, Text: c.Text, Score: cache.CosineSimilarity(query, c.Vector)})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Start < matches[j].Start
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// Len returns the number of indexed files.
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.Files)
+}
+
+func (x *Index) saveLocked() error {
+	if x.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to encode index", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(x.Path), 0755); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to create index directory %s", filepath.Dir(x.Path)), err)
+	}
+	temp := x.Path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write index %s", x.Path), err)
+	}
+	if err := os.Rename(temp, x.Path); err != nil {
+		os.Remove(temp)
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write index %s", x.Path), err)
+	}
+	return nil
+}
+
+// Format renders matches for a prompt: each chunk under a header naming its
+// file and lines.
+func Format(matches []Match) string {
+	var b strings.Builder
+	for i, m := range matches {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "--- %s:%d-%d\n%s\n", m.Path, m.Start, m.End, m.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package retrieval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wordEmbedder counts a few words, so texts about the same word are similar.
+type wordEmbedder struct {
+	calls int
+}
+
+func (e *wordEmbedder) Embed(text string) ([]float32, error) {
+	e.calls++
+	vec := []float32{0.01, 0.01, 0.01}
+	for i, word := range []string{"parser", "network", "database"} {
+		vec[i] += float32(strings.Count(text, word))
+	}
+	return vec, nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndex_SyncAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "parse.go", "the parser\nreads tokens\n")
+	writeFile(t, dir, "net/client.go", "network client\n\n\nnetwork retry\n")
+	writeFile(t, dir, "image.png", "\x89PNG\x00\x00")
+	paths := []string{"parse.go", "net/client.go", "image.png"}
+	indexPath := filepath.Join(dir, "index", "index.json")
+
+	x, err := Load(indexPath, "openai/small")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := &wordEmbedder{}
+	stats, err := x.Sync(dir, paths, e, Options{ChunkLines: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Embedded != 2 || x.Len() != 2 {
+		t.Fatalf("expected 2 text files indexed, got %+v and %d files", stats, x.Len())
+	}
+	if chunks := x.Files["net/client.go"].Chunks; len(chunks) != 2 || chunks[1].Start != 3 || chunks[1].End != 4 {
+		t.Errorf("expected the chunk of blank lines left out, got %+v", chunks)
+	}
+
+	query, _ := e.Embed("network")
+	matches := x.Search(query, 1)
+	if len(matches) != 1 || matches[0].Path != "net/client.go" {
+		t.Fatalf("expected the network chunk, got %+v", matches)
+	}
+	if got := Format(matches); !strings.HasPrefix(got, "--- net/client.go:1-2\nnetwork client\n") {
+		t.Errorf("unexpected format %q", got)
+	}
+
+	calls := e.calls
+	if stats, _ := x.Sync(dir, paths, e, Options{ChunkLines: 2}); stats != (Stats{}) || e.calls != calls {
+		t.Errorf("expected unchanged files not to be embedded again, got %+v and %d calls", stats, e.calls-calls)
+	}
+
+	writeFile(t, dir, "parse.go", "the database\n")
+	os.Chtimes(filepath.Join(dir, "parse.go"), time.Now(), time.Now().Add(time.Minute))
+	stats, err = x.Sync(dir, []string{"parse.go"}, e, Options{ChunkLines: 2})
+	if err != nil || stats.Embedded != 1 || stats.Removed != 1 {
+		t.Fatalf("expected parse.go embedded again and client.go removed, got %+v, %v", stats, err)
+	}
+
+	loaded, err := Load(indexPath, "openai/small")
+	if err != nil || loaded.Len() != 1 || loaded.Files["parse.go"].Chunks[0].Text != "the database" {
+		t.Errorf("expected the saved index to be loaded, got %+v, %v", loaded.Files, err)
+	}
+	if other, _ := Load(indexPath, "ollama/nomic"); other.Len() != 0 {
+		t.Error("expected the index of another model to be empty")
+	}
+}
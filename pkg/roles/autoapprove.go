@@ -0,0 +1,92 @@
+package roles
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// GitSnapshotFunc takes the git snapshot before the first auto-approved change.
+// It can be replaced in tests for mocking.
+var GitSnapshotFunc = tools.GitSnapshot
+
+// isDestructiveCall reports whether a tool call modifies files, runs commands,
+// changes the git history or branch, or sends an HTTP request other than GET
+// or HEAD.
+func isDestructiveCall(toolCall *types.ToolCall) bool {
+	switch toolCall.Name {
+	case "write_file", "WriteFile", "apply_patch", "ApplyPatch", "end_file", "run_command", "RunCommand", "git_commit", "git_checkout":
+		return true
+	case "http_request":
+		method, _ := toolCall.Arguments["method"].(string)
+		switch strings.ToUpper(method) {
+		case "", "GET", "HEAD":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// canAutoApprove applies the --yes guardrails, including the guardrail role,
+// to a tool call. Calls it rejects fall back to the interactive menu.
+func (session *Session) canAutoApprove(toolCall *types.ToolCall) bool {
+	return session.Yes && session.autoApproveGuardrails(toolCall)
+}
+
+// autoApproveGuardrails applies the checks of auto_approve to a call that is
+// to run without asking, whether --yes or the policy allowed it: refused
+// commands, the budget of destructive calls, the guardrail role and the git
+// snapshot.
+func (session *Session) autoApproveGuardrails(toolCall *types.ToolCall) bool {
+	if !isDestructiveCall(toolCall) {
+		return true
+	}
+	var policy types.AutoApproveConfig
+	if session.Config != nil {
+		policy = session.Config.AutoApprove
+	}
+
+	if command, ok := toolCall.Arguments["command"].(string); ok {
+		for _, pattern := range policy.RefuseCommands {
+			if matched, _ := regexp.MatchString(pattern, command); matched {
+				fmt.Printf("Not auto-approving command matching %q; manual approval required.\n", pattern)
+				return false
+			}
+		}
+	}
+	if policy.MaxDestructive > 0 && session.autoApproved >= policy.MaxDestructive {
+		fmt.Printf("Auto-approve budget of %d changes used up; manual approval required.\n", policy.MaxDestructive)
+		return false
+	}
+	if guard := newGuardrail(session.Config, session.RunID); guard != nil {
+		guard.at("interactive", session.inputs)
+		if err := guard.review(context.Background(), tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments}); err != nil {
+			fmt.Printf("Not auto-approving: %v; manual approval required.\n", err)
+			return false
+		}
+	}
+	if policy.Snapshot && session.snapshotRef == "" && !session.DryRun {
+		ref, sha, err := GitSnapshotFunc("before auto-approved changes")
+		if err != nil {
+			fmt.Printf("Could not take git snapshot (%v); manual approval required.\n", err)
+			return false
+		}
+		session.snapshotRef = ref
+		fmt.Printf("Git snapshot saved as %s (restore with: git stash apply %s)\n", ref, sha)
+	}
+	session.autoApproved++
+	return true
+}
+
+// confirmUnlessAuto asks the user to confirm unless the call was auto-approved.
+func confirmUnlessAuto(session *Session, autoApprove bool, prompt string) (bool, error) {
+	if autoApprove {
+		return true, nil
+	}
+	return session.UI.Confirm(prompt)
+}
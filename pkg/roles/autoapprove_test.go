@@ -0,0 +1,157 @@
+package roles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+func TestCanAutoApprove_Guardrails(t *testing.T) {
+	snapshots := 0
+	orig := GitSnapshotFunc
+	GitSnapshotFunc = func(label string) (string, string, error) {
+		snapshots++
+		return "refs/ai-team/snapshots/test", "abc123", nil
+	}
+	defer func() { GitSnapshotFunc = orig }()
+
+	session := &Session{Yes: true, Config: &config.Config{AutoApprove: types.AutoApproveConfig{
+		MaxDestructive: 2,
+		Snapshot:       true,
+		RefuseCommands: []string{`\bgit\s+push\b`},
+	}}}
+	write := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a", "content": "x"}}
+	read := &types.ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": "a"}}
+	push := &types.ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "git push origin main"}}
+
+	captureOutput(func() {
+		if session.canAutoApprove(push) {
+			t.Errorf("expected refused command to need manual approval")
+		}
+		if !session.canAutoApprove(write) || !session.canAutoApprove(write) {
+			t.Errorf("expected writes within budget to be auto-approved")
+		}
+		if session.canAutoApprove(write) {
+			t.Errorf("expected write over budget to need manual approval")
+		}
+		if !session.canAutoApprove(read) {
+			t.Errorf("expected read-only call to be auto-approved regardless of budget")
+		}
+	})
+	if snapshots != 1 || session.snapshotRef == "" {
+		t.Errorf("expected exactly one snapshot before the first change, got %d", snapshots)
+	}
+}
+
+func TestCanAutoApprove_SnapshotFailureNeedsApproval(t *testing.T) {
+	orig := GitSnapshotFunc
+	GitSnapshotFunc = func(label string) (string, string, error) { return "", "", fmt.Errorf("not a git repository") }
+	defer func() { GitSnapshotFunc = orig }()
+
+	session := &Session{Yes: true, Config: &config.Config{AutoApprove: types.AutoApproveConfig{Snapshot: true}}}
+	write := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a", "content": "x"}}
+	captureOutput(func() {
+		if session.canAutoApprove(write) {
+			t.Errorf("expected change without snapshot to need manual approval")
+		}
+	})
+	if (&Session{}).canAutoApprove(write) {
+		t.Errorf("expected no auto-approval without --yes")
+	}
+}
+
+func TestHandleToolCall_Policy(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: deny\nrules:\n  - tool: write_file\n    args: {file_path: 'allowed'}\n    action: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origExec := ExecuteRoleFunc
+	ExecuteRoleFunc = func(role types.Role, input map[string]interface{}, cfg *config.Config, logFilePath string) (string, error) {
+		return "done", nil
+	}
+	defer func() { ExecuteRoleFunc = origExec }()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	prompts := 0
+	ui := &MockUI{
+		ConfirmFunc:      func(string) (bool, error) { prompts++; return false, nil },
+		PromptSelectFunc: func([]string) (string, error) { prompts++; return "Reject", nil },
+	}
+	dir := t.TempDir()
+	denied := filepath.Join(dir, "denied.txt")
+	allowed := filepath.Join(dir, "allowed.txt")
+
+	for _, file := range []string{denied, allowed} {
+		session := &Session{UI: ui, Policy: policy, Yes: true, MaxIterations: 1, Transcript: &types.Transcript{}}
+		call := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": file, "content": "x"}}
+		captureOutput(func() {
+			handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+		})
+	}
+
+	if _, err := os.Stat(denied); !os.IsNotExist(err) {
+		t.Errorf("expected denied write not to happen despite --yes")
+	}
+	if _, err := os.Stat(allowed); err != nil {
+		t.Errorf("expected allowed write to happen: %v", err)
+	}
+	if prompts != 0 {
+		t.Errorf("expected no prompts for allow/deny decisions, got %d", prompts)
+	}
+}
+
+func TestHandleToolCall_PolicyAllowKeepsGuardrails(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: deny\nrules:\n  - tool: '*'\n    action: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origExec := ExecuteRoleFunc
+	ExecuteRoleFunc = func(role types.Role, input map[string]interface{}, cfg *config.Config, logFilePath string) (string, error) {
+		return "done", nil
+	}
+	defer func() { ExecuteRoleFunc = origExec }()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	prompts := 0
+	ui := &MockUI{
+		ConfirmFunc:      func(string) (bool, error) { prompts++; return false, nil },
+		PromptSelectFunc: func([]string) (string, error) { prompts++; return "Reject", nil },
+	}
+	cfg := &config.Config{AutoApprove: types.AutoApproveConfig{MaxDestructive: 1, RefuseCommands: []string{`\bgit\s+push\b`}}}
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+
+	session := &Session{UI: ui, Policy: policy, Config: cfg, MaxIterations: 1, Transcript: &types.Transcript{}}
+	calls := []*types.ToolCall{
+		{Name: "run_command", Arguments: map[string]interface{}{"command": "git push origin main"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"file_path": first, "content": "x"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"file_path": second, "content": "x"}},
+	}
+	captureOutput(func() {
+		for _, call := range calls {
+			handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+		}
+	})
+
+	if prompts != 2 {
+		t.Errorf("expected the refused command and the write over budget to be asked about, got %d prompts", prompts)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("expected the write within budget to happen: %v", err)
+	}
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("expected the rejected write over budget not to happen")
+	}
+}
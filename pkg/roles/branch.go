@@ -0,0 +1,54 @@
+package roles
+
+import (
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// maxJumps bounds the goto and on_error jumps of one chain run, so steps
+// that jump to each other cannot run forever.
+const maxJumps = 100
+
+// stepWhen reports whether the when condition of step holds in context. It
+// is evaluated like loop_condition; a step without one always runs.
+func stepWhen(stepIndex int, step types.ChainRole, context map[string]interface{}) (bool, error) {
+	if step.When == "" {
+		return true, nil
+	}
+	ok, err := evaluateLoopCondition(step.When, context)
+	if err != nil {
+		return false, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): failed to evaluate when", stepIndex+1, step.Key()), err)
+	}
+	return ok, nil
+}
+
+// stepGoto renders the goto template of step over context. An empty result
+// means the chain goes on with the next step.
+func stepGoto(stepIndex int, step types.ChainRole, context map[string]interface{}) (string, error) {
+	if step.Goto == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("goto").Parse(step.Goto)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): invalid goto", stepIndex+1, step.Key()), err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, context); err != nil {
+		return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): failed to evaluate goto", stepIndex+1, step.Key()), err)
+	}
+	target := strings.TrimSpace(buf.String())
+	if target == "<no value>" {
+		target = ""
+	}
+	return target, nil
+}
+
+// recordStepError stores why step failed under step_error in context, for
+// the step on_error jumps to.
+func recordStepError(context map[string]interface{}, step types.ChainRole, err error) {
+	context["step_error"] = map[string]interface{}{"step": step.Key(), "error": err.Error()}
+}
@@ -0,0 +1,97 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// branchChainConfig mocks the model to answer each role with its prompt,
+// except tester, which reports the next of results. It records the prompts.
+func branchChainConfig(t *testing.T, prompts *[]string, results ...string) {
+	origCallGemini := ai.CallGeminiFunc
+	t.Cleanup(func() { ai.CallGeminiFunc = origCallGemini })
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		*prompts = append(*prompts, prompt)
+		if prompt == "test" && len(results) > 0 {
+			result := results[0]
+			results = results[1:]
+			return result, nil
+		}
+		return prompt + " done", nil
+	}
+}
+
+func TestExecuteChain_WhenAndGoto(t *testing.T) {
+	var prompts []string
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	branchChainConfig(t, &prompts, "fail", "pass")
+	for _, name := range []string{"test", "fix", "report"} {
+		cfg.Roles[name] = types.Role{Provider: "gemini", Model: "flash", Prompt: name}
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "test", OutputKey: "tests"},
+		{Role: "fix", When: "{{.tests}} == 'fail'", Goto: "test"},
+		{Role: "report"},
+	}}
+	ctx, err := ExecuteChain(chain, nil, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(prompts, ","); got != "test,fix,test,report" {
+		t.Errorf("steps ran in order %s", got)
+	}
+	if ctx["tests"] != "pass" {
+		t.Errorf("tests = %v", ctx["tests"])
+	}
+}
+
+func TestExecuteChain_OnErrorJump(t *testing.T) {
+	var prompts []string
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	branchChainConfig(t, &prompts)
+	for _, name := range []string{"build", "fix", "deploy"} {
+		cfg.Roles[name] = types.Role{Provider: "gemini", Model: "flash", Prompt: name}
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "build", After: []types.StepHook{{Command: "exit 3"}}, OnError: "fixer"},
+		{Role: "deploy"},
+		{Name: "fixer", Role: "fix", Goto: "end"},
+		{Role: "deploy", Name: "never"},
+	}}
+	ctx, err := ExecuteChain(chain, nil, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(prompts, ","); got != "build,fix" {
+		t.Errorf("steps ran in order %s", got)
+	}
+	stepErr, _ := ctx["step_error"].(map[string]interface{})
+	if stepErr["step"] != "build" || !strings.Contains(stepErr["error"].(string), "after hook failed") {
+		t.Errorf("step_error = %v", ctx["step_error"])
+	}
+}
+
+func TestExecuteChain_GotoLimit(t *testing.T) {
+	var prompts []string
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	branchChainConfig(t, &prompts)
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Goto: "{{if .forever}}coder{{end}}"}}}
+	_, err := ExecuteChain(chain, map[string]interface{}{"forever": true}, cfg, "")
+	if err == nil || !strings.Contains(err.Error(), "jumps between steps") {
+		t.Fatalf("expected the jump limit to stop the chain, got %v", err)
+	}
+	if len(prompts) != maxJumps+1 {
+		t.Errorf("expected %d runs of the step, got %d", maxJumps+1, len(prompts))
+	}
+
+	prompts = nil
+	if _, err := ExecuteChain(chain, map[string]interface{}{"forever": false}, cfg, ""); err != nil || len(prompts) != 1 {
+		t.Fatalf("expected an empty goto to go on in order, got %v after %d runs", err, len(prompts))
+	}
+}
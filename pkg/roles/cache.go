@@ -0,0 +1,82 @@
+package roles
+
+import (
+	"ai-team/config"
+	ai "ai-team/pkg/ai"
+	"ai-team/pkg/cache"
+	"net/http"
+	"sync"
+
+	"ai-team/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	semanticCacheMu sync.Mutex
+	semanticCaches  = map[string]*cache.SemanticCache{}
+)
+
+// NewEmbedderFunc builds the embedder used by the semantic cache and
+// near-duplicate tool call detection.
+// It can be replaced in tests for mocking.
+var NewEmbedderFunc = func(cfg *config.Config) cache.Embedder {
+	return &ai.OpenAIEmbedder{
+		Client: ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions("openai", "")),
+		APIURL: cfg.OpenAI.DefaultApiurl,
+		APIKey: cfg.OpenAI.Apikey,
+		Model:  cfg.Cache.Semantic.EmbeddingModel,
+	}
+}
+
+// semanticCacheFor returns the process-wide semantic cache for cfg, or nil when
+// semantic caching is disabled or the cache cannot be loaded.
+func semanticCacheFor(cfg *config.Config) *cache.SemanticCache {
+	if cfg == nil || !cfg.Cache.Semantic.Enabled {
+		return nil
+	}
+	semanticCacheMu.Lock()
+	defer semanticCacheMu.Unlock()
+	path := cfg.Cache.Semantic.Path
+	if sc, ok := semanticCaches[path]; ok {
+		return sc
+	}
+	sc, err := cache.NewSemanticCache(NewEmbedderFunc(cfg), cfg.Cache.Semantic.Threshold, path)
+	if err != nil {
+		logrus.Warnf("Semantic cache disabled: %v", err)
+		return nil
+	}
+	semanticCaches[path] = sc
+	return sc
+}
+
+// lookupSemanticCache returns a cached response for prompt if one is close enough.
+// The computed embedding is returned for a later storeSemanticCache call.
+func lookupSemanticCache(cfg *config.Config, scope, prompt string) (string, bool, []float32) {
+	sc := semanticCacheFor(cfg)
+	if sc == nil {
+		return "", false, nil
+	}
+	entry, score, vec, err := sc.Lookup(scope, prompt)
+	if err != nil {
+		logrus.Warnf("Semantic cache lookup failed: %v", err)
+		return "", false, nil
+	}
+	if entry == nil {
+		logger.DebugPrintf("Semantic cache miss for %s (best score %.3f)", scope, score)
+		return "", false, vec
+	}
+	logrus.Infof("Semantic cache hit for %s (similarity %.3f)", scope, score)
+	return entry.Response, true, vec
+}
+
+// storeSemanticCache records a successful response in the semantic cache.
+func storeSemanticCache(cfg *config.Config, scope, prompt, response string, vec []float32) {
+	sc := semanticCacheFor(cfg)
+	if sc == nil {
+		return
+	}
+	if err := sc.Store(scope, prompt, response, vec); err != nil {
+		logrus.Warnf("Failed to store semantic cache entry: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package roles
+
+import (
+	"ai-team/pkg/cli"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChainInteraction steers a chain run step by step, see
+// ChainOptions.Interactive.
+type ChainInteraction interface {
+	// BeforeStep is called before each step that runs, with the context the
+	// step will see, which it may change. It reports whether to skip the step.
+	BeforeStep(index int, step string, context map[string]interface{}) (bool, error)
+	// ReviewInput is called before each iteration of step with the resolved
+	// input the role will see. It returns the input to use.
+	ReviewInput(step string, iteration int, input map[string]interface{}) (map[string]interface{}, error)
+	// ReviewToolCall is called before a tool call of step runs. It returns the
+	// call to run, possibly edited, or nil to skip it.
+	ReviewToolCall(step string, call *types.ToolCall) (*types.ToolCall, error)
+	// AfterIteration is called with the output of each iteration of step once
+	// it is stored in the context.
+	AfterIteration(step string, iteration int, output interface{}) error
+}
+
+// ErrChainAborted is returned by chains a person stopped through a
+// ChainSession.
+var ErrChainAborted = errors.New(errors.ErrCodeRole, "chain aborted by the user", nil)
+
+// reviewToolCall puts tc to in. It returns the call to run, possibly edited,
+// or no call and the error recorded for a skipped one; abort is set when the
+// chain must stop.
+func reviewToolCall(in ChainInteraction, step string, tc *types.ToolCall) (reviewed *types.ToolCall, skipErr, abort error) {
+	reviewed, abort = in.ReviewToolCall(step, tc)
+	if abort != nil {
+		return nil, nil, abort
+	}
+	if reviewed == nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("the %s call was skipped by the user", tc.Name), nil), nil
+	}
+	return reviewed, nil, nil
+}
+
+// ChainSession is the ChainInteraction of run-chain --interactive. It asks
+// through UI before every step and tool call, shows each iteration's output
+// and records the decisions in a transcript.
+type ChainSession struct {
+	UI             cli.UI
+	TranscriptPath string // Where WriteTranscript writes ({run_id} is expanded); empty disables
+	Transcript     types.ChainTranscript
+
+	editInput  bool                   // Open the input of the coming step in the editor
+	inputEdits map[string]interface{} // Input changes kept for the step's later iterations
+}
+
+// iterationInputs are the input keys set afresh for every iteration, which
+// edits of the step input do not carry over.
+var iterationInputs = map[string]bool{"lastToolResponse": true, "lastToolResponse_json": true}
+
+// NewChainSession returns a session for the run of chain with ID runID.
+func NewChainSession(ui cli.UI, chain, runID, transcriptPath string) *ChainSession {
+	return &ChainSession{
+		UI:             ui,
+		TranscriptPath: transcriptPath,
+		Transcript:     types.ChainTranscript{RunID: runID, Chain: chain, StartedAt: time.Now()},
+	}
+}
+
+func (s *ChainSession) record(event types.ChainEvent) {
+	s.Transcript.Events = append(s.Transcript.Events, event)
+}
+
+// abort records that the person stopped the run.
+func (s *ChainSession) abort(step string) error {
+	s.Transcript.Aborted = true
+	s.record(types.ChainEvent{Step: step, Kind: types.ChainEventAbort})
+	fmt.Println(i18n.T("chain_session.aborted"))
+	return ErrChainAborted
+}
+
+func (s *ChainSession) printError(err error) {
+	fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+}
+
+// BeforeStep lets the person run, skip or abort the step, and show or edit
+// the context first.
+func (s *ChainSession) BeforeStep(index int, step string, context map[string]interface{}) (bool, error) {
+	s.editInput, s.inputEdits = false, nil
+	fmt.Println(i18n.T("chain_session.step", index+1, step))
+	optRun, optInput, optShow, optEdit, optSkip, optAbort := i18n.T("chain_session.run_step"), i18n.T("chain_session.edit_input"), i18n.T("chain_session.show_context"), i18n.T("chain_session.edit_context"), i18n.T("chain_session.skip_step"), i18n.T("chain_session.abort")
+	for {
+		choice, err := s.UI.PromptSelect([]string{optRun, optInput, optShow, optEdit, optSkip, optAbort})
+		if err != nil {
+			return false, s.abort(step)
+		}
+		switch choice {
+		case optRun:
+			return false, nil
+		case optInput:
+			s.editInput = true
+			return false, nil
+		case optShow:
+			s.UI.Pager(runs.FormatContext(runs.SnapshotContext(context)))
+		case optEdit:
+			if changed := s.editContext(context); len(changed) > 0 {
+				s.record(types.ChainEvent{Step: step, Kind: types.ChainEventContext, Context: changed})
+			}
+		case optSkip:
+			s.record(types.ChainEvent{Step: step, Kind: types.ChainEventSkipStep})
+			return true, nil
+		case optAbort:
+			return false, s.abort(step)
+		}
+	}
+}
+
+// editContext opens context as JSON in the editor and applies the edited
+// version. It returns the keys that changed, with their new values; removed
+// keys map to nil.
+func (s *ChainSession) editContext(context map[string]interface{}) map[string]interface{} {
+	data, err := json.MarshalIndent(context, "", "  ")
+	if err != nil {
+		s.printError(err)
+		return nil
+	}
+	edited, err := s.UI.OpenEditor(string(data))
+	if err != nil {
+		s.printError(err)
+		return nil
+	}
+	var next map[string]interface{}
+	if err := json.Unmarshal([]byte(edited), &next); err != nil || next == nil {
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("chain_session.context_invalid")))
+		return nil
+	}
+	// Compare through JSON, as the edited values went through it.
+	var before map[string]interface{}
+	json.Unmarshal(data, &before)
+	changed := applyChanges(context, before, next)
+	if len(changed) > 0 {
+		fmt.Println(i18n.T("chain_session.context_changed", changedKeys(changed)))
+	}
+	return changed
+}
+
+// ReviewInput opens the input of the step's first iteration as YAML in the
+// editor when the person chose to edit it. The changes are applied to the
+// step's later iterations as well, except for the keys set per iteration.
+func (s *ChainSession) ReviewInput(step string, iteration int, input map[string]interface{}) (map[string]interface{}, error) {
+	for k, v := range s.inputEdits {
+		if iterationInputs[k] {
+			continue
+		}
+		if v == nil {
+			delete(input, k)
+		} else {
+			input[k] = v
+		}
+	}
+	if !s.editInput {
+		return input, nil
+	}
+	s.editInput = false
+	if changed := s.editStepInput(input); len(changed) > 0 {
+		s.inputEdits = changed
+		s.record(types.ChainEvent{Step: step, Iteration: iteration, Kind: types.ChainEventInput, Input: changed})
+	}
+	return input, nil
+}
+
+// editStepInput opens input as YAML in the editor and applies the edited
+// version. It returns the keys that changed, like editContext.
+func (s *ChainSession) editStepInput(input map[string]interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(input)
+	if err != nil {
+		s.printError(err)
+		return nil
+	}
+	edited, err := s.UI.OpenEditor(string(data))
+	if err != nil {
+		s.printError(err)
+		return nil
+	}
+	var next map[string]interface{}
+	if err := yaml.Unmarshal([]byte(edited), &next); err != nil || next == nil {
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("chain_session.input_invalid")))
+		return nil
+	}
+	// Compare through YAML, as the edited values went through it.
+	var before map[string]interface{}
+	yaml.Unmarshal(data, &before)
+	changed := applyChanges(input, before, next)
+	if len(changed) > 0 {
+		fmt.Println(i18n.T("chain_session.input_changed", changedKeys(changed)))
+	}
+	return changed
+}
+
+// applyChanges sets the keys of target that differ between before and next
+// to their value in next, and removes those next lacks. It returns the keys
+// that changed, with their new values; removed keys map to nil.
+func applyChanges(target, before, next map[string]interface{}) map[string]interface{} {
+	changed := map[string]interface{}{}
+	for k, v := range next {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			target[k] = v
+			changed[k] = v
+		}
+	}
+	for k := range before {
+		if _, ok := next[k]; !ok {
+			delete(target, k)
+			changed[k] = nil
+		}
+	}
+	return changed
+}
+
+// changedKeys lists the keys of changed, sorted and comma-separated.
+func changedKeys(changed map[string]interface{}) string {
+	keys := make([]string, 0, len(changed))
+	for k := range changed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// ReviewToolCall lets the person approve, edit, skip or abort the call.
+func (s *ChainSession) ReviewToolCall(step string, call *types.ToolCall) (*types.ToolCall, error) {
+	optApprove, optEdit, optSkip, optAbort := i18n.T("approval.approve"), i18n.T("approval.edit"), i18n.T("chain_session.skip_call"), i18n.T("chain_session.abort")
+	edited := false
+	for {
+		s.UI.PrettyJSON(call)
+		choice, err := s.UI.PromptSelect([]string{optApprove, optEdit, optSkip, optAbort})
+		if err != nil {
+			return nil, s.abort(step)
+		}
+		switch choice {
+		case optApprove:
+			decision := types.ChainDecisionApprove
+			if edited {
+				decision = types.ChainDecisionEdit
+			}
+			s.record(types.ChainEvent{Step: step, Kind: types.ChainEventToolCall, ToolCall: call, Decision: decision})
+			return call, nil
+		case optEdit:
+			if next := editToolCall(s.UI, call); next != call {
+				call, edited = next, true
+			}
+		case optSkip:
+			s.record(types.ChainEvent{Step: step, Kind: types.ChainEventToolCall, ToolCall: call, Decision: types.ChainDecisionSkip})
+			return nil, nil
+		case optAbort:
+			return nil, s.abort(step)
+		}
+	}
+}
+
+// AfterIteration shows the output and lets the person go on or abort.
+func (s *ChainSession) AfterIteration(step string, iteration int, output interface{}) error {
+	s.record(types.ChainEvent{Step: step, Iteration: iteration, Kind: types.ChainEventOutput, Output: output})
+	fmt.Println(i18n.T("chain_session.output", step, iteration+1))
+	s.UI.Pager(toolResultText(output))
+	optContinue, optAbort := i18n.T("chain_session.continue"), i18n.T("chain_session.abort")
+	choice, err := s.UI.PromptSelect([]string{optContinue, optAbort})
+	if err != nil || choice == optAbort {
+		return s.abort(step)
+	}
+	return nil
+}
+
+// WriteTranscript writes the transcript to TranscriptPath, if set.
+func (s *ChainSession) WriteTranscript() error {
+	if s.TranscriptPath == "" {
+		return nil
+	}
+	path := runs.ExpandRunID(s.TranscriptPath, s.Transcript.RunID)
+	data, err := json.MarshalIndent(s.Transcript, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to encode the chain transcript", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write the chain transcript to %s", path), err)
+	}
+	fmt.Println(i18n.T("session.transcript_written", path))
+	return nil
+}
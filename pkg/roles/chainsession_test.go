@@ -0,0 +1,155 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteChain_Interactive(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	dir := t.TempDir()
+	var prompts []string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		path := filepath.Join(dir, fmt.Sprintf("out%d.txt", len(prompts)))
+		return fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content": "A"}}}`, path), nil
+	}
+	role := cfg.Roles["coder"]
+	role.Prompt = "code {{.extra}}"
+	cfg.Roles["coder"] = role
+	step := types.ChainRole{Role: "coder", Input: map[string]interface{}{"extra": "{{.extra}}"}}
+	first, second, third := step, step, step
+	first.Name, second.Name, third.Name = "first", "second", "third"
+	chain := types.RoleChain{Steps: []types.ChainRole{first, second, third}}
+
+	answers := []string{
+		"chain_session.edit_context", "chain_session.run_step", // first: set extra, then run
+		"approval.edit", "approval.approve", // edit the write_file call, then run it
+		"chain_session.continue",
+		"chain_session.skip_step",                           // second
+		"chain_session.run_step", "chain_session.skip_call", // third
+		"chain_session.abort",
+	}
+	ui := &MockUI{
+		PromptSelectFunc: func(options []string) (string, error) {
+			if len(answers) == 0 {
+				t.Fatalf("unexpected prompt %q", options)
+			}
+			answer := i18n.T(answers[0])
+			answers = answers[1:]
+			return answer, nil
+		},
+		OpenEditorFunc: func(content string) (string, error) {
+			var call types.ToolCall
+			if json.Unmarshal([]byte(content), &call) == nil && call.Name != "" {
+				call.Arguments["content"] = "B"
+				data, _ := json.Marshal(call)
+				return string(data), nil
+			}
+			return `{"extra": "edited"}`, nil
+		},
+	}
+	transcript := filepath.Join(dir, "{run_id}.json")
+	session := NewChainSession(ui, "interactive", "run-1", transcript)
+	_, err := ExecuteChainWithOptions(chain, map[string]interface{}{"extra": "initial"}, cfg, ChainOptions{Interactive: session})
+	if err != ErrChainAborted {
+		t.Fatalf("expected the run to be aborted, got %v", err)
+	}
+	if len(answers) != 0 {
+		t.Errorf("expected every prompt to be asked, %d left", len(answers))
+	}
+	if len(prompts) != 2 || prompts[0] != "code edited" {
+		t.Errorf("expected two model calls seeing the edited context, got %q", prompts)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out1.txt")); err != nil || string(data) != "B" {
+		t.Errorf("expected the edited call to write B, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out2.txt")); err == nil {
+		t.Error("expected the skipped call not to run")
+	}
+
+	if err := session.WriteTranscript(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "run-1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved types.ChainTranscript
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range saved.Events {
+		kinds = append(kinds, e.Step+":"+e.Kind+":"+e.Decision)
+	}
+	want := "first:context: first:tool_call:edit first:output: second:skip_step: third:tool_call:skip third:output: third:abort:"
+	if !saved.Aborted || strings.Join(kinds, " ") != want {
+		t.Errorf("transcript events = %q (aborted %v), want %q", strings.Join(kinds, " "), saved.Aborted, want)
+	}
+}
+
+func TestExecuteChain_InteractiveInput(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	var prompts []string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return "done", nil
+	}
+	role := cfg.Roles["coder"]
+	role.Prompt = "code {{.task}}"
+	cfg.Roles["coder"] = role
+	step := types.ChainRole{Role: "coder", Input: map[string]interface{}{"task": "{{.task}}"}, Loop: true, LoopCount: 2}
+	first, second := step, step
+	first.Name, second.Name = "first", "second"
+	chain := types.RoleChain{Steps: []types.ChainRole{first, second}}
+
+	answers := []string{
+		"chain_session.edit_input", "chain_session.continue", "chain_session.continue", // first: edit, then both iterations
+		"chain_session.run_step", "chain_session.continue", "chain_session.continue", // second
+	}
+	var edited string
+	ui := &MockUI{
+		PromptSelectFunc: func(options []string) (string, error) {
+			if len(answers) == 0 {
+				t.Fatalf("unexpected prompt %q", options)
+			}
+			answer := i18n.T(answers[0])
+			answers = answers[1:]
+			return answer, nil
+		},
+		OpenEditorFunc: func(content string) (string, error) {
+			edited = content
+			return strings.Replace(content, "task: initial", "task: experiment", 1), nil
+		},
+	}
+	session := NewChainSession(ui, "interactive", "run-1", "")
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{"task": "initial"}, cfg, ChainOptions{Interactive: session}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(edited, "task: initial") {
+		t.Errorf("expected the resolved input as YAML in the editor, got %q", edited)
+	}
+	want := []string{"code experiment", "code experiment", "code initial", "code initial"}
+	if strings.Join(prompts, "|") != strings.Join(want, "|") {
+		t.Errorf("prompts = %q, want %q", prompts, want)
+	}
+	events := session.Transcript.Events
+	if len(events) == 0 || events[0].Kind != types.ChainEventInput || events[0].Input["task"] != "experiment" {
+		t.Errorf("expected the input edit to be recorded first, got %+v", events)
+	}
+}
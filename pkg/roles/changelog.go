@@ -0,0 +1,62 @@
+package roles
+
+import (
+	"ai-team/config"
+	"ai-team/pkg/runs"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultChangelogMaxDiff is used when changelog.max_diff is not set.
+const defaultChangelogMaxDiff = 20000
+
+// changelogInstructions are added to the summarizer role's prompt.
+const changelogInstructions = `Summarize the changes above for a changelog: a short title line, then one bullet per notable change. Do not call tools.`
+
+// summarizeChanges stores the change summary of a finished run that changed
+// files: the diffstat and, with changelog.role set, the role's summary of the
+// diffs. A failing summarizer is recorded in the summary, not returned. Dry
+// runs changed nothing and get no summary.
+func summarizeChanges(ctx context.Context, cfg *config.Config, run *runs.Record) {
+	if run.DryRun {
+		return
+	}
+	files := run.DiffStat()
+	if len(files) == 0 {
+		return
+	}
+	summary := &runs.ChangeSummary{Files: files}
+	if name := cfg.Changelog.Role; name != "" {
+		role, ok := cfg.Roles[name]
+		if !ok {
+			summary.Error = fmt.Sprintf("changelog role '%s' not found in config", name)
+		} else {
+			maxDiff := cfg.Changelog.MaxDiff
+			if maxDiff <= 0 {
+				maxDiff = defaultChangelogMaxDiff
+			}
+			filesJSON, _ := json.MarshalIndent(files, "", "  ")
+			input := map[string]interface{}{
+				"chain":      run.Chain,
+				"status":     run.Status,
+				"files":      files,
+				"files_json": string(filesJSON),
+				"diff":       truncate(run.Diffs(), maxDiff),
+			}
+			role.Prompt += "\n\n" + changelogInstructions
+			logrus.Infof("Summarizing the changes of run %s with role %s", run.ID, name)
+			result, err := RunRoleContext(ctx, role, input, cfg, "")
+			if err != nil {
+				summary.Error = err.Error()
+				logrus.Warnf("changelog role failed: %v", err)
+			} else {
+				summary.Summary, summary.Summarizer = strings.TrimSpace(result.Text), name
+			}
+		}
+	}
+	run.SetChanges(summary)
+}
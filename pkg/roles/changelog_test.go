@@ -0,0 +1,65 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteChain_Changelog(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	path := filepath.Join(t.TempDir(), "out.txt")
+	var summarizerPrompt string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		if strings.HasPrefix(prompt, "Summarize") {
+			summarizerPrompt = prompt
+			return "Add out.txt\n", nil
+		}
+		return fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content": "one\ntwo\n"}}}`, path), nil
+	}
+	cfg.Roles["scribe"] = types.Role{Provider: "gemini", Model: "flash", Prompt: "Summarize {{.files_json}}\n{{.diff}}"}
+	cfg.Changelog.Role = "scribe"
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder"}}}
+
+	run := runs.NewRecord("write", nil)
+	if _, err := ExecuteChainWithOptions(chain, nil, cfg, ChainOptions{Run: run}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	c := run.Changes
+	if c == nil || len(c.Files) != 1 || c.Files[0] != (runs.FileChange{Path: path, Writes: 1, Added: 2}) {
+		t.Fatalf("unexpected changes %+v", c)
+	}
+	if c.Summary != "Add out.txt" || c.Summarizer != "scribe" {
+		t.Errorf("unexpected summary %q by %q", c.Summary, c.Summarizer)
+	}
+	if !strings.Contains(summarizerPrompt, "two") || !strings.Contains(summarizerPrompt, "added") {
+		t.Errorf("summarizer did not get the diff and diffstat:\n%s", summarizerPrompt)
+	}
+}
+
+func TestExecuteChain_ChangelogNoChanges(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	cfg.Changelog.Role = "coder"
+	run := runs.NewRecord("read", nil)
+	if _, err := ExecuteChainWithOptions(types.RoleChain{Steps: []types.ChainRole{{Role: "coder"}}}, nil, cfg, ChainOptions{Run: run}); err != nil {
+		t.Fatal(err)
+	}
+	if run.Changes != nil || calls != 1 {
+		t.Errorf("expected no summary for a run without changes, got %+v after %d calls", run.Changes, calls)
+	}
+}
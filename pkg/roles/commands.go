@@ -0,0 +1,224 @@
+package roles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+	"ai-team/pkg/usage"
+)
+
+// SlashCommand is a session command typed as "/name args" in place of a message.
+type SlashCommand struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(session *Session, args []string) error
+}
+
+// undoEntry remembers how to revert a file written during the session. The
+// previous content is kept in memory because repeated writes to the same file
+// overwrite its .bak backup.
+type undoEntry struct {
+	FilePath string
+	Existed  bool
+	Content  []byte
+}
+
+// snapshotForUndo captures the current state of filePath, the file a write
+// tool call targets. It returns nil when there is no such file path.
+func snapshotForUndo(filePath string) *undoEntry {
+	if filePath == "" {
+		return nil
+	}
+	entry := &undoEntry{FilePath: filePath}
+	if data, err := os.ReadFile(filePath); err == nil {
+		entry.Existed = true
+		entry.Content = data
+	}
+	return entry
+}
+
+// undoLast reverts the most recent file modification of the session.
+func undoLast(session *Session) (string, error) {
+	if len(session.undo) == 0 {
+		return "", errors.New(errors.ErrCodeTool, "nothing to undo", nil)
+	}
+	last := session.undo[len(session.undo)-1]
+	if !last.Existed {
+		if err := os.Remove(last.FilePath); err != nil && !os.IsNotExist(err) {
+			return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to remove %s", last.FilePath), err)
+		}
+	} else if err := os.WriteFile(last.FilePath, last.Content, 0644); err != nil {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to restore %s", last.FilePath), err)
+	}
+	session.undo = session.undo[:len(session.undo)-1]
+	return last.FilePath, nil
+}
+
+// slashCommands returns the available session commands in display order.
+func slashCommands() []SlashCommand {
+	return []SlashCommand{
+		{Name: "help", Usage: "/help", Description: "List available commands", Run: cmdHelp},
+		{Name: "tools", Usage: "/tools", Description: "List tools the model can call", Run: cmdTools},
+		{Name: "context", Usage: "/context", Description: "Show the role, model and current inputs", Run: cmdContext},
+		{Name: "cost", Usage: "/cost", Description: "Show LLM calls, tokens and cost so far", Run: cmdCost},
+		{Name: "save", Usage: "/save [path]", Description: "Write the transcript (defaults to --transcript)", Run: cmdSave},
+		{Name: "undo", Usage: "/undo", Description: "Revert the last file written in this session", Run: cmdUndo},
+		{Name: "model", Usage: "/model <name>", Description: "Switch the model used for the next LLM call", Run: cmdModel},
+	}
+}
+
+// isSlashCommand reports whether a message should be handled as a command:
+// "/" followed by the name of a session command. Anything else starting with
+// "/", such as an absolute path, is a value.
+func isSlashCommand(message string) bool {
+	fields := strings.Fields(message)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return false
+	}
+	name := strings.ToLower(fields[0][1:])
+	for _, c := range slashCommands() {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeSlash drops the first "/" of a value starting with "//", the
+// escape for values that would read as a command, e.g. "//help" for "/help".
+func unescapeSlash(message string) string {
+	if i := strings.Index(message, "//"); i >= 0 && strings.TrimSpace(message[:i]) == "" {
+		return message[:i] + message[i+1:]
+	}
+	return message
+}
+
+// handleSlashCommand parses and runs a "/command args" line.
+func handleSlashCommand(session *Session, line string) error {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "/"))
+	if len(fields) == 0 {
+		return errors.New(errors.ErrCodeRole, "empty command; type /help for a list of commands", nil)
+	}
+	name := strings.ToLower(fields[0])
+	for _, c := range slashCommands() {
+		if c.Name == name {
+			return c.Run(session, fields[1:])
+		}
+	}
+	return errors.New(errors.ErrCodeRole, fmt.Sprintf("unknown command /%s; type /help for a list of commands", name), nil)
+}
+
+// readMessage opens the editor for a message, running any slash commands the
+// user enters until a regular message is given.
+func readMessage(session *Session) (string, error) {
+	return readMessageFrom(session, func() (string, error) { return session.UI.OpenEditor("") })
+}
+
+// readLine is like readMessage but reads a single inline line.
+func readLine(session *Session, label string) (string, error) {
+	return readMessageFrom(session, func() (string, error) { return session.UI.PromptLine(label) })
+}
+
+func readMessageFrom(session *Session, read func() (string, error)) (string, error) {
+	for {
+		message, err := read()
+		if err != nil {
+			return "", err
+		}
+		if !isSlashCommand(message) {
+			return unescapeSlash(message), nil
+		}
+		if err := handleSlashCommand(session, message); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+func cmdHelp(session *Session, args []string) error {
+	fmt.Println("Commands:")
+	for _, c := range slashCommands() {
+		fmt.Printf("  %-16s %s\n", c.Usage, c.Description)
+	}
+	return nil
+}
+
+func cmdTools(session *Session, args []string) error {
+	if session.toolRegistry == nil {
+		return errors.New(errors.ErrCodeTool, "no tools registered", nil)
+	}
+	schemas := session.toolRegistry.ListTools()
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	for _, s := range schemas {
+		fmt.Printf("  %-12s %s\n", s.Name, s.Description)
+	}
+	return nil
+}
+
+func cmdContext(session *Session, args []string) error {
+	if session.role != nil {
+		fmt.Printf("Role: %s (%s/%s)\n", render.Stdout().Paint(render.Role, session.Transcript.Role), session.role.Provider, session.role.Model)
+	}
+	fmt.Println("Inputs:")
+	return session.UI.PrettyJSON(session.inputs)
+}
+
+func cmdCost(session *Session, args []string) error {
+	fmt.Printf("LLM calls: %d, estimated tokens: ~%d (about 4 characters per token)\n", session.llmCalls, session.approxTokens)
+	summary := session.costSummary()
+	fmt.Printf("Provider usage: %s\n", summary)
+	fmt.Print(usage.Default.Report(summary.Currency))
+	return nil
+}
+
+func cmdSave(session *Session, args []string) error {
+	path := session.TranscriptPath
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return errors.New(errors.ErrCodeRole, "usage: /save <path> (no --transcript path set)", nil)
+	}
+	path = runs.ExpandRunID(path, session.RunID)
+	if session.Transcript == nil {
+		session.Transcript = &types.Transcript{RunID: session.RunID}
+	}
+	session.Transcript.Cost = session.costSummary()
+	data, err := json.MarshalIndent(session.Transcript, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeRole, "failed to marshal transcript", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to write transcript to %s", path), err)
+	}
+	fmt.Printf("Transcript written to: %s\n", path)
+	return nil
+}
+
+func cmdUndo(session *Session, args []string) error {
+	path, err := undoLast(session)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reverted %s\n", path)
+	return nil
+}
+
+func cmdModel(session *Session, args []string) error {
+	if len(args) != 1 {
+		return errors.New(errors.ErrCodeRole, "usage: /model <name>", nil)
+	}
+	session.Model = args[0]
+	if session.role != nil {
+		session.role.Model = args[0]
+	}
+	fmt.Printf("Model set to %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,174 @@
+package roles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+func TestReadMessage_RunsSlashCommandsFirst(t *testing.T) {
+	messages := []string{"/help", "/model gpt-4o", "/etc/hosts"}
+	mockUI := &MockUI{
+		OpenEditorFunc: func(content string) (string, error) {
+			m := messages[0]
+			messages = messages[1:]
+			return m, nil
+		},
+	}
+	role := types.Role{Model: "gpt-3.5-turbo"}
+	session := &Session{UI: mockUI, role: &role}
+
+	var message string
+	output := captureOutput(func() {
+		var err error
+		message, err = readMessage(session)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+
+	if message != "/etc/hosts" {
+		t.Errorf("expected a path not naming a command to be returned, got %q", message)
+	}
+	if role.Model != "gpt-4o" || session.Model != "gpt-4o" {
+		t.Errorf("expected /model to switch the model, got role=%s session=%s", role.Model, session.Model)
+	}
+	for _, want := range []string{"/undo", "Model set to gpt-4o"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got: %s", want, output)
+		}
+	}
+}
+
+func TestIsSlashCommand(t *testing.T) {
+	tests := []struct {
+		message string
+		command bool
+		value   string
+	}{
+		{"/help", true, ""},
+		{"  /Model gpt-4o\n", true, ""},
+		{"/etc/hosts", false, "/etc/hosts"},
+		{"/bogus", false, "/bogus"},
+		{"//help", false, "/help"},
+		{"fix the tests", false, "fix the tests"},
+	}
+	for _, tt := range tests {
+		if got := isSlashCommand(tt.message); got != tt.command {
+			t.Errorf("isSlashCommand(%q) = %v", tt.message, got)
+		}
+		if !tt.command {
+			if got := unescapeSlash(tt.message); got != tt.value {
+				t.Errorf("unescapeSlash(%q) = %q, want %q", tt.message, got, tt.value)
+			}
+		}
+	}
+}
+
+func TestSlashCommands_ToolsCostSaveUndo(t *testing.T) {
+	dir := t.TempDir()
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+
+	existing := filepath.Join(dir, "a.txt")
+	os.WriteFile(existing, []byte("new"), 0644)
+	created := filepath.Join(dir, "b.txt")
+	os.WriteFile(created, []byte("b"), 0644)
+
+	session := &Session{
+		UI:           &MockUI{},
+		toolRegistry: reg,
+		Transcript:   &types.Transcript{Role: "coder"},
+		llmCalls:     2,
+		approxTokens: 150,
+		undo:         []undoEntry{{FilePath: existing, Existed: true, Content: []byte("old")}, {FilePath: created}},
+	}
+
+	output := captureOutput(func() {
+		for _, cmd := range []string{"/tools", "/cost", "/save " + filepath.Join(dir, "t.json"), "/undo", "/undo"} {
+			if err := handleSlashCommand(session, cmd); err != nil {
+				t.Errorf("%s: unexpected error: %v", cmd, err)
+			}
+		}
+	})
+
+	for _, want := range []string{"write_file", "LLM calls: 2", "~150"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got: %s", want, output)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "t.json")); err != nil {
+		t.Errorf("expected transcript to be saved: %v", err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected undo to remove newly created file")
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "old" {
+		t.Errorf("expected undo to restore backup, got %q", data)
+	}
+	if err := handleSlashCommand(session, "/undo"); err == nil {
+		t.Errorf("expected error when nothing is left to undo")
+	}
+}
+
+func TestApproveAndExecute_UndoStackRevertsRepeatedWrites(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("v1"), 0644)
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{UI: &MockUI{ConfirmFunc: func(string) (bool, error) { return true, nil }}}
+
+	captureOutput(func() {
+		for _, content := range []string{"v2", "v3"} {
+			call := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": file, "content": content}}
+			if _, ok := approveAndExecute(session, reg, call, false, false); !ok {
+				t.Fatalf("expected write of %s to succeed", content)
+			}
+		}
+	})
+	if len(session.undo) != 2 {
+		t.Fatalf("expected 2 undo entries, got %d", len(session.undo))
+	}
+
+	for _, want := range []string{"v2", "v1"} {
+		if _, err := undoLast(session); err != nil {
+			t.Fatalf("undo failed: %v", err)
+		}
+		if data, _ := os.ReadFile(file); string(data) != want {
+			t.Errorf("expected %q after undo, got %q", want, data)
+		}
+	}
+}
+
+func TestApproveAndExecute_UndoUsesToolPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	os.WriteFile(file, []byte("v1"), 0644)
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{UI: &MockUI{ConfirmFunc: func(string) (bool, error) { return true, nil }}}
+
+	captureOutput(func() {
+		call := &types.ToolCall{Name: "WriteFile", Arguments: map[string]interface{}{"filePath": file, "content": "v2"}}
+		if _, ok := approveAndExecute(session, reg, call, false, false); !ok {
+			t.Fatalf("expected write through filePath to succeed")
+		}
+		call = &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": filepath.Join(dir, "other.go"), "filePath": file, "content": "v3"}}
+		if _, ok := approveAndExecute(session, reg, call, false, false); ok {
+			t.Errorf("expected write with conflicting path aliases to be refused")
+		}
+	})
+	if len(session.undo) != 1 || session.undo[0].FilePath != file {
+		t.Fatalf("expected one undo entry for %s, got %+v", file, session.undo)
+	}
+	if _, err := undoLast(session); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "v1" {
+		t.Errorf("expected v1 after undo, got %q", data)
+	}
+}
@@ -0,0 +1,57 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteChain_DryRun(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	dir := t.TempDir()
+	var checked string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		if strings.HasPrefix(prompt, "check") {
+			checked = prompt
+			return "looks good", nil
+		}
+		return fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content": "x"}}}`, filepath.Join(dir, "out.txt")), nil
+	}
+	cfg.Roles["checker"] = types.Role{Provider: "gemini", Model: "flash", Prompt: "check {{.lastToolResponse}}"}
+	cfg.Simulation.DryRun = true
+	chain := types.RoleChain{
+		Steps: []types.ChainRole{
+			{Role: "coder", Before: []types.StepHook{{Command: "touch " + filepath.Join(dir, "hook")}}},
+			{Role: "checker"},
+		},
+		OnSuccess: &types.ChainHook{Command: "touch " + filepath.Join(dir, "success")},
+	}
+	run := runs.NewRecord("dry", nil)
+	ctx, err := ExecuteChainWithOptions(chain, nil, cfg, ChainOptions{Run: run})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"out.txt", "hook", "success"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("dry run created %s", name)
+		}
+	}
+	if !strings.Contains(checked, "[dry run] write_file was not executed") {
+		t.Errorf("expected the next step to see the simulated result, got prompt %q", checked)
+	}
+	if hooks, _ := ctx["before_hooks"].([]interface{}); len(hooks) != 1 || !strings.Contains(fmt.Sprint(hooks[0]), "run_command was not executed") {
+		t.Errorf("expected a simulated hook result, got %v", ctx["before_hooks"])
+	}
+	if !run.DryRun || run.Changes != nil {
+		t.Errorf("expected a dry-run record without changes, got dry_run=%v changes=%+v", run.DryRun, run.Changes)
+	}
+}
@@ -0,0 +1,171 @@
+package roles
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// DefaultEstimateOutputTokens is the output size assumed for models without
+// max_tokens when estimating the upper bound of a chain's cost.
+const DefaultEstimateOutputTokens = 1024
+
+// StepEstimate is the estimated token use and cost of one chain step.
+type StepEstimate struct {
+	Index         int     `json:"index"`
+	Role          string  `json:"role"`
+	Model         string  `json:"model"` // provider/model
+	PromptTokens  int     `json:"prompt_tokens"`
+	OutputTokens  int     `json:"output_tokens"` // Upper bound per iteration
+	MinIterations int     `json:"min_iterations"`
+	MaxIterations int     `json:"max_iterations"`
+	Priced        bool    `json:"priced"`
+	MinCost       float64 `json:"min_cost"`
+	MaxCost       float64 `json:"max_cost"`
+}
+
+// ChainEstimate is the estimated cost range of a chain run.
+type ChainEstimate struct {
+	Steps    []StepEstimate `json:"steps"`
+	MinCost  float64        `json:"min_cost"`
+	MaxCost  float64        `json:"max_cost"`
+	Unpriced []string       `json:"unpriced,omitempty"` // provider/model labels without a pricing entry
+}
+
+// EstimateChain renders each step's prompt from the chain vars and initial
+// input and prices it with cfg.Pricing. Outputs of earlier steps are unknown,
+// so prompts referencing them are undercounted. The minimum assumes one
+// iteration per loop step (loop_count for fixed loops), none for steps with a
+// when condition, and no output tokens; the maximum assumes every step and
+// iteration runs once and produces max_tokens. Steps run again by a goto or
+// on_error jump are not counted.
+func EstimateChain(chain types.RoleChain, initialInput map[string]interface{}, cfg *config.Config) (*ChainEstimate, error) {
+	context := make(map[string]interface{}, len(chain.Vars)+len(initialInput))
+	for k, v := range chain.Vars {
+		context[k] = v
+	}
+	for k, v := range initialInput {
+		context[k] = v
+	}
+
+	estimate := &ChainEstimate{}
+	unpriced := map[string]bool{}
+	for i, step := range chain.Steps {
+		roleKey := step.Role
+		if roleKey == "" {
+			roleKey = step.Name
+		}
+		role, ok := cfg.Roles[roleKey]
+		if !ok {
+			return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("role '%s' not found in config", roleKey), nil)
+		}
+		input := map[string]interface{}{"lastToolResponse": nil, "lastToolResponse_json": ""}
+		for k, v := range step.Input {
+			input[k] = estimateInput(v, context)
+		}
+		prompt, err := renderPrompt(role, input, false)
+		if err != nil {
+			return nil, err
+		}
+
+		s := StepEstimate{
+			Index:         i,
+			Role:          roleKey,
+			Model:         role.Provider + "/" + role.Model,
+			PromptTokens:  ai.EstimateTokens(prompt),
+			OutputTokens:  DefaultEstimateOutputTokens,
+			MinIterations: 1,
+			MaxIterations: 1,
+		}
+		if modelCfg, ok := cfg.LookupModel(role.Provider, role.Model); ok && modelCfg.MaxTokens > 0 {
+			s.OutputTokens = modelCfg.MaxTokens
+		}
+		if step.Loop {
+			s.MinIterations, s.MaxIterations = loopBounds(step)
+		}
+		if step.When != "" {
+			s.MinIterations = 0 // The step may be skipped
+		}
+		if price, ok := cfg.Price(role.Provider, role.Model); ok {
+			s.Priced = true
+			s.MinCost = price.Cost(s.MinIterations*s.PromptTokens, 0)
+			s.MaxCost = float64(s.MaxIterations) * price.Cost(s.PromptTokens, s.OutputTokens)
+		} else {
+			unpriced[s.Model] = true
+		}
+		estimate.MinCost += s.MinCost
+		estimate.MaxCost += s.MaxCost
+		estimate.Steps = append(estimate.Steps, s)
+	}
+	for label := range unpriced {
+		estimate.Unpriced = append(estimate.Unpriced, label)
+	}
+	sort.Strings(estimate.Unpriced)
+	return estimate, nil
+}
+
+// loopBounds returns the fewest and most iterations a loop step can run, using
+// the same limits as ExecuteChainWithOptions. expected_loops caps the maximum
+// of steps that stop on a loop_condition or loop_script.
+func loopBounds(step types.ChainRole) (int, int) {
+	conditional := step.LoopCondition != "" || step.LoopScript != ""
+	switch {
+	case step.LoopCount > 0 && !conditional:
+		return step.LoopCount, step.LoopCount
+	case step.LoopCount > 0:
+		return 1, step.LoopCount
+	case !conditional:
+		return 1, 1
+	case step.ExpectedLoops > 0:
+		return 1, step.ExpectedLoops
+	default:
+		return 1, 100
+	}
+}
+
+// estimateInput resolves a step input template against the chain context the
+// way ExecuteChainWithOptions does, rendering unknown keys as "<no value>".
+func estimateInput(v interface{}, context map[string]interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || !strings.HasPrefix(s, "{{") || !strings.HasSuffix(s, "}}") {
+		return v
+	}
+	tmpl, err := template.New("input").Parse(s)
+	if err != nil {
+		return s
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, context); err != nil {
+		return s
+	}
+	return out.String()
+}
+
+// FormatEstimate renders a chain estimate as a table with a total line.
+func FormatEstimate(e *ChainEstimate) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-4s  %-20s  %-28s  %8s  %8s  %-7s  %s\n", "STEP", "ROLE", "MODEL", "PROMPT", "OUTPUT", "LOOPS", "COST")
+	for _, s := range e.Steps {
+		loops := fmt.Sprintf("%d", s.MinIterations)
+		if s.MaxIterations != s.MinIterations {
+			loops = fmt.Sprintf("%d-%d", s.MinIterations, s.MaxIterations)
+		}
+		cost := "unpriced"
+		if s.Priced {
+			cost = fmt.Sprintf("%.4f - %.4f", s.MinCost, s.MaxCost)
+		}
+		fmt.Fprintf(&b, "%-4d  %-20s  %-28s  %8d  %8d  %-7s  %s\n", s.Index+1, s.Role, s.Model, s.PromptTokens, s.OutputTokens, loops, cost)
+	}
+	fmt.Fprintf(&b, "Estimated cost: %.4f - %.4f\n", e.MinCost, e.MaxCost)
+	if len(e.Unpriced) > 0 {
+		fmt.Fprintf(&b, "No pricing for: %s (add them under pricing: in the config)\n", strings.Join(e.Unpriced, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package roles
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/types"
+)
+
+func TestEstimateChain(t *testing.T) {
+	cfg := &config.Config{
+		Roles: map[string]types.Role{
+			"planner": {Provider: "openai", Model: "gpt", Prompt: "Plan {{.task}}"},
+			"coder":   {Provider: "ollama", Model: "llama", Prompt: "Code {{.plan}}"},
+		},
+		Pricing: types.PricingConfig{Models: map[string]types.ModelPrice{"openai/gpt": {Input: 1000, Output: 2000}}},
+	}
+	cfg.OpenAI.Models = map[string]config.ModelConfig{"gpt": {MaxTokens: 10}}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "planner", Input: map[string]interface{}{"task": "{{.task}}"}, Loop: true, LoopCondition: "{{true}}", ExpectedLoops: 3},
+		{Role: "coder", Input: map[string]interface{}{"plan": "{{.steps.planner.output}}"}},
+	}}
+
+	e, err := EstimateChain(chain, map[string]interface{}{"task": "abcd"}, cfg)
+	if err != nil {
+		t.Fatalf("EstimateChain: %v", err)
+	}
+	if len(e.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(e.Steps))
+	}
+	planner := e.Steps[0]
+	// "Plan abcd" is 9 characters, about 3 tokens.
+	if planner.PromptTokens != 3 || planner.OutputTokens != 10 || planner.MinIterations != 1 || planner.MaxIterations != 3 {
+		t.Errorf("unexpected planner estimate: %+v", planner)
+	}
+	if math.Abs(e.MinCost-0.003) > 1e-9 || math.Abs(e.MaxCost-3*(0.003+0.02)) > 1e-9 {
+		t.Errorf("unexpected cost range %.4f - %.4f", e.MinCost, e.MaxCost)
+	}
+	if e.Steps[1].Priced || e.Steps[1].OutputTokens != DefaultEstimateOutputTokens {
+		t.Errorf("unexpected coder estimate: %+v", e.Steps[1])
+	}
+	if len(e.Unpriced) != 1 || e.Unpriced[0] != "ollama/llama" {
+		t.Errorf("unexpected unpriced models: %v", e.Unpriced)
+	}
+	if out := FormatEstimate(e); !strings.Contains(out, "Estimated cost: 0.0030 - 0.0690") || !strings.Contains(out, "No pricing for: ollama/llama") {
+		t.Errorf("unexpected formatted estimate:\n%s", out)
+	}
+}
+
+func TestLoopBounds(t *testing.T) {
+	tests := []struct {
+		step     types.ChainRole
+		min, max int
+	}{
+		{types.ChainRole{LoopCount: 4}, 4, 4},
+		{types.ChainRole{LoopCount: 4, LoopCondition: "x"}, 1, 4},
+		{types.ChainRole{LoopCondition: "x"}, 1, 100},
+		{types.ChainRole{LoopCondition: "x", ExpectedLoops: 5}, 1, 5},
+		{types.ChainRole{LoopScript: "stop = True"}, 1, 100},
+		{types.ChainRole{}, 1, 1},
+	}
+	for _, tt := range tests {
+		if min, max := loopBounds(tt.step); min != tt.min || max != tt.max {
+			t.Errorf("loopBounds(%+v) = %d, %d; want %d, %d", tt.step, min, max, tt.min, tt.max)
+		}
+	}
+}
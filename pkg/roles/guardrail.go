@@ -0,0 +1,190 @@
+package roles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultAuditLogPath is where guardrail decisions are appended when
+// guardrail.audit_log is not set.
+const DefaultAuditLogPath = ".ai-team/audit.jsonl"
+
+// Guardrail decisions.
+const (
+	GuardrailApprove = "approve"
+	GuardrailDeny    = "deny"
+)
+
+// guardrailInstructions is appended to the guardrail role's prompt.
+const guardrailInstructions = `Decide whether the pending tool call above may run unattended. ` +
+	`Reply with only a JSON object: {"decision": "approve" or "deny", "reason": "<one sentence>"}.`
+
+// auditMu serializes appends to audit logs.
+var auditMu sync.Mutex
+
+// guardrail consults the configured reviewer role about destructive tool
+// calls and appends its decisions to the audit log.
+type guardrail struct {
+	name  string
+	role  types.Role
+	cfg   *config.Config
+	runID string
+
+	// Set by the chain before each tool call.
+	step    string
+	context map[string]interface{}
+	last    *types.GuardrailDecision
+}
+
+// newGuardrail returns the guardrail of cfg, or nil when none is configured.
+func newGuardrail(cfg *config.Config, runID string) *guardrail {
+	if cfg == nil || cfg.Guardrail.Role == "" {
+		return nil
+	}
+	role, ok := cfg.Roles[cfg.Guardrail.Role]
+	if !ok {
+		logrus.Warnf("Guardrail role '%s' not found; destructive tool calls are denied", cfg.Guardrail.Role)
+	}
+	return &guardrail{name: cfg.Guardrail.Role, role: role, cfg: cfg, runID: runID}
+}
+
+// review returns an error unless the guardrail role approves call. Calls
+// that are not destructive pass without asking.
+func (g *guardrail) review(ctx context.Context, call tools.ToolCall) error {
+	if g == nil || !isDestructiveCall(&types.ToolCall{Name: call.Name, Arguments: call.Arguments}) {
+		return nil
+	}
+	decision := g.decide(ctx, call)
+	g.last = decision
+	g.record(decision)
+	if decision.Decision != GuardrailApprove {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("guardrail role %s denied %s: %s", g.name, call.Name, decision.Reason), nil)
+	}
+	logrus.Infof("Guardrail role %s approved %s: %s", g.name, call.Name, decision.Reason)
+	return nil
+}
+
+// at sets the step and chain context the next reviews are about.
+func (g *guardrail) at(step string, context map[string]interface{}) {
+	if g != nil {
+		g.step, g.context = step, context
+	}
+}
+
+// take returns the decision of the last review and forgets it.
+func (g *guardrail) take() *types.GuardrailDecision {
+	if g == nil {
+		return nil
+	}
+	decision := g.last
+	g.last = nil
+	return decision
+}
+
+// decide asks the guardrail role about call. Failures and unclear answers
+// deny the call.
+func (g *guardrail) decide(ctx context.Context, call tools.ToolCall) *types.GuardrailDecision {
+	decision := &types.GuardrailDecision{
+		Time:      time.Now(),
+		RunID:     g.runID,
+		Step:      g.step,
+		Tool:      call.Name,
+		Arguments: call.Arguments,
+		Role:      g.name,
+		Decision:  GuardrailDeny,
+	}
+	if g.role.Prompt == "" {
+		decision.Reason = "guardrail role is not configured"
+		return decision
+	}
+	callJSON, _ := json.Marshal(map[string]interface{}{"name": call.Name, "arguments": call.Arguments})
+	contextJSON, _ := json.Marshal(runs.SnapshotContext(g.context))
+	input := map[string]interface{}{
+		"tool_name": call.Name,
+		"tool_call": string(callJSON),
+		"step":      g.step,
+		"context":   string(contextJSON),
+	}
+	role := g.role
+	role.Prompt += "\n\n" + guardrailInstructions
+	result, err := RunRoleContext(ctx, role, input, g.cfg, "")
+	if err != nil {
+		decision.Reason = fmt.Sprintf("guardrail role failed: %v", err)
+		return decision
+	}
+	decision.Decision, decision.Reason = parseGuardrailAnswer(result.Text)
+	return decision
+}
+
+// parseGuardrailAnswer reads the decision and reason from the guardrail
+// role's answer: a JSON object, or text starting with approve or deny.
+func parseGuardrailAnswer(text string) (decision, reason string) {
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start != -1 && end > start {
+		var answer struct {
+			Decision string `json:"decision"`
+			Reason   string `json:"reason"`
+		}
+		if err := json.Unmarshal([]byte(text[start:end+1]), &answer); err == nil && answer.Decision != "" {
+			text = answer.Decision + " " + answer.Reason
+		}
+	}
+	text = strings.TrimSpace(text)
+	word, rest, _ := strings.Cut(text, " ")
+	rest = strings.TrimLeft(strings.TrimSpace(rest), ":- ")
+	switch strings.ToLower(strings.Trim(word, ".:,!*\"")) {
+	case "approve", "approved", "allow":
+		return GuardrailApprove, rest
+	case "deny", "denied", "reject":
+		return GuardrailDeny, rest
+	}
+	return GuardrailDeny, fmt.Sprintf("unclear answer from the guardrail role: %q", truncate(text, 200))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// record appends decision to the audit log.
+func (g *guardrail) record(decision *types.GuardrailDecision) {
+	path := g.cfg.Guardrail.AuditLog
+	if path == "" {
+		path = DefaultAuditLogPath
+	}
+	line, err := json.Marshal(decision)
+	if err != nil {
+		logrus.Warnf("Could not record guardrail decision: %v", err)
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		logrus.Warnf("Could not record guardrail decision: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		logrus.Warnf("Could not record guardrail decision: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logrus.Warnf("Could not record guardrail decision: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package roles
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+)
+
+func TestParseGuardrailAnswer(t *testing.T) {
+	cases := []struct {
+		answer, decision, reason string
+	}{
+		{`{"decision": "approve", "reason": "Writes a doc file."}`, GuardrailApprove, "Writes a doc file."},
+		{"```json\n{\"decision\": \"deny\", \"reason\": \"Deletes the repo.\"}\n```", GuardrailDeny, "Deletes the repo."},
+		{"APPROVED: harmless", GuardrailApprove, "harmless"},
+		{"Deny - touches .env", GuardrailDeny, "touches .env"},
+		{"I am not sure.", GuardrailDeny, `unclear answer from the guardrail role: "I am not sure."`},
+		{"", GuardrailDeny, `unclear answer from the guardrail role: ""`},
+	}
+	for _, c := range cases {
+		decision, reason := parseGuardrailAnswer(c.answer)
+		if decision != c.decision || reason != c.reason {
+			t.Errorf("parseGuardrailAnswer(%q) = %q, %q; want %q, %q", c.answer, decision, reason, c.decision, c.reason)
+		}
+	}
+}
+
+// guardrailConfig returns a config whose worker role writes each path in
+// order and whose guardrail role denies paths containing "secret".
+func guardrailConfig(t *testing.T, paths ...string) (*config.Config, *[]string) {
+	var reviews []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		if strings.Contains(prompt, guardrailInstructions) {
+			reviews = append(reviews, prompt)
+			if strings.Contains(prompt, "secret") {
+				return `{"decision": "deny", "reason": "Writes a secret."}`, nil
+			}
+			return `{"decision": "approve", "reason": "Harmless."}`, nil
+		}
+		path := paths[0]
+		paths = paths[1:]
+		return fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content": "x"}}}`, path), nil
+	}
+	t.Cleanup(func() { ai.CallGeminiFunc = origCallGemini })
+
+	cfg := &config.Config{}
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	cfg.Gemini.Apiurl = "http://mock"
+	cfg.Roles = map[string]types.Role{
+		"writer":   {Provider: "gemini", Model: "flash", Prompt: "Write."},
+		"reviewer": {Provider: "gemini", Model: "flash", Prompt: "Step {{.step}} wants to run {{.tool_call}}. Task: {{.context}}"},
+	}
+	cfg.Guardrail = types.GuardrailConfig{Role: "reviewer", AuditLog: filepath.Join(t.TempDir(), "audit.jsonl")}
+	return cfg, &reviews
+}
+
+func TestExecuteChain_Guardrail(t *testing.T) {
+	dir := t.TempDir()
+	docs, secret := filepath.Join(dir, "docs.md"), filepath.Join(dir, "secret.env")
+	cfg, reviews := guardrailConfig(t, docs, secret)
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "docs", Role: "writer"}, {Name: "keys", Role: "writer"}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{"task": "document the API"}, cfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if _, err := os.Stat(docs); err != nil {
+		t.Errorf("expected the approved write to run: %v", err)
+	}
+	if _, err := os.Stat(secret); !os.IsNotExist(err) {
+		t.Errorf("expected the denied write not to run")
+	}
+	if len(*reviews) != 2 || !strings.Contains((*reviews)[0], "Step docs wants to run") || !strings.Contains((*reviews)[0], "document the API") {
+		t.Errorf("expected the guardrail role to get the call and the chain context, got %q", *reviews)
+	}
+	if g := run.Steps[0].Guardrail; g == nil || g.Decision != GuardrailApprove || g.Reason != "Harmless." {
+		t.Errorf("expected the approval in the run record, got %+v", g)
+	}
+	if g := run.Steps[1].Guardrail; g == nil || g.Decision != GuardrailDeny || !strings.Contains(run.Steps[1].ToolError, "Writes a secret.") {
+		t.Errorf("expected the denial in the run record, got %+v, %q", g, run.Steps[1].ToolError)
+	}
+
+	f, err := os.Open(cfg.Guardrail.AuditLog)
+	if err != nil {
+		t.Fatalf("expected an audit log: %v", err)
+	}
+	defer f.Close()
+	var decisions []types.GuardrailDecision
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var d types.GuardrailDecision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		decisions = append(decisions, d)
+	}
+	if len(decisions) != 2 || decisions[0].RunID != run.ID || decisions[1].Step != "keys" || decisions[1].Tool != "write_file" ||
+		decisions[1].Arguments["file_path"] != secret || decisions[1].Role != "reviewer" || decisions[1].Decision != GuardrailDeny {
+		t.Errorf("unexpected audit log %+v", decisions)
+	}
+}
+
+func TestExecuteChain_GuardrailSkipsReadOnlyCalls(t *testing.T) {
+	cfg, reviews := guardrailConfig(t)
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, gen ai.Generation) (string, error) {
+		if strings.Contains(prompt, guardrailInstructions) {
+			return origCallGemini(nil, prompt, model, apiURL, apiKey, tools, gen)
+		}
+		return `{"tool_call": {"name": "list_dir", "arguments": {"path": "."}}}`, nil
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "look", Role: "writer"}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, cfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(*reviews) != 0 || run.Steps[0].Guardrail != nil || run.Steps[0].ToolError != "" {
+		t.Errorf("expected read-only calls to run without review, got %d review(s), %q", len(*reviews), run.Steps[0].ToolError)
+	}
+}
+
+func TestCanAutoApprove_GuardrailRole(t *testing.T) {
+	cfg, reviews := guardrailConfig(t)
+	session := &Session{Yes: true, Config: cfg}
+	write := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "notes.md", "content": "x"}}
+	writeSecret := &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "secret.env", "content": "x"}}
+
+	captureOutput(func() {
+		if !session.canAutoApprove(write) {
+			t.Errorf("expected a call the guardrail role approves to be auto-approved")
+		}
+		if session.canAutoApprove(writeSecret) {
+			t.Errorf("expected a call the guardrail role denies to need manual approval")
+		}
+	})
+	if len(*reviews) != 2 || session.autoApproved != 1 {
+		t.Errorf("expected two reviews and one auto-approval, got %d and %d", len(*reviews), session.autoApproved)
+	}
+}
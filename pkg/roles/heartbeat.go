@@ -0,0 +1,182 @@
+package roles
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Step phases reported by heartbeats.
+const (
+	phaseStarting = "starting"
+	phaseModel    = "waiting on model"
+	phaseTool     = "executing tool"
+	phaseHooks    = "running hooks"
+)
+
+// heartbeat reports on chain steps that run longer than a threshold: every
+// interval it logs the elapsed time and current phase and emits a
+// step_heartbeat event. A phase that lasts StallAfter is reported once as a
+// stall, with a step_stalled event and an optional webhook POST. All methods
+// are no-ops on a nil heartbeat.
+type heartbeat struct {
+	cfg    types.HeartbeatConfig
+	run    *runs.Record
+	events func(event string, fields map[string]interface{})
+	client *http.Client
+
+	mu         sync.Mutex
+	step       string
+	stepStart  time.Time
+	phase      string
+	detail     string // Model or tool of the phase
+	phaseStart time.Time
+	stalled    bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startHeartbeat starts reporting on the steps of run, or returns nil when
+// heartbeats are disabled.
+func startHeartbeat(cfg types.HeartbeatConfig, run *runs.Record, events func(string, map[string]interface{})) *heartbeat {
+	if cfg.After <= 0 || cfg.Interval <= 0 {
+		return nil
+	}
+	h := &heartbeat{
+		cfg:    cfg,
+		run:    run,
+		events: events,
+		client: &http.Client{Timeout: 10 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go h.loop()
+	return h
+}
+
+func (h *heartbeat) loop() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case now := <-ticker.C:
+			h.check(now)
+		}
+	}
+}
+
+// close stops reporting and waits for a report in progress.
+func (h *heartbeat) close() {
+	if h == nil {
+		return
+	}
+	close(h.stop)
+	<-h.done
+}
+
+// beginStep starts timing the named step.
+func (h *heartbeat) beginStep(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	h.step, h.stepStart = name, now
+	h.phase, h.detail, h.phaseStart, h.stalled = phaseStarting, "", now, false
+}
+
+// endStep stops reporting on the current step.
+func (h *heartbeat) endStep() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.step = ""
+}
+
+// setPhase records what the step is doing now, e.g. phaseTool and the tool name.
+func (h *heartbeat) setPhase(phase, detail string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.phase, h.detail, h.phaseStart, h.stalled = phase, detail, time.Now(), false
+}
+
+// check reports on the current step as of now.
+func (h *heartbeat) check(now time.Time) {
+	h.mu.Lock()
+	if h.step == "" || now.Sub(h.stepStart) < h.cfg.After {
+		h.mu.Unlock()
+		return
+	}
+	elapsed, phaseElapsed := now.Sub(h.stepStart).Round(time.Second), now.Sub(h.phaseStart).Round(time.Second)
+	fields := map[string]interface{}{
+		"run_id":        h.run.ID,
+		"chain":         h.run.Chain,
+		"step":          h.step,
+		"phase":         h.phase,
+		"detail":        h.detail,
+		"elapsed":       elapsed.String(),
+		"phase_elapsed": phaseElapsed.String(),
+	}
+	if len(h.run.Labels) > 0 {
+		fields["labels"] = h.run.Labels
+	}
+	stalled := h.cfg.StallAfter > 0 && !h.stalled && now.Sub(h.phaseStart) >= h.cfg.StallAfter
+	if stalled {
+		h.stalled = true
+	}
+	step, activity := h.step, h.phase
+	if h.detail != "" {
+		activity += " " + h.detail
+	}
+	h.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields(fields)).Infof("Step %s still running after %s (%s for %s)", step, elapsed, activity, phaseElapsed)
+	if h.events != nil {
+		h.events("step_heartbeat", fields)
+	}
+	if !stalled {
+		return
+	}
+	logrus.WithFields(logrus.Fields(fields)).Warnf("Step %s may be stalled: %s for %s without progress", step, activity, phaseElapsed)
+	if h.events != nil {
+		h.events("step_stalled", fields)
+	}
+	if h.cfg.Webhook != "" {
+		h.alert(fields)
+	}
+}
+
+// alert posts a stall to the configured webhook.
+func (h *heartbeat) alert(fields map[string]interface{}) {
+	payload := map[string]interface{}{"event": "step_stalled"}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	body, _ := json.Marshal(payload)
+	resp, err := h.client.Post(h.cfg.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logrus.Warnf("Failed to send stall alert to %s: %v", h.cfg.Webhook, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logrus.Warnf("Stall alert webhook %s returned %s", h.cfg.Webhook, resp.Status)
+	}
+}
@@ -0,0 +1,79 @@
+package roles
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+)
+
+func TestHeartbeat_ReportsLongStepsAndStalls(t *testing.T) {
+	alerts := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		alerts <- payload
+	}))
+	defer server.Close()
+
+	var events []string
+	// An hour-long interval keeps the background loop out of the way; the test
+	// drives check directly.
+	cfg := types.HeartbeatConfig{After: time.Minute, Interval: time.Hour, StallAfter: 5 * time.Minute, Webhook: server.URL}
+	run := runs.NewRecord("build", nil)
+	run.Labels = map[string]string{"ticket": "ENG-42"}
+	beat := startHeartbeat(cfg, run, func(event string, fields map[string]interface{}) {
+		events = append(events, event)
+	})
+	defer beat.close()
+
+	beat.beginStep("coder")
+	beat.setPhase(phaseModel, "openai/gpt")
+	start := time.Now()
+
+	beat.check(start.Add(30 * time.Second))
+	if len(events) != 0 {
+		t.Fatalf("expected no heartbeat before the threshold, got %v", events)
+	}
+	beat.check(start.Add(2 * time.Minute))
+	if len(events) != 1 || events[0] != "step_heartbeat" {
+		t.Fatalf("expected a heartbeat, got %v", events)
+	}
+
+	beat.check(start.Add(6 * time.Minute))
+	beat.check(start.Add(7 * time.Minute))
+	if want := []string{"step_heartbeat", "step_heartbeat", "step_stalled", "step_heartbeat"}; len(events) != len(want) || events[2] != want[2] || events[3] != want[3] {
+		t.Fatalf("expected one stall report, got %v", events)
+	}
+	select {
+	case alert := <-alerts:
+		if alert["event"] != "step_stalled" || alert["step"] != "coder" || alert["phase"] != phaseModel || alert["detail"] != "openai/gpt" ||
+			fmt.Sprint(alert["labels"]) != "map[ticket:ENG-42]" {
+			t.Errorf("unexpected stall alert %v", alert)
+		}
+	default:
+		t.Fatal("expected a stall alert on the webhook")
+	}
+
+	beat.endStep()
+	beat.check(start.Add(10 * time.Minute))
+	if len(events) != 4 {
+		t.Errorf("expected no reports between steps, got %v", events)
+	}
+}
+
+func TestHeartbeat_DisabledIsNil(t *testing.T) {
+	beat := startHeartbeat(types.HeartbeatConfig{Interval: time.Second}, runs.NewRecord("build", nil), nil)
+	if beat != nil {
+		t.Fatal("expected no heartbeat without a threshold")
+	}
+	beat.beginStep("coder")
+	beat.setPhase(phaseTool, "run_command")
+	beat.endStep()
+	beat.close()
+}
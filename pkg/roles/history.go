@@ -0,0 +1,74 @@
+package roles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ai-team/pkg/errors"
+)
+
+// maxInputHistory is the number of recent values kept per role input.
+const maxInputHistory = 10
+
+// InputHistory persists values entered for role inputs, most recent first.
+type InputHistory struct {
+	Path   string
+	Values map[string]map[string][]string // role -> input -> values
+}
+
+// LoadInputHistory reads the history file at path; a missing file yields an empty history.
+func LoadInputHistory(path string) (*InputHistory, error) {
+	h := &InputHistory{Path: path, Values: map[string]map[string][]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to read input history %s", path), err)
+	}
+	if err := json.Unmarshal(data, &h.Values); err != nil {
+		return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to parse input history %s", path), err)
+	}
+	return h, nil
+}
+
+// Recent returns previously used values for a role input, most recent first.
+func (h *InputHistory) Recent(role, input string) []string {
+	return h.Values[role][input]
+}
+
+// Add records value as the most recent entry for a role input.
+func (h *InputHistory) Add(role, input, value string) {
+	if value == "" {
+		return
+	}
+	if h.Values[role] == nil {
+		h.Values[role] = map[string][]string{}
+	}
+	values := []string{value}
+	for _, v := range h.Values[role][input] {
+		if v != value && len(values) < maxInputHistory {
+			values = append(values, v)
+		}
+	}
+	h.Values[role][input] = values
+}
+
+// Save writes the history back to its file.
+func (h *InputHistory) Save() error {
+	if dir := filepath.Dir(h.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to create directory for %s", h.Path), err)
+		}
+	}
+	data, err := json.MarshalIndent(h.Values, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeRole, "failed to marshal input history", err)
+	}
+	if err := os.WriteFile(h.Path, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to write input history %s", h.Path), err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package roles
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestInputHistory_AddSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "inputs.json")
+	h, err := LoadInputHistory(path)
+	if err != nil {
+		t.Fatalf("expected empty history for missing file, got: %v", err)
+	}
+	for i := 0; i < maxInputHistory+3; i++ {
+		h.Add("coder", "task", fmt.Sprintf("v%d", i))
+	}
+	h.Add("coder", "task", "v5")
+	if err := h.Save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := LoadInputHistory(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	recent := loaded.Recent("coder", "task")
+	if len(recent) != maxInputHistory || recent[0] != "v5" || recent[1] != "v12" {
+		t.Errorf("unexpected history order or size: %v", recent)
+	}
+	if len(loaded.Recent("tester", "task")) != 0 {
+		t.Errorf("expected history to be kept per role")
+	}
+}
+
+func TestGetInputs_DefaultsHistoryAndInline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inputs.json")
+	h, _ := LoadInputHistory(path)
+	h.Add("coder", "lang", "rust")
+	h.Save()
+
+	role := &types.Role{
+		Prompt: "Write {{.task}} in {{.lang}} for {{.audience}}",
+		Inputs: []types.RoleInput{
+			{Name: "lang", Description: "Programming language", Default: "go"},
+			{Name: "task", Required: true},
+			{Name: "audience"},
+		},
+	}
+	var seen [][]string
+	mockUI := &MockUI{
+		PromptSelectFunc: func(options []string) (string, error) {
+			seen = append(seen, options)
+			switch len(seen) {
+			case 1: // lang: pick the previous value
+				return "Previous: rust", nil
+			case 2: // task: inline entry
+				return inputOptionInline, nil
+			default: // audience: typed directly at the prompt
+				return "beginners", nil
+			}
+		},
+		PromptLineFunc: func(label string) (string, error) { return "a parser", nil },
+	}
+	session := &Session{UI: mockUI, HistoryPath: path, Transcript: &types.Transcript{Role: "coder"}}
+
+	var inputs map[string]interface{}
+	output := captureOutput(func() {
+		var err error
+		inputs, err = getInputs(session, role)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+
+	if inputs["lang"] != "rust" || inputs["task"] != "a parser" || inputs["audience"] != "beginners" {
+		t.Errorf("unexpected inputs: %v", inputs)
+	}
+	if strings.Join(seen[0], "|") != "Default: go|Previous: rust|Type inline|Open editor" {
+		t.Errorf("unexpected options for declared input: %v", seen[0])
+	}
+	if !strings.Contains(output, "Programming language") {
+		t.Errorf("expected input description in output, got: %s", output)
+	}
+
+	saved, _ := LoadInputHistory(path)
+	if got := saved.Recent("coder", "task"); len(got) != 1 || got[0] != "a parser" {
+		t.Errorf("expected entered value to be remembered, got %v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package roles
+
+import (
+	"ai-team/config"
+	"ai-team/pkg/cleanup"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/script"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"ai-team/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHookOutputKey is the context key used when a hook has no output_key.
+const defaultHookOutputKey = "on_success_output"
+
+// runOnSuccessHook invokes the chain's on_success hook with the run manifest and
+// stores its output in context: the text a role generated, or a command's
+// output. Dry runs do not write the output file. The hook's error is returned
+// to the caller.
+func runOnSuccessHook(ctx context.Context, hook *types.ChainHook, context map[string]interface{}, cfg *config.Config, run *runs.Record, logFilePath string) error {
+	if hook == nil {
+		return nil
+	}
+	manifest := run.Manifest()
+	manifest.Status = runs.StatusSuccess
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeRole, "failed to marshal run manifest", err)
+	}
+
+	var output string
+	switch {
+	case hook.Role != "":
+		roleDef, ok := cfg.Roles[hook.Role]
+		if !ok {
+			return errors.New(errors.ErrCodeRole, fmt.Sprintf("on_success role '%s' not found in config", hook.Role), nil)
+		}
+		input := make(map[string]interface{}, len(context)+2)
+		for k, v := range context {
+			input[k] = v
+		}
+		input["manifest"] = manifest
+		input["manifest_json"] = string(manifestJSON)
+		logrus.Infof("Running on_success role: %s", hook.Role)
+		result, roleErr := RunRoleContext(ctx, roleDef, input, cfg, logFilePath)
+		if roleErr != nil {
+			return roleErr
+		}
+		output = result.Text
+	case hook.Command != "":
+		logrus.Infof("Running on_success command: %s", hook.Command)
+		output, err = runHookCommand(ctx, hook.Command, manifestJSON)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New(errors.ErrCodeRole, "on_success hook must set either role or command", nil)
+	}
+
+	key := hook.OutputKey
+	if key == "" {
+		key = defaultHookOutputKey
+	}
+	context[key] = output
+	if hook.OutputFile != "" && run.DryRun {
+		logrus.Infof("Dry run: not writing the on_success output to %s", hook.OutputFile)
+	} else if hook.OutputFile != "" {
+		if _, err := tools.WriteFile(runs.ExpandRunID(hook.OutputFile, run.ID), output); err != nil {
+			return err
+		}
+	}
+	logger.DebugPrintf("on_success hook output stored under %s", key)
+	return nil
+}
+
+// runHookCommand runs a shell command with the manifest JSON on stdin and in a
+// temporary file referenced by AI_TEAM_MANIFEST.
+func runHookCommand(ctx context.Context, command string, manifestJSON []byte) (string, error) {
+	temps := cleanup.From(ctx)
+	f, err := temps.CreateTemp("", "ai-team-manifest-*.json")
+	if err != nil {
+		return "", errors.New(errors.ErrCodeTool, "failed to create manifest file", err)
+	}
+	defer temps.Release(f.Name())
+	if _, err := f.Write(manifestJSON); err != nil {
+		f.Close()
+		return "", errors.New(errors.ErrCodeTool, "failed to write manifest file", err)
+	}
+	f.Close()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdin = bytes.NewReader(manifestJSON)
+	cmd.Env = tools.CommandEnv(ctx, "AI_TEAM_MANIFEST="+f.Name())
+	out, err := temps.CombinedOutput(cmd)
+	if err != nil {
+		return string(out), errors.New(errors.ErrCodeTool, fmt.Sprintf("hook command failed: %s: %s", command, string(out)), err)
+	}
+	return string(out), nil
+}
+
+// runStepHooks runs hooks in order and returns the outputs collected so far
+// along with the first error encountered. Script hooks run over context and
+// store the variables they assign in it; their output is those variables.
+func runStepHooks(ctx context.Context, phase string, hooks []types.StepHook, executor *tools.ToolExecutor, scripts map[string]*script.Program, context map[string]interface{}) ([]interface{}, error) {
+	outputs := make([]interface{}, 0, len(hooks))
+	for _, hook := range hooks {
+		var (
+			result interface{}
+			err    error
+		)
+		if hook.Command != "" && executor.Simulator != nil && executor.Simulator.DryRun() && executor.Simulator.Simulates("run_command") {
+			// Dry runs treat hook commands like run_command calls.
+			logger.From(ctx).Infof("Dry run: simulating %s hook command: %s", phase, hook.Command)
+			result, err = executor.Simulator.Result(tools.ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": hook.Command}})
+		} else if hook.Command != "" {
+			logger.From(ctx).Infof("Running %s hook command: %s", phase, hook.Command)
+			result, err = tools.RunCommandContext(ctx, hook.Command)
+		} else if hook.Script != "" {
+			prog, ok := scripts[hook.Script]
+			if !ok {
+				return outputs, errors.New(errors.ErrCodeTool, fmt.Sprintf("%s hook script was not compiled", phase), nil)
+			}
+			logger.From(ctx).Infof("Running %s hook script: %s", phase, prog.Name())
+			result, err = runScript(ctx, prog, context, nil)
+		} else {
+			logger.From(ctx).Infof("Running %s hook tool: %s", phase, hook.Tool)
+			result, err = executor.Execute(ctx, tools.ToolCall{Name: hook.Tool, Arguments: hook.Arguments})
+		}
+		if err != nil {
+			return outputs, errors.New(errors.ErrCodeTool, fmt.Sprintf("%s hook failed", phase), err)
+		}
+		outputs = append(outputs, result)
+	}
+	return outputs, nil
+}
+
+// applyOnError applies the step's on_error policy to a hook error. It reports
+// whether the step should be skipped, or returns the error when the chain must stop.
+// When on_error names a step, the step is skipped and the caller jumps there.
+func applyOnError(step types.ChainRole, phase string, err error) (bool, error) {
+	if err == nil {
+		return false, nil
+	}
+	if target := step.ErrorTarget(); target != "" {
+		logrus.Warnf("Step %s %s hook failed, continuing with step %s: %v", step.Key(), phase, target, err)
+		return true, nil
+	}
+	switch step.OnError {
+	case types.OnErrorFail:
+		return false, err
+	case types.OnErrorSkip:
+		logrus.Warnf("Skipping step %s: %v", step.Role, err)
+		return true, nil
+	default:
+		logrus.Warnf("Continuing step %s despite %s hook failure: %v", step.Role, phase, err)
+		return false, nil
+	}
+}
@@ -0,0 +1,162 @@
+package roles
+
+import (
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func manifestRecord() *runs.Record {
+	run := runs.NewRecord("build", nil)
+	run.AddStep(runs.StepRecord{ToolCall: &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "main.go"}}})
+	run.AddStep(runs.StepRecord{ToolCall: &types.ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "go test ./..."}}})
+	return run
+}
+
+func TestRunOnSuccessHook_Command(t *testing.T) {
+	ctx := map[string]interface{}{}
+	out := filepath.Join(t.TempDir(), "manifest.txt")
+	hook := &types.ChainHook{Command: "cat", OutputKey: "pr_description", OutputFile: out}
+	if err := runOnSuccessHook(context.Background(), hook, ctx, &config.Config{}, manifestRecord(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := ctx["pr_description"].(string)
+	if !strings.Contains(got, `"main.go"`) || !strings.Contains(got, "go test ./...") {
+		t.Fatalf("expected manifest on stdin, got %q", got)
+	}
+}
+
+func TestRunOnSuccessHook_Role(t *testing.T) {
+	var seenPrompt string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		seenPrompt = prompt
+		return `{"candidates":[{"content":{"parts":[{"text":"Add main.go"}]},"finishReason":"STOP"}]}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	cfg := &config.Config{}
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	cfg.Roles = map[string]types.Role{
+		"committer": {Provider: "gemini", Model: "flash", Prompt: "Files: {{range .manifest.FilesChanged}}{{.}} {{end}}"},
+	}
+	ctx := map[string]interface{}{}
+	out := filepath.Join(t.TempDir(), "pr.md")
+	if err := runOnSuccessHook(context.Background(), &types.ChainHook{Role: "committer", OutputFile: out}, ctx, cfg, manifestRecord(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(seenPrompt, "main.go") {
+		t.Errorf("expected manifest in rendered prompt, got %q", seenPrompt)
+	}
+	if ctx[defaultHookOutputKey] != "Add main.go" {
+		t.Errorf("expected the generated text in context, got %v", ctx[defaultHookOutputKey])
+	}
+	if data, _ := os.ReadFile(out); string(data) != "Add main.go" {
+		t.Errorf("expected the generated text in the output file, got %q", data)
+	}
+
+	dryOut := filepath.Join(t.TempDir(), "dry.md")
+	run := manifestRecord()
+	run.DryRun = true
+	if err := runOnSuccessHook(context.Background(), &types.ChainHook{Role: "committer", OutputFile: dryOut}, ctx, cfg, run, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(dryOut); !os.IsNotExist(err) {
+		t.Errorf("expected a dry run not to write the output file, got %v", err)
+	}
+}
+
+func hookChainConfig(calls *int) *config.Config {
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		*calls++
+		return "done", nil
+	}
+	cfg := &config.Config{}
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	cfg.Roles = map[string]types.Role{"coder": {Provider: "gemini", Model: "flash", Prompt: "code"}}
+	return cfg
+}
+
+func TestExecuteChain_StepHooks(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+
+	chain := types.RoleChain{Steps: []types.ChainRole{{
+		Role:   "coder",
+		Before: []types.StepHook{{Command: "echo before"}},
+		After:  []types.StepHook{{Tool: "RunCommand", Arguments: map[string]interface{}{"command": "echo after"}}},
+	}}}
+	ctx, err := ExecuteChain(chain, nil, cfg, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 model call, got %d", calls)
+	}
+	after, _ := ctx["after_hooks"].([]interface{})
+	if len(after) != 1 || !strings.Contains(after[0].(string), "after") {
+		t.Fatalf("expected after hook output in context, got %v", ctx["after_hooks"])
+	}
+}
+
+func TestExecuteChain_StepLogFiles(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	cfg.Logging = types.LoggingConfig{StepOutput: types.StepLogFiles, Dir: t.TempDir()}
+
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "build", Role: "coder", Before: []types.StepHook{{Command: "echo build"}}},
+		{Name: "test", Role: "coder", Before: []types.StepHook{{Command: "echo test"}}},
+	}}
+	run := runs.NewRecord("hooks", nil)
+	if _, err := ExecuteChainWithOptions(chain, nil, cfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, name := range []string{"build", "test"} {
+		data, err := os.ReadFile(filepath.Join(cfg.Logging.Dir, run.ID, fmt.Sprintf("%02d-%s.log", i+1, name)))
+		if err != nil {
+			t.Fatalf("expected a log file for step %s: %v", name, err)
+		}
+		if !strings.Contains(string(data), "Running before hook command: echo "+name) || strings.Count(string(data), "Running before hook") != 1 {
+			t.Errorf("expected only the hook of step %s in its log, got %q", name, data)
+		}
+	}
+}
+
+func TestExecuteChain_StepHookOnError(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+
+	failing := []types.StepHook{{Command: "exit 3"}}
+	skipChain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Before: failing, OnError: types.OnErrorSkip}}}
+	if _, err := ExecuteChain(skipChain, nil, cfg, ""); err != nil {
+		t.Fatalf("expected skip policy to continue the chain, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected skipped step not to call the model, got %d calls", calls)
+	}
+
+	failChain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Before: failing, OnError: types.OnErrorFail}}}
+	if _, err := ExecuteChain(failChain, nil, cfg, ""); err == nil {
+		t.Fatalf("expected fail policy to abort the chain")
+	}
+
+	continueChain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Before: failing}}}
+	if _, err := ExecuteChain(continueChain, nil, cfg, ""); err != nil || calls != 1 {
+		t.Fatalf("expected default policy to run the step, got err=%v calls=%d", err, calls)
+	}
+}
@@ -0,0 +1,355 @@
+package roles
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"ai-team/config"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// InputSpecs returns the role's declared inputs, or, when none are declared,
+// the top-level variables referenced by its prompt template (except the
+// generated tools_prompt).
+func InputSpecs(role types.Role) []types.RoleInput {
+	if len(role.Inputs) > 0 {
+		return role.Inputs
+	}
+	names := templateVariables(role.Prompt)
+	specs := make([]types.RoleInput, 0, len(names))
+	for _, name := range names {
+		if name == ToolsPromptInput {
+			continue
+		}
+		specs = append(specs, types.RoleInput{Name: name})
+	}
+	return specs
+}
+
+// templateVariables lists the top-level fields ({{.name}}) a template reads, in
+// order of first use, including those inside if/else branches. Fields inside
+// range and with blocks refer to a different dot and are ignored.
+func templateVariables(prompt string) []string {
+	tmpl, err := template.New("prompt").Parse(prompt)
+	if err != nil || tmpl.Tree == nil {
+		return regexVariables(prompt)
+	}
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			add(n.Ident[0])
+		case *parse.ChainNode:
+			walk(n.Node)
+		}
+	}
+	walk(tmpl.Tree.Root)
+	return names
+}
+
+// regexVariables is the fallback for prompts that do not parse as templates.
+func regexVariables(prompt string) []string {
+	re := regexp.MustCompile(`{{\s*\.(\w+)`)
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range re.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// ResolveInputs validates provided values against the role's declared inputs:
+// defaults are filled in, required inputs must be present and string values
+// are converted to the declared type.
+func ResolveInputs(role types.Role, provided map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(provided)+len(role.Inputs))
+	for k, v := range provided {
+		resolved[k] = v
+	}
+	var missing []string
+	for _, spec := range role.Inputs {
+		v, ok := resolved[spec.Name]
+		if !ok {
+			if spec.Default != "" {
+				value, err := spec.ParseValue(spec.Default)
+				if err != nil {
+					return nil, errors.New(errors.ErrCodeRole, "invalid input default", err)
+				}
+				resolved[spec.Name] = value
+			} else if spec.Required {
+				missing = append(missing, spec.Name)
+			}
+			continue
+		}
+		if s, isString := v.(string); isString {
+			value, err := spec.ParseValue(s)
+			if err != nil {
+				return nil, errors.New(errors.ErrCodeRole, "invalid input value", err)
+			}
+			resolved[spec.Name] = value
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("missing required input(s): %s", strings.Join(missing, ", ")), nil)
+	}
+	return resolved, nil
+}
+
+// FormatInputHelp renders the inputs of the named role: a usage line, each
+// input with its type, default and description, and an example invocation.
+// Inputs of roles without declarations are the prompt's variables.
+func FormatInputHelp(name string, role types.Role) string {
+	specs := InputSpecs(role)
+	var b strings.Builder
+	usage := []string{"ai-team role " + name}
+	for _, spec := range specs {
+		arg := spec.Name + "=<" + inputType(spec) + ">"
+		if !spec.Required {
+			arg = "[" + arg + "]"
+		}
+		usage = append(usage, arg)
+	}
+	fmt.Fprintf(&b, "Usage: %s\n", strings.Join(usage, " "))
+	if len(specs) == 0 {
+		b.WriteString("\nThe role takes no inputs.\n")
+		return b.String()
+	}
+
+	attrs := make([]string, len(specs))
+	nameWidth, attrWidth := 0, 0
+	for i, spec := range specs {
+		var parts []string
+		if spec.Required {
+			parts = append(parts, "required")
+		}
+		parts = append(parts, inputType(spec))
+		if spec.Default != "" {
+			parts = append(parts, "default: "+spec.Default)
+		}
+		attrs[i] = "(" + strings.Join(parts, ", ") + ")"
+		nameWidth = max(nameWidth, len(spec.Name))
+		attrWidth = max(attrWidth, len(attrs[i]))
+	}
+	b.WriteString("\nInputs:\n")
+	for i, spec := range specs {
+		line := fmt.Sprintf("  %-*s  %-*s  %s", nameWidth, spec.Name, attrWidth, attrs[i], spec.Description)
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+		if spec.Example != "" {
+			fmt.Fprintf(&b, "  %-*s  Example: %s\n", nameWidth, "", spec.Example)
+		}
+	}
+	if len(role.Inputs) == 0 {
+		b.WriteString("\nThe role declares no inputs; these are the variables its prompt reads.\n")
+	}
+
+	// The example sets the required inputs and those with an example value, or
+	// every variable of a role without declarations.
+	example := []string{"ai-team role " + tools.ShellQuote(name)}
+	for _, spec := range specs {
+		value := spec.Example
+		if value == "" {
+			if !spec.Required && len(role.Inputs) > 0 {
+				continue
+			}
+			value = "<" + spec.Name + ">"
+		}
+		example = append(example, tools.ShellQuote(spec.Name+"="+value))
+	}
+	fmt.Fprintf(&b, "\nExample:\n  %s\n", strings.Join(example, " "))
+	return b.String()
+}
+
+// inputType returns the declared type of spec, defaulting to string.
+func inputType(spec types.RoleInput) string {
+	if spec.Type == "" {
+		return types.InputTypeString
+	}
+	return spec.Type
+}
+
+// withInputDefaults returns input with defaults for any declared inputs it lacks.
+func withInputDefaults(role types.Role, input map[string]interface{}) map[string]interface{} {
+	var out map[string]interface{}
+	for _, spec := range role.Inputs {
+		if spec.Default == "" {
+			continue
+		}
+		if _, ok := input[spec.Name]; ok {
+			continue
+		}
+		value, err := spec.ParseValue(spec.Default)
+		if err != nil {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(input)+1)
+			for k, v := range input {
+				out[k] = v
+			}
+		}
+		out[spec.Name] = value
+	}
+	if out == nil {
+		return input
+	}
+	return out
+}
+
+// chainProvidedInputs are set on every chain step's role input by ExecuteChain.
+var chainProvidedInputs = map[string]bool{"lastToolResponse": true, "lastToolResponse_json": true, ToolsPromptInput: true}
+
+// LintChain reports problems with the named chain's role inputs: required inputs
+// a step never sets, and step inputs the role does not declare.
+func LintChain(cfg *config.Config, name string) []string {
+	chain, ok := cfg.Chains[name]
+	if !ok {
+		return []string{fmt.Sprintf("chain '%s' not found", name)}
+	}
+	var problems []string
+	for i, step := range chain.Steps {
+		roleKey := step.Role
+		if roleKey == "" {
+			roleKey = step.Name
+		}
+		role, ok := cfg.Roles[roleKey]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("chain '%s' step %d: role '%s' not found", name, i+1, roleKey))
+			continue
+		}
+		declared := map[string]bool{}
+		for _, spec := range InputSpecs(role) {
+			declared[spec.Name] = true
+			required := spec.Required || len(role.Inputs) == 0
+			if !required || spec.Default != "" || chainProvidedInputs[spec.Name] {
+				continue
+			}
+			if _, ok := step.Input[spec.Name]; !ok {
+				problems = append(problems, fmt.Sprintf("chain '%s' step %d (%s): input '%s' is not set", name, i+1, roleKey, spec.Name))
+			}
+		}
+		if len(role.Inputs) == 0 {
+			continue
+		}
+		var unknown []string
+		for k := range step.Input {
+			if !declared[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			problems = append(problems, fmt.Sprintf("chain '%s' step %d (%s): input '%s' is not declared by the role", name, i+1, roleKey, k))
+		}
+	}
+	return append(problems, lintOutputCollisions(name, chain)...)
+}
+
+// reservedContextKeys are chain context entries set by ExecuteChain itself.
+var reservedContextKeys = map[string]bool{"steps": true, "tool_call": true, "before_hooks": true, "after_hooks": true}
+
+// lintOutputCollisions reports steps that overwrite each other's output: a
+// shared output_key (unless every writer appends), a shared step name in the
+// steps namespace, or an output_key that shadows a chain var or reserved key.
+func lintOutputCollisions(name string, chain types.RoleChain) []string {
+	var problems []string
+	writers := map[string][]int{}
+	appendOnly := map[string]bool{}
+	names := map[string][]int{}
+	var keys, stepNames []string
+	for i, step := range chain.Steps {
+		roleKey := step.Role
+		if roleKey == "" {
+			roleKey = step.Name
+		}
+		key := stepKey(step, roleKey)
+		if len(names[key]) == 0 {
+			stepNames = append(stepNames, key)
+		}
+		names[key] = append(names[key], i+1)
+		if step.OutputKey == "" {
+			continue
+		}
+		if len(writers[step.OutputKey]) == 0 {
+			keys = append(keys, step.OutputKey)
+			appendOnly[step.OutputKey] = true
+		}
+		writers[step.OutputKey] = append(writers[step.OutputKey], i+1)
+		if step.OutputMode != types.OutputModeAppend {
+			appendOnly[step.OutputKey] = false
+		}
+		if reservedContextKeys[step.OutputKey] {
+			problems = append(problems, fmt.Sprintf("chain '%s' step %d: output_key '%s' is reserved by the chain engine", name, i+1, step.OutputKey))
+		} else if _, ok := chain.Vars[step.OutputKey]; ok {
+			problems = append(problems, fmt.Sprintf("chain '%s' step %d: output_key '%s' overwrites the chain var of the same name", name, i+1, step.OutputKey))
+		}
+	}
+	for _, key := range keys {
+		if steps := writers[key]; len(steps) > 1 && !appendOnly[key] {
+			problems = append(problems, fmt.Sprintf("chain '%s': output_key '%s' is written by steps %s; later steps overwrite earlier output (use steps.<name>.output or output_mode: append)", name, key, joinInts(steps)))
+		}
+	}
+	for _, key := range stepNames {
+		if steps := names[key]; len(steps) > 1 {
+			problems = append(problems, fmt.Sprintf("chain '%s': steps %s share the name '%s'; steps.%s.output only holds the last one (set distinct names)", name, joinInts(steps), key, key))
+		}
+	}
+	return problems
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,143 @@
+package roles
+
+import (
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/types"
+)
+
+func TestTemplateVariables_NestedAndConditional(t *testing.T) {
+	prompt := `Task: {{.task}}
+{{if .context}}Context: {{.context}}{{else}}{{printf "%s" .fallback}}{{end}}
+{{range .files}}- {{.Name}}{{end}}
+{{with .meta}}{{.Author}}{{end}}`
+	got := strings.Join(templateVariables(prompt), ",")
+	if got != "task,context,fallback,files,meta" {
+		t.Errorf("unexpected variables: %s", got)
+	}
+	if got := strings.Join(templateVariables("{{.broken"), ","); got != "broken" {
+		t.Errorf("expected regex fallback for unparsable prompt, got %s", got)
+	}
+}
+
+func TestResolveInputs(t *testing.T) {
+	role := types.Role{Inputs: []types.RoleInput{
+		{Name: "task", Required: true},
+		{Name: "retries", Type: types.InputTypeInt, Default: "3"},
+		{Name: "strict", Type: types.InputTypeBool},
+	}}
+
+	resolved, err := ResolveInputs(role, map[string]interface{}{"task": "x", "strict": "true"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if resolved["retries"] != 3 || resolved["strict"] != true {
+		t.Errorf("expected defaults and type conversion, got %v", resolved)
+	}
+
+	if _, err := ResolveInputs(role, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "task") {
+		t.Errorf("expected missing required input error, got %v", err)
+	}
+	if _, err := ResolveInputs(role, map[string]interface{}{"task": "x", "retries": "many"}); err == nil {
+		t.Errorf("expected type error for non-int value")
+	}
+}
+
+func TestFormatInputHelp(t *testing.T) {
+	role := types.Role{Prompt: "{{.task}} {{.lang}}", Inputs: []types.RoleInput{
+		{Name: "task", Description: "What to build", Required: true, Example: "a parser"},
+		{Name: "lang", Default: "go"},
+	}}
+	help := FormatInputHelp("coder", role)
+	for _, want := range []string{
+		"Usage: ai-team role coder task=<string> [lang=<string>]",
+		"(required, string)",
+		"What to build",
+		"Example: a parser",
+		"(string, default: go)",
+		"ai-team role coder 'task=a parser'\n",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help lacks %q:\n%s", want, help)
+		}
+	}
+
+	help = FormatInputHelp("free", types.Role{Prompt: "Do {{.task}}"})
+	if !strings.Contains(help, "declares no inputs") || !strings.Contains(help, "ai-team role free 'task=<task>'") {
+		t.Errorf("help of an undeclared role:\n%s", help)
+	}
+}
+
+func TestRenderPrompt_AppliesDefaults(t *testing.T) {
+	role := types.Role{Prompt: "Use {{.lang}}", Inputs: []types.RoleInput{{Name: "lang", Default: "go"}}}
+	prompt, err := RenderPrompt(role, map[string]interface{}{})
+	if err != nil || prompt != "Use go" {
+		t.Errorf("expected default to be rendered, got %q (%v)", prompt, err)
+	}
+}
+
+func TestLintChain(t *testing.T) {
+	cfg := &config.Config{
+		Roles: map[string]types.Role{
+			"coder": {Model: "m", Prompt: "{{.design}}", Inputs: []types.RoleInput{
+				{Name: "design", Required: true},
+				{Name: "lang", Required: true, Default: "go"},
+			}},
+			"tester": {Model: "m", Prompt: "Test {{.code}} given {{.lastToolResponse}} ({{.lastToolResponse_json}})"},
+		},
+		Chains: map[string]types.RoleChain{
+			"build": {Steps: []types.ChainRole{
+				{Role: "coder", Input: map[string]interface{}{"desing": "{{.design}}"}},
+				{Role: "tester"},
+			}},
+		},
+	}
+	problems := LintChain(cfg, "build")
+	want := []string{
+		"step 1 (coder): input 'design' is not set",
+		"step 1 (coder): input 'desing' is not declared",
+		"step 2 (tester): input 'code' is not set",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i], w) {
+			t.Errorf("problem %d: expected %q, got %q", i, w, problems[i])
+		}
+	}
+}
+
+func TestLintChain_OutputCollisions(t *testing.T) {
+	cfg := &config.Config{
+		Roles: map[string]types.Role{"r": {Model: "m", Prompt: "go"}},
+		Chains: map[string]types.RoleChain{
+			"c": {
+				Vars: map[string]interface{}{"target": "x"},
+				Steps: []types.ChainRole{
+					{Role: "r", OutputKey: "result"},
+					{Role: "r", OutputKey: "result"},
+					{Name: "notes", Role: "r", OutputKey: "log", OutputMode: types.OutputModeAppend},
+					{Name: "more", Role: "r", OutputKey: "log", OutputMode: types.OutputModeAppend},
+					{Name: "t", Role: "r", OutputKey: "target"},
+				},
+			},
+		},
+	}
+	problems := LintChain(cfg, "c")
+	want := []string{
+		"output_key 'target' overwrites the chain var",
+		"output_key 'result' is written by steps 1, 2",
+		"steps 1, 2 share the name 'r'",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i], w) {
+			t.Errorf("problem %d: expected %q, got %q", i, w, problems[i])
+		}
+	}
+}
@@ -1,17 +1,24 @@
 package roles
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
+	"strings"
 	"time"
 
 	"ai-team/config"
 	"ai-team/pkg/ai"
 	"ai-team/pkg/cli"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/notify"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
 	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
+	"ai-team/pkg/usage"
 )
 
 // Session represents an interactive role-playing session.
@@ -25,12 +32,35 @@ type Session struct {
 	Transcript     *types.Transcript
 	TranscriptPath string
 	Yes            bool
+	HistoryPath    string           // Where entered input values are remembered; empty disables history
+	Policy         *tools.Policy    // When set, decides which tool calls are allowed, denied or need approval
+	RunID          string           // Identifies the session in logs, transcripts and tool environments; generated when empty
+	Notifier       *notify.Notifier // Announces tool calls waiting for approval; nil disables
+	Stream         io.Writer        // Receives model output as it arrives; nil waits for whole responses
+	Resume         *SessionCheckpoint // When set, the session goes on from this checkpoint instead of asking for a role and inputs
+
+	// Per-session state used by slash commands and --yes guardrails
+	role         *types.Role
+	inputs       map[string]interface{}
+	toolRegistry *tools.ToolRegistry
+	conversation *types.Conversation // Set for roles with conversation: true
+	undo         []undoEntry
+	llmCalls     int
+	approxTokens int
+	started      time.Time // When the session started, for the status line
+	iteration    int       // Tool-call iterations so far, of MaxIterations
+	autoApproved int    // Destructive calls approved by --yes so far
+	snapshotRef  string // Git snapshot taken before the first auto-approved change
 }
 
 // ExecuteRoleFunc is a variable that holds the function to execute a role.
 // It can be replaced in tests for mocking.
 var ExecuteRoleFunc = ExecuteRole
 
+// ExecuteRoleContextFunc executes a role for sessions that stream model
+// output. It can be replaced in tests for mocking.
+var ExecuteRoleContextFunc = ExecuteRoleContext
+
 // NewToolCallExtractorFunc is a variable that holds the function to create a new tool call extractor.
 // It can be replaced in tests for mocking.
 var NewToolCallExtractorFunc = ai.NewDefaultToolCallExtractor
@@ -39,37 +69,93 @@ var NewToolCallExtractorFunc = ai.NewDefaultToolCallExtractor
 func StartSession(session *Session) {
 	fmt.Printf("Interactive session starting with options: %+v\n", session)
 
-	confirm, err := session.UI.Confirm("Start session?")
+	confirm, err := session.UI.Confirm(i18n.T("session.start"))
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return
 	}
 
 	if !confirm {
-		fmt.Println("Session aborted.")
+		fmt.Println(i18n.T("session.aborted"))
 		return
 	}
 
+	if session.RunID == "" {
+		session.RunID = runs.NewID()
+	}
+	defer beginRun(session.RunID)()
+	fmt.Println(i18n.T("run.id", session.RunID))
+
 	// Create a new tool registry
 	toolRegistry := tools.NewToolRegistry()
 
 	tools.RegisterDefaultTools(toolRegistry)
-
-	// Get the role from the user
-	selectedRole, err := getRole(session)
-	if err != nil {
+	if err := tools.RegisterConfigTools(toolRegistry, session.Config.Tools); err != nil {
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+	}
+	if session.Config.DocumentsDir != "" {
+		toolRegistry.Documents().Dir = session.Config.DocumentsDir
+	}
+	if session.Config.Memory.Path != "" {
+		toolRegistry.Memory().Path = session.Config.Memory.Path
+	}
+	toolRegistry.Memory().MaxFacts = session.Config.Memory.MaxFacts
+	toolRegistry.HTTP().Configure(session.Config.HTTPRequest)
+	mcpClients := tools.ConnectMCPServers(context.Background(), session.Config.MCPServers, toolRegistry)
+	defer mcpClients.Close()
+	session.toolRegistry = toolRegistry
+
+	// Get the role from the user, or from the checkpoint being resumed
+	var selectedRole string
+	if session.Resume != nil {
+		selectedRole = session.Resume.Role
+		if _, ok := session.Config.Roles[selectedRole]; !ok {
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", fmt.Errorf("role not found: %s", selectedRole))))
+			return
+		}
+	} else if selectedRole, err = getRole(session); err != nil {
 		fmt.Printf("Error getting role: %v\n", err)
 		return
 	}
 
+	usage.Default.SetScope(usage.Scope{Role: selectedRole})
+	defer session.printUsage()
+	session.started = time.Now()
+
 	role := session.Config.Roles[selectedRole]
+	if session.Model != "" {
+		role.Model = session.Model
+	}
+	session.role = &role
+	if role.Conversation {
+		session.conversation = &types.Conversation{}
+	}
 
 	session.Transcript = &types.Transcript{
+		RunID:     session.RunID,
 		Role:      selectedRole,
+		ToolsHash: toolRegistry.Hash(),
 		StartedAt: time.Now(),
 		Steps:     []types.Step{},
 	}
 
+	if cp := session.Resume; cp != nil {
+		if cp.Steps != nil {
+			session.Transcript.Steps = cp.Steps
+		}
+		if session.conversation != nil {
+			session.conversation.Messages = cp.Conversation
+		}
+		if cp.Inputs == nil {
+			cp.Inputs = map[string]interface{}{}
+		}
+		session.inputs = cp.Inputs
+		fmt.Println(i18n.T("session.resumed", selectedRole, cp.ToolCall.Name))
+		handleToolCall(session, toolRegistry, cp.ToolCall, &role, cp.Inputs)
+		session.writeTranscript()
+		return
+	}
+
 	// Get the inputs from the user
 	inputs, err := getInputs(session, &role)
 	if err != nil {
@@ -78,7 +164,7 @@ func StartSession(session *Session) {
 	}
 
 	// Execute the role
-	output, err := ExecuteRoleFunc(role, inputs, session.Config, "")
+	output, err := session.callRole(role, inputs)
 	if err != nil {
 		fmt.Printf("Error executing role: %v\n", err)
 		return	
@@ -87,25 +173,52 @@ func StartSession(session *Session) {
 	// Extract the tool call from the output
 	toolCall, _, err := NewToolCallExtractorFunc(toolRegistry).ExtractToolCall(output)
 	if err != nil {
-		fmt.Println("Role output:")
+		fmt.Println(i18n.T("session.role_output"))
 		session.UI.Pager(output)
 		return
 	}
 
 	// Handle the tool call
 	handleToolCall(session, toolRegistry, toolCall, &role, inputs)
+	session.writeTranscript()
+}
 
-	// Write transcript if path is provided
+// writeTranscript writes the transcript if a path is provided.
+func (session *Session) writeTranscript() {
 	if session.TranscriptPath != "" {
-		err := writeTranscript(session.TranscriptPath, session.Transcript)
+		session.Transcript.Cost = session.costSummary()
+		session.Transcript.Conversation = session.conversation.History()
+		path := runs.ExpandRunID(session.TranscriptPath, session.RunID)
+		err := writeTranscript(path, session.Transcript)
 		if err != nil {
 			fmt.Printf("Error writing transcript: %v\n", err)
 		} else {
-			fmt.Printf("Transcript written to: %s\n", session.TranscriptPath)
+			fmt.Println(i18n.T("session.transcript_written", path))
 		}
 	}
 }
 
+// costSummary returns the provider usage of the process, which runs one session.
+func (session *Session) costSummary() *types.CostSummary {
+	currency := "USD"
+	if session.Config != nil {
+		currency = session.Config.Currency()
+	}
+	summary := usage.Default.Totals(currency)
+	return &summary
+}
+
+// printUsage prints the provider usage of the session by model and role, if
+// any calls were made.
+func (session *Session) printUsage() {
+	summary := session.costSummary()
+	if summary.Calls == 0 {
+		return
+	}
+	fmt.Println(i18n.T("run.usage", summary))
+	fmt.Print(usage.Default.Report(summary.Currency))
+}
+
 func writeTranscript(filePath string, transcript *types.Transcript) error {
 	data, err := json.MarshalIndent(transcript, "", "  ")
 	if err != nil {
@@ -120,7 +233,19 @@ func writeTranscript(filePath string, transcript *types.Transcript) error {
 }
 
 func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall *types.ToolCall, role *types.Role, inputs map[string]interface{}) {
-	for i := 0; i < session.MaxIterations; i++ {
+	for i := 0; ; i++ {
+		// A final answer ends the session; there is nothing to approve.
+		if toolCall.Name == tools.FinalAnswerName {
+			answer := toolCall.Arguments["answer"]
+			fmt.Println(i18n.T("session.final_answer"))
+			session.UI.Pager(toolResultText(answer))
+			session.Transcript.Steps = append(session.Transcript.Steps, types.Step{ToolCall: toolCall, Approved: true, Result: answer})
+			return
+		}
+		if i >= session.MaxIterations && !session.onMaxIterations(toolCall, role, inputs) {
+			return
+		}
+		session.iteration = i + 1
 		// Pretty-print the tool call
 		session.UI.PrettyJSON(toolCall)
 
@@ -131,22 +256,39 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 		}
 
 		var selectedOption string
-		if session.Yes {
-			selectedOption = "Approve & execute"
+		var autoApprove bool
+		if session.Policy != nil {
+			action, _ := session.Policy.Decide(tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments})
+			if action == tools.PolicyDeny {
+				fmt.Println(i18n.T("approval.denied", toolCall.Name))
+				session.Transcript.Steps = append(session.Transcript.Steps, step)
+				return
+			}
+			autoApprove = action == tools.PolicyAllow && session.autoApproveGuardrails(toolCall)
 		} else {
-			options := []string{"Approve & execute", "Edit tool_call JSON", "Reject", "Ask LLM to re-plan"}
+			autoApprove = session.canAutoApprove(toolCall)
+		}
+		optApprove, optEdit, optReject, optReplan, optUndo := i18n.T("approval.approve"), i18n.T("approval.edit"), i18n.T("approval.reject"), i18n.T("approval.replan"), i18n.T("approval.undo")
+		if autoApprove {
+			selectedOption = optApprove
+		} else {
+			options := []string{optApprove, optEdit, optReject, optReplan}
+			if len(session.undo) > 0 {
+				options = append(options, optUndo)
+			}
+			session.Notifier.Notify(i18n.T("notify.title"), i18n.T("notify.approval", toolCall.Name))
 			var err error
 			selectedOption, err = session.UI.PromptSelect(options)
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 				session.Transcript.Steps = append(session.Transcript.Steps, step) // Record step before returning
 				return
 			}
 		}
 
 		switch selectedOption {
-		case "Approve & execute":
-			result, continueLoop := approveAndExecute(session, toolRegistry, toolCall, session.DryRun)
+		case optApprove:
+			result, continueLoop := approveAndExecute(session, toolRegistry, toolCall, session.DryRun, autoApprove)
 			step.Approved = true
 			step.Result = result
 			if !continueLoop {
@@ -154,29 +296,37 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 				return
 			}
 			inputs["tool_output"] = result
-		case "Edit tool_call JSON":
-			toolCall = editToolCall(session, toolCall)
+			session.conversation.AddToolResult(toolCall.Name, toolResultText(result))
+		case optEdit:
+			toolCall = editToolCall(session.UI, toolCall)
 			session.Transcript.Steps = append(session.Transcript.Steps, step) // Record step after edit
 			continue
-		case "Reject":
-			fmt.Println("Tool call rejected.")
+		case optUndo:
+			if path, err := undoLast(session); err != nil {
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+			} else {
+				fmt.Println(i18n.T("approval.reverted", path))
+			}
+			continue
+		case optReject:
+			fmt.Println(i18n.T("approval.rejected"))
 			session.Transcript.Steps = append(session.Transcript.Steps, step)
 			return
-		case "Ask LLM to re-plan":
+		case optReplan:
 			// Get the new instruction from the user
-			fmt.Println("Enter new instruction:")
-			newInstruction, err := session.UI.OpenEditor("")
+			fmt.Println(i18n.T("session.new_instruction"))
+			newInstruction, err := readMessage(session)
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 				session.Transcript.Steps = append(session.Transcript.Steps, step)
 				return
 			}
 
 			// Execute the role again with the new instruction
 			inputs["instruction"] = newInstruction
-			output, err := ExecuteRoleFunc(*role, inputs, session.Config, "")
+			output, err := session.callRole(*role, inputs)
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 				session.Transcript.Steps = append(session.Transcript.Steps, step)
 				return
 			}
@@ -185,7 +335,7 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 			// Extract the tool call from the output
 			newToolCall, _, err := NewToolCallExtractorFunc(toolRegistry).ExtractToolCall(output)
 			if err != nil {
-				fmt.Println("Role output:")
+				fmt.Println(i18n.T("session.role_output"))
 				session.UI.Pager(output)
 				session.Transcript.Steps = append(session.Transcript.Steps, step)
 				return
@@ -196,9 +346,9 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 		}
 
 		// If we approved and executed, now get the next LLM output
-		output, err := ExecuteRoleFunc(*role, inputs, session.Config, "")
+		output, err := session.callRole(*role, inputs)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 			session.Transcript.Steps = append(session.Transcript.Steps, step)
 			return
 		}
@@ -206,7 +356,7 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 
 		newToolCall, _, err := NewToolCallExtractorFunc(toolRegistry).ExtractToolCall(output)
 		if err != nil {
-			fmt.Println("Role output:")
+			fmt.Println(i18n.T("session.role_output"))
 			session.UI.Pager(output)
 			session.Transcript.Steps = append(session.Transcript.Steps, step)
 			return
@@ -216,54 +366,58 @@ func handleToolCall(session *Session, toolRegistry *tools.ToolRegistry, toolCall
 	}
 }
 
-func approveAndExecute(session *Session, toolRegistry *tools.ToolRegistry, toolCall *types.ToolCall, dryRun bool) (interface{}, bool) {
+func approveAndExecute(session *Session, toolRegistry *tools.ToolRegistry, toolCall *types.ToolCall, dryRun bool, autoApprove bool) (interface{}, bool) {
 	if dryRun {
-		fmt.Println("DRY RUN: Tool call would be:")
+		fmt.Println(i18n.T("approval.dry_run_call"))
 		session.UI.PrettyJSON(toolCall)
 
 		if toolCall.Name == "write_file" || toolCall.Name == "WriteFile" {
-			filePath, ok := toolCall.Arguments["file_path"].(string)
-			if !ok {
-				fmt.Printf("Error: Missing or invalid 'file_path' argument for write_file tool.\n")
+			filePath, content, _, err := tools.WriteTarget(tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments})
+			if err != nil {
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 				return nil, false
 			}
-			content, ok := toolCall.Arguments["content"].(string)
-			if !ok {
-				fmt.Printf("Error: Missing or invalid 'content' argument for write_file tool.\n")
+			if filePath == "" {
+				fmt.Printf("Error: Missing or invalid 'file_path' argument for write_file tool.\n")
 				return nil, false
 			}
 			oldContent := tools.ReadFileOrEmpty(filePath)
 			diff := tools.GenerateUnifiedDiff(filePath, oldContent, content)
-			fmt.Println("DRY RUN: Diff:")
-			fmt.Println(diff)
+			fmt.Println(i18n.T("approval.dry_run_diff"))
+			session.UI.ShowDiff(diff)
 		}
 
 		return nil, true
 	}
 
+	// Resolve the written file once, the way the tool does, for the diff,
+	// the backup and the undo entry
+	filePath, content, isWrite, err := tools.WriteTarget(tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments})
+	if err != nil {
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+		return nil, false
+	}
+	var undo *undoEntry
+	if isWrite {
+		undo = snapshotForUndo(filePath)
+	}
 	if toolCall.Name == "write_file" || toolCall.Name == "WriteFile" {
-		filePath, ok := toolCall.Arguments["file_path"].(string)
-		if !ok {
+		if filePath == "" {
 			fmt.Printf("Error: Missing or invalid 'file_path' argument for write_file tool.\n")
 			return nil, false
 		}
-		content, ok := toolCall.Arguments["content"].(string)
-		if !ok {
-			fmt.Printf("Error: Missing or invalid 'content' argument for write_file tool.\n")
-			return nil, false
-		}
 		oldContent := tools.ReadFileOrEmpty(filePath)
 		diff := tools.GenerateUnifiedDiff(filePath, oldContent, content)
-		fmt.Println("Diff:")
-		fmt.Println(diff)
+		fmt.Println(i18n.T("approval.diff"))
+		session.UI.ShowDiff(diff)
 
-		confirm, err := session.UI.Confirm("Apply this change?")
+		confirm, err := confirmUnlessAuto(session, autoApprove, i18n.T("approval.apply_change"))
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 			return nil, false
 		}
 		if !confirm {
-			fmt.Println("Change rejected.")
+			fmt.Println(i18n.T("approval.change_rejected"))
 			return nil, false
 		}
 
@@ -273,7 +427,7 @@ func approveAndExecute(session *Session, toolRegistry *tools.ToolRegistry, toolC
 			return nil, false
 		}
 		if backupPath != "" {
-			fmt.Printf("Backup created at: %s\n", backupPath)
+			fmt.Println(i18n.T("approval.backup_created", backupPath))
 		}
 	}
 
@@ -283,50 +437,65 @@ func approveAndExecute(session *Session, toolRegistry *tools.ToolRegistry, toolC
 			fmt.Printf("Error: Missing or invalid 'command' argument for run_command tool.\n")
 			return nil, false
 		}
-		fmt.Printf("Command to execute: %s\n", command)
+		fmt.Println(i18n.T("approval.command", command))
 
-		confirm, err := session.UI.Confirm("Execute this command?")
+		confirm, err := confirmUnlessAuto(session, autoApprove, i18n.T("approval.execute_command"))
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 			return nil, false
 		}
 		if !confirm {
-			fmt.Println("Command rejected.")
+			fmt.Println(i18n.T("approval.command_rejected"))
 			return nil, false
 		}
 	}
 
 	// Execute the tool call
-	toolExecutor := &tools.ToolExecutor{Registry: toolRegistry}
-	result, err := toolExecutor.Execute(tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments})
+	toolExecutor := &tools.ToolExecutor{Registry: toolRegistry, Ignore: ignoreFilterFor(session.Config), Lock: workspaceLockFor(session.Config), Journal: journalFor(session.Config), Env: toolEnv(session.Config, types.EnvConfig{}), PostProcess: postProcessorsFor(session.Config)}
+	if session.Config != nil {
+		sandbox, err := tools.NewSandbox(session.Config.Sandbox)
+		if err != nil {
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+			return nil, false
+		}
+		toolExecutor.Sandbox = sandbox
+	}
+	author := tools.Author{RunID: session.RunID}
+	if session.Transcript != nil {
+		author.Role = session.Transcript.Role
+	}
+	result, err := toolExecutor.Execute(tools.WithAuthor(context.Background(), author), tools.ToolCall{Name: toolCall.Name, Arguments: toolCall.Arguments})
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return nil, false
 	}
+	if undo != nil {
+		session.undo = append(session.undo, *undo)
+	}
 
-	fmt.Println("Tool output:")
+	fmt.Println(i18n.T("approval.tool_output"))
 	session.UI.Pager(fmt.Sprintf("%v", result))
 	return result, true
 }
 
-func editToolCall(session *Session, toolCall *types.ToolCall) *types.ToolCall {
+func editToolCall(ui cli.UI, toolCall *types.ToolCall) *types.ToolCall {
 	// Open the editor to edit the tool call JSON
 	jsonBytes, err := json.MarshalIndent(toolCall, "", "  ")
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return toolCall
 	}
 
-	editedJSON, err := session.UI.OpenEditor(string(jsonBytes))
+	editedJSON, err := ui.OpenEditor(string(jsonBytes))
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return toolCall
 	}
 
 	// Parse the edited JSON
 	var editedToolCall types.ToolCall
 	if err := json.Unmarshal([]byte(editedJSON), &editedToolCall); err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return toolCall
 	}
 
@@ -351,50 +520,210 @@ func getRole(session *Session) (string, error) {
 
 func getInputs(session *Session, role *types.Role) (map[string]interface{}, error) {
 	inputs := make(map[string]interface{})
+	session.inputs = inputs
 
-	// Get the inputs required by the role by parsing the prompt
-	re := regexp.MustCompile(`{{\.(.*?)}}`)
-	matches := re.FindAllStringSubmatch(role.Prompt, -1)
+	var history *InputHistory
+	if session.HistoryPath != "" {
+		h, err := LoadInputHistory(session.HistoryPath)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			history = h
+		}
+	}
+	roleName := ""
+	if session.Transcript != nil {
+		roleName = session.Transcript.Role
+	}
 
-	for _, match := range matches {
-		inputName := match[1]
+	for _, spec := range InputSpecs(*role) {
+		var recent []string
+		if history != nil {
+			recent = history.Recent(roleName, spec.Name)
+		}
+		for {
+			raw, err := promptInput(session, spec, recent)
+			if err != nil {
+				return nil, err
+			}
+			if raw == "" && spec.Required {
+				fmt.Printf("Input '%s' is required.\n", spec.Name)
+				continue
+			}
+			value, err := spec.ParseValue(raw)
+			if err != nil {
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+				continue
+			}
+			inputs[spec.Name] = value
+			if history != nil {
+				history.Add(roleName, spec.Name, raw)
+			}
+			break
+		}
+	}
+
+	if history != nil {
+		if err := history.Save(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return inputs, nil
+}
 
-		// Prompt the user for the input
-		fmt.Printf("Enter value for input '%s': ", inputName)
-		value, err := session.UI.OpenEditor("")
+const (
+	inputOptionInline = "Type inline"
+	inputOptionEditor = "Open editor"
+)
+
+// promptInput asks for a single input value, offering the role default and
+// previously used values before inline entry or the editor.
+func promptInput(session *Session, spec types.RoleInput, recent []string) (string, error) {
+	if session.Yes && spec.Default != "" {
+		return spec.Default, nil
+	}
+
+	label := fmt.Sprintf("Enter value for input '%s'", spec.Name)
+	if spec.Type != "" && spec.Type != types.InputTypeString {
+		label += fmt.Sprintf(" [%s]", spec.Type)
+	}
+	if spec.Description != "" {
+		label += fmt.Sprintf(" (%s)", spec.Description)
+	}
+	fmt.Println(label + ":")
+
+	choices := make(map[string]string)
+	var options []string
+	addChoice := func(prefix, value string) {
+		option := prefix + inputPreview(value)
+		if _, dup := choices[option]; !dup {
+			choices[option] = value
+			options = append(options, option)
+		}
+	}
+	if spec.Default != "" {
+		addChoice("Default: ", spec.Default)
+	}
+	for _, v := range recent {
+		if v != spec.Default {
+			addChoice("Previous: ", v)
+		}
+	}
+	options = append(options, inputOptionInline, inputOptionEditor)
+
+	for {
+		choice, err := session.UI.PromptSelect(options)
 		if err != nil {
-			return nil, err
+			return "", err
+		}
+		if value, ok := choices[choice]; ok {
+			return value, nil
+		}
+		switch {
+		case choice == inputOptionInline:
+			return readLine(session, spec.Name+": ")
+		case choice == inputOptionEditor || choice == "":
+			return readMessageFrom(session, func() (string, error) { return session.UI.OpenEditor(spec.Default) })
+		case isSlashCommand(choice):
+			if err := handleSlashCommand(session, choice); err != nil {
+				fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+			}
+		default:
+			// A value typed directly at the selection prompt
+			return unescapeSlash(choice), nil
 		}
-		inputs[inputName] = value
 	}
+}
 
-	return inputs, nil
+// inputPreview shortens a value to a single line for display in a menu.
+func inputPreview(value string) string {
+	line := strings.SplitN(value, "\n", 2)[0]
+	if len(line) > 60 {
+		line = line[:57] + "..."
+	} else if line != value {
+		line += " ..."
+	}
+	return line
 }
 
 func askLLMToReplan(session *Session, toolRegistry *tools.ToolRegistry, role *types.Role, inputs map[string]interface{}) *types.ToolCall {
 	// Get the new instruction from the user
-	fmt.Println("Enter new instruction:")
-	newInstruction, err := session.UI.OpenEditor("")
+	fmt.Println(i18n.T("session.new_instruction"))
+	newInstruction, err := readMessage(session)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return nil
 	}
 
 	// Execute the role again with the new instruction
 	inputs["instruction"] = newInstruction
-	output, err := ExecuteRole(*role, inputs, session.Config, "")
+	output, err := session.callRole(*role, inputs)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
 		return nil
 	}
 
 	// Extract the tool call from the output
 	newToolCall, _, err := ai.NewDefaultToolCallExtractor(toolRegistry).ExtractToolCall(output)
 	if err != nil {
-		fmt.Println("Role output:")
+		fmt.Println(i18n.T("session.role_output"))
 		session.UI.Pager(output)
 		return nil
 	}
 
 	return newToolCall
-}
\ No newline at end of file
+}
+
+// callRole executes the role, with the session's conversation when the role
+// keeps one, and tracks usage for /cost.
+func (session *Session) callRole(role types.Role, inputs map[string]interface{}) (string, error) {
+	if session.toolRegistry != nil {
+		inputs = withToolsPrompt(role, inputs, session.toolRegistry)
+	}
+	var output string
+	var err error
+	if session.Stream != nil || session.conversation != nil {
+		ctx := context.Background()
+		if session.conversation != nil {
+			ctx = ai.WithConversation(ctx, session.conversation)
+		}
+		if session.Stream != nil {
+			ctx = ai.WithStream(ctx, ai.StreamWriter(session.Stream))
+		}
+		output, err = ExecuteRoleContextFunc(ctx, role, inputs, session.Config, "")
+		if session.Stream != nil {
+			fmt.Fprintln(session.Stream)
+		}
+	} else {
+		output, err = ExecuteRoleFunc(role, inputs, session.Config, "")
+	}
+	session.llmCalls++
+	if prompt, renderErr := RenderPrompt(role, inputs); renderErr == nil {
+		session.approxTokens += (len(prompt) + len(output)) / 4
+	}
+	fmt.Println(render.Stdout().Paint(render.Status, session.statusLine()))
+	return output, err
+}
+
+// statusLine returns the session's progress after a model call: elapsed
+// time, tokens, cost and the iteration of MaxIterations. Tokens are the
+// estimate of /cost when no provider reported usage.
+func (session *Session) statusLine() string {
+	summary := session.costSummary()
+	tokens := fmt.Sprint(summary.InputTokens + summary.OutputTokens)
+	if summary.InputTokens+summary.OutputTokens == 0 {
+		tokens = fmt.Sprintf("~%d", session.approxTokens)
+	}
+	cost := i18n.T("session.status_cost_unknown")
+	switch {
+	case summary.Calls > 0 && summary.Complete:
+		cost = i18n.T("session.status_cost", summary.Cost, summary.Currency)
+	case summary.Cost > 0:
+		cost = i18n.T("session.status_cost_partial", summary.Cost, summary.Currency)
+	}
+	var elapsed time.Duration
+	if !session.started.IsZero() {
+		elapsed = time.Since(session.started).Round(time.Second)
+	}
+	return i18n.T("session.status", elapsed, tokens, cost, session.iteration, session.MaxIterations)
+}
@@ -4,6 +4,7 @@ import (
 	"ai-team/pkg/ai"
 	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
+	"context"
 	"fmt"
 )
 
@@ -45,7 +46,7 @@ type MockTool struct {
 	ExecuteFunc func(args map[string]interface{}) (interface{}, error)
 }
 
-func (m *MockTool) Execute(args map[string]interface{}) (interface{}, error) {
+func (m *MockTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
 	if m.ExecuteFunc != nil {
 		return m.ExecuteFunc(args)
 	}
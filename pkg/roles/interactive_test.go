@@ -2,10 +2,18 @@ package roles
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
 )
 
 // MockUI is a mock implementation of the UI interface.
@@ -13,8 +21,10 @@ type MockUI struct {
 	ConfirmFunc      func(prompt string) (bool, error)
 	PromptSelectFunc func(options []string) (string, error)
 	OpenEditorFunc   func(content string) (string, error)
+	PromptLineFunc   func(label string) (string, error)
 	PagerFunc        func(content string) error
 	PrettyJSONFunc   func(obj interface{}) error
+	ShowDiffFunc     func(diff string) error
 }
 
 func (m *MockUI) Confirm(prompt string) (bool, error) {
@@ -38,6 +48,13 @@ func (m *MockUI) OpenEditor(content string) (string, error) {
 	return "", nil
 }
 
+func (m *MockUI) PromptLine(label string) (string, error) {
+	if m.PromptLineFunc != nil {
+		return m.PromptLineFunc(label)
+	}
+	return "", nil
+}
+
 func (m *MockUI) Pager(content string) error {
 	if m.PagerFunc != nil {
 		return m.PagerFunc(content)
@@ -52,6 +69,13 @@ func (m *MockUI) PrettyJSON(obj interface{}) error {
 	return nil
 }
 
+func (m *MockUI) ShowDiff(diff string) error {
+	if m.ShowDiffFunc != nil {
+		return m.ShowDiffFunc(diff)
+	}
+	return nil
+}
+
 func TestStartSession_Abort(t *testing.T) {
 	// Create a mock UI
 	mockUI := &MockUI{
@@ -91,4 +115,113 @@ func captureOutput(f func()) string {
 	var buf bytes.Buffer
 	io.Copy(&buf, r)
 	return buf.String()
-}
\ No newline at end of file
+}
+func TestHandleToolCall_Conversation(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history []types.Message
+	origExec := ExecuteRoleContextFunc
+	ExecuteRoleContextFunc = func(ctx context.Context, role types.Role, input map[string]interface{}, cfg *config.Config, logFilePath string) (string, error) {
+		history = ai.ConversationFrom(ctx).History()
+		return "done", nil
+	}
+	defer func() { ExecuteRoleContextFunc = origExec }()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{UI: &MockUI{}, Policy: policy, MaxIterations: 1, Transcript: &types.Transcript{}, conversation: &types.Conversation{}}
+	call := &types.ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": t.TempDir()}}
+	captureOutput(func() {
+		handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+	})
+
+	if len(history) != 1 || history[0].Role != types.MessageTool || history[0].Name != "list_dir" {
+		t.Errorf("expected the next call to get the tool result in the conversation, got %+v", history)
+	}
+}
+
+func TestHandleToolCall_StatusLine(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origExec := ExecuteRoleFunc
+	ExecuteRoleFunc = func(role types.Role, input map[string]interface{}, cfg *config.Config, logFilePath string) (string, error) {
+		return `{"tool_call": {"name": "list_dir", "arguments": {}}}`, nil
+	}
+	defer func() { ExecuteRoleFunc = origExec }()
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{UI: &MockUI{}, Policy: policy, MaxIterations: 2, Transcript: &types.Transcript{}, started: time.Now()}
+	call := &types.ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": t.TempDir()}}
+	output := captureOutput(func() {
+		handleToolCall(session, reg, call, &types.Role{Prompt: "list files"}, map[string]interface{}{})
+	})
+
+	for _, want := range []string{"iteration 1/2", "iteration 2/2", "Stopped after 2 iterations"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in the output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestHandleToolCall_FinalAnswer(t *testing.T) {
+	var shown string
+	ui := &MockUI{
+		PagerFunc:        func(content string) error { shown = content; return nil },
+		PromptSelectFunc: func([]string) (string, error) { t.Fatal("final answer needs no approval"); return "", nil },
+	}
+	session := &Session{UI: ui, MaxIterations: 1, Transcript: &types.Transcript{}}
+	call := &types.ToolCall{Name: "final_answer", Arguments: map[string]interface{}{"answer": "All tests pass."}}
+	captureOutput(func() {
+		handleToolCall(session, tools.NewToolRegistry(), call, &types.Role{}, map[string]interface{}{})
+	})
+
+	if shown != "All tests pass." {
+		t.Errorf("shown = %q", shown)
+	}
+	if steps := session.Transcript.Steps; len(steps) != 1 || steps[0].Result != "All tests pass." {
+		t.Errorf("steps = %+v", steps)
+	}
+}
+
+func TestHandleToolCall_Ignore(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	os.WriteFile(".env", []byte("TOKEN=x"), 0644)
+	os.WriteFile(".ai-teamignore", []byte("secrets/\n"), 0644)
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{UI: &MockUI{}, Policy: policy, MaxIterations: 1, Transcript: &types.Transcript{}, Config: &config.Config{Ignore: []string{".env"}}}
+	for _, call := range []*types.ToolCall{
+		{Name: "read_file", Arguments: map[string]interface{}{"file_path": ".env"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"file_path": "secrets/key.txt", "content": "x"}},
+	} {
+		output := captureOutput(func() {
+			handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+		})
+		if !strings.Contains(output, "excluded by ignore rules") {
+			t.Errorf("expected %s of an ignored path to be refused, got:\n%s", call.Name, output)
+		}
+	}
+	if _, err := os.Stat("secrets/key.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected no write to an ignored directory, got %v", err)
+	}
+}
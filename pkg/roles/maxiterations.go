@@ -0,0 +1,148 @@
+package roles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/i18n"
+	"ai-team/pkg/render"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+)
+
+// progressSummaryInstruction replaces the instruction of the role when a
+// session asks it to summarize its progress at the iteration limit.
+const progressSummaryInstruction = "The session has reached its iteration limit. Do not call any tools. " +
+	"Summarize what has been done so far, what remains to be done, and the next step you would take."
+
+// SessionCheckpoint is the state of an interactive session saved at its
+// iteration limit, from which role --interactive --resume goes on.
+type SessionCheckpoint struct {
+	RunID        string                 `json:"run_id"`
+	Role         string                 `json:"role"`
+	Model        string                 `json:"model,omitempty"` // --model of the session, if any
+	Inputs       map[string]interface{} `json:"inputs"`
+	ToolCall     *types.ToolCall        `json:"tool_call"` // Proposed by the model, not yet run
+	Steps        []types.Step           `json:"steps"`
+	Conversation []types.Message        `json:"conversation,omitempty"`
+	SavedAt      time.Time              `json:"saved_at"`
+}
+
+// LoadSessionCheckpoint reads a checkpoint written at a session's iteration
+// limit.
+func LoadSessionCheckpoint(path string) (*SessionCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, "failed to read session checkpoint: "+path, err)
+	}
+	var cp SessionCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, "failed to parse session checkpoint: "+path, err)
+	}
+	if cp.Role == "" || cp.ToolCall == nil {
+		return nil, errors.New(errors.ErrCodeConfig, "session checkpoint has no role or pending tool call: "+path, nil)
+	}
+	return &cp, nil
+}
+
+// onMaxIterations handles reaching MaxIterations with toolCall pending, as
+// max_iterations.action configures. It reports whether the session goes on,
+// in which case MaxIterations has been raised.
+func (session *Session) onMaxIterations(toolCall *types.ToolCall, role *types.Role, inputs map[string]interface{}) bool {
+	var cfg types.MaxIterationsConfig
+	if session.Config != nil {
+		cfg = session.Config.MaxIterations
+	}
+	extendBy := cfg.ExtendBy
+	if extendBy <= 0 {
+		extendBy = 10
+	}
+	fmt.Println(render.Stdout().Paint(render.Status, i18n.T("session.max_iterations_reached", session.MaxIterations, toolCall.Name)))
+
+	action := cfg.Action
+	extend := false
+	if action == "" || action == types.MaxIterationsAsk {
+		optExtend, optSummarize, optCheckpoint, optStop := i18n.T("session.max_iterations_extend", extendBy), i18n.T("session.max_iterations_summarize"), i18n.T("session.max_iterations_checkpoint"), i18n.T("session.max_iterations_stop")
+		session.Notifier.Notify(i18n.T("notify.title"), i18n.T("session.max_iterations_reached", session.MaxIterations, toolCall.Name))
+		selected, err := session.UI.PromptSelect([]string{optExtend, optSummarize, optCheckpoint, optStop})
+		if err != nil {
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+		}
+		switch selected {
+		case optExtend:
+			extend = true
+		case optSummarize:
+			action = types.MaxIterationsSummarize
+		case optCheckpoint:
+			action = types.MaxIterationsCheckpoint
+		default:
+			action = types.MaxIterationsStop
+		}
+	}
+	if extend {
+		session.MaxIterations += extendBy
+		fmt.Println(i18n.T("session.max_iterations_extended", session.MaxIterations))
+		return true
+	}
+
+	switch action {
+	case types.MaxIterationsSummarize:
+		session.summarizeProgress(role, inputs)
+	case types.MaxIterationsCheckpoint:
+		path := cfg.Checkpoint
+		if path == "" {
+			path = ".ai-team/sessions/{run_id}.json"
+		}
+		path = runs.ExpandRunID(path, session.RunID)
+		if err := session.saveCheckpoint(path, toolCall, inputs); err != nil {
+			fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+		} else {
+			fmt.Println(i18n.T("session.checkpoint_saved", path))
+		}
+	}
+	fmt.Println(i18n.T("session.max_iterations", session.MaxIterations))
+	return false
+}
+
+// summarizeProgress asks the role to summarize the session without calling
+// tools and shows the answer, which the transcript records as a step.
+func (session *Session) summarizeProgress(role *types.Role, inputs map[string]interface{}) {
+	inputs["instruction"] = progressSummaryInstruction
+	output, err := session.callRole(*role, inputs)
+	if err != nil {
+		fmt.Println(render.Stdout().Paint(render.Error, i18n.T("common.error", err)))
+		return
+	}
+	fmt.Println(i18n.T("session.summary"))
+	session.UI.Pager(output)
+	session.Transcript.Steps = append(session.Transcript.Steps, types.Step{LlmOutput: output})
+}
+
+// saveCheckpoint writes the session with its pending tool call to path.
+func (session *Session) saveCheckpoint(path string, toolCall *types.ToolCall, inputs map[string]interface{}) error {
+	cp := SessionCheckpoint{
+		RunID:        session.RunID,
+		Role:         session.Transcript.Role,
+		Model:        session.Model,
+		Inputs:       inputs,
+		ToolCall:     toolCall,
+		Steps:        session.Transcript.Steps,
+		Conversation: session.conversation.History(),
+		SavedAt:      time.Now(),
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to encode session checkpoint", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to create session checkpoint directory: "+filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to write session checkpoint: "+path, err)
+	}
+	return nil
+}
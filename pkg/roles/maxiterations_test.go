@@ -0,0 +1,115 @@
+package roles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// listDirSession returns a session whose policy allows every call, with a
+// role that keeps proposing list_dir.
+func listDirSession(t *testing.T, cfg types.MaxIterationsConfig) (*Session, *tools.ToolRegistry) {
+	t.Helper()
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("default: allow\n"), 0644)
+	policy, err := tools.LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origExec := ExecuteRoleFunc
+	ExecuteRoleFunc = func(role types.Role, input map[string]interface{}, cfg *config.Config, logFilePath string) (string, error) {
+		if input["instruction"] == progressSummaryInstruction {
+			return "Listed the directory twice; nothing left to do.", nil
+		}
+		return `{"tool_call": {"name": "list_dir", "arguments": {"path": "."}}}`, nil
+	}
+	t.Cleanup(func() { ExecuteRoleFunc = origExec })
+
+	reg := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(reg)
+	session := &Session{
+		UI:            &MockUI{},
+		Policy:        policy,
+		MaxIterations: 1,
+		RunID:         "run1",
+		Config:        &config.Config{MaxIterations: cfg},
+		Transcript:    &types.Transcript{Role: "dev"},
+	}
+	return session, reg
+}
+
+func TestHandleToolCall_MaxIterationsExtend(t *testing.T) {
+	session, reg := listDirSession(t, types.MaxIterationsConfig{Action: types.MaxIterationsAsk, ExtendBy: 2})
+	asked := 0
+	session.UI = &MockUI{PromptSelectFunc: func(options []string) (string, error) {
+		asked++
+		if asked == 1 {
+			return options[0], nil // Extend
+		}
+		return options[len(options)-1], nil // Stop
+	}}
+	call := &types.ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}
+	output := captureOutput(func() {
+		handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+	})
+
+	if asked != 2 || session.iteration != 3 || session.MaxIterations != 3 {
+		t.Errorf("asked %d times, ran %d of %d iterations", asked, session.iteration, session.MaxIterations)
+	}
+	for _, want := range []string{"Reached the limit of 1 iterations", "Iteration limit raised to 3", "Stopped after 3 iterations"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in the output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestHandleToolCall_MaxIterationsCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "{run_id}.json")
+	session, reg := listDirSession(t, types.MaxIterationsConfig{Action: types.MaxIterationsCheckpoint, Checkpoint: path})
+	call := &types.ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}
+	captureOutput(func() {
+		handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{"task": "look around"})
+	})
+
+	cp, err := LoadSessionCheckpoint(strings.ReplaceAll(path, "{run_id}", "run1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Role != "dev" || cp.RunID != "run1" || cp.ToolCall.Name != "list_dir" || cp.Inputs["task"] != "look around" || len(cp.Steps) != 1 {
+		t.Errorf("checkpoint = %+v", cp)
+	}
+
+	// Resuming runs the pending call first.
+	resumed, _ := listDirSession(t, types.MaxIterationsConfig{Action: types.MaxIterationsStop})
+	resumed.Config.Roles = map[string]types.Role{"dev": {}}
+	resumed.UI = &MockUI{ConfirmFunc: func(string) (bool, error) { return true, nil }}
+	resumed.RunID = cp.RunID
+	resumed.Resume = cp
+	output := captureOutput(func() { StartSession(resumed) })
+	if !strings.Contains(output, "Resuming role dev") || len(resumed.Transcript.Steps) != 2 || !resumed.Transcript.Steps[1].Approved {
+		t.Errorf("steps = %+v, output:\n%s", resumed.Transcript.Steps, output)
+	}
+}
+
+func TestHandleToolCall_MaxIterationsSummarize(t *testing.T) {
+	session, reg := listDirSession(t, types.MaxIterationsConfig{Action: types.MaxIterationsSummarize})
+	var shown string
+	session.UI = &MockUI{PagerFunc: func(content string) error { shown = content; return nil }}
+	call := &types.ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}
+	captureOutput(func() {
+		handleToolCall(session, reg, call, &types.Role{}, map[string]interface{}{})
+	})
+
+	if !strings.Contains(shown, "nothing left to do") {
+		t.Errorf("summary shown = %q", shown)
+	}
+	steps := session.Transcript.Steps
+	if len(steps) != 2 || steps[1].ToolCall != nil || !strings.Contains(steps[1].LlmOutput, "nothing left") {
+		t.Errorf("steps = %+v", steps)
+	}
+}
@@ -0,0 +1,45 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/memory"
+	"ai-team/pkg/types"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecuteChain_LoadsMemory(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	var prompt string
+	ai.CallGeminiFunc = func(_ *http.Client, p, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompt = p
+		return "done", nil
+	}
+	cfg.Memory.Path = filepath.Join(t.TempDir(), "memory.json")
+	cfg.Memory.Load = 5
+	cfg.Memory.Tags = []string{"build"}
+	store := memory.NewStore(cfg.Memory.Path)
+	store.Remember(memory.Fact{Text: "Tests run with make test", Tags: []string{"build"}})
+	store.Remember(memory.Fact{Text: "Prefers tabs", Tags: []string{"style"}})
+	role := cfg.Roles["coder"]
+	role.Prompt = "code\n{{.memory}}"
+	cfg.Roles["coder"] = role
+
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Input: map[string]interface{}{"memory": "{{.memory}}"}}}}
+	ctx, err := ExecuteChain(chain, nil, cfg, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ctx["memory"] != "- Tests run with make test [build]\n" || prompt != "code\n- Tests run with make test [build]\n" {
+		t.Errorf("expected the build facts in the context and prompt, got %q and prompt %q", ctx["memory"], prompt)
+	}
+
+	ctx, _ = ExecuteChain(chain, map[string]interface{}{"memory": "given"}, cfg, "")
+	if ctx["memory"] != "given" {
+		t.Errorf("expected a memory input to be kept, got %q", ctx["memory"])
+	}
+}
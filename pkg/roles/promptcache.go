@@ -0,0 +1,68 @@
+package roles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+)
+
+// staticPromptPrefix returns the part of the role's rendered prompt that is the
+// same on every call: the leading literal text of the template and any
+// {{.tools_prompt}} sections within it, up to the first other action. It
+// returns "" when the prompt starts with input-dependent content.
+func staticPromptPrefix(role types.Role, registry *tools.ToolRegistry) string {
+	tmpl, err := template.New("prompt").Parse(role.Prompt)
+	if err != nil || tmpl.Tree == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			b.Write(n.Text)
+			continue
+		case *parse.ActionNode:
+			if isToolsPromptAction(n) {
+				section, _ := registry.PromptSection()
+				b.WriteString(section)
+				continue
+			}
+		}
+		break
+	}
+	return b.String()
+}
+
+// isToolsPromptAction reports whether n is exactly {{.tools_prompt}}.
+func isToolsPromptAction(n *parse.ActionNode) bool {
+	if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	return ok && len(field.Ident) == 1 && field.Ident[0] == ToolsPromptInput
+}
+
+// promptCacheKey identifies a static prompt prefix sent to provider/model, so
+// every step of a role, and roles sharing a prefix, reuse the same cache entry.
+func promptCacheKey(role types.Role, prefix string) string {
+	sum := sha256.Sum256([]byte(role.Provider + "/" + role.Model + "\x00" + prefix))
+	return "ai-team-" + hex.EncodeToString(sum[:8])
+}
+
+// cacheablePrefix returns the static prefix of prompt and its cache key when
+// the role enables prompt caching and prompt starts with the prefix.
+func cacheablePrefix(role types.Role, prompt string, registry *tools.ToolRegistry) (prefix, key string, ok bool) {
+	if !role.PromptCache {
+		return "", "", false
+	}
+	prefix = staticPromptPrefix(role, registry)
+	if strings.TrimSpace(prefix) == "" || !strings.HasPrefix(prompt, prefix) {
+		return "", "", false
+	}
+	return prefix, promptCacheKey(role, prefix), true
+}
@@ -0,0 +1,46 @@
+package roles
+
+import (
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestStaticPromptPrefix(t *testing.T) {
+	section, _ := defaultToolRegistry().PromptSection()
+	tests := []struct {
+		prompt, want string
+	}{
+		{"You are a reviewer.\n{{.tools_prompt}}\nReview {{.file}}", "You are a reviewer.\n" + section + "\nReview "},
+		{"{{.file}} first", ""},
+		{"Static only", "Static only"},
+		{"Intro {{if .x}}a{{end}} rest", "Intro "},
+	}
+	for _, tt := range tests {
+		if got := staticPromptPrefix(types.Role{Prompt: tt.prompt}, defaultToolRegistry()); got != tt.want {
+			t.Errorf("staticPromptPrefix(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func TestCacheablePrefix(t *testing.T) {
+	role := types.Role{Provider: "openai", Model: "gpt", Prompt: "You are a reviewer.\nReview {{.file}}", PromptCache: true}
+	prompt, err := RenderPrompt(role, map[string]interface{}{"file": "a.go"})
+	if err != nil {
+		t.Fatalf("RenderPrompt: %v", err)
+	}
+	prefix, key, ok := cacheablePrefix(role, prompt, defaultToolRegistry())
+	if !ok || prefix != "You are a reviewer.\nReview " || !strings.HasPrefix(key, "ai-team-") {
+		t.Fatalf("unexpected prefix %q key %q (%v)", prefix, key, ok)
+	}
+	other, _ := RenderPrompt(role, map[string]interface{}{"file": "b.go"})
+	if _, key2, _ := cacheablePrefix(role, other, defaultToolRegistry()); key2 != key {
+		t.Errorf("expected the same cache key for every call of the role, got %s and %s", key, key2)
+	}
+
+	role.PromptCache = false
+	if _, _, ok := cacheablePrefix(role, prompt, defaultToolRegistry()); ok {
+		t.Error("expected no cacheable prefix when prompt_cache is off")
+	}
+}
@@ -0,0 +1,159 @@
+package roles
+
+import (
+	"ai-team/config"
+	ai "ai-team/pkg/ai"
+	"ai-team/pkg/cache"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/retrieval"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/logger"
+)
+
+// RetrievedInput is the input key holding the chunks a step with retrieve
+// found. Prompts that do not use it get the chunks appended.
+const RetrievedInput = "retrieved"
+
+// retrievedHeading introduces retrieved chunks appended to a prompt.
+const retrievedHeading = "\n\nRelevant repository files:\n\n"
+
+var (
+	indexMu sync.Mutex
+	indexes = map[string]*retrieval.Index{}
+)
+
+// NewRetrievalEmbedderFunc builds the embedder of the retrieval index from
+// the embeddings API of the retrieval provider's adapter.
+// It can be replaced in tests for mocking.
+var NewRetrievalEmbedderFunc = func(cfg *config.Config) cache.Embedder {
+	provider := cfg.RetrievalProvider()
+	apiURL, apiKey := cfg.ProviderEndpoint(provider)
+	return ai.ProviderEmbedder{
+		Provider: provider,
+		Client:   ai.WithRequestOptions(&http.Client{}, cfg.RequestOptions(provider, "")),
+		Request:  ai.EmbeddingRequest{Model: cfg.Retrieval.Model, APIURL: apiURL, APIKey: apiKey},
+	}
+}
+
+// retrievalIndexFor returns the process-wide index of cfg's retrieval
+// settings, loading it on first use.
+func retrievalIndexFor(cfg *config.Config) (*retrieval.Index, error) {
+	path := cfg.Retrieval.Index
+	if path == "" {
+		path = retrieval.DefaultPath
+	}
+	model := cfg.RetrievalProvider() + "/" + cfg.Retrieval.Model
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if x, ok := indexes[path]; ok && x.Model == model {
+		return x, nil
+	}
+	x, err := retrieval.Load(path, model)
+	if err != nil {
+		return nil, err
+	}
+	indexes[path] = x
+	return x, nil
+}
+
+// retrievalFiles lists the files of the working directory to index: those
+// matching retrieval.paths that no ignore file, ignore pattern or
+// retrieval.exclude leaves out. The .ai-team directory is never indexed.
+func retrievalFiles(cfg *config.Config) ([]string, error) {
+	exclude := append([]string{".ai-team"}, cfg.Retrieval.Exclude...)
+	entries, err := tools.ListDirWithOptions(".", tools.ListDirOptions{Recursive: true, Include: cfg.Retrieval.Paths, Exclude: exclude})
+	if err != nil {
+		return nil, err
+	}
+	ignore := tools.NewIgnoreFilter(".", cfg.Ignore)
+	var paths []string
+	for _, e := range entries {
+		if e.Type == "file" && !ignore.Ignored(filepath.FromSlash(e.Path)) {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths, nil
+}
+
+// retrieve brings the index up to date with the working directory and
+// returns the chunks closest to query, formatted for a prompt. An empty
+// query retrieves nothing.
+func retrieve(cfg *config.Config, step types.RetrieveStep, query string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", nil
+	}
+	x, err := retrievalIndexFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	paths, err := retrievalFiles(cfg)
+	if err != nil {
+		return "", err
+	}
+	embedder := NewRetrievalEmbedderFunc(cfg)
+	stats, err := x.Sync(".", paths, embedder, retrieval.Options{ChunkLines: cfg.Retrieval.ChunkLines, MaxFileSize: cfg.Retrieval.MaxFileSize})
+	if err != nil {
+		return "", err
+	}
+	if stats != (retrieval.Stats{}) {
+		logger.DebugPrintf("Retrieval index: %d files embedded, %d removed", stats.Embedded, stats.Removed)
+	}
+	vec, err := embedder.Embed(query)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeAPI, "failed to embed the retrieval query", err)
+	}
+	topK := step.TopK
+	if topK == 0 {
+		topK = cfg.Retrieval.TopK
+	}
+	return retrieval.Format(x.Search(vec, topK)), nil
+}
+
+// retrievalQuery returns the query of a step with retrieve: its query
+// template rendered against the chain context, or else the values of the
+// step's input in key order.
+func retrievalQuery(chainRole types.ChainRole, context, roleInput map[string]interface{}) (string, error) {
+	if chainRole.Retrieve.Query != "" {
+		tmpl, err := template.New("retrieve").Parse(chainRole.Retrieve.Query)
+		if err != nil {
+			return "", errors.New(errors.ErrCodeRole, "failed to parse retrieve query template", err)
+		}
+		var query bytes.Buffer
+		if err := tmpl.Execute(&query, context); err != nil {
+			return "", errors.New(errors.ErrCodeRole, "failed to execute retrieve query template", err)
+		}
+		return query.String(), nil
+	}
+	keys := make([]string, 0, len(chainRole.Input))
+	for k := range chainRole.Input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := roleInput[k]; ok && v != nil {
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// withRetrieved appends retrieved chunks to a prompt that does not use the
+// retrieved input.
+func withRetrieved(role types.Role, prompt string, input map[string]interface{}) string {
+	retrieved, _ := input[RetrievedInput].(template.HTML)
+	if retrieved == "" || strings.Contains(role.Prompt, "."+RetrievedInput) {
+		return prompt
+	}
+	return prompt + retrievedHeading + string(retrieved)
+}
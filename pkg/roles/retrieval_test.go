@@ -0,0 +1,81 @@
+package roles
+
+import (
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/cache"
+	"ai-team/pkg/types"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// topicEmbedder embeds texts by whether they mention storage or parsing.
+type topicEmbedder struct{}
+
+func (topicEmbedder) Embed(text string) ([]float32, error) {
+	return []float32{0.01 + float32(strings.Count(text, "storage")), 0.01 + float32(strings.Count(text, "parse"))}, nil
+}
+
+func TestExecuteChain_Retrieve(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	origEmbedder := NewRetrievalEmbedderFunc
+	defer func() {
+		ai.CallGeminiFunc = origCallGemini
+		NewRetrievalEmbedderFunc = origEmbedder
+	}()
+	NewRetrievalEmbedderFunc = func(*config.Config) cache.Embedder { return topicEmbedder{} }
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	var prompts []string
+	ai.CallGeminiFunc = func(_ *http.Client, p, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, p)
+		return "done", nil
+	}
+
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	os.WriteFile("store.go", []byte("// storage of records\n"), 0644)
+	os.WriteFile("parser.go", []byte("// parse the input\n"), 0644)
+	os.WriteFile("secret.txt", []byte("storage password\n"), 0644)
+	os.WriteFile(".ai-teamignore", []byte("secret.txt\n"), 0644)
+	cfg.Retrieval.Index = filepath.Join(dir, ".ai-team", "index.json")
+	cfg.Retrieval.TopK = 1
+
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "coder", Input: map[string]interface{}{"task": "{{.task}}"}, Retrieve: &types.RetrieveStep{}},
+		{Role: "coder", Retrieve: &types.RetrieveStep{Query: "how do we parse"}},
+	}}
+	if _, err := ExecuteChain(chain, map[string]interface{}{"task": "fix the storage"}, cfg, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(prompts))
+	}
+	if want := "code" + retrievedHeading + "--- store.go:1-1\n// storage of records\n"; prompts[0] != want {
+		t.Errorf("expected the storage file appended, got %q", prompts[0])
+	}
+	if !strings.Contains(prompts[1], "--- parser.go:1-1") {
+		t.Errorf("expected the query to retrieve the parser, got %q", prompts[1])
+	}
+	if _, err := os.Stat(cfg.Retrieval.Index); err != nil {
+		t.Errorf("expected the index to be saved: %v", err)
+	}
+
+	role := cfg.Roles["coder"]
+	role.Prompt = "code\n{{.retrieved}}"
+	cfg.Roles["coder"] = role
+	prompts = nil
+	if _, err := ExecuteChain(types.RoleChain{Steps: chain.Steps[1:]}, nil, cfg, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prompts[0] != "code\n--- parser.go:1-1\n// parse the input\n" {
+		t.Errorf("expected the chunks in place of the retrieved input only, got %q", prompts[0])
+	}
+}
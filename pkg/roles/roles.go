@@ -3,111 +3,145 @@ package roles
 import (
 	"ai-team/config"
 	ai "ai-team/pkg/ai"
+	"ai-team/pkg/cleanup"
 	"ai-team/pkg/errors"
+	"ai-team/pkg/memory"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/script"
 	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
+	"ai-team/pkg/usage"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"ai-team/pkg/logger"
+
+	"github.com/sirupsen/logrus"
 )
 
-// ExecuteRole executes a single AI role.
+// ExecuteRole executes a single AI role. It returns the JSON of the tool call
+// found in the response or, failing that, the response's outermost JSON object.
 func ExecuteRole(
 	role types.Role,
 	input map[string]interface{},
 	cfg *config.Config,
 	logFilePath string, // Add logFilePath parameter
 ) (string, error) {
-	// Render the prompt with the provided input
-	tmpl, err := template.New("prompt").Parse(role.Prompt)
-	if err != nil {
-		return "", errors.New(errors.ErrCodeRole, "failed to parse role prompt template", err)
+	return ExecuteRoleContext(context.Background(), role, input, cfg, logFilePath)
+}
+
+// ExecuteRoleContext is ExecuteRole with the provider call cancelled when ctx
+// is done.
+func ExecuteRoleContext(
+	ctx context.Context,
+	role types.Role,
+	input map[string]interface{},
+	cfg *config.Config,
+	logFilePath string,
+) (string, error) {
+	result, err := RunRoleContext(ctx, role, input, cfg, logFilePath)
+	if result.Raw == "" && err != nil {
+		return "", err
 	}
+	response := result.Raw
 
-	var processedPrompt bytes.Buffer
-	if err := tmpl.Execute(&processedPrompt, input); err != nil {
-		return "", errors.New(errors.ErrCodeRole, "failed to execute role prompt template", err)
+	// Use ToolCallExtractor for robust extraction with schema validation
+	extractor, extractorErr := toolCallExtractor(cfg, role, toolRegistryFor(cfg))
+	if extractorErr != nil {
+		return "", extractorErr
+	}
+	tc, _, extractErr := extractor.ExtractToolCall(response)
+	if extractErr == nil && tc != nil {
+		// If a tool-call is found, return its JSON
+		b, _ := json.Marshal(tc)
+		return string(b), err
+	}
+	// Fallback: extract first JSON object (legacy)
+	cleanResponse := response
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = response[start : end+1]
+	}
+	return cleanResponse, err
+}
+
+// RoleResult is the outcome of a role call.
+type RoleResult struct {
+	RunID    string          `json:"run_id"`    // Run the call belongs to
+	Text     string          `json:"text"`      // Generated text (the raw response when its format is unknown)
+	ToolCall *types.ToolCall `json:"tool_call"` // Tool call found in the text, if any
+	Raw      string          `json:"raw"`       // Provider response body
+}
+
+// RunRole executes a single AI role and returns the generated text, the tool
+// call it contains, if any, and the raw provider response.
+func RunRole(
+	role types.Role,
+	input map[string]interface{},
+	cfg *config.Config,
+	logFilePath string,
+) (RoleResult, error) {
+	return RunRoleContext(context.Background(), role, input, cfg, logFilePath)
+}
+
+// RunRoleContext is RunRole with the provider call cancelled when ctx is done.
+func RunRoleContext(
+	ctx context.Context,
+	role types.Role,
+	input map[string]interface{},
+	cfg *config.Config,
+	logFilePath string,
+) (RoleResult, error) {
+	// A role called on its own is a run of its own.
+	if currentRunID() == "" {
+		defer beginRun(runs.NewID())()
 	}
+	logFilePath = runs.ExpandRunID(logFilePath, currentRunID())
 
-	// Call the AI model based on the role's model
-	// Currently only Gemini is supported for roles
-	// (Future: Add cases for OpenAI, Ollama, etc.)
-	client := &http.Client{}
+	// Render the prompt with the provided input
+	prompt, err := RenderPrompt(role, withToolsPrompt(role, input, registryOf(ctx, cfg)))
+	if err != nil {
+		return RoleResult{}, err
+	}
 
-	// Determine provider and model config
+	scope := role.Provider + "/" + role.Model
+	conv := ai.ConversationFrom(ctx)
+	// Answers depend on the history, so only first turns use the cache.
+	firstTurn := len(conv.History()) == 0
 	var response string
+	var cacheHit bool
+	var embedding []float32
+	if firstTurn {
+		response, cacheHit, embedding = lookupSemanticCache(cfg, scope, prompt)
+	}
 	var roleErr error
-
-	switch role.Provider {
-	case "gemini":
-		if modelCfg, ok := cfg.Gemini.Models[role.Model]; ok {
-			apiKey := modelCfg.Apikey
-			if apiKey == "" {
-				apiKey = cfg.Gemini.Apikey
-			}
-			apiURL := modelCfg.Apiurl
-			if apiURL == "" {
-				apiURL = cfg.Gemini.Apiurl
-			}
-			response, roleErr = ai.CallGeminiFunc(
-				client,
-				processedPrompt.String(),
-				modelCfg.Model,
-				apiURL,
-				apiKey,
-				cfg.Tools,
-			)
-		} else {
-			return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("Gemini model '%s' not found in config", role.Model), nil)
-		}
-	case "openai":
-		logger.DebugPrintf("Looking for OpenAI model %q in map with keys: %q", role.Model, keys(cfg.OpenAI.Models))
-		if modelCfg, ok := cfg.OpenAI.Models[role.Model]; ok {
-			logger.DebugPrintf("OpenAI model '%s' found: %t", role.Model, ok)
-			apiKey := modelCfg.Apikey
-			if apiKey == "" {
-				apiKey = cfg.OpenAI.Apikey
-			}
-			apiURL := modelCfg.Apiurl
-			if apiURL == "" {
-				apiURL = cfg.OpenAI.DefaultApiurl
-			}
-			response, roleErr = ai.CallOpenAIFunc(
-				client,
-				processedPrompt.String(),
-				apiURL,
-				apiKey,
-			)
-		} else {
-			return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("OpenAI model '%s' not found in config", role.Model), nil)
-		}
-	case "ollama":
-		if modelCfg, ok := cfg.Ollama.Models[role.Model]; ok {
-			apiURL := modelCfg.Apiurl
-			if apiURL == "" {
-				apiURL = cfg.Ollama.Apiurl
-			}
-			response, roleErr = ai.CallOllama(
-				client,
-				processedPrompt.String(),
-				apiURL,
-				modelCfg.Model,
-				cfg.Tools,
-			)
+	if !cacheHit {
+		response, roleErr = callProvider(ctx, role, prompt, cfg)
+		if roleErr == nil && firstTurn {
+			storeSemanticCache(cfg, scope, prompt, response, embedding)
+		}
+	} else if onText := ai.StreamFrom(ctx); onText != nil {
+		if text, _, ok := ai.ResponseText(role.Provider, response); ok {
+			onText(text)
 		} else {
-			return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("Ollama model '%s' not found in config", role.Model), nil)
+			onText(response)
 		}
-	default:
-		return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("unsupported or undefined provider '%s' for model '%s'", role.Provider, role.Model), nil)
 	}
 
 	// Log the role call
 	logEntry := types.RoleCallLogEntry{
+		RunID:    currentRunID(),
 		RoleName: role.Model, // Use model name as identifier
 		Input:    input,
 		Output:   response,
@@ -121,24 +155,409 @@ func ExecuteRole(
 		}
 	}
 
-	// Use ToolCallExtractor for robust extraction with schema validation
-	toolRegistry := tools.NewToolRegistry()
-	tools.RegisterDefaultTools(toolRegistry)
-	extractor := ai.NewDefaultToolCallExtractor(toolRegistry)
-	tc, _, err := extractor.ExtractToolCall(response)
-	if err == nil && tc != nil {
-		// If a tool-call is found, return its JSON
-		b, _ := json.Marshal(tc)
-		return string(b), roleErr
+	result := RoleResult{RunID: currentRunID(), Text: response, Raw: response}
+	if text, _, ok := ai.ResponseText(role.Provider, response); ok {
+		result.Text = text
 	}
-	// Fallback: extract first JSON object (legacy)
-	cleanResponse := response
-	start := strings.Index(response, "{")
-	end := strings.LastIndex(response, "}")
-	if start != -1 && end != -1 && end > start {
-		cleanResponse = response[start : end+1]
+	if extractor, extractorErr := toolCallExtractor(cfg, role, registryOf(ctx, cfg)); extractorErr == nil {
+		if tc, _, extractErr := extractor.ExtractResponse(result.Raw, result.Text); extractErr == nil && tc != nil {
+			result.ToolCall = tc
+		}
+	}
+	if conv != nil && roleErr == nil {
+		conv.Add(types.MessageUser, prompt)
+		conv.Add(types.MessageAssistant, assistantMessage(role.Provider, result))
+	}
+	return result, roleErr
+}
+
+// toolCallExtractor returns the extractor of role's responses: its
+// extraction strategies, else those configured for its provider, else the
+// built-in order.
+func toolCallExtractor(cfg *config.Config, role types.Role, reg *tools.ToolRegistry) (*ai.ToolCallExtractor, error) {
+	strategies := role.Extraction
+	if len(strategies) == 0 && cfg != nil {
+		strategies = cfg.Extraction.For(role.Provider)
+	}
+	return ai.NewToolCallExtractor(reg, role.Provider, strategies)
+}
+
+// extractedToolCall returns the tool call of an ExecuteRoleContext output
+// that is one, encoded as a bare types.ToolCall, or nil.
+func extractedToolCall(output string) *types.ToolCall {
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.DisallowUnknownFields()
+	var tc types.ToolCall
+	if err := dec.Decode(&tc); err != nil || tc.Name == "" || dec.More() {
+		return nil
+	}
+	return &tc
+}
+
+// assistantMessage returns the answer of a role call as it is kept in the
+// conversation: its text or, for a native tool call without text, the call
+// in the tool-call format of the prompt.
+func assistantMessage(provider string, result RoleResult) string {
+	if strings.TrimSpace(result.Text) != "" {
+		return result.Text
+	}
+	if tc, _ := ai.NativeToolCall(provider, result.Raw); tc != nil {
+		b, _ := json.Marshal(map[string]interface{}{"tool_call": tc})
+		return string(b)
+	}
+	return result.Text
+}
+
+// RenderPrompt renders the role's prompt template with the provided input.
+func RenderPrompt(role types.Role, input map[string]interface{}) (string, error) {
+	return renderPrompt(role, input, false)
+}
+
+// ToolsPromptInput is the prompt variable holding the generated description of
+// the available tools and the tool-call format.
+const ToolsPromptInput = "tools_prompt"
+
+var defaultTools struct {
+	once     sync.Once
+	registry *tools.ToolRegistry
+}
+
+// defaultToolRegistry returns a shared registry of the default tools.
+func defaultToolRegistry() *tools.ToolRegistry {
+	defaultTools.once.Do(func() {
+		defaultTools.registry = tools.NewToolRegistry()
+		tools.RegisterDefaultTools(defaultTools.registry)
+	})
+	return defaultTools.registry
+}
+
+// toolRegistryFor returns a registry of the default tools and the config
+// tools of cfg, or the shared default registry when cfg has none.
+func toolRegistryFor(cfg *config.Config) *tools.ToolRegistry {
+	if cfg == nil || len(cfg.Tools) == 0 {
+		return defaultToolRegistry()
+	}
+	registry := tools.NewToolRegistry()
+	tools.RegisterDefaultTools(registry)
+	if err := tools.RegisterConfigTools(registry, cfg.Tools); err != nil {
+		logrus.Warn(err)
+	}
+	return registry
+}
+
+// registryOf returns the tool registry of ctx, set by chains, or the
+// registry of cfg's tools for a role called on its own.
+func registryOf(ctx context.Context, cfg *config.Config) *tools.ToolRegistry {
+	if registry := ai.ToolsFrom(ctx); registry != nil {
+		return registry
 	}
-	return cleanResponse, roleErr
+	return toolRegistryFor(cfg)
+}
+
+// withToolsPrompt sets ToolsPromptInput from registry when the role's prompt
+// uses it and the input does not already provide it. The section is marked as
+// safe HTML so the prompt template does not escape its JSON example.
+func withToolsPrompt(role types.Role, input map[string]interface{}, registry *tools.ToolRegistry) map[string]interface{} {
+	if _, ok := input[ToolsPromptInput]; ok || !strings.Contains(role.Prompt, ToolsPromptInput) {
+		return input
+	}
+	out := make(map[string]interface{}, len(input)+1)
+	for k, v := range input {
+		out[k] = v
+	}
+	section, _ := registry.PromptSection()
+	out[ToolsPromptInput] = template.HTML(section)
+	return out
+}
+
+// withRefsHint adds the argument reference hint to the tools section of a
+// chain step's input, as only chains resolve references.
+func withRefsHint(input map[string]interface{}) map[string]interface{} {
+	section, ok := input[ToolsPromptInput].(template.HTML)
+	if !ok || strings.HasSuffix(string(section), tools.RefsHint) {
+		return input
+	}
+	out := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		out[k] = v
+	}
+	out[ToolsPromptInput] = section + template.HTML(tools.RefsHint)
+	return out
+}
+
+// renderPrompt renders the role's prompt template, failing on references to
+// missing input keys when strict is set. Retrieved chunks the template does
+// not use are appended.
+func renderPrompt(role types.Role, input map[string]interface{}, strict bool) (string, error) {
+	input = withInputDefaults(role, input)
+	input = withToolsPrompt(role, input, defaultToolRegistry())
+	tmpl, err := template.New("prompt").Parse(role.Prompt)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeRole, "failed to parse role prompt template", err)
+	}
+	if strict {
+		tmpl.Option("missingkey=error")
+	}
+
+	var processedPrompt bytes.Buffer
+	if err := tmpl.Execute(&processedPrompt, input); err != nil {
+		return "", errors.New(errors.ErrCodeRole, "failed to execute role prompt template", err)
+	}
+	return withRetrieved(role, processedPrompt.String(), input), nil
+}
+
+var missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// missingKeyError explains a strict-mode template failure caused by a missing
+// key, naming the step and the key. It returns nil for other errors.
+func missingKeyError(stepIndex int, step, what string, err error) error {
+	m := missingKeyPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	return errors.New(errors.ErrCodeRole, fmt.Sprintf(
+		"step %d (%s): %s references missing key %q; provide it via the step input, chain vars or --input, or set strict: false on the chain",
+		stepIndex+1, step, what, m[1]), nil)
+}
+
+// callProvider sends the rendered prompt to the provider configured for role
+// and returns the raw response body. Responses cut off at the output token
+// limit are continued with follow-up requests and returned as one response.
+// Requests are cancelled when ctx is done.
+func callProvider(ctx context.Context, role types.Role, prompt string, cfg *config.Config) (string, error) {
+	response, err := callProviderOnce(ctx, role, prompt, cfg)
+	limit := role.MaxContinuations
+	if limit == 0 {
+		limit = types.DefaultMaxContinuations
+	}
+	if err != nil || limit < 0 {
+		return response, err
+	}
+	text, _, _ := ai.ResponseText(role.Provider, response)
+	for n := 1; n <= limit && ai.Truncated(role.Provider, response); n++ {
+		logger.From(ctx).Infof("Response from %s/%s was truncated; requesting continuation %d/%d", role.Provider, role.Model, n, limit)
+		next, nextErr := callProviderOnce(ctx, role, ai.ContinuationPrompt(prompt, text), cfg)
+		if nextErr != nil {
+			logger.From(ctx).Warnf("Continuation request failed, keeping truncated response: %v", nextErr)
+			break
+		}
+		nextText, _, _ := ai.ResponseText(role.Provider, next)
+		text = ai.JoinContinuation(text, nextText)
+		response = ai.WithText(role.Provider, next, text)
+	}
+	return response, nil
+}
+
+// callProviderOnce sends a single request to the provider configured for role.
+func callProviderOnce(ctx context.Context, role types.Role, prompt string, cfg *config.Config) (string, error) {
+	reqOpts := cfg.RequestOptions(role.Provider, role.Model)
+	reqOpts.RunID = currentRunID()
+	staticPrompt, cacheKey, cacheable := cacheablePrefix(role, prompt, registryOf(ctx, cfg))
+	if cacheable {
+		logger.DebugPrintf("Prompt cache key %s covers ~%d static tokens of %s/%s", cacheKey, ai.EstimateTokens(staticPrompt), role.Provider, role.Model)
+		if role.Provider == "openai" {
+			reqOpts.PromptCacheKey = cacheKey
+		}
+	}
+	client := ai.WithContext(ai.WithRequestOptions(&http.Client{}, reqOpts), ctx)
+	adapter, ok := ai.Provider(role.Provider)
+	if !ok {
+		return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("unsupported or undefined provider '%s' for model '%s'", role.Provider, role.Model), nil)
+	}
+	req, ok := cfg.ProviderRequest(role.Provider, role.Model)
+	if !ok {
+		return "", errors.New(errors.ErrCodeRole, fmt.Sprintf("%s model '%s' not found in config", role.Provider, role.Model), nil)
+	}
+	history := ai.ConversationFrom(ctx).History()
+	req.Prompt, req.History = prompt, history
+	req.Tools = cfg.Tools
+	req.StaticPrompt, req.CacheKey = staticPrompt, cacheKey
+	if registry := ai.ToolsFrom(ctx); req.NativeTools && registry != nil {
+		req.Schemas = registry.ListTools()
+	}
+	response, roleErr := adapter.BuildRequest(req)(client, ai.StreamFrom(ctx))
+
+	if roleErr == nil {
+		recordTokens(ctx, reqOpts.UsageLabel, role, ai.HistoryPrompt(history, prompt), response, cfg)
+	}
+	return response, roleErr
+}
+
+// recordTokens adds the token counts of a call to the usage tracker, priced
+// from the config. Counts are estimated from the text when the provider
+// reports none.
+func recordTokens(ctx context.Context, label string, role types.Role, prompt, response string, cfg *config.Config) {
+	input, output, _ := usage.Parse(role.Provider, prompt, response)
+	var price *types.ModelPrice
+	if p, found := cfg.Price(role.Provider, role.Model); found {
+		price = &p
+	}
+	usage.Default.RecordTokens(ctx, label, input, output, price)
+}
+
+var workspaceLocks struct {
+	sync.Mutex
+	byPath map[string]*tools.WorkspaceLock
+}
+
+// workspaceLockFor returns the process-wide workspace lock configured in cfg,
+// or nil when locking is disabled. Sharing one lock per path serializes the
+// writers of this process without polling the lock file.
+func workspaceLockFor(cfg *config.Config) *tools.WorkspaceLock {
+	if cfg == nil || !cfg.WorkspaceLock.Enabled {
+		return nil
+	}
+	workspaceLocks.Lock()
+	defer workspaceLocks.Unlock()
+	path := cfg.WorkspaceLock.Path
+	if path == "" {
+		path = tools.DefaultLockPath
+	}
+	if workspaceLocks.byPath == nil {
+		workspaceLocks.byPath = map[string]*tools.WorkspaceLock{}
+	}
+	lock, ok := workspaceLocks.byPath[path]
+	if !ok {
+		lock = tools.NewWorkspaceLock(path, cfg.WorkspaceLock.Wait)
+		workspaceLocks.byPath[path] = lock
+	}
+	return lock
+}
+
+// ignoreFilterFor returns the filter keeping tools away from paths excluded
+// by .ai-teamignore files and cfg's ignore patterns.
+func ignoreFilterFor(cfg *config.Config) *tools.IgnoreFilter {
+	var patterns []string
+	if cfg != nil {
+		patterns = cfg.Ignore
+	}
+	return tools.NewIgnoreFilter(".", patterns)
+}
+
+var journals struct {
+	sync.Mutex
+	byPath map[string]*tools.Journal
+}
+
+// journalFor returns the process-wide write journal configured in cfg, or nil
+// when journaling is disabled. Entries left unfinished by a crash are kept for
+// `ai-team recover`.
+func journalFor(cfg *config.Config) *tools.Journal {
+	if cfg == nil || !cfg.Journal.Enabled {
+		return nil
+	}
+	journals.Lock()
+	defer journals.Unlock()
+	path := cfg.Journal.Path
+	if path == "" {
+		path = tools.DefaultJournalPath
+	}
+	if journals.byPath == nil {
+		journals.byPath = map[string]*tools.Journal{}
+	}
+	journal, ok := journals.byPath[path]
+	if !ok {
+		journal = tools.NewJournal(path)
+		journals.byPath[path] = journal
+		// Drop the finished entries of earlier runs, under the workspace lock so
+		// no other run appends meanwhile.
+		if lock := workspaceLockFor(cfg); lock != nil {
+			if release, err := lock.Acquire("compact journal"); err == nil {
+				if err := journal.Compact(); err != nil {
+					logger.DebugPrintf("Failed to compact journal: %v", err)
+				}
+				release()
+			}
+		}
+	}
+	return journal
+}
+
+// toolEnv returns the environment of commands run by tools, from the env of
+// cfg merged with the chain's, or nil when commands simply inherit the process
+// environment.
+func toolEnv(cfg *config.Config, chain types.EnvConfig) *tools.Env {
+	var global types.EnvConfig
+	if cfg != nil {
+		global = cfg.Env
+	}
+	merged := global.Merge(chain)
+	inherit := merged.Inherit == nil || *merged.Inherit
+	if inherit && len(merged.Vars) == 0 {
+		return nil
+	}
+	return &tools.Env{Inherit: inherit, Vars: merged.Vars}
+}
+
+// postProcessorsFor returns the result post-processors of the built-in tools
+// and config tools of cfg, or nil when there are none.
+func postProcessorsFor(cfg *config.Config) *tools.PostProcessors {
+	if cfg == nil {
+		return nil
+	}
+	configs := types.PostProcessors{}
+	for name, p := range cfg.PostProcess {
+		configs[name] = p
+	}
+	for _, tool := range cfg.Tools {
+		if tool.PostProcess != nil {
+			configs[tool.Name] = *tool.PostProcess
+		}
+	}
+	p, err := tools.NewPostProcessors(configs)
+	if err != nil {
+		logrus.Warnf("Tool results are not post-processed: %v", err)
+		return nil
+	}
+	return p
+}
+
+// ChainOptions controls optional behavior of ExecuteChainWithOptions.
+type ChainOptions struct {
+	LogFilePath string
+	// Run receives a StepRecord for every role invocation (a fresh record is used
+	// when nil). If Store is set the record is persisted after each step and when
+	// the chain ends.
+	Run   *runs.Record
+	Store *runs.Store
+	// Confirm asks the user a yes/no question, e.g. when a tool quota is
+	// exceeded. When nil such questions are answered "no".
+	Confirm func(prompt string) (bool, error)
+	// Policy, when set, decides which tool calls may run; calls it marks
+	// "confirm" are put to Confirm.
+	Policy *tools.Policy
+	// DumpContext, when set, receives the pretty-printed (redacted) context
+	// after every step iteration.
+	DumpContext io.Writer
+	// MetricsHook, when set, receives the tool executor's events, a
+	// step_iteration event before each iteration of a step and the
+	// step_heartbeat and step_stalled events of long-running steps, with the
+	// run ID and labels. Heartbeat events are sent from another goroutine.
+	MetricsHook func(event string, fields map[string]interface{})
+	// Since, when set, is an earlier run of the chain. Steps whose model, prompt
+	// and input are unchanged since then reuse its output instead of calling
+	// the model; their tool calls still run.
+	Since *runs.Record
+	// Checkpoint, when set, is the state file written after each completed
+	// step, so a run that dies midway can be resumed. No checkpoint is written
+	// after a step whose model call failed or that timed out, or after any
+	// later step; the file is removed when every step completed.
+	Checkpoint string
+	// Resume, when set, continues an earlier run from its checkpoint: the
+	// completed steps are skipped and the context is restored. A named
+	// checkpoint starts a branch of the earlier run.
+	Resume *runs.Checkpoint
+	// CheckpointSteps names steps after which a named checkpoint, called
+	// like the step, is saved in Store, besides the steps with checkpoint
+	// set. Named checkpoints are kept once the run ends.
+	CheckpointSteps []string
+	// Resources tracks the temporary files and child processes of the run.
+	// When nil the chain tracks its own and cleans them up when it ends.
+	Resources *cleanup.Tracker
+	// Interactive, when set, is consulted before each step, on the input
+	// and tool calls of each step iteration and after it, so a person can
+	// follow and steer the run. An error it returns stops the chain.
+	Interactive ChainInteraction
 }
 
 // ExecuteChain executes a chain of AI roles.
@@ -148,33 +567,282 @@ func ExecuteChain(
 	cfg *config.Config,
 	logFilePath string, // Add logFilePath parameter
 ) (map[string]interface{}, error) {
+	return ExecuteChainWithOptions(chain, initialInput, cfg, ChainOptions{LogFilePath: logFilePath})
+}
+
+// ExecuteChainWithOptions executes a chain of AI roles with the given options.
+func ExecuteChainWithOptions(
+	chain types.RoleChain,
+	initialInput map[string]interface{},
+	cfg *config.Config,
+	opts ChainOptions,
+) (result map[string]interface{}, err error) {
+	if opts.Run == nil {
+		opts.Run = runs.NewRecord("", initialInput)
+	}
+	if opts.Resume != nil {
+		if resumeErr := checkResume(chain, opts.Resume); resumeErr != nil {
+			return nil, resumeErr
+		}
+		if opts.Resume.Name != "" {
+			opts.Run.BranchedFrom = opts.Resume.RunID + "@" + opts.Resume.Name
+		} else {
+			opts.Run.ResumedFrom = opts.Resume.RunID
+		}
+	}
+	logFilePath := runs.ExpandRunID(opts.LogFilePath, opts.Run.ID)
+	endRun := beginRun(opts.Run.ID)
+	defer func() {
+		endRun()
+		opts.Run.Finish(err)
+		summarizeChanges(context.Background(), cfg, opts.Run)
+		saveRun(opts)
+	}()
 	roles := cfg.Roles
 	logger.DebugPrintf("Executing chain (steps): %+v", chain.Steps)
 	logger.DebugPrintf("Roles: %v", roles)
 	// Initialize ToolRegistry and ToolExecutor for the chain
 	toolRegistry := tools.NewToolRegistry()
 	tools.RegisterDefaultTools(toolRegistry)
-	// toolExecutor removed (was unused)
+	if err := tools.RegisterConfigTools(toolRegistry, cfg.Tools); err != nil {
+		logrus.Warn(err)
+	}
+	if cfg.DocumentsDir != "" {
+		toolRegistry.Documents().Dir = cfg.DocumentsDir
+	}
+	if cfg.Memory.Path != "" {
+		toolRegistry.Memory().Path = cfg.Memory.Path
+	}
+	toolRegistry.Memory().MaxFacts = cfg.Memory.MaxFacts
+	toolRegistry.HTTP().Configure(cfg.HTTPRequest)
+	mcpClients := tools.ConnectMCPServers(context.Background(), cfg.MCPServers, toolRegistry)
+	defer mcpClients.Close()
+	opts.Run.ToolsHash = toolRegistry.Hash()
+	defer func() {
+		if aborted := toolRegistry.ChunkedFiles().Abort(); len(aborted) > 0 {
+			logrus.Warnf("Discarded unfinished chunked file(s): %s", strings.Join(aborted, ", "))
+		}
+	}()
+	toolExecutor := &tools.ToolExecutor{
+		Registry:   toolRegistry,
+		RetryCount: 1,
+		Quota:      tools.NewQuotaTracker(cfg.Quota.Merge(chain.Quota), opts.Confirm),
+	}
+	if cfg.Dedup.Enabled {
+		toolExecutor.Dedup = tools.NewDedupCache(cfg.Dedup.AllowRepeat)
+		toolExecutor.Dedup.Mutating = cfg.Dedup.Mutating
+		if cfg.Dedup.Similar.Enabled {
+			toolExecutor.Dedup.Similar = tools.NewSimilarCalls(NewEmbedderFunc(cfg), cfg.Dedup.Similar.Threshold, cfg.Dedup.Similar.Tools)
+		}
+	}
+	toolExecutor.Ignore = ignoreFilterFor(cfg)
+	toolExecutor.Lock = workspaceLockFor(cfg)
+	toolExecutor.Journal = journalFor(cfg)
+	toolExecutor.PostProcess = postProcessorsFor(cfg)
+	guard := newGuardrail(cfg, opts.Run.ID)
+	if guard != nil {
+		toolExecutor.Review = guard.review
+	}
+	spans := &timeline{run: opts.Run}
+	toolExecutor.MetricsHook = spans.metricsHook
+	if opts.MetricsHook != nil {
+		toolExecutor.MetricsHook = func(event string, fields map[string]interface{}) {
+			spans.metricsHook(event, fields)
+			fields["run_id"] = opts.Run.ID
+			if len(opts.Run.Labels) > 0 {
+				fields["labels"] = opts.Run.Labels
+			}
+			opts.MetricsHook(event, fields)
+		}
+	}
+	beat := startHeartbeat(cfg.Heartbeat, opts.Run, opts.MetricsHook)
+	defer beat.close()
+	if opts.Policy != nil {
+		policy := *opts.Policy
+		if policy.Confirm == nil {
+			policy.Confirm = opts.Confirm
+		}
+		toolExecutor.Policy = &policy
+	}
+	if cfg.Simulation.DryRun {
+		simulator, simErr := tools.NewDryRunSimulator(cfg.Simulation)
+		if simErr != nil {
+			return nil, simErr
+		}
+		toolExecutor.Simulator = simulator
+		opts.Run.DryRun = true
+		logrus.Warnf("Dry run: tools are not executed and return simulated results")
+	} else if cfg.Simulation.Enabled {
+		simulator, simErr := tools.NewSimulator(cfg.Simulation.Tools)
+		if simErr != nil {
+			return nil, simErr
+		}
+		toolExecutor.Simulator = simulator
+		logrus.Warnf("Simulation mode: %d tool(s) return scripted results", len(cfg.Simulation.Tools))
+	}
+	sandbox, sandboxErr := tools.NewSandbox(cfg.Sandbox)
+	if sandboxErr != nil {
+		return nil, sandboxErr
+	}
+	toolExecutor.Sandbox = sandbox
+	scripts, scriptErr := config.CompileScripts(opts.Run.Chain, chain)
+	if scriptErr != nil {
+		return nil, scriptErr
+	}
+
+	if opts.Resources == nil {
+		opts.Resources = cleanup.New(false)
+		defer opts.Resources.Cleanup()
+	}
+	chainCtx, cancelChain := withTimeout(ai.WithTools(cleanup.With(context.Background(), opts.Resources), toolRegistry), chain.Timeout)
+	defer cancelChain()
+	runLog := logger.NewRunLogger(opts.Run.ID, cfg.Logging)
+	var openStepLog *logger.StepLogger // Closed on early returns
+	defer func() {
+		if openStepLog != nil {
+			openStepLog.Close()
+		}
+	}()
+	if env := toolEnv(cfg, chain.Env); env != nil {
+		toolExecutor.Env = env
+		chainCtx = tools.WithEnv(chainCtx, env)
+	}
 
-	context := make(map[string]interface{})
+	context := make(map[string]interface{}, len(chain.Vars)+len(initialInput))
+	for k, v := range chain.Vars {
+		context[k] = v
+	}
 	for k, v := range initialInput {
 		context[k] = v
 	}
-
+	if _, set := context["memory"]; !set && cfg.Memory.Load > 0 {
+		if facts, memErr := toolRegistry.Memory().Recent(cfg.Memory.Load, cfg.Memory.Tags); memErr != nil {
+			logrus.Warnf("Memory not loaded: %v", memErr)
+		} else {
+			context["memory"] = memory.Format(facts)
+		}
+	}
 	var lastToolResponse interface{} = nil
-	for _, chainRole := range chain.Steps {
+	if opts.Resume != nil {
+		context = make(map[string]interface{}, len(opts.Resume.Context))
+		for k, v := range opts.Resume.Context {
+			context[k] = v
+		}
+		lastToolResponse = opts.Resume.LastToolResponse
+	}
+	checkpointing := opts.Checkpoint != ""
+	if opts.Resume != nil && checkpointing {
+		// The resumed run can be resumed again from where it started.
+		saveCheckpoint(opts, chain, opts.Resume.NextStep, context, lastToolResponse)
+	}
+
+	var stepCache *runs.StepCache
+	if opts.Store != nil {
+		stepCache = runs.NewStepCache(opts.Store)
+	}
+	sinceCache := runs.NewRunStepCache(opts.Since)
+	if opts.Since != nil {
+		opts.Run.Since = opts.Since.ID
+	}
+
+	strict := chain.StrictTemplates()
+	jumps := 0
+	// jump returns the index before the step named target, for the step loop
+	// to go on there. Jumps end checkpointing, as a resumed run could not
+	// retrace them.
+	jump := func(from int, target string) (int, error) {
+		next, ok := chain.StepIndex(target)
+		if !ok {
+			return 0, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): no step '%s' to continue with", from+1, stepKey(chain.Steps[from], chain.Steps[from].Role), target), nil)
+		}
+		if jumps++; jumps > maxJumps {
+			return 0, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): more than %d jumps between steps", from+1, stepKey(chain.Steps[from], chain.Steps[from].Role), maxJumps), nil)
+		}
+		checkpointing = false
+		logrus.Infof("Step %s: continuing with %s", stepKey(chain.Steps[from], chain.Steps[from].Role), target)
+		return next - 1, nil
+	}
+	for stepIndex := 0; stepIndex < len(chain.Steps); stepIndex++ {
+		chainRole := chain.Steps[stepIndex]
+		if opts.Resume != nil && jumps == 0 && stepIndex < opts.Resume.NextStep {
+			logrus.Infof("Step %s completed in run %s, skipping", stepKey(chainRole, chainRole.Role), opts.Resume.RunID)
+			continue
+		}
+		if chainCtx.Err() != nil {
+			return nil, chainTimeoutError(chain, fmt.Sprintf("before step %d (%s)", stepIndex+1, stepKey(chainRole, chainRole.Role)))
+		}
+		if run, whenErr := stepWhen(stepIndex, chainRole, context); whenErr != nil {
+			return nil, whenErr
+		} else if !run {
+			logrus.Infof("Step %s skipped: its when condition does not hold", stepKey(chainRole, chainRole.Role))
+			continue
+		}
+		if opts.Interactive != nil {
+			skip, stepErr := opts.Interactive.BeforeStep(stepIndex, stepKey(chainRole, chainRole.Role), context)
+			if stepErr != nil {
+				return nil, stepErr
+			}
+			if skip {
+				logrus.Infof("Step %s skipped by the user", stepKey(chainRole, chainRole.Role))
+				checkpointing = false
+				continue
+			}
+		}
+		stepCtx, cancelTimeout := withTimeout(chainCtx, chainRole.Timeout)
+		stepLog := runLog.Step(stepIndex, stepKey(chainRole, chainRole.Role))
+		openStepLog = stepLog
+		stepCtx = logger.WithLogger(stepCtx, stepLog.Entry)
+		cancelStep := func() {
+			cancelTimeout()
+			stepLog.Close()
+		}
+		author := tools.Author{RunID: opts.Run.ID, Role: chainRole.Role}
+		if author.Role == "" {
+			author.Role = chainRole.Name
+		}
+		stepCtx = tools.WithAuthor(stepCtx, author)
+		stepCtx = usage.WithScope(stepCtx, usage.Scope{Role: author.Role, Chain: opts.Run.Chain})
+		beat.beginStep(stepKey(chainRole, chainRole.Role))
+		if toolExecutor.Dedup != nil && cfg.Dedup.Scope == "step" {
+			toolExecutor.Dedup.Reset()
+		}
 		loopCount := 1
 		maxLoop := 100 // Prevent infinite loops
 		if chainRole.Loop {
 			if chainRole.LoopCount > 0 {
 				loopCount = chainRole.LoopCount
-			} else if chainRole.LoopCondition != "" {
-				loopCount = maxLoop // Use maxLoop if only LoopCondition is set
+			} else if chainRole.LoopCondition != "" || chainRole.LoopScript != "" {
+				loopCount = maxLoop // Use maxLoop if only LoopCondition or LoopScript is set
 			} else {
 				loopCount = 1 // Default to 1 if not specified
 			}
 		}
-		for i := 0; i < loopCount; i++ {
+		if len(chainRole.Before) > 0 {
+			beat.setPhase(phaseHooks, "before")
+			outputs, hookErr := runStepHooks(stepCtx, "before", chainRole.Before, toolExecutor, scripts, context)
+			context["before_hooks"] = outputs
+			if skip, fatal := applyOnError(chainRole, "before", hookErr); fatal != nil {
+				return nil, fatal
+			} else if skip {
+				cancelStep()
+				if target := chainRole.ErrorTarget(); target != "" {
+					recordStepError(context, chainRole, hookErr)
+					next, jumpErr := jump(stepIndex, target)
+					if jumpErr != nil {
+						return nil, jumpErr
+					}
+					stepIndex = next
+				}
+				continue
+			}
+		}
+		continuation := ""
+		continuations := 0
+		toolFeedback := ""
+		conversation := &types.Conversation{} // Used by roles with conversation: true
+		stepOK := true                        // No model call of the step failed
+		var stepFailure error                 // The last failed model call, for on_error jumps
+		for i := 0; i < loopCount && stepCtx.Err() == nil; i++ {
 			// Look up the role by key from the map, prefer 'Role' field (YAML 'role')
 			roleKey := chainRole.Role
 			if roleKey == "" {
@@ -185,6 +853,14 @@ func ExecuteChain(
 				return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("role '%s' not found in config", roleKey), nil)
 			}
 			logger.DebugPrintf("Found role: %s with model: %s", roleKey, roleDef.Model)
+			if continuation != "" {
+				roleDef.Prompt += "\n\n" + continuation
+				continuation = ""
+			}
+			if toolFeedback != "" {
+				roleDef.Prompt += "\n\n" + toolFeedback
+				toolFeedback = ""
+			}
 
 			// Prepare input for the current role
 			roleInput := make(map[string]interface{})
@@ -195,8 +871,14 @@ func ExecuteChain(
 					if err != nil {
 						return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to parse input template for role %s in chain", roleKey), err)
 					}
+					if strict {
+						tmpl.Option("missingkey=error")
+					}
 					var resolvedInput bytes.Buffer
 					if err := tmpl.Execute(&resolvedInput, context); err != nil {
+						if keyErr := missingKeyError(stepIndex, stepKey(chainRole, roleKey), fmt.Sprintf("input %q", k), err); keyErr != nil {
+							return nil, keyErr
+						}
 						return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("failed to execute input template for role %s in chain", roleKey), err)
 					}
 					roleInput[k] = resolvedInput.String()
@@ -205,6 +887,14 @@ func ExecuteChain(
 				}
 			}
 
+			if len(chainRole.Documents) > 0 {
+				documents, docErr := loadDocuments(toolRegistry.Documents(), chainRole.Documents)
+				if docErr != nil {
+					return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): failed to load documents", stepIndex+1, stepKey(chainRole, roleKey)), docErr)
+				}
+				roleInput["documents"] = documents
+			}
+
 			logger.DebugPrintf("Preparing to execute role: %s (loop %d/%d) with input: %v", roleKey, i+1, loopCount, roleInput)
 			// Inject lastToolResponse just before role execution, after any tool execution from previous step
 			roleInput["lastToolResponse"] = lastToolResponse
@@ -218,9 +908,95 @@ func ExecuteChain(
 			} else {
 				roleInput["lastToolResponse_json"] = ""
 			}
+			if chainRole.InputScript != "" {
+				changed, scriptErr := runStepScript(stepCtx, scripts, chainRole.InputScript, stepIndex, stepKey(chainRole, roleKey), "input_script", context, map[string]interface{}{"input": roleInput})
+				if scriptErr != nil {
+					return nil, scriptErr
+				}
+				if input, ok := changed["input"]; ok {
+					m, isMap := input.(map[string]interface{})
+					if !isMap {
+						return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): input_script must leave input a dict", stepIndex+1, stepKey(chainRole, roleKey)), nil)
+					}
+					roleInput = m
+				}
+			}
+			if opts.Interactive != nil {
+				reviewed, inputErr := opts.Interactive.ReviewInput(stepKey(chainRole, roleKey), i, roleInput)
+				if inputErr != nil {
+					return nil, inputErr
+				}
+				roleInput = reviewed
+			}
+			if _, set := roleInput[RetrievedInput]; !set && chainRole.Retrieve != nil {
+				query, queryErr := retrievalQuery(chainRole, context, roleInput)
+				if queryErr != nil {
+					return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): invalid retrieve query", stepIndex+1, stepKey(chainRole, roleKey)), queryErr)
+				}
+				if retrieved, retrieveErr := retrieve(cfg, *chainRole.Retrieve, query); retrieveErr != nil {
+					logrus.Warnf("Step %s: nothing retrieved: %v", stepKey(chainRole, roleKey), retrieveErr)
+				} else {
+					roleInput[RetrievedInput] = template.HTML(retrieved)
+				}
+			}
 
 			logger.DebugPrintf("Executing role: %s (loop %d/%d) with input: %v", roleKey, i+1, loopCount, roleInput)
-			rawOutput, _ := ExecuteRole(roleDef, roleInput, cfg, logFilePath)
+			stepRecord := runs.StepRecord{
+				Index:     stepIndex,
+				Name:      chainRole.Name,
+				Role:      roleKey,
+				Iteration: i,
+				StartedAt: time.Now(),
+			}
+			spans.at(stepIndex, i)
+			if opts.MetricsHook != nil {
+				fields := map[string]interface{}{"run_id": opts.Run.ID, "chain": opts.Run.Chain, "step": stepKey(chainRole, roleKey), "index": stepIndex, "iteration": i}
+				if len(opts.Run.Labels) > 0 {
+					fields["labels"] = opts.Run.Labels
+				}
+				opts.MetricsHook("step_iteration", fields)
+			}
+			roleInput = withRefsHint(withToolsPrompt(roleDef, roleInput, toolRegistry))
+			prompt, renderErr := renderPrompt(roleDef, roleInput, strict)
+			if renderErr != nil && strict {
+				if keyErr := missingKeyError(stepIndex, stepKey(chainRole, roleKey), fmt.Sprintf("the prompt of role %s", roleKey), renderErr); keyErr != nil {
+					return nil, keyErr
+				}
+			}
+			stepRecord.Prompt = prompt
+			modelStart := time.Now()
+			usageLabel := roleDef.Provider + "/" + roleDef.Model
+			usageBefore := usage.Default.Model(usageLabel)
+			var rawOutput string
+			var roleErr error
+			stepRecord.CacheKey = runs.StepCacheKey(usageLabel, prompt, roleInput)
+			if chainRole.Cache {
+				rawOutput, stepRecord.Cached = stepCache.Lookup(stepRecord.CacheKey)
+			}
+			if !stepRecord.Cached {
+				rawOutput, stepRecord.Cached = sinceCache.Lookup(stepRecord.CacheKey)
+			}
+			if stepRecord.Cached {
+				stepLog.Infof("Step %s: inputs unchanged, reusing the output of an earlier run", stepKey(chainRole, roleKey))
+			} else {
+				beat.setPhase(phaseModel, usageLabel)
+				roleCtx := stepCtx
+				if roleDef.Conversation {
+					roleCtx = ai.WithConversation(stepCtx, conversation)
+				}
+				rawOutput, roleErr = ExecuteRoleContext(roleCtx, roleDef, roleInput, cfg, logFilePath)
+				spans.record(runs.SpanModel, chainRole.Name, modelStart, roleErr)
+			}
+			if used := usage.Default.Model(usageLabel).Since(usageBefore); used.Calls > 0 || used.InputTokens > 0 {
+				summary := used.CostSummary(cfg.Currency())
+				stepRecord.Usage = &summary
+			}
+			stepRecord.Response = rawOutput
+			if roleErr != nil {
+				stepRecord.Error = roleErr.Error()
+				stepOK = false
+				stepFailure = roleErr
+			}
 			// Try to extract tool call from Gemini response's text field if present
 			var toolCallText string
 			var output string
@@ -243,81 +1019,154 @@ func ExecuteChain(
 			} else {
 				toolCallText = rawOutput
 			}
-			extractor := ai.NewDefaultToolCallExtractor(toolRegistry)
-			tc, _, errExtract := extractor.ExtractToolCall(toolCallText)
+			// The role's extraction strategies decide where a tool call is
+			// looked for; ExecuteRoleContext has applied them already when it
+			// returns a call.
+			extractor, extractorErr := toolCallExtractor(cfg, roleDef, toolRegistry)
+			if extractorErr != nil {
+				return nil, extractorErr
+			}
+			tc, errExtract := extractedToolCall(rawOutput), error(nil)
+			if tc == nil {
+				tc, _, errExtract = extractor.ExtractResponse(rawOutput, toolCallText)
+			}
+			// A response in the legacy format, a bare JSON object with
+			// file_path and content, is a write_file call.
+			legacyText := ""
+			if errExtract != nil || tc == nil {
+				var legacy *types.ToolCall
+				if legacy, legacyText = legacyWriteCall(toolCallText); legacy != nil {
+					tc, errExtract = legacy, nil
+				}
+			}
 			if errExtract == nil && tc != nil {
 				b, _ := json.Marshal(tc)
 				output = string(b)
+				if legacyText != "" {
+					output = legacyText
+				}
 				// expose the parsed tool_call in the context for loop_condition templates
 				context["tool_call"] = map[string]interface{}{"name": tc.Name, "arguments": tc.Arguments}
-				// Inline tool execution logic
-				toolExecutor := &tools.ToolExecutor{
-					Registry:   toolRegistry,
-					Logger:     nil,
-					RetryCount: 1,
-					Timeout:    0,
-				}
 				call := tools.ToolCall{
 					Name:      tc.Name,
 					Arguments: tc.Arguments,
 				}
-				result, err := toolExecutor.Execute(call)
-				if err != nil {
-					lastToolResponse = map[string]interface{}{
-						"error":      "tool execution failed",
-						"tool":       tc.Name,
-						"exec_error": err.Error(),
+				refErr := toolRegistry.ResolveRefs(call, context)
+				stepRecord.ToolCall = tc
+				stepRecord.Diff = toolCallDiff(tc)
+				toolStart := time.Now()
+				beat.setPhase(phaseTool, tc.Name)
+				usage := &tools.UsageRecorder{}
+				var result interface{}
+				err := refErr
+				if err == nil {
+					err = tools.CheckFinalAnswer(roleDef.FinalAnswer, call)
+				}
+				if err == nil && opts.Interactive != nil {
+					reviewed, skipErr, abortErr := reviewToolCall(opts.Interactive, stepKey(chainRole, roleKey), tc)
+					if abortErr != nil {
+						return nil, abortErr
 					}
+					if err = skipErr; reviewed != tc && reviewed != nil {
+						tc, call = reviewed, tools.ToolCall{Name: reviewed.Name, Arguments: reviewed.Arguments}
+						b, _ := json.Marshal(tc)
+						output = string(b)
+						context["tool_call"] = map[string]interface{}{"name": tc.Name, "arguments": tc.Arguments}
+						stepRecord.ToolCall = tc
+						stepRecord.Diff = toolCallDiff(tc)
+					}
+				}
+				if err == nil {
+					guard.at(stepKey(chainRole, roleKey), context)
+					result, err = toolExecutor.Execute(tools.WithUsageRecorder(stepCtx, usage), call)
+					stepRecord.Guardrail = guard.take()
+				}
+				spans.record(runs.SpanTool, tc.Name, toolStart, err)
+				stepRecord.Commands = usage.Usages()
+				if err != nil {
+					feedback := toolErrorFeedback(tc.Name, tc.Arguments, err)
+					lastToolResponse = feedback
+					toolFeedback = toolErrorPrompt(feedback)
+					stepRecord.ToolError = err.Error()
 				} else {
 					lastToolResponse = result
+					stepRecord.ToolResult = result
+					continuation = chunkContinuation(result)
+					if legacyText != "" {
+						// The written content is the output of a legacy step.
+						lastToolResponse = map[string]interface{}{"file_path": call.Arguments["file_path"], "content": call.Arguments["content"]}
+					}
 				}
 				logger.DebugPrintf("[Chain] lastToolResponse after executing tool %s: %v", tc.Name, lastToolResponse)
 			} else {
-				// Fallback: extract first JSON object (legacy)
-				output = toolCallText
-				start := strings.Index(toolCallText, "{")
-				end := strings.LastIndex(toolCallText, "}")
-				if start != -1 && end != -1 && end > start {
-					output = toolCallText[start : end+1]
+				output = legacyText
+				lastToolResponse = nil
+				// clear any tool_call context when no tool was found
+				delete(context, "tool_call")
+			}
+			if roleDef.Conversation && stepRecord.ToolCall != nil {
+				if stepRecord.ToolError != "" {
+					conversation.AddToolResult(stepRecord.ToolCall.Name, "Error: "+stepRecord.ToolError)
+				} else {
+					conversation.AddToolResult(stepRecord.ToolCall.Name, toolResultText(lastToolResponse))
 				}
-				// Try to parse as a legacy tool call (file_path/content)
-				var fileObj struct {
-					FilePath string `json:"file_path"`
-					Content  string `json:"content"`
+			}
+			// Store output in context under steps.<name>.output and OutputKey if set
+			// (immediately after output is set)
+			stepOutput := interface{}(output)
+			// If lastToolResponse is from write_file and has content, store the content directly
+			if respMap, ok := lastToolResponse.(map[string]interface{}); ok {
+				if strContent, ok := respMap["content"].(string); ok && strContent != "" {
+					stepOutput = strContent
 				}
-				if err := json.Unmarshal([]byte(output), &fileObj); err == nil && fileObj.FilePath != "" {
-					logger.DebugPrintf("[Fallback] fileObj: file_path=%s, content-len=%d", fileObj.FilePath, len(fileObj.Content))
-					logger.DebugPrintf("[Fallback] Writing file: %s", fileObj.FilePath)
-					_, _ = tools.WriteFile(fileObj.FilePath, fileObj.Content)
-					lastToolResponse = map[string]interface{}{"file_path": fileObj.FilePath, "content": fileObj.Content}
-				} else {
-					lastToolResponse = nil
-					// clear any tool_call context when no tool was found
-					delete(context, "tool_call")
+			}
+			finished := stepRecord.ToolCall != nil && stepRecord.ToolCall.Name == tools.FinalAnswerName && stepRecord.ToolError == ""
+			if finished {
+				// The answer itself is the step's output, not the call.
+				stepOutput = lastToolResponse
+				context["final_answer"] = lastToolResponse
+			}
+			if chainRole.OutputScript != "" {
+				changed, scriptErr := runStepScript(stepCtx, scripts, chainRole.OutputScript, stepIndex, stepKey(chainRole, roleKey), "output_script", context, map[string]interface{}{"output": stepOutput, "iteration": i})
+				if scriptErr != nil {
+					return nil, scriptErr
+				}
+				if output, ok := changed["output"]; ok {
+					stepOutput = output
 				}
 			}
-			// Store output in context if OutputKey is set (immediately after output is set)
-			if chainRole.OutputKey != "" {
-				// If lastToolResponse is from write_file and has content, store the content directly
-				if lastToolResponse != nil {
-					if respMap, ok := lastToolResponse.(map[string]interface{}); ok {
-						if content, ok := respMap["content"]; ok {
-							if strContent, ok := content.(string); ok && strContent != "" {
-								context[chainRole.OutputKey] = strContent
-							} else {
-								context[chainRole.OutputKey] = output
-							}
-						} else {
-							context[chainRole.OutputKey] = output
-						}
-					} else {
-						context[chainRole.OutputKey] = output
+			storeStepOutput(context, chainRole, roleKey, stepOutput)
+			logger.DebugPrintf("[Chain] lastToolResponse after executing tool %s: %v", roleKey, lastToolResponse)
+			stepRecord.Context = runs.SnapshotContext(context)
+			if opts.DumpContext != nil {
+				fmt.Fprintf(opts.DumpContext, "--- Context after step %d (%s), iteration %d ---\n%s", stepIndex+1, chainRole.Name, i+1, runs.FormatContext(stepRecord.Context))
+			}
+			stepRecord.FinishedAt = time.Now()
+			opts.Run.AddStep(stepRecord)
+			saveRun(opts)
+			if opts.Interactive != nil {
+				if iterErr := opts.Interactive.AfterIteration(stepKey(chainRole, roleKey), i, stepOutput); iterErr != nil {
+					return nil, iterErr
+				}
+			}
+
+			// A chunked file write in progress re-prompts the same role until end_file.
+			if continuation != "" {
+				if continuations < maxChunkContinuations {
+					continuations++
+					if i+1 == loopCount {
+						loopCount++
 					}
-				} else {
-					context[chainRole.OutputKey] = output
+					continue
 				}
+				stepLog.Warnf("Step %s reached %d chunk continuations without end_file", roleKey, maxChunkContinuations)
+				continuation = ""
+			}
+
+			if finished && chainRole.StopsOnFinalAnswer() {
+				logger.DebugPrintf("Role %s gave its final answer, ending the loop", roleKey)
+				break
 			}
-			logger.DebugPrintf("[Chain] lastToolResponse after executing tool %s: %v", roleKey, lastToolResponse)
 
 			// If a loop condition is provided on the chain role, evaluate it now. If it evaluates
 			// to true, break out of the inner loop early.
@@ -330,11 +1179,353 @@ func ExecuteChain(
 					break
 				}
 			}
+			if chainRole.LoopScript != "" {
+				changed, scriptErr := runStepScript(stepCtx, scripts, chainRole.LoopScript, stepIndex, stepKey(chainRole, roleKey), "loop_script", context, map[string]interface{}{"output": stepOutput, "iteration": i})
+				if scriptErr != nil {
+					return nil, scriptErr
+				}
+				if script.Truth(changed["stop"]) {
+					logger.DebugPrintf("Loop script set stop, breaking loop for role %s", roleKey)
+					break
+				}
+			}
+		}
+		// A timed-out step stops the chain under on_error: fail; otherwise the
+		// chain moves on to the next step without the step's after hooks.
+		if timeoutErr := stepTimeoutError(chainCtx, stepCtx, chain, chainRole, stepIndex, stepKey(chainRole, chainRole.Role)); timeoutErr != nil {
+			cancelStep()
+			if chainCtx.Err() != nil || chainRole.OnError == types.OnErrorFail {
+				return nil, timeoutErr
+			}
+			if target := chainRole.ErrorTarget(); target != "" {
+				recordStepError(context, chainRole, timeoutErr)
+				next, jumpErr := jump(stepIndex, target)
+				if jumpErr != nil {
+					return nil, jumpErr
+				}
+				stepIndex = next
+				continue
+			}
+			logrus.Warnf("Moving on to the next step: %v", timeoutErr)
+			checkpointing = false
+			continue
+		}
+		if len(chainRole.After) > 0 {
+			beat.setPhase(phaseHooks, "after")
+			outputs, hookErr := runStepHooks(stepCtx, "after", chainRole.After, toolExecutor, scripts, context)
+			context["after_hooks"] = outputs
+			if skip, fatal := applyOnError(chainRole, "after", hookErr); fatal != nil {
+				cancelStep()
+				return nil, fatal
+			} else if skip && chainRole.ErrorTarget() != "" {
+				stepFailure = hookErr
+			}
+		}
+		cancelStep()
+		// A failed step goes on with its on_error step, if it names one, and
+		// any other step with its goto step.
+		target := ""
+		if stepFailure != nil && chainRole.ErrorTarget() != "" {
+			target = chainRole.ErrorTarget()
+			recordStepError(context, chainRole, stepFailure)
+		} else {
+			var gotoErr error
+			if target, gotoErr = stepGoto(stepIndex, chainRole, context); gotoErr != nil {
+				return nil, gotoErr
+			}
+		}
+		if name := checkpointName(chainRole, opts.CheckpointSteps); name != "" {
+			if stepOK && target == "" {
+				saveNamedCheckpoint(opts, chain, stepIndex+1, name, context, lastToolResponse)
+			} else {
+				logrus.Warnf("Checkpoint %s not saved: step %s did not complete or does not go on with the next step", name, stepKey(chainRole, chainRole.Role))
+			}
+		}
+		if target != "" {
+			next, jumpErr := jump(stepIndex, target)
+			if jumpErr != nil {
+				return nil, jumpErr
+			}
+			stepIndex = next
+			continue
+		}
+		if checkpointing = checkpointing && stepOK; checkpointing {
+			saveCheckpoint(opts, chain, stepIndex+1, context, lastToolResponse)
+		}
+	}
+	beat.endStep()
+	if checkpointing {
+		os.Remove(opts.Checkpoint)
+	}
+
+	if chain.OnSuccess != nil && opts.Run.DryRun && chain.OnSuccess.Command != "" {
+		logrus.Infof("Dry run: not running the on_success command")
+	} else if chain.OnSuccess != nil {
+		if hookErr := runOnSuccessHook(chainCtx, chain.OnSuccess, context, cfg, opts.Run, logFilePath); hookErr != nil {
+			logrus.Warnf("on_success hook failed: %v", hookErr)
 		}
 	}
 	return context, nil
 }
 
+// legacyWriteCall extracts the first JSON object of text, the output of a
+// step without a tool call. When the object has a file_path, the legacy
+// response format, it also returns the write_file call it stands for;
+// content_from is resolved with the call's other references.
+func legacyWriteCall(text string) (*types.ToolCall, string) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start != -1 && end != -1 && end > start {
+		text = text[start : end+1]
+	}
+	var fileObj struct {
+		FilePath    string `json:"file_path"`
+		Content     string `json:"content"`
+		ContentFrom string `json:"content_from"`
+	}
+	if err := json.Unmarshal([]byte(text), &fileObj); err != nil || fileObj.FilePath == "" {
+		return nil, text
+	}
+	args := map[string]interface{}{"file_path": fileObj.FilePath, "content": fileObj.Content}
+	if fileObj.ContentFrom != "" && fileObj.Content == "" {
+		args = map[string]interface{}{"file_path": fileObj.FilePath, "content_from": fileObj.ContentFrom}
+	}
+	return &types.ToolCall{Name: "write_file", Arguments: args}, text
+}
+
+// saveRun persists the run record when a store is configured.
+func saveRun(opts ChainOptions) {
+	if opts.Run == nil || opts.Store == nil {
+		return
+	}
+	if err := opts.Store.Save(opts.Run); err != nil {
+		logger.DebugPrintf("Failed to save run record: %v", err)
+	}
+}
+
+// checkResume fails when chain no longer starts with the steps completed
+// before cp was written.
+func checkResume(chain types.RoleChain, cp *runs.Checkpoint) error {
+	if cp.NextStep > len(chain.Steps) || len(cp.Steps) != cp.NextStep {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("checkpoint of run %s does not match the chain: %d step(s) completed, chain has %d", cp.RunID, cp.NextStep, len(chain.Steps)), nil)
+	}
+	for i, name := range cp.Steps {
+		if key := stepKey(chain.Steps[i], chain.Steps[i].Role); key != name {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("checkpoint of run %s does not match the chain: step %d is %s, was %s", cp.RunID, i+1, key, name), nil)
+		}
+	}
+	return nil
+}
+
+// saveCheckpoint writes the state of the run before step next to
+// opts.Checkpoint. A failed write is logged; the run goes on.
+func saveCheckpoint(opts ChainOptions, chain types.RoleChain, next int, context map[string]interface{}, lastToolResponse interface{}) {
+	if err := runs.SaveCheckpoint(opts.Checkpoint, newCheckpoint(opts, chain, next, context, lastToolResponse)); err != nil {
+		logrus.Warnf("Not checkpointed: %v", err)
+	}
+}
+
+// checkpointName returns the name of the checkpoint to save after step, if
+// any: its checkpoint setting, or its name when listed in steps.
+func checkpointName(step types.ChainRole, steps []string) string {
+	if step.Checkpoint != "" {
+		return step.Checkpoint
+	}
+	for _, s := range steps {
+		if s == step.Key() {
+			return s
+		}
+	}
+	return ""
+}
+
+// saveNamedCheckpoint writes the state of the run before step next as the
+// checkpoint called name and records it in the run. Like saveCheckpoint, it
+// only logs a failed write.
+func saveNamedCheckpoint(opts ChainOptions, chain types.RoleChain, next int, name string, context map[string]interface{}, lastToolResponse interface{}) {
+	switch {
+	case opts.Store == nil:
+		logrus.Warnf("Checkpoint %s not saved: the run has no store", name)
+		return
+	case opts.Run.DryRun:
+		logrus.Infof("Dry run: checkpoint %s not saved", name)
+		return
+	}
+	cp := newCheckpoint(opts, chain, next, context, lastToolResponse)
+	cp.Name = name
+	if err := runs.SaveCheckpoint(opts.Store.NamedCheckpointPath(opts.Run.ID, name), cp); err != nil {
+		logrus.Warnf("Checkpoint %s not saved: %v", name, err)
+		return
+	}
+	opts.Run.AddCheckpoint(name)
+	logrus.Infof("Saved checkpoint %s@%s", opts.Run.ID, name)
+}
+
+// newCheckpoint returns the state of the run before step next.
+func newCheckpoint(opts ChainOptions, chain types.RoleChain, next int, context map[string]interface{}, lastToolResponse interface{}) *runs.Checkpoint {
+	steps := make([]string, next)
+	for i := range steps {
+		steps[i] = stepKey(chain.Steps[i], chain.Steps[i].Role)
+	}
+	cp := &runs.Checkpoint{
+		RunID:            opts.Run.ID,
+		Chain:            opts.Run.Chain,
+		Input:            opts.Run.Input,
+		Steps:            steps,
+		NextStep:         next,
+		Context:          context,
+		LastToolResponse: lastToolResponse,
+		SavedAt:          time.Now(),
+	}
+	if opts.Resume != nil {
+		cp.Input = opts.Resume.Input
+	}
+	return cp
+}
+
+// stepKey is the name a step's output is stored under in the steps namespace.
+func stepKey(chainRole types.ChainRole, roleKey string) string {
+	if chainRole.Name != "" {
+		return chainRole.Name
+	}
+	return roleKey
+}
+
+// loadDocuments returns the saved documents names by name, for the documents
+// input of a step.
+func loadDocuments(store *tools.DocumentStore, names []string) (map[string]interface{}, error) {
+	documents := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		doc, err := store.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		documents[name] = doc.Content
+	}
+	return documents, nil
+}
+
+// storeStepOutput records a step iteration's output as steps.<name>.output and,
+// when the step has one, under its OutputKey. In append mode the values of
+// successive iterations are collected in a list.
+func storeStepOutput(context map[string]interface{}, chainRole types.ChainRole, roleKey string, value interface{}) {
+	steps, ok := context["steps"].(map[string]interface{})
+	if !ok {
+		steps = map[string]interface{}{}
+		context["steps"] = steps
+	}
+	key := stepKey(chainRole, roleKey)
+	entry, ok := steps[key].(map[string]interface{})
+	if !ok {
+		entry = map[string]interface{}{}
+		steps[key] = entry
+	}
+	appendMode := chainRole.OutputMode == types.OutputModeAppend
+	entry["output"] = mergeOutput(entry["output"], value, appendMode)
+	if chainRole.OutputKey != "" {
+		context[chainRole.OutputKey] = mergeOutput(context[chainRole.OutputKey], value, appendMode)
+	}
+}
+
+func mergeOutput(existing, value interface{}, appendMode bool) interface{} {
+	if !appendMode {
+		return value
+	}
+	list, _ := existing.([]interface{})
+	return append(list, value)
+}
+
+// maxChunkContinuations bounds how often a step is re-prompted to continue a
+// chunked file write.
+const maxChunkContinuations = 50
+
+// chunkContinuation returns the prompt addition asking the model to continue a
+// pending begin_file/append_file write, or "" when result is not one.
+func chunkContinuation(result interface{}) string {
+	status, ok := result.(map[string]interface{})
+	if !ok || status["pending"] != true {
+		return ""
+	}
+	return fmt.Sprintf("You are writing %v in chunks: %v bytes in %v chunk(s) so far, ending with:\n%v\n\n"+
+		"Continue exactly where it stops with append_file, or call end_file with the last chunk when the file is complete.",
+		status["file_path"], status["bytes"], status["chunks"], status["tail"])
+}
+
+// Limits of what toolErrorFeedback quotes back to the model.
+const (
+	maxFeedbackArgument = 200  // Bytes of each string argument
+	maxFeedbackOutput   = 2000 // Bytes at the end of a failed command's output
+)
+
+// toolErrorFeedback describes a failed tool call for lastToolResponse: the
+// tool, its arguments, the error, the end of what a failed command printed
+// and an instruction to fix the call.
+func toolErrorFeedback(name string, args map[string]interface{}, err error) map[string]interface{} {
+	arguments := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok && len(s) > maxFeedbackArgument {
+			v = s[:maxFeedbackArgument] + fmt.Sprintf("... (%d bytes)", len(s))
+		}
+		arguments[k] = v
+	}
+	feedback := map[string]interface{}{
+		"error":       "tool execution failed",
+		"tool":        name,
+		"arguments":   arguments,
+		"exec_error":  err.Error(),
+		"instruction": "Fix your tool call: correct the arguments or the command named in the error and send the call again, or use a different tool.",
+	}
+	if output := tools.ErrorOutput(err); output != "" {
+		if len(output) > maxFeedbackOutput {
+			output = "..." + output[len(output)-maxFeedbackOutput:]
+		}
+		feedback["output"] = output
+	}
+	return feedback
+}
+
+// toolErrorPrompt returns the prompt addition telling a looping role that its
+// last tool call failed.
+func toolErrorPrompt(feedback map[string]interface{}) string {
+	args, _ := json.Marshal(feedback["arguments"])
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your previous tool call failed.\nTool: %v\nArguments: %s\nError: %v\n", feedback["tool"], args, feedback["exec_error"])
+	if output, ok := feedback["output"].(string); ok {
+		fmt.Fprintf(&b, "Output:\n%s\n", strings.TrimRight(output, "\n"))
+	}
+	b.WriteString(fmt.Sprint(feedback["instruction"]))
+	return b.String()
+}
+
+// toolResultText returns a tool result as conversation text: strings as
+// they are, anything else as JSON.
+func toolResultText(result interface{}) string {
+	if s, ok := result.(string); ok {
+		return s
+	}
+	if b, err := json.Marshal(result); err == nil {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", result)
+}
+
+// toolCallDiff returns a unified diff of the change a write_file tool call
+// would make, or "" for other tools.
+func toolCallDiff(tc *types.ToolCall) string {
+	if tc == nil || (tc.Name != "write_file" && tc.Name != "WriteFile") {
+		return ""
+	}
+	filePath, _ := tc.Arguments["file_path"].(string)
+	if filePath == "" {
+		filePath, _ = tc.Arguments["filePath"].(string)
+	}
+	content, _ := tc.Arguments["content"].(string)
+	if filePath == "" {
+		return ""
+	}
+	return tools.GenerateUnifiedDiff(filePath, tools.ReadFileOrEmpty(filePath), content)
+}
+
 // keys returns the keys of a map[string]T as a []string
 func keys[T any](m map[string]T) []string {
 	out := make([]string, 0, len(m))
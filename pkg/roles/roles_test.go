@@ -3,15 +3,27 @@ package roles
 import (
 	"ai-team/config"
 	"ai-team/pkg/ai"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
 	"ai-team/pkg/types"
+	"ai-team/pkg/usage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestExecuteRole_Basic(t *testing.T) {
 	// Mock ai.CallGeminiFunc to avoid real HTTP
 	origCallGemini := ai.CallGeminiFunc
-	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool) (string, error) {
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
 		return "mocked-response", nil
 	}
 	defer func() { ai.CallGeminiFunc = origCallGemini }()
@@ -45,7 +57,7 @@ func TestExecuteChain_AnalysisDesign_StopsOnWriteFile(t *testing.T) {
 	// and then a write_file tool call on the third call.
 	origCallGemini := ai.CallGeminiFunc
 	callCount := 0
-	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool) (string, error) {
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
 		callCount++
 		if callCount < 3 {
 			// Return a JSON tool_call for list_dir
@@ -103,3 +115,1075 @@ func TestExecuteChain_AnalysisDesign_StopsOnWriteFile(t *testing.T) {
 		t.Fatalf("expected pre_design in context")
 	}
 }
+
+func TestExecuteChainWithOptions_RecordsRun(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return `{"tool_call": {"name": "list_dir", "arguments": {"path": "."}}}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"explorer": {Provider: "gemini", Model: "flash", Prompt: "Explore {{.problem}}"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "explorer", Input: map[string]interface{}{"problem": "{{.problem}}"}, OutputKey: "listing"},
+	}}
+
+	store := runs.NewStore(t.TempDir())
+	run := runs.NewRecord("explore", map[string]interface{}{"problem": "repo"})
+	run.Labels = map[string]string{"env": "staging"}
+	var eventLabels []interface{}
+	metrics := func(event string, fields map[string]interface{}) { eventLabels = append(eventLabels, fields["labels"]) }
+	_, err := ExecuteChainWithOptions(chain, map[string]interface{}{"problem": "repo"}, &mockCfg, ChainOptions{Run: run, Store: store, MetricsHook: metrics})
+	if err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+
+	saved, err := store.Load(run.ID)
+	if err != nil {
+		t.Fatalf("expected run to be saved: %v", err)
+	}
+	if saved.Status != runs.StatusSuccess || len(saved.Steps) != 1 {
+		t.Fatalf("unexpected run record: %+v", saved)
+	}
+	if saved.Labels["env"] != "staging" {
+		t.Errorf("expected the labels to be saved, got %v", saved.Labels)
+	}
+	if len(eventLabels) == 0 || fmt.Sprint(eventLabels[0]) != "map[env:staging]" {
+		t.Errorf("expected metrics events to carry the labels, got %v", eventLabels)
+	}
+	step := saved.Steps[0]
+	if step.Prompt != "Explore repo" {
+		t.Errorf("expected rendered prompt to be recorded, got %q", step.Prompt)
+	}
+	if step.ToolCall == nil || step.ToolCall.Name != "list_dir" {
+		t.Errorf("expected list_dir tool call to be recorded, got %+v", step.ToolCall)
+	}
+	kinds := map[string]int{}
+	for _, span := range saved.Timeline {
+		kinds[span.Kind]++
+	}
+	if kinds[runs.SpanModel] != 1 || kinds[runs.SpanTool] != 1 || kinds[runs.SpanToolAttempt] != 1 {
+		t.Errorf("expected one model, tool and attempt span, got %+v", saved.Timeline)
+	}
+	if saved.Timing == nil || len(saved.Timing.Steps) != 1 || saved.Timing.Steps[0].Iterations != 1 {
+		t.Errorf("expected timing summary for one step, got %+v", saved.Timing)
+	}
+}
+
+func TestExecuteChainWithOptions_StepCache(t *testing.T) {
+	calls := 0
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		calls++
+		return "summary of " + prompt, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"summarizer": {Provider: "gemini", Model: "flash", Prompt: "Summarize {{.problem}}"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "summary", Role: "summarizer", Input: map[string]interface{}{"problem": "{{.problem}}"}, OutputKey: "summary", Cache: true},
+	}}
+	store := runs.NewStore(t.TempDir())
+	run := func(problem string) (*runs.Record, map[string]interface{}) {
+		record := runs.NewRecord("summarize", nil)
+		ctx, err := ExecuteChainWithOptions(chain, map[string]interface{}{"problem": problem}, &mockCfg, ChainOptions{Run: record, Store: store})
+		if err != nil {
+			t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+		}
+		return record, ctx
+	}
+
+	first, _ := run("repo")
+	second, ctx := run("repo")
+	if calls != 1 {
+		t.Fatalf("expected the unchanged step to reuse the cached output, got %d model calls", calls)
+	}
+	if first.Steps[0].Cached || !second.Steps[0].Cached || ctx["summary"] != "summary of Summarize repo" {
+		t.Errorf("unexpected cache use: first %+v, second %+v, context %v", first.Steps[0], second.Steps[0], ctx["summary"])
+	}
+
+	if third, _ := run("other repo"); calls != 2 || third.Steps[0].Cached {
+		t.Errorf("expected changed input to call the model, got %d calls", calls)
+	}
+}
+
+func TestExecuteChainWithOptions_Since(t *testing.T) {
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		if strings.HasPrefix(prompt, "Fail") {
+			return "", fmt.Errorf("model unavailable")
+		}
+		return "out of " + prompt, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"designer": {Provider: "gemini", Model: "flash", Prompt: "Design {{.problem}}"},
+		"coder":    {Provider: "gemini", Model: "flash", Prompt: "{{.verb}} {{.design}}"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "design", Role: "designer", Input: map[string]interface{}{"problem": "{{.problem}}"}, OutputKey: "design"},
+		{Name: "code", Role: "coder", Input: map[string]interface{}{"design": "{{.design}}", "verb": "{{.verb}}"}},
+	}}
+	run := func(since *runs.Record, input map[string]interface{}) *runs.Record {
+		record := runs.NewRecord("build", input)
+		ExecuteChainWithOptions(chain, input, &mockCfg, ChainOptions{Run: record, Since: since})
+		return record
+	}
+
+	failed := run(nil, map[string]interface{}{"problem": "a cache", "verb": "Fail to code"})
+	if len(failed.Steps) != 2 || failed.Steps[1].Error == "" || len(prompts) != 2 {
+		t.Fatalf("expected the second step of the first run to fail, got %+v after %d calls", failed.Steps, len(prompts))
+	}
+	rerun := run(failed, map[string]interface{}{"problem": "a cache", "verb": "Code"})
+	if len(prompts) != 3 || prompts[2] != "Code out of Design a cache" {
+		t.Fatalf("expected only the changed step to call the model, got %q", prompts)
+	}
+	if !rerun.Steps[0].Cached || rerun.Steps[1].Cached || rerun.Steps[1].Error != "" || rerun.Since != failed.ID {
+		t.Errorf("unexpected rerun %+v", rerun)
+	}
+
+	run(rerun, map[string]interface{}{"problem": "a queue", "verb": "Code"})
+	if len(prompts) != 5 {
+		t.Errorf("expected a changed upstream output to re-run the downstream step, got %q", prompts)
+	}
+}
+
+func TestExecuteChainWithOptions_CheckpointResume(t *testing.T) {
+	var prompts []string
+	failCoder := true
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		if failCoder && strings.HasPrefix(prompt, "Code") {
+			return "", fmt.Errorf("503 service unavailable")
+		}
+		return "out of " + prompt, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"designer": {Provider: "gemini", Model: "flash", Prompt: "Design {{.problem}}"},
+		"coder":    {Provider: "gemini", Model: "flash", Prompt: "Code {{.design}}"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "design", Role: "designer", Input: map[string]interface{}{"problem": "{{.problem}}"}, OutputKey: "design"},
+		{Name: "code", Role: "coder", Input: map[string]interface{}{"design": "{{.design}}"}, OutputKey: "code"},
+		{Name: "review", Role: "designer", Input: map[string]interface{}{"problem": "{{.code}}"}},
+	}}
+	checkpoint := filepath.Join(t.TempDir(), "state.json")
+	first := runs.NewRecord("build", map[string]interface{}{"problem": "a cache"})
+	ExecuteChainWithOptions(chain, first.Input, &mockCfg, ChainOptions{Run: first, Checkpoint: checkpoint})
+	cp, err := runs.LoadCheckpoint(checkpoint)
+	if err != nil {
+		t.Fatalf("expected a checkpoint, got %v", err)
+	}
+	if cp.NextStep != 1 || cp.RunID != first.ID || cp.Context["design"] != "out of Design a cache" {
+		t.Fatalf("expected the checkpoint after the last step that succeeded, got %+v", cp)
+	}
+
+	failCoder = false
+	prompts = nil
+	second := runs.NewRecord("build", cp.Input)
+	ctx, err := ExecuteChainWithOptions(chain, cp.Input, &mockCfg, ChainOptions{Run: second, Checkpoint: checkpoint + ".2", Resume: cp})
+	if err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(prompts) != 2 || prompts[0] != "Code out of Design a cache" || ctx["code"] != "out of Code out of Design a cache" {
+		t.Errorf("expected only the remaining steps run on the restored context, got %q", prompts)
+	}
+	if second.ResumedFrom != first.ID || len(second.Steps) != 2 {
+		t.Errorf("unexpected resumed run %+v", second)
+	}
+	if _, err := os.Stat(checkpoint + ".2"); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint removed after success, got %v", err)
+	}
+
+	chain.Steps[0].Name = "plan"
+	if _, err := ExecuteChainWithOptions(chain, cp.Input, &mockCfg, ChainOptions{Resume: cp}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a changed chain to be refused, got %v", err)
+	}
+}
+
+func TestExecuteChainWithOptions_NamedCheckpointBranch(t *testing.T) {
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return "out of " + prompt, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"designer": {Provider: "gemini", Model: "flash", Prompt: "Design {{.problem}}"},
+		"coder":    {Provider: "gemini", Model: "flash", Prompt: "Code {{.design}} in {{.language}}"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "design", Role: "designer", Input: map[string]interface{}{"problem": "{{.problem}}"}, OutputKey: "design", Checkpoint: "designed"},
+		{Name: "code", Role: "coder", Input: map[string]interface{}{"design": "{{.design}}", "language": "{{.language}}"}, OutputKey: "code"},
+	}}
+	store := runs.NewStore(t.TempDir())
+	first := runs.NewRecord("build", map[string]interface{}{"problem": "a cache", "language": "Go"})
+	if _, err := ExecuteChainWithOptions(chain, first.Input, &mockCfg, ChainOptions{Run: first, Store: store, CheckpointSteps: []string{"code"}}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if strings.Join(first.Checkpoints, ",") != "designed,code" {
+		t.Errorf("expected both named checkpoints recorded, got %v", first.Checkpoints)
+	}
+	if _, err := os.Stat(store.NamedCheckpointPath(first.ID, "code")); err != nil {
+		t.Errorf("expected the named checkpoint kept after the run, got %v", err)
+	}
+
+	cp, err := store.LoadNamedCheckpoint(first.ID, "designed")
+	if err != nil {
+		t.Fatalf("expected the named checkpoint, got %v", err)
+	}
+	branch := cp.Branch(map[string]interface{}{"language": "Rust"})
+	prompts = nil
+	second := runs.NewRecord("build", branch.Input)
+	ctx, err := ExecuteChainWithOptions(chain, branch.Input, &mockCfg, ChainOptions{Run: second, Store: store, Resume: branch})
+	if err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(prompts) != 1 || prompts[0] != "Code out of Design a cache in Rust" || ctx["code"] != "out of Code out of Design a cache in Rust" {
+		t.Errorf("expected only the code step run with the changed input, got %q", prompts)
+	}
+	if second.BranchedFrom != first.ID+"@designed" || second.ResumedFrom != "" || second.Input["language"] != "Rust" {
+		t.Errorf("unexpected branched run %+v", second)
+	}
+	if _, err := store.LoadNamedCheckpoint(first.ID, "tested"); err == nil || !strings.Contains(err.Error(), "designed, code") {
+		t.Errorf("expected an unknown checkpoint to list the saved ones, got %v", err)
+	}
+}
+
+func TestExecuteChain_ContinuesChunkedWrite(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "big.txt")
+	responses := []string{
+		`{"tool_call": {"name": "begin_file", "arguments": {"file_path": "` + target + `", "content": "part1 "}}}`,
+		`{"tool_call": {"name": "append_file", "arguments": {"file_path": "` + target + `", "content": "part2 "}}}`,
+		`{"tool_call": {"name": "end_file", "arguments": {"file_path": "` + target + `", "content": "part3"}}}`,
+	}
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return responses[len(prompts)-1], nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"writer": {Provider: "gemini", Model: "flash", Prompt: "Write the file"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "writer"}}}
+
+	if _, err := ExecuteChain(chain, map[string]interface{}{}, &mockCfg, ""); err != nil {
+		t.Fatalf("ExecuteChain returned error: %v", err)
+	}
+	if len(prompts) != 3 {
+		t.Fatalf("expected 3 role calls, got %d", len(prompts))
+	}
+	if !strings.Contains(prompts[1], "in chunks") || !strings.Contains(prompts[1], "part1 ") {
+		t.Errorf("expected continuation prompt, got %q", prompts[1])
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "part1 part2 part3" {
+		t.Fatalf("unexpected assembled file %q, %v", data, err)
+	}
+}
+
+func TestCallProvider_ContinuesTruncatedResponse(t *testing.T) {
+	responses := []string{
+		`{"candidates":[{"content":{"parts":[{"text":"package main\n\nfunc main() {\n"}]},"finishReason":"MAX_TOKENS"}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"\tprintln(\"hi\")\n}\n"}]},"finishReason":"STOP"}]}`,
+	}
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return responses[len(prompts)-1], nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	role := types.Role{Provider: "gemini", Model: "flash", Prompt: "Write main.go"}
+
+	response, err := callProvider(context.Background(), role, "Write main.go", &mockCfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "cut off") {
+		t.Fatalf("expected one continuation request, got prompts %q", prompts)
+	}
+	text, reason, _ := ai.ResponseText("gemini", response)
+	if text != "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n" || reason != "STOP" {
+		t.Errorf("unexpected merged response %q (%s)", text, reason)
+	}
+
+	prompts = nil
+	role.MaxContinuations = -1
+	if _, err := callProvider(context.Background(), role, "Write main.go", &mockCfg); err != nil || len(prompts) != 1 {
+		t.Errorf("expected no continuation when disabled, got %d calls (%v)", len(prompts), err)
+	}
+}
+
+func TestCallProvider_Anthropic(t *testing.T) {
+	var got ai.ClaudeRequest
+	origCallClaude := ai.CallClaudeFunc
+	ai.CallClaudeFunc = func(_ *http.Client, prompt string, req ai.ClaudeRequest) (string, error) {
+		got = req
+		return `{"content":[{"type":"text","text":"done"}],"stop_reason":"end_turn"}`, nil
+	}
+	defer func() { ai.CallClaudeFunc = origCallClaude }()
+
+	mockCfg := config.Config{}
+	mockCfg.Anthropic.Apikey = "sk-provider"
+	mockCfg.Anthropic.Apiurl = "https://gateway.example.com"
+	mockCfg.Anthropic.Models = map[string]config.ModelConfig{"sonnet": {Model: "claude-sonnet-4-5", MaxTokens: 2048, Apikey: "sk-model"}}
+	role := types.Role{Provider: "anthropic", Model: "sonnet", Prompt: "Review"}
+
+	if _, err := callProvider(context.Background(), role, "Review", &mockCfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Model != "claude-sonnet-4-5" || got.MaxTokens != 2048 || got.APIKey != "sk-model" || got.URL != "https://gateway.example.com" {
+		t.Errorf("unexpected request %+v", got)
+	}
+
+	role.Model = "opus"
+	if _, err := callProvider(context.Background(), role, "Review", &mockCfg); err == nil {
+		t.Error("expected an error for an unknown model")
+	}
+}
+
+func TestCallProvider_Streams(t *testing.T) {
+	origStream, origCall := ai.StreamOllamaFunc, ai.CallOllamaFunc
+	ai.StreamOllamaFunc = func(_ *http.Client, _ string, _ string, _ string, _ []types.ConfigurableTool, _ ai.Generation, onText ai.StreamFunc) (string, error) {
+		onText("he")
+		onText("llo")
+		return `{"message":{"content":"hello"},"done_reason":"stop"}`, nil
+	}
+	ai.CallOllamaFunc = func(*http.Client, string, string, string, []types.ConfigurableTool, ai.Generation) (string, error) {
+		t.Error("expected the streaming call")
+		return "", nil
+	}
+	defer func() { ai.StreamOllamaFunc, ai.CallOllamaFunc = origStream, origCall }()
+
+	mockCfg := config.Config{}
+	mockCfg.Ollama.Models = map[string]config.ModelConfig{"llama": {Model: "llama3", Apiurl: "http://localhost:11434/api/chat"}}
+	role := types.Role{Provider: "ollama", Model: "llama", Prompt: "hi"}
+
+	var streamed strings.Builder
+	ctx := ai.WithStream(context.Background(), ai.StreamWriter(&streamed))
+	response, err := callProvider(ctx, role, "hi", &mockCfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streamed.String() != "hello" {
+		t.Errorf("expected streamed text, got %q", streamed.String())
+	}
+	if text, _, _ := ai.ResponseText("ollama", response); text != "hello" {
+		t.Errorf("unexpected response %s", response)
+	}
+}
+
+// recordingAdapter is a provider adapter answering with a fixed response and
+// recording its requests.
+type recordingAdapter struct{ requests *[]ai.ProviderRequest }
+
+func (a recordingAdapter) BuildRequest(req ai.ProviderRequest) ai.ProviderCall {
+	return func(*http.Client, ai.StreamFunc) (string, error) {
+		*a.requests = append(*a.requests, req)
+		return "recorded", nil
+	}
+}
+
+func (recordingAdapter) ParseResponse(raw string) (string, string, bool) { return raw, "", true }
+func (recordingAdapter) ParseToolCalls(string) (*types.ToolCall, error)  { return nil, nil }
+func (recordingAdapter) ParseUsage(string) (int, int, bool)              { return 0, 0, false }
+
+func TestCallProvider_RegisteredProvider(t *testing.T) {
+	var requests []ai.ProviderRequest
+	ai.RegisterProvider("recording", recordingAdapter{&requests})
+
+	mockCfg := config.Config{Tools: []types.ConfigurableTool{{Name: "lint"}}}
+	mockCfg.Providers = map[string]config.ProviderConfig{
+		"recording": {Apikey: "sk-provider", Models: map[string]config.ModelConfig{"r1": {Model: "rec-1", MaxTokens: 32}}},
+	}
+	role := types.Role{Provider: "recording", Model: "r1", Prompt: "hi"}
+	response, err := callProvider(context.Background(), role, "hi", &mockCfg)
+	if err != nil || response != "recorded" {
+		t.Fatalf("unexpected response %q, error %v", response, err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	if got := requests[0]; got.Prompt != "hi" || got.Model != "rec-1" || got.APIKey != "sk-provider" || got.Generation.MaxTokens != 32 || len(got.Tools) != 1 {
+		t.Errorf("unexpected request %+v", got)
+	}
+
+	role.Provider = "unregistered"
+	if _, err := callProvider(context.Background(), role, "hi", &mockCfg); err == nil || !strings.Contains(err.Error(), "unsupported or undefined provider") {
+		t.Errorf("expected an unsupported provider error, got %v", err)
+	}
+}
+
+func TestExecuteChain_VarsUnderInitialInput(t *testing.T) {
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "{{.dir}} {{.style}}"}}
+	chain := types.RoleChain{
+		Vars: map[string]interface{}{"src_dir": "pkg/", "style": "gofmt"},
+		Steps: []types.ChainRole{
+			{Role: "r", Input: map[string]interface{}{"dir": "{{.src_dir}}", "style": "{{.style}}"}},
+			{Role: "r", Input: map[string]interface{}{"dir": "{{.src_dir}}", "style": "fixed"}},
+		},
+	}
+
+	ctx, err := ExecuteChain(chain, map[string]interface{}{"style": "goimports"}, &mockCfg, "")
+	if err != nil {
+		t.Fatalf("ExecuteChain returned error: %v", err)
+	}
+	if len(prompts) != 2 || prompts[0] != "pkg/ goimports" || prompts[1] != "pkg/ fixed" {
+		t.Fatalf("unexpected prompts %q", prompts)
+	}
+	if ctx["src_dir"] != "pkg/" {
+		t.Errorf("expected vars in final context, got %v", ctx)
+	}
+}
+
+func TestExecuteChain_UsageByRoleAndChain(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(*http.Client, string, string, string, string, []types.ConfigurableTool, ai.Generation) (string, error) {
+		return `{"candidates":[{"content":{"parts":[{"text":"done"}]}}],"usageMetadata":{"promptTokenCount":100,"candidatesTokenCount":10}}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Roles = map[string]types.Role{
+		"usage-planner": {Provider: "gemini", Model: "flash", Prompt: "plan"},
+		"usage-coder":   {Provider: "gemini", Model: "flash", Prompt: "code"},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "usage-planner"}, {Role: "usage-coder"}, {Role: "usage-coder"}}}
+	if _, err := ExecuteChainWithOptions(chain, nil, &mockCfg, ChainOptions{Run: runs.NewRecord("usage-chain", nil)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byLabel := func(list []usage.Usage, label string) usage.Usage {
+		for _, u := range list {
+			if u.Label == label {
+				return u
+			}
+		}
+		return usage.Usage{}
+	}
+	if u := byLabel(usage.Default.Roles(), "usage-coder"); u.InputTokens != 200 || u.OutputTokens != 20 {
+		t.Errorf("unexpected usage of role usage-coder: %+v", u)
+	}
+	if u := byLabel(usage.Default.Roles(), "usage-planner"); u.InputTokens != 100 {
+		t.Errorf("unexpected usage of role usage-planner: %+v", u)
+	}
+	if u := byLabel(usage.Default.Chains(), "usage-chain"); u.InputTokens != 300 || u.OutputTokens != 30 {
+		t.Errorf("unexpected usage of chain usage-chain: %+v", u)
+	}
+}
+
+func TestExecuteChain_StepNamespaceAndAppend(t *testing.T) {
+	calls := 0
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		calls++
+		return fmt.Sprintf("answer %d", calls), nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "{{.prev}}"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "ideas", Role: "r", Input: map[string]interface{}{"prev": "start"}, Loop: true, LoopCount: 2, OutputKey: "ideas", OutputMode: types.OutputModeAppend},
+		{Name: "pick", Role: "r", Input: map[string]interface{}{"prev": "{{.steps.ideas.output}}"}, OutputKey: "choice"},
+	}}
+
+	ctx, err := ExecuteChain(chain, map[string]interface{}{}, &mockCfg, "")
+	if err != nil {
+		t.Fatalf("ExecuteChain returned error: %v", err)
+	}
+	ideas, ok := ctx["ideas"].([]interface{})
+	if !ok || len(ideas) != 2 || ideas[0] != "answer 1" || ideas[1] != "answer 2" {
+		t.Fatalf("expected appended outputs, got %#v", ctx["ideas"])
+	}
+	steps := ctx["steps"].(map[string]interface{})
+	if steps["pick"].(map[string]interface{})["output"] != "answer 3" {
+		t.Errorf("unexpected steps namespace %v", steps)
+	}
+}
+
+func TestExecuteChain_RecordsContextSnapshots(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "go"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "first", Role: "r", OutputKey: "out"}}}
+
+	var dump bytes.Buffer
+	run := runs.NewRecord("test", nil)
+	_, err := ExecuteChainWithOptions(chain, map[string]interface{}{"api_token": "hunter2"}, &mockCfg, ChainOptions{Run: run, DumpContext: &dump})
+	if err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	snapshot := run.Steps[0].Context
+	if snapshot["out"] != "done" || snapshot["api_token"] != runs.Redacted {
+		t.Errorf("unexpected context snapshot %v", snapshot)
+	}
+	if !strings.Contains(dump.String(), "Context after step 1 (first)") || strings.Contains(dump.String(), "hunter2") {
+		t.Errorf("unexpected context dump:\n%s", dump.String())
+	}
+}
+
+func TestExecuteChain_StrictTemplates(t *testing.T) {
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "Solve {{.task}}"}}
+
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "solve", Role: "r"}}}
+	_, err := ExecuteChain(chain, map[string]interface{}{}, &mockCfg, "")
+	if err == nil || !strings.Contains(err.Error(), `step 1 (solve)`) || !strings.Contains(err.Error(), `missing key "task"`) {
+		t.Fatalf("expected missing key error naming step and key, got %v", err)
+	}
+	if len(prompts) != 0 {
+		t.Errorf("expected no model call, got %d", len(prompts))
+	}
+
+	chain.Steps[0].Input = map[string]interface{}{"task": "{{.problem}}"}
+	_, err = ExecuteChain(chain, map[string]interface{}{}, &mockCfg, "")
+	if err == nil || !strings.Contains(err.Error(), `input "task" references missing key "problem"`) {
+		t.Fatalf("expected missing key error for input template, got %v", err)
+	}
+
+	lenient := false
+	chain.Strict = &lenient
+	if _, err := ExecuteChain(chain, map[string]interface{}{}, &mockCfg, ""); err != nil {
+		t.Fatalf("expected non-strict chain to run, got %v", err)
+	}
+	if len(prompts) != 1 || prompts[0] != "Solve " {
+		t.Errorf("unexpected prompts %q", prompts)
+	}
+}
+
+func TestExecuteChain_ToolsPrompt(t *testing.T) {
+	var prompts []string
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "Do it.\n{{.tools_prompt}}"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "do", Role: "r"}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], `{"tool_call": {"name": "<tool>"`) || !strings.Contains(prompts[0], "- ReadFile: ") {
+		t.Fatalf("expected unescaped tools section in prompt, got %q", prompts)
+	}
+	if !strings.Contains(prompts[0], `"content_from": "steps.design.output"`) {
+		t.Errorf("expected the argument reference hint in a chain's tools section")
+	}
+	if run.ToolsHash == "" || run.ToolsHash != defaultToolRegistry().Hash() {
+		t.Errorf("expected run to record the tools hash, got %q", run.ToolsHash)
+	}
+	if run.Steps[0].Prompt != prompts[0] {
+		t.Errorf("recorded prompt differs from sent prompt")
+	}
+}
+
+func TestExecuteChain_ContentFromStepOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "design.md")
+	responses := []string{
+		"# Design\n\nA long design document.",
+		fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content_from": "steps.design.output"}}}`, out),
+		fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content_from": "steps.nothing.output"}}}`, out+".bad"),
+	}
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "go"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "design", Role: "r"}, {Name: "save", Role: "r"}, {Name: "broken", Role: "r"}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != "# Design\n\nA long design document." {
+		t.Fatalf("expected the design step's output in %s, got %q (%v)", out, data, err)
+	}
+	if len(run.Steps) != 3 || run.Steps[1].ToolCall.Arguments["content"] != string(data) {
+		t.Errorf("expected the recorded call to carry the resolved content, got %+v", run.Steps)
+	}
+	if !strings.Contains(run.Steps[2].ToolError, "content_from") {
+		t.Errorf("expected an unresolvable reference to fail the tool call, got %q", run.Steps[2].ToolError)
+	}
+	if _, err := os.Stat(out + ".bad"); !os.IsNotExist(err) {
+		t.Error("expected no file for the unresolvable reference")
+	}
+}
+
+func TestExecuteChain_ToolErrorFeedback(t *testing.T) {
+	var prompts []string
+	dir := t.TempDir()
+	responses := []string{
+		fmt.Sprintf(`{"tool_call": {"name": "write_file", "arguments": {"file_path": %q, "content": "x"}}}`, dir),
+		"fixed",
+	}
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "Build it. {{.lastToolResponse_json}}"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "build", Role: "r", Loop: true, LoopCount: 2}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected two iterations, got %d", len(prompts))
+	}
+	for _, want := range []string{"Your previous tool call failed.", "Tool: write_file", fmt.Sprintf(`"file_path":%q`, dir), "Error: ", "is a directory", "Fix your tool call"} {
+		if !strings.Contains(prompts[1], want) {
+			t.Errorf("expected %q in the next prompt, got %q", want, prompts[1])
+		}
+	}
+	if strings.Contains(prompts[0], "previous tool call") {
+		t.Errorf("expected no feedback before a tool call failed, got %q", prompts[0])
+	}
+	if run.Steps[0].ToolError == "" {
+		t.Error("expected the failed call to be recorded")
+	}
+}
+
+func TestExecuteChain_NativeToolCalls(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hello.txt")
+	var offered int
+	origCallOpenAITools := ai.CallOpenAIToolsFunc
+	ai.CallOpenAIToolsFunc = func(_ *http.Client, prompt string, req ai.OpenAIToolsRequest) (string, error) {
+		offered = len(req.Tools)
+		if req.Model != "gpt-4o" {
+			t.Errorf("unexpected model %q", req.Model)
+		}
+		args, _ := json.Marshal(map[string]string{"file_path": out, "content": "hello"})
+		body, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{map[string]interface{}{
+			"message": map[string]interface{}{"role": "assistant", "tool_calls": []interface{}{map[string]interface{}{
+				"type": "function", "function": map[string]interface{}{"name": "write_file", "arguments": string(args)},
+			}}},
+			"finish_reason": "tool_calls",
+		}}})
+		return string(body), nil
+	}
+	defer func() { ai.CallOpenAIToolsFunc = origCallOpenAITools }()
+
+	mockCfg := config.Config{}
+	mockCfg.OpenAI.Models = map[string]config.ModelConfig{"gpt": {Model: "gpt-4o", NativeTools: true}}
+	mockCfg.OpenAI.DefaultApiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "openai", Model: "gpt", Prompt: "Save hello."}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "save", Role: "r"}}}
+
+	run := runs.NewRecord("test", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if offered != len(defaultToolRegistry().ListTools()) {
+		t.Errorf("expected the chain's tools to be offered, got %d", offered)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "hello" {
+		t.Errorf("expected the native tool call to be executed, got %q (%v)", data, err)
+	}
+	if tc := run.Steps[0].ToolCall; tc == nil || tc.Name != "write_file" {
+		t.Errorf("expected the tool call to be recorded, got %+v", tc)
+	}
+}
+
+func TestExecuteChain_GeminiFunctionCalls(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hello.txt")
+	var offered int
+	origCallGeminiTools := ai.CallGeminiToolsFunc
+	ai.CallGeminiToolsFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, schemas []tools.ToolSchema, _ ai.Generation) (string, error) {
+		offered = len(schemas)
+		call := map[string]interface{}{"candidates": []interface{}{map[string]interface{}{"content": map[string]interface{}{"parts": []interface{}{
+			map[string]interface{}{"functionCall": map[string]interface{}{"name": "write_file", "args": map[string]string{"file_path": out, "content": "hello"}}},
+		}}}}}
+		body, _ := json.Marshal(call)
+		return string(body), nil
+	}
+	defer func() { ai.CallGeminiToolsFunc = origCallGeminiTools }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash", NativeTools: true}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "Save hello."}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "save", Role: "r"}}}
+
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if offered != len(defaultToolRegistry().ListTools()) {
+		t.Errorf("expected the chain's tools to be declared, got %d", offered)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "hello" {
+		t.Errorf("expected the function call to be executed, got %q (%v)", data, err)
+	}
+}
+
+func TestExecuteChain_Conversation(t *testing.T) {
+	dir := t.TempDir()
+	origCallGemini, origCallGeminiChat := ai.CallGeminiFunc, ai.CallGeminiChatFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return fmt.Sprintf(`{"tool_call": {"name": "list_dir", "arguments": {"path": %q}}}`, dir), nil
+	}
+	var histories [][]types.Message
+	ai.CallGeminiChatFunc = func(_ *http.Client, history []types.Message, task, model, apiURL, apiKey string, schemas []tools.ToolSchema, _ ai.Generation) (string, error) {
+		histories = append(histories, append([]types.Message(nil), history...))
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc, ai.CallGeminiChatFunc = origCallGemini, origCallGeminiChat }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"r": {Provider: "gemini", Model: "flash", Prompt: "Explore.", Conversation: true}}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "explore", Role: "r", Loop: true, LoopCount: 2},
+		{Name: "again", Role: "r"},
+	}}
+
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(histories) != 1 {
+		t.Fatalf("expected only the second iteration to send history, got %d call(s)", len(histories))
+	}
+	history := histories[0]
+	if len(history) != 3 || history[0].Role != types.MessageUser || history[0].Content != "Explore." ||
+		history[1].Role != types.MessageAssistant || !strings.Contains(history[1].Content, "list_dir") ||
+		history[2].Role != types.MessageTool || history[2].Name != "list_dir" {
+		t.Errorf("expected the prompt, answer and tool result of the first iteration, got %+v", history)
+	}
+}
+
+func TestExecuteChain_Documents(t *testing.T) {
+	dir := t.TempDir()
+	origCallGemini := ai.CallGeminiFunc
+	var prompts []string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		return `{"tool_call": {"name": "save_document", "arguments": {"name": "design", "content": "# Design\nUse a queue."}}}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{DocumentsDir: dir}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{
+		"architect": {Provider: "gemini", Model: "flash", Prompt: "Design it."},
+		"tester":    {Provider: "gemini", Model: "flash", Prompt: `Plan tests for: {{index .documents "design.md"}}`},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Name: "design", Role: "architect"},
+		{Name: "plan", Role: "tester", Documents: []string{"design.md"}},
+	}}
+	run := runs.NewRecord("", nil)
+
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "Use a queue.") {
+		t.Fatalf("expected the saved design in the second prompt, got %q", prompts)
+	}
+	doc, err := tools.NewDocumentStore(dir).Load("design.md")
+	if err != nil {
+		t.Fatalf("expected the document saved, got %v", err)
+	}
+	if doc.RunID != run.ID || doc.Role != "tester" {
+		t.Errorf("expected the last step's run and role in the front matter, got %+v", doc.DocumentMeta)
+	}
+
+	chain.Steps = []types.ChainRole{{Name: "plan", Role: "tester", Documents: []string{"missing.md"}}}
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{}); err == nil || !strings.Contains(err.Error(), "missing.md") {
+		t.Errorf("expected an error naming the missing document, got %v", err)
+	}
+}
+
+func TestToolErrorFeedback(t *testing.T) {
+	err := errors.New(errors.ErrCodeTool, "failed to run command: make", &tools.OutputError{Output: strings.Repeat("x", maxFeedbackOutput) + "missing separator", Err: fmt.Errorf("exit status 2")})
+	feedback := toolErrorFeedback("RunCommand", map[string]interface{}{"command": "make", "stdin": strings.Repeat("y", 500)}, err)
+	if feedback["error"] != "tool execution failed" || feedback["tool"] != "RunCommand" || feedback["exec_error"] != err.Error() {
+		t.Errorf("unexpected feedback: %v", feedback)
+	}
+	output, _ := feedback["output"].(string)
+	if !strings.HasSuffix(output, "missing separator") || len(output) != maxFeedbackOutput+3 {
+		t.Errorf("expected the end of the command output, got %d bytes", len(output))
+	}
+	args := feedback["arguments"].(map[string]interface{})
+	if args["command"] != "make" || !strings.HasSuffix(args["stdin"].(string), "... (500 bytes)") {
+		t.Errorf("expected long arguments to be shortened, got %v", args)
+	}
+	if _, ok := toolErrorFeedback("write_file", nil, fmt.Errorf("denied"))["output"]; ok {
+		t.Error("expected no output for errors of tools that run no command")
+	}
+}
+
+func TestRunRole_Result(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return `{"candidates":[{"content":{"parts":[{"text":"Saving.\n{\"tool_call\": {\"name\": \"write_file\", \"arguments\": {\"file_path\": \"a.md\", \"content\": \"x\"}}}"}]}}]}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	role := types.Role{Provider: "gemini", Model: "flash", Prompt: "write"}
+
+	result, err := RunRole(role, map[string]interface{}{}, &mockCfg, "")
+	if err != nil {
+		t.Fatalf("RunRole returned error: %v", err)
+	}
+	if !strings.HasPrefix(result.Text, "Saving.\n") || !strings.HasPrefix(result.Raw, `{"candidates"`) {
+		t.Errorf("unexpected text/raw: %q / %q", result.Text, result.Raw)
+	}
+	if result.ToolCall == nil || result.ToolCall.Name != "write_file" {
+		t.Errorf("expected write_file tool call, got %+v", result.ToolCall)
+	}
+}
+
+func TestExecuteChain_ExtractionStrategies(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		if strings.Contains(prompt, "YAML") {
+			return "```yaml\ntool_call:\n  name: list_documents\n  arguments: {}\n```", nil
+		}
+		return `Listing: {"tool_call": {"name": "list_documents", "arguments": {}}}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{DocumentsDir: t.TempDir()}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Extraction.Providers = map[string][]string{"gemini": {"json_block"}}
+	mockCfg.Roles = map[string]types.Role{
+		"inline": {Provider: "gemini", Model: "flash", Prompt: "List."},
+		"yaml":   {Provider: "gemini", Model: "flash", Prompt: "List in YAML.", Extraction: []string{"yaml"}},
+	}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "inline"}, {Role: "yaml"}}}
+	run := runs.NewRecord("", nil)
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if run.Steps[0].ToolCall != nil {
+		t.Errorf("expected inline JSON ignored by the provider's json_block order, got %+v", run.Steps[0].ToolCall)
+	}
+	if tc := run.Steps[1].ToolCall; tc == nil || tc.Name != "list_documents" {
+		t.Errorf("expected the role's yaml strategy to find the call, got %+v", tc)
+	}
+
+	mockCfg.Extraction.Providers = map[string][]string{"gemini": {"xml"}}
+	if _, err := ExecuteChainWithOptions(chain, map[string]interface{}{}, &mockCfg, ChainOptions{}); err == nil || !strings.Contains(err.Error(), "unknown extraction strategy") {
+		t.Errorf("expected an unknown strategy error, got %v", err)
+	}
+}
+
+func TestExecuteChain_FinalAnswer(t *testing.T) {
+	var prompts []string
+	responses := []string{
+		`{"tool_call": {"name": "final_answer", "arguments": {"answer": {"verdict": "maybe"}}}}`,
+		`{"tool_call": {"name": "final_answer", "arguments": {"answer": {"verdict": "approve", "notes": ["tidy"]}}}}`,
+		"never asked",
+	}
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"reviewer": {
+		Provider: "gemini", Model: "flash", Prompt: "Review. {{.lastToolResponse_json}}",
+		FinalAnswer: &types.JSONSchema{Type: "object", Required: []string{"verdict"}, Properties: map[string]*types.JSONSchema{
+			"verdict": {Type: "string", Enum: []interface{}{"approve", "reject"}},
+		}},
+	}}
+	chain := types.RoleChain{Steps: []types.ChainRole{{Name: "review", Role: "reviewer", Loop: true, LoopCount: 3, OutputKey: "review"}}}
+
+	ctx, err := ExecuteChain(chain, map[string]interface{}{}, &mockCfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The answer not matching the role's schema is fed back; the valid one
+	// ends the loop.
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "must be one of approve, reject") {
+		t.Fatalf("prompts = %q", prompts)
+	}
+	answer, ok := ctx["review"].(map[string]interface{})
+	if !ok || answer["verdict"] != "approve" {
+		t.Errorf("review = %#v, want the answer object", ctx["review"])
+	}
+	if !reflect.DeepEqual(ctx["final_answer"], ctx["review"]) {
+		t.Errorf("final_answer = %#v", ctx["final_answer"])
+	}
+}
+
+func TestExecuteChainWithOptions_LegacyWriteError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	os.WriteFile(blocker, []byte("a file, not a directory"), 0644)
+	target := filepath.Join(blocker, "out.txt")
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return fmt.Sprintf(`{"file_path": %q, "content": "hi"}`, target), nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"coder": {Provider: "gemini", Model: "flash", Prompt: "code"}}
+	run := runs.NewRecord("build", nil)
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder"}}}
+	if _, err := ExecuteChainWithOptions(chain, nil, &mockCfg, ChainOptions{Run: run}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if len(run.Steps) != 1 || run.Steps[0].ToolError == "" {
+		t.Fatalf("expected the failed legacy write recorded as a tool error, got %+v", run.Steps)
+	}
+}
+
+// redirectWrites is a ChainInteraction that moves every write to path.
+type redirectWrites struct {
+	path string
+}
+
+func (redirectWrites) BeforeStep(int, string, map[string]interface{}) (bool, error) {
+	return false, nil
+}
+
+func (redirectWrites) ReviewInput(_ string, _ int, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
+
+func (r redirectWrites) ReviewToolCall(_ string, call *types.ToolCall) (*types.ToolCall, error) {
+	args := map[string]interface{}{}
+	for k, v := range call.Arguments {
+		args[k] = v
+	}
+	args["file_path"] = r.path
+	return &types.ToolCall{Name: call.Name, Arguments: args}, nil
+}
+
+func (redirectWrites) AfterIteration(string, int, interface{}) error {
+	return nil
+}
+
+func TestExecuteChainWithOptions_LegacyWriteEditedToIgnoredPath(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return `{"file_path": "notes.md", "content": "hi"}`, nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+
+	mockCfg := config.Config{Ignore: []string{".env"}}
+	mockCfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	mockCfg.Gemini.Apiurl = "http://mock"
+	mockCfg.Roles = map[string]types.Role{"coder": {Provider: "gemini", Model: "flash", Prompt: "code"}}
+	run := runs.NewRecord("build", nil)
+	chain := types.RoleChain{Steps: []types.ChainRole{{Role: "coder"}}}
+	if _, err := ExecuteChainWithOptions(chain, nil, &mockCfg, ChainOptions{Run: run, Interactive: redirectWrites{path: ".env"}}); err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	if _, err := os.Stat(".env"); err == nil {
+		t.Fatal("expected the edited write to an ignored path to be refused")
+	}
+	if len(run.Steps) != 1 || !strings.Contains(run.Steps[0].ToolError, "excluded by ignore rules") {
+		t.Errorf("expected the refusal recorded as a tool error, got %+v", run.Steps)
+	}
+}
@@ -0,0 +1,68 @@
+package roles
+
+import (
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunIDEnv is the environment variable holding the active run ID, inherited
+// by commands run by tools and hooks.
+const RunIDEnv = "AI_TEAM_RUN_ID"
+
+// activeRun holds the ID of the chain run, role call or interactive session in
+// progress. It is sent to gateways as the run_id attribution attribute and
+// added to log entries by RunIDHook.
+var activeRun struct {
+	sync.Mutex
+	id string
+}
+
+func setCurrentRunID(id string) {
+	activeRun.Lock()
+	defer activeRun.Unlock()
+	activeRun.id = id
+}
+
+func currentRunID() string {
+	activeRun.Lock()
+	defer activeRun.Unlock()
+	return activeRun.id
+}
+
+// beginRun makes id the active run ID, also in the environment, and returns
+// the function restoring the previous one.
+func beginRun(id string) func() {
+	previousID := currentRunID()
+	previousEnv, hadEnv := os.LookupEnv(RunIDEnv)
+	setCurrentRunID(id)
+	os.Setenv(RunIDEnv, id)
+	return func() {
+		setCurrentRunID(previousID)
+		if hadEnv {
+			os.Setenv(RunIDEnv, previousEnv)
+		} else {
+			os.Unsetenv(RunIDEnv)
+		}
+	}
+}
+
+// RunIDHook is a logrus hook adding the active run ID to every entry as the
+// run_id field.
+type RunIDHook struct{}
+
+// Levels returns all levels.
+func (RunIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the run_id field while a run is active.
+func (RunIDHook) Fire(entry *logrus.Entry) error {
+	if id := currentRunID(); id != "" {
+		if _, ok := entry.Data["run_id"]; !ok {
+			entry.Data["run_id"] = id
+		}
+	}
+	return nil
+}
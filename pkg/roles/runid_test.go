@@ -0,0 +1,51 @@
+package roles
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+)
+
+func TestRunID_PropagatedToHooksAndRoleCalls(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return "done", nil
+	}
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	os.Unsetenv(RunIDEnv)
+
+	cfg := &config.Config{}
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	cfg.Gemini.Apiurl = "http://mock"
+	cfg.Roles = map[string]types.Role{"worker": {Provider: "gemini", Model: "flash", Prompt: "work"}}
+	chain := types.RoleChain{Steps: []types.ChainRole{
+		{Role: "worker", After: []types.StepHook{{Command: "echo $" + RunIDEnv}}},
+	}}
+
+	run := runs.NewRecord("work", nil)
+	ctx, err := ExecuteChainWithOptions(chain, nil, cfg, ChainOptions{Run: run})
+	if err != nil {
+		t.Fatalf("ExecuteChainWithOptions returned error: %v", err)
+	}
+	outputs, _ := ctx["after_hooks"].([]interface{})
+	if len(outputs) != 1 || strings.TrimSpace(outputs[0].(string)) != run.ID {
+		t.Errorf("expected the hook to see run ID %s, got %v", run.ID, outputs)
+	}
+
+	result, err := RunRole(cfg.Roles["worker"], nil, cfg, "")
+	if err != nil {
+		t.Fatalf("RunRole returned error: %v", err)
+	}
+	if result.RunID == "" || result.RunID == run.ID {
+		t.Errorf("expected a role call on its own to get a new run ID, got %q", result.RunID)
+	}
+	if _, ok := os.LookupEnv(RunIDEnv); ok || currentRunID() != "" {
+		t.Errorf("expected the run ID to be cleared after the call")
+	}
+}
@@ -0,0 +1,50 @@
+package roles
+
+import (
+	"ai-team/pkg/errors"
+	"ai-team/pkg/script"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// scriptVars are the variables input, output and loop scripts are given on
+// top of the chain context. They are not written back to the context.
+var scriptVars = map[string]bool{"input": true, "output": true, "iteration": true, "stop": true}
+
+// runScript runs prog over a copy of context plus vars. Variables the script
+// assigns are written to context, except vars and names starting with an
+// underscore; the returned map holds every variable it changed.
+func runScript(ctx context.Context, prog *script.Program, context, vars map[string]interface{}) (map[string]interface{}, error) {
+	globals := make(map[string]interface{}, len(context)+len(vars))
+	for k, v := range context {
+		globals[k] = v
+	}
+	for k, v := range vars {
+		globals[k] = v
+	}
+	changed, err := prog.Run(ctx, globals)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range changed {
+		if !scriptVars[k] && !strings.HasPrefix(k, "_") {
+			context[k] = v
+		}
+	}
+	return changed, nil
+}
+
+// runStepScript runs the step script ref (input_script, output_script or
+// loop_script, named by field) and reports its failure as a role error.
+func runStepScript(ctx context.Context, scripts map[string]*script.Program, ref string, stepIndex int, step, field string, context, vars map[string]interface{}) (map[string]interface{}, error) {
+	prog, ok := scripts[ref]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): %s was not compiled", stepIndex+1, step, field), nil)
+	}
+	changed, err := runScript(ctx, prog, context, vars)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s): %s failed", stepIndex+1, step, field), err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,70 @@
+package roles
+
+import (
+	"ai-team/pkg/ai"
+	"ai-team/pkg/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExecuteChain_Scripts(t *testing.T) {
+	origCallGemini := ai.CallGeminiFunc
+	defer func() { ai.CallGeminiFunc = origCallGemini }()
+	calls := 0
+	cfg := hookChainConfig(&calls)
+	var prompts []string
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		calls++
+		prompts = append(prompts, prompt)
+		return "  draft  ", nil
+	}
+	role := cfg.Roles["coder"]
+	role.Prompt = "code {{.task}}"
+	cfg.Roles["coder"] = role
+
+	chain := types.RoleChain{
+		Scripts: map[string]string{"shout": "input['task'] = input['task'].upper() + ' for ' + owner"},
+		Steps: []types.ChainRole{{
+			Name:         "write",
+			Role:         "coder",
+			Input:        map[string]interface{}{"task": "{{.topic}}"},
+			Loop:         true,
+			Before:       []types.StepHook{{Script: "owner = 'team-' + str(len(topic))\n_scratch = 1"}},
+			InputScript:  "shout",
+			OutputScript: "output = output.strip() + '!'\nlengths = lengths + [len(output)]",
+			LoopScript:   "stop = iteration >= 1",
+			OutputKey:    "draft",
+		}},
+	}
+	ctx, err := ExecuteChain(chain, map[string]interface{}{"topic": "docs", "lengths": []interface{}{}}, cfg, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected loop_script to stop after 2 iterations, got %d calls", calls)
+	}
+	if len(prompts) == 0 || prompts[0] != "code DOCS for team-4" {
+		t.Errorf("expected input_script to rewrite the input, got prompts %q", prompts)
+	}
+	if ctx["draft"] != "draft!" {
+		t.Errorf("expected output_script to rewrite the output, got %v", ctx["draft"])
+	}
+	if lengths, _ := ctx["lengths"].([]interface{}); len(lengths) != 2 {
+		t.Errorf("expected script variables in the context, got lengths %v", ctx["lengths"])
+	}
+	for _, key := range []string{"_scratch", "output", "input", "stop", "iteration"} {
+		if _, ok := ctx[key]; ok {
+			t.Errorf("expected %s to stay out of the context", key)
+		}
+	}
+	before, _ := ctx["before_hooks"].([]interface{})
+	if len(before) != 1 || before[0].(map[string]interface{})["owner"] != "team-4" {
+		t.Errorf("expected the hook script's variables as its output, got %v", ctx["before_hooks"])
+	}
+
+	failing := types.RoleChain{Steps: []types.ChainRole{{Role: "coder", Input: map[string]interface{}{"task": "x"}, OutputScript: "fail('empty draft')"}}}
+	if _, err := ExecuteChain(failing, nil, cfg, ""); err == nil || !strings.Contains(err.Error(), "output_script failed") {
+		t.Errorf("expected a failing output_script to stop the chain, got %v", err)
+	}
+}
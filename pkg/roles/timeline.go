@@ -0,0 +1,68 @@
+package roles
+
+import (
+	"fmt"
+	"time"
+
+	"ai-team/pkg/runs"
+)
+
+// timeline records model, tool and tool-attempt spans of a chain run.
+type timeline struct {
+	run       *runs.Record
+	step      int
+	iteration int
+	attempt   *runs.Span
+}
+
+// at attributes subsequent spans to the given step iteration.
+func (t *timeline) at(step, iteration int) {
+	t.step, t.iteration = step, iteration
+}
+
+// record adds a span of the given kind that started at start and ends now.
+func (t *timeline) record(kind, name string, start time.Time, err error) {
+	span := runs.Span{
+		Kind:       kind,
+		Name:       name,
+		Step:       t.step,
+		Iteration:  t.iteration,
+		StartedAt:  start,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		span.Error = err.Error()
+	}
+	t.run.AddSpan(span)
+}
+
+// metricsHook is installed as the tool executor's MetricsHook to time each attempt.
+func (t *timeline) metricsHook(event string, fields map[string]interface{}) {
+	switch event {
+	case "tool_call_attempt":
+		attempt, _ := fields["attempt"].(int)
+		t.attempt = &runs.Span{
+			Kind:      runs.SpanToolAttempt,
+			Name:      fmt.Sprintf("%v", fields["tool"]),
+			Step:      t.step,
+			Iteration: t.iteration,
+			Attempt:   attempt,
+			StartedAt: time.Now(),
+		}
+	case "tool_call_success", "tool_call_failure", "tool_call_timeout", "tool_call_cancelled":
+		if t.attempt == nil {
+			return
+		}
+		span := *t.attempt
+		t.attempt = nil
+		span.FinishedAt = time.Now()
+		if event == "tool_call_failure" {
+			span.Error = fmt.Sprintf("%v", fields["error"])
+		} else if event == "tool_call_timeout" {
+			span.Error = "timeout"
+		} else if event == "tool_call_cancelled" {
+			span.Error = "cancelled"
+		}
+		t.run.AddSpan(span)
+	}
+}
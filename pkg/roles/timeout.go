@@ -0,0 +1,36 @@
+package roles
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// withTimeout returns parent limited to d. A zero d returns parent itself, so
+// chains without timeouts call providers and tools without a deadline.
+func withTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return parent, func() {}
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// stepTimeoutError describes why a step's context ended: the chain deadline or
+// the step's own timeout. It returns nil while stepCtx is live.
+func stepTimeoutError(chainCtx, stepCtx context.Context, chain types.RoleChain, step types.ChainRole, stepIndex int, name string) error {
+	if stepCtx.Err() == nil {
+		return nil
+	}
+	if chainCtx.Err() != nil {
+		return chainTimeoutError(chain, fmt.Sprintf("during step %d (%s)", stepIndex+1, name))
+	}
+	return errors.New(errors.ErrCodeRole, fmt.Sprintf("step %d (%s) timed out after %s", stepIndex+1, name, step.Timeout), stepCtx.Err())
+}
+
+// chainTimeoutError reports that the chain's deadline passed; where says when.
+func chainTimeoutError(chain types.RoleChain, where string) error {
+	return errors.New(errors.ErrCodeRole, fmt.Sprintf("chain timed out after %s %s", chain.Timeout, where), context.DeadlineExceeded)
+}
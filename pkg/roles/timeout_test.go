@@ -0,0 +1,93 @@
+package roles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/types"
+)
+
+// hangingProvider mocks a Gemini call whose request hangs, until cancelled,
+// for prompts containing "slow".
+func hangingProvider(t *testing.T) *[]string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	var prompts []string
+	orig := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(client *http.Client, prompt, model, apiURL, apiKey string, tools []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		prompts = append(prompts, prompt)
+		if strings.Contains(prompt, "slow") {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				return "", err
+			}
+			resp.Body.Close()
+		}
+		return "done", nil
+	}
+	t.Cleanup(func() { ai.CallGeminiFunc = orig })
+	return &prompts
+}
+
+func timeoutConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "gemini-2.5-flash"}}
+	cfg.Gemini.Apiurl = "http://mock"
+	cfg.Roles = map[string]types.Role{
+		"worker": {Provider: "gemini", Model: "flash", Prompt: "{{.task}}"},
+	}
+	return cfg
+}
+
+func TestExecuteChain_StepTimeoutAppliesOnError(t *testing.T) {
+	prompts := hangingProvider(t)
+	steps := []types.ChainRole{
+		{Name: "hang", Role: "worker", Input: map[string]interface{}{"task": "slow"}, Timeout: 50 * time.Millisecond},
+		{Name: "next", Role: "worker", Input: map[string]interface{}{"task": "fast"}},
+	}
+
+	start := time.Now()
+	if _, err := ExecuteChain(types.RoleChain{Steps: steps}, nil, timeoutConfig(), ""); err != nil {
+		t.Fatalf("expected the chain to move past the timed-out step, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the hung call to be abandoned, chain took %s", elapsed)
+	}
+	if len(*prompts) != 2 || (*prompts)[1] != "fast" {
+		t.Errorf("expected the next step to run, got prompts %q", *prompts)
+	}
+
+	steps[0].OnError = types.OnErrorFail
+	_, err := ExecuteChain(types.RoleChain{Steps: steps}, nil, timeoutConfig(), "")
+	if err == nil || !strings.Contains(err.Error(), "step 1 (hang) timed out after 50ms") {
+		t.Fatalf("expected a step timeout error, got %v", err)
+	}
+}
+
+func TestExecuteChain_ChainDeadline(t *testing.T) {
+	prompts := hangingProvider(t)
+	chain := types.RoleChain{
+		Timeout: 50 * time.Millisecond,
+		Steps: []types.ChainRole{
+			{Name: "hang", Role: "worker", Input: map[string]interface{}{"task": "slow"}, OnError: types.OnErrorSkip},
+			{Name: "next", Role: "worker", Input: map[string]interface{}{"task": "fast"}},
+		},
+	}
+	_, err := ExecuteChain(chain, nil, timeoutConfig(), "")
+	if err == nil || !strings.Contains(err.Error(), "chain timed out after 50ms during step 1 (hang)") {
+		t.Fatalf("expected the chain deadline to fail the run, got %v", err)
+	}
+	if len(*prompts) != 1 {
+		t.Errorf("expected no step to run after the deadline, got prompts %q", *prompts)
+	}
+}
@@ -0,0 +1,246 @@
+package runs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxBugReportFile is how much of a file a bug report keeps; longer files
+// such as a log shared by many runs keep their end.
+const maxBugReportFile = 1 << 20
+
+// BugReport is what WriteBugReport packs for a run.
+type BugReport struct {
+	Record      *Record
+	Config      []byte            // Config file as written
+	Files       map[string]string // Logs and transcripts to add: archive name to file path
+	Environment map[string]string // Versions and OS details
+	// Secrets are values known to be secret, such as the API keys in the
+	// environment, removed wherever they appear.
+	Secrets []string
+}
+
+// WriteBugReport writes report to w as a zip archive holding run.json,
+// config.yaml, environment.json and the files, with secrets replaced by
+// Redacted: values of secret-looking keys, common API key formats and
+// report.Secrets. Files that cannot be read are listed in environment.json
+// instead. It returns the names of the archive entries.
+func WriteBugReport(w io.Writer, report BugReport) ([]string, error) {
+	r := newRedactor(report.Secrets)
+	zw := zip.NewWriter(w)
+	var names []string
+	add := func(name string, data []byte) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err == nil {
+			_, err = f.Write(data)
+		}
+		if err != nil {
+			return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to add %s to the bug report", name), err)
+		}
+		names = append(names, name)
+		return nil
+	}
+
+	run, err := r.json(report.Record)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to encode run %s", report.Record.ID), err)
+	}
+	if err := add("run.json", run); err != nil {
+		return nil, err
+	}
+	if report.Config != nil {
+		if err := add("config.yaml", r.config(report.Config)); err != nil {
+			return nil, err
+		}
+	}
+
+	env := map[string]string{}
+	for k, v := range report.Environment {
+		env[k] = v
+	}
+	files := make([]string, 0, len(report.Files))
+	for name := range report.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		data, err := readTail(report.Files[name])
+		if err != nil {
+			env["missing."+name] = err.Error()
+			continue
+		}
+		if strings.HasSuffix(name, ".json") {
+			var v interface{}
+			if json.Unmarshal(data, &v) == nil {
+				data, _ = r.json(v)
+			}
+		}
+		if err := add(name, []byte(r.text(string(data)))); err != nil {
+			return nil, err
+		}
+	}
+
+	envData, _ := json.MarshalIndent(env, "", "  ")
+	if err := add("environment.json", []byte(r.text(string(envData)))); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, "failed to write the bug report", err)
+	}
+	return names, nil
+}
+
+// readTail reads the file at path, keeping its last maxBugReportFile bytes.
+func readTail(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxBugReportFile {
+		if _, err := f.Seek(-maxBugReportFile, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		return append([]byte(fmt.Sprintf("[... %d earlier bytes left out ...]\n", info.Size()-maxBugReportFile)), data...), err
+	}
+	return io.ReadAll(f)
+}
+
+// StepLogFiles returns the step log files of run id under dir, the
+// logging.dir of the config, keyed by their name in a bug report.
+func StepLogFiles(dir, id string) map[string]string {
+	files := map[string]string{}
+	entries, _ := os.ReadDir(filepath.Join(dir, id))
+	for _, e := range entries {
+		if !e.IsDir() {
+			files["logs/"+e.Name()] = filepath.Join(dir, id, e.Name())
+		}
+	}
+	return files
+}
+
+// redactor removes secrets from the parts of a bug report.
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(secrets []string) *redactor {
+	r := &redactor{}
+	for _, s := range secrets {
+		// Short values would redact ordinary words.
+		if len(s) >= 8 {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longer first, so a secret containing another is removed whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+// text redacts the secrets and API keys in s.
+func (r *redactor) text(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return secretValuePattern.ReplaceAllString(s, Redacted)
+}
+
+// json encodes v as indented JSON with the secrets, API keys and string
+// values of secret-looking keys redacted. Numbers such as token counts stay.
+func (r *redactor) json(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal([]byte(r.text(string(data))), &generic); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactStrings(generic), "", "  ")
+}
+
+// redactStrings replaces the string values of secret-looking keys in v.
+func redactStrings(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if s, ok := item.(string); ok && s != "" && secretKeyPattern.MatchString(k) {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = redactStrings(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactStrings(item)
+		}
+	}
+	return v
+}
+
+// config strips the secrets from a YAML config file, keeping its layout and
+// comments. A file that does not parse is redacted as text.
+func (r *redactor) config(data []byte) []byte {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []byte(r.text(string(data)))
+	}
+	redactNode(&doc)
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return []byte(r.text(string(data)))
+	}
+	return []byte(r.text(out.String()))
+}
+
+// redactNode replaces the string values of secret-looking keys under n.
+func redactNode(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if secretKeyPattern.MatchString(key.Value) && value.Kind == yaml.ScalarNode && value.Tag == "!!str" && value.Value != "" {
+				value.Value, value.Tag, value.Style = Redacted, "!!str", 0
+				continue
+			}
+			redactNode(value)
+		}
+		return
+	}
+	for _, c := range n.Content {
+		redactNode(c)
+	}
+}
+
+// EnvSecrets returns the values of the variables in environ, given as
+// key=value, whose names look secret, such as OPENAI_API_KEY.
+func EnvSecrets(environ []string) []string {
+	var secrets []string
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && v != "" && secretKeyPattern.MatchString(k) {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
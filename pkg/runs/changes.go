@@ -0,0 +1,157 @@
+package runs
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// FileChange is the diffstat of one file a run changed. Lines are counted
+// over every write to the file, so a file rewritten twice counts both.
+type FileChange struct {
+	Path    string `json:"path"`
+	Writes  int    `json:"writes"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// ChangeSummary describes the files a run changed: their diffstat and, when
+// a summarizer role is configured, its account of the changes.
+type ChangeSummary struct {
+	Files      []FileChange `json:"files"`
+	Summary    string       `json:"summary,omitempty"`
+	Summarizer string       `json:"summarizer,omitempty"` // Role that wrote Summary
+	Error      string       `json:"error,omitempty"`      // Why the summarizer gave no summary
+}
+
+// DiffStat returns the files changed by successful write_file and
+// apply_patch calls of the run, in the order they were first changed.
+func (r *Record) DiffStat() []FileChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var files []FileChange
+	index := map[string]int{}
+	for _, s := range r.Steps {
+		path := editedPath(s)
+		if path == "" {
+			continue
+		}
+		i, ok := index[path]
+		if !ok {
+			i = len(files)
+			index[path] = i
+			files = append(files, FileChange{Path: path})
+		}
+		added, removed := countDiffLines(s.Diff)
+		files[i].Writes++
+		files[i].Added += added
+		files[i].Removed += removed
+	}
+	return files
+}
+
+// Diffs returns the diffs of the run's file changes, in order.
+func (r *Record) Diffs() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	for _, s := range r.Steps {
+		if editedPath(s) != "" && s.Diff != "" {
+			b.WriteString(strings.TrimRight(s.Diff, "\n"))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// SetChanges stores the change summary of the run.
+func (r *Record) SetChanges(c *ChangeSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Changes = c
+}
+
+// countDiffLines counts the added and removed lines of a unified diff.
+func countDiffLines(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// FormatChanges renders a change summary as a diffstat table followed by the
+// summary.
+func FormatChanges(c *ChangeSummary) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	added, removed := 0, 0
+	for _, f := range c.Files {
+		fmt.Fprintf(w, "%s\t+%d\t-%d\t%s\n", f.Path, f.Added, f.Removed, plural(f.Writes, "write"))
+		added += f.Added
+		removed += f.Removed
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "%s changed, +%d -%d\n", plural(len(c.Files), "file"), added, removed)
+	switch {
+	case c.Summary != "":
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(c.Summary))
+	case c.Error != "":
+		fmt.Fprintf(&b, "\nNo summary: %s\n", c.Error)
+	}
+	return b.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Show renders an overview of a run for the terminal: its status, timing,
+// cost and the changes it made.
+func Show(r *Record) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Run:      %s\n", r.ID)
+	fmt.Fprintf(&b, "Chain:    %s\n", r.Chain)
+	fmt.Fprintf(&b, "Status:   %s\n", r.Status)
+	fmt.Fprintf(&b, "Started:  %s\n", r.StartedAt.Format(time.RFC3339))
+	if !r.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, "Duration: %s\n", r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond))
+	}
+	fmt.Fprintf(&b, "Steps:    %d\n", len(r.Steps))
+	if r.Cost != nil {
+		fmt.Fprintf(&b, "Cost:     %s\n", r.Cost)
+	}
+	if len(r.Labels) > 0 {
+		fmt.Fprintf(&b, "Labels:   %s\n", FormatLabels(r.Labels))
+	}
+	if r.BranchedFrom != "" {
+		fmt.Fprintf(&b, "Branched: %s\n", r.BranchedFrom)
+	}
+	if len(r.Checkpoints) > 0 {
+		fmt.Fprintf(&b, "Checkpoints: %s\n", strings.Join(r.Checkpoints, ", "))
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error:    %s\n", r.Error)
+	}
+	b.WriteString("\nChanges:\n")
+	changes := r.Changes
+	if changes == nil {
+		// Records from before change summaries, or of runs still going.
+		changes = &ChangeSummary{Files: r.DiffStat()}
+	}
+	if len(changes.Files) == 0 {
+		b.WriteString("No files changed.\n")
+		return b.String()
+	}
+	b.WriteString(FormatChanges(changes))
+	return b.String()
+}
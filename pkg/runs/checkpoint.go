@@ -0,0 +1,147 @@
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+)
+
+// Checkpoint is the state of a chain run after its last completed step, from
+// which run-chain --resume continues, or after a step with a named
+// checkpoint, from which runs branch starts. Context values are restored
+// from JSON, so tool results that were Go structs come back as maps.
+type Checkpoint struct {
+	RunID            string                 `json:"run_id"`
+	Name             string                 `json:"name,omitempty"` // Set for named checkpoints
+	Chain            string                 `json:"chain"`
+	Input            map[string]interface{} `json:"input"`
+	Steps            []string               `json:"steps"`     // Names of the completed steps, in order
+	NextStep         int                    `json:"next_step"` // Index of the step to run next
+	Context          map[string]interface{} `json:"context"`
+	LastToolResponse interface{}            `json:"last_tool_response,omitempty"`
+	SavedAt          time.Time              `json:"saved_at"`
+}
+
+// CheckpointPath returns the state file of the run id in the store.
+func (s *Store) CheckpointPath(id string) string {
+	return filepath.Join(s.Dir, "checkpoints", id+".json")
+}
+
+// checkpointNamePattern is what names of named checkpoints may consist of,
+// as they become file names.
+var checkpointNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidCheckpointName reports whether name can name a checkpoint.
+func ValidCheckpointName(name string) bool {
+	return checkpointNamePattern.MatchString(name) && strings.Trim(name, ".") != ""
+}
+
+// NamedCheckpointPath returns the file of the checkpoint called name of the
+// run id in the store. Unlike the checkpoint at CheckpointPath, named
+// checkpoints are kept when the run completes.
+func (s *Store) NamedCheckpointPath(id, name string) string {
+	return filepath.Join(s.Dir, "checkpoints", id, name+".json")
+}
+
+// LoadNamedCheckpoint reads the checkpoint called name of the run id, which
+// may be a unique prefix.
+func (s *Store) LoadNamedCheckpoint(id, name string) (*Checkpoint, error) {
+	record, err := s.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	path := s.NamedCheckpointPath(record.ID, name)
+	if _, err := os.Stat(path); err != nil {
+		msg := fmt.Sprintf("run %s has no checkpoint '%s'", record.ID, name)
+		if len(record.Checkpoints) > 0 {
+			msg += fmt.Sprintf(" (it has %s)", strings.Join(record.Checkpoints, ", "))
+		}
+		return nil, errors.New(errors.ErrCodeConfig, msg, nil)
+	}
+	return LoadCheckpoint(path)
+}
+
+// ParseCheckpointRef splits a reference to a named checkpoint, given as
+// <run-id>@<name>.
+func ParseCheckpointRef(ref string) (id, name string, err error) {
+	id, name, ok := strings.Cut(ref, "@")
+	if !ok || id == "" || !ValidCheckpointName(name) {
+		return "", "", errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid checkpoint '%s'. Expected <run-id>@<checkpoint>", ref), nil)
+	}
+	return id, name, nil
+}
+
+// Branch returns a copy of cp with the values of input set in the run's
+// input and its context, for a run that starts from cp with changed input.
+func (cp *Checkpoint) Branch(input map[string]interface{}) *Checkpoint {
+	branch := *cp
+	branch.Input = make(map[string]interface{}, len(cp.Input)+len(input))
+	branch.Context = make(map[string]interface{}, len(cp.Context)+len(input))
+	for k, v := range cp.Input {
+		branch.Input[k] = v
+	}
+	for k, v := range cp.Context {
+		branch.Context[k] = v
+	}
+	for k, v := range input {
+		branch.Input[k] = v
+		branch.Context[k] = v
+	}
+	return &branch
+}
+
+// AddCheckpoint records that the run saved the named checkpoint.
+func (r *Record) AddCheckpoint(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.Checkpoints {
+		if n == name {
+			return
+		}
+	}
+	r.Checkpoints = append(r.Checkpoints, name)
+}
+
+// SaveCheckpoint writes cp to filePath, replacing the previous checkpoint
+// only once the new one is complete. The file is private to the user, as the
+// context may hold secrets.
+func SaveCheckpoint(filePath string, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to encode checkpoint of run %s", cp.RunID), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to create checkpoint directory %s", filepath.Dir(filePath)), err)
+	}
+	temp := filePath + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write checkpoint %s", filePath), err)
+	}
+	if err := os.Rename(temp, filePath); err != nil {
+		os.Remove(temp)
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write checkpoint %s", filePath), err)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint.
+func LoadCheckpoint(filePath string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to read checkpoint %s", filePath), err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to parse checkpoint %s", filePath), err)
+	}
+	if cp.Context == nil {
+		cp.Context = map[string]interface{}{}
+	}
+	return &cp, nil
+}
@@ -0,0 +1,98 @@
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"ai-team/pkg/errors"
+)
+
+// Redacted replaces secret values in context snapshots.
+const Redacted = "[REDACTED]"
+
+// secretKeyPattern matches context keys whose values are treated as secrets.
+var secretKeyPattern = regexp.MustCompile(`(?i)(api[_-]?key|token|secret|password|passwd|credential|authorization)`)
+
+// secretValuePattern matches common API key formats appearing in any value.
+var secretValuePattern = regexp.MustCompile(`\b(sk-[A-Za-z0-9_-]{16,}|AIza[0-9A-Za-z_-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xox[baprs]-[A-Za-z0-9-]{10,})\b`)
+
+// SnapshotContext returns a deep copy of a chain context with secrets
+// redacted, suitable for storing in a StepRecord. Values that cannot be
+// encoded as JSON are replaced by their %v representation.
+func SnapshotContext(context map[string]interface{}) map[string]interface{} {
+	if context == nil {
+		return nil
+	}
+	var copied map[string]interface{}
+	data, err := json.Marshal(context)
+	if err == nil {
+		err = json.Unmarshal(data, &copied)
+	}
+	if err != nil {
+		copied = make(map[string]interface{}, len(context))
+		for k, v := range context {
+			copied[k] = fmt.Sprintf("%v", v)
+		}
+	}
+	return redactValue(copied).(map[string]interface{})
+}
+
+// FormatContext pretty-prints a context snapshot.
+func FormatContext(context map[string]interface{}) string {
+	data, err := json.MarshalIndent(context, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v\n", context)
+	}
+	return string(data) + "\n"
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if secretKeyPattern.MatchString(k) {
+				if item != nil && item != "" {
+					val[k] = Redacted
+				}
+				continue
+			}
+			val[k] = redactValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+		return val
+	case string:
+		return secretValuePattern.ReplaceAllString(val, Redacted)
+	default:
+		return v
+	}
+}
+
+// ContextAt returns the context snapshot taken after the given step (0-based
+// index), at its last iteration unless iteration is non-negative.
+func (r *Record) ContextAt(step, iteration int) (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found *StepRecord
+	for i := range r.Steps {
+		s := &r.Steps[i]
+		if s.Index != step || (iteration >= 0 && s.Iteration != iteration) {
+			continue
+		}
+		found = s
+	}
+	if found == nil {
+		if iteration >= 0 {
+			return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run %s has no step %d iteration %d", r.ID, step+1, iteration+1), nil)
+		}
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run %s has no step %d", r.ID, step+1), nil)
+	}
+	if found.Context == nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run %s has no context snapshot for step %d", r.ID, step+1), nil)
+	}
+	return found.Context, nil
+}
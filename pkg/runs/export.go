@@ -0,0 +1,189 @@
+package runs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+)
+
+// Export renders a run record in the given format ("md" or "html").
+func Export(r *Record, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "md", "markdown", "":
+		return RenderMarkdown(r), nil
+	case "html":
+		return RenderHTML(r)
+	default:
+		return "", errors.New(errors.ErrCodeUnknown, fmt.Sprintf("unsupported export format '%s' (expected md or html)", format), nil)
+	}
+}
+
+// RenderMarkdown renders a human-readable Markdown report of a run.
+func RenderMarkdown(r *Record) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Run %s\n\n", r.ID)
+	fmt.Fprintf(&b, "- **Chain:** %s\n", r.Chain)
+	fmt.Fprintf(&b, "- **Status:** %s\n", r.Status)
+	fmt.Fprintf(&b, "- **Started:** %s\n", r.StartedAt.Format(time.RFC3339))
+	if r.ToolsHash != "" {
+		fmt.Fprintf(&b, "- **Tools:** %s\n", r.ToolsHash)
+	}
+	if !r.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, "- **Duration:** %s\n", r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond))
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- **Error:** %s\n", r.Error)
+	}
+	if len(r.Input) > 0 {
+		b.WriteString("\n## Input\n\n")
+		b.WriteString(fence("json", prettyJSON(r.Input)))
+	}
+	if r.Timing != nil {
+		b.WriteString("\n## Timing\n\n")
+		b.WriteString(fence("", FormatTiming(*r.Timing)))
+	}
+	if r.Changes != nil && len(r.Changes.Files) > 0 {
+		b.WriteString("\n## Changes\n\n")
+		b.WriteString(fence("", FormatChanges(r.Changes)))
+	}
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "\n## Step %d: %s", s.Index+1, stepTitle(s))
+		if s.Iteration > 0 {
+			fmt.Fprintf(&b, " (iteration %d)", s.Iteration+1)
+		}
+		b.WriteString("\n\n")
+		if s.Prompt != "" {
+			b.WriteString("### Prompt\n\n")
+			b.WriteString(fence("", s.Prompt))
+		}
+		if s.Response != "" {
+			b.WriteString("### Response\n\n")
+			b.WriteString(fence("", s.Response))
+		}
+		if s.ToolCall != nil {
+			fmt.Fprintf(&b, "### Tool call: `%s`\n\n", s.ToolCall.Name)
+			if cmd, ok := s.ToolCall.Arguments["command"].(string); ok {
+				b.WriteString(fence("bash", cmd))
+			} else {
+				b.WriteString(fence("json", prettyJSON(s.ToolCall.Arguments)))
+			}
+		}
+		if len(s.Commands) > 0 {
+			b.WriteString("### Commands\n\n")
+			for _, c := range s.Commands {
+				fmt.Fprintf(&b, "- `%s`: %s\n", c.Command, FormatCommandUsage(c))
+			}
+			b.WriteString("\n")
+		}
+		if s.Diff != "" {
+			b.WriteString("### Diff\n\n")
+			b.WriteString(fence("diff", s.Diff))
+		}
+		if s.ToolError != "" {
+			fmt.Fprintf(&b, "### Tool error\n\n%s\n\n", s.ToolError)
+		} else if s.ToolResult != nil {
+			b.WriteString("### Tool output\n\n")
+			b.WriteString(fence("", resultText(s.ToolResult)))
+		}
+		if s.Error != "" {
+			fmt.Fprintf(&b, "**Error:** %s\n\n", s.Error)
+		}
+	}
+	return b.String()
+}
+
+var htmlReport = template.Must(template.New("run").Funcs(template.FuncMap{
+	"title":  stepTitle,
+	"json":   prettyJSON,
+	"result": resultText,
+	"usage":  FormatCommandUsage,
+	"inc":    func(i int) int { return i + 1 },
+	"time":   func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run {{.ID}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; white-space: pre-wrap; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Run {{.ID}}</h1>
+<ul>
+<li><b>Chain:</b> {{.Chain}}</li>
+<li><b>Status:</b> {{.Status}}</li>
+<li><b>Started:</b> {{time .StartedAt}}</li>
+{{if .Error}}<li class="error"><b>Error:</b> {{.Error}}</li>{{end}}
+</ul>
+{{if .Input}}<h2>Input</h2>
+<pre>{{json .Input}}</pre>{{end}}
+{{range .Steps}}
+<h2>Step {{inc .Index}}: {{title .}}{{if .Iteration}} (iteration {{inc .Iteration}}){{end}}</h2>
+{{if .Prompt}}<h3>Prompt</h3>
+<pre>{{.Prompt}}</pre>{{end}}
+{{if .Response}}<h3>Response</h3>
+<pre>{{.Response}}</pre>{{end}}
+{{if .ToolCall}}<h3>Tool call: <code>{{.ToolCall.Name}}</code></h3>
+<pre>{{json .ToolCall.Arguments}}</pre>{{end}}
+{{if .Commands}}<h3>Commands</h3>
+<ul>{{range .Commands}}
+<li><code>{{.Command}}</code>: {{usage .}}</li>{{end}}
+</ul>{{end}}
+{{if .Diff}}<h3>Diff</h3>
+<pre>{{.Diff}}</pre>{{end}}
+{{if .ToolError}}<h3>Tool error</h3>
+<p class="error">{{.ToolError}}</p>{{else if .ToolResult}}<h3>Tool output</h3>
+<pre>{{result .ToolResult}}</pre>{{end}}
+{{if .Error}}<p class="error"><b>Error:</b> {{.Error}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders a self-contained HTML report of a run.
+func RenderHTML(r *Record) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, r); err != nil {
+		return "", errors.New(errors.ErrCodeUnknown, "failed to render html report", err)
+	}
+	return buf.String(), nil
+}
+
+func stepTitle(s StepRecord) string {
+	if s.Name != "" && s.Name != s.Role {
+		return fmt.Sprintf("%s (%s)", s.Name, s.Role)
+	}
+	return s.Role
+}
+
+func fence(lang, content string) string {
+	content = strings.TrimRight(content, "\n")
+	marker := "```"
+	for strings.Contains(content, marker) {
+		marker += "`"
+	}
+	return marker + lang + "\n" + content + "\n" + marker + "\n\n"
+}
+
+func prettyJSON(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+func resultText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return prettyJSON(v)
+}
@@ -0,0 +1,53 @@
+package runs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ai-team/pkg/errors"
+)
+
+// ParseLabels parses key=value pairs, e.g. from repeated --label flags. Later
+// pairs override earlier ones with the same key. It returns nil for no pairs.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid label %q. Expected key=value", pair), nil)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// FormatLabels returns labels as key=value pairs ordered by key.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// HasLabels reports whether the record carries every label of filter. An
+// empty value in filter matches any value of that key.
+func (r *Record) HasLabels(filter map[string]string) bool {
+	for k, want := range filter {
+		got, ok := r.Labels[k]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package runs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prompt diff status values.
+const (
+	PromptSame    = "same"
+	PromptChanged = "changed"
+	PromptOnlyA   = "only_a" // The step iteration only ran in the first run
+	PromptOnlyB   = "only_b" // The step iteration only ran in the second run
+)
+
+// promptDiffContext is the number of unchanged lines shown around changes.
+const promptDiffContext = 3
+
+// PromptDiff compares the rendered prompt of one step iteration in two runs.
+type PromptDiff struct {
+	Index     int    `json:"index"`
+	Iteration int    `json:"iteration"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Diff      string `json:"diff,omitempty"`
+}
+
+// DiffPrompts pairs the step iterations of two runs by step index and
+// iteration and diffs their rendered prompts, in execution order of a.
+func DiffPrompts(a, b *Record) []PromptDiff {
+	a.mu.Lock()
+	stepsA := append([]StepRecord(nil), a.Steps...)
+	a.mu.Unlock()
+	b.mu.Lock()
+	stepsB := append([]StepRecord(nil), b.Steps...)
+	b.mu.Unlock()
+
+	type key struct{ index, iteration int }
+	byKey := make(map[key]StepRecord, len(stepsB))
+	for _, s := range stepsB {
+		byKey[key{s.Index, s.Iteration}] = s
+	}
+	var diffs []PromptDiff
+	seen := map[key]bool{}
+	for _, s := range stepsA {
+		k := key{s.Index, s.Iteration}
+		seen[k] = true
+		d := PromptDiff{Index: s.Index, Iteration: s.Iteration, Name: stepTitle(s)}
+		other, ok := byKey[k]
+		switch {
+		case !ok:
+			d.Status = PromptOnlyA
+		case other.Prompt == s.Prompt:
+			d.Status = PromptSame
+		default:
+			d.Status = PromptChanged
+			d.Diff = DiffLines(s.Prompt, other.Prompt)
+		}
+		diffs = append(diffs, d)
+	}
+	for _, s := range stepsB {
+		if !seen[key{s.Index, s.Iteration}] {
+			diffs = append(diffs, PromptDiff{Index: s.Index, Iteration: s.Iteration, Name: stepTitle(s), Status: PromptOnlyB})
+		}
+	}
+	return diffs
+}
+
+// FormatPromptDiffs renders prompt diffs as text, one section per step iteration.
+func FormatPromptDiffs(a, b *Record, diffs []PromptDiff) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s (%s)\n+++ %s (%s)\n", a.ID, a.Chain, b.ID, b.Chain)
+	changed := 0
+	for _, d := range diffs {
+		title := fmt.Sprintf("Step %d: %s", d.Index+1, d.Name)
+		if d.Iteration > 0 {
+			title += fmt.Sprintf(" (iteration %d)", d.Iteration+1)
+		}
+		switch d.Status {
+		case PromptSame:
+			fmt.Fprintf(&out, "\n%s: unchanged\n", title)
+		case PromptOnlyA:
+			changed++
+			fmt.Fprintf(&out, "\n%s: only in %s\n", title, a.ID)
+		case PromptOnlyB:
+			changed++
+			fmt.Fprintf(&out, "\n%s: only in %s\n", title, b.ID)
+		default:
+			changed++
+			fmt.Fprintf(&out, "\n%s: changed\n%s", title, d.Diff)
+		}
+	}
+	fmt.Fprintf(&out, "\n%d of %d step iteration(s) differ\n", changed, len(diffs))
+	return out.String()
+}
+
+// DiffLines returns a line diff of a and b in unified format, showing changed
+// lines with a few lines of context. It returns "" when a and b are equal.
+func DiffLines(a, b string) string {
+	if a == b {
+		return ""
+	}
+	linesA := strings.Split(a, "\n")
+	linesB := strings.Split(b, "\n")
+	ops := diffOps(linesA, linesB)
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	var out strings.Builder
+	for n := 0; n < len(changes); {
+		// Changes separated by more than twice the context share no lines.
+		last := n
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*promptDiffContext {
+			last++
+		}
+		from := changes[n] - promptDiffContext
+		if from < 0 {
+			from = 0
+		}
+		to := changes[last] + promptDiffContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[from].lineA+1, countA, ops[from].lineB+1, countB)
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		n = last + 1
+	}
+	return out.String()
+}
+
+type diffOp struct {
+	kind         byte // ' ', '-' or '+'
+	text         string
+	lineA, lineB int // 0-based positions in a and b where the op applies
+}
+
+// diffOps computes a line edit script from a to b using a longest common
+// subsequence table.
+func diffOps(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,292 @@
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// DefaultReportTop is how many failing steps and edited files a report lists.
+const DefaultReportTop = 5
+
+// Report summarizes the runs of a period.
+type Report struct {
+	Since        time.Time          `json:"since"`
+	Until        time.Time          `json:"until"`
+	Labels       map[string]string  `json:"labels,omitempty"` // Filter the runs were selected by
+	Runs         int                `json:"runs"`
+	Succeeded    int                `json:"succeeded"`
+	Failed       int                `json:"failed"`
+	SuccessRate  float64            `json:"success_rate"` // Of finished runs, 0 to 1
+	Cost         *types.CostSummary `json:"cost,omitempty"`
+	Chains       []ChainReport      `json:"chains"`
+	FailingSteps []StepFailures     `json:"failing_steps"`
+	EditedFiles  []FileEdits        `json:"edited_files"`
+}
+
+// ChainReport is the share of one chain in a report.
+type ChainReport struct {
+	Chain       string             `json:"chain"`
+	Runs        int                `json:"runs"`
+	Succeeded   int                `json:"succeeded"`
+	Failed      int                `json:"failed"`
+	SuccessRate float64            `json:"success_rate"`
+	Cost        *types.CostSummary `json:"cost,omitempty"`
+}
+
+// StepFailures counts the runs in which a chain step had a model or tool error.
+type StepFailures struct {
+	Chain    string `json:"chain"`
+	Step     string `json:"step"`
+	Failures int    `json:"failures"`
+	LastErr  string `json:"last_error"`
+}
+
+// FileEdits counts the successful write_file and apply_patch calls on a file.
+type FileEdits struct {
+	Path  string `json:"path"`
+	Edits int    `json:"edits"`
+	Runs  int    `json:"runs"`
+}
+
+// ParseSince returns the start of a report period: now minus a duration such
+// as "7d", "2w" or "36h", or a date such as "2024-05-01".
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid period %q (expected e.g. 7d, 2w, 36h or 2024-05-01)", s), nil)
+}
+
+// Summarize builds the report of the records started at or after since that
+// carry the labels of filter, listing at most top failing steps and files.
+func Summarize(records []*Record, since, until time.Time, filter map[string]string, top int) Report {
+	report := Report{Since: since, Until: until, Labels: filter, Chains: []ChainReport{}, FailingSteps: []StepFailures{}, EditedFiles: []FileEdits{}}
+	chains := map[string]*ChainReport{}
+	failures := map[[2]string]*StepFailures{}
+	files := map[string]*FileEdits{}
+	for _, r := range records {
+		if r.StartedAt.Before(since) || r.StartedAt.After(until) || !r.HasLabels(filter) {
+			continue
+		}
+		chain := chains[r.Chain]
+		if chain == nil {
+			chain = &ChainReport{Chain: r.Chain}
+			chains[r.Chain] = chain
+		}
+		report.Runs++
+		chain.Runs++
+		switch r.Status {
+		case StatusSuccess:
+			report.Succeeded++
+			chain.Succeeded++
+		case StatusFailed:
+			report.Failed++
+			chain.Failed++
+		}
+		if r.Cost != nil {
+			report.Cost = addCost(report.Cost, *r.Cost)
+			chain.Cost = addCost(chain.Cost, *r.Cost)
+		}
+
+		failed := map[string]bool{}
+		edited := map[string]bool{}
+		for _, s := range r.Steps {
+			if msg := stepError(s); msg != "" {
+				key := [2]string{r.Chain, stepTitle(s)}
+				f := failures[key]
+				if f == nil {
+					f = &StepFailures{Chain: r.Chain, Step: stepTitle(s)}
+					failures[key] = f
+				}
+				if !failed[key[1]] {
+					failed[key[1]] = true
+					f.Failures++
+				}
+				f.LastErr = msg
+			}
+			if path := editedPath(s); path != "" {
+				e := files[path]
+				if e == nil {
+					e = &FileEdits{Path: path}
+					files[path] = e
+				}
+				e.Edits++
+				if !edited[path] {
+					edited[path] = true
+					e.Runs++
+				}
+			}
+		}
+	}
+	report.SuccessRate = successRate(report.Succeeded, report.Failed)
+
+	for _, c := range chains {
+		c.SuccessRate = successRate(c.Succeeded, c.Failed)
+		report.Chains = append(report.Chains, *c)
+	}
+	sort.Slice(report.Chains, func(i, j int) bool {
+		a, b := report.Chains[i], report.Chains[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return a.Chain < b.Chain
+	})
+	for _, f := range failures {
+		report.FailingSteps = append(report.FailingSteps, *f)
+	}
+	sort.Slice(report.FailingSteps, func(i, j int) bool {
+		a, b := report.FailingSteps[i], report.FailingSteps[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Chain+"/"+a.Step < b.Chain+"/"+b.Step
+	})
+	for _, e := range files {
+		report.EditedFiles = append(report.EditedFiles, *e)
+	}
+	sort.Slice(report.EditedFiles, func(i, j int) bool {
+		a, b := report.EditedFiles[i], report.EditedFiles[j]
+		if a.Edits != b.Edits {
+			return a.Edits > b.Edits
+		}
+		return a.Path < b.Path
+	})
+	if top > 0 {
+		if len(report.FailingSteps) > top {
+			report.FailingSteps = report.FailingSteps[:top]
+		}
+		if len(report.EditedFiles) > top {
+			report.EditedFiles = report.EditedFiles[:top]
+		}
+	}
+	return report
+}
+
+func addCost(total *types.CostSummary, cost types.CostSummary) *types.CostSummary {
+	if total == nil {
+		total = &types.CostSummary{Currency: cost.Currency, Complete: true}
+	}
+	total.Add(cost)
+	return total
+}
+
+func successRate(succeeded, failed int) float64 {
+	if succeeded+failed == 0 {
+		return 0
+	}
+	return float64(succeeded) / float64(succeeded+failed)
+}
+
+// stepError returns the model or tool error of a step, if any.
+func stepError(s StepRecord) string {
+	if s.Error != "" {
+		return s.Error
+	}
+	return s.ToolError
+}
+
+// ExportReport renders a report in the given format ("md" or "json").
+func ExportReport(report Report, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "md", "markdown", "":
+		return RenderReportMarkdown(report), nil
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", errors.New(errors.ErrCodeUnknown, "failed to encode the report", err)
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", errors.New(errors.ErrCodeUnknown, fmt.Sprintf("unsupported report format '%s' (expected md or json)", format), nil)
+	}
+}
+
+// RenderReportMarkdown renders a report as Markdown for a team update.
+func RenderReportMarkdown(report Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Chain runs %s to %s\n\n", report.Since.Format("2006-01-02"), report.Until.Format("2006-01-02"))
+	if len(report.Labels) > 0 {
+		fmt.Fprintf(&b, "- **Labels:** %s\n", FormatLabels(report.Labels))
+	}
+	fmt.Fprintf(&b, "- **Runs:** %d (%d succeeded, %d failed)\n", report.Runs, report.Succeeded, report.Failed)
+	fmt.Fprintf(&b, "- **Success rate:** %s\n", formatRate(report.SuccessRate, report.Succeeded+report.Failed))
+	if report.Cost != nil {
+		fmt.Fprintf(&b, "- **Cost:** %s\n", report.Cost)
+	}
+	if report.Runs == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\n## Runs per chain\n\n")
+	b.WriteString("| Chain | Runs | Succeeded | Failed | Success rate | Cost |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|\n")
+	for _, c := range report.Chains {
+		cost := "-"
+		if c.Cost != nil {
+			cost = fmt.Sprintf("%.4f %s", c.Cost.Cost, c.Cost.Currency)
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %s | %s |\n", tableCell(c.Chain), c.Runs, c.Succeeded, c.Failed, formatRate(c.SuccessRate, c.Succeeded+c.Failed), cost)
+	}
+
+	if len(report.FailingSteps) > 0 {
+		b.WriteString("\n## Top failing steps\n\n")
+		b.WriteString("| Chain | Step | Failed runs | Last error |\n")
+		b.WriteString("|---|---|---:|---|\n")
+		for _, f := range report.FailingSteps {
+			fmt.Fprintf(&b, "| %s | %s | %d | %s |\n", tableCell(f.Chain), tableCell(f.Step), f.Failures, tableCell(truncateLine(f.LastErr, 120)))
+		}
+	}
+
+	if len(report.EditedFiles) > 0 {
+		b.WriteString("\n## Most edited files\n\n")
+		b.WriteString("| File | Edits | Runs |\n")
+		b.WriteString("|---|---:|---:|\n")
+		for _, e := range report.EditedFiles {
+			fmt.Fprintf(&b, "| `%s` | %d | %d |\n", e.Path, e.Edits, e.Runs)
+		}
+	}
+	return b.String()
+}
+
+func formatRate(rate float64, finished int) string {
+	if finished == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// tableCell escapes a value for a Markdown table cell.
+func tableCell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// truncateLine returns the first line of s, cut to n bytes.
+func truncateLine(s string, n int) string {
+	line := strings.SplitN(s, "\n", 2)[0]
+	if len(line) > n {
+		return line[:n] + "..."
+	}
+	return line
+}
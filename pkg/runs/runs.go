@@ -0,0 +1,344 @@
+package runs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// DefaultDir is where run records are stored when no runs_dir is configured.
+const DefaultDir = ".ai-team/runs"
+
+// Run status values.
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// StepRecord captures a single role invocation (one loop iteration) of a chain run.
+type StepRecord struct {
+	Index      int             `json:"index"`
+	Name       string          `json:"name"`
+	Role       string          `json:"role"`
+	Iteration  int             `json:"iteration"`
+	Prompt     string          `json:"prompt"`
+	Response   string          `json:"response"`
+	ToolCall   *types.ToolCall `json:"tool_call,omitempty"`
+	ToolResult interface{}     `json:"tool_result,omitempty"`
+	ToolError  string          `json:"tool_error,omitempty"`
+	Diff       string          `json:"diff,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	// Guardrail is the guardrail role's decision about the tool call.
+	Guardrail *types.GuardrailDecision `json:"guardrail,omitempty"`
+	// Commands is the resource use of the commands the tool call ran.
+	Commands []types.CommandUsage `json:"commands,omitempty"`
+	// Usage is the provider token use and cost of this iteration.
+	Usage *types.CostSummary `json:"usage,omitempty"`
+	// CacheKey identifies the model call of the step (see StepCacheKey);
+	// Cached marks an output reused from an earlier run instead of calling
+	// the model.
+	CacheKey string `json:"cache_key,omitempty"`
+	Cached   bool   `json:"cached,omitempty"`
+	// Context is the chain context after this iteration, with secrets redacted.
+	Context    map[string]interface{} `json:"context,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+}
+
+// Record is the persisted history of a single chain run.
+type Record struct {
+	ID           string                 `json:"id"`
+	Chain        string                 `json:"chain"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	Input        map[string]interface{} `json:"input"`
+	Labels       map[string]string      `json:"labels,omitempty"`        // Set with --label, for filtering run history
+	ToolsHash    string                 `json:"tools_hash,omitempty"`    // Hash of the tool definitions available to the run
+	Since        string                 `json:"since,omitempty"`         // Earlier run whose unchanged steps were reused (run-chain --since)
+	ResumedFrom  string                 `json:"resumed_from,omitempty"`  // Run continued from its checkpoint (run-chain --resume)
+	BranchedFrom string                 `json:"branched_from,omitempty"` // Run and named checkpoint this run started from, as id@name (runs branch)
+	Checkpoints  []string               `json:"checkpoints,omitempty"`   // Named checkpoints saved by the run, in order
+	DryRun       bool                   `json:"dry_run,omitempty"`       // Tools were simulated (run-chain --dry-run)
+	Steps        []StepRecord           `json:"steps"`
+	Timeline     []Span                 `json:"timeline,omitempty"`
+	Timing       *Timing                `json:"timing,omitempty"`
+	Cost         *types.CostSummary     `json:"cost,omitempty"`    // Sum of the steps' usage
+	Changes      *ChangeSummary         `json:"changes,omitempty"` // Files the run changed
+	StartedAt    time.Time              `json:"started_at"`
+	FinishedAt   time.Time              `json:"finished_at,omitempty"`
+
+	mu sync.Mutex
+}
+
+// NewRecord starts a new run record for the named chain.
+func NewRecord(chain string, input map[string]interface{}) *Record {
+	return &Record{
+		ID:        NewID(),
+		Chain:     chain,
+		Status:    StatusRunning,
+		Input:     input,
+		Steps:     []StepRecord{},
+		StartedAt: time.Now(),
+	}
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new run identifier: a ULID, 26 characters holding the
+// creation time in milliseconds followed by 80 random bits, so IDs sort by
+// creation time.
+func NewID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	_, _ = rand.Read(b[6:])
+	// 26 characters of 5 bits cover 130 bits; the first holds the top 3.
+	id := make([]byte, 26)
+	for i := range id {
+		shift := 125 - 5*i
+		var c byte
+		for k := 0; k < 5; k++ {
+			if bit := shift + k; bit < 128 && b[15-bit/8]>>(bit%8)&1 == 1 {
+				c |= 1 << k
+			}
+		}
+		id[i] = crockford[c]
+	}
+	return string(id)
+}
+
+// RunIDPlaceholder in an output path is replaced by the run ID.
+const RunIDPlaceholder = "{run_id}"
+
+// ExpandRunID returns path with every RunIDPlaceholder replaced by id.
+func ExpandRunID(path, id string) string {
+	return strings.ReplaceAll(path, RunIDPlaceholder, id)
+}
+
+// AddStep appends a step to the record.
+func (r *Record) AddStep(step StepRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps = append(r.Steps, step)
+}
+
+// Finish marks the run as finished, with a failure status if err is non-nil.
+func (r *Record) Finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now()
+	timing := r.timingLocked()
+	r.Timing = &timing
+	r.Cost = r.costLocked()
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+	} else {
+		r.Status = StatusSuccess
+	}
+}
+
+// costLocked sums the usage of the steps, or returns nil when no step has any.
+func (r *Record) costLocked() *types.CostSummary {
+	var total *types.CostSummary
+	for _, step := range r.Steps {
+		if step.Usage == nil {
+			continue
+		}
+		if total == nil {
+			total = &types.CostSummary{Currency: step.Usage.Currency, Complete: true}
+		}
+		total.Add(*step.Usage)
+	}
+	return total
+}
+
+// Store persists run records as JSON files in a directory.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns a store rooted at dir (DefaultDir when empty).
+func NewStore(dir string) *Store {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return &Store{Dir: dir}
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Save writes the record to the store.
+func (s *Store) Save(r *Record) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to create runs directory %s", s.Dir), err)
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, "failed to marshal run record", err)
+	}
+	if err := os.WriteFile(s.path(r.ID), data, 0644); err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to write run record %s", r.ID), err)
+	}
+	return nil
+}
+
+// Load reads a record by ID. A unique ID prefix is also accepted.
+func (s *Store) Load(id string) (*Record, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		full, resolveErr := s.resolvePrefix(id)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		data, err = os.ReadFile(s.path(full))
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to read run record %s", id), err)
+	}
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to parse run record %s", id), err)
+	}
+	return &r, nil
+}
+
+func (s *Store) resolvePrefix(prefix string) (string, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, id := range ids {
+		// ULIDs are case-insensitive.
+		if strings.HasPrefix(strings.ToUpper(id), strings.ToUpper(prefix)) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run '%s' not found in %s", prefix, s.Dir), nil)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", errors.New(errors.ErrCodeUnknown, fmt.Sprintf("run id prefix '%s' is ambiguous (%d matches)", prefix, len(matches)), nil)
+	}
+}
+
+// IDs returns all stored run IDs in lexical order, which for ULIDs is oldest
+// first.
+func (s *Store) IDs() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to list runs directory %s", s.Dir), err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// List loads all stored records, oldest first. Unreadable records are skipped.
+// Records are ordered by start time, as IDs from before run IDs became ULIDs
+// sort differently.
+func (s *Store) List() ([]*Record, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*Record, 0, len(ids))
+	for _, id := range ids {
+		r, err := s.Load(id)
+		if err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].StartedAt.Before(records[j].StartedAt) })
+	return records, nil
+}
+
+// Manifest summarizes the side effects of a run.
+type Manifest struct {
+	RunID        string   `json:"run_id"`
+	Chain        string   `json:"chain"`
+	Status       string   `json:"status"`
+	FilesChanged []string `json:"files_changed"`
+	CommandsRun  []string `json:"commands_run"`
+	ToolCalls    int      `json:"tool_calls"`
+}
+
+// Manifest returns the files changed and commands run by successful tool calls.
+func (r *Record) Manifest() Manifest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := Manifest{RunID: r.ID, Chain: r.Chain, Status: r.Status, FilesChanged: []string{}, CommandsRun: []string{}}
+	seen := map[string]bool{}
+	for _, s := range r.Steps {
+		if s.ToolCall == nil {
+			continue
+		}
+		m.ToolCalls++
+		if s.ToolError != "" {
+			continue
+		}
+		if path := editedPath(s); path != "" && !seen[path] {
+			seen[path] = true
+			m.FilesChanged = append(m.FilesChanged, path)
+		}
+		switch s.ToolCall.Name {
+		case "run_command", "RunCommand":
+			if cmd := stringArg(s.ToolCall.Arguments, "command"); cmd != "" {
+				m.CommandsRun = append(m.CommandsRun, cmd)
+			}
+		}
+	}
+	return m
+}
+
+// editedPath returns the file a step changed with a successful write_file or
+// apply_patch call, or "".
+func editedPath(s StepRecord) string {
+	if s.ToolCall == nil || s.ToolError != "" {
+		return ""
+	}
+	switch s.ToolCall.Name {
+	case "write_file", "WriteFile", "apply_patch", "ApplyPatch":
+		return stringArg(s.ToolCall.Arguments, "file_path", "filePath")
+	}
+	return ""
+}
+
+func stringArg(args map[string]interface{}, names ...string) string {
+	for _, n := range names {
+		if v, ok := args[n].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
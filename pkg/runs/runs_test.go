@@ -0,0 +1,532 @@
+package runs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-team/pkg/types"
+)
+
+func sampleRecord() *Record {
+	r := NewRecord("design-code-test", map[string]interface{}{"problem": "add two numbers"})
+	r.AddStep(StepRecord{
+		Index:    0,
+		Role:     "coder",
+		Prompt:   "Write code for add",
+		Response: `{"tool_call": {"name": "write_file"}}`,
+		ToolCall: &types.ToolCall{
+			Name:      "write_file",
+			Arguments: map[string]interface{}{"file_path": "add.go", "content": "package main"},
+		},
+		Diff:       "--- add.go\n+++ add.go\n+package main\n",
+		ToolResult: "package main",
+	})
+	r.AddStep(StepRecord{
+		Index:      1,
+		Role:       "tester",
+		ToolCall:   &types.ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "go test ./..."}},
+		ToolResult: "ok  add 0.01s",
+	})
+	r.Finish(nil)
+	return r
+}
+
+func TestStore_SaveLoadAndPrefix(t *testing.T) {
+	store := NewStore(t.TempDir())
+	r := sampleRecord()
+	if err := store.Save(r); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := store.Load(r.ID)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if loaded.Chain != r.Chain || len(loaded.Steps) != 2 || loaded.Status != StatusSuccess {
+		t.Fatalf("unexpected loaded record: %+v", loaded)
+	}
+
+	byPrefix, err := store.Load(r.ID[:len(r.ID)-3])
+	if err != nil || byPrefix.ID != r.ID {
+		t.Fatalf("expected prefix lookup to resolve %s, got %v, %v", r.ID, byPrefix, err)
+	}
+
+	if _, err := store.Load("does-not-exist"); err == nil {
+		t.Fatalf("expected error for unknown run")
+	}
+}
+
+func TestRecord_FinishWithError(t *testing.T) {
+	r := NewRecord("c", nil)
+	r.Finish(errors.New("boom"))
+	if r.Status != StatusFailed || r.Error != "boom" {
+		t.Fatalf("expected failed status with error, got %s / %s", r.Status, r.Error)
+	}
+}
+
+func TestExport_Markdown(t *testing.T) {
+	out, err := Export(sampleRecord(), "md")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	for _, want := range []string{"# Run ", "## Step 1: coder", "```diff", "+package main", "```bash\ngo test ./...", "ok  add 0.01s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExport_HTMLEscapes(t *testing.T) {
+	r := NewRecord("c", nil)
+	r.AddStep(StepRecord{Role: "coder", Prompt: "<script>alert(1)</script>"})
+	out, err := Export(r, "html")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if strings.Contains(out, "<script>alert") {
+		t.Errorf("expected prompt to be escaped in html report")
+	}
+	if !strings.Contains(out, "<h2>Step 1: coder</h2>") {
+		t.Errorf("expected step heading in html report:\n%s", out)
+	}
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	if _, err := Export(sampleRecord(), "pdf"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestComputeTiming(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	r := &Record{StartedAt: start, FinishedAt: at(1000)}
+	r.AddSpan(Span{Kind: SpanModel, Name: "coder", Step: 0, StartedAt: at(0), FinishedAt: at(300)})
+	r.AddSpan(Span{Kind: SpanTool, Name: "run_command", Step: 0, StartedAt: at(300), FinishedAt: at(700)})
+	r.AddSpan(Span{Kind: SpanToolAttempt, Name: "run_command", Step: 0, Attempt: 1, StartedAt: at(300), FinishedAt: at(450)})
+	r.AddSpan(Span{Kind: SpanToolAttempt, Name: "run_command", Step: 0, Attempt: 2, StartedAt: at(450), FinishedAt: at(700)})
+	r.AddSpan(Span{Kind: SpanModel, Name: "tester", Step: 1, StartedAt: at(700), FinishedAt: at(900)})
+	r.AddStep(StepRecord{Index: 0, Role: "coder", StartedAt: at(0), FinishedAt: at(700)})
+	r.AddStep(StepRecord{Index: 1, Role: "tester", StartedAt: at(700), FinishedAt: at(1000)})
+
+	timing := r.ComputeTiming()
+	if timing.Total != time.Second || timing.Model != 500*time.Millisecond || timing.Tools != 400*time.Millisecond {
+		t.Fatalf("unexpected totals: %+v", timing)
+	}
+	if timing.Retries != 1 || timing.Retry != 250*time.Millisecond {
+		t.Errorf("expected one 250ms retry, got %d (%s)", timing.Retries, timing.Retry)
+	}
+	if len(timing.Steps) != 2 || timing.Steps[0].Name != "coder" || timing.Steps[0].Total != 700*time.Millisecond {
+		t.Fatalf("unexpected step timings: %+v", timing.Steps)
+	}
+
+	out := FormatTiming(timing)
+	for _, want := range []string{"coder", "tester", "1s", "Time spent in tool retries: 250ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in timing table:\n%s", want, out)
+		}
+	}
+}
+
+func TestComputeTiming_CommandUsage(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Record{StartedAt: start, FinishedAt: start.Add(time.Minute)}
+	r.AddStep(StepRecord{Index: 0, Role: "tester", StartedAt: start, FinishedAt: start.Add(time.Minute), Commands: []types.CommandUsage{
+		{Command: "go test ./...", Wall: 40 * time.Second, UserCPU: 90 * time.Second, SystemCPU: 10 * time.Second, PeakRSS: 300 << 20},
+		{Command: "go vet ./...", Wall: 5 * time.Second, UserCPU: 4 * time.Second, SystemCPU: time.Second, PeakRSS: 100 << 20},
+	}})
+
+	timing := r.ComputeTiming()
+	if timing.CommandCPU != 105*time.Second || timing.Steps[0].CommandCPU != 105*time.Second {
+		t.Errorf("unexpected command CPU: %s / %s", timing.CommandCPU, timing.Steps[0].CommandCPU)
+	}
+	if timing.PeakRSS != 300<<20 {
+		t.Errorf("unexpected peak RSS %d", timing.PeakRSS)
+	}
+	if out := FormatTiming(timing); !strings.Contains(out, "1m45s") || !strings.Contains(out, "300.0 MiB") {
+		t.Errorf("expected command usage in timing table:\n%s", out)
+	}
+	if md := RenderMarkdown(r); !strings.Contains(md, "- `go test ./...`: wall 40s, cpu 1m40s (user 1m30s, sys 10s), peak RSS 300.0 MiB, exit 0") {
+		t.Errorf("expected commands in report:\n%s", md)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "-", 512: "512 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFinish_StoresTiming(t *testing.T) {
+	r := sampleRecord()
+	if r.Timing == nil || len(r.Timing.Steps) != 2 {
+		t.Fatalf("expected timing summary on finished run, got %+v", r.Timing)
+	}
+}
+
+func TestSnapshotContext_RedactsSecrets(t *testing.T) {
+	context := map[string]interface{}{
+		"problem":  "add two numbers",
+		"api_key":  "abc123",
+		"channels": make(chan int),
+	}
+	snapshot := SnapshotContext(context)
+	if snapshot["problem"] != "add two numbers" || snapshot["api_key"] != Redacted {
+		t.Errorf("unexpected snapshot %v", snapshot)
+	}
+	if _, ok := snapshot["channels"].(string); !ok {
+		t.Errorf("expected unencodable value to be stringified, got %#v", snapshot["channels"])
+	}
+	if context["api_key"] != "abc123" {
+		t.Error("snapshot must not modify the original context")
+	}
+
+	snapshot = SnapshotContext(map[string]interface{}{
+		"nested": map[string]interface{}{"Password": "pw", "note": "key sk-abcdefghijklmnopqrstuv in text"},
+	})
+	nested := snapshot["nested"].(map[string]interface{})
+	if nested["Password"] != Redacted || nested["note"] != "key "+Redacted+" in text" {
+		t.Errorf("unexpected nested snapshot %v", nested)
+	}
+}
+
+func TestContextAt(t *testing.T) {
+	r := NewRecord("loop", nil)
+	r.AddStep(StepRecord{Index: 0, Iteration: 0, Context: map[string]interface{}{"n": 1}})
+	r.AddStep(StepRecord{Index: 0, Iteration: 1, Context: map[string]interface{}{"n": 2}})
+	r.AddStep(StepRecord{Index: 1})
+
+	if ctx, err := r.ContextAt(0, -1); err != nil || ctx["n"] != 2 {
+		t.Errorf("expected last iteration context, got %v, %v", ctx, err)
+	}
+	if ctx, err := r.ContextAt(0, 0); err != nil || ctx["n"] != 1 {
+		t.Errorf("expected first iteration context, got %v, %v", ctx, err)
+	}
+	if _, err := r.ContextAt(1, -1); err == nil {
+		t.Error("expected error for step without snapshot")
+	}
+	if _, err := r.ContextAt(5, -1); err == nil {
+		t.Error("expected error for missing step")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	if DiffLines("a\nb", "a\nb") != "" {
+		t.Error("expected no diff for equal prompts")
+	}
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	b := "1\n2\n3\n4\n5\ninserted\n6\n7\n8\n9\n10\n11\nchanged"
+	want := "@@ -3,6 +3,7 @@\n 3\n 4\n 5\n+inserted\n 6\n 7\n 8\n" +
+		"@@ -9,4 +10,4 @@\n 9\n 10\n 11\n-12\n+changed\n"
+	if got := DiffLines(a, b); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffPrompts(t *testing.T) {
+	a := NewRecord("c", nil)
+	a.AddStep(StepRecord{Index: 0, Role: "architect", Prompt: "design x"})
+	a.AddStep(StepRecord{Index: 1, Role: "coder", Prompt: "code\nfor x"})
+	a.AddStep(StepRecord{Index: 1, Iteration: 1, Role: "coder", Prompt: "again"})
+	b := NewRecord("c", nil)
+	b.AddStep(StepRecord{Index: 0, Role: "architect", Prompt: "design x"})
+	b.AddStep(StepRecord{Index: 1, Role: "coder", Prompt: "code\nfor y"})
+	b.AddStep(StepRecord{Index: 2, Role: "tester", Prompt: "test"})
+
+	diffs := DiffPrompts(a, b)
+	statuses := []string{}
+	for _, d := range diffs {
+		statuses = append(statuses, d.Status)
+	}
+	want := []string{PromptSame, PromptChanged, PromptOnlyA, PromptOnlyB}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	if !strings.Contains(diffs[1].Diff, "-for x\n+for y\n") {
+		t.Errorf("unexpected diff %q", diffs[1].Diff)
+	}
+	report := FormatPromptDiffs(a, b, diffs)
+	if !strings.Contains(report, "Step 2: coder (iteration 2): only in "+a.ID) || !strings.Contains(report, "3 of 4 step iteration(s) differ") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestNewID_IsSortableULID(t *testing.T) {
+	first := NewID()
+	time.Sleep(2 * time.Millisecond)
+	second := NewID()
+	for _, id := range []string{first, second} {
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("expected a 26-character ULID, got %q", id)
+		}
+	}
+	if first >= second {
+		t.Errorf("expected IDs to sort by creation time, got %s then %s", first, second)
+	}
+	if got := ExpandRunID("reports/{run_id}.md", first); got != "reports/"+first+".md" {
+		t.Errorf("unexpected expanded path %q", got)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"project=api", " ticket = ENG-42 ", "project=web", "env="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := FormatLabels(labels); got != "env=,project=web,ticket=ENG-42" {
+		t.Errorf("unexpected labels %q", got)
+	}
+	if labels, err := ParseLabels(nil); labels != nil || err != nil {
+		t.Errorf("expected no labels, got %v, %v", labels, err)
+	}
+	for _, bad := range []string{"project", "=api"} {
+		if _, err := ParseLabels([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestRecord_HasLabels(t *testing.T) {
+	r := NewRecord("build", nil)
+	r.Labels = map[string]string{"project": "api", "env": "prod"}
+	cases := []struct {
+		filter map[string]string
+		want   bool
+	}{
+		{nil, true},
+		{map[string]string{"project": "api"}, true},
+		{map[string]string{"project": "api", "env": "prod"}, true},
+		{map[string]string{"env": ""}, true},
+		{map[string]string{"project": "web"}, false},
+		{map[string]string{"ticket": ""}, false},
+	}
+	for _, c := range cases {
+		if got := r.HasLabels(c.filter); got != c.want {
+			t.Errorf("HasLabels(%v) = %v, want %v", c.filter, got, c.want)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"7d":         now.AddDate(0, 0, -7),
+		"2w":         now.AddDate(0, 0, -14),
+		"36h":        now.Add(-36 * time.Hour),
+		"2024-05-01": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for in, want := range cases {
+		if got, err := ParseSince(in, now); err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "soon", "-3d"} {
+		if _, err := ParseSince(bad, now); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	record := func(chain, status string, age time.Duration, steps ...StepRecord) *Record {
+		r := NewRecord(chain, nil)
+		r.Status, r.StartedAt, r.Steps = status, now.Add(-age), steps
+		r.Cost = &types.CostSummary{Currency: "USD", Calls: 1, Cost: 0.5, Complete: true}
+		return r
+	}
+	write := func(path string) StepRecord {
+		return StepRecord{Name: "code", Role: "coder", ToolCall: &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": path}}}
+	}
+	failing := StepRecord{Name: "test", Role: "tester", ToolCall: &types.ToolCall{Name: "run_command"}, ToolError: "exit status 1"}
+	records := []*Record{
+		record("build", StatusSuccess, time.Hour, write("a.go"), write("a.go"), write("b.go")),
+		record("build", StatusFailed, 2*time.Hour, write("a.go"), failing, failing),
+		record("build", StatusFailed, 3*time.Hour, failing),
+		record("docs", StatusSuccess, 4*time.Hour),
+		record("docs", StatusFailed, 30*24*time.Hour), // Before the period
+	}
+	records[3].Labels = map[string]string{"team": "docs"}
+
+	report := Summarize(records, now.AddDate(0, 0, -7), now, nil, 1)
+	if report.Runs != 4 || report.Succeeded != 2 || report.Failed != 2 || report.SuccessRate != 0.5 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	if report.Cost == nil || report.Cost.Calls != 4 || report.Cost.Cost != 2 {
+		t.Errorf("unexpected cost %+v", report.Cost)
+	}
+	if len(report.Chains) != 2 || report.Chains[0].Chain != "build" || report.Chains[0].Runs != 3 || report.Chains[1].SuccessRate != 1 {
+		t.Errorf("unexpected chains %+v", report.Chains)
+	}
+	if len(report.FailingSteps) != 1 || report.FailingSteps[0].Step != "test (tester)" || report.FailingSteps[0].Failures != 2 {
+		t.Errorf("expected failing steps counted once per run, got %+v", report.FailingSteps)
+	}
+	if len(report.EditedFiles) != 1 || report.EditedFiles[0].Path != "a.go" || report.EditedFiles[0].Edits != 3 || report.EditedFiles[0].Runs != 2 {
+		t.Errorf("unexpected edited files %+v", report.EditedFiles)
+	}
+
+	md := RenderReportMarkdown(report)
+	for _, want := range []string{"4 (2 succeeded, 2 failed)", "| build | 3 | 1 | 2 | 33% |", "## Top failing steps", "| `a.go` | 3 | 2 |"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in the report:\n%s", want, md)
+		}
+	}
+
+	if labeled := Summarize(records, now.AddDate(0, 0, -7), now, map[string]string{"team": "docs"}, 0); labeled.Runs != 1 || labeled.Chains[0].Chain != "docs" {
+		t.Errorf("expected only the labeled run, got %+v", labeled)
+	}
+	if out, err := ExportReport(report, "json"); err != nil || !strings.Contains(out, `"success_rate": 0.5`) {
+		t.Errorf("unexpected JSON report %q, %v", out, err)
+	}
+	if _, err := ExportReport(report, "pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestCheckpoint_SaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+	path := store.CheckpointPath("01RUN")
+	cp := &Checkpoint{RunID: "01RUN", Chain: "build", Steps: []string{"design"}, NextStep: 1, Context: map[string]interface{}{"design": "queue", "count": 2}, LastToolResponse: map[string]interface{}{"ok": true}}
+	if err := SaveCheckpoint(path, cp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids, _ := store.IDs(); len(ids) != 0 {
+		t.Errorf("expected checkpoints apart from run records, got %v", ids)
+	}
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.NextStep != 1 || loaded.Context["design"] != "queue" || loaded.Context["count"] != 2.0 || loaded.LastToolResponse.(map[string]interface{})["ok"] != true {
+		t.Errorf("unexpected checkpoint %+v", loaded)
+	}
+	if _, err := LoadCheckpoint(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing checkpoint")
+	}
+}
+
+func TestCheckpoint_Branch(t *testing.T) {
+	id, name, err := ParseCheckpointRef("01RUN@designed")
+	if err != nil || id != "01RUN" || name != "designed" {
+		t.Errorf("unexpected reference %q %q %v", id, name, err)
+	}
+	for _, ref := range []string{"01RUN", "01RUN@", "@designed", "01RUN@../x", "01RUN@.."} {
+		if _, _, err := ParseCheckpointRef(ref); err == nil {
+			t.Errorf("expected an error for %q", ref)
+		}
+	}
+
+	cp := &Checkpoint{RunID: "01RUN", Name: "designed", Input: map[string]interface{}{"language": "Go"}, Context: map[string]interface{}{"language": "Go", "design": "queue"}}
+	branch := cp.Branch(map[string]interface{}{"language": "Rust"})
+	if branch.Input["language"] != "Rust" || branch.Context["language"] != "Rust" || branch.Context["design"] != "queue" || branch.Name != "designed" {
+		t.Errorf("unexpected branch %+v", branch)
+	}
+	if cp.Input["language"] != "Go" || cp.Context["language"] != "Go" {
+		t.Errorf("expected the checkpoint left unchanged, got %+v", cp)
+	}
+}
+
+func TestWriteBugReport(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	os.WriteFile(logPath, []byte("calling with key envsecret-1234\n"), 0644)
+	transcript := filepath.Join(dir, "session.json")
+	os.WriteFile(transcript, []byte(`{"token": "tok-123", "note": "ok"}`), 0644)
+
+	r := sampleRecord()
+	r.Input["api_key"] = "sk-abcdefghijklmnopqrstuv"
+	r.Cost = &types.CostSummary{InputTokens: 42}
+	config := []byte("# models\nproviders:\n  openai:\n    api_key: plain-key\n    max_tokens: 100\nroles: {}\n")
+	var buf bytes.Buffer
+	names, err := WriteBugReport(&buf, BugReport{
+		Record:      r,
+		Config:      config,
+		Files:       map[string]string{"logs/run.log": logPath, "transcripts/session.json": transcript, "logs/gone.log": filepath.Join(dir, "gone.log")},
+		Environment: map[string]string{"os": "linux"},
+		Secrets:     []string{"envsecret-1234", "short"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "run.json config.yaml logs/run.log transcripts/session.json environment.json"
+	if strings.Join(names, " ") != want {
+		t.Errorf("entries = %q, want %q", strings.Join(names, " "), want)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	if strings.Contains(files["run.json"], "sk-abc") || !strings.Contains(files["run.json"], `"input_tokens": 42`) {
+		t.Errorf("run.json not redacted as expected:\n%s", files["run.json"])
+	}
+	if strings.Contains(files["config.yaml"], "plain-key") || !strings.Contains(files["config.yaml"], "max_tokens: 100") || !strings.Contains(files["config.yaml"], "# models") {
+		t.Errorf("config.yaml not redacted as expected:\n%s", files["config.yaml"])
+	}
+	if files["logs/run.log"] != "calling with key "+Redacted+"\n" {
+		t.Errorf("log = %q", files["logs/run.log"])
+	}
+	if strings.Contains(files["transcripts/session.json"], "tok-123") {
+		t.Errorf("transcript not redacted:\n%s", files["transcripts/session.json"])
+	}
+	if !strings.Contains(files["environment.json"], `"missing.logs/gone.log"`) || !strings.Contains(files["environment.json"], `"os": "linux"`) {
+		t.Errorf("environment.json = %s", files["environment.json"])
+	}
+}
+
+func TestEnvSecrets(t *testing.T) {
+	got := EnvSecrets([]string{"OPENAI_API_KEY=sk-1", "HOME=/root", "GITHUB_TOKEN=", "DB_PASSWORD=a=b"})
+	if strings.Join(got, " ") != "sk-1 a=b" {
+		t.Errorf("EnvSecrets = %q", got)
+	}
+}
+
+func TestDiffStatAndShow(t *testing.T) {
+	r := sampleRecord()
+	r.AddStep(StepRecord{
+		Index:    2,
+		Role:     "coder",
+		ToolCall: &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "add.go", "content": "package add"}},
+		Diff:     "--- add.go\n+++ add.go\n-package main\n+package add\n",
+	})
+	r.AddStep(StepRecord{
+		Index:     3,
+		ToolCall:  &types.ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "fail.go"}},
+		ToolError: "denied",
+	})
+	files := r.DiffStat()
+	if len(files) != 1 || files[0] != (FileChange{Path: "add.go", Writes: 2, Added: 2, Removed: 1}) {
+		t.Fatalf("DiffStat = %+v", files)
+	}
+	if out := Show(r); !strings.Contains(out, "1 file changed, +2 -1") || !strings.Contains(out, "2 writes") {
+		t.Errorf("Show without a stored summary:\n%s", out)
+	}
+
+	r.SetChanges(&ChangeSummary{Files: files, Summary: "Rename the package", Summarizer: "scribe"})
+	out := Show(r)
+	if !strings.Contains(out, "Status:   success") || !strings.HasSuffix(out, "\nRename the package\n") {
+		t.Errorf("Show with a summary:\n%s", out)
+	}
+	if md := RenderMarkdown(r); !strings.Contains(md, "## Changes") {
+		t.Error("expected the Markdown export to list the changes")
+	}
+	if out := Show(NewRecord("empty", nil)); !strings.HasSuffix(out, "No files changed.\n") {
+		t.Errorf("Show of a run without changes:\n%s", out)
+	}
+}
@@ -0,0 +1,83 @@
+package runs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// StepCacheKey identifies a model call by the model it goes to, its rendered
+// prompt and the step input, so equal keys can share an output.
+func StepCacheKey(label, prompt string, input map[string]interface{}) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", label, prompt)
+	if b, err := json.Marshal(input); err == nil {
+		h.Write(b)
+	} else {
+		fmt.Fprintf(h, "%v", input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// StepCache looks up the model outputs of steps of earlier successful runs,
+// dry runs aside, by their cache key. The store is read once, on the first lookup.
+type StepCache struct {
+	store     *Store
+	run       *Record
+	once      sync.Once
+	responses map[string]string
+}
+
+// NewStepCache returns a cache over the runs in store.
+func NewStepCache(store *Store) *StepCache {
+	return &StepCache{store: store}
+}
+
+// NewRunStepCache returns a cache over the steps of run that got an answer
+// from the model, whether or not the run succeeded, for re-running a chain
+// after an earlier run.
+func NewRunStepCache(run *Record) *StepCache {
+	if run == nil {
+		return nil
+	}
+	return &StepCache{run: run}
+}
+
+// Lookup returns the output of the latest successful step recorded with key.
+func (c *StepCache) Lookup(key string) (string, bool) {
+	if c == nil || (c.store == nil && c.run == nil) || key == "" {
+		return "", false
+	}
+	c.once.Do(c.load)
+	response, ok := c.responses[key]
+	return response, ok
+}
+
+func (c *StepCache) load() {
+	c.responses = map[string]string{}
+	if c.run != nil {
+		for _, step := range c.run.Steps {
+			if step.CacheKey != "" && step.Error == "" {
+				c.responses[step.CacheKey] = step.Response
+			}
+		}
+		return
+	}
+	records, err := c.store.List()
+	if err != nil {
+		return
+	}
+	// Oldest first, so later runs overwrite earlier outputs.
+	for _, r := range records {
+		if r.Status != StatusSuccess || r.DryRun {
+			continue
+		}
+		for _, step := range r.Steps {
+			if step.CacheKey != "" && step.Error == "" {
+				c.responses[step.CacheKey] = step.Response
+			}
+		}
+	}
+}
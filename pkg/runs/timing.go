@@ -0,0 +1,172 @@
+package runs
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ai-team/pkg/types"
+)
+
+// Span kinds recorded on a run's timeline.
+const (
+	SpanModel       = "model"        // A role (LLM provider) call
+	SpanTool        = "tool"         // A tool call including all retries
+	SpanToolAttempt = "tool_attempt" // A single attempt of a tool call
+)
+
+// Span is a timed event of a run, attributed to a chain step.
+type Span struct {
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	Step       int       `json:"step"`
+	Iteration  int       `json:"iteration"`
+	Attempt    int       `json:"attempt,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Duration returns how long the span took.
+func (s Span) Duration() time.Duration {
+	return s.FinishedAt.Sub(s.StartedAt)
+}
+
+// AddSpan appends a span to the run's timeline.
+func (r *Record) AddSpan(span Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Timeline = append(r.Timeline, span)
+}
+
+// StepTiming summarizes where a chain step spent its time.
+type StepTiming struct {
+	Index      int           `json:"index"`
+	Name       string        `json:"name"`
+	Iterations int           `json:"iterations"`
+	Total      time.Duration `json:"total_ns"`
+	Model      time.Duration `json:"model_ns"`
+	Tools      time.Duration `json:"tools_ns"`
+	Retries    int           `json:"retries"`
+	CommandCPU time.Duration `json:"command_cpu_ns"`           // CPU time of the commands run by tools
+	PeakRSS    int64         `json:"peak_rss_bytes,omitempty"` // Largest peak RSS of those commands
+}
+
+// Timing summarizes where a run spent its time. Retry is the time spent in
+// tool attempts after the first.
+type Timing struct {
+	Total   time.Duration `json:"total_ns"`
+	Model   time.Duration `json:"model_ns"`
+	Tools   time.Duration `json:"tools_ns"`
+	Retry   time.Duration `json:"retry_ns"`
+	Retries int           `json:"retries"`
+	Steps   []StepTiming  `json:"steps"`
+
+	CommandCPU time.Duration `json:"command_cpu_ns"`
+	PeakRSS    int64         `json:"peak_rss_bytes,omitempty"`
+}
+
+// ComputeTiming computes the timing summary of the run. Finished runs also
+// store it in Timing.
+func (r *Record) ComputeTiming() Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timingLocked()
+}
+
+func (r *Record) timingLocked() Timing {
+	t := Timing{}
+	finished := r.FinishedAt
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+	t.Total = finished.Sub(r.StartedAt)
+
+	byIndex := map[int]*StepTiming{}
+	var order []int
+	stepFor := func(index int, name string) *StepTiming {
+		st, ok := byIndex[index]
+		if !ok {
+			st = &StepTiming{Index: index, Name: name}
+			byIndex[index] = st
+			order = append(order, index)
+		}
+		return st
+	}
+	for _, s := range r.Steps {
+		st := stepFor(s.Index, stepTitle(s))
+		st.Iterations++
+		st.Total += s.FinishedAt.Sub(s.StartedAt)
+		for _, c := range s.Commands {
+			st.CommandCPU += c.CPU()
+			t.CommandCPU += c.CPU()
+			st.PeakRSS = max(st.PeakRSS, c.PeakRSS)
+			t.PeakRSS = max(t.PeakRSS, c.PeakRSS)
+		}
+	}
+	for _, sp := range r.Timeline {
+		st := stepFor(sp.Step, sp.Name)
+		switch sp.Kind {
+		case SpanModel:
+			st.Model += sp.Duration()
+			t.Model += sp.Duration()
+		case SpanTool:
+			st.Tools += sp.Duration()
+			t.Tools += sp.Duration()
+		case SpanToolAttempt:
+			if sp.Attempt > 1 {
+				st.Retries++
+				t.Retries++
+				t.Retry += sp.Duration()
+			}
+		}
+	}
+	for _, i := range order {
+		t.Steps = append(t.Steps, *byIndex[i])
+	}
+	return t
+}
+
+// FormatTiming renders a timing summary as a plain-text table.
+func FormatTiming(t Timing) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tNAME\tRUNS\tTOTAL\tMODEL\tTOOLS\tRETRIES\tCMD CPU\tPEAK RSS")
+	for _, s := range t.Steps {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%d\t%s\t%s\n", s.Index+1, s.Name, s.Iterations, roundDuration(s.Total), roundDuration(s.Model), roundDuration(s.Tools), s.Retries, roundDuration(s.CommandCPU), FormatBytes(s.PeakRSS))
+	}
+	fmt.Fprintf(w, "\tTOTAL\t\t%s\t%s\t%s\t%d\t%s\t%s\n", roundDuration(t.Total), roundDuration(t.Model), roundDuration(t.Tools), t.Retries, roundDuration(t.CommandCPU), FormatBytes(t.PeakRSS))
+	w.Flush()
+	if t.Retries > 0 {
+		fmt.Fprintf(&b, "Time spent in tool retries: %s\n", roundDuration(t.Retry))
+	}
+	return b.String()
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+// FormatBytes renders a byte count with a binary unit, or "-" for 0.
+func FormatBytes(n int64) string {
+	if n <= 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// FormatCommandUsage renders the resource use of a command on one line.
+func FormatCommandUsage(u types.CommandUsage) string {
+	return fmt.Sprintf("wall %s, cpu %s (user %s, sys %s), peak RSS %s, exit %d",
+		roundDuration(u.Wall), roundDuration(u.CPU()), roundDuration(u.UserCPU), roundDuration(u.SystemCPU), FormatBytes(u.PeakRSS), u.ExitCode)
+}
@@ -0,0 +1,374 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var builtins map[string]*callable
+
+func init() {
+	builtins = map[string]*callable{}
+	for name, fn := range map[string]func([]Value) (Value, error){
+		"len":         builtinLen,
+		"str":         oneArg(func(v Value) (Value, error) { return toString(v), nil }),
+		"int":         oneArg(toInt),
+		"float":       oneArg(toFloat),
+		"bool":        oneArg(func(v Value) (Value, error) { return truth(v), nil }),
+		"json_decode": oneArg(jsonDecode),
+		"json_encode": oneArg(builtinJSONEncode),
+		"matches":     builtinMatches,
+		"find_all":    builtinFindAll,
+		"fail": func(args []Value) (Value, error) {
+			parts := make([]string, len(args))
+			for i, a := range args {
+				parts[i] = toString(a)
+			}
+			return nil, fmt.Errorf("%s", strings.Join(parts, " "))
+		},
+	} {
+		builtins[name] = &callable{name: name, fn: fn}
+	}
+}
+
+func oneArg(fn func(Value) (Value, error)) func([]Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes exactly one argument, got %d", len(args))
+		}
+		return fn(args[0])
+	}
+}
+
+func stringArg(args []Value, i int, what string) (string, error) {
+	if i >= len(args) {
+		return "", fmt.Errorf("missing %s argument", what)
+	}
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string, got %s", what, typeName(args[i]))
+	}
+	return s, nil
+}
+
+func builtinLen(args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("takes exactly one argument, got %d", len(args))
+	}
+	switch x := args[0].(type) {
+	case string:
+		return len([]rune(x)), nil
+	case *List:
+		return len(x.elems), nil
+	case *Dict:
+		return len(x.keys), nil
+	}
+	return nil, fmt.Errorf("%s has no length", typeName(args[0]))
+}
+
+func toInt(v Value) (Value, error) {
+	switch x := v.(type) {
+	case int:
+		return x, nil
+	case float64:
+		return int(x), nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(x))
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", x)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to int", typeName(v))
+}
+
+func toFloat(v Value) (Value, error) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", x)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to float", typeName(v))
+}
+
+func jsonDecode(v Value) (Value, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string, got %s", typeName(v))
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var plain interface{}
+	if err := dec.Decode(&plain); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return decodeNumbers(plain), nil
+}
+
+// decodeNumbers converts decoded JSON to script values, keeping whole numbers
+// as ints.
+func decodeNumbers(v interface{}) Value {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := strconv.Atoi(x.String()); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		list := &List{elems: make([]Value, len(x))}
+		for i, e := range x {
+			list.elems[i] = decodeNumbers(e)
+		}
+		return list
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := newDict()
+		for _, k := range keys {
+			d.set(k, decodeNumbers(x[k]))
+		}
+		return d
+	}
+	return v
+}
+
+func builtinJSONEncode(v Value) (Value, error) {
+	data, err := json.Marshal(fromValue(v))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func compileArgs(args []Value) (*regexp.Regexp, string, error) {
+	pattern, err := stringArg(args, 0, "pattern")
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := stringArg(args, 1, "text")
+	if err != nil {
+		return nil, "", err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid pattern: %v", err)
+	}
+	return re, s, nil
+}
+
+func builtinMatches(args []Value) (Value, error) {
+	re, s, err := compileArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(s), nil
+}
+
+// builtinFindAll returns every match of the pattern, or the first capture
+// group of every match when the pattern has groups.
+func builtinFindAll(args []Value) (Value, error) {
+	re, s, err := compileArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	list := &List{}
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		if len(m) > 1 {
+			list.elems = append(list.elems, m[1])
+		} else {
+			list.elems = append(list.elems, m[0])
+		}
+	}
+	return list, nil
+}
+
+// method returns the method name bound to x.
+func method(x Value, name string) (Value, error) {
+	var fn func([]Value) (Value, error)
+	switch recv := x.(type) {
+	case string:
+		fn = stringMethod(recv, name)
+	case *List:
+		fn = listMethod(recv, name)
+	case *Dict:
+		fn = dictMethod(recv, name)
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("%s has no method %s", typeName(x), name)
+	}
+	return &callable{name: name, fn: fn}, nil
+}
+
+func stringMethod(s, name string) func([]Value) (Value, error) {
+	str := func(fn func(string) string) func([]Value) (Value, error) {
+		return func(args []Value) (Value, error) {
+			return fn(s), nil
+		}
+	}
+	withString := func(fn func(string) (Value, error)) func([]Value) (Value, error) {
+		return func(args []Value) (Value, error) {
+			arg, err := stringArg(args, 0, "argument")
+			if err != nil {
+				return nil, err
+			}
+			return fn(arg)
+		}
+	}
+	strip := func(trim func(string, string) string, space func(string) string) func([]Value) (Value, error) {
+		return func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return space(s), nil
+			}
+			chars, err := stringArg(args, 0, "characters")
+			if err != nil {
+				return nil, err
+			}
+			return trim(s, chars), nil
+		}
+	}
+	switch name {
+	case "upper":
+		return str(strings.ToUpper)
+	case "lower":
+		return str(strings.ToLower)
+	case "strip":
+		return strip(strings.Trim, strings.TrimSpace)
+	case "lstrip":
+		return strip(strings.TrimLeft, func(s string) string { return strings.TrimLeft(s, " \t\r\n") })
+	case "rstrip":
+		return strip(strings.TrimRight, func(s string) string { return strings.TrimRight(s, " \t\r\n") })
+	case "startswith":
+		return withString(func(prefix string) (Value, error) { return strings.HasPrefix(s, prefix), nil })
+	case "endswith":
+		return withString(func(suffix string) (Value, error) { return strings.HasSuffix(s, suffix), nil })
+	case "splitlines":
+		return func(args []Value) (Value, error) {
+			list := &List{}
+			for _, line := range strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n") {
+				if line != "" || s != "" {
+					list.elems = append(list.elems, line)
+				}
+			}
+			return list, nil
+		}
+	case "split":
+		return func(args []Value) (Value, error) {
+			var parts []string
+			if len(args) == 0 || args[0] == nil {
+				parts = strings.Fields(s)
+			} else {
+				sep, err := stringArg(args, 0, "separator")
+				if err != nil {
+					return nil, err
+				}
+				if sep == "" {
+					return nil, fmt.Errorf("empty separator")
+				}
+				parts = strings.Split(s, sep)
+			}
+			list := &List{elems: make([]Value, len(parts))}
+			for i, p := range parts {
+				list.elems[i] = p
+			}
+			return list, nil
+		}
+	case "replace":
+		return func(args []Value) (Value, error) {
+			old, err := stringArg(args, 0, "old")
+			if err != nil {
+				return nil, err
+			}
+			repl, err := stringArg(args, 1, "new")
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, old, repl), nil
+		}
+	case "join":
+		return func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("takes exactly one argument, got %d", len(args))
+			}
+			elems, err := iterate(args[0])
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, len(elems))
+			for i, e := range elems {
+				p, ok := e.(string)
+				if !ok {
+					return nil, fmt.Errorf("element %d is %s, not a string", i, typeName(e))
+				}
+				parts[i] = p
+			}
+			return strings.Join(parts, s), nil
+		}
+	}
+	return nil
+}
+
+func listMethod(l *List, name string) func([]Value) (Value, error) {
+	switch name {
+	case "append":
+		return func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("takes exactly one argument, got %d", len(args))
+			}
+			l.elems = append(l.elems, args[0])
+			return nil, nil
+		}
+	}
+	return nil
+}
+
+func dictMethod(d *Dict, name string) func([]Value) (Value, error) {
+	switch name {
+	case "get":
+		return func(args []Value) (Value, error) {
+			key, err := stringArg(args, 0, "key")
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := d.get(key); ok {
+				return v, nil
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return nil, nil
+		}
+	case "keys", "values":
+		return func(args []Value) (Value, error) {
+			list := &List{elems: make([]Value, len(d.keys))}
+			for i, k := range d.keys {
+				if name == "keys" {
+					list.elems[i] = k
+				} else {
+					list.elems[i] = d.m[k]
+				}
+			}
+			return list, nil
+		}
+	}
+	return nil
+}
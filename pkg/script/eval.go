@@ -0,0 +1,490 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxSteps bounds the statements and loop iterations of one run, so that a
+// script cannot hold up a chain.
+const maxSteps = 1000000
+
+type control int
+
+const (
+	controlNone control = iota
+	controlBreak
+	controlContinue
+)
+
+type frame struct {
+	ctx     context.Context
+	globals map[string]Value
+	print   *callable // Logs through the run's logger
+	steps   int
+}
+
+// tick counts a step and stops the run once the budget is spent or ctx is
+// done.
+func (f *frame) tick(line int) error {
+	f.steps++
+	if f.steps > maxSteps {
+		return &Error{Line: line, Msg: fmt.Sprintf("script exceeded %d steps", maxSteps)}
+	}
+	if f.steps%1000 == 0 {
+		if err := f.ctx.Err(); err != nil {
+			return &Error{Line: line, Msg: "script cancelled: " + err.Error()}
+		}
+	}
+	return nil
+}
+
+func (f *frame) execBlock(body []stmt) (control, error) {
+	for _, s := range body {
+		ctrl, err := f.exec(s)
+		if err != nil || ctrl != controlNone {
+			return ctrl, err
+		}
+	}
+	return controlNone, nil
+}
+
+func (f *frame) exec(s stmt) (control, error) {
+	if err := f.tick(s.stmtLine()); err != nil {
+		return controlNone, err
+	}
+	switch s := s.(type) {
+	case *exprStmt:
+		_, err := f.eval(s.x)
+		return controlNone, err
+	case *assignStmt:
+		return controlNone, f.assign(s)
+	case *ifStmt:
+		cond, err := f.eval(s.cond)
+		if err != nil {
+			return controlNone, err
+		}
+		if truth(cond) {
+			return f.execBlock(s.then)
+		}
+		return f.execBlock(s.els)
+	case *forStmt:
+		iter, err := f.eval(s.iter)
+		if err != nil {
+			return controlNone, err
+		}
+		elems, err := iterate(iter)
+		if err != nil {
+			return controlNone, &Error{Line: s.line, Msg: err.Error()}
+		}
+		for _, elem := range elems {
+			if err := f.tick(s.line); err != nil {
+				return controlNone, err
+			}
+			f.globals[s.name] = elem
+			ctrl, err := f.execBlock(s.body)
+			if err != nil {
+				return controlNone, err
+			}
+			if ctrl == controlBreak {
+				break
+			}
+		}
+		return controlNone, nil
+	case *branchStmt:
+		switch s.kind {
+		case "break":
+			return controlBreak, nil
+		case "continue":
+			return controlContinue, nil
+		}
+		return controlNone, nil
+	}
+	return controlNone, fmt.Errorf("unknown statement %T", s)
+}
+
+func (f *frame) assign(s *assignStmt) error {
+	value, err := f.eval(s.value)
+	if err != nil {
+		return err
+	}
+	if s.op != "=" {
+		old, err := f.eval(s.target)
+		if err != nil {
+			return err
+		}
+		if value, err = binary(strings.TrimSuffix(s.op, "="), old, value); err != nil {
+			return &Error{Line: s.line, Msg: err.Error()}
+		}
+	}
+	switch t := s.target.(type) {
+	case *nameExpr:
+		f.globals[t.name] = value
+		return nil
+	case *indexExpr:
+		container, err := f.eval(t.x)
+		if err != nil {
+			return err
+		}
+		idx, err := f.eval(t.idx)
+		if err != nil {
+			return err
+		}
+		switch c := container.(type) {
+		case *List:
+			i, err := listIndex(idx, len(c.elems))
+			if err != nil {
+				return &Error{Line: s.line, Msg: err.Error()}
+			}
+			c.elems[i] = value
+			return nil
+		case *Dict:
+			key, ok := idx.(string)
+			if !ok {
+				return &Error{Line: s.line, Msg: fmt.Sprintf("dict keys must be strings, got %s", typeName(idx))}
+			}
+			c.set(key, value)
+			return nil
+		}
+		return &Error{Line: s.line, Msg: fmt.Sprintf("cannot assign to an element of %s", typeName(container))}
+	}
+	return &Error{Line: s.line, Msg: "invalid assignment"}
+}
+
+func (f *frame) eval(e expr) (Value, error) {
+	v, err := f.evalExpr(e)
+	if err != nil {
+		if _, ok := err.(*Error); !ok {
+			err = &Error{Line: e.exprLine(), Msg: err.Error()}
+		}
+	}
+	return v, err
+}
+
+func (f *frame) evalExpr(e expr) (Value, error) {
+	switch e := e.(type) {
+	case *literalExpr:
+		return e.value, nil
+	case *nameExpr:
+		if v, ok := f.globals[e.name]; ok {
+			return v, nil
+		}
+		if e.name == "print" {
+			return f.print, nil
+		}
+		if b, ok := builtins[e.name]; ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("undefined: %s", e.name)
+	case *listExpr:
+		list := &List{elems: make([]Value, len(e.elems))}
+		for i, elem := range e.elems {
+			v, err := f.eval(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.elems[i] = v
+		}
+		return list, nil
+	case *dictExpr:
+		d := newDict()
+		for i := range e.keys {
+			k, err := f.eval(e.keys[i])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", typeName(k))
+			}
+			v, err := f.eval(e.values[i])
+			if err != nil {
+				return nil, err
+			}
+			d.set(key, v)
+		}
+		return d, nil
+	case *unaryExpr:
+		x, err := f.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "not":
+			return !truth(x), nil
+		case "-":
+			switch n := x.(type) {
+			case int:
+				return -n, nil
+			case float64:
+				return -n, nil
+			}
+		case "+":
+			if _, ok := number(x); ok {
+				return x, nil
+			}
+		}
+		return nil, fmt.Errorf("bad operand %s for unary %s", typeName(x), e.op)
+	case *binaryExpr:
+		x, err := f.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		// and and or only evaluate their right side when it decides.
+		switch e.op {
+		case "and":
+			if !truth(x) {
+				return x, nil
+			}
+			return f.eval(e.y)
+		case "or":
+			if truth(x) {
+				return x, nil
+			}
+			return f.eval(e.y)
+		}
+		y, err := f.eval(e.y)
+		if err != nil {
+			return nil, err
+		}
+		return binary(e.op, x, y)
+	case *indexExpr:
+		x, err := f.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := f.eval(e.idx)
+		if err != nil {
+			return nil, err
+		}
+		return index(x, idx)
+	case *sliceExpr:
+		x, err := f.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		var lo, hi Value
+		if e.lo != nil {
+			if lo, err = f.eval(e.lo); err != nil {
+				return nil, err
+			}
+		}
+		if e.hi != nil {
+			if hi, err = f.eval(e.hi); err != nil {
+				return nil, err
+			}
+		}
+		return slice(x, lo, hi)
+	case *attrExpr:
+		x, err := f.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return method(x, e.name)
+	case *callExpr:
+		fn, err := f.eval(e.fn)
+		if err != nil {
+			return nil, err
+		}
+		c, ok := fn.(*callable)
+		if !ok {
+			return nil, fmt.Errorf("%s is not callable", typeName(fn))
+		}
+		args := make([]Value, len(e.args))
+		for i, arg := range e.args {
+			if args[i], err = f.eval(arg); err != nil {
+				return nil, err
+			}
+		}
+		v, err := c.fn(args)
+		if err != nil {
+			if _, ok := err.(*Error); !ok {
+				err = fmt.Errorf("%s: %v", c.name, err)
+			}
+		}
+		return v, err
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+// binary applies a binary operator other than and and or.
+func binary(op string, x, y Value) (Value, error) {
+	switch op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "<", "<=", ">", ">=":
+		c, err := compare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "in", "not in":
+		in, err := contains(y, x)
+		if err != nil {
+			return nil, err
+		}
+		return in == (op == "in"), nil
+	}
+
+	if a, ok := x.(int); ok {
+		if b, ok := y.(int); ok {
+			switch op {
+			case "+":
+				return a + b, nil
+			case "-":
+				return a - b, nil
+			case "*":
+				return a * b, nil
+			}
+		}
+	}
+	if a, ok := number(x); ok {
+		if b, ok := number(y); ok {
+			switch op {
+			case "+":
+				return a + b, nil
+			case "-":
+				return a - b, nil
+			case "*":
+				return a * b, nil
+			case "/":
+				if b == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				return a / b, nil
+			}
+		}
+	}
+	switch a := x.(type) {
+	case string:
+		if b, ok := y.(string); ok && op == "+" {
+			return a + b, nil
+		}
+	case *List:
+		if b, ok := y.(*List); ok && op == "+" {
+			return &List{elems: append(append([]Value(nil), a.elems...), b.elems...)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported operands %s %s %s", typeName(x), op, typeName(y))
+}
+
+// contains reports whether x is in container: a substring, a list element or
+// a dict key.
+func contains(container, x Value) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := x.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires a string, got %s", typeName(x))
+		}
+		return strings.Contains(c, s), nil
+	case *List:
+		for _, e := range c.elems {
+			if equal(e, x) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *Dict:
+		key, ok := x.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c.get(key)
+		return found, nil
+	}
+	return false, fmt.Errorf("'in' is not supported for %s", typeName(container))
+}
+
+// listIndex resolves a possibly negative index into a sequence of length n.
+func listIndex(idx Value, n int) (int, error) {
+	i, ok := idx.(int)
+	if !ok {
+		return 0, fmt.Errorf("index must be an int, got %s", typeName(idx))
+	}
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return 0, fmt.Errorf("index %d out of range for length %d", idx, n)
+	}
+	return i, nil
+}
+
+func index(x, idx Value) (Value, error) {
+	switch c := x.(type) {
+	case *List:
+		i, err := listIndex(idx, len(c.elems))
+		if err != nil {
+			return nil, err
+		}
+		return c.elems[i], nil
+	case string:
+		runes := []rune(c)
+		i, err := listIndex(idx, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return string(runes[i]), nil
+	case *Dict:
+		key, ok := idx.(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %s", typeName(idx))
+		}
+		v, found := c.get(key)
+		if !found {
+			return nil, fmt.Errorf("key %q not found; use get() for optional keys", key)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(x))
+}
+
+func slice(x, lo, hi Value) (Value, error) {
+	var n int
+	switch c := x.(type) {
+	case *List:
+		n = len(c.elems)
+	case string:
+		n = len([]rune(c))
+	default:
+		return nil, fmt.Errorf("cannot slice %s", typeName(x))
+	}
+	bound := func(v Value, def int) (int, error) {
+		if v == nil {
+			return def, nil
+		}
+		i, ok := v.(int)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be ints, got %s", typeName(v))
+		}
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, 0), n), nil
+	}
+	start, err := bound(lo, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(hi, n)
+	if err != nil {
+		return nil, err
+	}
+	end = max(end, start)
+	if c, ok := x.(*List); ok {
+		return &List{elems: append([]Value(nil), c.elems[start:end]...)}, nil
+	}
+	return string([]rune(x.(string))[start:end]), nil
+}
@@ -0,0 +1,241 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokIndent
+	tokDedent
+	tokName
+	tokInt
+	tokFloat
+	tokString
+	tokOp // Operators and punctuation, and keywords
+)
+
+type token struct {
+	kind tokenKind
+	text string // Name, operator or keyword; the value of a string literal
+	num  interface{}
+	line int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "continue": true, "elif": true, "else": true, "for": true,
+	"if": true, "in": true, "not": true, "or": true, "pass": true,
+	"True": true, "False": true, "None": true,
+}
+
+// Operators, longest first so that "+=" wins over "+".
+var operators = []string{
+	"==", "!=", "<=", ">=", "+=", "-=", "*=", "/=",
+	"+", "-", "*", "/", "<", ">", "=", "(", ")", "[", "]", "{", "}", ",", ":", ".",
+}
+
+// lex splits src into tokens. Like Python, indentation opens and closes
+// blocks, and line breaks inside brackets do not end a statement.
+func lex(src string) ([]token, error) {
+	var toks []token
+	indents := []int{0}
+	depth := 0 // Open brackets
+	line := 1
+	atLineStart := true
+	i := 0
+	for i < len(src) {
+		if atLineStart && depth == 0 {
+			col := 0
+			j := i
+			for ; j < len(src) && (src[j] == ' ' || src[j] == '\t'); j++ {
+				if src[j] == '\t' {
+					col += 8 - col%8
+				} else {
+					col++
+				}
+			}
+			// Blank and comment-only lines do not affect indentation.
+			if j == len(src) || src[j] == '\n' || src[j] == '\r' || src[j] == '#' {
+				for j < len(src) && src[j] != '\n' {
+					j++
+				}
+				if j < len(src) {
+					line++
+					j++
+				}
+				i = j
+				continue
+			}
+			switch top := indents[len(indents)-1]; {
+			case col > top:
+				indents = append(indents, col)
+				toks = append(toks, token{kind: tokIndent, line: line})
+			case col < top:
+				for col < indents[len(indents)-1] {
+					indents = indents[:len(indents)-1]
+					toks = append(toks, token{kind: tokDedent, line: line})
+				}
+				if col != indents[len(indents)-1] {
+					return nil, &Error{Line: line, Msg: "unindent does not match any outer indentation level"}
+				}
+			}
+			i = j
+			atLineStart = false
+		}
+
+		c := src[i]
+		switch {
+		case c == '\n':
+			if depth == 0 {
+				toks = append(toks, token{kind: tokNewline, line: line})
+				atLineStart = true
+			}
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			line++
+			i += 2
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			s, n, lines, err := lexString(src[i:])
+			if err != nil {
+				return nil, &Error{Line: line, Msg: err.Error()}
+			}
+			toks = append(toks, token{kind: tokString, text: s, line: line})
+			line += lines
+			i += n
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			j := i
+			float := false
+			for j < len(src) && (isDigit(src[j]) || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				((src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E'))) {
+				if !isDigit(src[j]) {
+					float = true
+				}
+				j++
+			}
+			text := src[i:j]
+			tok := token{kind: tokInt, text: text, line: line}
+			if float {
+				f, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, &Error{Line: line, Msg: fmt.Sprintf("invalid number %s", text)}
+				}
+				tok.kind, tok.num = tokFloat, f
+			} else {
+				n, err := strconv.Atoi(text)
+				if err != nil {
+					return nil, &Error{Line: line, Msg: fmt.Sprintf("invalid number %s", text)}
+				}
+				tok.num = n
+			}
+			toks = append(toks, tok)
+			i = j
+		case isNameStart(c):
+			j := i
+			for j < len(src) && (isNameStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			word := src[i:j]
+			if keywords[word] {
+				toks = append(toks, token{kind: tokOp, text: word, line: line})
+			} else {
+				toks = append(toks, token{kind: tokName, text: word, line: line})
+			}
+			i = j
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Line: line, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			switch op {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth > 0 {
+					depth--
+				}
+			}
+			toks = append(toks, token{kind: tokOp, text: op, line: line})
+			i += len(op)
+		}
+	}
+	if n := len(toks); n > 0 && toks[n-1].kind != tokNewline && toks[n-1].kind != tokDedent {
+		toks = append(toks, token{kind: tokNewline, line: line})
+	}
+	for len(indents) > 1 {
+		indents = indents[:len(indents)-1]
+		toks = append(toks, token{kind: tokDedent, line: line})
+	}
+	return append(toks, token{kind: tokEOF, line: line}), nil
+}
+
+// lexString reads the string literal at the start of s, single- or
+// triple-quoted. It returns the value, the length of the literal and the
+// number of line breaks in it.
+func lexString(s string) (string, int, int, error) {
+	quote := s[:1]
+	if strings.HasPrefix(s, strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	var b strings.Builder
+	lines := 0
+	for i := len(quote); i < len(s); {
+		if strings.HasPrefix(s[i:], quote) {
+			return b.String(), i + len(quote), lines, nil
+		}
+		c := s[i]
+		switch {
+		case c == '\n' && len(quote) == 1:
+			return "", 0, 0, fmt.Errorf("unterminated string")
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '\'', '"':
+				b.WriteByte(e)
+			case '\n':
+				lines++
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			i++
+			continue
+		case c == '\n':
+			lines++
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return "", 0, 0, fmt.Errorf("unterminated string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
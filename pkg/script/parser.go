@@ -0,0 +1,535 @@
+package script
+
+import (
+	"fmt"
+)
+
+// Statements.
+type (
+	stmt interface{ stmtLine() int }
+
+	exprStmt struct {
+		line int
+		x    expr
+	}
+	assignStmt struct {
+		line   int
+		target expr   // nameExpr or indexExpr
+		op     string // "=" or an augmented operator such as "+="
+		value  expr
+	}
+	ifStmt struct {
+		line int
+		cond expr
+		then []stmt
+		els  []stmt // An elif is an ifStmt of its own in els
+	}
+	forStmt struct {
+		line int
+		name string
+		iter expr
+		body []stmt
+	}
+	branchStmt struct {
+		line int
+		kind string // "pass", "break" or "continue"
+	}
+)
+
+func (s *exprStmt) stmtLine() int   { return s.line }
+func (s *assignStmt) stmtLine() int { return s.line }
+func (s *ifStmt) stmtLine() int     { return s.line }
+func (s *forStmt) stmtLine() int    { return s.line }
+func (s *branchStmt) stmtLine() int { return s.line }
+
+// Expressions.
+type (
+	expr interface{ exprLine() int }
+
+	literalExpr struct {
+		line  int
+		value Value
+	}
+	nameExpr struct {
+		line int
+		name string
+	}
+	listExpr struct {
+		line  int
+		elems []expr
+	}
+	dictExpr struct {
+		line         int
+		keys, values []expr
+	}
+	unaryExpr struct {
+		line int
+		op   string // "-", "+" or "not"
+		x    expr
+	}
+	binaryExpr struct {
+		line int
+		op   string
+		x, y expr
+	}
+	indexExpr struct {
+		line int
+		x    expr
+		idx  expr
+	}
+	sliceExpr struct {
+		line   int
+		x      expr
+		lo, hi expr // Optional
+	}
+	attrExpr struct {
+		line int
+		x    expr
+		name string
+	}
+	callExpr struct {
+		line int
+		fn   expr
+		args []expr
+	}
+)
+
+func (e *literalExpr) exprLine() int { return e.line }
+func (e *nameExpr) exprLine() int    { return e.line }
+func (e *listExpr) exprLine() int    { return e.line }
+func (e *dictExpr) exprLine() int    { return e.line }
+func (e *unaryExpr) exprLine() int   { return e.line }
+func (e *binaryExpr) exprLine() int  { return e.line }
+func (e *indexExpr) exprLine() int   { return e.line }
+func (e *sliceExpr) exprLine() int   { return e.line }
+func (e *attrExpr) exprLine() int    { return e.line }
+func (e *callExpr) exprLine() int    { return e.line }
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func parse(src string) ([]stmt, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	var body []stmt
+	for p.peek().kind != tokEOF {
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+	}
+	return body, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the next token is the operator or keyword op.
+func (p *parser) is(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) accept(op string) bool {
+	if p.is(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return p.errorf("expected %s, found %s", op, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Line: p.peek().line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of script"
+	case tokNewline:
+		return "end of line"
+	case tokIndent:
+		return "indentation"
+	case tokDedent:
+		return "end of block"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+func (p *parser) statement() (stmt, error) {
+	line := p.peek().line
+	switch {
+	case p.accept("if"):
+		return p.ifRest(line)
+	case p.accept("for"):
+		t := p.next()
+		if t.kind != tokName {
+			return nil, &Error{Line: t.line, Msg: fmt.Sprintf("expected a loop variable, found %s", describe(t))}
+		}
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		iter, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		body, err := p.suite()
+		if err != nil {
+			return nil, err
+		}
+		return &forStmt{line: line, name: t.text, iter: iter, body: body}, nil
+	}
+	s, err := p.simpleStatement()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokNewline && p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %s", describe(p.peek()))
+	}
+	p.next()
+	return s, nil
+}
+
+// ifRest parses an if statement after its keyword, and its elif and else
+// branches.
+func (p *parser) ifRest(line int) (stmt, error) {
+	cond, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	then, err := p.suite()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{line: line, cond: cond, then: then}
+	elifLine := p.peek().line
+	switch {
+	case p.accept("elif"):
+		elif, err := p.ifRest(elifLine)
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elif}
+	case p.accept("else"):
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if s.els, err = p.suite(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// suite parses the body of an if or for: a statement on the same line, or
+// an indented block.
+func (p *parser) suite() ([]stmt, error) {
+	if p.peek().kind != tokNewline {
+		s, err := p.simpleStatement()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind == tokNewline {
+			p.next()
+		}
+		return []stmt{s}, nil
+	}
+	p.next()
+	if p.peek().kind != tokIndent {
+		return nil, p.errorf("expected an indented block")
+	}
+	p.next()
+	var body []stmt
+	for p.peek().kind != tokDedent && p.peek().kind != tokEOF {
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+	}
+	p.next()
+	return body, nil
+}
+
+func (p *parser) simpleStatement() (stmt, error) {
+	line := p.peek().line
+	for _, kind := range []string{"pass", "break", "continue"} {
+		if p.accept(kind) {
+			return &branchStmt{line: line, kind: kind}, nil
+		}
+	}
+	x, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "+=", "-=", "*=", "/="} {
+		if !p.accept(op) {
+			continue
+		}
+		switch x.(type) {
+		case *nameExpr, *indexExpr:
+		default:
+			return nil, &Error{Line: line, Msg: "can only assign to a name or an element"}
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &assignStmt{line: line, target: x, op: op, value: value}, nil
+	}
+	return &exprStmt{line: line, x: x}, nil
+}
+
+// expression parses an expression, starting at the loosest binding
+// operator.
+func (p *parser) expression() (expr, error) {
+	return p.binary(0)
+}
+
+// Binary operators by precedence, loosest first. "not" is a prefix operator
+// between and and the comparisons.
+var precedence = [][]string{
+	{"or"},
+	{"and"},
+	{"not"},
+	{"==", "!=", "<", "<=", ">", ">=", "in", "not in"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	line := p.peek().line
+	if precedence[level][0] == "not" {
+		if p.accept("not") {
+			x, err := p.binary(level)
+			if err != nil {
+				return nil, err
+			}
+			return &unaryExpr{line: line, op: "not", x: x}, nil
+		}
+		return p.binary(level + 1)
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.binaryOp(precedence[level])
+		if op == "" {
+			return x, nil
+		}
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{line: line, op: op, x: x, y: y}
+	}
+}
+
+// binaryOp consumes and returns the next token when it is one of ops.
+func (p *parser) binaryOp(ops []string) string {
+	for _, op := range ops {
+		if op == "not in" {
+			if p.is("not") && p.toks[p.pos+1].kind == tokOp && p.toks[p.pos+1].text == "in" {
+				p.pos += 2
+				return op
+			}
+		} else if p.accept(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *parser) unary() (expr, error) {
+	line := p.peek().line
+	for _, op := range []string{"-", "+"} {
+		if p.accept(op) {
+			x, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryExpr{line: line, op: op, x: x}, nil
+		}
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line := p.peek().line
+		switch {
+		case p.accept("("):
+			call := &callExpr{line: line, fn: x}
+			if err := p.callArgs(call); err != nil {
+				return nil, err
+			}
+			x = call
+		case p.accept("["):
+			if x, err = p.subscript(line, x); err != nil {
+				return nil, err
+			}
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokName {
+				return nil, &Error{Line: t.line, Msg: fmt.Sprintf("expected a method name, found %s", describe(t))}
+			}
+			x = &attrExpr{line: line, x: x, name: t.text}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) callArgs(call *callExpr) error {
+	for !p.accept(")") {
+		arg, err := p.expression()
+		if err != nil {
+			return err
+		}
+		call.args = append(call.args, arg)
+		if !p.accept(",") {
+			return p.expect(")")
+		}
+	}
+	return nil
+}
+
+// subscript parses x[i] or x[lo:hi] after the opening bracket.
+func (p *parser) subscript(line int, x expr) (expr, error) {
+	var lo, hi expr
+	var err error
+	if !p.is(":") {
+		if lo, err = p.expression(); err != nil {
+			return nil, err
+		}
+		if p.accept("]") {
+			return &indexExpr{line: line, x: x, idx: lo}, nil
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if !p.is("]") {
+		if hi, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return &sliceExpr{line: line, x: x, lo: lo, hi: hi}, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokName:
+		return &nameExpr{line: t.line, name: t.text}, nil
+	case tokInt, tokFloat:
+		return &literalExpr{line: t.line, value: t.num}, nil
+	case tokString:
+		s := t.text
+		for p.peek().kind == tokString { // Adjacent literals are joined
+			s += p.next().text
+		}
+		return &literalExpr{line: t.line, value: s}, nil
+	case tokOp:
+		switch t.text {
+		case "True":
+			return &literalExpr{line: t.line, value: true}, nil
+		case "False":
+			return &literalExpr{line: t.line, value: false}, nil
+		case "None":
+			return &literalExpr{line: t.line, value: nil}, nil
+		case "(":
+			x, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.list(t.line)
+		case "{":
+			return p.dict(t.line)
+		}
+	}
+	return nil, &Error{Line: t.line, Msg: fmt.Sprintf("unexpected %s", describe(t))}
+}
+
+// list parses a list literal after its opening bracket.
+func (p *parser) list(line int) (expr, error) {
+	list := &listExpr{line: line}
+	for !p.accept("]") {
+		elem, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		list.elems = append(list.elems, elem)
+		if !p.accept(",") {
+			return list, p.expect("]")
+		}
+	}
+	return list, nil
+}
+
+// dict parses a dict literal after its opening brace.
+func (p *parser) dict(line int) (expr, error) {
+	d := &dictExpr{line: line}
+	for !p.accept("}") {
+		key, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		d.keys = append(d.keys, key)
+		d.values = append(d.values, value)
+		if !p.accept(",") {
+			return d, p.expect("}")
+		}
+	}
+	return d, nil
+}
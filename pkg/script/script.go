@@ -0,0 +1,100 @@
+// Package script implements the small embedded language of chain hook
+// scripts. It is a subset of Starlark cut down to what hooks need to reshape
+// data and decide conditions: assignments, if/elif/else and for loops over
+// None, booleans, ints, floats, strings, lists and dicts, plus a fixed set of
+// builtins. Scripts
+// cannot define functions, import modules or touch files, the network or
+// the environment, and each run is bounded in steps.
+package script
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ai-team/pkg/logger"
+)
+
+// Error is a compile or run time error in a script.
+type Error struct {
+	Script string
+	Line   int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.Script, e.Msg)
+	case e.Script == "":
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Script, e.Line, e.Msg)
+}
+
+// Program is a compiled script. It can be run any number of times, also
+// concurrently.
+type Program struct {
+	name string
+	body []stmt
+}
+
+// Compile parses src. name identifies the script in errors.
+func Compile(name, src string) (*Program, error) {
+	body, err := parse(src)
+	if err != nil {
+		return nil, withScript(name, err)
+	}
+	return &Program{name: name, body: body}, nil
+}
+
+// Name returns the name the program was compiled with.
+func (p *Program) Name() string {
+	return p.name
+}
+
+// Run executes the program with globals as its initial variables, converted
+// to script values. It returns the variables the script assigned, as plain
+// Go values, leaving out those it left equal to their initial value.
+func (p *Program) Run(ctx context.Context, globals map[string]interface{}) (map[string]interface{}, error) {
+	f := &frame{ctx: ctx, globals: make(map[string]Value, len(globals))}
+	f.print = &callable{name: "print", fn: func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = toString(a)
+		}
+		logger.From(ctx).Infof("[%s] %s", p.name, strings.Join(parts, " "))
+		return nil, nil
+	}}
+	for k, v := range globals {
+		f.globals[k] = toValue(v)
+	}
+	if _, err := f.execBlock(p.body); err != nil {
+		return nil, withScript(p.name, err)
+	}
+
+	changed := map[string]interface{}{}
+	for k, v := range f.globals {
+		if _, ok := v.(*callable); ok {
+			continue
+		}
+		if orig, ok := globals[k]; ok && equal(toValue(orig), v) {
+			continue
+		}
+		changed[k] = fromValue(v)
+	}
+	return changed, nil
+}
+
+// Truth reports whether v counts as true in a script condition.
+func Truth(v interface{}) bool {
+	return truth(toValue(v))
+}
+
+func withScript(name string, err error) error {
+	if e, ok := err.(*Error); ok {
+		e.Script = name
+		return e
+	}
+	return &Error{Script: name, Msg: err.Error()}
+}
@@ -0,0 +1,132 @@
+package script
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, src string, globals map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	prog, err := Compile("test", src)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	out, err := prog.Run(context.Background(), globals)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return out
+}
+
+func TestRun_Expressions(t *testing.T) {
+	for src, want := range map[string]interface{}{
+		"x = 1 + 2 * 3":                               7,
+		"x = 7 / 2":                                   3.5,
+		"x = 'ab' + 'c'":                              "abc",
+		"x = [1, 2] + [3]":                            []interface{}{1, 2, 3},
+		"x = 'b' in 'abc' and 3 not in [1, 2]":        true,
+		"x = 1 < 2 == True":                           true,
+		"x = None or 'default'":                       "default",
+		"x = 'hello'[-1] + 'hello'[1:3]":              "oel",
+		"x = {'a': 1, 'b': [True, None]}":             map[string]interface{}{"a": 1, "b": []interface{}{true, nil}},
+		"x = len('héllo')":                            5,
+		"x = ', '.join(['a', 'b'])":                   "a, b",
+		"x = ' A b '.strip().lower().split()":         []interface{}{"a", "b"},
+		"x = str([1, 'a'])":                           `[1,"a"]`,
+		"x = int('42') + int(2.9)":                    44,
+		"x = json_decode('{\"n\": 2, \"f\": 1.5}')":   map[string]interface{}{"n": 2, "f": 1.5},
+		"x = json_encode({'a': [1]})":                 `{"a":[1]}`,
+		"x = matches('^v[0-9]+$', 'v12')":             true,
+		"x = find_all('#([0-9]+)', 'fix #12 and #7')": []interface{}{"12", "7"},
+		"x = {'a': 1}.keys() + {'a': 1}.values()":     []interface{}{"a", 1},
+	} {
+		out := run(t, src, nil)
+		if !reflect.DeepEqual(out["x"], want) {
+			t.Errorf("%s: x = %#v, want %#v", src, out["x"], want)
+		}
+	}
+}
+
+func TestRun_Statements(t *testing.T) {
+	src := `
+# Count words, skipping short ones.
+counts = {}
+for word in text.split():
+    if len(word) < 3:
+        continue
+    elif word == "stop":
+        break
+    else:
+        counts[word] = counts.get(word, 0) + 1
+total = 0
+for n in counts.values():
+    total += n
+items.append("more")
+`
+	out := run(t, src, map[string]interface{}{
+		"text":  "the cat and the dog a cat stop cat",
+		"items": []string{"one"},
+		"same":  map[string]interface{}{"k": 1},
+	})
+	want := map[string]interface{}{
+		"counts": map[string]interface{}{"the": 2, "cat": 2, "and": 1, "dog": 1},
+		"total":  6,
+		"items":  []interface{}{"one", "more"},
+		"word":   "stop",
+		"n":      1,
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("changed globals = %#v, want %#v", out, want)
+	}
+}
+
+// spin loops over a string of 2048 characters in a nested loop, four million
+// steps in all.
+const spin = "s = 'ab'\nfor c in '0123456789':\n    s += s\nfor a in s:\n    for b in s:\n        pass"
+
+func TestRun_Errors(t *testing.T) {
+	for src, want := range map[string]string{
+		"x = missing":          "test:1: undefined: missing",
+		"x = 1\ny = 'a' + 1":   "test:2: unsupported operands string + int",
+		"x = {}['k']":          `key "k" not found`,
+		"fail('bad', 'input')": "fail: bad input",
+		"x = [1][3]":           "index 3 out of range",
+		spin:                   "exceeded",
+	} {
+		prog, err := Compile("test", src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		if _, err := prog.Run(context.Background(), nil); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want it to contain %q", src, err, want)
+		}
+	}
+
+	for src, want := range map[string]string{
+		"if x\n    y = 1":    "test:1: expected :",
+		"x = (1,":            "test:1:",
+		"if x:\ny = 1":       "test:2: expected an indented block",
+		"x = 'open":          "unterminated string",
+		"def f():\n    pass": "test:1:",
+		"x = [n for n in y]": "test:1: expected ]",
+		"x = 7 // 2":         "test:1: unexpected '/'",
+	} {
+		if _, err := Compile("test", src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Compile(%q) error = %v, want it to contain %q", src, err, want)
+		}
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	prog, err := Compile("spin", spin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := prog.Run(ctx, nil); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("error = %v, want a cancellation", err)
+	}
+}
@@ -0,0 +1,301 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value is a script value: nil (None), bool, int, float64, string, *List,
+// *Dict or a callable.
+type Value interface{}
+
+// List is a mutable script list.
+type List struct {
+	elems []Value
+}
+
+// Dict is a mutable script dict with string keys, iterated in insertion
+// order.
+type Dict struct {
+	keys []string
+	m    map[string]Value
+}
+
+func newDict() *Dict {
+	return &Dict{m: map[string]Value{}}
+}
+
+func (d *Dict) get(key string) (Value, bool) {
+	v, ok := d.m[key]
+	return v, ok
+}
+
+func (d *Dict) set(key string, v Value) {
+	if _, ok := d.m[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.m[key] = v
+}
+
+// callable is a builtin function or a method bound to its receiver.
+type callable struct {
+	name string
+	fn   func(args []Value) (Value, error)
+}
+
+// toValue converts a Go value, such as a chain context entry, to a script
+// value. Maps with string keys become dicts, ordered by key, and slices
+// become lists; other types go through their JSON form.
+func toValue(v interface{}) Value {
+	switch x := v.(type) {
+	case nil, bool, string, float64:
+		return x
+	case int:
+		return x
+	case int64:
+		return int(x)
+	case int32:
+		return int(x)
+	case float32:
+		return float64(x)
+	case []interface{}:
+		list := &List{elems: make([]Value, len(x))}
+		for i, e := range x {
+			list.elems[i] = toValue(e)
+		}
+		return list
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := newDict()
+		for _, k := range keys {
+			d.set(k, toValue(x[k]))
+		}
+		return d
+	case *List, *Dict:
+		return x
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := &List{elems: make([]Value, rv.Len())}
+		for i := range list.elems {
+			list.elems[i] = toValue(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			for _, k := range rv.MapKeys() {
+				m[k.String()] = rv.MapIndex(k).Interface()
+			}
+			return toValue(m)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Convert(reflect.TypeOf(0)).Int())
+	case reflect.String:
+		return rv.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return string(data)
+	}
+	return toValue(plain)
+}
+
+// fromValue converts a script value back to plain Go values:
+// map[string]interface{}, []interface{}, string, int, float64, bool or nil.
+func fromValue(v Value) interface{} {
+	switch x := v.(type) {
+	case *List:
+		out := make([]interface{}, len(x.elems))
+		for i, e := range x.elems {
+			out[i] = fromValue(e)
+		}
+		return out
+	case *Dict:
+		out := make(map[string]interface{}, len(x.keys))
+		for _, k := range x.keys {
+			out[k] = fromValue(x.m[k])
+		}
+		return out
+	case *callable:
+		return x.name
+	}
+	return v
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *List:
+		return "list"
+	case *Dict:
+		return "dict"
+	case *callable:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func truth(v Value) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case int:
+		return x != 0
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case *List:
+		return len(x.elems) > 0
+	case *Dict:
+		return len(x.keys) > 0
+	}
+	return true
+}
+
+// toString returns v as str() does: strings as they are, lists and dicts as
+// JSON, and None, True and False spelled as in the language.
+func toString(v Value) string {
+	switch x := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if x {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+			return strconv.FormatFloat(x, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case string:
+		return x
+	case *List, *Dict:
+		data, err := json.Marshal(fromValue(x))
+		if err != nil {
+			return fmt.Sprint(fromValue(x))
+		}
+		return string(data)
+	case *callable:
+		return "<function " + x.name + ">"
+	}
+	return fmt.Sprint(v)
+}
+
+// number returns v as a float64 when it is an int or a float.
+func number(v Value) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+func equal(a, b Value) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch x := a.(type) {
+	case *List:
+		y, ok := b.(*List)
+		if !ok || len(x.elems) != len(y.elems) {
+			return false
+		}
+		for i := range x.elems {
+			if !equal(x.elems[i], y.elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Dict:
+		y, ok := b.(*Dict)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for _, k := range x.keys {
+			other, ok := y.m[k]
+			if !ok || !equal(x.m[k], other) {
+				return false
+			}
+		}
+		return true
+	case *callable:
+		return a == b
+	}
+	return a == b
+}
+
+// compare orders two numbers or two strings.
+func compare(a, b Value) (int, error) {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", typeName(a), typeName(b))
+}
+
+// iterate returns the elements a for loop visits: the elements of a list, the
+// keys of a dict or the characters of a string.
+func iterate(v Value) ([]Value, error) {
+	switch x := v.(type) {
+	case *List:
+		return append([]Value(nil), x.elems...), nil
+	case *Dict:
+		out := make([]Value, len(x.keys))
+		for i, k := range x.keys {
+			out[i] = k
+		}
+		return out, nil
+	case string:
+		var out []Value
+		for _, r := range x {
+			out = append(out, string(r))
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+}
@@ -0,0 +1,381 @@
+// Package server exposes roles and chains over HTTP, so CI systems and web
+// UIs can run them without shelling out to the CLI.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/errors"
+	"ai-team/pkg/roles"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/tools"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Server serves the roles and chains of a configuration:
+//
+//	GET  /config         roles, chains and providers
+//	POST /roles/{name}   run a role
+//	POST /chains/{name}  run a chain
+//
+// Runs take a JSON body {"input": {...}, "labels": {...}} and answer with
+// JSON, or with server-sent events when the request accepts
+// text/event-stream. One run executes at a time; later requests wait.
+//
+// So that web pages cannot drive the server from the user's browser, runs
+// must be sent as application/json, requests from another origin are
+// rejected, and without a Token only loopback host names are accepted.
+type Server struct {
+	Config *config.Config
+	Store  *runs.Store // Chain run records and checkpoints; none kept when nil
+	// Policy, when set, decides which tool calls may run. Calls that need
+	// confirmation are denied, as nobody is there to confirm them.
+	Policy *tools.Policy
+	// Token, when set, must be sent as "Authorization: Bearer <token>". It is
+	// required to listen on addresses other than loopback.
+	Token string
+
+	run sync.Mutex
+}
+
+// RunRequest is the body of a role or chain request.
+type RunRequest struct {
+	Input  map[string]interface{} `json:"input"`
+	Labels map[string]string      `json:"labels,omitempty"` // Chain runs only
+}
+
+// ChainResponse is the outcome of a chain run.
+type ChainResponse struct {
+	RunID  string                 `json:"run_id"`
+	Status string                 `json:"status"`
+	Result map[string]interface{} `json:"result"`
+	Error  string                 `json:"error,omitempty"`
+	Timing runs.Timing            `json:"timing"`
+	Cost   *types.CostSummary     `json:"cost,omitempty"`
+}
+
+// Handler returns the HTTP handler of the server's endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", s.handleConfig)
+	mux.HandleFunc("POST /roles/{name}", s.handleRole)
+	mux.HandleFunc("POST /chains/{name}", s.handleChain)
+	return s.authorize(s.guard(mux))
+}
+
+// ListenAndServe serves the endpoints on addr until ctx is done.
+// It refuses addresses other than loopback when no Token is set.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if s.Token == "" && !IsLoopback(addr) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("refusing to serve on %s without a token; anyone reaching it could run the configured tools", addr), nil)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.New(errors.ErrCodeUnknown, fmt.Sprintf("failed to listen on %s", addr), err)
+	}
+	srv := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	logrus.Infof("Serving roles and chains at http://%s/", listener.Addr())
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return errors.New(errors.ErrCodeUnknown, "server stopped", err)
+	}
+	return nil
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guard rejects requests a web page could send from the user's browser:
+// those with a foreign Origin, those to a host name other than loopback when
+// no token is set (DNS rebinding), and POSTs without a JSON Content-Type,
+// which browsers send cross-origin without a CORS preflight.
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !sameOrigin(origin, r.Host) {
+			writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+			return
+		}
+		if s.Token == "" && !IsLoopback(r.Host) {
+			writeError(w, http.StatusForbidden, "without a token, requests must be addressed to localhost or a loopback IP")
+			return
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether origin names the host the request was sent to.
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
+
+// IsLoopback reports whether addr, a host with an optional port, is
+// localhost or a loopback IP.
+func IsLoopback(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// configView is the part of the configuration GET /config lists; API keys
+// and URLs are left out.
+type configView struct {
+	Roles     map[string]roleView  `json:"roles"`
+	Chains    map[string]chainView `json:"chains"`
+	Providers []string             `json:"providers"`
+}
+
+type roleView struct {
+	Provider string      `json:"provider"`
+	Model    string      `json:"model"`
+	Inputs   []inputView `json:"inputs,omitempty"`
+}
+
+type inputView struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Example     string `json:"example,omitempty"`
+}
+
+type chainView struct {
+	Steps []stepView `json:"steps"`
+}
+
+type stepView struct {
+	Name string `json:"name,omitempty"`
+	Role string `json:"role"`
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	view := configView{Roles: map[string]roleView{}, Chains: map[string]chainView{}, Providers: ai.Providers()}
+	for name, role := range s.Config.Roles {
+		rv := roleView{Provider: role.Provider, Model: role.Model}
+		for _, in := range role.Inputs {
+			rv.Inputs = append(rv.Inputs, inputView(in))
+		}
+		view.Roles[name] = rv
+	}
+	for name, chain := range s.Config.Chains {
+		cv := chainView{Steps: []stepView{}}
+		for _, step := range chain.Steps {
+			cv.Steps = append(cv.Steps, stepView{Name: step.Name, Role: step.Role})
+		}
+		view.Chains[name] = cv
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (s *Server) handleRole(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	role, ok := s.Config.Roles[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("role '%s' not found in config", name))
+		return
+	}
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	input, err := roles.ResolveInputs(role, req.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorMessage(err))
+		return
+	}
+
+	ctx := r.Context()
+	var events *eventStream
+	if acceptsEvents(r) {
+		events = newEventStream(w)
+		ctx = ai.WithStream(ctx, func(text string) {
+			events.Send("text", map[string]string{"text": text})
+		})
+	}
+	s.run.Lock()
+	result, err := roles.RunRoleContext(ctx, role, input, s.Config, s.Config.LogFilePath)
+	s.run.Unlock()
+
+	if events != nil {
+		if err != nil {
+			events.Send("error", map[string]string{"error": errorMessage(err)})
+		}
+		events.Send("result", result)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleChain(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	chain, ok := s.Config.Chains[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("role chain '%s' not found in config", name))
+		return
+	}
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+
+	run := runs.NewRecord(name, input)
+	run.Labels = req.Labels
+	opts := roles.ChainOptions{
+		LogFilePath: s.Config.LogFilePath,
+		Run:         run,
+		Store:       s.Store,
+		Policy:      s.Policy,
+	}
+	if s.Store != nil {
+		opts.Checkpoint = s.Store.CheckpointPath(run.ID)
+	}
+	var events *eventStream
+	if acceptsEvents(r) {
+		events = newEventStream(w)
+		events.Send("run", map[string]string{"run_id": run.ID, "chain": name})
+		opts.MetricsHook = func(event string, fields map[string]interface{}) {
+			events.Send(event, fields)
+		}
+	}
+
+	s.run.Lock()
+	result, err := roles.ExecuteChainWithOptions(chain, input, s.Config, opts)
+	s.run.Unlock()
+
+	resp := ChainResponse{
+		RunID:  run.ID,
+		Status: run.Status,
+		Result: result,
+		Error:  run.Error,
+		Timing: run.ComputeTiming(),
+		Cost:   run.Cost,
+	}
+	if err != nil && resp.Error == "" {
+		resp.Error = errorMessage(err)
+	}
+	if events != nil {
+		events.Send("result", resp)
+		return
+	}
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, resp)
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request) (RunRequest, bool) {
+	var req RunRequest
+	if r.ContentLength == 0 {
+		return req, true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return req, false
+	}
+	return req, true
+}
+
+func acceptsEvents(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// errorMessage returns the message of err, with its cause for ai-team errors.
+func errorMessage(err error) string {
+	if e, ok := err.(*errors.Error); ok && e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	} else if ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// eventStream writes server-sent events. Send may be called from several
+// goroutines, e.g. for heartbeat events.
+type eventStream struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w}
+}
+
+// Send writes an event named event with data encoded as JSON.
+func (e *eventStream) Send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logrus.Warnf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload)
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-team/config"
+	"ai-team/pkg/ai"
+	"ai-team/pkg/roles"
+	"ai-team/pkg/runs"
+	"ai-team/pkg/types"
+)
+
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	orig := ai.CallGeminiFunc
+	ai.CallGeminiFunc = func(_ *http.Client, prompt, _, _, _ string, _ []types.ConfigurableTool, _ ai.Generation) (string, error) {
+		return "echo: " + prompt, nil
+	}
+	t.Cleanup(func() { ai.CallGeminiFunc = orig })
+
+	cfg := &config.Config{}
+	cfg.Gemini.Apiurl = "http://mock-gemini"
+	cfg.Gemini.Apikey = "secret"
+	cfg.Gemini.Models = map[string]config.ModelConfig{"flash": {Model: "flash"}}
+	cfg.Roles = map[string]types.Role{
+		"echo": {Provider: "gemini", Model: "flash", Prompt: "say {{.text}}", Inputs: []types.RoleInput{{Name: "text", Required: true}}},
+	}
+	cfg.Chains = map[string]types.RoleChain{
+		"greet": {Steps: []types.ChainRole{{Name: "hello", Role: "echo", Input: map[string]interface{}{"text": "{{.name}}"}, OutputKey: "greeting"}}},
+	}
+	return &Server{Config: cfg, Store: runs.NewStore(t.TempDir())}
+}
+
+func do(t *testing.T, s *Server, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Host = "127.0.0.1:8080"
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		if k == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Config(t *testing.T) {
+	s := testServer(t)
+	rec := do(t, s, "GET", "/config", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("config listing leaks the API key: %s", rec.Body)
+	}
+	var view configView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Roles["echo"].Model != "flash" || len(view.Roles["echo"].Inputs) != 1 {
+		t.Errorf("roles = %+v", view.Roles)
+	}
+	if steps := view.Chains["greet"].Steps; len(steps) != 1 || steps[0].Role != "echo" {
+		t.Errorf("chains = %+v", view.Chains)
+	}
+}
+
+func TestServer_Role(t *testing.T) {
+	s := testServer(t)
+	rec := do(t, s, "POST", "/roles/echo", `{"input": {"text": "hi"}}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var result roles.RoleResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Text, "say hi") {
+		t.Errorf("text = %q", result.Text)
+	}
+
+	if rec := do(t, s, "POST", "/roles/echo", `{"input": {}}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing required input: status = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, "POST", "/roles/nope", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown role: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestServer_Chain(t *testing.T) {
+	s := testServer(t)
+	rec := do(t, s, "POST", "/chains/greet", `{"input": {"name": "Ada"}, "labels": {"ci": "yes"}}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp ChainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != runs.StatusSuccess || resp.RunID == "" {
+		t.Errorf("response = %+v", resp)
+	}
+	if got, _ := resp.Result["greeting"].(string); !strings.Contains(got, "say Ada") {
+		t.Errorf("greeting = %v", resp.Result["greeting"])
+	}
+	record, err := s.Store.Load(resp.RunID)
+	if err != nil {
+		t.Fatalf("run record not stored: %v", err)
+	}
+	if record.Labels["ci"] != "yes" {
+		t.Errorf("labels = %v", record.Labels)
+	}
+}
+
+func TestServer_ChainEvents(t *testing.T) {
+	s := testServer(t)
+	rec := do(t, s, "POST", "/chains/greet", `{"input": {"name": "Ada"}}`, map[string]string{"Accept": "text/event-stream"})
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+	body := rec.Body.String()
+	run := strings.Index(body, "event: run\n")
+	step := strings.Index(body, "event: step_iteration\n")
+	result := strings.Index(body, "event: result\n")
+	if run < 0 || step < run || result < step {
+		t.Errorf("events out of order or missing:\n%s", body)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	s := testServer(t)
+	s.Token = "t0ken"
+	if rec := do(t, s, "GET", "/config", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, s, "GET", "/config", "", map[string]string{"Authorization": "Bearer t0ken"}); rec.Code != http.StatusOK {
+		t.Errorf("with token: status = %d, want 200", rec.Code)
+	}
+	if rec := do(t, s, "GET", "/config", "", map[string]string{"Authorization": "Bearer t0ke"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("with a token prefix: status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, s, "GET", "/config", "", map[string]string{"Authorization": "Bearer t0ken", "Host": "ci-runner:8080"}); rec.Code != http.StatusOK {
+		t.Errorf("with token on another host: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestServer_RejectsBrowserRequests(t *testing.T) {
+	s := testServer(t)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"text/plain body", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"form body", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"null origin", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"rebound host", map[string]string{"Host": "evil.example:8080"}, http.StatusForbidden},
+		{"same origin", map[string]string{"Origin": "http://127.0.0.1:8080", "Content-Type": "application/json; charset=utf-8"}, http.StatusOK},
+		{"localhost", map[string]string{"Host": "localhost:8080"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(t, s, "POST", "/chains/greet", `{"input": {"name": "Ada"}}`, tt.header); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServer_ListenRequiresToken(t *testing.T) {
+	s := testServer(t)
+	if err := s.ListenAndServe(context.Background(), "0.0.0.0:0"); err == nil || !strings.Contains(err.Error(), "without a token") {
+		t.Errorf("expected a refusal to serve on all interfaces without a token, got %v", err)
+	}
+	for addr, want := range map[string]bool{"127.0.0.1:8080": true, "[::1]:80": true, "localhost:1": true, ":8080": false, "0.0.0.0:8080": false, "10.0.0.5:8080": false, "127.0.0.1": true} {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
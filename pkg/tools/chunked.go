@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"ai-team/pkg/errors"
+)
+
+// chunkTailLength is how much of a pending file's end is echoed back so the
+// model can continue where it stopped.
+const chunkTailLength = 200
+
+// ChunkedFiles assembles files written over several tool calls with
+// begin_file, append_file and end_file. Content is staged in a temporary file
+// next to the target and moved into place by end_file.
+type ChunkedFiles struct {
+	mu      sync.Mutex
+	pending map[string]*pendingFile
+}
+
+type pendingFile struct {
+	temp   *os.File
+	bytes  int
+	chunks int
+	tail   string
+}
+
+// NewChunkedFiles returns an empty set of pending files.
+func NewChunkedFiles() *ChunkedFiles {
+	return &ChunkedFiles{pending: map[string]*pendingFile{}}
+}
+
+// Begin starts (or restarts) a pending file with an optional first chunk.
+func (c *ChunkedFiles) Begin(filePath, content string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pending[filePath]; ok {
+		p.discard()
+		delete(c.pending, filePath)
+	}
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to create parent directory %s", dir), err)
+	}
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".partial-*")
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to start chunked write of %s", filePath), err)
+	}
+	p := &pendingFile{temp: temp}
+	c.pending[filePath] = p
+	if content != "" {
+		if err := p.append(content); err != nil {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to write chunk of %s", filePath), err)
+		}
+	}
+	return p.status(filePath), nil
+}
+
+// Append adds a chunk to a pending file.
+func (c *ChunkedFiles) Append(filePath, content string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[filePath]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("no chunked write in progress for %s; call begin_file first", filePath), nil)
+	}
+	if err := p.append(content); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to write chunk of %s", filePath), err)
+	}
+	return p.status(filePath), nil
+}
+
+// End appends an optional last chunk and moves the assembled file into place.
+func (c *ChunkedFiles) End(filePath, content string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[filePath]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("no chunked write in progress for %s; call begin_file first", filePath), nil)
+	}
+	if content != "" {
+		if err := p.append(content); err != nil {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to write chunk of %s", filePath), err)
+		}
+	}
+	delete(c.pending, filePath)
+	if err := p.temp.Close(); err != nil {
+		os.Remove(p.temp.Name())
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to finish chunked write of %s", filePath), err)
+	}
+	if err := os.Chmod(p.temp.Name(), 0644); err != nil {
+		os.Remove(p.temp.Name())
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to finish chunked write of %s", filePath), err)
+	}
+	if err := os.Rename(p.temp.Name(), filePath); err != nil {
+		os.Remove(p.temp.Name())
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to move assembled file to %s", filePath), err)
+	}
+	return map[string]interface{}{
+		"file_path": filePath,
+		"bytes":     p.bytes,
+		"chunks":    p.chunks,
+		"pending":   false,
+	}, nil
+}
+
+// Pending lists files with a chunked write in progress.
+func (c *ChunkedFiles) Pending() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := make([]string, 0, len(c.pending))
+	for path := range c.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Abort discards all pending files and returns their paths.
+func (c *ChunkedFiles) Abort() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var paths []string
+	for path, p := range c.pending {
+		p.discard()
+		paths = append(paths, path)
+	}
+	c.pending = map[string]*pendingFile{}
+	sort.Strings(paths)
+	return paths
+}
+
+func (p *pendingFile) append(content string) error {
+	if _, err := p.temp.WriteString(content); err != nil {
+		return err
+	}
+	p.bytes += len(content)
+	p.chunks++
+	p.tail += content
+	if len(p.tail) > chunkTailLength {
+		p.tail = p.tail[len(p.tail)-chunkTailLength:]
+	}
+	return nil
+}
+
+func (p *pendingFile) status(filePath string) map[string]interface{} {
+	return map[string]interface{}{
+		"file_path": filePath,
+		"bytes":     p.bytes,
+		"chunks":    p.chunks,
+		"pending":   true,
+		"tail":      p.tail,
+	}
+}
+
+func (p *pendingFile) discard() {
+	p.temp.Close()
+	os.Remove(p.temp.Name())
+}
+
+// ChunkTool implements begin_file, append_file and end_file on a shared ChunkedFiles.
+type ChunkTool struct {
+	Files *ChunkedFiles
+	Op    string // "begin", "append" or "end"
+}
+
+func (t *ChunkTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if err := checkContext(ctx, t.Op+"_file"); err != nil {
+		return nil, err
+	}
+	filePath, _, err := targetPath(t.Op+"_file", args)
+	if err != nil {
+		return nil, err
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("invalid arguments for %s_file: file_path required", t.Op)
+	}
+	content, _, err := aliasedArg(args, "content")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for %s_file: %v", t.Op, err)
+	}
+	switch t.Op {
+	case "begin":
+		return t.Files.Begin(filePath, content)
+	case "append":
+		return t.Files.Append(filePath, content)
+	default:
+		return t.Files.End(filePath, content)
+	}
+}
+
+// registerChunkTools registers begin_file, append_file and end_file sharing files.
+func registerChunkTools(reg *ToolRegistry, files *ChunkedFiles) {
+	reg.RegisterTool(ToolSchema{
+		Name:        "begin_file",
+		Description: "Starts writing a large file in chunks. Follow with append_file calls and finish with end_file; the file is only written by end_file.",
+		Arguments: []ToolArgument{
+			{Name: "file_path", Type: "string", Required: true, Description: "Path to the file to write."},
+			{Name: "content", Type: "string", Required: false, Description: "First chunk of content."},
+		},
+	}, &ChunkTool{Files: files, Op: "begin"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "append_file",
+		Description: "Appends the next chunk to a file started with begin_file.",
+		Arguments: []ToolArgument{
+			{Name: "file_path", Type: "string", Required: true, Description: "Path given to begin_file."},
+			{Name: "content", Type: "string", Required: true, Description: "Next chunk of content."},
+		},
+	}, &ChunkTool{Files: files, Op: "append"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "end_file",
+		Description: "Appends an optional last chunk and writes the assembled file started with begin_file.",
+		Arguments: []ToolArgument{
+			{Name: "file_path", Type: "string", Required: true, Description: "Path given to begin_file."},
+			{Name: "content", Type: "string", Required: false, Description: "Last chunk of content."},
+		},
+	}, &ChunkTool{Files: files, Op: "end"})
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkTools_AssembleFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "sub", "big.txt")
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	te := &ToolExecutor{Registry: reg}
+
+	steps := []ToolCall{
+		{Name: "begin_file", Arguments: map[string]interface{}{"file_path": target, "content": "one\n"}},
+		{Name: "append_file", Arguments: map[string]interface{}{"file_path": target, "content": "two\n"}},
+	}
+	for _, call := range steps {
+		result, err := te.Execute(context.Background(), call)
+		if err != nil {
+			t.Fatalf("%s failed: %v", call.Name, err)
+		}
+		if result.(map[string]interface{})["pending"] != true {
+			t.Fatalf("expected pending status from %s, got %v", call.Name, result)
+		}
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected target not to exist before end_file, stat err: %v", err)
+	}
+	if pending := reg.ChunkedFiles().Pending(); len(pending) != 1 || pending[0] != target {
+		t.Fatalf("unexpected pending files: %v", pending)
+	}
+
+	result, err := te.Execute(context.Background(), ToolCall{Name: "end_file", Arguments: map[string]interface{}{"file_path": target, "content": "three\n"}})
+	if err != nil {
+		t.Fatalf("end_file failed: %v", err)
+	}
+	status := result.(map[string]interface{})
+	if status["chunks"] != 3 || status["bytes"] != 14 || status["pending"] != false {
+		t.Errorf("unexpected end status: %v", status)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "one\ntwo\nthree\n" {
+		t.Fatalf("unexpected assembled file %q, %v", data, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(target))
+	if len(entries) != 1 {
+		t.Errorf("expected staging file to be gone, found %d entries", len(entries))
+	}
+}
+
+func TestChunkedFiles_AppendWithoutBegin(t *testing.T) {
+	files := NewChunkedFiles()
+	if _, err := files.Append(filepath.Join(t.TempDir(), "x.txt"), "data"); err == nil {
+		t.Fatal("expected error appending without begin_file")
+	}
+}
+
+func TestChunkedFiles_AbortRemovesStaging(t *testing.T) {
+	dir := t.TempDir()
+	files := NewChunkedFiles()
+	target := filepath.Join(dir, "a.txt")
+	if _, err := files.Begin(target, "partial"); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if aborted := files.Abort(); len(aborted) != 1 || aborted[0] != target {
+		t.Fatalf("unexpected aborted files: %v", aborted)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected no files after abort, found %d", len(entries))
+	}
+}
@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandTemplate is the parsed command_template of a configurable tool. It
+// renders to a shell command in which every argument value is quoted, so
+// model-supplied arguments cannot inject shell syntax.
+type CommandTemplate struct {
+	tool      string
+	arguments []types.ToolArgument
+	tmpl      *template.Template
+}
+
+// ParseCommandTemplate parses tool's command_template. References such as
+// {{.path}} must name declared arguments.
+func ParseCommandTemplate(tool types.ConfigurableTool) (*CommandTemplate, error) {
+	tmpl, err := template.New(tool.Name).Option("missingkey=error").Parse(tool.CommandTemplate)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' has an invalid command_template", tool.Name), err)
+	}
+	declared := map[string]bool{}
+	for _, arg := range tool.Arguments {
+		declared[arg.Name] = true
+	}
+	var undeclared []string
+	for name := range templateFields(tmpl) {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("tool '%s' command_template references undeclared arguments: %s", tool.Name, strings.Join(undeclared, ", ")), nil)
+	}
+	return &CommandTemplate{tool: tool.Name, arguments: tool.Arguments, tmpl: tmpl}, nil
+}
+
+// Render returns the command for args. Each value is shell-quoted; a list
+// renders as its quoted elements separated by spaces, an object as quoted
+// JSON, and a declared argument that is missing renders as an empty quoted
+// string.
+func (c *CommandTemplate) Render(args map[string]interface{}) (string, error) {
+	data := make(map[string]string, len(c.arguments))
+	for _, arg := range c.arguments {
+		data[arg.Name] = quoteArg(args[arg.Name])
+	}
+	var b strings.Builder
+	if err := c.tmpl.Execute(&b, data); err != nil {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to render command for tool %s", c.tool), err)
+	}
+	return b.String(), nil
+}
+
+func quoteArg(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "''"
+	case string:
+		return ShellQuote(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = quoteArg(e)
+		}
+		return strings.Join(parts, " ")
+	case []string:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = ShellQuote(e)
+		}
+		return strings.Join(parts, " ")
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ShellQuote(fmt.Sprint(v))
+		}
+		return ShellQuote(string(data))
+	default:
+		return ShellQuote(fmt.Sprint(v))
+	}
+}
+
+// shellSafe matches words that need no quoting.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote returns s as a single shell word.
+func ShellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// templateFields returns the top-level fields referenced by tmpl, e.g. "path"
+// for {{.path}} or {{$.path}}.
+func templateFields(tmpl *template.Template) map[string]bool {
+	fields := map[string]bool{}
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.FieldNode:
+			fields[n.Ident[0]] = true
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields[n.Ident[1]] = true
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	return fields
+}
+
+// ConfigurableCommandTool runs a tool of the config's tools section: its
+// command_template is rendered with the call's arguments and the command is
+// run like run_command, so the sandbox and environment of the context apply.
+type ConfigurableCommandTool struct {
+	template  *CommandTemplate
+	arguments []types.ToolArgument
+}
+
+// NewConfigurableCommandTool parses the command_template of tool.
+func NewConfigurableCommandTool(tool types.ConfigurableTool) (*ConfigurableCommandTool, error) {
+	tmpl, err := ParseCommandTemplate(tool)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigurableCommandTool{template: tmpl, arguments: tool.Arguments}, nil
+}
+
+// Execute renders and runs the command, killing it when ctx is done.
+func (t *ConfigurableCommandTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	command, err := t.Command(args)
+	if err != nil {
+		return nil, err
+	}
+	return RunCommandContext(ctx, command)
+}
+
+// Command returns the command for args. Arguments are matched to the
+// declared ones like in validation, so file_path also sets filePath, and
+// missing ones take their default.
+func (t *ConfigurableCommandTool) Command(args map[string]interface{}) (string, error) {
+	declared := make(map[string]interface{}, len(t.arguments))
+	for _, arg := range t.arguments {
+		if v, ok := lookupArgFlexible(args, arg.Name); ok {
+			declared[arg.Name] = v
+		} else if arg.Default != nil {
+			declared[arg.Name] = arg.Default
+		}
+	}
+	return t.template.Render(declared)
+}
+
+// configToolNames returns the names a config tool is registered under: its
+// own name and, when different, the snake_case name tool calls are
+// normalized to.
+func configToolNames(name string) []string {
+	if snake := toSnakeCase(name); snake != name {
+		return []string{name, snake}
+	}
+	return []string{name}
+}
+
+// RegisterConfigTools registers the tools of the config's tools section in
+// reg, running their command_template or calling their endpoint. A tool
+// named like one already in reg in any case, such as run_command
+// listed only to describe the built-in RunCommand, leaves that tool in place.
+// Tools whose template does not parse are skipped and reported in the
+// returned error; the others are still registered.
+func RegisterConfigTools(reg *ToolRegistry, configTools []types.ConfigurableTool) error {
+	registered := map[string]bool{}
+	for name := range reg.tools {
+		registered[toolNameKey(name)] = true
+	}
+	var failed []string
+	var firstErr error
+	for _, tool := range configTools {
+		if registered[toolNameKey(tool.Name)] {
+			logrus.Debugf("Config tool %s names a registered tool; keeping the registered tool", tool.Name)
+			continue
+		}
+		var impl Tool
+		if tool.Endpoint != "" {
+			impl = NewEndpointTool(tool)
+		} else if commandTool, err := NewConfigurableCommandTool(tool); err != nil {
+			failed = append(failed, tool.Name)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		} else {
+			impl = commandTool
+		}
+		args := make([]ToolArgument, len(tool.Arguments))
+		for i, arg := range tool.Arguments {
+			args[i] = ToolArgument{Name: arg.Name, Type: configArgType(arg.Type), Required: arg.Required, Description: arg.Description, Schema: ConfigArgumentSchema(arg)}
+		}
+		for _, name := range configToolNames(tool.Name) {
+			reg.RegisterTool(ToolSchema{Name: name, Description: tool.Description, Arguments: args}, impl)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(errors.ErrCodeConfig, "config tools not registered: "+strings.Join(failed, ", "), firstErr)
+	}
+	return nil
+}
+
+// toolNameKey returns name without case and underscores, so that
+// read_file, ReadFile and readFile have the same key.
+func toolNameKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// configArgType maps the type of a config tool argument to the argument
+// types of the registry.
+func configArgType(t string) string {
+	switch strings.ToLower(t) {
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	}
+	return strings.ToLower(t)
+}
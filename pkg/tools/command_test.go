@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestCommandTemplate_QuotesArguments(t *testing.T) {
+	tmpl, err := ParseCommandTemplate(types.ConfigurableTool{
+		Name:            "grep",
+		CommandTemplate: "grep -rn {{.pattern}} {{.paths}}",
+		Arguments:       []types.ToolArgument{{Name: "pattern", Type: "string"}, {Name: "paths", Type: "array"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := tmpl.Render(map[string]interface{}{
+		"pattern": "it's; rm -rf /",
+		"paths":   []interface{}{"src", "my dir"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `grep -rn 'it'\''s; rm -rf /' src 'my dir'`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	out, err := RunCommand("echo " + ShellQuote("$(echo injected) `id` 'x'"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out) != "$(echo injected) `id` 'x'" {
+		t.Errorf("expected the quoted value to reach the command verbatim, got %q", out)
+	}
+
+	if got, _ := tmpl.Render(map[string]interface{}{"pattern": "TODO"}); got != "grep -rn TODO ''" {
+		t.Errorf("expected a missing argument to render as '', got %q", got)
+	}
+}
+
+func TestParseCommandTemplate_UndeclaredArguments(t *testing.T) {
+	tool := types.ConfigurableTool{
+		Name:            "deploy",
+		CommandTemplate: "deploy {{.env}} {{if .force}}--force{{end}} {{$.region}}",
+		Arguments:       []types.ToolArgument{{Name: "env", Type: "string"}},
+	}
+	_, err := ParseCommandTemplate(tool)
+	if err == nil || !strings.Contains(err.Error(), "force, region") {
+		t.Fatalf("expected undeclared force and region, got %v", err)
+	}
+	tool.CommandTemplate = "deploy {{.env"
+	if _, err := ParseCommandTemplate(tool); err == nil {
+		t.Fatal("expected error for an invalid template")
+	}
+}
+
+func TestRegisterConfigTools(t *testing.T) {
+	workdir := t.TempDir()
+	os.WriteFile(filepath.Join(workdir, "notes.txt"), []byte("TODO: ship\n"), 0644)
+	s, err := NewSandbox(types.SandboxConfig{Enabled: true, Workdir: workdir, Allow: []string{`^grep\b`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exec := &ToolExecutor{Registry: NewToolRegistry(), Sandbox: s}
+	RegisterDefaultTools(exec.Registry)
+	err = RegisterConfigTools(exec.Registry, []types.ConfigurableTool{
+		{Name: "searchCode", Description: "Searches files.", CommandTemplate: "grep -rn -- {{.pattern}} {{.paths}}", Arguments: []types.ToolArgument{{Name: "pattern", Type: "string"}, {Name: "paths", Type: "array"}}},
+		{Name: "count_lines", CommandTemplate: "wc -l {{.path}}", Arguments: []types.ToolArgument{{Name: "path", Type: "string"}}},
+		{Name: "broken", CommandTemplate: "echo {{.missing}}"},
+		{Name: "run_command", CommandTemplate: "echo shadowed"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the invalid tool reported, got %v", err)
+	}
+	if _, ok := exec.Registry.GetToolSchema("search_code"); !ok {
+		t.Fatal("expected the tool registered under its snake_case name")
+	}
+	if _, ok := exec.Registry.GetToolImpl("run_command"); ok {
+		t.Error("expected run_command left to the built-in RunCommand")
+	}
+
+	result, err := exec.Execute(context.Background(), ToolCall{Name: "search_code", Arguments: map[string]interface{}{"pattern": "TODO", "paths": []interface{}{"."}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out := result.(string); !strings.Contains(out, "notes.txt:1:TODO: ship") {
+		t.Errorf("expected the grep output from the sandbox workdir, got %q", out)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "count_lines", Arguments: map[string]interface{}{"path": "notes.txt"}}); err == nil || !strings.Contains(err.Error(), "refused by sandbox") {
+		t.Errorf("expected the rendered command checked by the sandbox, got %v", err)
+	}
+}
+
+func TestConfigurableCommandTool_Defaults(t *testing.T) {
+	tool, err := NewConfigurableCommandTool(types.ConfigurableTool{
+		Name:            "tail_log",
+		CommandTemplate: "tail -n {{.lines}} {{.file}}",
+		Arguments:       []types.ToolArgument{{Name: "file", Type: "string"}, {Name: "lines", Type: "int", Default: 20}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := tool.Command(map[string]interface{}{"file": "app.log"}); got != "tail -n 20 app.log" {
+		t.Errorf("expected the default for a missing argument, got %q", got)
+	}
+	if got, _ := tool.Command(map[string]interface{}{"file": "app.log", "lines": 5}); got != "tail -n 5 app.log" {
+		t.Errorf("expected the given argument, got %q", got)
+	}
+}
+
+func TestRegisterConfigTools_Endpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var args map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &args); err != nil || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if args["city"] == "nowhere" {
+			http.Error(w, "unknown city", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	exec := &ToolExecutor{Registry: NewToolRegistry()}
+	if err := RegisterConfigTools(exec.Registry, []types.ConfigurableTool{{
+		Name:      "get_weather",
+		Endpoint:  server.URL + "/get_weather",
+		Arguments: []types.ToolArgument{{Name: "city", Type: "string"}, {Name: "unit", Type: "string", Default: "celsius"}},
+	}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := exec.Execute(context.Background(), ToolCall{Name: "get_weather", Arguments: map[string]interface{}{"City": "Berlin", "extra": true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.(string); got != `{"city":"Berlin","unit":"celsius"}` {
+		t.Errorf("expected the declared arguments with defaults posted, got %s", got)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "get_weather", Arguments: map[string]interface{}{"city": "nowhere"}}); err == nil || !strings.Contains(err.Error(), "unknown city") {
+		t.Errorf("expected the error status with its body, got %v", err)
+	}
+}
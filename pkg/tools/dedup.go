@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/cache"
+)
+
+// DedupCache remembers the results of executed tool calls so that identical
+// repeated calls (same tool name and arguments) can be skipped. Calls of
+// mutating tools always run, unless Mutating is set, and clear the results
+// they may have changed (see Invalidate).
+type DedupCache struct {
+	// AllowRepeat holds snake_case tool names that are always executed, even when repeated.
+	AllowRepeat map[string]bool
+
+	// Mutating also skips repeated calls of mutating tools.
+	Mutating bool
+
+	// Similar, when set, also catches calls that are close to, but not exactly
+	// the same as, an earlier call (see LookupSimilar).
+	Similar *SimilarCalls
+
+	mu      sync.Mutex
+	results map[string]dedupEntry
+}
+
+type dedupEntry struct {
+	path   string // Path argument of the call, "" when it has none
+	result interface{}
+}
+
+// SimilarCalls finds earlier calls of the same tool whose arguments have an
+// embedding within Threshold (cosine similarity) of a new call's arguments.
+// Only the tools in Tools are checked, since near-duplicate writes or commands
+// usually differ on purpose.
+type SimilarCalls struct {
+	Embedder  cache.Embedder
+	Threshold float64
+	Tools     map[string]bool // snake_case tool names
+
+	entries []similarEntry
+	pending map[string][]float32 // embeddings computed by LookupSimilar, by idempotency key
+}
+
+type similarEntry struct {
+	tool      string
+	path      string
+	arguments map[string]interface{}
+	result    interface{}
+	embedding []float32
+}
+
+// DefaultSimilarTools are the read-only tools checked for near-duplicate calls
+// when no list is configured.
+var DefaultSimilarTools = []string{"read_file", "list_dir"}
+
+// NewSimilarCalls returns an empty index for tools (DefaultSimilarTools when empty).
+func NewSimilarCalls(embedder cache.Embedder, threshold float64, tools []string) *SimilarCalls {
+	if len(tools) == 0 {
+		tools = DefaultSimilarTools
+	}
+	names := map[string]bool{}
+	for _, name := range tools {
+		names[toSnakeCase(name)] = true
+	}
+	return &SimilarCalls{Embedder: embedder, Threshold: threshold, Tools: names, pending: map[string][]float32{}}
+}
+
+// NewDedupCache creates an empty cache; tools in allowRepeat are never deduplicated.
+// The stateful chunked-write tools are always allowed to repeat.
+func NewDedupCache(allowRepeat []string) *DedupCache {
+	allow := map[string]bool{"begin_file": true, "append_file": true, "end_file": true}
+	for _, name := range allowRepeat {
+		allow[toSnakeCase(name)] = true
+	}
+	return &DedupCache{AllowRepeat: allow, results: map[string]dedupEntry{}}
+}
+
+// mutatingTools are the snake_case names of the built-in tools that change
+// files, the repository, documents or memory, or run commands.
+var mutatingTools = map[string]bool{
+	"write_file": true, "apply_patch": true, "begin_file": true, "append_file": true, "end_file": true,
+	"run_command": true, "git_commit": true, "git_checkout": true, "save_document": true, "remember": true,
+}
+
+// mutates reports whether call may change what other calls return: a call of
+// a mutating tool, a git_branch call creating a branch or an HTTP request
+// other than GET or HEAD.
+func mutates(call ToolCall) bool {
+	switch name := toSnakeCase(call.Name); name {
+	case "git_branch":
+		return stringArg(call.Arguments, "name") != ""
+	case "http_request":
+		switch strings.ToUpper(stringArg(call.Arguments, "method")) {
+		case "", "GET", "HEAD":
+			return false
+		}
+		return true
+	default:
+		return mutatingTools[name]
+	}
+}
+
+// IdempotencyKey returns a stable key for a tool call based on its snake_case
+// name and arguments, so WriteFile and write_file calls share keys.
+func IdempotencyKey(call ToolCall) string {
+	// encoding/json sorts map keys, so equal argument maps marshal identically
+	args, err := json.Marshal(call.Arguments)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(toSnakeCase(call.Name)+"\x00"), args...))
+	return hex.EncodeToString(sum[:])
+}
+
+// skips reports whether repeats of call may be skipped.
+func (d *DedupCache) skips(call ToolCall) bool {
+	return !d.AllowRepeat[toSnakeCase(call.Name)] && (d.Mutating || !mutates(call))
+}
+
+// Lookup returns the cached result of an identical earlier call.
+func (d *DedupCache) Lookup(call ToolCall) (interface{}, bool) {
+	if !d.skips(call) {
+		return nil, false
+	}
+	key := IdempotencyKey(call)
+	if key == "" {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.results[key]
+	return e.result, ok
+}
+
+// LookupSimilar returns a reminder holding the result of an earlier call that
+// is nearly identical to call, for the model to use instead of a fresh result.
+// Embedding failures are returned so callers can log them and run the call.
+func (d *DedupCache) LookupSimilar(call ToolCall) (interface{}, bool, error) {
+	s := d.Similar
+	name := toSnakeCase(call.Name)
+	if s == nil || !s.Tools[name] || !d.skips(call) {
+		return nil, false, nil
+	}
+	key := IdempotencyKey(call)
+	if key == "" {
+		return nil, false, nil
+	}
+	vec, err := s.Embedder.Embed(similarityText(name, call.Arguments))
+	if err != nil {
+		return nil, false, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var best *similarEntry
+	bestScore := -1.0
+	for i := range s.entries {
+		e := &s.entries[i]
+		if e.tool != name {
+			continue
+		}
+		if score := cache.CosineSimilarity(vec, e.embedding); score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil || bestScore < s.Threshold {
+		// Kept for Store to index once the call succeeds; forgetPending
+		// drops it when the call does not
+		s.pending[key] = vec
+		return nil, false, nil
+	}
+	return map[string]interface{}{
+		"deduplicated": true,
+		"reminder": fmt.Sprintf("This %s call is nearly identical (similarity %.2f) to an earlier one, so it was not run again. "+
+			"The earlier result is below; use it instead of asking again.", name, bestScore),
+		"previous_arguments": best.arguments,
+		"previous_result":    best.result,
+	}, true, nil
+}
+
+// forgetPending drops the embedding LookupSimilar computed for call, which
+// Store has not taken because the call failed or never ran.
+func (d *DedupCache) forgetPending(call ToolCall) {
+	if d.Similar == nil {
+		return
+	}
+	key := IdempotencyKey(call)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.Similar.pending, key)
+}
+
+// similarityText is the text embedded to compare calls of a tool.
+func similarityText(name string, args map[string]interface{}) string {
+	data, _ := json.Marshal(args)
+	return name + " " + string(data)
+}
+
+// Store records the result of a successful call.
+func (d *DedupCache) Store(call ToolCall, result interface{}) {
+	if !d.skips(call) {
+		return
+	}
+	key := IdempotencyKey(call)
+	if key == "" {
+		return
+	}
+	path, _, _ := TargetPath(call)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results[key] = dedupEntry{path: path, result: result}
+	if s := d.Similar; s != nil {
+		if vec, ok := s.pending[key]; ok {
+			delete(s.pending, key)
+			s.entries = append(s.entries, similarEntry{tool: toSnakeCase(call.Name), path: path, arguments: call.Arguments, result: result, embedding: vec})
+		}
+	}
+}
+
+// Invalidate forgets the results a mutating call may have changed: those of
+// calls on its path or a directory containing it and of calls without a
+// path, or all of them when the call has no path. The call's own result is
+// kept, so an identical repeat can still be skipped.
+func (d *DedupCache) Invalidate(call ToolCall) {
+	path, hasPath, _ := TargetPath(call)
+	stale := func(p string) bool {
+		return !hasPath || p == "" || within(path, p)
+	}
+	key := IdempotencyKey(call)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, e := range d.results {
+		if k != key && stale(e.path) {
+			delete(d.results, k)
+		}
+	}
+	if s := d.Similar; s != nil {
+		kept := s.entries[:0]
+		for _, e := range s.entries {
+			if !stale(e.path) {
+				kept = append(kept, e)
+			}
+		}
+		s.entries = kept
+	}
+}
+
+// within reports whether path is dir or lies under it.
+func within(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return path == dir || dir == "." || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// Reset forgets all recorded calls (used for step-scoped deduplication).
+func (d *DedupCache) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results = map[string]dedupEntry{}
+	if d.Similar != nil {
+		d.Similar.entries = nil
+		d.Similar.pending = map[string][]float32{}
+	}
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTool struct {
+	calls int32
+}
+
+func (c *countingTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	n := atomic.AddInt32(&c.calls, 1)
+	return n, nil
+}
+
+func TestIdempotencyKey_StableAcrossArgOrder(t *testing.T) {
+	a := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a", "content": "x"}}
+	b := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"content": "x", "file_path": "a"}}
+	if IdempotencyKey(a) != IdempotencyKey(b) {
+		t.Fatalf("expected identical keys for identical calls")
+	}
+	c := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a", "content": "y"}}
+	if IdempotencyKey(a) == IdempotencyKey(c) {
+		t.Fatalf("expected different keys for different arguments")
+	}
+	if d := (ToolCall{Name: "WriteFile", Arguments: a.Arguments}); IdempotencyKey(a) != IdempotencyKey(d) {
+		t.Fatalf("expected identical keys for the snake_case and CamelCase names")
+	}
+}
+
+func TestToolExecutor_DedupSkipsRepeatedCall(t *testing.T) {
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "Counter"}, tool)
+	reg.RegisterTool(ToolSchema{Name: "RunCommand"}, tool)
+	exec := &ToolExecutor{Registry: reg, Dedup: NewDedupCache([]string{"run_command"})}
+
+	call := ToolCall{Name: "Counter", Arguments: map[string]interface{}{"x": 1}}
+	first, _ := exec.Execute(context.Background(), call)
+	second, _ := exec.Execute(context.Background(), call)
+	if tool.calls != 1 || first != second {
+		t.Fatalf("expected repeated call to be skipped, got %d executions (%v, %v)", tool.calls, first, second)
+	}
+
+	exec.Dedup.Reset()
+	exec.Execute(context.Background(), call)
+	if tool.calls != 2 {
+		t.Fatalf("expected call to run again after reset, got %d executions", tool.calls)
+	}
+
+	cmd := ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "go test"}}
+	exec.Execute(context.Background(), cmd)
+	exec.Execute(context.Background(), cmd)
+	if tool.calls != 4 {
+		t.Fatalf("expected allow_repeat tool to always execute, got %d executions", tool.calls)
+	}
+}
+
+// pathEmbedder gives every text mentioning main.go the same embedding and
+// everything else an orthogonal one.
+type pathEmbedder struct{}
+
+func (pathEmbedder) Embed(text string) ([]float32, error) {
+	if strings.Contains(text, "main.go") {
+		return []float32{1, 0}, nil
+	}
+	return []float32{0, 1}, nil
+}
+
+func TestToolExecutor_DedupRemindsOfNearDuplicateCall(t *testing.T) {
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "ReadFile"}, tool)
+	reg.RegisterTool(ToolSchema{Name: "WriteFile"}, tool)
+	dedup := NewDedupCache(nil)
+	dedup.Similar = NewSimilarCalls(pathEmbedder{}, 0.9, nil)
+	exec := &ToolExecutor{Registry: reg, Dedup: dedup}
+
+	exec.Execute(context.Background(), ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": "main.go"}})
+	result, err := exec.Execute(context.Background(), ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": "./main.go"}})
+	if err != nil || tool.calls != 1 {
+		t.Fatalf("expected near-duplicate read to be skipped, got %d executions (%v)", tool.calls, err)
+	}
+	reminder, ok := result.(map[string]interface{})
+	if !ok || reminder["previous_result"] != int32(1) || !strings.Contains(reminder["reminder"].(string), "nearly identical") {
+		t.Fatalf("unexpected reminder: %#v", result)
+	}
+
+	exec.Execute(context.Background(), ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": "go.mod"}})
+	if tool.calls != 2 {
+		t.Fatalf("expected a different read to run, got %d executions", tool.calls)
+	}
+
+	// Writes are not checked for near-duplicates by default.
+	exec.Execute(context.Background(), ToolCall{Name: "WriteFile", Arguments: map[string]interface{}{"file_path": "main.go", "content": "a"}})
+	exec.Execute(context.Background(), ToolCall{Name: "WriteFile", Arguments: map[string]interface{}{"file_path": "main.go", "content": "b"}})
+	if tool.calls != 4 {
+		t.Fatalf("expected both writes to run, got %d executions", tool.calls)
+	}
+}
+
+func TestToolExecutor_DedupMutatingCalls(t *testing.T) {
+	reads, writes := &countingTool{}, &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "read_file"}, reads)
+	reg.RegisterTool(ToolSchema{Name: "list_dir"}, reads)
+	reg.RegisterTool(ToolSchema{Name: "WriteFile"}, writes)
+	reg.RegisterTool(ToolSchema{Name: "write_file"}, writes)
+	reg.RegisterTool(ToolSchema{Name: "run_command"}, writes)
+	exec := &ToolExecutor{Registry: reg, Dedup: NewDedupCache(nil)}
+	run := func(name string, args map[string]interface{}) {
+		t.Helper()
+		if _, err := exec.Execute(context.Background(), ToolCall{Name: name, Arguments: args}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	run("read_file", map[string]interface{}{"file_path": "pkg/a.go"})
+	run("read_file", map[string]interface{}{"file_path": "b.go"})
+	run("list_dir", map[string]interface{}{"path": "pkg"})
+	run("read_file", map[string]interface{}{"file_path": "pkg/a.go"})
+	if reads.calls != 3 {
+		t.Fatalf("expected the repeated read to be skipped, got %d reads", reads.calls)
+	}
+
+	write := map[string]interface{}{"file_path": "./pkg/a.go", "content": "x"}
+	run("write_file", write)
+	run("WriteFile", write)
+	if writes.calls != 2 {
+		t.Fatalf("expected repeated writes to run, got %d writes", writes.calls)
+	}
+	run("read_file", map[string]interface{}{"file_path": "pkg/a.go"})
+	run("list_dir", map[string]interface{}{"path": "pkg"})
+	run("read_file", map[string]interface{}{"file_path": "b.go"})
+	if reads.calls != 5 {
+		t.Fatalf("expected the write to clear the file and its directory only, got %d reads", reads.calls)
+	}
+
+	run("run_command", map[string]interface{}{"command": "go generate"})
+	run("read_file", map[string]interface{}{"file_path": "b.go"})
+	if reads.calls != 6 {
+		t.Fatalf("expected a command to clear every result, got %d reads", reads.calls)
+	}
+
+	exec.Dedup.Mutating = true
+	run("write_file", write)
+	run("WriteFile", write)
+	if writes.calls != 4 {
+		t.Fatalf("expected a repeated write to be skipped with Mutating, got %d writes", writes.calls)
+	}
+}
+
+type failingTool struct{}
+
+func (failingTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestToolExecutor_DedupForgetsEmbeddingsOfFailedCalls(t *testing.T) {
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "ReadFile"}, failingTool{})
+	dedup := NewDedupCache(nil)
+	dedup.Similar = NewSimilarCalls(pathEmbedder{}, 0.9, nil)
+	exec := &ToolExecutor{Registry: reg, Dedup: dedup}
+
+	for _, path := range []string{"main.go", "go.mod", "README.md"} {
+		if _, err := exec.Execute(context.Background(), ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": path}}); err == nil {
+			t.Fatalf("expected the read of %s to fail", path)
+		}
+	}
+	if n := len(dedup.Similar.pending); n != 0 {
+		t.Fatalf("expected no embeddings left for failed calls, got %d", n)
+	}
+	if n := len(dedup.Similar.entries); n != 0 {
+		t.Fatalf("expected failed calls not to be indexed, got %d", n)
+	}
+}
@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDocumentsDir is where documents are saved when no documents_dir is
+// configured.
+const DefaultDocumentsDir = "docs"
+
+// DocumentMeta is the front matter of a saved document.
+type DocumentMeta struct {
+	Name    string    `yaml:"name" json:"name"`
+	RunID   string    `yaml:"run_id,omitempty" json:"run_id,omitempty"`
+	Role    string    `yaml:"role,omitempty" json:"role,omitempty"`
+	Created time.Time `yaml:"created" json:"created"`
+	Updated time.Time `yaml:"updated" json:"updated"`
+}
+
+// Document is a named document saved by a role, e.g. design.md.
+type Document struct {
+	DocumentMeta
+	Content string `json:"content"`
+}
+
+// DocumentStore keeps documents as Markdown files with YAML front matter
+// under Dir, so later roles and people can load them by name.
+type DocumentStore struct {
+	Dir string
+}
+
+// NewDocumentStore returns a store saving to dir, or DefaultDocumentsDir.
+func NewDocumentStore(dir string) *DocumentStore {
+	if dir == "" {
+		dir = DefaultDocumentsDir
+	}
+	return &DocumentStore{Dir: dir}
+}
+
+// DocumentName cleans a document name: a relative slash-separated path that
+// stays inside the store. Names without an extension get ".md".
+func DocumentName(name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if clean == "." || clean == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid document name %q: expected a relative name such as design.md", name), nil)
+	}
+	if path.Ext(clean) == "" {
+		clean += ".md"
+	}
+	return clean, nil
+}
+
+// Path returns the file of the document name.
+func (s *DocumentStore) Path(name string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(name))
+}
+
+// Save writes content as the document name by author, keeping the creation
+// time of an earlier version.
+func (s *DocumentStore) Save(name, content string, author Author) (*Document, error) {
+	name, err := DocumentName(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	doc := &Document{DocumentMeta: DocumentMeta{Name: name, RunID: author.RunID, Role: author.Role, Created: now, Updated: now}, Content: content}
+	if previous, err := s.Load(name); err == nil && !previous.Created.IsZero() {
+		doc.Created = previous.Created
+	}
+	meta, err := yaml.Marshal(doc.DocumentMeta)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to encode front matter of document %s", name), err)
+	}
+	file := s.Path(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to create documents directory %s", filepath.Dir(file)), err)
+	}
+	data := "---\n" + string(meta) + "---\n" + content
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to save document %s", name), err)
+	}
+	return doc, nil
+}
+
+// Load reads the document name. Files without front matter, e.g. written by
+// hand, are loaded with their whole content.
+func (s *DocumentStore) Load(name string) (*Document, error) {
+	name, err := DocumentName(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.Path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("document %s not found in %s; use list_documents to see saved documents", name, s.Dir), err)
+		}
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to load document %s", name), err)
+	}
+	doc := &Document{DocumentMeta: DocumentMeta{Name: name}, Content: string(data)}
+	if rest, ok := bytes.CutPrefix(data, []byte("---\n")); ok {
+		if meta, content, ok := bytes.Cut(rest, []byte("\n---\n")); ok {
+			if err := yaml.Unmarshal(meta, &doc.DocumentMeta); err != nil {
+				return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid front matter in document %s", name), err)
+			}
+			doc.Name = name
+			doc.Content = string(content)
+		}
+	}
+	return doc, nil
+}
+
+// List returns the front matter of the saved documents ordered by name.
+func (s *DocumentStore) List() ([]DocumentMeta, error) {
+	var metas []DocumentMeta
+	err := filepath.WalkDir(s.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == s.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		doc, err := s.Load(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		metas = append(metas, doc.DocumentMeta)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to list documents in %s", s.Dir), err)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	return metas, nil
+}
+
+// Author is the run and role tool calls are made for, recorded in the front
+// matter of saved documents.
+type Author struct {
+	RunID string
+	Role  string
+}
+
+type authorKey struct{}
+
+// WithAuthor returns ctx carrying the author of the tool calls run with it.
+func WithAuthor(ctx context.Context, author Author) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// AuthorFrom returns the author carried by ctx, or the zero Author.
+func AuthorFrom(ctx context.Context) Author {
+	author, _ := ctx.Value(authorKey{}).(Author)
+	return author
+}
+
+// DocumentTool implements save_document, load_document and list_documents on
+// a shared DocumentStore.
+type DocumentTool struct {
+	Store *DocumentStore
+	Op    string // "save", "load" or "list"
+}
+
+func (t *DocumentTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if t.Op == "list" {
+		metas, err := t.Store.List()
+		if err != nil {
+			return nil, err
+		}
+		if metas == nil {
+			metas = []DocumentMeta{}
+		}
+		return metas, nil
+	}
+	var name string
+	if v, ok := lookupArgFlexible(args, "name"); ok {
+		name, _ = v.(string)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("invalid arguments for %s_document: name required", t.Op)
+	}
+	if t.Op == "load" {
+		return t.Store.Load(name)
+	}
+	v, ok := lookupArgFlexible(args, "content")
+	content, isString := v.(string)
+	if !ok || !isString {
+		return nil, fmt.Errorf("invalid arguments for save_document: content required")
+	}
+	doc, err := t.Store.Save(name, content, AuthorFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":    doc.Name,
+		"path":    t.Store.Path(doc.Name),
+		"bytes":   len(content),
+		"created": doc.Created,
+		"updated": doc.Updated,
+	}, nil
+}
+
+// registerDocumentTools registers save_document, load_document and
+// list_documents sharing store.
+func registerDocumentTools(reg *ToolRegistry, store *DocumentStore) {
+	reg.RegisterTool(ToolSchema{
+		Name:        "save_document",
+		Description: "Saves a named document (e.g. design.md, testplan.md) for later roles, recording the run and role that wrote it. Saving an existing name replaces it.",
+		Arguments: []ToolArgument{
+			{Name: "name", Type: "string", Required: true, Description: "Document name, e.g. design.md; .md is added when there is no extension."},
+			{Name: "content", Type: "string", Required: true, Description: "Document content."},
+		},
+	}, &DocumentTool{Store: store, Op: "save"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "load_document",
+		Description: "Loads a document saved with save_document by name, with the run and role that wrote it.",
+		Arguments: []ToolArgument{
+			{Name: "name", Type: "string", Required: true, Description: "Document name, e.g. design.md."},
+		},
+	}, &DocumentTool{Store: store, Op: "load"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "list_documents",
+		Description: "Lists the saved documents with the run and role that wrote them.",
+		Arguments:   []ToolArgument{},
+	}, &DocumentTool{Store: store, Op: "list"})
+}
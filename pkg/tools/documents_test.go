@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentName(t *testing.T) {
+	for name, want := range map[string]string{"design": "design.md", "plans/test.txt": "plans/test.txt", `a\b.md`: "a/b.md", "./x/../y.md": "y.md"} {
+		if got, err := DocumentName(name); err != nil || got != want {
+			t.Errorf("DocumentName(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "  ", "../secret.md", "/etc/passwd", "a/../../b"} {
+		if _, err := DocumentName(name); err == nil {
+			t.Errorf("expected an error for %q", name)
+		}
+	}
+}
+
+func TestDocumentStore_SaveLoad(t *testing.T) {
+	store := NewDocumentStore(t.TempDir())
+	first, err := store.Save("design", "# Design\n", Author{RunID: "r1", Role: "architect"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(store.Path("design.md"))
+	if !strings.HasPrefix(string(data), "---\nname: design.md\nrun_id: r1\nrole: architect\n") || !strings.HasSuffix(string(data), "---\n# Design\n") {
+		t.Errorf("unexpected file:\n%s", data)
+	}
+
+	second, err := store.Save("design.md", "# Design v2\n", Author{RunID: "r2", Role: "reviewer"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	doc, err := store.Load("design")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.Content != "# Design v2\n" || doc.RunID != "r2" || doc.Role != "reviewer" || !doc.Created.Equal(first.Created) || !doc.Updated.Equal(second.Updated) {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	os.WriteFile(filepath.Join(store.Dir, "notes.md"), []byte("---\nhand written"), 0644)
+	if doc, err := store.Load("notes.md"); err != nil || doc.Content != "---\nhand written" {
+		t.Errorf("expected a file without front matter loaded whole, got %+v, %v", doc, err)
+	}
+	if _, err := store.Load("missing.md"); err == nil || !strings.Contains(err.Error(), "list_documents") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	metas, err := store.List()
+	if err != nil || len(metas) != 2 || metas[0].Name != "design.md" || metas[1].Name != "notes.md" {
+		t.Errorf("unexpected list %+v, %v", metas, err)
+	}
+	if metas, err := NewDocumentStore(filepath.Join(store.Dir, "none")).List(); err != nil || len(metas) != 0 {
+		t.Errorf("expected no documents in a missing directory, got %+v, %v", metas, err)
+	}
+}
+
+func TestDocumentTools(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	reg.Documents().Dir = t.TempDir()
+	exec := &ToolExecutor{Registry: reg}
+	ctx := WithAuthor(context.Background(), Author{RunID: "r1", Role: "architect"})
+
+	if _, err := exec.Execute(ctx, ToolCall{Name: "save_document", Arguments: map[string]interface{}{"name": "testplan", "content": "1. run it"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := exec.Execute(ctx, ToolCall{Name: "load_document", Arguments: map[string]interface{}{"name": "testplan.md"}})
+	if doc, ok := result.(*Document); err != nil || !ok || doc.Content != "1. run it" || doc.Role != "architect" {
+		t.Errorf("unexpected load result %+v, %v", result, err)
+	}
+	result, err = exec.Execute(ctx, ToolCall{Name: "list_documents", Arguments: map[string]interface{}{}})
+	if metas, ok := result.([]DocumentMeta); err != nil || !ok || len(metas) != 1 || metas[0].RunID != "r1" {
+		t.Errorf("unexpected list result %+v, %v", result, err)
+	}
+	if _, err := exec.Execute(ctx, ToolCall{Name: "save_document", Arguments: map[string]interface{}{"name": "x"}}); err == nil {
+		t.Error("expected an error without content")
+	}
+}
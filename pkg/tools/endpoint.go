@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// EndpointTool runs a config tool with an endpoint instead of a
+// command_template: the call's arguments are POSTed to the URL as a JSON
+// object and the response body is the result. The URL is the config's, so
+// http_request.allowed_domains does not apply.
+type EndpointTool struct {
+	url       string
+	arguments []types.ToolArgument
+}
+
+// NewEndpointTool returns the tool for tool's endpoint.
+func NewEndpointTool(tool types.ConfigurableTool) *EndpointTool {
+	return &EndpointTool{url: tool.Endpoint, arguments: tool.Arguments}
+}
+
+// Execute posts the declared arguments, defaults applied, and returns
+// the response body, cut at DefaultHTTPMaxBytes. Statuses of 400 and above
+// are errors carrying the body.
+func (t *EndpointTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	payload := make(map[string]interface{}, len(t.arguments))
+	for _, arg := range t.arguments {
+		if v, ok := lookupArgFlexible(args, arg.Name); ok {
+			payload[arg.Name] = v
+		} else if arg.Default != nil {
+			payload[arg.Name] = arg.Default
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to encode the arguments for "+t.url, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultHTTPTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "invalid tool endpoint "+t.url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to call tool endpoint "+t.url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, DefaultHTTPMaxBytes))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to read the response of "+t.url, err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("tool endpoint %s answered %s: %s", t.url, resp.Status, strings.TrimSpace(string(data))), nil)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Env is the environment of commands run by tools. Tool implementations read
+// it from their context with EnvFrom.
+type Env struct {
+	Inherit bool     // Start from the process environment
+	Vars    []string // NAME=value entries, overriding earlier ones of the same name
+}
+
+// baseEnv lists the variables kept when the process environment is not
+// inherited.
+var baseEnv = []string{"PATH", "HOME", "AI_TEAM_RUN_ID"}
+
+// Environ returns the environment as NAME=value entries. Values of Vars have
+// $VAR and ${VAR} expanded from the process environment.
+func (e *Env) Environ() []string {
+	var env []string
+	if e.Inherit {
+		env = os.Environ()
+	} else {
+		for _, name := range baseEnv {
+			if v, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+v)
+			}
+		}
+	}
+	for _, kv := range e.Vars {
+		name, value, _ := strings.Cut(kv, "=")
+		env = setEnv(env, name, os.ExpandEnv(value))
+	}
+	return env
+}
+
+// Lookup returns the value of name in the environment.
+func (e *Env) Lookup(name string) (string, bool) {
+	for _, kv := range e.Environ() {
+		if n, v, _ := strings.Cut(kv, "="); n == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// setEnv replaces name's entry in env, or appends one.
+func setEnv(env []string, name, value string) []string {
+	for i, kv := range env {
+		if n, _, _ := strings.Cut(kv, "="); n == name {
+			env[i] = name + "=" + value
+			return env
+		}
+	}
+	return append(env, name+"="+value)
+}
+
+type envKey struct{}
+
+// WithEnv returns ctx carrying env for the tools run with it.
+func WithEnv(ctx context.Context, env *Env) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// EnvFrom returns the environment carried by ctx, or nil when commands should
+// inherit the process environment.
+func EnvFrom(ctx context.Context) *Env {
+	env, _ := ctx.Value(envKey{}).(*Env)
+	return env
+}
+
+// CommandEnv returns the environment for a command run with ctx, plus extra
+// NAME=value entries. It returns nil, meaning the process environment, when
+// there are neither.
+func CommandEnv(ctx context.Context, extra ...string) []string {
+	env := EnvFrom(ctx)
+	if env == nil {
+		if len(extra) == 0 {
+			return nil
+		}
+		env = &Env{Inherit: true}
+	}
+	environ := env.Environ()
+	for _, kv := range extra {
+		name, value, _ := strings.Cut(kv, "=")
+		environ = setEnv(environ, name, value)
+	}
+	return environ
+}
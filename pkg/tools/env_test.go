@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnv_Environ(t *testing.T) {
+	t.Setenv("AI_TEAM_TEST_SECRET", "s3cret")
+	t.Setenv("AI_TEAM_TEST_OTHER", "other")
+
+	env := &Env{Vars: []string{"TOKEN=${AI_TEAM_TEST_SECRET}", "MODE=ci", "MODE=release"}}
+	if v, ok := env.Lookup("TOKEN"); !ok || v != "s3cret" {
+		t.Errorf("expected TOKEN expanded from the process environment, got %q", v)
+	}
+	if v, _ := env.Lookup("MODE"); v != "release" {
+		t.Errorf("expected the later MODE to win, got %q", v)
+	}
+	if _, ok := env.Lookup("AI_TEAM_TEST_OTHER"); ok {
+		t.Error("expected the process environment not to be inherited")
+	}
+	if _, ok := env.Lookup("PATH"); !ok {
+		t.Error("expected PATH to be kept")
+	}
+
+	env.Inherit = true
+	if v, _ := env.Lookup("AI_TEAM_TEST_OTHER"); v != "other" {
+		t.Errorf("expected the process environment to be inherited, got %q", v)
+	}
+}
+
+func TestToolExecutor_InjectsEnv(t *testing.T) {
+	t.Setenv("AI_TEAM_TEST_OTHER", "other")
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	exec := &ToolExecutor{Registry: reg, Env: &Env{Vars: []string{"AI_TEAM_TEST_VAR=injected"}}}
+
+	out, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "echo \"$AI_TEAM_TEST_VAR-$AI_TEAM_TEST_OTHER\""}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := fmt.Sprint(out); !strings.Contains(s, "injected-") || strings.Contains(s, "other") {
+		t.Errorf("expected only the injected var in the command environment, got %q", out)
+	}
+}
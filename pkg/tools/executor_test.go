@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -11,7 +13,7 @@ type mockTool struct {
 	attempts int32
 }
 
-func (m *mockTool) Execute(args map[string]interface{}) (interface{}, error) {
+func (m *mockTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
 	a := atomic.AddInt32(&m.attempts, 1)
 	if a < 2 {
 		return nil, fmt.Errorf("transient error attempt=%d", a)
@@ -21,12 +23,23 @@ func (m *mockTool) Execute(args map[string]interface{}) (interface{}, error) {
 
 type slowTool struct{}
 
-func (s *slowTool) Execute(args map[string]interface{}) (interface{}, error) {
+func (s *slowTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
 	// block longer than executor timeout to trigger timeout branch
 	time.Sleep(200 * time.Millisecond)
 	return "done", nil
 }
 
+// waitTool blocks until its context is done and reports the context's error.
+type waitTool struct {
+	stopped chan error
+}
+
+func (w *waitTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	<-ctx.Done()
+	w.stopped <- ctx.Err()
+	return nil, ctx.Err()
+}
+
 func TestToolExecutor_RetryAndSuccess(t *testing.T) {
 	reg := NewToolRegistry()
 	// register a no-arg schema so validation passes
@@ -35,7 +48,7 @@ func TestToolExecutor_RetryAndSuccess(t *testing.T) {
 	reg.impls["MockTool"] = &mockTool{}
 
 	exec := &ToolExecutor{Registry: reg, RetryCount: 3, Timeout: 1 * time.Second}
-	res, err := exec.Execute(ToolCall{Name: "MockTool", Arguments: map[string]interface{}{}})
+	res, err := exec.Execute(context.Background(), ToolCall{Name: "MockTool", Arguments: map[string]interface{}{}})
 	if err != nil {
 		t.Fatalf("expected success after retry, got error: %v", err)
 	}
@@ -50,7 +63,7 @@ func TestToolExecutor_Timeout(t *testing.T) {
 	reg.impls["SlowTool"] = &slowTool{}
 
 	exec := &ToolExecutor{Registry: reg, RetryCount: 1, Timeout: 50 * time.Millisecond}
-	_, err := exec.Execute(ToolCall{Name: "SlowTool", Arguments: map[string]interface{}{}})
+	_, err := exec.Execute(context.Background(), ToolCall{Name: "SlowTool", Arguments: map[string]interface{}{}})
 	if err == nil {
 		t.Fatalf("expected timeout error, got nil")
 	}
@@ -58,3 +71,42 @@ func TestToolExecutor_Timeout(t *testing.T) {
 		t.Fatalf("expected non-empty error message on timeout")
 	}
 }
+
+func TestToolExecutor_CancelKillsCommand(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	exec := &ToolExecutor{Registry: reg, RetryCount: 3}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := exec.Execute(ctx, ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "sleep 5"}})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("expected a cancelled error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the command to be killed without retries, took %s", elapsed)
+	}
+	if _, err := exec.Execute(ctx, ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "echo hi"}}); err == nil {
+		t.Error("expected no tool to run once the context is done")
+	}
+}
+
+func TestToolExecutor_TimeoutStopsTool(t *testing.T) {
+	tool := &waitTool{stopped: make(chan error, 1)}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "WaitTool", Description: "waits"}, tool)
+
+	exec := &ToolExecutor{Registry: reg, RetryCount: 1, Timeout: 50 * time.Millisecond}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "WaitTool"}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	select {
+	case err := <-tool.stopped:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the tool's context to hit its deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the tool to be stopped when the call timed out")
+	}
+}
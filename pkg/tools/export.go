@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Formats of ExportSchemas, one per function-calling API.
+const (
+	SchemaFormatOpenAI    = "openai"
+	SchemaFormatGemini    = "gemini"
+	SchemaFormatAnthropic = "anthropic"
+)
+
+// SchemaFormats lists the formats ExportSchemas supports.
+var SchemaFormats = []string{SchemaFormatOpenAI, SchemaFormatGemini, SchemaFormatAnthropic}
+
+// OpenAITool is a function definition of the OpenAI tools API.
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction describes a function and its parameters, a JSON Schema of
+// type object.
+type OpenAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// AnthropicTool is a tool block of the Anthropic Messages API.
+type AnthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ExportSchemas returns the registered tools as the JSON a function-calling
+// API expects: the tools array of OpenAI, the tools with functionDeclarations
+// of Gemini, or the tool blocks of Anthropic.
+func (r *ToolRegistry) ExportSchemas(format string) ([]byte, error) {
+	var out interface{}
+	switch strings.ToLower(format) {
+	case SchemaFormatOpenAI:
+		out = OpenAITools(r.ListTools())
+	case SchemaFormatGemini:
+		out = GeminiTools(r.ListTools())
+	case SchemaFormatAnthropic:
+		out = AnthropicTools(r.ListTools())
+	default:
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("unknown schema format '%s'; use one of %s", format, strings.Join(SchemaFormats, ", ")), nil)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to encode tool schemas", err)
+	}
+	return data, nil
+}
+
+// OpenAITools converts tool schemas to function definitions, ordered by name.
+func OpenAITools(schemas []ToolSchema) []OpenAITool {
+	sorted := sortedSchemas(schemas)
+	out := make([]OpenAITool, 0, len(sorted))
+	for _, s := range sorted {
+		out = append(out, OpenAITool{Type: "function", Function: OpenAIFunction{
+			Name:        s.Name,
+			Description: s.Description,
+			Parameters:  parametersMap(s, jsonSchemaMap, "object"),
+		}})
+	}
+	return out
+}
+
+// GeminiTools converts tool schemas to function declarations, ordered by
+// name, or nil when there are none.
+func GeminiTools(schemas []ToolSchema) []types.GeminiTool {
+	if len(schemas) == 0 {
+		return nil
+	}
+	sorted := sortedSchemas(schemas)
+	declarations := make([]types.GeminiFunctionDeclaration, 0, len(sorted))
+	for _, s := range sorted {
+		declaration := types.GeminiFunctionDeclaration{Name: s.Name, Description: s.Description}
+		// Gemini rejects OBJECT parameters without properties.
+		if len(s.Arguments) > 0 {
+			declaration.Parameters = parametersMap(s, openAPISchemaMap, "OBJECT")
+		}
+		declarations = append(declarations, declaration)
+	}
+	return []types.GeminiTool{{FunctionDeclarations: declarations}}
+}
+
+// AnthropicTools converts tool schemas to tool blocks, ordered by name.
+func AnthropicTools(schemas []ToolSchema) []AnthropicTool {
+	sorted := sortedSchemas(schemas)
+	out := make([]AnthropicTool, 0, len(sorted))
+	for _, s := range sorted {
+		out = append(out, AnthropicTool{
+			Name:        s.Name,
+			Description: s.Description,
+			InputSchema: parametersMap(s, jsonSchemaMap, "object"),
+		})
+	}
+	return out
+}
+
+func sortedSchemas(schemas []ToolSchema) []ToolSchema {
+	sorted := append([]ToolSchema(nil), schemas...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// parametersMap returns the arguments of s as an object schema of type
+// objectType, with each argument converted by convert.
+func parametersMap(s ToolSchema, convert func(*types.JSONSchema) map[string]interface{}, objectType string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, arg := range s.Arguments {
+		properties[arg.Name] = convert(arg.JSONSchema())
+		if arg.Required {
+			required = append(required, arg.Name)
+		}
+	}
+	return map[string]interface{}{"type": objectType, "properties": properties, "required": required}
+}
+
+// jsonSchemaMap returns schema as the JSON Schema of function-calling APIs.
+// A nullable schema lists "null" among its types.
+func jsonSchemaMap(schema *types.JSONSchema) map[string]interface{} {
+	out := schemaMap(schema, jsonSchemaMap)
+	if schema.Type != "" && schema.Nullable {
+		out["type"] = []string{schema.Type, "null"}
+	}
+	return out
+}
+
+// openAPISchemaMap returns schema as the OpenAPI schema of the Gemini API,
+// which spells types in upper case and marks nullable values with nullable.
+// Gemini only accepts enums of strings; others are left out.
+func openAPISchemaMap(schema *types.JSONSchema) map[string]interface{} {
+	out := schemaMap(schema, openAPISchemaMap)
+	if schema.Type != "" {
+		out["type"] = strings.ToUpper(schema.Type)
+	}
+	if schema.Nullable {
+		out["nullable"] = true
+	}
+	if len(schema.Enum) > 0 {
+		if schema.Type == "string" {
+			out["format"] = "enum"
+		} else {
+			delete(out, "enum")
+		}
+	}
+	return out
+}
+
+// schemaMap returns the keywords of schema, with nested schemas converted
+// by nested.
+func schemaMap(schema *types.JSONSchema, nested func(*types.JSONSchema) map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	if schema.Type != "" {
+		out["type"] = schema.Type
+	}
+	if schema.Description != "" {
+		out["description"] = schema.Description
+	}
+	if len(schema.Enum) > 0 {
+		out["enum"] = schema.Enum
+	}
+	if schema.Default != nil {
+		out["default"] = schema.Default
+	}
+	for key, value := range map[string]interface{}{
+		"minimum": schema.Minimum, "maximum": schema.Maximum,
+		"minLength": schema.MinLength, "maxLength": schema.MaxLength,
+		"minItems": schema.MinItems, "maxItems": schema.MaxItems,
+	} {
+		switch v := value.(type) {
+		case *float64:
+			if v != nil {
+				out[key] = *v
+			}
+		case *int:
+			if v != nil {
+				out[key] = *v
+			}
+		}
+	}
+	if schema.Items != nil {
+		out["items"] = nested(schema.Items)
+	}
+	if len(schema.Properties) > 0 {
+		properties := map[string]interface{}{}
+		for name, prop := range schema.Properties {
+			if prop != nil {
+				properties[name] = nested(prop)
+			}
+		}
+		out["properties"] = properties
+	}
+	if len(schema.Required) > 0 {
+		out["required"] = schema.Required
+	}
+	return out
+}
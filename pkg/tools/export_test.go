@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestExportSchemas_Arguments(t *testing.T) {
+	low := 1.0
+	functions := OpenAITools([]ToolSchema{{Name: "search", Arguments: []ToolArgument{
+		{Name: "level", Type: "string", Required: true, Schema: &types.JSONSchema{Enum: []interface{}{"debug", "info"}}},
+		{Name: "limit", Type: "int", Schema: &types.JSONSchema{Minimum: &low, Nullable: true}},
+		{Name: "filter", Schema: &types.JSONSchema{Type: "object", Properties: map[string]*types.JSONSchema{"paths": {Type: "array", Items: &types.JSONSchema{Type: "string"}}}}},
+	}}})
+	data, _ := json.Marshal(functions[0].Function.Parameters)
+	want := `{"properties":{"filter":{"properties":{"paths":{"items":{"type":"string"},"type":"array"}},"type":"object"},"level":{"enum":["debug","info"],"type":"string"},"limit":{"minimum":1,"type":["integer","null"]}},"required":["level"],"type":"object"}`
+	if string(data) != want {
+		t.Errorf("parameters =\n%s\nwant\n%s", data, want)
+	}
+
+	declared := GeminiTools([]ToolSchema{{Name: "search", Arguments: []ToolArgument{
+		{Name: "level", Type: "string", Schema: &types.JSONSchema{Enum: []interface{}{"debug", "info"}}},
+		{Name: "limit", Type: "int", Schema: &types.JSONSchema{Nullable: true, Enum: []interface{}{1, 2}}},
+	}}})[0].FunctionDeclarations
+	data, _ = json.Marshal(declared[0].Parameters["properties"])
+	want = `{"level":{"enum":["debug","info"],"format":"enum","type":"STRING"},"limit":{"nullable":true,"type":"INTEGER"}}`
+	if string(data) != want {
+		t.Errorf("gemini properties =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestGeminiTools(t *testing.T) {
+	if GeminiTools(nil) != nil {
+		t.Error("expected no tools without schemas")
+	}
+	declared := GeminiTools([]ToolSchema{
+		{Name: "now", Description: "Current time"},
+		{Name: "grep", Arguments: []ToolArgument{{Name: "paths", Type: "array"}, {Name: "max", Type: "int"}}},
+	})[0].FunctionDeclarations
+	if declared[0].Name != "grep" || declared[1].Name != "now" {
+		t.Fatalf("expected declarations ordered by name, got %+v", declared)
+	}
+	if declared[1].Parameters != nil {
+		t.Errorf("expected no parameters for a tool without arguments, got %v", declared[1].Parameters)
+	}
+	properties := declared[0].Parameters["properties"].(map[string]interface{})
+	paths := properties["paths"].(map[string]interface{})
+	if paths["type"] != "ARRAY" || paths["items"].(map[string]interface{})["type"] != "STRING" || properties["max"].(map[string]interface{})["type"] != "INTEGER" {
+		t.Errorf("unexpected properties %v", properties)
+	}
+}
+
+func TestToolRegistry_ExportSchemas(t *testing.T) {
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "search", Description: "Searches.", Arguments: []ToolArgument{{Name: "pattern", Type: "string", Required: true}}}, nil)
+	reg.RegisterTool(ToolSchema{Name: "now"}, nil)
+
+	for format, want := range map[string]string{
+		"openai":    `[{"type":"function","function":{"name":"now","parameters":{"properties":{},"required":[],"type":"object"}}},{"type":"function","function":{"name":"search","description":"Searches.","parameters":{"properties":{"pattern":{"type":"string"}},"required":["pattern"],"type":"object"}}}]`,
+		"Gemini":    `[{"functionDeclarations":[{"name":"now"},{"name":"search","description":"Searches.","parameters":{"properties":{"pattern":{"type":"STRING"}},"required":["pattern"],"type":"OBJECT"}}]}]`,
+		"anthropic": `[{"name":"now","input_schema":{"properties":{},"required":[],"type":"object"}},{"name":"search","description":"Searches.","input_schema":{"properties":{"pattern":{"type":"string"}},"required":["pattern"],"type":"object"}}]`,
+	} {
+		data, err := reg.ExportSchemas(format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", format, err)
+		}
+		if compact.String() != want {
+			t.Errorf("%s export =\n%s\nwant\n%s", format, data, want)
+		}
+	}
+	if _, err := reg.ExportSchemas("xml"); err == nil || !strings.Contains(err.Error(), "openai, gemini, anthropic") {
+		t.Errorf("expected an unknown format refused, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"ai-team/pkg/types"
+)
+
+// FinalAnswerName is the tool roles call to finish with a result. Chains store
+// its answer under the step's output_key and end the step's loop on it.
+const FinalAnswerName = "final_answer"
+
+// FinalAnswerTool returns the answer it is called with.
+type FinalAnswerTool struct{}
+
+func (t *FinalAnswerTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	answer, ok := lookupArgFlexible(args, "answer")
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments for final_answer: answer required")
+	}
+	return answer, nil
+}
+
+// CheckFinalAnswer validates the answer of a final_answer call against the
+// schema a role declares for it; any answer passes a nil schema.
+func CheckFinalAnswer(schema *types.JSONSchema, call ToolCall) error {
+	if schema == nil || call.Name != FinalAnswerName {
+		return nil
+	}
+	answer, _ := lookupArgFlexible(call.Arguments, "answer")
+	if err := ValidateValue(schema, "answer", answer); err != nil {
+		return fmt.Errorf("invalid final_answer: %w", err)
+	}
+	return nil
+}
+
+func registerFinalAnswerTool(reg *ToolRegistry) {
+	reg.RegisterTool(ToolSchema{
+		Name:        FinalAnswerName,
+		Description: "Finishes the task with its result. Call it once the work is done instead of another tool.",
+		Arguments: []ToolArgument{
+			// No type: the answer may be text, a number, a list or an object.
+			{Name: "answer", Required: true, Description: "The result of the task.", Schema: &types.JSONSchema{}},
+		},
+	}, &FinalAnswerTool{})
+}
@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-team/pkg/cleanup"
+	"ai-team/pkg/errors"
+)
+
+// GitSnapshot records the current working tree (tracked files, staged or not)
+// as a commit under refs/ai-team/snapshots without touching the index, the
+// working tree or the stash list. It returns the ref and commit created.
+func GitSnapshot(label string) (string, string, error) {
+	sha, err := gitOutput("stash", "create", "ai-team snapshot: "+label)
+	if err != nil {
+		return "", "", err
+	}
+	if sha == "" {
+		// Clean working tree: HEAD is the snapshot
+		if sha, err = gitOutput("rev-parse", "HEAD"); err != nil {
+			return "", "", err
+		}
+	}
+	ref := fmt.Sprintf("refs/ai-team/snapshots/%s", time.Now().UTC().Format("20060102T150405.000"))
+	if _, err := gitOutput("update-ref", ref, sha); err != nil {
+		return "", "", err
+	}
+	return ref, sha, nil
+}
+
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out))), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GitTool runs one git operation for the model: "status", "diff", "commit",
+// "branch" or "checkout". Arguments are validated before git runs, and
+// paths always follow "--", so a call cannot smuggle in options. Git runs in
+// the sandbox workdir, with the sandbox environment, when a sandbox is set.
+// Files the ignore filter of the context excludes are left out of status,
+// diffs and commits, and naming one is refused.
+type GitTool struct {
+	Op string
+}
+
+func (t *GitTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	switch t.Op {
+	case "status":
+		paths, err := gitPaths(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkGitPaths(ctx, paths); err != nil {
+			return nil, err
+		}
+		excludes, err := gitExcludes(ctx, gitChangedArgs, paths)
+		if err != nil {
+			return nil, err
+		}
+		return runGit(ctx, append(append([]string{"status", "--short", "--branch", "--"}, paths...), excludes...)...)
+	case "diff":
+		return t.diff(ctx, args)
+	case "commit":
+		return t.commit(ctx, args)
+	case "branch":
+		name := stringArg(args, "name")
+		if name == "" {
+			return runGit(ctx, "branch", "--list", "--no-color")
+		}
+		if err := checkGitBranch(ctx, name); err != nil {
+			return nil, err
+		}
+		if _, err := runGit(ctx, "branch", name); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Created branch %s", name), nil
+	case "checkout":
+		name := stringArg(args, "branch")
+		if err := checkGitBranch(ctx, name); err != nil {
+			return nil, err
+		}
+		if create, _ := boolArg(args, "create"); create {
+			return runGit(ctx, "checkout", "-b", name)
+		}
+		// The trailing "--" makes git read name as a branch, never a path.
+		return runGit(ctx, "checkout", name, "--")
+	}
+	return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("unknown git operation '%s'", t.Op), nil)
+}
+
+func (t *GitTool) diff(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	var revs []string
+	if staged, _ := boolArg(args, "staged"); staged {
+		revs = append(revs, "--cached")
+	}
+	if ref := stringArg(args, "ref"); ref != "" {
+		if !gitRefPattern.MatchString(ref) || strings.HasPrefix(ref, "-") {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid git revision '%s'", ref), nil)
+		}
+		revs = append(revs, ref)
+	}
+	paths, err := gitPaths(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGitPaths(ctx, paths); err != nil {
+		return nil, err
+	}
+	excludes, err := gitExcludes(ctx, append([]string{"diff", "--name-only", "-z"}, revs...), paths)
+	if err != nil {
+		return nil, err
+	}
+	gitArgs := []string{"diff", "--no-color"}
+	if stat, _ := boolArg(args, "stat"); stat {
+		gitArgs = append(gitArgs, "--stat")
+	}
+	gitArgs = append(append(gitArgs, revs...), "--")
+	out, err := runGit(ctx, append(append(gitArgs, paths...), excludes...)...)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return "No changes.", nil
+	}
+	return out, nil
+}
+
+// commit stages the given files, or all changes, and commits them. Without
+// either it commits what is already staged. Ignored files are not staged, and
+// a commit that would include one is refused.
+func (t *GitTool) commit(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	message := strings.TrimSpace(stringArg(args, "message"))
+	if message == "" {
+		return nil, errors.New(errors.ErrCodeTool, "git_commit requires a non-empty message", nil)
+	}
+	files, err := gitPaths(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGitPaths(ctx, files); err != nil {
+		return nil, err
+	}
+	all, _ := boolArg(args, "all")
+	if all || len(files) > 0 {
+		if all {
+			files = nil
+		}
+		excludes, err := gitExcludes(ctx, gitChangedArgs, files)
+		if err != nil {
+			return nil, err
+		}
+		add := []string{"add"}
+		if all {
+			add = append(add, "--all")
+		}
+		if _, err := runGit(ctx, append(append(append(add, "--"), files...), excludes...)...); err != nil {
+			return nil, err
+		}
+	}
+	staged, err := gitIgnoredFiles(ctx, []string{"diff", "--cached", "--name-only", "-z"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(staged) > 0 {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("the staged changes include %s, which is excluded by ignore rules; unstage it before committing", staged[0]), nil)
+	}
+	return runGit(ctx, "commit", "--no-edit", "-m", message)
+}
+
+// gitRefPattern matches revisions such as HEAD~2, main, v1.0^ or
+// main..feature.
+var gitRefPattern = regexp.MustCompile(`^[A-Za-z0-9._/~^@{}:+-]+$`)
+
+// checkGitBranch refuses names git would not accept as a branch, including
+// ones starting with "-".
+func checkGitBranch(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New(errors.ErrCodeTool, "a branch name is required", nil)
+	}
+	if strings.HasPrefix(name, "-") {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid branch name '%s'", name), nil)
+	}
+	if _, err := runGit(ctx, "check-ref-format", "--branch", name); err != nil {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid branch name '%s'", name), err)
+	}
+	return nil
+}
+
+// gitPaths returns the path or files argument of a call: a path or a list
+// of paths. Paths may not start with "-" or hold NUL bytes.
+func gitPaths(args map[string]interface{}) ([]string, error) {
+	var paths []string
+	for _, name := range []string{"path", "files"} {
+		v, ok := lookupArgFlexible(args, name)
+		if !ok || v == nil {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			if s != "" {
+				paths = append(paths, s)
+			}
+			continue
+		}
+		items, ok := arrayValue(v)
+		if !ok {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("argument '%s' must be a path or a list of paths", name), nil)
+		}
+		for _, item := range items {
+			s, _ := item.(string)
+			if s == "" {
+				return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("argument '%s' holds an empty or non-string path", name), nil)
+			}
+			paths = append(paths, s)
+		}
+	}
+	for _, p := range paths {
+		if strings.HasPrefix(p, "-") || strings.ContainsRune(p, 0) {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid path '%s'", p), nil)
+		}
+	}
+	return paths, nil
+}
+
+// gitChangedArgs lists the changed and untracked files of the working tree,
+// one NUL-terminated "XY path" entry each. Renames are listed as a deletion
+// and an addition, so every entry names a single path.
+var gitChangedArgs = []string{"status", "--porcelain", "-z", "--no-renames", "--untracked-files=all"}
+
+// checkGitPaths refuses paths the ignore filter of ctx excludes.
+func checkGitPaths(ctx context.Context, paths []string) error {
+	ignore := IgnoreFrom(ctx)
+	if ignore == nil {
+		return nil
+	}
+	for _, p := range paths {
+		if ignore.Ignored(gitWorkPath(ctx, p)) {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("path %s is excluded by ignore rules", p), nil)
+		}
+	}
+	return nil
+}
+
+// gitExcludes returns pathspecs leaving out the files git lists with
+// listArgs, limited to paths, that the ignore filter of ctx excludes.
+func gitExcludes(ctx context.Context, listArgs, paths []string) ([]string, error) {
+	ignored, err := gitIgnoredFiles(ctx, listArgs, paths)
+	if err != nil {
+		return nil, err
+	}
+	excludes := make([]string, 0, len(ignored))
+	for _, name := range ignored {
+		excludes = append(excludes, ":(top,exclude,literal)"+name)
+	}
+	return excludes, nil
+}
+
+// gitIgnoredFiles runs git with listArgs, limited to paths, and returns the
+// listed files the ignore filter of ctx excludes. Git lists NUL-terminated
+// paths relative to the top of the repository; status entries carry a
+// status prefix, which is dropped.
+func gitIgnoredFiles(ctx context.Context, listArgs, paths []string) ([]string, error) {
+	ignore := IgnoreFrom(ctx)
+	if ignore == nil {
+		return nil, nil
+	}
+	top, err := runGit(ctx, "rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(ctx, append(append(append([]string(nil), listArgs...), "--"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	var ignored []string
+	for _, name := range strings.Split(out, "\x00") {
+		if listArgs[0] == "status" && len(name) > 3 {
+			name = name[3:]
+		}
+		if name != "" && ignore.Ignored(gitWorkPath(ctx, filepath.Join(top, filepath.FromSlash(name)))) {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored, nil
+}
+
+// gitWorkPath returns path as seen from the directory git runs in.
+func gitWorkPath(ctx context.Context, path string) string {
+	if sandbox := SandboxFrom(ctx); sandbox != nil && sandbox.Workdir() != "" && !filepath.IsAbs(path) {
+		return filepath.Join(sandbox.Workdir(), path)
+	}
+	return path
+}
+
+// runGit runs git with args for a git tool and returns its output. Failures
+// carry git's output, e.g. "nothing to commit".
+func runGit(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = CommandEnv(ctx)
+	if sandbox := SandboxFrom(ctx); sandbox != nil {
+		cmd.Env = sandbox.Environ(cmd.Env)
+		cmd.Dir = sandbox.Workdir()
+	}
+	cmd.WaitDelay = time.Second
+	out, err := cleanup.From(ctx).CombinedOutput(cmd)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("git %s failed: %s", args[0], strings.TrimSpace(string(out))), err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func registerGitTools(reg *ToolRegistry) {
+	reg.RegisterTool(ToolSchema{
+		Name:        "git_status",
+		Description: "Shows the current branch and the changed, staged and untracked files.",
+		Arguments: []ToolArgument{
+			{Name: "path", Type: "string", Description: "Limit the status to this file or directory."},
+		},
+	}, &GitTool{Op: "status"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "git_diff",
+		Description: "Shows the changes of the working tree, or the staged changes, as a unified diff.",
+		Arguments: []ToolArgument{
+			{Name: "path", Type: "string", Description: "Limit the diff to this file or directory."},
+			{Name: "staged", Type: "bool", Description: "Show the staged changes instead of the unstaged ones."},
+			{Name: "ref", Type: "string", Description: "Compare against this revision, e.g. HEAD~1 or main."},
+			{Name: "stat", Type: "bool", Description: "Show only a summary of the changed files."},
+		},
+	}, &GitTool{Op: "diff"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "git_commit",
+		Description: "Commits changes. Stages the given files, or all changes when all is true; otherwise commits what is already staged.",
+		Arguments: []ToolArgument{
+			{Name: "message", Type: "string", Required: true, Description: "Commit message."},
+			{Name: "files", Type: "array", Description: "Files to stage before committing."},
+			{Name: "all", Type: "bool", Description: "Stage all changes, including new and deleted files."},
+		},
+	}, &GitTool{Op: "commit"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "git_branch",
+		Description: "Lists the branches, or creates a branch at HEAD when a name is given.",
+		Arguments: []ToolArgument{
+			{Name: "name", Type: "string", Description: "Name of the branch to create."},
+		},
+	}, &GitTool{Op: "branch"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "git_checkout",
+		Description: "Switches to a branch, or creates it first when create is true. Does not restore files.",
+		Arguments: []ToolArgument{
+			{Name: "branch", Type: "string", Required: true, Description: "Branch to switch to."},
+			{Name: "create", Type: "bool", Description: "Create the branch at HEAD first."},
+		},
+	}, &GitTool{Op: "checkout"})
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGitSnapshot_KeepsWorkingTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	os.WriteFile("a.txt", []byte("draft"), 0644)
+	exec.Command("git", "add", "a.txt").Run()
+
+	ref, sha, err := GitSnapshot("test")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(ref, "refs/ai-team/snapshots/") || sha == "" {
+		t.Errorf("unexpected snapshot %s %s", ref, sha)
+	}
+	if out, _ := exec.Command("git", "show", sha+":a.txt").Output(); string(out) != "draft" {
+		t.Errorf("expected snapshot to contain staged file, got %q", out)
+	}
+	if data, _ := os.ReadFile("a.txt"); string(data) != "draft" {
+		t.Errorf("expected working tree to be untouched")
+	}
+	if out, _ := exec.Command("git", "stash", "list").Output(); len(out) != 0 {
+		t.Errorf("expected stash list to stay empty, got %s", out)
+	}
+}
+
+func TestGitTools(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@example.com")
+	if out, err := exec.Command("git", "init", "-q", "-b", "main").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	te := &ToolExecutor{Registry: reg}
+	call := func(name string, args map[string]interface{}) (string, error) {
+		result, err := te.Execute(context.Background(), ToolCall{Name: name, Arguments: args})
+		s, _ := result.(string)
+		return s, err
+	}
+
+	os.WriteFile("a.txt", []byte("one\n"), 0644)
+	if out, err := call("git_status", map[string]interface{}{}); err != nil || !strings.Contains(out, "?? a.txt") {
+		t.Fatalf("status = %q, %v", out, err)
+	}
+	if out, err := call("git_commit", map[string]interface{}{"message": "add a", "files": []interface{}{"a.txt"}}); err != nil || !strings.Contains(out, "add a") {
+		t.Fatalf("commit = %q, %v", out, err)
+	}
+	if _, err := call("git_commit", map[string]interface{}{"message": "again", "all": true}); err == nil || !strings.Contains(err.Error(), "nothing") {
+		t.Errorf("commit without changes: %v, want git's output", err)
+	}
+
+	os.WriteFile("a.txt", []byte("two\n"), 0644)
+	if out, err := call("git_diff", map[string]interface{}{"path": "a.txt"}); err != nil || !strings.Contains(out, "+two") {
+		t.Errorf("diff = %q, %v", out, err)
+	}
+	if out, err := call("git_diff", map[string]interface{}{"staged": true}); err != nil || out != "No changes." {
+		t.Errorf("staged diff = %q, %v", out, err)
+	}
+
+	if _, err := call("git_checkout", map[string]interface{}{"branch": "feature", "create": true}); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := call("git_branch", map[string]interface{}{}); err != nil || !strings.Contains(out, "* feature") {
+		t.Errorf("branches = %q, %v", out, err)
+	}
+	if _, err := call("git_checkout", map[string]interface{}{"branch": "main"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Options and invalid names are refused before git runs.
+	for _, c := range []struct {
+		name string
+		args map[string]interface{}
+	}{
+		{"git_checkout", map[string]interface{}{"branch": "--orphan"}},
+		{"git_checkout", map[string]interface{}{"branch": "a..b"}},
+		{"git_branch", map[string]interface{}{"name": "-D"}},
+		{"git_diff", map[string]interface{}{"ref": "--output=x"}},
+		{"git_diff", map[string]interface{}{"path": "--output=x"}},
+		{"git_commit", map[string]interface{}{"message": "x", "files": []interface{}{"-A"}}},
+		{"git_commit", map[string]interface{}{"message": "  "}},
+	} {
+		if _, err := call(c.name, c.args); err == nil {
+			t.Errorf("%s %v: expected an error", c.name, c.args)
+		}
+	}
+	if _, err := os.Stat("x"); err == nil {
+		t.Error("a refused option was passed to git")
+	}
+}
+
+func TestGitTools_Ignore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@example.com")
+	if out, err := exec.Command("git", "init", "-q", "-b", "main").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	os.WriteFile(".env", []byte("TOKEN=1\n"), 0644)
+	os.Mkdir("secrets", 0755)
+	os.WriteFile("secrets/key", []byte("key\n"), 0644)
+	os.WriteFile("main.go", []byte("package main\n"), 0644)
+	os.WriteFile(AccessIgnoreFile, []byte("secrets/\n"), 0644)
+	exec.Command("git", "add", ".env", "main.go").Run()
+
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	te := &ToolExecutor{Registry: reg, Ignore: NewIgnoreFilter(".", []string{".env"})}
+	call := func(name string, args map[string]interface{}) (string, error) {
+		result, err := te.Execute(context.Background(), ToolCall{Name: name, Arguments: args})
+		s, _ := result.(string)
+		return s, err
+	}
+
+	if out, err := call("git_status", map[string]interface{}{}); err != nil || strings.Contains(out, ".env") || strings.Contains(out, "secrets") || !strings.Contains(out, "main.go") {
+		t.Errorf("status = %q, %v", out, err)
+	}
+	if out, err := call("git_diff", map[string]interface{}{"staged": true}); err != nil || strings.Contains(out, "TOKEN") || !strings.Contains(out, "+package main") {
+		t.Errorf("staged diff = %q, %v", out, err)
+	}
+	for _, args := range []map[string]interface{}{
+		{"path": "secrets/key"},
+		{"files": []interface{}{"main.go", ".env"}},
+	} {
+		if _, err := call("git_diff", args); err == nil || !strings.Contains(err.Error(), "excluded by ignore rules") {
+			t.Errorf("diff %v: %v, want the ignored path refused", args, err)
+		}
+	}
+	if _, err := call("git_commit", map[string]interface{}{"message": "staged", "files": []interface{}{"main.go"}}); err == nil || !strings.Contains(err.Error(), ".env") {
+		t.Errorf("commit with .env staged: %v, want it refused", err)
+	}
+	exec.Command("git", "reset", "-q").Run()
+
+	if _, err := call("git_commit", map[string]interface{}{"message": "all", "all": true}); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := exec.Command("git", "ls-files").Output()
+	if got := strings.Fields(string(out)); strings.Join(got, " ") != AccessIgnoreFile+" main.go" {
+		t.Errorf("committed %v, want the ignored files left out", got)
+	}
+}
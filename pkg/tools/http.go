@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Limits of http_request when the config sets none.
+const (
+	DefaultHTTPMaxBytes = 1 << 20
+	DefaultHTTPTimeout  = 30 * time.Second
+)
+
+// HTTPRequestTool sends an HTTP request for the model, e.g. to fetch
+// documentation or an API spec. Only hosts matching AllowedDomains may be
+// requested, redirects included; with none, every request is refused.
+type HTTPRequestTool struct {
+	AllowedDomains []string      // Hosts such as docs.example.com; *.example.com matches its subdomains
+	MaxBytes       int64         // Response bodies are cut after this many bytes
+	Timeout        time.Duration // Limit on the whole request, body included
+}
+
+// Configure applies the http_request settings of the config; zero limits
+// keep the defaults.
+func (t *HTTPRequestTool) Configure(cfg types.HTTPRequestConfig) {
+	t.AllowedDomains = cfg.AllowedDomains
+	t.MaxBytes = cfg.MaxBytes
+	t.Timeout = cfg.Timeout
+}
+
+// Allowed reports whether requests to host are permitted.
+func (t *HTTPRequestTool) Allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range t.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute sends the request and returns the status line, the
+// response headers and the body, cut at MaxBytes. Error statuses are
+// results too, so the model sees what the server answered.
+func (t *HTTPRequestTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	method := strings.ToUpper(stringArg(args, "method"))
+	switch method {
+	case "":
+		method = http.MethodGet
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("http_request does not support method '%s'", method), nil)
+	}
+	rawURL := stringArg(args, "url")
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("http_request needs an http(s) URL, got '%s'", rawURL), err)
+	}
+	if err := t.check(u); err != nil {
+		return nil, err
+	}
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var body io.Reader
+	if s := stringArg(args, "body"); s != "" {
+		body = strings.NewReader(s)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid http_request %s %s", method, rawURL), err)
+	}
+	if v, ok := lookupArgFlexible(args, "headers"); ok && v != nil {
+		headers, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrCodeTool, "http_request headers must be an object of strings", nil)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	client := &http.Client{CheckRedirect: func(next *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New(errors.ErrCodeTool, "http_request stopped after 10 redirects", nil)
+		}
+		return t.check(next.URL)
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("http_request %s %s failed", method, rawURL), err)
+	}
+	defer resp.Body.Close()
+
+	maxBytes := t.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultHTTPMaxBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to read the response of %s %s", method, rawURL), err)
+	}
+	truncated := int64(len(data)) > maxBytes
+	if truncated {
+		data = data[:maxBytes]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", resp.Proto, resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(resp.Header[name], ", "))
+	}
+	b.WriteString("\n")
+	b.Write(data)
+	if truncated {
+		fmt.Fprintf(&b, "\n[response truncated after %d bytes]", maxBytes)
+	}
+	return b.String(), nil
+}
+
+// check refuses URLs whose host is not allowed.
+func (t *HTTPRequestTool) check(u *url.URL) error {
+	if !t.Allowed(u.Hostname()) {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("http_request refused: host '%s' is not in http_request.allowed_domains", u.Hostname()), nil)
+	}
+	return nil
+}
+
+func registerHTTPTool(reg *ToolRegistry, tool *HTTPRequestTool) {
+	reg.RegisterTool(ToolSchema{
+		Name:        "http_request",
+		Description: "Sends an HTTP request to an allowed domain, e.g. to fetch documentation or an API spec, and returns the status, headers and body.",
+		Arguments: []ToolArgument{
+			{Name: "url", Type: "string", Required: true, Description: "http or https URL to request."},
+			{Name: "method", Type: "string", Description: "GET (default), HEAD, POST, PUT, PATCH or DELETE."},
+			{Name: "headers", Type: "object", Description: "Request headers by name."},
+			{Name: "body", Type: "string", Description: "Request body."},
+		},
+	}, tool)
+}
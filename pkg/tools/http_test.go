@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequestTool_Allowed(t *testing.T) {
+	tool := &HTTPRequestTool{AllowedDomains: []string{"docs.example.com", "*.golang.org"}}
+	for host, want := range map[string]bool{
+		"docs.example.com":  true,
+		"DOCS.example.com.": true,
+		"example.com":       false,
+		"pkg.golang.org":    true,
+		"golang.org":        false,
+		"evilgolang.org":    false,
+	} {
+		if got := tool.Allowed(host); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if (&HTTPRequestTool{}).Allowed("example.com") {
+		t.Error("expected no domains to refuse every host")
+	}
+}
+
+func TestHTTPRequestTool_Execute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Write([]byte(r.Header.Get("Authorization") + " " + string(body)))
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/away":
+			http.Redirect(w, r, "http://elsewhere.invalid/", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	reg.HTTP().AllowedDomains = []string{"127.0.0.1"}
+	reg.HTTP().MaxBytes = 10
+	te := &ToolExecutor{Registry: reg}
+	call := func(args map[string]interface{}) (string, error) {
+		result, err := te.Execute(context.Background(), ToolCall{Name: "http_request", Arguments: args})
+		s, _ := result.(string)
+		return s, err
+	}
+
+	out, err := call(map[string]interface{}{"method": "post", "url": server.URL + "/echo", "headers": map[string]interface{}{"Authorization": "Bearer t"}, "body": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "HTTP/1.1 200 OK\n") || !strings.Contains(out, "X-Method: POST\n") || !strings.HasSuffix(out, "\n\nBearer t h\n[response truncated after 10 bytes]") {
+		t.Errorf("response = %q", out)
+	}
+	if out, err := call(map[string]interface{}{"url": server.URL + "/missing"}); err != nil || !strings.Contains(out, "404 Not Found") {
+		t.Errorf("error status = %q, %v; want it as the result", out, err)
+	}
+	if _, err := call(map[string]interface{}{"url": server.URL + "/away"}); err == nil || !strings.Contains(err.Error(), "elsewhere.invalid") {
+		t.Errorf("redirect to another host: %v", err)
+	}
+	if _, err := call(map[string]interface{}{"url": "https://example.com/"}); err == nil || !strings.Contains(err.Error(), "allowed_domains") {
+		t.Errorf("disallowed host: %v", err)
+	}
+	if _, err := call(map[string]interface{}{"url": "file:///etc/passwd"}); err == nil {
+		t.Error("expected non-http URLs to be refused")
+	}
+	if _, err := call(map[string]interface{}{"method": "CONNECT", "url": server.URL}); err == nil {
+		t.Error("expected unsupported methods to be refused")
+	}
+
+	reg.HTTP().Timeout = 50 * time.Millisecond
+	if _, err := call(map[string]interface{}{"url": server.URL + "/slow"}); err == nil {
+		t.Error("expected the request to time out")
+	}
+}
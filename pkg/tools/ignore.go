@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessIgnoreFile lists paths that tools must neither read, list nor modify.
+const AccessIgnoreFile = ".ai-teamignore"
+
+// IgnoreFiles are read from every listed directory when ignore rules are respected.
+var IgnoreFiles = []string{".gitignore", AccessIgnoreFile}
+
+// ignoreRule is a single compiled line of an ignore file.
+type ignoreRule struct {
+	base     string // Directory of the ignore file, relative to the listed root
+	re       *regexp.Regexp
+	anchored bool // Pattern contains a slash and matches relative to base
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreMatcher accumulates ignore rules from the directories visited so far.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+func (m *ignoreMatcher) load(dir, rel string, names ...string) {
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(scanner.Text(), rel); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+		f.Close()
+	}
+}
+
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	re, err := regexp.Compile("^" + globToRegex(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// ignored reports whether path should be skipped; the last matching rule wins.
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := path
+		if r.base != "" {
+			if !strings.HasPrefix(path, r.base+"/") {
+				continue
+			}
+			sub = path[len(r.base)+1:]
+		}
+		target := sub
+		if !r.anchored {
+			target = sub[strings.LastIndex(sub, "/")+1:]
+		}
+		if r.re.MatchString(target) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// IgnoreFilter decides whether tools may access a path. It applies
+// .ai-teamignore files found between Root and the path, plus extra
+// gitignore-style patterns (typically from config) relative to Root.
+type IgnoreFilter struct {
+	Root  string
+	extra []ignoreRule
+
+	mu   sync.Mutex
+	dirs map[string]dirRules // Rules of each directory's ignore file, by path relative to Root
+}
+
+// dirRules are the compiled rules of one directory's ignore file. The file's
+// size and modification time tell whether it changed since; size is -1 when
+// there is no file.
+type dirRules struct {
+	size    int64
+	modTime time.Time
+	rules   []ignoreRule
+}
+
+// NewIgnoreFilter creates a filter rooted at root with additional patterns.
+func NewIgnoreFilter(root string, patterns []string) *IgnoreFilter {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	f := &IgnoreFilter{Root: root, dirs: map[string]dirRules{}}
+	for _, p := range patterns {
+		if rule, ok := parseIgnoreLine(p, ""); ok {
+			f.extra = append(f.extra, rule)
+		}
+	}
+	return f
+}
+
+// Ignored reports whether path, or any directory containing it, is excluded.
+// Paths outside Root are never ignored.
+func (f *IgnoreFilter) Ignored(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(f.Root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	m := &ignoreMatcher{rules: append(append([]ignoreRule(nil), f.extra...), f.rulesOf("")...)}
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		isDir := i < len(parts)-1
+		if !isDir {
+			if info, err := os.Stat(abs); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if m.ignored(prefix, isDir) {
+			return true
+		}
+		if isDir {
+			m.rules = append(m.rules, f.rulesOf(prefix)...)
+		}
+	}
+	return false
+}
+
+// rulesOf returns the rules of the ignore file in directory rel, read once
+// and again only when the file changes.
+func (f *IgnoreFilter) rulesOf(rel string) []ignoreRule {
+	dir := filepath.Join(f.Root, filepath.FromSlash(rel))
+	key := dirRules{size: -1}
+	if info, err := os.Stat(filepath.Join(dir, AccessIgnoreFile)); err == nil {
+		key.size, key.modTime = info.Size(), info.ModTime()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cached, ok := f.dirs[rel]; ok && cached.size == key.size && cached.modTime.Equal(key.modTime) {
+		return cached.rules
+	}
+	if key.size >= 0 {
+		m := &ignoreMatcher{}
+		m.load(dir, rel, AccessIgnoreFile)
+		key.rules = m.rules
+	}
+	if f.dirs == nil {
+		f.dirs = map[string]dirRules{}
+	}
+	f.dirs[rel] = key
+	return key.rules
+}
+
+type ignoreKey struct{}
+
+// WithIgnore returns ctx carrying the ignore filter of the tools run with it.
+func WithIgnore(ctx context.Context, f *IgnoreFilter) context.Context {
+	return context.WithValue(ctx, ignoreKey{}, f)
+}
+
+// IgnoreFrom returns the ignore filter carried by ctx, or nil.
+func IgnoreFrom(ctx context.Context) *IgnoreFilter {
+	f, _ := ctx.Value(ignoreKey{}).(*IgnoreFilter)
+	return f
+}
+
+// filterListing removes ignored entries from a list_dir result for the listed dir.
+func (f *IgnoreFilter) filterListing(dir string, result interface{}) interface{} {
+	switch entries := result.(type) {
+	case []string:
+		kept := make([]string, 0, len(entries))
+		for _, name := range entries {
+			if !f.Ignored(filepath.Join(dir, strings.TrimSuffix(name, "/"))) {
+				kept = append(kept, name)
+			}
+		}
+		return kept
+	case []DirEntry:
+		kept := make([]DirEntry, 0, len(entries))
+		for _, e := range entries {
+			if !f.Ignored(filepath.Join(dir, filepath.FromSlash(e.Path))) {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIgnoreFilter_Ignored(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".ai-teamignore":         "secrets/\n*.pem\n",
+		"secrets/token.txt":      "x",
+		"keys/server.pem":        "x",
+		"node_modules/lib/a.js":  "x",
+		"src/main.go":            "x",
+		"src/gen/.ai-teamignore": "*.pb.go\n",
+		"src/gen/api.pb.go":      "x",
+		"src/gen/api.go":         "x",
+	})
+	f := NewIgnoreFilter(root, []string{"node_modules/"})
+
+	for _, p := range []string{"secrets", "secrets/token.txt", "keys/server.pem", "node_modules/lib/a.js", "src/gen/api.pb.go"} {
+		if !f.Ignored(filepath.Join(root, p)) {
+			t.Errorf("expected %s to be ignored", p)
+		}
+	}
+	for _, p := range []string{"src/main.go", "src/gen/api.go", "keys", "new/file.txt"} {
+		if f.Ignored(filepath.Join(root, p)) {
+			t.Errorf("expected %s to be accessible", p)
+		}
+	}
+	if f.Ignored(filepath.Join(filepath.Dir(root), "outside.pem")) {
+		t.Errorf("expected paths outside root to be accessible")
+	}
+}
+
+func TestIgnoreFilter_CachesRules(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{".ai-teamignore": "*.pem\n", "a.pem": "x", "b.key": "x"})
+	f := NewIgnoreFilter(root, nil)
+	if !f.Ignored(filepath.Join(root, "a.pem")) || f.Ignored(filepath.Join(root, "b.key")) {
+		t.Fatal("expected only a.pem to be ignored")
+	}
+	if cached := f.dirs[""]; len(cached.rules) != 1 {
+		t.Fatalf("expected the root rules cached, got %+v", cached)
+	}
+
+	ignoreFile := filepath.Join(root, ".ai-teamignore")
+	os.WriteFile(ignoreFile, []byte("*.key\n"), 0644)
+	os.Chtimes(ignoreFile, time.Now(), time.Now().Add(time.Minute))
+	if f.Ignored(filepath.Join(root, "a.pem")) || !f.Ignored(filepath.Join(root, "b.key")) {
+		t.Error("expected a changed ignore file to be read again")
+	}
+	os.Remove(ignoreFile)
+	if f.Ignored(filepath.Join(root, "b.key")) {
+		t.Error("expected the rules of a removed ignore file to be dropped")
+	}
+}
+
+func TestToolExecutor_IgnoreRejectsAndFilters(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".ai-teamignore": "secret.txt\n",
+		"secret.txt":     "s",
+		"vendor/v.go":    "v",
+		"main.go":        "m",
+	})
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	exec := &ToolExecutor{Registry: reg, Ignore: NewIgnoreFilter(root, []string{"vendor"})}
+
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": filepath.Join(root, "secret.txt")}}); err == nil {
+		t.Fatalf("expected read of ignored file to be rejected")
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": filepath.Join(root, "vendor", "x.go"), "content": "x"}}); err == nil {
+		t.Fatalf("expected write into ignored directory to be rejected")
+	}
+
+	result, err := exec.Execute(context.Background(), ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"path": root}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	names, _ := result.([]string)
+	for _, n := range names {
+		if n == "secret.txt" || n == "vendor/" {
+			t.Errorf("expected %s to be hidden from listing, got %v", n, names)
+		}
+	}
+	if len(names) != 2 {
+		t.Errorf("expected .ai-teamignore and main.go only, got %v", names)
+	}
+}
+
+func TestToolExecutor_IgnoreSeesToolPath(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".ai-teamignore":  "secrets/\n",
+		"secrets/key.txt": "k",
+		"src/main.go":     "m",
+	})
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	exec := &ToolExecutor{Registry: reg, Ignore: NewIgnoreFilter(root, nil)}
+
+	call := ToolCall{Name: "write_file", Arguments: map[string]interface{}{
+		"file_path": filepath.Join(root, "ok.txt"),
+		"filePath":  filepath.Join(root, "secrets", "key.txt"),
+		"content":   "pwned",
+	}}
+	if _, err := exec.Execute(context.Background(), call); err == nil {
+		t.Fatalf("expected write with conflicting path aliases to be rejected")
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "secrets", "key.txt")); string(data) != "k" {
+		t.Errorf("expected ignored file to stay unchanged, got %q", data)
+	}
+
+	call = ToolCall{Name: "list_dir", Arguments: map[string]interface{}{
+		"file_path": filepath.Join(root, "src"),
+		"path":      filepath.Join(root, "secrets"),
+	}}
+	if result, err := exec.Execute(context.Background(), call); err == nil {
+		t.Fatalf("expected listing of ignored directory to be rejected, got %v", result)
+	}
+
+	call = ToolCall{Name: "list_dir", Arguments: map[string]interface{}{
+		"path":      filepath.Join(root, "src"),
+		"directory": filepath.Join(root, "secrets"),
+	}}
+	if result, err := exec.Execute(context.Background(), call); err == nil {
+		t.Fatalf("expected list_dir with conflicting path aliases to be rejected, got %v", result)
+	}
+}
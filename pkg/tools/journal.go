@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+)
+
+// DefaultJournalPath is the write-ahead journal used when none is configured.
+const DefaultJournalPath = ".ai-team/journal.jsonl"
+
+// Journal entry phases.
+const (
+	JournalBegin = "begin"
+	JournalEnd   = "end"
+)
+
+// JournalEntry is one line of the journal. A begin entry is written, and
+// synced, before a file is modified and holds what is needed to undo the
+// change; the end entry with the same ID follows once the tool returns. A
+// begin entry without an end marks a modification interrupted by a crash.
+type JournalEntry struct {
+	ID           string    `json:"id"`
+	Phase        string    `json:"phase"`
+	Op           string    `json:"op,omitempty"` // Tool name, e.g. write_file
+	Path         string    `json:"path,omitempty"`
+	PID          int       `json:"pid,omitempty"`
+	Time         time.Time `json:"time"`
+	Existed      bool      `json:"existed,omitempty"`       // Whether the file existed before
+	OriginalHash string    `json:"original_hash,omitempty"` // sha256 of the original content
+	Backup       string    `json:"backup,omitempty"`        // Copy of the original content
+}
+
+// Journal records file modifications ahead of time so that changes half
+// applied when a process dies can be reported and reverted.
+type Journal struct {
+	Path string
+	Dir  string // Where original contents are kept until their entry ends
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewJournal returns a journal at path (DefaultJournalPath when empty) keeping
+// originals in a directory next to it.
+func NewJournal(path string) *Journal {
+	if path == "" {
+		path = DefaultJournalPath
+	}
+	ext := filepath.Ext(path)
+	return &Journal{Path: path, Dir: path[:len(path)-len(ext)] + ".d"}
+}
+
+// Begin records that op is about to modify path, saving the current content,
+// and returns the entry ID to pass to End.
+func (j *Journal) Begin(op, path string) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	entry := JournalEntry{
+		ID:    fmt.Sprintf("%d-%d-%d", os.Getpid(), time.Now().UnixNano(), j.seq),
+		Phase: JournalBegin,
+		Op:    op,
+		Path:  path,
+		PID:   os.Getpid(),
+		Time:  time.Now(),
+	}
+	original, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := os.MkdirAll(j.Dir, 0755); err != nil {
+			return "", errors.New(errors.ErrCodeTool, "failed to create journal directory "+j.Dir, err)
+		}
+		entry.Existed = true
+		entry.OriginalHash = hashContent(original)
+		entry.Backup = filepath.Join(j.Dir, entry.ID+".orig")
+		if err := writeSynced(entry.Backup, original); err != nil {
+			return "", errors.New(errors.ErrCodeTool, "failed to save original of "+path, err)
+		}
+	case !os.IsNotExist(err):
+		return "", errors.New(errors.ErrCodeTool, "failed to read "+path+" for the journal", err)
+	}
+	if err := j.append(entry, true); err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// End records that the modification begun as id has finished, successfully
+// or not, and drops the saved original.
+func (j *Journal) End(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.append(JournalEntry{ID: id, Phase: JournalEnd, Time: time.Now()}, false); err == nil {
+		os.Remove(filepath.Join(j.Dir, id+".orig"))
+	}
+}
+
+func (j *Journal) append(entry JournalEntry, sync bool) error {
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0755); err != nil {
+		return errors.New(errors.ErrCodeTool, "failed to create journal directory", err)
+	}
+	f, err := os.OpenFile(j.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.New(errors.ErrCodeTool, "failed to open journal "+j.Path, err)
+	}
+	defer f.Close()
+	line, _ := json.Marshal(entry)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.New(errors.ErrCodeTool, "failed to write journal "+j.Path, err)
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			return errors.New(errors.ErrCodeTool, "failed to sync journal "+j.Path, err)
+		}
+	}
+	return nil
+}
+
+// Incomplete returns the begin entries without an end, oldest first, skipping
+// those of processes that are still running.
+func (j *Journal) Incomplete() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries, err := j.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	var open []JournalEntry
+	for _, e := range entries {
+		if e.PID != os.Getpid() && processAlive(e.PID) {
+			continue
+		}
+		open = append(open, e)
+	}
+	return open, nil
+}
+
+// readLocked returns the begin entries without an end.
+func (j *Journal) readLocked() ([]JournalEntry, error) {
+	f, err := os.Open(j.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to open journal "+j.Path, err)
+	}
+	defer f.Close()
+	var order []string
+	begun := map[string]JournalEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e JournalEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue // A line torn by a crash
+		}
+		switch e.Phase {
+		case JournalBegin:
+			order = append(order, e.ID)
+			begun[e.ID] = e
+		case JournalEnd:
+			delete(begun, e.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, "failed to read journal "+j.Path, err)
+	}
+	var open []JournalEntry
+	for _, id := range order {
+		if e, ok := begun[id]; ok {
+			open = append(open, e)
+		}
+	}
+	return open, nil
+}
+
+// Compact rewrites the journal keeping only entries without an end.
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	open, err := j.readLocked()
+	if err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		if err := os.Remove(j.Path); err != nil && !os.IsNotExist(err) {
+			return errors.New(errors.ErrCodeTool, "failed to remove journal "+j.Path, err)
+		}
+		return nil
+	}
+	var data []byte
+	for _, e := range open {
+		line, _ := json.Marshal(e)
+		data = append(append(data, line...), '\n')
+	}
+	tmp := j.Path + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		return errors.New(errors.ErrCodeTool, "failed to compact journal "+j.Path, err)
+	}
+	if err := os.Rename(tmp, j.Path); err != nil {
+		return errors.New(errors.ErrCodeTool, "failed to compact journal "+j.Path, err)
+	}
+	return nil
+}
+
+// JournalState describes the file of an interrupted modification now.
+type JournalState string
+
+// Journal states reported by State.
+const (
+	StateUnchanged JournalState = "unchanged" // Still the original content
+	StateModified  JournalState = "modified"  // Differs from the original, possibly half written
+	StateCreated   JournalState = "created"   // Did not exist before
+	StateMissing   JournalState = "missing"   // Existed before and is gone
+)
+
+// State compares the entry's file with its original.
+func (e JournalEntry) State() JournalState {
+	current, err := os.ReadFile(e.Path)
+	switch {
+	case err != nil && !e.Existed:
+		return StateUnchanged
+	case err != nil:
+		return StateMissing
+	case !e.Existed:
+		return StateCreated
+	case hashContent(current) == e.OriginalHash:
+		return StateUnchanged
+	default:
+		return StateModified
+	}
+}
+
+// Revert restores the entry's file to its original content, removing it if it
+// did not exist, and ends the entry.
+func (j *Journal) Revert(e JournalEntry) error {
+	if e.Existed {
+		original, err := os.ReadFile(e.Backup)
+		if err != nil {
+			return errors.New(errors.ErrCodeTool, "original of "+e.Path+" is not available", err)
+		}
+		if hashContent(original) != e.OriginalHash {
+			return errors.New(errors.ErrCodeTool, "saved original of "+e.Path+" is damaged", nil)
+		}
+		if err := writeSynced(e.Path, original); err != nil {
+			return errors.New(errors.ErrCodeTool, "failed to restore "+e.Path, err)
+		}
+	} else if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+		return errors.New(errors.ErrCodeTool, "failed to remove "+e.Path, err)
+	}
+	j.End(e.ID)
+	return nil
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeSynced writes data to path and flushes it to disk.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal_RevertsInterruptedWrites(t *testing.T) {
+	dir := t.TempDir()
+	journal := NewJournal(filepath.Join(dir, "journal.jsonl"))
+	existing := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	if err := os.WriteFile(existing, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A finished write leaves nothing to recover.
+	id, err := journal.Begin("write_file", existing)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	journal.End(id)
+
+	// Two writes interrupted before End, as by a crash.
+	if _, err := journal.Begin("write_file", existing); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	os.WriteFile(existing, []byte("half writ"), 0644)
+	if _, err := journal.Begin("write_file", created); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	os.WriteFile(created, []byte("package x"), 0644)
+
+	entries, err := journal.Incomplete()
+	if err != nil {
+		t.Fatalf("Incomplete: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 interrupted writes, got %+v", entries)
+	}
+	if entries[0].State() != StateModified || entries[1].State() != StateCreated {
+		t.Fatalf("unexpected states %s, %s", entries[0].State(), entries[1].State())
+	}
+
+	for _, e := range entries {
+		if err := journal.Revert(e); err != nil {
+			t.Fatalf("Revert %s: %v", e.Path, err)
+		}
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "original" {
+		t.Errorf("expected the original content restored, got %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected the created file to be removed, got %v", err)
+	}
+	if entries, _ := journal.Incomplete(); len(entries) != 0 {
+		t.Errorf("expected no interrupted writes after revert, got %+v", entries)
+	}
+	if err := journal.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if _, err := os.Stat(journal.Path); !os.IsNotExist(err) {
+		t.Errorf("expected an empty journal to be removed, got %v", err)
+	}
+}
+
+func TestToolExecutor_JournalsWrites(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.txt")
+	journal := NewJournal(filepath.Join(dir, "journal.jsonl"))
+	registry := NewToolRegistry()
+	registry.RegisterTool(ToolSchema{Name: "write_file"}, journalCheckingTool{func(args map[string]interface{}) (interface{}, error) {
+		// The write is in flight: its begin entry must already be on disk.
+		if entries, _ := journal.Incomplete(); len(entries) != 1 || entries[0].Path != target {
+			t.Errorf("expected the write to be journaled before it runs, got %+v", entries)
+		}
+		return "ok", os.WriteFile(target, []byte(args["content"].(string)), 0644)
+	}})
+	executor := &ToolExecutor{Registry: registry, Journal: journal}
+	call := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": target, "content": "x"}}
+	if _, err := executor.Execute(context.Background(), call); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if entries, _ := journal.Incomplete(); len(entries) != 0 {
+		t.Errorf("expected the finished write to be closed in the journal, got %+v", entries)
+	}
+}
+
+type journalCheckingTool struct {
+	fn func(args map[string]interface{}) (interface{}, error)
+}
+
+func (j journalCheckingTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	return j.fn(args)
+}
@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-team/pkg/errors"
+)
+
+// DirEntry describes a single file system entry returned by ListDirWithOptions.
+type DirEntry struct {
+	Path    string    `json:"path"` // Relative to the listed root, slash-separated
+	Type    string    `json:"type"` // "file", "dir" or "symlink"
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// ListDirOptions controls recursive directory listing.
+type ListDirOptions struct {
+	Recursive bool
+	MaxDepth  int      // Levels below the root to descend when Recursive (0 = unlimited)
+	Include   []string // Glob patterns a file must match (on its path or name) to be listed
+	Exclude   []string // Glob patterns of files and directories to leave out
+	NoIgnore  bool     // Do not apply .gitignore/.ai-teamignore rules
+}
+
+// ListDirWithOptions lists root, optionally recursively, honouring glob filters
+// and ignore files. The .git directory is always skipped. Entries are sorted by path.
+func ListDirWithOptions(root string, opts ListDirOptions) ([]DirEntry, error) {
+	return ListDirWithOptionsContext(context.Background(), root, opts)
+}
+
+// ListDirWithOptionsContext is ListDirWithOptions stopping between
+// directories once ctx is done.
+func ListDirWithOptionsContext(ctx context.Context, root string, opts ListDirOptions) ([]DirEntry, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to list directory %s", root), err)
+	}
+	if !info.IsDir() {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("%s is not a directory", root), nil)
+	}
+	include, err := compileGlobs(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &dirLister{ctx: ctx, root: root, opts: opts, include: include, exclude: exclude, ignore: &ignoreMatcher{}}
+	if err := l.walk("", 1); err != nil {
+		return nil, err
+	}
+	sort.Slice(l.entries, func(i, j int) bool { return l.entries[i].Path < l.entries[j].Path })
+	return l.entries, nil
+}
+
+type dirLister struct {
+	ctx              context.Context
+	root             string
+	opts             ListDirOptions
+	include, exclude []*regexp.Regexp
+	ignore           *ignoreMatcher
+	entries          []DirEntry
+}
+
+func (l *dirLister) walk(rel string, depth int) error {
+	if err := checkContext(l.ctx, "ListDir"); err != nil {
+		return err
+	}
+	dir := filepath.Join(l.root, filepath.FromSlash(rel))
+	if !l.opts.NoIgnore {
+		l.ignore.load(dir, rel, IgnoreFiles...)
+	}
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to list directory %s", dir), err)
+	}
+	for _, item := range items {
+		path := item.Name()
+		if rel != "" {
+			path = rel + "/" + path
+		}
+		isDir := item.IsDir()
+		if isDir && item.Name() == ".git" {
+			continue
+		}
+		if !l.opts.NoIgnore && l.ignore.ignored(path, isDir) {
+			continue
+		}
+		if matchesAny(l.exclude, path) {
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		entry := DirEntry{Path: path, Type: "file", Size: info.Size(), ModTime: info.ModTime()}
+		switch {
+		case isDir:
+			entry.Type = "dir"
+			entry.Size = 0
+		case item.Type()&os.ModeSymlink != 0:
+			entry.Type = "symlink"
+		}
+		if len(l.include) == 0 || (!isDir && matchesAny(l.include, path)) {
+			l.entries = append(l.entries, entry)
+		}
+		if isDir && l.opts.Recursive && (l.opts.MaxDepth <= 0 || depth < l.opts.MaxDepth) {
+			if err := l.walk(path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile("^" + globToRegex(p) + "$")
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid glob pattern %q", p), err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// matchesAny reports whether a slash-separated path or its base name matches any pattern.
+func matchesAny(patterns []*regexp.Regexp, path string) bool {
+	base := path[strings.LastIndex(path, "/")+1:]
+	for _, re := range patterns {
+		if re.MatchString(path) || re.MatchString(base) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegex translates a gitignore-style glob ("*", "?", "**", "[...]") into a regular expression.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func entryPaths(entries []DirEntry) map[string]DirEntry {
+	m := make(map[string]DirEntry, len(entries))
+	for _, e := range entries {
+		m[e.Path] = e
+	}
+	return m
+}
+
+func TestListDirWithOptions_RecursiveWithIgnores(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":           "*.log\nbuild/\n!keep.log\n",
+		".ai-teamignore":       "/secret.txt\n",
+		"main.go":              "package main",
+		"debug.log":            "x",
+		"keep.log":             "x",
+		"secret.txt":           "x",
+		"build/out.bin":        "x",
+		"pkg/a/a.go":           "package a",
+		"pkg/a/.gitignore":     "gen_*.go\n",
+		"pkg/a/gen_types.go":   "package a",
+		"pkg/a/deep/x/y.go":    "package y",
+		".git/HEAD":            "ref",
+		"docs/guide/intro.md":  "# hi",
+		"docs/guide/notes.txt": "n",
+	})
+
+	entries, err := ListDirWithOptions(root, ListDirOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	got := entryPaths(entries)
+	for _, want := range []string{"main.go", "keep.log", "pkg/a/a.go", "pkg/a/deep/x/y.go", "docs/guide/intro.md"} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("expected %s in listing", want)
+		}
+	}
+	if e := got["pkg"]; e.Type != "dir" {
+		t.Errorf("expected pkg directory entry, got %+v", e)
+	}
+	for _, unwanted := range []string{"debug.log", "secret.txt", "build", "build/out.bin", "pkg/a/gen_types.go", ".git", ".git/HEAD"} {
+		if _, ok := got[unwanted]; ok {
+			t.Errorf("expected %s to be ignored", unwanted)
+		}
+	}
+	if e := got["main.go"]; e.Type != "file" || e.Size != int64(len("package main")) || e.ModTime.IsZero() {
+		t.Errorf("unexpected entry metadata: %+v", e)
+	}
+
+	all, err := ListDirWithOptions(root, ListDirOptions{Recursive: true, NoIgnore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entryPaths(all)["debug.log"]; !ok {
+		t.Errorf("expected ignored files to be listed with NoIgnore")
+	}
+}
+
+func TestListDirWithOptions_DepthAndGlobs(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.go":          "",
+		"a_test.go":     "",
+		"sub/b.go":      "",
+		"sub/c.md":      "",
+		"sub/deep/d.go": "",
+		"vendor/v/v.go": "",
+	})
+
+	shallow, err := ListDirWithOptions(root, ListDirOptions{Recursive: true, MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entryPaths(shallow)["sub/b.go"]; ok {
+		t.Errorf("expected max depth 1 to stay at the top level")
+	}
+
+	goFiles, err := ListDirWithOptions(root, ListDirOptions{Recursive: true, Include: []string{"*.go"}, Exclude: []string{"vendor", "*_test.go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := entryPaths(goFiles)
+	if len(got) != 3 {
+		t.Errorf("expected 3 go files, got %v", got)
+	}
+	for _, want := range []string{"a.go", "sub/b.go", "sub/deep/d.go"} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("expected %s in filtered listing", want)
+		}
+	}
+}
+
+func TestListDirTool_StructuredOnlyWhenRequested(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"x/y.txt": "y"})
+	tool := &ListDirTool{}
+
+	plain, err := tool.Execute(context.Background(), map[string]interface{}{"path": root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain.([]string); !ok {
+		t.Fatalf("expected plain name listing, got %T", plain)
+	}
+
+	structured, err := tool.Execute(context.Background(), map[string]interface{}{"path": root, "recursive": true, "max_depth": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, ok := structured.([]DirEntry)
+	if !ok {
+		t.Fatalf("expected structured entries, got %T", structured)
+	}
+	if _, ok := entryPaths(entries)["x/y.txt"]; !ok {
+		t.Errorf("expected recursive listing to include x/y.txt, got %+v", entries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ListDirWithOptionsContext(ctx, root, ListDirOptions{Recursive: true}); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("expected a cancelled listing to stop, got %v", err)
+	}
+}
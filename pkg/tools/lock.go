@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/filelock"
+)
+
+// DefaultLockPath is the workspace lock file used when none is configured.
+const DefaultLockPath = ".ai-team/workspace.lock"
+
+// WorkspaceLock serializes file writes to a workspace across goroutines and
+// processes. The lock file holds the owner's PID and operation, so a run that
+// finds it taken can name the holder. On Unix the file is locked with flock,
+// which the kernel releases when its holder exits: a lock left behind by a
+// process that died is free again without anyone having to judge it stale.
+// Elsewhere the lock is the exclusively created file, and one left behind
+// must be removed by hand.
+type WorkspaceLock struct {
+	Path string
+	Wait time.Duration // How long to wait for another holder; 0 fails at once
+
+	mu sync.Mutex
+}
+
+// lockPollInterval is how often a waiting Acquire retries.
+var lockPollInterval = 50 * time.Millisecond
+
+// NewWorkspaceLock returns a lock at path (DefaultLockPath when empty).
+func NewWorkspaceLock(path string, wait time.Duration) *WorkspaceLock {
+	if path == "" {
+		path = DefaultLockPath
+	}
+	return &WorkspaceLock{Path: path, Wait: wait}
+}
+
+// Acquire takes the lock for op (e.g. "write_file main.go") and returns the
+// function that releases it. It fails with a message naming the holder when
+// the lock is still held after Wait.
+func (l *WorkspaceLock) Acquire(op string) (func(), error) {
+	l.mu.Lock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		l.mu.Unlock()
+		return nil, errors.New(errors.ErrCodeTool, "failed to create workspace lock directory", err)
+	}
+	deadline := time.Now().Add(l.Wait)
+	for {
+		f, err := filelock.TryLock(l.Path)
+		if err != nil {
+			l.mu.Unlock()
+			return nil, errors.New(errors.ErrCodeTool, "failed to create workspace lock "+l.Path, err)
+		}
+		if f != nil {
+			f.Truncate(0)
+			fmt.Fprintf(f, "%d\n%s\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339), op)
+			return func() {
+				filelock.Release(l.Path, f)
+				l.mu.Unlock()
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			l.mu.Unlock()
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf(
+				"workspace is locked by %s; another ai-team run is writing here. Wait for it to finish, or remove %s if no run is active",
+				l.holder(), l.Path), nil)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// holder describes the current lock owner from the lock file.
+func (l *WorkspaceLock) holder() string {
+	data, _ := os.ReadFile(l.Path)
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 3)
+	pid, _ := strconv.Atoi(lines[0])
+	if pid <= 0 {
+		return "another process"
+	}
+	desc := fmt.Sprintf("process %d", pid)
+	if len(lines) == 3 {
+		desc += fmt.Sprintf(" (%s since %s)", lines[2], lines[1])
+	}
+	return desc
+}
+
+// processAlive reports whether pid is a running process. Where this cannot be
+// determined the process is assumed to be alive.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || (err != os.ErrProcessDone && err != syscall.ESRCH)
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceLock_FailsFastWhenHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ws.lock")
+	first := NewWorkspaceLock(path, 0)
+	release, err := first.Acquire("write_file a.go")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	// A second lock instance stands in for another process.
+	other := NewWorkspaceLock(path, 0)
+	if _, err := other.Acquire("write_file b.go"); err == nil || !strings.Contains(err.Error(), "write_file a.go") {
+		t.Fatalf("expected a locked error naming the holder, got %v", err)
+	}
+
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the lock file to be removed on release, got %v", err)
+	}
+	release2, err := other.Acquire("write_file b.go")
+	if err != nil {
+		t.Fatalf("expected the lock to be free after release, got %v", err)
+	}
+	release2()
+}
+
+func TestWorkspaceLock_WaitsForHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ws.lock")
+	release, err := NewWorkspaceLock(path, 0).Acquire("first")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		release()
+	}()
+	release2, err := NewWorkspaceLock(path, 5*time.Second).Acquire("second")
+	if err != nil {
+		t.Fatalf("expected to get the lock after waiting, got %v", err)
+	}
+	release2()
+}
+
+func TestWorkspaceLock_TakesOverStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ws.lock")
+	// A PID far above any default pid_max belongs to no running process.
+	os.WriteFile(path, []byte(fmt.Sprintf("%d\n%s\nwrite_file x\n", 1<<30, time.Now().Format(time.RFC3339))), 0644)
+	release, err := NewWorkspaceLock(path, 0).Acquire("write_file y")
+	if err != nil {
+		t.Fatalf("expected a dead holder's lock to be taken over, got %v", err)
+	}
+	release()
+}
+
+func TestWorkspaceLock_ConcurrentTakeover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ws.lock")
+	os.WriteFile(path, []byte(fmt.Sprintf("%d\n%s\nwrite_file x\n", 1<<30, time.Now().Format(time.RFC3339))), 0644)
+	var holders, most int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Separate lock instances stand in for separate processes.
+			release, err := NewWorkspaceLock(path, 10*time.Second).Acquire(fmt.Sprintf("write_file %d", i))
+			if err != nil {
+				t.Errorf("Acquire: %v", err)
+				return
+			}
+			n := atomic.AddInt32(&holders, 1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			release()
+		}(i)
+	}
+	wg.Wait()
+	if most != 1 {
+		t.Errorf("expected one holder at a time, saw %d", most)
+	}
+}
+
+func TestToolExecutor_LockBlocksWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ws.lock")
+	release, _ := NewWorkspaceLock(path, 0).Acquire("other run")
+	defer release()
+
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "write_file"}, tool)
+	reg.RegisterTool(ToolSchema{Name: "read_file"}, tool)
+	exec := &ToolExecutor{Registry: reg, Lock: NewWorkspaceLock(path, 0)}
+
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": filepath.Join(dir, "a"), "content": "x"}}); err == nil {
+		t.Fatal("expected the write to fail while another run holds the lock")
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "read_file", Arguments: map[string]interface{}{"file_path": "a"}}); err != nil || tool.calls != 1 {
+		t.Fatalf("expected reads to ignore the lock, got %v (%d calls)", err, tool.calls)
+	}
+}
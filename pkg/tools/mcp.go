@@ -0,0 +1,654 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MCPProtocolVersion is the Model Context Protocol revision the client speaks.
+const MCPProtocolVersion = "2024-11-05"
+
+// DefaultMCPTimeout bounds each request to an MCP server without a timeout of
+// its own.
+const DefaultMCPTimeout = 30 * time.Second
+
+// MCPClient is a connection to a Model Context Protocol server: a JSON-RPC
+// session over the server's stdio or over HTTP with server-sent events.
+type MCPClient struct {
+	Name    string
+	Timeout time.Duration
+
+	transport mcpTransport
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan mcpMessage
+	err     error // Set when the connection is lost
+}
+
+// mcpTransport carries JSON-RPC messages to the server. Messages from the
+// server are passed to the client's receive as they arrive.
+type mcpTransport interface {
+	send(ctx context.Context, msg []byte) error
+	close() error
+}
+
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// MCPTool is a tool a server offers.
+type MCPTool struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	InputSchema types.JSONSchema `json:"inputSchema"`
+}
+
+// ConnectMCP starts or connects to the server of cfg and completes the MCP
+// handshake.
+func ConnectMCP(ctx context.Context, name string, cfg types.MCPServerConfig) (*MCPClient, error) {
+	c := newMCPClient(name, cfg.Timeout)
+	var err error
+	if cfg.Command != "" {
+		c.transport, err = startMCPStdio(c, cfg)
+	} else {
+		c.transport, err = connectMCPSSE(ctx, c, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newMCPClient(name string, timeout time.Duration) *MCPClient {
+	if timeout <= 0 {
+		timeout = DefaultMCPTimeout
+	}
+	return &MCPClient{Name: name, Timeout: timeout, pending: map[int64]chan mcpMessage{}}
+}
+
+func (c *MCPClient) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": MCPProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "ai-team", "version": "1"},
+	}
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	logrus.Debugf("MCP server %s is %s %s (protocol %s)", c.Name, result.ServerInfo.Name, result.ServerInfo.Version, result.ProtocolVersion)
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+// call sends a request and decodes its result into result.
+func (c *MCPClient) call(ctx context.Context, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s disconnected", c.Name), c.err)
+	}
+	c.nextID++
+	id := c.nextID
+	reply := make(chan mcpMessage, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	msg, _ := json.Marshal(mcpMessage{JSONRPC: "2.0", ID: json.RawMessage(fmt.Sprint(id)), Method: method, Params: params})
+	if err := c.transport.send(ctx, msg); err != nil {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to send %s to MCP server %s", method, c.Name), err)
+	}
+	select {
+	case resp, ok := <-reply:
+		if !ok {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s disconnected", c.Name), c.lost())
+		}
+		if resp.Error != nil {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s: %s failed: %s (code %d)", c.Name, method, resp.Error.Message, resp.Error.Code), nil)
+		}
+		if result != nil {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s sent an invalid %s result", c.Name, method), err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s: %s", c.Name, method), ctx.Err())
+	}
+}
+
+func (c *MCPClient) notify(ctx context.Context, method string, params interface{}) error {
+	msg, _ := json.Marshal(mcpMessage{JSONRPC: "2.0", Method: method, Params: params})
+	if err := c.transport.send(ctx, msg); err != nil {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to send %s to MCP server %s", method, c.Name), err)
+	}
+	return nil
+}
+
+// receive handles a message from the server: a response to a pending
+// request, a request of the server's own or a notification.
+func (c *MCPClient) receive(data []byte) {
+	var msg mcpMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		logrus.Warnf("Ignoring invalid message from MCP server %s: %v", c.Name, err)
+		return
+	}
+	switch {
+	case msg.Method != "" && len(msg.ID) > 0:
+		// Servers may ping; other requests (sampling, roots) are not supported.
+		reply := mcpMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage("{}")}
+		if msg.Method != "ping" {
+			reply = mcpMessage{JSONRPC: "2.0", ID: msg.ID, Error: &mcpError{Code: -32601, Message: "method not supported by ai-team: " + msg.Method}}
+		}
+		out, _ := json.Marshal(reply)
+		ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+		defer cancel()
+		if err := c.transport.send(ctx, out); err != nil {
+			logrus.Warnf("Failed to answer %s of MCP server %s: %v", msg.Method, c.Name, err)
+		}
+	case msg.Method != "":
+		logrus.Debugf("MCP server %s: %s", c.Name, msg.Method)
+	default:
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			logrus.Warnf("Ignoring response with unknown id %s from MCP server %s", msg.ID, c.Name)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if reply, ok := c.pending[id]; ok {
+			select {
+			case reply <- msg:
+			default: // A duplicate response
+			}
+		}
+	}
+}
+
+// disconnected fails the pending requests and every later one with err.
+func (c *MCPClient) disconnected(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+}
+
+func (c *MCPClient) lost() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close ends the connection, stopping a server started over stdio.
+func (c *MCPClient) Close() error {
+	c.disconnected(errors.New(errors.ErrCodeTool, "connection closed", nil))
+	return c.transport.close()
+}
+
+// ListTools returns the tools the server offers.
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	var all []MCPTool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the server's tool name and returns the text of its result.
+// A result the server marks as an error is returned as an error.
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if err := c.call(ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		switch {
+		case content.Type == "text":
+			parts = append(parts, content.Text)
+		case content.Type == "resource" && content.Resource.Text != "":
+			parts = append(parts, content.Resource.Text)
+		case content.Type == "resource":
+			parts = append(parts, fmt.Sprintf("[resource %s]", content.Resource.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content (%s) omitted]", content.Type, content.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("tool %s of MCP server %s failed: %s", name, c.Name, text), nil)
+	}
+	return text, nil
+}
+
+var unsafeToolNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// MCPToolName returns the name a server's tool is registered under: prefix
+// and the tool's name in snake_case, the form tool calls are matched in.
+func MCPToolName(prefix, name string) string {
+	return unsafeToolNameChars.ReplaceAllString(toSnakeCase(prefix+name), "_")
+}
+
+// Register adds the server's tools to reg under MCPToolName(prefix, name),
+// or only those named in only when it is not empty, and returns the names
+// they were registered under.
+func (c *MCPClient) Register(ctx context.Context, reg *ToolRegistry, prefix string, only []string) ([]string, error) {
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, name := range only {
+		wanted[name] = true
+	}
+	var names []string
+	for _, tool := range tools {
+		if len(wanted) > 0 && !wanted[tool.Name] {
+			continue
+		}
+		schema := mcpSchema(tool)
+		schema.Name = MCPToolName(prefix, tool.Name)
+		impl := &mcpTool{client: c, name: tool.Name}
+		for _, arg := range schema.Arguments {
+			impl.args = append(impl.args, arg.Name)
+		}
+		reg.RegisterTool(schema, impl)
+		names = append(names, schema.Name)
+	}
+	return names, nil
+}
+
+// mcpSchema converts the input schema of tool to tool arguments, each with
+// the full schema of its property.
+func mcpSchema(tool MCPTool) ToolSchema {
+	required := map[string]bool{}
+	for _, name := range tool.InputSchema.Required {
+		required[name] = true
+	}
+	schema := ToolSchema{Description: tool.Description}
+	for name, prop := range tool.InputSchema.Properties {
+		if prop == nil {
+			prop = &types.JSONSchema{}
+		}
+		schema.Arguments = append(schema.Arguments, ToolArgument{
+			Name:        name,
+			Type:        mcpArgType(prop.Type),
+			Required:    required[name],
+			Description: prop.Description,
+			Schema:      prop,
+		})
+	}
+	sort.Slice(schema.Arguments, func(i, j int) bool { return schema.Arguments[i].Name < schema.Arguments[j].Name })
+	return schema
+}
+
+// mcpArgType maps a JSON Schema type to the argument types of ToolArgument.
+func mcpArgType(name string) string {
+	switch name {
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "number", "array", "object", "":
+		return name
+	}
+	return "string"
+}
+
+// mcpTool runs a tool of an MCP server.
+type mcpTool struct {
+	client *MCPClient
+	name   string   // Name on the server
+	args   []string // Argument names as the server declares them
+}
+
+// Execute calls the tool with the argument names the server declares,
+// whatever case variant the model used.
+func (t *mcpTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	out := make(map[string]interface{}, len(args))
+	for _, name := range t.args {
+		if v, ok := lookupArgFlexible(args, name); ok {
+			out[name] = v
+		}
+	}
+	return t.client.CallTool(ctx, t.name, out)
+}
+
+// MCPClients are the connections of ConnectMCPServers.
+type MCPClients []*MCPClient
+
+// Close closes every connection.
+func (cs MCPClients) Close() {
+	for _, c := range cs {
+		if err := c.Close(); err != nil {
+			logrus.Warnf("Closing MCP server %s: %v", c.Name, err)
+		}
+	}
+}
+
+// ConnectMCPServers connects to the configured servers and registers their
+// tools in reg. A server that cannot be reached is skipped with a warning, so
+// it only affects the chains that use its tools.
+func ConnectMCPServers(ctx context.Context, servers types.MCPServers, reg *ToolRegistry) MCPClients {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var clients MCPClients
+	for _, name := range names {
+		cfg := servers[name]
+		client, err := ConnectMCP(ctx, name, cfg)
+		if err != nil {
+			logrus.Warnf("Skipping MCP server %s: %v", name, err)
+			continue
+		}
+		prefix := cfg.Prefix
+		if prefix == "" {
+			prefix = name + "_"
+		}
+		registered, err := client.Register(ctx, reg, prefix, cfg.Tools)
+		if err != nil {
+			logrus.Warnf("Skipping MCP server %s: %v", name, err)
+			client.Close()
+			continue
+		}
+		logrus.Infof("MCP server %s: registered %s", name, strings.Join(registered, ", "))
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// mcpStdio talks to a server over the stdin and stdout of a child process,
+// one JSON message per line.
+type mcpStdio struct {
+	cmd    *exec.Cmd     // Nil for connections not made by startMCPStdio
+	exited chan struct{} // Closed when cmd has exited
+
+	mu sync.Mutex // Serializes writes
+	w  io.WriteCloser
+}
+
+func startMCPStdio(c *MCPClient, cfg types.MCPServerConfig) (*mcpStdio, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	stderr := logrus.WithField("mcp_server", c.Name).WriterLevel(logrus.DebugLevel)
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to start MCP server %s", c.Name), err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to start MCP server %s", c.Name), err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to start MCP server %s", c.Name), err)
+	}
+	t := newMCPStdio(c, stdout, stdin)
+	t.cmd, t.exited = cmd, make(chan struct{})
+	go func() {
+		cmd.Wait()
+		stderr.Close()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+// newMCPStdio returns a transport writing to w and passing the lines read
+// from r to c.
+func newMCPStdio(c *MCPClient, r io.Reader, w io.WriteCloser) *mcpStdio {
+	t := &mcpStdio{w: w}
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				c.receive(line)
+			}
+		}
+		c.disconnected(scanner.Err())
+	}()
+	return t
+}
+
+func (t *mcpStdio) send(_ context.Context, msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.w.Write(append(msg, '\n'))
+	return err
+}
+
+// close closes the server's stdin, which tells it to exit, and kills it if
+// it is still running a few seconds later.
+func (t *mcpStdio) close() error {
+	err := t.w.Close()
+	if t.cmd == nil {
+		return err
+	}
+	select {
+	case <-t.exited:
+	case <-time.After(3 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.exited
+	}
+	return err
+}
+
+// mcpSSE talks to a server over HTTP: messages from the server arrive as
+// server-sent events, messages to it are POSTed to the endpoint the server
+// names in its first event.
+type mcpSSE struct {
+	client   *http.Client
+	headers  map[string]string
+	endpoint string
+	cancel   context.CancelFunc
+}
+
+func connectMCPSSE(ctx context.Context, c *MCPClient, cfg types.MCPServerConfig) (*mcpSSE, error) {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	// Header values may use $VAR from ai-team's environment, like env vars.
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+	t := &mcpSSE{client: &http.Client{}, headers: headers, cancel: cancel}
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid url of MCP server %s", c.Name), err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to connect to MCP server %s", c.Name), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s answered %s", c.Name, resp.Status), nil)
+	}
+
+	endpoint := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readEvents(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoint <- data:
+				default:
+				}
+			case "message", "":
+				c.receive([]byte(data))
+			}
+		})
+		c.disconnected(err)
+		close(endpoint)
+	}()
+
+	timeout := time.NewTimer(c.Timeout)
+	defer timeout.Stop()
+	select {
+	case path, ok := <-endpoint:
+		if !ok {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s closed the event stream before naming its endpoint", c.Name), nil)
+		}
+		base, _ := url.Parse(cfg.URL)
+		ref, err := url.Parse(path)
+		if err != nil {
+			cancel()
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s named an invalid endpoint", c.Name), err)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+		return t, nil
+	case <-timeout.C:
+		cancel()
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("MCP server %s did not name its endpoint within %s", c.Name, c.Timeout), nil)
+	case <-ctx.Done():
+		cancel()
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("connecting to MCP server %s", c.Name), ctx.Err())
+	}
+}
+
+// readEvents calls fn for each server-sent event read from r.
+func readEvents(r io.Reader, fn func(event, data string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Comment, e.g. a keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return scanner.Err()
+}
+
+func (t *mcpSSE) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *mcpSSE) close() error {
+	t.cancel()
+	return nil
+}
@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-team/pkg/types"
+)
+
+// fakeMCP answers MCP requests. Its tools are readFile, which echoes its
+// arguments, and fail, listed on a second page.
+type fakeMCP struct{}
+
+func (f *fakeMCP) handle(req map[string]interface{}) (result interface{}, ok bool) {
+	params, _ := req["params"].(map[string]interface{})
+	switch req["method"] {
+	case "initialize":
+		return map[string]interface{}{"protocolVersion": MCPProtocolVersion, "serverInfo": map[string]string{"name": "fake", "version": "0"}}, true
+	case "tools/list":
+		if params["cursor"] == nil {
+			return map[string]interface{}{"tools": []interface{}{map[string]interface{}{
+				"name":        "readFile",
+				"description": "Reads a file.",
+				"inputSchema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"filePath": map[string]interface{}{"type": "string", "description": "File to read."},
+						"maxBytes": map[string]interface{}{"type": []string{"integer", "null"}},
+					},
+					"required": []string{"filePath"},
+				},
+			}}, "nextCursor": "2"}, true
+		}
+		return map[string]interface{}{"tools": []interface{}{map[string]interface{}{"name": "fail", "inputSchema": map[string]interface{}{"type": "object"}}}}, true
+	case "tools/call":
+		args, _ := params["arguments"].(map[string]interface{})
+		if params["name"] == "fail" {
+			return map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": "no such file"}}, "isError": true}, true
+		}
+		data, _ := json.Marshal(args)
+		return map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": "read " + string(data)}}}, true
+	}
+	return nil, false
+}
+
+// reply returns the response to a request line, or nil for notifications.
+func (f *fakeMCP) reply(line []byte) []byte {
+	var req map[string]interface{}
+	if err := json.Unmarshal(line, &req); err != nil || req["id"] == nil {
+		return nil
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req["id"]}
+	if result, ok := f.handle(req); ok {
+		resp["result"] = result
+	} else {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "unknown method"}
+	}
+	out, _ := json.Marshal(resp)
+	return out
+}
+
+// serveStdio answers the requests read from r on w, one per line.
+func (f *fakeMCP) serveStdio(r io.Reader, w io.WriteCloser) {
+	defer w.Close()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if out := f.reply(scanner.Bytes()); out != nil {
+			w.Write(append(out, '\n'))
+		}
+	}
+}
+
+func pipeMCPClient(t *testing.T, f *fakeMCP) *MCPClient {
+	t.Helper()
+	toServer, clientOut := io.Pipe()
+	clientIn, fromServer := io.Pipe()
+	go f.serveStdio(toServer, fromServer)
+	c := newMCPClient("fake", time.Second)
+	c.transport = newMCPStdio(c, clientIn, clientOut)
+	if err := c.initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestMCPClient_RegisterAndCall(t *testing.T) {
+	f := &fakeMCP{}
+	c := pipeMCPClient(t, f)
+	reg := NewToolRegistry()
+	names, err := c.Register(context.Background(), reg, "fs_", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "fs_read_file,fs_fail" {
+		t.Fatalf("registered %v", names)
+	}
+	schema, _ := reg.GetToolSchema("fs_read_file")
+	if len(schema.Arguments) != 2 || schema.Arguments[0].Name != "filePath" || !schema.Arguments[0].Required || schema.Arguments[1].Type != "int" {
+		t.Errorf("schema = %+v", schema)
+	}
+
+	// Tool calls are normalized to snake_case; the server gets its own names.
+	te := &ToolExecutor{Registry: reg}
+	result, err := te.Execute(context.Background(), ToolCall{Name: "fs_read_file", Arguments: map[string]interface{}{"file_path": "go.mod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `read {"filePath":"go.mod"}` {
+		t.Errorf("result = %v", result)
+	}
+	if _, err := te.Execute(context.Background(), ToolCall{Name: "fs_fail", Arguments: map[string]interface{}{}}); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("error = %v, want the tool's error text", err)
+	}
+	if _, err := te.Execute(context.Background(), ToolCall{Name: "fs_read_file", Arguments: map[string]interface{}{}}); err == nil {
+		t.Error("expected validation to require filePath")
+	}
+}
+
+func TestMCPClient_RegisterOnly(t *testing.T) {
+	c := pipeMCPClient(t, &fakeMCP{})
+	reg := NewToolRegistry()
+	names, err := c.Register(context.Background(), reg, "", []string{"readFile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "read_file" {
+		t.Errorf("registered %v", names)
+	}
+}
+
+func TestMCPClient_Disconnected(t *testing.T) {
+	toServer, clientOut := io.Pipe()
+	clientIn, fromServer := io.Pipe()
+	go io.Copy(io.Discard, toServer)
+	c := newMCPClient("gone", time.Second)
+	c.transport = newMCPStdio(c, clientIn, clientOut)
+	fromServer.Close()
+	time.Sleep(10 * time.Millisecond)
+	if err := c.initialize(context.Background()); err == nil || !strings.Contains(err.Error(), "disconnected") {
+		t.Errorf("error = %v, want disconnected", err)
+	}
+}
+
+func TestConnectMCP_SSE(t *testing.T) {
+	f := &fakeMCP{}
+	events := make(chan []byte, 10)
+	var auth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\nevent: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case msg := <-events:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("POST /messages", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if out := f.reply(body); out != nil {
+			events <- out
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := types.MCPServerConfig{URL: server.URL + "/sse", Headers: map[string]string{"Authorization": "Bearer x"}, Timeout: 2 * time.Second}
+	c, err := ConnectMCP(context.Background(), "web", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if auth != "Bearer x" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	text, err := c.CallTool(context.Background(), "readFile", map[string]interface{}{"filePath": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != `read {"filePath":"a"}` {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestConnectMCPServers_SkipsUnreachable(t *testing.T) {
+	reg := NewToolRegistry()
+	clients := ConnectMCPServers(context.Background(), types.MCPServers{
+		"missing": {Command: "/nonexistent/mcp-server"},
+	}, reg)
+	defer clients.Close()
+	if len(clients) != 0 || len(reg.ListTools()) != 0 {
+		t.Errorf("clients = %v, tools = %v", clients, reg.ListTools())
+	}
+}
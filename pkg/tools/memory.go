@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"ai-team/pkg/memory"
+)
+
+// MemoryTool implements remember and recall on a shared memory.Store.
+type MemoryTool struct {
+	Store *memory.Store
+	Op    string // "remember" or "recall"
+}
+
+func (t *MemoryTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if err := checkContext(ctx, t.Op); err != nil {
+		return nil, err
+	}
+	str := func(name string) string {
+		v, _ := lookupArgFlexible(args, name)
+		s, _ := v.(string)
+		return s
+	}
+	if t.Op == "recall" {
+		limit := 0
+		if v, ok := lookupArgFlexible(args, "limit"); ok {
+			switch n := v.(type) {
+			case int:
+				limit = n
+			case float64:
+				limit = int(n)
+			}
+		}
+		facts, err := t.Store.Recall(str("query"), str("tag"), limit)
+		if err != nil {
+			return nil, err
+		}
+		if facts == nil {
+			facts = []memory.Fact{}
+		}
+		return facts, nil
+	}
+	text := str("text")
+	if text == "" {
+		return nil, fmt.Errorf("invalid arguments for remember: text required")
+	}
+	var tags []string
+	if v, ok := lookupArgFlexible(args, "tags"); ok {
+		if s, isString := v.(string); isString && s != "" {
+			tags = []string{s}
+		} else if items, isArray := arrayValue(v); isArray {
+			for _, item := range items {
+				if s, _ := item.(string); s != "" {
+					tags = append(tags, s)
+				}
+			}
+		}
+	}
+	author := AuthorFrom(ctx)
+	fact, updated, err := t.Store.Remember(memory.Fact{Key: str("key"), Text: text, Tags: tags, RunID: author.RunID, Role: author.Role})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": fact.ID, "key": fact.Key, "updated": updated}, nil
+}
+
+// registerMemoryTools registers remember and recall sharing store.
+func registerMemoryTools(reg *ToolRegistry, store *memory.Store) {
+	reg.RegisterTool(ToolSchema{
+		Name:        "remember",
+		Description: "Stores a fact about the project for later runs, e.g. how tests are run or a decision and its reason. Remembering a key again replaces its fact.",
+		Arguments: []ToolArgument{
+			{Name: "text", Type: "string", Required: true, Description: "The fact, in one or two sentences."},
+			{Name: "tags", Type: "array", Description: "Tags to find the fact by, e.g. build or api."},
+			{Name: "key", Type: "string", Description: "Name of the fact, to update it later instead of adding another."},
+		},
+	}, &MemoryTool{Store: store, Op: "remember"})
+	reg.RegisterTool(ToolSchema{
+		Name:        "recall",
+		Description: "Returns remembered facts about the project that contain words of the query, best matches first; without a query the most recent ones.",
+		Arguments: []ToolArgument{
+			{Name: "query", Type: "string", Description: "Words to look for."},
+			{Name: "tag", Type: "string", Description: "Only facts with this tag."},
+			{Name: "limit", Type: "int", Description: fmt.Sprintf("Most facts to return (default %d).", memory.DefaultRecallLimit)},
+		},
+	}, &MemoryTool{Store: store, Op: "recall"})
+}
@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"ai-team/pkg/memory"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryTools(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	reg.Memory().Path = filepath.Join(t.TempDir(), "memory.json")
+	te := &ToolExecutor{Registry: reg}
+	ctx := WithAuthor(context.Background(), Author{RunID: "01RUN", Role: "coder"})
+
+	result, err := te.Execute(ctx, ToolCall{Name: "remember", Arguments: map[string]interface{}{"text": "Lint with golangci-lint", "tags": []interface{}{"build", "lint"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m := result.(map[string]interface{}); m["id"] != 1 || m["updated"] != false {
+		t.Errorf("unexpected remember result %v", m)
+	}
+	result, err = te.Execute(ctx, ToolCall{Name: "recall", Arguments: map[string]interface{}{"query": "lint", "limit": 5.0}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	facts := result.([]memory.Fact)
+	if len(facts) != 1 || facts[0].Role != "coder" || facts[0].RunID != "01RUN" || len(facts[0].Tags) != 2 {
+		t.Errorf("expected the fact with its author and tags, got %+v", facts)
+	}
+	if _, err := te.Execute(ctx, ToolCall{Name: "remember", Arguments: map[string]interface{}{}}); err == nil {
+		t.Error("expected an error for remember without text")
+	}
+}
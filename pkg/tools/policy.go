@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"ai-team/pkg/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy actions.
+const (
+	PolicyAllow   = "allow"
+	PolicyDeny    = "deny"
+	PolicyConfirm = "confirm"
+)
+
+// Policy decides whether tool calls may run without a human. Rules are checked
+// in order and the first match wins; calls matching no rule get Default.
+type Policy struct {
+	Default string       `yaml:"default"`
+	Rules   []PolicyRule `yaml:"rules"`
+
+	// Confirm is asked about calls whose action is "confirm". Without it such
+	// calls are refused.
+	Confirm func(prompt string) (bool, error) `yaml:"-"`
+}
+
+// PolicyRule matches tool calls by name and arguments.
+type PolicyRule struct {
+	Tool   string            `yaml:"tool"`   // Glob on the snake_case tool name, e.g. "write_*" or "*"
+	Args   map[string]string `yaml:"args"`   // Argument name -> regular expression the value must match
+	Action string            `yaml:"action"` // allow, deny or confirm
+
+	args map[string]*regexp.Regexp
+}
+
+// LoadPolicy reads and validates a YAML policy file.
+func LoadPolicy(filePath string) (*Policy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to read policy file %s", filePath), err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("failed to parse policy file %s", filePath), err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) compile() error {
+	if p.Default == "" {
+		p.Default = PolicyConfirm
+	}
+	if !validPolicyAction(p.Default) {
+		return errors.New(errors.ErrCodeConfig, fmt.Sprintf("policy default must be allow, deny or confirm, got '%s'", p.Default), nil)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Tool == "" {
+			r.Tool = "*"
+		}
+		if _, err := path.Match(r.Tool, ""); err != nil {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("policy rule %d has invalid tool pattern '%s'", i+1, r.Tool), err)
+		}
+		if !validPolicyAction(r.Action) {
+			return errors.New(errors.ErrCodeConfig, fmt.Sprintf("policy rule %d action must be allow, deny or confirm, got '%s'", i+1, r.Action), nil)
+		}
+		r.args = make(map[string]*regexp.Regexp, len(r.Args))
+		for name, pattern := range r.Args {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return errors.New(errors.ErrCodeConfig, fmt.Sprintf("policy rule %d has invalid pattern for '%s'", i+1, name), err)
+			}
+			r.args[name] = re
+		}
+	}
+	return nil
+}
+
+func validPolicyAction(action string) bool {
+	return action == PolicyAllow || action == PolicyDeny || action == PolicyConfirm
+}
+
+// Decide returns the action for call and the rule that produced it (nil for the default).
+// Calls giving one argument under aliases with different values are denied.
+func (p *Policy) Decide(call ToolCall) (string, *PolicyRule) {
+	action, rule, _ := p.decide(call)
+	return action, rule
+}
+
+func (p *Policy) decide(call ToolCall) (string, *PolicyRule, error) {
+	name := toSnakeCase(call.Name)
+	target, hasTarget, err := targetPath(call.Name, call.Arguments)
+	if err != nil {
+		return PolicyDeny, nil, err
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if ok, _ := path.Match(r.Tool, name); !ok {
+			continue
+		}
+		ok, err := r.matchesArgs(call.Arguments, target, hasTarget)
+		if err != nil {
+			return PolicyDeny, nil, err
+		}
+		if ok {
+			return r.Action, r, nil
+		}
+	}
+	return p.Default, nil, nil
+}
+
+// policyPathArgs are the arguments holding a path. A rule on any of them
+// matches the path the tool uses, whichever argument names it, cleaned so
+// that "src/../secrets" does not pass for "^src/".
+var policyPathArgs = map[string]bool{"file_path": true, "path": true, "directory": true}
+
+func (r *PolicyRule) matchesArgs(args map[string]interface{}, target string, hasTarget bool) (bool, error) {
+	for name, re := range r.args {
+		var value string
+		if policyPathArgs[toSnakeCase(name)] {
+			if !hasTarget {
+				return false, nil
+			}
+			value = filepath.Clean(target)
+		} else {
+			v, ok, err := aliasedValue(args, name)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, nil
+			}
+			value = fmt.Sprintf("%v", v)
+		}
+		if !re.MatchString(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Check enforces the policy for call, asking Confirm when required. It returns
+// an error if the call must not run.
+func (p *Policy) Check(call ToolCall) error {
+	action, rule, err := p.decide(call)
+	if err != nil {
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("tool call %s denied by policy (%v)", call.Name, err), nil)
+	}
+	switch action {
+	case PolicyAllow:
+		return nil
+	case PolicyConfirm:
+		if p.Confirm != nil {
+			ok, err := p.Confirm(fmt.Sprintf("Policy requires confirmation for %s %v. Allow?", call.Name, call.Arguments))
+			if err == nil && ok {
+				return nil
+			}
+		}
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("tool call %s was not confirmed (%s)", call.Name, describeRule(rule)), nil)
+	default:
+		return errors.New(errors.ErrCodeTool, fmt.Sprintf("tool call %s denied by policy (%s)", call.Name, describeRule(rule)), nil)
+	}
+}
+
+func describeRule(rule *PolicyRule) string {
+	if rule == nil {
+		return "default"
+	}
+	return fmt.Sprintf("rule tool=%s", rule.Tool)
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - tool: run_command
+    args:
+      command: '^go (test|build)\b'
+    action: allow
+  - tool: run_command
+    action: confirm
+  - tool: write_*
+    args:
+      file_path: '^docs/'
+    action: allow
+  - tool: read_file
+    action: allow
+`
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPolicy_Decide(t *testing.T) {
+	p, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	cases := []struct {
+		call ToolCall
+		want string
+	}{
+		{ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "go test ./..."}}, PolicyAllow},
+		{ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "make deploy"}}, PolicyConfirm},
+		{ToolCall{Name: "WriteFile", Arguments: map[string]interface{}{"filePath": "docs/a.md"}}, PolicyAllow},
+		{ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "main.go"}}, PolicyDeny},
+		{ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "docs/../main.go"}}, PolicyDeny},
+		{ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "./docs/a.md"}}, PolicyAllow},
+		{ToolCall{Name: "ReadFile", Arguments: map[string]interface{}{"file_path": "x"}}, PolicyAllow},
+		{ToolCall{Name: "ApplyPatch"}, PolicyDeny},
+		{ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "docs/a.md", "filePath": "main.go"}}, PolicyDeny},
+		{ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "go test ./...", "Command": "make deploy"}}, PolicyDeny},
+	}
+	for _, c := range cases {
+		if got, _ := p.Decide(c.call); got != c.want {
+			t.Errorf("%s %v: expected %s, got %s", c.call.Name, c.call.Arguments, c.want, got)
+		}
+	}
+}
+
+func TestPolicy_InvalidFile(t *testing.T) {
+	for _, content := range []string{
+		"rules:\n  - tool: x\n    action: maybe\n",
+		"default: sometimes\n",
+		"rules:\n  - tool: x\n    args: {a: '('}\n    action: allow\n",
+	} {
+		if _, err := LoadPolicy(writePolicy(t, content)); err == nil {
+			t.Errorf("expected error for policy %q", content)
+		}
+	}
+}
+
+func TestToolExecutor_PolicyEnforced(t *testing.T) {
+	p, _ := LoadPolicy(writePolicy(t, testPolicy))
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "RunCommand"}, tool)
+	reg.RegisterTool(ToolSchema{Name: "WriteFile"}, tool)
+	exec := &ToolExecutor{Registry: reg, Policy: p}
+
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "WriteFile", Arguments: map[string]interface{}{"filePath": "main.go"}}); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected denied write, got %v", err)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "make"}}); err == nil {
+		t.Errorf("expected confirm action without Confirm to be refused")
+	}
+
+	var prompts []string
+	p.Confirm = func(prompt string) (bool, error) {
+		prompts = append(prompts, prompt)
+		return true, nil
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "make"}}); err != nil {
+		t.Errorf("expected confirmed command to run, got %v", err)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "go build"}}); err != nil {
+		t.Errorf("expected allowed command to run, got %v", err)
+	}
+	if len(prompts) != 1 || tool.calls != 2 {
+		t.Errorf("expected 1 confirmation and 2 executions, got %d and %d", len(prompts), tool.calls)
+	}
+}
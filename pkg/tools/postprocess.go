@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// PostProcessors trims the text results of selected tools, e.g. keeping the
+// failing lines of a test run, before they reach the model.
+type PostProcessors struct {
+	steps map[string]postProcessor // keyed by snake_case tool name
+}
+
+type postProcessor struct {
+	regex *regexp.Regexp
+	json  string
+	head  int
+}
+
+// NewPostProcessors compiles the post-processors of each tool. It returns nil,
+// which processes nothing, when there are none.
+func NewPostProcessors(configs types.PostProcessors) (*PostProcessors, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	p := &PostProcessors{steps: map[string]postProcessor{}}
+	for name, c := range configs {
+		step := postProcessor{json: c.JSON, head: c.Head}
+		if c.Regex != "" {
+			re, err := regexp.Compile(c.Regex)
+			if err != nil {
+				return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid post_process regex for tool %s", name), err)
+			}
+			step.regex = re
+		}
+		if c.Head < 0 {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("post_process head for tool %s must not be negative", name), nil)
+		}
+		p.steps[toSnakeCase(name)] = step
+	}
+	return p, nil
+}
+
+// Apply returns the processed result of the named tool. Results that are not
+// text, and those of tools without a post-processor, are returned unchanged.
+func (p *PostProcessors) Apply(name string, result interface{}) (interface{}, error) {
+	if p == nil {
+		return result, nil
+	}
+	step, ok := p.steps[toSnakeCase(name)]
+	if !ok {
+		return result, nil
+	}
+	text, ok := result.(string)
+	if !ok {
+		return result, nil
+	}
+	if step.regex != nil {
+		var kept []string
+		for _, m := range step.regex.FindAllStringSubmatch(text, -1) {
+			if len(m) > 1 {
+				kept = append(kept, m[1])
+			} else {
+				kept = append(kept, m[0])
+			}
+		}
+		text = strings.Join(kept, "\n")
+	}
+	if step.json != "" {
+		v, err := jsonPath(text, step.json)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to post-process %s result", name), err)
+		}
+		text = v
+	}
+	if step.head > 0 {
+		if lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n"); len(lines) > step.head {
+			text = strings.Join(lines[:step.head], "\n") + fmt.Sprintf("\n[%d more lines omitted]", len(lines)-step.head)
+		}
+	}
+	return text, nil
+}
+
+// jsonPath returns the value at a dotted path in a JSON document; numeric
+// segments index arrays and "." is the whole document. Strings are returned
+// as-is, other values as indented JSON.
+func jsonPath(doc, path string) (string, error) {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("result is not valid JSON: %w", err)
+	}
+	if path != "." {
+		for _, segment := range strings.Split(path, ".") {
+			switch node := v.(type) {
+			case map[string]interface{}:
+				next, ok := node[segment]
+				if !ok {
+					return "", fmt.Errorf("no '%s' at '%s'", segment, path)
+				}
+				v = next
+			case []interface{}:
+				i, err := strconv.Atoi(segment)
+				if err != nil || i < 0 || i >= len(node) {
+					return "", fmt.Errorf("no index '%s' at '%s'", segment, path)
+				}
+				v = node[i]
+			default:
+				return "", fmt.Errorf("no '%s' at '%s'", segment, path)
+			}
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestPostProcessors_Apply(t *testing.T) {
+	p, err := NewPostProcessors(types.PostProcessors{
+		"run_command": {Regex: `(?m)^--- FAIL: (\w+)`},
+		"deps":        {JSON: "dependencies.1.name"},
+		"log":         {Head: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		tool   string
+		result interface{}
+		want   interface{}
+	}{
+		{"RunCommand", "=== RUN TestA\n--- FAIL: TestA (0.00s)\nok\n--- FAIL: TestB (0.01s)\n", "TestA\nTestB"},
+		{"deps", `{"dependencies": [{"name": "cobra"}, {"name": "viper"}]}`, "viper"},
+		{"log", "one\ntwo\nthree\nfour\n", "one\ntwo\n[2 more lines omitted]"},
+		{"log", "one\ntwo\n", "one\ntwo\n"},
+		{"read_file", "untouched", "untouched"},
+		{"run_command", []string{"not text"}, []string{"not text"}},
+	}
+	for _, c := range cases {
+		got, err := p.Apply(c.tool, c.result)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.tool, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: got %q, want %q", c.tool, got, c.want)
+		}
+	}
+	if _, err := p.Apply("deps", "not json"); err == nil {
+		t.Error("expected error for a result that is not JSON")
+	}
+
+	if _, err := NewPostProcessors(types.PostProcessors{"x": {Regex: "("}}); err == nil {
+		t.Error("expected error for an invalid regex")
+	}
+}
+
+func TestToolExecutor_PostProcess(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	p, _ := NewPostProcessors(types.PostProcessors{"run_command": {Head: 1}})
+	exec := &ToolExecutor{Registry: reg, PostProcess: p}
+	out, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "printf 'a\\nb\\n'"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "a\n[1 more lines omitted]" {
+		t.Errorf("expected the trimmed output, got %q", out)
+	}
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/types"
+)
+
+// promptCache holds rendered tool prompt sections by registry hash.
+var promptCache = struct {
+	sync.Mutex
+	sections map[string]string
+}{sections: map[string]string{}}
+
+// sortedTools returns the registered schemas ordered by name.
+func (r *ToolRegistry) sortedTools() []ToolSchema {
+	schemas := r.ListTools()
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// Hash identifies the registered tool definitions: it changes whenever a tool
+// is added or removed or its description or arguments change.
+func (r *ToolRegistry) Hash() string {
+	data, _ := json.Marshal(r.sortedTools())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// PromptSection describes the registered tools and the tool-call format for
+// inclusion in a prompt. Renderings are cached by the registry hash, which is
+// returned alongside.
+func (r *ToolRegistry) PromptSection() (section, hash string) {
+	hash = r.Hash()
+	promptCache.Lock()
+	defer promptCache.Unlock()
+	if cached, ok := promptCache.sections[hash]; ok {
+		return cached, hash
+	}
+	section = renderPromptSection(r.sortedTools())
+	promptCache.sections[hash] = section
+	return section, hash
+}
+
+func renderPromptSection(schemas []ToolSchema) string {
+	var b strings.Builder
+	b.WriteString("Available tools. To use one, reply with a single JSON object of the form\n")
+	b.WriteString(`{"tool_call": {"name": "<tool>", "arguments": {"<argument>": <value>}}}` + "\n\n")
+	for _, s := range schemas {
+		fmt.Fprintf(&b, "- %s: %s\n", s.Name, s.Description)
+		for _, arg := range s.Arguments {
+			required := "optional"
+			if arg.Required {
+				required = "required"
+			}
+			fmt.Fprintf(&b, "    - %s (%s): %s\n", arg.Name, strings.Join(append([]string{argTypeLabel(arg), required}, schemaConstraints(arg.Schema)...), ", "), arg.Description)
+		}
+	}
+	return b.String()
+}
+
+// argTypeLabel returns the type of arg as shown in the prompt: its Type, or
+// the type of its schema, e.g. "array of integer".
+func argTypeLabel(arg ToolArgument) string {
+	if arg.Type != "" && arg.Type != "array" {
+		return arg.Type
+	}
+	schema := arg.JSONSchema()
+	if schema.Type == "" {
+		return "any"
+	}
+	return schemaTypeLabel(schema)
+}
+
+// schemaConstraints describes the enum, default and range of schema.
+func schemaConstraints(schema *types.JSONSchema) []string {
+	if schema == nil {
+		return nil
+	}
+	var out []string
+	if len(schema.Enum) > 0 {
+		out = append(out, "one of: "+formatEnum(schema.Enum))
+	}
+	if schema.Default != nil {
+		out = append(out, fmt.Sprintf("default: %v", schema.Default))
+	}
+	if schema.Minimum != nil {
+		out = append(out, fmt.Sprintf("min: %v", *schema.Minimum))
+	}
+	if schema.Maximum != nil {
+		out = append(out, fmt.Sprintf("max: %v", *schema.Maximum))
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptSection_HashChangesWithTools(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+	section, hash := reg.PromptSection()
+	if !strings.Contains(section, "- ReadFile: ") || !strings.Contains(section, "file_path (string, required)") {
+		t.Errorf("unexpected section:\n%s", section)
+	}
+	if again, againHash := reg.PromptSection(); again != section || againHash != hash {
+		t.Error("expected the same section and hash for an unchanged registry")
+	}
+
+	reg.RegisterTool(ToolSchema{Name: "deploy", Description: "Deploys the app."}, nil)
+	changed, changedHash := reg.PromptSection()
+	if changedHash == hash || !strings.Contains(changed, "- deploy: Deploys the app.") {
+		t.Errorf("expected a new hash and section after adding a tool, got %s:\n%s", changedHash, changed)
+	}
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"fmt"
+	"sync"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// QuotaTracker enforces per-run tool limits. When a limit would be exceeded,
+// Confirm (if set) is asked whether to continue; approving grants another
+// allowance of the same size for that limit.
+type QuotaTracker struct {
+	Limits  types.ToolQuota
+	Confirm func(prompt string) (bool, error)
+
+	mu       sync.Mutex
+	base     types.ToolQuota
+	calls    int
+	files    map[string]bool
+	bytes    int64
+	commands int
+}
+
+// NewQuotaTracker creates a tracker for the given limits.
+func NewQuotaTracker(limits types.ToolQuota, confirm func(prompt string) (bool, error)) *QuotaTracker {
+	return &QuotaTracker{Limits: limits, Confirm: confirm, base: limits, files: map[string]bool{}}
+}
+
+// QuotaUsage reports tool usage accumulated by a QuotaTracker.
+type QuotaUsage struct {
+	ToolCalls    int
+	FilesWritten int
+	BytesWritten int64
+	Commands     int
+}
+
+// Usage returns the counters accumulated so far.
+func (q *QuotaTracker) Usage() QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QuotaUsage{
+		ToolCalls:    q.calls,
+		FilesWritten: len(q.files),
+		BytesWritten: q.bytes,
+		Commands:     q.commands,
+	}
+}
+
+// Check verifies that executing call stays within the limits, pausing for
+// confirmation when it would not. It returns an error if the call must not run.
+func (q *QuotaTracker) Check(call ToolCall) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	path, content, isWrite := writeTarget(call)
+
+	if q.Limits.MaxToolCalls > 0 && q.calls+1 > q.Limits.MaxToolCalls {
+		if err := q.confirmLocked(fmt.Sprintf("tool call quota of %d reached", q.Limits.MaxToolCalls)); err != nil {
+			return err
+		}
+		q.Limits.MaxToolCalls += q.base.MaxToolCalls
+	}
+	if isWrite && q.Limits.MaxFilesWritten > 0 && !q.files[path] && len(q.files)+1 > q.Limits.MaxFilesWritten {
+		if err := q.confirmLocked(fmt.Sprintf("files written quota of %d reached", q.Limits.MaxFilesWritten)); err != nil {
+			return err
+		}
+		q.Limits.MaxFilesWritten += q.base.MaxFilesWritten
+	}
+	if isWrite && q.Limits.MaxBytesWritten > 0 && q.bytes+int64(len(content)) > q.Limits.MaxBytesWritten {
+		if err := q.confirmLocked(fmt.Sprintf("bytes written quota of %d reached", q.Limits.MaxBytesWritten)); err != nil {
+			return err
+		}
+		q.Limits.MaxBytesWritten += q.base.MaxBytesWritten
+	}
+	if isCommand(call) && q.Limits.MaxCommands > 0 && q.commands+1 > q.Limits.MaxCommands {
+		if err := q.confirmLocked(fmt.Sprintf("commands executed quota of %d reached", q.Limits.MaxCommands)); err != nil {
+			return err
+		}
+		q.Limits.MaxCommands += q.base.MaxCommands
+	}
+	return nil
+}
+
+// Record accounts for a successfully executed call.
+func (q *QuotaTracker) Record(call ToolCall) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.calls++
+	if path, content, ok := writeTarget(call); ok {
+		q.files[path] = true
+		q.bytes += int64(len(content))
+	}
+	if isCommand(call) {
+		q.commands++
+	}
+}
+
+func (q *QuotaTracker) confirmLocked(reason string) error {
+	if q.Confirm != nil {
+		ok, err := q.Confirm(fmt.Sprintf("Run paused: %s. Continue?", reason))
+		if err == nil && ok {
+			return nil
+		}
+	}
+	return errors.New(errors.ErrCodeTool, "quota exceeded: "+reason, nil)
+}
+
+func writeTarget(call ToolCall) (string, string, bool) {
+	path, content, isWrite, _ := WriteTarget(call)
+	return path, content, isWrite
+}
+
+func isCommand(call ToolCall) bool {
+	return call.Name == "run_command" || call.Name == "RunCommand"
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func writeCall(path, content string) ToolCall {
+	return ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": path, "content": content}}
+}
+
+func TestQuotaTracker_FilesAndBytes(t *testing.T) {
+	q := NewQuotaTracker(types.ToolQuota{MaxFilesWritten: 2, MaxBytesWritten: 10}, nil)
+	for _, c := range []ToolCall{writeCall("a.txt", "1234"), writeCall("b.txt", "1234")} {
+		if err := q.Check(c); err != nil {
+			t.Fatalf("unexpected quota error: %v", err)
+		}
+		q.Record(c)
+	}
+	// rewriting an already-written file does not count as a new file, but bytes still accumulate
+	if err := q.Check(writeCall("a.txt", "12345")); err == nil {
+		t.Fatalf("expected bytes quota to be exceeded")
+	}
+	if err := q.Check(writeCall("c.txt", "1")); err == nil {
+		t.Fatalf("expected files quota to be exceeded")
+	}
+	if u := q.Usage(); u.FilesWritten != 2 || u.BytesWritten != 8 || u.ToolCalls != 2 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+}
+
+func TestQuotaTracker_CountsContentToolWrites(t *testing.T) {
+	q := NewQuotaTracker(types.ToolQuota{MaxBytesWritten: 10}, nil)
+	call := ToolCall{Name: "ApplyPatch", Arguments: map[string]interface{}{
+		"filePath":     "a.txt",
+		"patchContent": "a patch longer than ten bytes",
+		"content":      "short",
+	}}
+	if err := q.Check(call); err == nil {
+		t.Fatalf("expected bytes quota to count the patch applied")
+	}
+	if path, _, _ := writeTarget(call); path != "a.txt" {
+		t.Errorf("expected write target a.txt, got %q", path)
+	}
+}
+
+func TestQuotaTracker_ConfirmExtends(t *testing.T) {
+	asked := 0
+	q := NewQuotaTracker(types.ToolQuota{MaxCommands: 1}, func(prompt string) (bool, error) {
+		asked++
+		return true, nil
+	})
+	cmd := ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "true"}}
+	for i := 0; i < 3; i++ {
+		if err := q.Check(cmd); err != nil {
+			t.Fatalf("expected confirmation to allow call %d: %v", i, err)
+		}
+		q.Record(cmd)
+	}
+	// limit 1 -> confirm on call 2 (limit 2) -> confirm on call 3 (limit 3)
+	if asked != 2 {
+		t.Fatalf("expected two confirmations, got %d", asked)
+	}
+}
+
+func TestToolExecutor_QuotaBlocksCall(t *testing.T) {
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "MockTool", Description: "mock"}, &mockTool{attempts: 1})
+	exec := &ToolExecutor{Registry: reg, Quota: NewQuotaTracker(types.ToolQuota{MaxToolCalls: 1}, nil)}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "MockTool", Arguments: map[string]interface{}{}}); err != nil {
+		t.Fatalf("first call should succeed: %v", err)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "MockTool", Arguments: map[string]interface{}{}}); err == nil {
+		t.Fatalf("expected second call to exceed quota")
+	}
+}
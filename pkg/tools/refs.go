@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ai-team/pkg/errors"
+)
+
+// RefSuffix marks a tool argument whose value is read from the run instead of
+// written out by the model: "content_from": "steps.design.output" sets the
+// content argument to the output of the step named design.
+const RefSuffix = "_from"
+
+// RefsHint tells the model about argument references.
+const RefsHint = "\nTo pass the output of an earlier step as an argument without repeating it, " +
+	`send "<argument>_from": "steps.<step>.output" in place of the argument, for example "content_from": "steps.design.output".` + "\n"
+
+// ResolveRefs replaces the argument references of call with the values they
+// point to in source, in place. An argument "<name>_from" is a reference when
+// the tool has an argument <name> and none named "<name>_from". Values that
+// are not strings are passed as JSON.
+func (r *ToolRegistry) ResolveRefs(call ToolCall, source map[string]interface{}) error {
+	schema, ok := r.GetToolSchema(call.Name)
+	if !ok {
+		return nil // Reported by validation
+	}
+	names := make([]string, 0, len(call.Arguments))
+	for name := range call.Arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target, isRef := strings.CutSuffix(name, RefSuffix)
+		if !isRef || target == "" || hasArgument(schema, name) || !hasArgument(schema, target) {
+			continue
+		}
+		path, ok := call.Arguments[name].(string)
+		if !ok {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("argument '%s' for tool '%s' must be a path such as steps.<step>.output", name, call.Name), nil)
+		}
+		if _, exists := lookupArgFlexible(call.Arguments, target); exists {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("tool '%s' got both '%s' and '%s'", call.Name, target, name), nil)
+		}
+		value, err := valueAt(source, path)
+		if err != nil {
+			return errors.New(errors.ErrCodeTool, fmt.Sprintf("cannot resolve argument '%s' for tool '%s'", name, call.Name), err)
+		}
+		call.Arguments[target] = value
+		delete(call.Arguments, name)
+	}
+	return nil
+}
+
+// hasRef reports whether args hold a reference for the argument name, which
+// then counts as given when validating the call.
+func hasRef(schema ToolSchema, args map[string]interface{}, name string) bool {
+	if hasArgument(schema, name+RefSuffix) {
+		return false
+	}
+	_, ok := lookupArgFlexible(args, name+RefSuffix)
+	return ok
+}
+
+func hasArgument(schema ToolSchema, name string) bool {
+	for _, arg := range schema.Arguments {
+		if toSnakeCase(arg.Name) == toSnakeCase(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueAt returns the value at a dotted path in source as a string. Numeric
+// segments index lists ("steps.ideas.output.0").
+func valueAt(source map[string]interface{}, path string) (string, error) {
+	var v interface{} = source
+	for _, segment := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return "", fmt.Errorf("nothing at '%s' in '%s'", segment, path)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("no index '%s' in '%s'", segment, path)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("nothing at '%s' in '%s'", segment, path)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func refsSource() map[string]interface{} {
+	return map[string]interface{}{
+		"steps": map[string]interface{}{
+			"design": map[string]interface{}{"output": "# Design"},
+			"ideas":  map[string]interface{}{"output": []interface{}{"first", map[string]interface{}{"name": "second"}}},
+		},
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+
+	call := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a.md", "content_from": "steps.design.output"}}
+	if err := reg.ValidateToolCall(call); err != nil {
+		t.Fatalf("expected a reference to stand in for content, got %v", err)
+	}
+	if err := reg.ResolveRefs(call, refsSource()); err != nil {
+		t.Fatalf("ResolveRefs returned error: %v", err)
+	}
+	if call.Arguments["content"] != "# Design" {
+		t.Errorf("expected the step output as content, got %v", call.Arguments["content"])
+	}
+	if _, ok := call.Arguments["content_from"]; ok {
+		t.Error("expected the reference to be removed")
+	}
+
+	cases := map[string]string{
+		"steps.ideas.output.0": "first",
+		"steps.ideas.output.1": `{"name":"second"}`,
+	}
+	for path, want := range cases {
+		call := ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a.md", "content_from": path}}
+		if err := reg.ResolveRefs(call, refsSource()); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if call.Arguments["content"] != want {
+			t.Errorf("%s: expected %q, got %v", path, want, call.Arguments["content"])
+		}
+	}
+}
+
+func TestResolveRefs_Errors(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+
+	cases := map[string]map[string]interface{}{
+		"cannot resolve": {"file_path": "a.md", "content_from": "steps.missing.output"},
+		"no index":       {"file_path": "a.md", "content_from": "steps.ideas.output.5"},
+		"got both":       {"file_path": "a.md", "content": "x", "content_from": "steps.design.output"},
+		"must be a path": {"file_path": "a.md", "content_from": 3.0},
+	}
+	for want, args := range cases {
+		err := reg.ResolveRefs(ToolCall{Name: "write_file", Arguments: args}, refsSource())
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestResolveRefs_OnlyKnownArguments(t *testing.T) {
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "Copy", Arguments: []ToolArgument{
+		{Name: "path", Type: "string"},
+		{Name: "copy_from", Type: "string"},
+	}}, &ListDirTool{})
+
+	call := ToolCall{Name: "Copy", Arguments: map[string]interface{}{"copy_from": "a.txt", "path_from": "steps.design.output"}}
+	if err := reg.ResolveRefs(call, refsSource()); err != nil {
+		t.Fatalf("ResolveRefs returned error: %v", err)
+	}
+	if call.Arguments["copy_from"] != "a.txt" {
+		t.Errorf("expected an argument named *_from to be left alone, got %v", call.Arguments["copy_from"])
+	}
+	if call.Arguments["path"] != "# Design" {
+		t.Errorf("expected path to be resolved, got %v", call.Arguments["path"])
+	}
+}
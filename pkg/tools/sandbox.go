@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Sandbox restricts the commands of run_command: which commands may run, the
+// directory they run in, the variables they see and, optionally, a container
+// or chroot they run in. ToolExecutor checks calls against it before dispatch
+// and RunCommandContext applies it through the context.
+type Sandbox struct {
+	allow     []*regexp.Regexp
+	deny      []*regexp.Regexp
+	workdir   string
+	scrub     []string
+	container types.SandboxContainer
+	chroot    string
+}
+
+// NewSandbox compiles cfg. It returns nil when the sandbox is disabled.
+func NewSandbox(cfg types.SandboxConfig) (*Sandbox, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	s := &Sandbox{scrub: cfg.ScrubEnv, container: cfg.Container, chroot: cfg.Chroot}
+	var err error
+	if s.allow, err = compileSandboxPatterns("allow", cfg.Allow); err != nil {
+		return nil, err
+	}
+	if s.deny, err = compileSandboxPatterns("deny", cfg.Deny); err != nil {
+		return nil, err
+	}
+	for _, pattern := range cfg.ScrubEnv {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("sandbox scrub_env has invalid pattern '%s'", pattern), err)
+		}
+	}
+	if s.container.Image != "" && s.chroot != "" {
+		return nil, errors.New(errors.ErrCodeConfig, "sandbox container and chroot cannot both be set", nil)
+	}
+	if s.container.Runtime == "" {
+		s.container.Runtime = "docker"
+	}
+	workdir := cfg.Workdir
+	if workdir == "" {
+		workdir = "."
+	}
+	if s.workdir, err = filepath.Abs(workdir); err != nil {
+		return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid sandbox workdir '%s'", cfg.Workdir), err)
+	}
+	return s, nil
+}
+
+func compileSandboxPatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("sandbox %s has invalid pattern '%s'", field, pattern), err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Workdir returns the absolute directory commands run in.
+func (s *Sandbox) Workdir() string {
+	return s.workdir
+}
+
+// Check refuses run_command calls whose command the sandbox does not permit.
+// Other tools pass.
+func (s *Sandbox) Check(call ToolCall) error {
+	if s == nil || !isCommand(call) {
+		return nil
+	}
+	command, _ := call.Arguments["command"].(string)
+	return s.CheckCommand(command)
+}
+
+// CheckCommand refuses a command matching a deny pattern, a command with a
+// part matching no allow pattern, and a command referencing paths outside
+// the workdir.
+func (s *Sandbox) CheckCommand(command string) error {
+	parts := commandParts(command)
+	for _, re := range s.deny {
+		if re.MatchString(command) {
+			return sandboxError(command, fmt.Sprintf("it matches deny pattern %q", re.String()))
+		}
+		for _, part := range parts {
+			if re.MatchString(part) {
+				return sandboxError(command, fmt.Sprintf("it matches deny pattern %q", re.String()))
+			}
+		}
+	}
+	if len(s.allow) > 0 {
+		if strings.Contains(command, "$(") || strings.Contains(command, "`") {
+			return sandboxError(command, "command substitution is not permitted with an allow list")
+		}
+		for _, part := range parts {
+			if !matchesPattern(s.allow, part) {
+				return sandboxError(command, fmt.Sprintf("%q matches no allow pattern", part))
+			}
+		}
+	}
+	if p, ok := s.outsidePath(command); ok {
+		return sandboxError(command, fmt.Sprintf("it references %s outside the sandbox workdir %s", p, s.workdir))
+	}
+	return nil
+}
+
+func sandboxError(command, reason string) error {
+	return errors.New(errors.ErrCodeTool, fmt.Sprintf("command refused by sandbox: %s: %s", reason, command), nil)
+}
+
+func matchesPattern(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandSeparator splits a shell command into the commands it runs, and
+// fdRedirection matches redirections such as 2>&1 that contain an ampersand.
+var (
+	commandSeparator = regexp.MustCompile(`\|\||&&|[;|&\n()]`)
+	fdRedirection    = regexp.MustCompile(`[0-9]*>&[0-9-]+|&>>?`)
+)
+
+// commandParts returns the trimmed, non-empty parts of a compound command.
+func commandParts(command string) []string {
+	var parts []string
+	for _, part := range commandSeparator.Split(fdRedirection.ReplaceAllString(command, " > "), -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// redirection matches the operator before a redirected path, e.g. "2>".
+var redirection = regexp.MustCompile(`^[0-9]*[<>]+&?`)
+
+// sandboxDevices are paths outside the workdir commands may use.
+var sandboxDevices = map[string]bool{"/dev/null": true, "/dev/stdin": true, "/dev/stdout": true, "/dev/stderr": true}
+
+// outsidePath returns the first word of command naming a path outside the
+// workdir: an absolute path, a path above it or one in a home directory. It
+// only sees literal words; a container or chroot confines commands fully.
+func (s *Sandbox) outsidePath(command string) (string, bool) {
+	words := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;|&()`", r)
+	})
+	for _, word := range words {
+		w := redirection.ReplaceAllString(strings.Trim(word, `"'`), "")
+		if strings.HasPrefix(w, "-") {
+			_, w, _ = strings.Cut(w, "=")
+		}
+		if strings.HasPrefix(w, "~") {
+			return w, true
+		}
+		if !filepath.IsAbs(w) && !strings.Contains(w, "..") {
+			continue
+		}
+		p := w
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(s.workdir, p)
+		}
+		p = filepath.Clean(p)
+		if sandboxDevices[p] || p == s.workdir || strings.HasPrefix(p, s.workdir+string(filepath.Separator)) {
+			continue
+		}
+		return w, true
+	}
+	return "", false
+}
+
+// Environ returns env without the variables matched by scrub_env. A nil env
+// stands for the process environment.
+func (s *Sandbox) Environ(env []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	if len(s.scrub) == 0 {
+		return env
+	}
+	kept := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !s.scrubs(name) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+func (s *Sandbox) scrubs(name string) bool {
+	for _, pattern := range s.scrub {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Command returns the program and arguments running command with bash in the
+// container or chroot, if configured, or on the host. Variables of env other
+// than PATH and HOME are passed into a container.
+func (s *Sandbox) Command(command string, env []string) (string, []string) {
+	switch {
+	case s.container.Image != "":
+		args := []string{"run", "--rm", "-i", "-v", s.workdir + ":" + s.workdir, "-w", s.workdir}
+		for _, kv := range env {
+			if name, _, _ := strings.Cut(kv, "="); name != "PATH" && name != "HOME" {
+				args = append(args, "-e", name)
+			}
+		}
+		args = append(args, s.container.Args...)
+		return s.container.Runtime, append(args, s.container.Image, "bash", "-c", command)
+	case s.chroot != "":
+		return "chroot", []string{s.chroot, "bash", "-c", command}
+	default:
+		return "bash", []string{"-c", command}
+	}
+}
+
+type sandboxKey struct{}
+
+// WithSandbox returns ctx carrying the sandbox of the commands run with it.
+func WithSandbox(ctx context.Context, s *Sandbox) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, s)
+}
+
+// SandboxFrom returns the sandbox carried by ctx, or nil.
+func SandboxFrom(ctx context.Context) *Sandbox {
+	s, _ := ctx.Value(sandboxKey{}).(*Sandbox)
+	return s
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestNewSandbox(t *testing.T) {
+	if s, err := NewSandbox(types.SandboxConfig{Allow: []string{"["}}); s != nil || err != nil {
+		t.Errorf("expected no sandbox when disabled, got %v, %v", s, err)
+	}
+	for _, cfg := range []types.SandboxConfig{
+		{Enabled: true, Allow: []string{"["}},
+		{Enabled: true, Deny: []string{"("}},
+		{Enabled: true, ScrubEnv: []string{"["}},
+		{Enabled: true, Chroot: "/srv/root", Container: types.SandboxContainer{Image: "alpine"}},
+	} {
+		if _, err := NewSandbox(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestSandbox_CheckCommand(t *testing.T) {
+	workdir := t.TempDir()
+	s, err := NewSandbox(types.SandboxConfig{Enabled: true, Workdir: workdir, Allow: []string{`^go (build|test|vet)\b`, `^ls\b`}, Deny: []string{`-race`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, command := range []string{"go test ./...", "go build ./... && go vet ./...", "ls pkg 2>/dev/null | ls", "go test ./... 2>&1", "ls " + filepath.Join(workdir, "pkg")} {
+		if err := s.CheckCommand(command); err != nil {
+			t.Errorf("expected %q allowed, got %v", command, err)
+		}
+	}
+	for command, reason := range map[string]string{
+		"rm -rf build":             "matches no allow pattern",
+		"go test ./... ; rm -rf .": "matches no allow pattern",
+		"go test -race ./...":      "deny pattern",
+		"ls $(rm -rf .)":           "command substitution",
+		"ls /etc":                  "outside the sandbox workdir",
+		"ls ../..":                 "outside the sandbox workdir",
+		"ls ~/.ssh":                "outside the sandbox workdir",
+		"go build -o=/usr/bin/x":   "outside the sandbox workdir",
+	} {
+		if err := s.CheckCommand(command); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("expected %q refused with %q, got %v", command, reason, err)
+		}
+	}
+}
+
+func TestSandbox_Command(t *testing.T) {
+	s, _ := NewSandbox(types.SandboxConfig{Enabled: true, Workdir: "/work", Container: types.SandboxContainer{Image: "golang:1.23", Args: []string{"--network=none"}}})
+	name, args := s.Command("go test ./...", []string{"PATH=/bin", "GOFLAGS=-mod=mod"})
+	want := "run --rm -i -v /work:/work -w /work -e GOFLAGS --network=none golang:1.23 bash -c go test ./..."
+	if name != "docker" || strings.Join(args, " ") != want {
+		t.Errorf("unexpected container command %s %q", name, args)
+	}
+	s, _ = NewSandbox(types.SandboxConfig{Enabled: true, Chroot: "/srv/root"})
+	if name, args := s.Command("ls", nil); name != "chroot" || strings.Join(args, " ") != "/srv/root bash -c ls" {
+		t.Errorf("unexpected chroot command %s %q", name, args)
+	}
+}
+
+func TestSandbox_RunCommand(t *testing.T) {
+	workdir := t.TempDir()
+	os.WriteFile(filepath.Join(workdir, "inside.txt"), []byte("x"), 0644)
+	t.Setenv("SANDBOX_TEST_TOKEN", "secret")
+	t.Setenv("SANDBOX_TEST_VISIBLE", "shown")
+	s, err := NewSandbox(types.SandboxConfig{Enabled: true, Workdir: workdir, ScrubEnv: []string{"*_TOKEN"}, Deny: []string{`\brm\b`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exec := &ToolExecutor{Registry: NewToolRegistry(), Sandbox: s}
+	RegisterDefaultTools(exec.Registry)
+
+	result, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "ls; echo \"[$SANDBOX_TEST_TOKEN][$SANDBOX_TEST_VISIBLE]\""}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out := result.(string); !strings.Contains(out, "inside.txt") || !strings.Contains(out, "[][shown]") {
+		t.Errorf("expected the command run in the workdir with the token scrubbed, got %q", out)
+	}
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "rm inside.txt"}}); err == nil || !strings.Contains(err.Error(), "refused by sandbox") {
+		t.Errorf("expected the command refused before dispatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "inside.txt")); err != nil {
+		t.Errorf("expected the refused command not run, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ai-team/pkg/types"
+)
+
+// JSONSchema returns the schema that values of the argument must match:
+// Schema when set, else one derived from Type. The description defaults to
+// the argument's.
+func (a ToolArgument) JSONSchema() *types.JSONSchema {
+	var schema types.JSONSchema
+	if a.Schema != nil {
+		schema = *a.Schema
+	}
+	// A schema without a type allows any value.
+	if schema.Type == "" && (a.Schema == nil || a.Type != "") {
+		schema.Type = jsonSchemaTypeName(a.Type)
+	}
+	if schema.Type == "array" && schema.Items == nil {
+		schema.Items = &types.JSONSchema{Type: "string"}
+	}
+	if schema.Description == "" {
+		schema.Description = a.Description
+	}
+	return &schema
+}
+
+// jsonSchemaTypeName returns the JSON Schema type of an argument type such as
+// "int" or "bool". Unknown types are strings.
+func jsonSchemaTypeName(t string) string {
+	switch strings.ToLower(t) {
+	case "int", "integer":
+		return "integer"
+	case "float", "number":
+		return "number"
+	case "bool", "boolean":
+		return "boolean"
+	case "array", "object":
+		return strings.ToLower(t)
+	}
+	return "string"
+}
+
+// Parameters returns the arguments of the tool as the JSON Schema of an
+// object, as used by function-calling APIs.
+func (s ToolSchema) Parameters() *types.JSONSchema {
+	params := &types.JSONSchema{Type: "object", Properties: map[string]*types.JSONSchema{}, Required: []string{}}
+	for _, arg := range s.Arguments {
+		params.Properties[arg.Name] = arg.JSONSchema()
+		if arg.Required {
+			params.Required = append(params.Required, arg.Name)
+		}
+	}
+	return params
+}
+
+// ConfigArgumentSchema returns the JSON Schema of an argument of a config
+// tool.
+func ConfigArgumentSchema(arg types.ToolArgument) *types.JSONSchema {
+	return &types.JSONSchema{
+		Type:        jsonSchemaTypeName(arg.Type),
+		Description: arg.Description,
+		Enum:        arg.Enum,
+		Default:     arg.Default,
+		Minimum:     arg.Minimum,
+		Maximum:     arg.Maximum,
+		Items:       arg.Items,
+		Properties:  arg.Properties,
+	}
+}
+
+// ValidateValue checks v against schema; name is the argument the value is
+// for and prefixes the path of nested values in the error, e.g. options.level
+// or files[2].
+func ValidateValue(schema *types.JSONSchema, name string, v interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	if v == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return schemaErr(name, "must be %s", schemaTypeLabel(schema))
+	}
+	switch schema.Type {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return schemaErr(name, "must be string")
+		}
+		n := utf8.RuneCountInString(s)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			return schemaErr(name, "must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			return schemaErr(name, "must be at most %d characters", *schema.MaxLength)
+		}
+	case "integer", "number":
+		f, ok := numberValue(v)
+		if !ok || (schema.Type == "integer" && f != math.Trunc(f)) {
+			return schemaErr(name, "must be %s", schema.Type)
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return schemaErr(name, "must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return schemaErr(name, "must be at most %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return schemaErr(name, "must be boolean")
+		}
+	case "array":
+		items, ok := arrayValue(v)
+		if !ok {
+			return schemaErr(name, "must be %s", schemaTypeLabel(schema))
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			return schemaErr(name, "must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			return schemaErr(name, "must have at most %d items", *schema.MaxItems)
+		}
+		for i, item := range items {
+			if err := ValidateValue(schema.Items, fmt.Sprintf("%s[%d]", name, i), item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return schemaErr(name, "must be object")
+		}
+		for _, field := range schema.Required {
+			if _, ok := lookupArgFlexible(fields, field); !ok {
+				return schemaErr(name, "is missing required field '%s'", field)
+			}
+		}
+		keys := make([]string, 0, len(schema.Properties))
+		for key := range schema.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if value, ok := lookupArgFlexible(fields, key); ok {
+				if err := ValidateValue(schema.Properties[key], name+"."+key, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, v) {
+		return schemaErr(name, "must be one of %s", formatEnum(schema.Enum))
+	}
+	return nil
+}
+
+// SchemaError is a value that does not match its schema.
+type SchemaError struct {
+	Path    string // The argument, or a value within it such as options.level or files[2]
+	Problem string // e.g. "must be string"
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("'%s' %s", e.Path, e.Problem)
+}
+
+func schemaErr(path, format string, args ...interface{}) error {
+	return &SchemaError{Path: path, Problem: fmt.Sprintf(format, args...)}
+}
+
+// schemaTypeLabel names the type of schema for errors, e.g. "array of integer".
+func schemaTypeLabel(schema *types.JSONSchema) string {
+	if schema.Type == "array" && schema.Items != nil && schema.Items.Type != "" {
+		return "array of " + schema.Items.Type
+	}
+	if schema.Type == "" {
+		return "set"
+	}
+	return schema.Type
+}
+
+// numberValue returns v as a float64 when it is a number.
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// arrayValue returns the elements of v when it is a list.
+func arrayValue(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case []interface{}:
+		return a, true
+	case []string:
+		out := make([]interface{}, len(a))
+		for i, s := range a {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// enumContains reports whether v is one of values; numbers are compared by
+// value, so 2 matches 2.0.
+func enumContains(values []interface{}, v interface{}) bool {
+	n, isNumber := numberValue(v)
+	for _, allowed := range values {
+		if m, ok := numberValue(allowed); ok && isNumber {
+			if m == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(allowed, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestValidateToolCall_Schema(t *testing.T) {
+	one, ten := 1.0, 10.0
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "search", Arguments: []ToolArgument{
+		{Name: "query", Type: "string", Required: true},
+		{Name: "limit", Type: "int", Schema: &types.JSONSchema{Minimum: &one, Maximum: &ten}},
+		{Name: "options", Schema: &types.JSONSchema{
+			Type:     "object",
+			Required: []string{"level"},
+			Properties: map[string]*types.JSONSchema{
+				"level": {Type: "string", Enum: []interface{}{"debug", "info"}},
+				"files": {Type: "array", Items: &types.JSONSchema{Type: "string"}},
+			},
+		}},
+	}}, nil)
+
+	valid := map[string]interface{}{"query": "x", "limit": 3.0, "options": map[string]interface{}{"level": "info", "files": []interface{}{"a.go"}}}
+	if err := reg.ValidateToolCall(ToolCall{Name: "search", Arguments: valid}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for want, args := range map[string]map[string]interface{}{
+		"'limit' for tool 'search' must be integer":                    {"query": "x", "limit": 2.5},
+		"'limit' for tool 'search' must be at most 10":                 {"query": "x", "limit": 11},
+		"'options' for tool 'search' is missing required field":        {"query": "x", "options": map[string]interface{}{}},
+		"'options.level' for tool 'search' must be one of debug, info": {"query": "x", "options": map[string]interface{}{"level": "trace"}},
+		"'options.files[1]' for tool 'search' must be string":          {"query": "x", "options": map[string]interface{}{"level": "info", "files": []interface{}{"a", 2}}},
+		"'query' for tool 'search' must be string":                     {"query": 1},
+	} {
+		err := reg.ValidateToolCall(ToolCall{Name: "search", Arguments: args})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("args %v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestJSONSchema_TypeList(t *testing.T) {
+	var schema types.JSONSchema
+	if err := json.Unmarshal([]byte(`{"type": "object", "properties": {"max": {"type": ["integer", "null"], "minimum": 0}}}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	max := schema.Properties["max"]
+	if max.Type != "integer" || !max.Nullable || max.Minimum == nil {
+		t.Fatalf("max = %+v", max)
+	}
+	if err := ValidateValue(max, "max", nil); err != nil {
+		t.Errorf("expected null accepted, got %v", err)
+	}
+	if err := ValidateValue(max, "max", -1.0); err == nil {
+		t.Error("expected the minimum enforced")
+	}
+}
+
+func TestRenderPromptSection_Constraints(t *testing.T) {
+	section := renderPromptSection([]ToolSchema{{Name: "log", Description: "Logs.", Arguments: []ToolArgument{
+		{Name: "level", Type: "string", Schema: &types.JSONSchema{Enum: []interface{}{"debug", "info"}, Default: "info"}},
+		{Name: "ids", Type: "array", Schema: &types.JSONSchema{Items: &types.JSONSchema{Type: "integer"}}},
+	}}})
+	for _, want := range []string{"level (string, optional, one of: debug, info, default: info)", "ids (array of integer, optional)"} {
+		if !strings.Contains(section, want) {
+			t.Errorf("expected %q in:\n%s", want, section)
+		}
+	}
+}
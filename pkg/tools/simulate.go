@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"ai-team/pkg/errors"
+	"ai-team/pkg/types"
+)
+
+// Simulator returns scripted results for selected tools instead of executing
+// them, so chain logic can be exercised against a pretend environment.
+type Simulator struct {
+	mu       sync.Mutex
+	fixtures map[string][]types.ToolFixture // keyed by snake_case tool name
+	used     map[string][]int               // uses per fixture
+
+	// In dry-run mode every tool is simulated, with the result mode in
+	// results, keyed by snake_case tool name, or fallback.
+	dryRun   bool
+	results  map[string]string
+	fallback string
+}
+
+// NewSimulator creates a simulator from per-tool fixtures.
+func NewSimulator(fixtures map[string][]types.ToolFixture) (*Simulator, error) {
+	s := &Simulator{fixtures: map[string][]types.ToolFixture{}, used: map[string][]int{}}
+	for name, list := range fixtures {
+		for _, f := range list {
+			for arg, pattern := range f.Match {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return nil, errors.New(errors.ErrCodeConfig, fmt.Sprintf("invalid simulation match for %s.%s", name, arg), err)
+				}
+			}
+		}
+		key := toSnakeCase(name)
+		s.fixtures[key] = append(s.fixtures[key], list...)
+		s.used[key] = make([]int, len(s.fixtures[key]))
+	}
+	return s, nil
+}
+
+// NewDryRunSimulator creates a simulator under which no tool runs except
+// those whose result mode is types.SimulateRun and final_answer. Tools with
+// fixtures use them unless cfg.Results says otherwise.
+func NewDryRunSimulator(cfg types.SimulationConfig) (*Simulator, error) {
+	s, err := NewSimulator(cfg.Tools)
+	if err != nil {
+		return nil, err
+	}
+	s.dryRun = true
+	s.results = map[string]string{}
+	for name, mode := range cfg.Results {
+		s.results[toSnakeCase(name)] = mode
+	}
+	s.fallback = cfg.DefaultResult
+	if s.fallback == "" {
+		s.fallback = types.SimulateSkipped
+	}
+	return s, nil
+}
+
+// mode returns how the simulator answers calls to the tool.
+func (s *Simulator) mode(name string) string {
+	key := toSnakeCase(name)
+	_, hasFixtures := s.fixtures[key]
+	switch {
+	case !s.dryRun && hasFixtures:
+		return types.SimulateFixture
+	case !s.dryRun, key == FinalAnswerName:
+		return types.SimulateRun
+	case s.results[key] != "":
+		return s.results[key]
+	case hasFixtures:
+		return types.SimulateFixture
+	}
+	return s.fallback
+}
+
+// Simulates reports whether calls to the tool get a simulated result rather
+// than being executed.
+func (s *Simulator) Simulates(name string) bool {
+	return s.mode(name) != types.SimulateRun
+}
+
+// DryRun reports whether the simulator was created by NewDryRunSimulator.
+func (s *Simulator) DryRun() bool {
+	return s.dryRun
+}
+
+// Result returns the simulated result for call. With fixtures, the first
+// fixture whose argument patterns match and whose use count is not exhausted
+// wins. In dry-run mode, echo returns the call's arguments and skipped a
+// marker saying the call did not run.
+func (s *Simulator) Result(call ToolCall) (interface{}, error) {
+	switch s.mode(call.Name) {
+	case types.SimulateEcho:
+		args := make(map[string]interface{}, len(call.Arguments))
+		for k, v := range call.Arguments {
+			args[k] = v
+		}
+		return args, nil
+	case types.SimulateSkipped:
+		return fmt.Sprintf("[dry run] %s was not executed", call.Name), nil
+	}
+	key := toSnakeCase(call.Name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.fixtures[key] {
+		if f.Times > 0 && s.used[key][i] >= f.Times {
+			continue
+		}
+		if !fixtureMatches(f, call.Arguments) {
+			continue
+		}
+		s.used[key][i]++
+		if f.Error != "" {
+			return f.Result, errors.New(errors.ErrCodeTool, fmt.Sprintf("simulated failure: %s", f.Error), nil)
+		}
+		return f.Result, nil
+	}
+	return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("no simulation fixture matched call to %s", call.Name), nil)
+}
+
+func fixtureMatches(f types.ToolFixture, args map[string]interface{}) bool {
+	for arg, pattern := range f.Match {
+		v, ok := lookupArgFlexible(args, arg)
+		if !ok {
+			return false
+		}
+		if matched, _ := regexp.MatchString(pattern, fmt.Sprintf("%v", v)); !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestSimulator_ScriptedSequence(t *testing.T) {
+	sim, err := NewSimulator(map[string][]types.ToolFixture{
+		"RunCommand": {
+			{Match: map[string]string{"command": "^go test"}, Result: "FAIL: TestX", Error: "exit status 1", Times: 1},
+			{Match: map[string]string{"command": "^go test"}, Result: "ok"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	reg.RegisterTool(ToolSchema{Name: "RunCommand"}, tool)
+	reg.RegisterTool(ToolSchema{Name: "Counter"}, tool)
+	exec := &ToolExecutor{Registry: reg, Simulator: sim}
+
+	call := ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "go test ./..."}}
+	result, err := exec.Execute(context.Background(), call)
+	if err == nil || !strings.Contains(err.Error(), "exit status 1") || result != "FAIL: TestX" {
+		t.Fatalf("expected simulated failure first, got %v, %v", result, err)
+	}
+	result, err = exec.Execute(context.Background(), call)
+	if err != nil || result != "ok" {
+		t.Fatalf("expected simulated success second, got %v, %v", result, err)
+	}
+
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "RunCommand", Arguments: map[string]interface{}{"command": "rm -rf /"}}); err == nil {
+		t.Fatalf("expected unmatched call to a simulated tool to fail")
+	}
+	if tool.calls != 0 {
+		t.Fatalf("simulated tool must not execute, got %d executions", tool.calls)
+	}
+
+	if _, err := exec.Execute(context.Background(), ToolCall{Name: "Counter"}); err != nil || tool.calls != 1 {
+		t.Fatalf("expected unlisted tool to execute normally, got %d executions (%v)", tool.calls, err)
+	}
+}
+
+func TestNewSimulator_InvalidPattern(t *testing.T) {
+	_, err := NewSimulator(map[string][]types.ToolFixture{
+		"run_command": {{Match: map[string]string{"command": "("}}},
+	})
+	if err == nil {
+		t.Fatalf("expected error for invalid match pattern")
+	}
+}
+
+func TestDryRunSimulator(t *testing.T) {
+	sim, err := NewDryRunSimulator(types.SimulationConfig{
+		Tools:   map[string][]types.ToolFixture{"run_command": {{Result: "ok"}}},
+		Results: map[string]string{"ReadFile": types.SimulateRun, "write_file": types.SimulateEcho},
+	})
+	if err != nil {
+		t.Fatalf("NewDryRunSimulator: %v", err)
+	}
+	tool := &countingTool{}
+	reg := NewToolRegistry()
+	for _, name := range []string{"read_file", "write_file", "run_command", "list_dir", FinalAnswerName} {
+		reg.RegisterTool(ToolSchema{Name: name}, tool)
+	}
+	exec := &ToolExecutor{Registry: reg, Simulator: sim}
+	ctx := context.Background()
+
+	result, err := exec.Execute(ctx, ToolCall{Name: "write_file", Arguments: map[string]interface{}{"file_path": "a.go"}})
+	if args, _ := result.(map[string]interface{}); err != nil || args["file_path"] != "a.go" {
+		t.Errorf("expected write_file to echo its arguments, got %v, %v", result, err)
+	}
+	if result, err := exec.Execute(ctx, ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "make"}}); err != nil || result != "ok" {
+		t.Errorf("expected run_command to use its fixture, got %v, %v", result, err)
+	}
+	if result, err := exec.Execute(ctx, ToolCall{Name: "list_dir"}); err != nil || result != "[dry run] list_dir was not executed" {
+		t.Errorf("expected list_dir to be skipped, got %v, %v", result, err)
+	}
+	if tool.calls != 0 {
+		t.Fatalf("simulated tools must not execute, got %d executions", tool.calls)
+	}
+	for _, name := range []string{"read_file", FinalAnswerName} {
+		if _, err := exec.Execute(ctx, ToolCall{Name: name}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if tool.calls != 2 || !sim.DryRun() {
+		t.Errorf("expected read_file and final_answer to run, got %d executions", tool.calls)
+	}
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ai-team/pkg/errors"
+)
+
+// argKey folds the spellings of an argument name, such as file_path, filePath
+// and FILE_PATH, into one key.
+func argKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// aliasedValue returns the value of the argument given under any of names, in
+// any spelling argKey folds together, and whether the call gives it. A call
+// giving several of them with different values fails, so that checks and
+// tools cannot read different ones.
+func aliasedValue(args map[string]interface{}, names ...string) (interface{}, bool, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[argKey(name)] = true
+	}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		if wanted[argKey(k)] {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, false, nil
+	}
+	sort.Strings(keys)
+	value := args[keys[0]]
+	for _, k := range keys[1:] {
+		if fmt.Sprint(args[k]) != fmt.Sprint(value) {
+			return nil, false, fmt.Errorf("%s and %s name different values", keys[0], k)
+		}
+	}
+	return value, true, nil
+}
+
+// aliasedArg is aliasedValue for string arguments.
+func aliasedArg(args map[string]interface{}, names ...string) (string, bool, error) {
+	v, ok, err := aliasedValue(args, names...)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	s, isString := v.(string)
+	if !isString {
+		return "", false, fmt.Errorf("%s must be a string", names[0])
+	}
+	return s, true, nil
+}
+
+// pathArgs returns the arguments naming the file or directory a tool works
+// on, as groups of aliases. The first group the call gives wins. Other tools
+// than the built-in file tools are read like the ignore rules always read
+// them: file_path, then path, then directory.
+func pathArgs(tool string) [][]string {
+	switch toSnakeCase(tool) {
+	case "read_file", "write_file", "apply_patch", "begin_file", "append_file", "end_file":
+		return [][]string{{"file_path"}}
+	case "list_dir":
+		return [][]string{{"path", "directory"}}
+	}
+	return [][]string{{"file_path"}, {"path"}, {"directory"}}
+}
+
+// targetPath returns the file or directory a call of tool with args works on,
+// read the way the tool reads it, and whether the call names one.
+func targetPath(tool string, args map[string]interface{}) (string, bool, error) {
+	for _, group := range pathArgs(tool) {
+		path, ok, err := aliasedArg(args, group...)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid arguments for %s: %v", tool, err)
+		}
+		if ok && path != "" {
+			return path, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// TargetPath returns the file or directory call works on, read from the
+// arguments its tool reads, and whether the call names one. Checks run
+// before a call use it, so they see the path the tool will use. A call naming
+// the path through aliases with different values, such as file_path and
+// filePath, fails.
+func TargetPath(call ToolCall) (string, bool, error) {
+	path, ok, err := targetPath(call.Name, call.Arguments)
+	if err != nil {
+		return "", false, errors.New(errors.ErrCodeTool, err.Error(), nil)
+	}
+	return path, ok, nil
+}
+
+// WriteTarget returns the file a writing call changes and the content it
+// writes, read the way the tool reads them, and whether call writes a file
+// at all.
+func WriteTarget(call ToolCall) (path, content string, isWrite bool, err error) {
+	contentArg := "content"
+	switch toSnakeCase(call.Name) {
+	case "write_file", "end_file":
+	case "apply_patch":
+		contentArg = "patch_content"
+	default:
+		return "", "", false, nil
+	}
+	if path, _, err = TargetPath(call); err != nil {
+		return "", "", true, err
+	}
+	content, _, cerr := aliasedArg(call.Arguments, contentArg)
+	if cerr != nil {
+		return "", "", true, errors.New(errors.ErrCodeTool, fmt.Sprintf("invalid arguments for %s: %v", call.Name, cerr), nil)
+	}
+	return path, content, true, nil
+}
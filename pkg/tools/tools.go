@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,7 +12,10 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"ai-team/pkg/cleanup"
 	"ai-team/pkg/errors"
+	"ai-team/pkg/memory"
+	"ai-team/pkg/types"
 )
 
 // BackupFile creates a backup of a file.
@@ -42,14 +46,59 @@ type ToolExecutor struct {
 	MetricsHook func(event string, fields map[string]interface{})
 	RetryCount  int
 	Timeout     time.Duration
+	// Quota, when set, enforces per-run tool limits before each call.
+	Quota *QuotaTracker
+	// Dedup, when set, skips calls identical to an earlier successful call and
+	// returns the earlier result instead.
+	Dedup *DedupCache
+	// Simulator, when set, returns scripted results for the tools it covers
+	// instead of executing them.
+	Simulator *Simulator
+	// Ignore, when set, rejects calls on excluded paths and hides excluded
+	// entries from directory listings.
+	Ignore *IgnoreFilter
+	// Policy, when set, allows, denies or asks for confirmation of each call.
+	Policy *Policy
+	// Lock, when set, is held while a file-writing tool runs so concurrent
+	// runs on the same workspace do not interleave writes.
+	Lock *WorkspaceLock
+	// Journal, when set, records each file-writing tool call before it runs
+	// so that a write interrupted by a crash can be reported and reverted.
+	Journal *Journal
+	// Env, when set, is the environment of commands run by tools, unless the
+	// context passed to Execute carries one.
+	Env *Env
+	// PostProcess, when set, trims the results of the tools it covers. A result
+	// that cannot be processed is returned whole.
+	PostProcess *PostProcessors
+	// Sandbox, when set, refuses run_command calls it does not permit and
+	// confines the commands it lets run.
+	Sandbox *Sandbox
+	// Review, when set, is asked about each call that passed the other checks
+	// right before it runs; an error refuses the call.
+	Review func(ctx context.Context, call ToolCall) error
 }
 
-// Execute runs a ToolCall with validation, logging, error handling, and retry/timeout logic.
-func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
+// Execute runs a ToolCall with validation, logging, error handling, and
+// retry/timeout logic. Once parent is done, or an attempt times out, the tool
+// is stopped through its context and the call is not retried.
+func (te *ToolExecutor) Execute(parent context.Context, call ToolCall) (interface{}, error) {
 	if te.Logger == nil {
 		te.Logger = logrus.New()
 	}
 	logger := te.Logger.WithFields(logrus.Fields{"tool": call.Name, "args": call.Arguments})
+	if err := parent.Err(); err != nil {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("tool %s not run", call.Name), err)
+	}
+	if te.Env != nil && EnvFrom(parent) == nil {
+		parent = WithEnv(parent, te.Env)
+	}
+	if te.Sandbox != nil {
+		parent = WithSandbox(parent, te.Sandbox)
+	}
+	if te.Ignore != nil {
+		parent = WithIgnore(parent, te.Ignore)
+	}
 	logger.Infof("ToolExecutor: Executing tool call: %s", call.Name)
 	if te.MetricsHook != nil {
 		te.MetricsHook("tool_call_start", map[string]interface{}{"tool": call.Name, "args": call.Arguments})
@@ -64,6 +113,106 @@ func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
 		return nil, err
 	}
 
+	target, hasTarget, err := TargetPath(call)
+	if err != nil {
+		logger.Warn(err)
+		if te.MetricsHook != nil {
+			te.MetricsHook("tool_call_validation_failed", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+		}
+		return nil, err
+	}
+
+	if te.Ignore != nil {
+		if path := target; hasTarget && te.Ignore.Ignored(path) {
+			err := errors.New(errors.ErrCodeTool, fmt.Sprintf("path %s is excluded by ignore rules", path), nil)
+			logger.Warn(err)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_ignored_path", map[string]interface{}{"tool": call.Name, "path": path})
+			}
+			return nil, err
+		}
+	}
+
+	if err := te.Sandbox.Check(call); err != nil {
+		logger.Warn(err)
+		if te.MetricsHook != nil {
+			te.MetricsHook("tool_call_sandbox_denied", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+		}
+		return nil, err
+	}
+
+	if te.Policy != nil {
+		if err := te.Policy.Check(call); err != nil {
+			logger.Warnf("Policy check failed: %v", err)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_policy_denied", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+			}
+			return nil, err
+		}
+	}
+
+	if te.Dedup != nil {
+		if cached, ok := te.Dedup.Lookup(call); ok {
+			logger.Infof("Skipping repeated tool call %s; returning previous result", call.Name)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_deduplicated", map[string]interface{}{"tool": call.Name})
+			}
+			return cached, nil
+		}
+		reminder, ok, err := te.Dedup.LookupSimilar(call)
+		if err != nil {
+			logger.Warnf("Near-duplicate check for %s failed: %v", call.Name, err)
+		} else if ok {
+			logger.Infof("Skipping near-duplicate tool call %s; reminding the model of the earlier result", call.Name)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_near_duplicate", map[string]interface{}{"tool": call.Name})
+			}
+			return reminder, nil
+		}
+		defer te.Dedup.forgetPending(call)
+		if mutates(call) {
+			defer te.Dedup.Invalidate(call)
+		}
+	}
+
+	if te.Quota != nil {
+		if err := te.Quota.Check(call); err != nil {
+			logger.Warnf("Quota check failed: %v", err)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_quota_exceeded", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+			}
+			return nil, err
+		}
+	}
+
+	if te.Simulator != nil && te.Simulator.Simulates(call.Name) {
+		logger.Infof("Simulating tool call %s", call.Name)
+		result, err := te.Simulator.Result(call)
+		if te.MetricsHook != nil {
+			te.MetricsHook("tool_call_simulated", map[string]interface{}{"tool": call.Name, "failed": err != nil})
+		}
+		if err != nil {
+			return result, err
+		}
+		if te.Quota != nil {
+			te.Quota.Record(call)
+		}
+		if te.Dedup != nil {
+			te.Dedup.Store(call, result)
+		}
+		return result, nil
+	}
+
+	if te.Review != nil {
+		if err := te.Review(parent, call); err != nil {
+			logger.Warnf("Review refused the call: %v", err)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_review_denied", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+			}
+			return nil, err
+		}
+	}
+
 	toolImpl, ok := te.Registry.GetToolImpl(call.Name)
 	if !ok {
 		err := fmt.Errorf("tool implementation not found: %s", call.Name)
@@ -74,6 +223,26 @@ func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
 		return nil, err
 	}
 
+	if path, _, isWrite := writeTarget(call); isWrite && te.Lock != nil {
+		release, err := te.Lock.Acquire(call.Name + " " + path)
+		if err != nil {
+			logger.Warn(err)
+			if te.MetricsHook != nil {
+				te.MetricsHook("tool_call_locked", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+			}
+			return nil, err
+		}
+		defer release()
+	}
+	if path, _, isWrite := writeTarget(call); isWrite && path != "" && te.Journal != nil {
+		id, err := te.Journal.Begin(call.Name, path)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		defer te.Journal.End(id)
+	}
+
 	var lastErr error
 	retries := te.RetryCount
 	if retries < 1 {
@@ -83,22 +252,43 @@ func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
 		if te.MetricsHook != nil {
 			te.MetricsHook("tool_call_attempt", map[string]interface{}{"tool": call.Name, "attempt": attempt})
 		}
-		ctx := context.Background()
+		ctx := parent
 		if te.Timeout > 0 {
 			var cancel context.CancelFunc
 			ctx, cancel = context.WithTimeout(ctx, te.Timeout)
 			defer cancel()
 		}
-		done := make(chan struct{})
-		var result interface{}
+		// The tool runs aside so that one ignoring ctx cannot hold up the
+		// executor; its late result goes to the buffered channel unread.
+		done := make(chan toolOutcome, 1)
 		go func() {
-			result, lastErr = toolImpl.Execute(call.Arguments)
-			close(done)
+			result, err := toolImpl.Execute(ctx, call.Arguments)
+			done <- toolOutcome{result, err}
 		}()
 		select {
-		case <-done:
+		case outcome := <-done:
+			result := outcome.result
+			lastErr = outcome.err
 			if lastErr == nil {
 				logger.Infof("Tool %s succeeded on attempt %d", call.Name, attempt)
+				if te.Ignore != nil && toSnakeCase(call.Name) == "list_dir" {
+					dir := target
+					if !hasTarget {
+						dir = "."
+					}
+					result = te.Ignore.filterListing(dir, result)
+				}
+				if processed, err := te.PostProcess.Apply(call.Name, result); err != nil {
+					logger.Warnf("Returning the whole result: %v", err)
+				} else {
+					result = processed
+				}
+				if te.Quota != nil {
+					te.Quota.Record(call)
+				}
+				if te.Dedup != nil {
+					te.Dedup.Store(call, result)
+				}
 				if te.MetricsHook != nil {
 					te.MetricsHook("tool_call_success", map[string]interface{}{"tool": call.Name, "attempt": attempt})
 				}
@@ -109,6 +299,14 @@ func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
 				te.MetricsHook("tool_call_failure", map[string]interface{}{"tool": call.Name, "attempt": attempt, "error": lastErr.Error()})
 			}
 		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				lastErr = errors.New(errors.ErrCodeTool, fmt.Sprintf("tool %s cancelled", call.Name), err)
+				logger.Error(lastErr)
+				if te.MetricsHook != nil {
+					te.MetricsHook("tool_call_cancelled", map[string]interface{}{"tool": call.Name, "error": err.Error()})
+				}
+				return nil, lastErr
+			}
 			lastErr = fmt.Errorf("tool %s timed out after %s", call.Name, te.Timeout)
 			logger.Error(lastErr)
 			if te.MetricsHook != nil {
@@ -123,10 +321,20 @@ func (te *ToolExecutor) Execute(call ToolCall) (interface{}, error) {
 	return nil, lastErr
 }
 
+// toolOutcome is what one attempt of a tool returned.
+type toolOutcome struct {
+	result interface{}
+	err    error
+}
+
 // ToolRegistry holds all registered tools and their schemas.
 type ToolRegistry struct {
 	tools map[string]ToolSchema
-	impls map[string]Tool // tool name to implementation
+	impls map[string]Tool  // tool name to implementation
+	files *ChunkedFiles    // pending begin_file/append_file writes
+	docs  *DocumentStore   // documents of save_document/load_document
+	mem   *memory.Store    // facts of remember/recall
+	http  *HTTPRequestTool // http_request, configured from the config
 }
 
 // NewToolRegistry creates a new ToolRegistry instance.
@@ -143,6 +351,29 @@ func (r *ToolRegistry) RegisterTool(schema ToolSchema, impl Tool) {
 	r.impls[schema.Name] = impl
 }
 
+// ChunkedFiles returns the files being written with begin_file/append_file, or
+// nil when the chunk tools are not registered.
+func (r *ToolRegistry) ChunkedFiles() *ChunkedFiles {
+	return r.files
+}
+
+// Documents returns the store of save_document and load_document, or nil when
+// the document tools are not registered.
+func (r *ToolRegistry) Documents() *DocumentStore {
+	return r.docs
+}
+
+// Memory returns the store of remember and recall, or nil when the memory
+// tools are not registered.
+func (r *ToolRegistry) Memory() *memory.Store {
+	return r.mem
+}
+
+// HTTP returns the http_request tool, or nil when it is not registered.
+func (r *ToolRegistry) HTTP() *HTTPRequestTool {
+	return r.http
+}
+
 // GetToolSchema returns the schema for a tool by name.
 func (r *ToolRegistry) GetToolSchema(name string) (ToolSchema, bool) {
 	schema, ok := r.tools[name]
@@ -164,25 +395,83 @@ func (r *ToolRegistry) ListTools() []ToolSchema {
 	return schemas
 }
 
-// Tool is the interface all tools must implement.
+// Tool is the interface all tools must implement. Execute should return
+// soon after ctx is done, e.g. by killing a running command, so that a call
+// that timed out or was cancelled does not keep working in the background.
 type Tool interface {
-	Execute(args map[string]interface{}) (interface{}, error)
+	Execute(ctx context.Context, args map[string]interface{}) (interface{}, error)
+}
+
+// checkContext returns an error once ctx is done, for tools to call before
+// starting work that nobody waits for any more.
+func checkContext(ctx context.Context, tool string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.New(errors.ErrCodeTool, tool+" cancelled", err)
+	}
+	return nil
 }
 
 // ListDirTool implements the Tool interface for listing directory contents.
 type ListDirTool struct{}
 
-func (t *ListDirTool) Execute(args map[string]interface{}) (interface{}, error) {
-	// Accept both "path" and "directory" as valid argument keys
-	var path string
-	if p, ok := args["path"].(string); ok {
-		path = p
-	} else if d, ok := args["directory"].(string); ok {
-		path = d
-	} else {
-		return nil, fmt.Errorf("invalid arguments for ListDir: path or directory required")
+func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if err := checkContext(ctx, "ListDir"); err != nil {
+		return nil, err
+	}
+	// Accept both "path" and "directory"; without either list the current directory
+	path, ok, err := targetPath("ListDir", args)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		path = "."
+	}
+	// Plain name listing unless the caller asks for recursion, filters or details
+	opts, structured := listDirOptionsFromArgs(args)
+	if !structured {
+		return ListDir(path)
+	}
+	return ListDirWithOptionsContext(ctx, path, opts)
+}
+
+func listDirOptionsFromArgs(args map[string]interface{}) (ListDirOptions, bool) {
+	var opts ListDirOptions
+	structured := false
+	if v, ok := lookupArgFlexible(args, "recursive"); ok {
+		opts.Recursive, _ = v.(bool)
+		structured = true
+	}
+	if v, ok := lookupArgFlexible(args, "max_depth"); ok {
+		switch n := v.(type) {
+		case int:
+			opts.MaxDepth = n
+		case float64:
+			opts.MaxDepth = int(n)
+		}
+		structured = true
+	}
+	if v, ok := lookupArgFlexible(args, "include"); ok {
+		if s, ok := v.(string); ok {
+			opts.Include = strings.Split(s, ",")
+		}
+		structured = true
+	}
+	if v, ok := lookupArgFlexible(args, "exclude"); ok {
+		if s, ok := v.(string); ok {
+			opts.Exclude = strings.Split(s, ",")
+		}
+		structured = true
+	}
+	if v, ok := lookupArgFlexible(args, "no_ignore"); ok {
+		opts.NoIgnore, _ = v.(bool)
+		structured = true
 	}
-	return ListDir(path)
+	if v, ok := lookupArgFlexible(args, "detailed"); ok {
+		if b, _ := v.(bool); b {
+			structured = true
+		}
+	}
+	return opts, structured
 }
 
 // ListDir lists the contents of a directory and returns a slice of file/directory names.
@@ -205,11 +494,21 @@ func ListDir(path string) ([]string, error) {
 // ReadFileTool implements the Tool interface for reading file contents.
 type ReadFileTool struct{}
 
-func (t *ReadFileTool) Execute(args map[string]interface{}) (interface{}, error) {
-	filePath, ok := args["file_path"].(string)
-	if !ok {
+func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if err := checkContext(ctx, "ReadFile"); err != nil {
+		return nil, err
+	}
+	// Accept both "file_path" and "filePath" (and case variants)
+	filePath, _, err := targetPath("ReadFile", args)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(filePath) == "" {
 		return nil, fmt.Errorf("invalid arguments for ReadFile: file_path required")
 	}
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		return nil, errors.New(errors.ErrCodeTool, fmt.Sprintf("%s is a directory; use list_dir to list it", filePath), nil)
+	}
 	return ReadFile(filePath)
 }
 
@@ -225,38 +524,20 @@ func ReadFile(filePath string) (string, error) {
 // WriteFileTool implements the Tool interface for writing files.
 type WriteFileTool struct{}
 
-func (t *WriteFileTool) Execute(args map[string]interface{}) (interface{}, error) {
+func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	if err := checkContext(ctx, "WriteFile"); err != nil {
+		return nil, err
+	}
 	// Accept both "filePath" and "file_path" (and case variants)
-	var filePath string
-	if v, ok := args["filePath"].(string); ok {
-		filePath = v
-	} else if v, ok := args["file_path"].(string); ok {
-		filePath = v
-	} else {
-		// try case-insensitive lookup
-		for k, val := range args {
-			if strings.EqualFold(k, "filePath") || strings.EqualFold(k, "file_path") {
-				if s, ok := val.(string); ok {
-					filePath = s
-					break
-				}
-			}
-		}
+	filePath, _, err := targetPath("WriteFile", args)
+	if err != nil {
+		return nil, err
 	}
-	content, ok2 := args["content"].(string)
-	if !ok2 {
-		// try case-insensitive lookup
-		for k, val := range args {
-			if strings.EqualFold(k, "content") {
-				if s, ok := val.(string); ok {
-					content = s
-					ok2 = true
-					break
-				}
-			}
-		}
+	content, ok, err := aliasedArg(args, "content")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for WriteFile: %v", err)
 	}
-	if filePath == "" || !ok2 {
+	if filePath == "" || !ok {
 		return nil, fmt.Errorf("invalid arguments for WriteFile: filePath and content required")
 	}
 	return WriteFile(filePath, content)
@@ -265,52 +546,44 @@ func (t *WriteFileTool) Execute(args map[string]interface{}) (interface{}, error
 // RunCommandTool implements the Tool interface for running shell commands.
 type RunCommandTool struct{}
 
-func (t *RunCommandTool) Execute(args map[string]interface{}) (interface{}, error) {
+// Execute runs the command, killing it when ctx is done.
+func (t *RunCommandTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
 	command, ok := args["command"].(string)
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments for RunCommand: command required")
 	}
-	return RunCommand(command)
+	return RunCommandContext(ctx, command)
 }
 
 // ApplyPatchTool implements the Tool interface for applying patches.
 type ApplyPatchTool struct{}
 
-func (t *ApplyPatchTool) Execute(args map[string]interface{}) (interface{}, error) {
-	filePath, ok1 := args["filePath"].(string)
-	patchContent, ok2 := args["patchContent"].(string)
+// Execute applies the patch, killing the patch command when ctx is done.
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	filePath, ok1, err := targetPath("ApplyPatch", args)
+	if err != nil {
+		return nil, err
+	}
+	patchContent, ok2, err := aliasedArg(args, "patch_content")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for ApplyPatch: %v", err)
+	}
 	if !ok1 || !ok2 {
 		return nil, fmt.Errorf("invalid arguments for ApplyPatch: filePath and patchContent required")
 	}
-	return ApplyPatch(filePath, patchContent)
+	return ApplyPatchContext(ctx, filePath, patchContent)
 }
 
 // RegisterDefaultTools registers the built-in tools in the given registry.
 func RegisterDefaultTools(reg *ToolRegistry) {
-	reg.RegisterTool(ToolSchema{
-		Name:        "ReadFile",
-		Description: "Reads the contents of a file and returns it as a string.",
-		Arguments: []ToolArgument{
-			{Name: "file_path", Type: "string", Required: true, Description: "Path to the file to read."},
-		},
-	}, &ReadFileTool{})
-	// Register both 'ListDir' and 'list_dir' for compatibility with model output
-	reg.RegisterTool(ToolSchema{
-		Name:        "ListDir",
-		Description: "Lists the contents of a directory.",
-		Arguments: []ToolArgument{
-			{Name: "path", Type: "string", Required: false, Description: "Path to the directory to list."},
-			{Name: "directory", Type: "string", Required: false, Description: "Directory to list (alias for path)."},
-		},
-	}, &ListDirTool{})
-	reg.RegisterTool(ToolSchema{
-		Name:        "list_dir",
-		Description: "Lists the contents of a directory.",
-		Arguments: []ToolArgument{
-			{Name: "path", Type: "string", Required: false, Description: "Path to the directory to list."},
-			{Name: "directory", Type: "string", Required: false, Description: "Directory to list (alias for path)."},
-		},
-	}, &ListDirTool{})
+	// ReadFile and ListDir (camelCase and snake_case); the extractor
+	// normalizes tool names to snake_case before validating them.
+	for _, name := range []string{"ReadFile", "read_file"} {
+		reg.RegisterTool(readFileSchema(name), &ReadFileTool{})
+	}
+	for _, name := range []string{"ListDir", "list_dir"} {
+		reg.RegisterTool(listDirSchema(name), &ListDirTool{})
+	}
 
 	// WriteFile (camelCase and snake_case)
 	reg.RegisterTool(ToolSchema{
@@ -346,6 +619,46 @@ func RegisterDefaultTools(reg *ToolRegistry) {
 			{Name: "patchContent", Type: "string", Required: true, Description: "Patch content."},
 		},
 	}, &ApplyPatchTool{})
+
+	reg.files = NewChunkedFiles()
+	registerChunkTools(reg, reg.files)
+	reg.docs = NewDocumentStore("")
+	registerDocumentTools(reg, reg.docs)
+	reg.mem = memory.NewStore("")
+	registerMemoryTools(reg, reg.mem)
+	registerGitTools(reg)
+	reg.http = &HTTPRequestTool{}
+	registerHTTPTool(reg, reg.http)
+	registerFinalAnswerTool(reg)
+}
+
+// readFileSchema returns the schema of the read_file tool under name.
+func readFileSchema(name string) ToolSchema {
+	return ToolSchema{
+		Name:        name,
+		Description: "Reads the contents of a file and returns it as a string. Use list_dir to find files.",
+		Arguments: []ToolArgument{
+			{Name: "file_path", Type: "string", Required: true, Description: "Path to the file to read."},
+		},
+	}
+}
+
+// listDirSchema returns the schema of the list_dir tool under name.
+func listDirSchema(name string) ToolSchema {
+	return ToolSchema{
+		Name:        name,
+		Description: "Lists the contents of a directory (default: the current one). With recursive, filters or detailed set, returns entries with path, type, size and mtime, skipping .gitignore/.ai-teamignore matches.",
+		Arguments: []ToolArgument{
+			{Name: "path", Type: "string", Required: false, Description: "Path to the directory to list."},
+			{Name: "directory", Type: "string", Required: false, Description: "Directory to list (alias for path)."},
+			{Name: "recursive", Type: "bool", Required: false, Description: "List subdirectories recursively."},
+			{Name: "max_depth", Type: "int", Required: false, Description: "Maximum depth when recursive (0 = unlimited)."},
+			{Name: "include", Type: "string", Required: false, Description: "Comma-separated globs files must match, e.g. '*.go,docs/**/*.md'."},
+			{Name: "exclude", Type: "string", Required: false, Description: "Comma-separated globs of files and directories to skip."},
+			{Name: "no_ignore", Type: "bool", Required: false, Description: "Also list paths matched by .gitignore/.ai-teamignore."},
+			{Name: "detailed", Type: "bool", Required: false, Description: "Return entries with path, type, size and mtime."},
+		},
+	}
 }
 
 // ToolCall represents a validated tool invocation.
@@ -365,31 +678,16 @@ func (r *ToolRegistry) ValidateToolCall(call ToolCall) error {
 	for _, arg := range schema.Arguments {
 		// flexible lookup: exact key, snake_case, camelCase, case-insensitive
 		val, exists := lookupArgFlexible(call.Arguments, arg.Name)
-		if arg.Required && !exists {
+		if arg.Required && !exists && !hasRef(schema, call.Arguments, arg.Name) {
 			return fmt.Errorf("missing required argument '%s' for tool '%s'", arg.Name, call.Name)
 		}
 		if exists {
-			switch arg.Type {
-			case "string":
-				if _, ok := val.(string); !ok {
-					return fmt.Errorf("argument '%s' for tool '%s' must be string", arg.Name, call.Name)
-				}
-			case "int":
-				if _, ok := val.(int); !ok {
-					// JSON numbers may be float64 when unmarshaled
-					if f, okf := val.(float64); okf {
-						// allow float that is integer-valued
-						if f == float64(int(f)) {
-							continue
-						}
-					}
-					return fmt.Errorf("argument '%s' for tool '%s' must be int", arg.Name, call.Name)
+			if err := ValidateValue(arg.JSONSchema(), arg.Name, val); err != nil {
+				var schemaErr *SchemaError
+				if stderrors.As(err, &schemaErr) {
+					return fmt.Errorf("argument '%s' for tool '%s' %s", schemaErr.Path, call.Name, schemaErr.Problem)
 				}
-			case "bool":
-				if _, ok := val.(bool); !ok {
-					return fmt.Errorf("argument '%s' for tool '%s' must be bool", arg.Name, call.Name)
-				}
-				// Add more types as needed
+				return err
 			}
 		}
 	}
@@ -417,6 +715,20 @@ func lookupArgFlexible(args map[string]interface{}, name string) (interface{}, b
 	return nil, false
 }
 
+// stringArg returns the named argument when it is a string, else "".
+func stringArg(args map[string]interface{}, name string) string {
+	v, _ := lookupArgFlexible(args, name)
+	s, _ := v.(string)
+	return s
+}
+
+// boolArg returns the named argument and whether it is set to a boolean.
+func boolArg(args map[string]interface{}, name string) (bool, bool) {
+	v, ok := lookupArgFlexible(args, name)
+	b, isBool := v.(bool)
+	return b, ok && isBool
+}
+
 func toSnakeCase(s string) string {
 	// simple conversion: CamelCase -> snake_case
 	var out []rune
@@ -452,12 +764,15 @@ type ToolSchema struct {
 	Arguments   []ToolArgument
 }
 
-// ToolArgument defines a single argument for a tool.
+// ToolArgument defines a single argument for a tool. Type is a shorthand
+// for simple arguments; Schema, when set, describes values in full, e.g. the
+// items of an array, the fields of an object, enums and ranges.
 type ToolArgument struct {
 	Name        string
 	Type        string // e.g. "string", "int", "bool"
 	Required    bool
 	Description string
+	Schema      *types.JSONSchema `json:",omitempty"`
 }
 
 // WriteFile writes content to a specified file.
@@ -507,6 +822,13 @@ func WriteFile(filePath string, content string) (string, error) {
 
 // RunCommand executes a shell command.
 func RunCommand(command string) (string, error) {
+	return RunCommandContext(context.Background(), command)
+}
+
+// RunCommandContext is RunCommand with the command, and every process it
+// started, killed when ctx is done. The command's resource use is recorded
+// with the UsageRecorder of ctx.
+func RunCommandContext(ctx context.Context, command string) (string, error) {
 	log := logrus.WithFields(logrus.Fields{
 		"tool":    "RunCommand",
 		"command": command,
@@ -525,18 +847,61 @@ func RunCommand(command string) (string, error) {
 		log.Warnf("[RunCommand] Could not get current working directory: %v", absErr)
 	}
 
-	cmd := exec.Command("bash", "-c", command)
-	output, err := cmd.CombinedOutput()
+	name, args, env := "bash", []string{"-c", command}, CommandEnv(ctx)
+	sandbox := SandboxFrom(ctx)
+	if sandbox != nil {
+		if err := sandbox.CheckCommand(command); err != nil {
+			return "", err
+		}
+		env = sandbox.Environ(env)
+		name, args = sandbox.Command(command, env)
+		absPath = sandbox.Workdir()
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	if sandbox != nil {
+		cmd.Dir = sandbox.Workdir()
+	}
+	// Don't wait on children of a killed shell that keep its output open.
+	cmd.WaitDelay = time.Second
+	started := time.Now()
+	output, err := cleanup.From(ctx).CombinedOutput(cmd)
+	recordUsage(ctx, command, time.Since(started), cmd.ProcessState)
 	if err != nil {
 		log.Errorf("Failed to run command: %s, output: %s, err: %v", command, string(output), err)
-		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to run command: %s (cwd=%s)", command, absPath), err)
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to run command: %s (cwd=%s)", command, absPath), &OutputError{Output: string(output), Err: err})
 	}
 	log.Infof("Finished RunCommand: %s", command)
 	return string(output), nil
 }
 
+// OutputError is the error of a command that failed, with what it printed.
+type OutputError struct {
+	Output string // Combined stdout and stderr
+	Err    error
+}
+
+func (e *OutputError) Error() string { return e.Err.Error() }
+
+func (e *OutputError) Unwrap() error { return e.Err }
+
+// ErrorOutput returns what the failed command behind err printed, or "".
+func ErrorOutput(err error) string {
+	var outErr *OutputError
+	if stderrors.As(err, &outErr) {
+		return outErr.Output
+	}
+	return ""
+}
+
 // ApplyPatch applies a patch to a file.
 func ApplyPatch(filePath string, patchContent string) (string, error) {
+	return ApplyPatchContext(context.Background(), filePath, patchContent)
+}
+
+// ApplyPatchContext is ApplyPatch with the patch command killed when ctx is
+// done. The temporary patch file is tracked by the cleanup tracker of ctx.
+func ApplyPatchContext(ctx context.Context, filePath string, patchContent string) (string, error) {
 	log := logrus.WithFields(logrus.Fields{
 		"tool":      "ApplyPatch",
 		"filePath":  filePath,
@@ -555,12 +920,13 @@ func ApplyPatch(filePath string, patchContent string) (string, error) {
 		log.Warnf("[ApplyPatch] Could not get current working directory: %v", absErr)
 	}
 	// Create a temporary patch file
-	tmpPatchFile, err := os.CreateTemp("", "patch-*.patch")
+	temps := cleanup.From(ctx)
+	tmpPatchFile, err := temps.CreateTemp("", "patch-*.patch")
 	if err != nil {
 		log.Errorf("Failed to create temporary patch file: %v", err)
 		return "", errors.New(errors.ErrCodeTool, "failed to create temporary patch file", err)
 	}
-	defer os.Remove(tmpPatchFile.Name()) // Clean up the temporary file
+	defer temps.Release(tmpPatchFile.Name()) // Clean up the temporary file
 
 	_, err = tmpPatchFile.WriteString(patchContent)
 	if err != nil {
@@ -570,11 +936,11 @@ func ApplyPatch(filePath string, patchContent string) (string, error) {
 	tmpPatchFile.Close()
 
 	// Apply the patch using the 'patch' command
-	cmd := exec.Command("patch", filePath, tmpPatchFile.Name())
-	output, err := cmd.CombinedOutput()
+	cmd := exec.CommandContext(ctx, "patch", filePath, tmpPatchFile.Name())
+	output, err := temps.CombinedOutput(cmd)
 	if err != nil {
 		log.Errorf("Failed to apply patch to %s, output: %s, err: %v", filePath, string(output), err)
-		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to apply patch to %s (cwd=%s)", filePath, absPath), err)
+		return "", errors.New(errors.ErrCodeTool, fmt.Sprintf("failed to apply patch to %s (cwd=%s)", filePath, absPath), &OutputError{Output: string(output), Err: err})
 	}
 
 	log.Infof("Successfully applied patch to %s:\n%s", filePath, string(output))
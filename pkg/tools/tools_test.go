@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"context"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -48,6 +50,12 @@ func TestRunCommand_Fail(t *testing.T) {
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
+	if out := ErrorOutput(err); !strings.Contains(out, "nonexistentcommand1234") {
+		t.Errorf("expected the shell's complaint in the error output, got %q", out)
+	}
+	if ErrorOutput(os.ErrNotExist) != "" {
+		t.Error("expected no output for other errors")
+	}
 }
 
 func TestApplyPatch_Fail(t *testing.T) {
@@ -108,3 +116,43 @@ func TestReadFile_Fail(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestReadFileTool_Execute(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/test.txt"
+	os.WriteFile(file, []byte("hello"), 0644)
+	tool := &ReadFileTool{}
+	for _, args := range []map[string]interface{}{{"file_path": file}, {"filePath": file}} {
+		if out, err := tool.Execute(context.Background(), args); err != nil || out != "hello" {
+			t.Errorf("args %v: expected the file content, got %v, %v", args, out, err)
+		}
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": ""}); err == nil {
+		t.Error("expected an error for an empty path")
+	}
+	_, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": dir})
+	if err == nil || !strings.Contains(err.Error(), "list_dir") {
+		t.Errorf("expected a directory to be rejected with a list_dir hint, got %v", err)
+	}
+}
+
+func TestListDirTool_Execute(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(dir+"/a.txt", []byte("a"), 0644)
+	tool := &ListDirTool{}
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"directory": dir})
+	if names, _ := out.([]string); err != nil || len(names) != 1 || names[0] != "a.txt" {
+		t.Errorf("expected the directory listed, got %v, %v", out, err)
+	}
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	out, err = tool.Execute(context.Background(), map[string]interface{}{})
+	if names, _ := out.([]string); err != nil || len(names) != 1 {
+		t.Errorf("expected the current directory listed without a path, got %v, %v", out, err)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"path": 3}); err == nil {
+		t.Error("expected an error for a non-string path")
+	}
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"ai-team/pkg/types"
+)
+
+// UsageRecorder collects the resource use of the commands tools run with a
+// context carrying it.
+type UsageRecorder struct {
+	mu     sync.Mutex
+	usages []types.CommandUsage
+}
+
+// Record adds the usage of one command.
+func (r *UsageRecorder) Record(usage types.CommandUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usages = append(r.usages, usage)
+}
+
+// Usages returns the recorded usages in the order the commands finished.
+func (r *UsageRecorder) Usages() []types.CommandUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]types.CommandUsage(nil), r.usages...)
+}
+
+type usageKey struct{}
+
+// WithUsageRecorder returns ctx carrying r for the tools run with it.
+func WithUsageRecorder(ctx context.Context, r *UsageRecorder) context.Context {
+	return context.WithValue(ctx, usageKey{}, r)
+}
+
+// recordUsage records the usage of a finished command with the recorder
+// carried by ctx, if any.
+func recordUsage(ctx context.Context, command string, wall time.Duration, state *os.ProcessState) {
+	r, _ := ctx.Value(usageKey{}).(*UsageRecorder)
+	if r == nil || state == nil {
+		return
+	}
+	r.Record(commandUsage(command, wall, state))
+}
+
+// commandUsage reads the CPU times and peak RSS of a finished process from
+// its rusage.
+func commandUsage(command string, wall time.Duration, state *os.ProcessState) types.CommandUsage {
+	usage := types.CommandUsage{
+		Command:   command,
+		Wall:      wall,
+		UserCPU:   state.UserTime(),
+		SystemCPU: state.SystemTime(),
+		ExitCode:  state.ExitCode(),
+	}
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage.PeakRSS = int64(rusage.Maxrss)
+		if runtime.GOOS != "darwin" { // Linux and the BSDs report KiB, macOS bytes
+			usage.PeakRSS *= 1024
+		}
+	}
+	return usage
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+func TestRunCommandContext_RecordsUsage(t *testing.T) {
+	usage := &UsageRecorder{}
+	ctx := WithUsageRecorder(context.Background(), usage)
+	if _, err := RunCommandContext(ctx, "echo hi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	RunCommandContext(ctx, "exit 3")
+
+	got := usage.Usages()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 usages, got %+v", got)
+	}
+	if got[0].Command != "echo hi" || got[0].Wall <= 0 || got[0].ExitCode != 0 {
+		t.Errorf("unexpected usage %+v", got[0])
+	}
+	if runtime.GOOS == "linux" && got[0].PeakRSS < 1024 {
+		t.Errorf("expected the peak RSS in bytes, got %d", got[0].PeakRSS)
+	}
+	if got[1].ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v", got[1])
+	}
+}
+
+func TestRunCommandContext_NoRecorder(t *testing.T) {
+	if _, err := RunCommandContext(context.Background(), "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -14,6 +14,23 @@ func TestValidateToolCall_ListDirSnakeCase(t *testing.T) {
 	}
 }
 
+func TestValidateToolCall_ReadFileVariants(t *testing.T) {
+	reg := NewToolRegistry()
+	RegisterDefaultTools(reg)
+
+	for _, name := range []string{"read_file", "ReadFile"} {
+		if err := reg.ValidateToolCall(ToolCall{Name: name, Arguments: map[string]interface{}{"file_path": "main.go"}}); err != nil {
+			t.Fatalf("expected valid %s call, got error: %v", name, err)
+		}
+	}
+	if err := reg.ValidateToolCall(ToolCall{Name: "read_file", Arguments: map[string]interface{}{}}); err == nil {
+		t.Fatal("expected error for missing file_path, got nil")
+	}
+	if err := reg.ValidateToolCall(ToolCall{Name: "list_dir", Arguments: map[string]interface{}{"recursive": "yes"}}); err == nil {
+		t.Fatal("expected error for a non-bool recursive, got nil")
+	}
+}
+
 func TestValidateToolCall_WriteFileVariants(t *testing.T) {
 	reg := NewToolRegistry()
 	RegisterDefaultTools(reg)
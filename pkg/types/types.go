@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // OpenAIResponse represents the JSON response from the OpenAI API.
 type OpenAIResponse struct {
@@ -11,17 +17,47 @@ type OpenAIResponse struct {
 
 // GeminiRequest represents the request body for Gemini API.
 type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
+	Contents          []GeminiContent         `json:"contents"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiGenerationConfig holds the sampling settings of a Gemini request.
+type GeminiGenerationConfig struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+}
+
+// GeminiTool offers functions the model may call instead of answering in text.
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration describes a function and its parameters, an
+// OpenAPI schema of type OBJECT.
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
 }
 
 // GeminiContent represents a content block for Gemini API.
 type GeminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" or "model"; set in multi-turn requests
 	Parts []GeminiPart `json:"parts"`
 }
 
 // GeminiPart represents a part of the content for Gemini API.
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text         string              `json:"text"`
+	FunctionCall *GeminiFunctionCall `json:"functionCall,omitempty"` // Set in responses to requests with tools
+}
+
+// GeminiFunctionCall is a call of a declared function by the model.
+type GeminiFunctionCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
 }
 
 // GeminiResponse represents the JSON response from the Gemini API.
@@ -45,6 +81,14 @@ type OllamaRequest struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"messages"`
+	Stream  bool           `json:"stream,omitempty"`
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions holds the sampling settings of an Ollama request.
+type OllamaOptions struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"` // Maximum tokens to generate
 }
 
 // GeminiModelListResponse represents the JSON response from the Gemini models API.
@@ -65,11 +109,74 @@ type ToolCallRequest struct {
 	ToolCall ToolCall `json:"tool_call"`
 }
 
-// ToolArgument represents an argument for a configurable tool.
+// ToolArgument represents an argument for a configurable tool. Besides its
+// type, it may constrain values like a JSON Schema: enum, minimum/maximum,
+// the items of an array or the properties of an object.
 type ToolArgument struct {
-	Name        string `mapstructure:"name"`
-	Type        string `mapstructure:"type"`
-	Description string `mapstructure:"description"`
+	Name        string                 `mapstructure:"name"`
+	Type        string                 `mapstructure:"type"`
+	Description string                 `mapstructure:"description"`
+	Required    bool                   `mapstructure:"required"`
+	Enum        []interface{}          `mapstructure:"enum"`
+	Default     interface{}            `mapstructure:"default"` // Used when the call does not set the argument
+	Minimum     *float64               `mapstructure:"minimum"`
+	Maximum     *float64               `mapstructure:"maximum"`
+	Items       *JSONSchema            `mapstructure:"items"`      // Elements of an array argument
+	Properties  map[string]*JSONSchema `mapstructure:"properties"` // Fields of an object argument
+}
+
+// JSONSchema is the subset of JSON Schema that describes tool arguments.
+type JSONSchema struct {
+	Type        string                 `json:"type,omitempty" mapstructure:"type"` // string, integer, number, boolean, array or object; empty allows any value
+	Description string                 `json:"description,omitempty" mapstructure:"description"`
+	Enum        []interface{}          `json:"enum,omitempty" mapstructure:"enum"`
+	Default     interface{}            `json:"default,omitempty" mapstructure:"default"`
+	Minimum     *float64               `json:"minimum,omitempty" mapstructure:"minimum"`
+	Maximum     *float64               `json:"maximum,omitempty" mapstructure:"maximum"`
+	MinLength   *int                   `json:"minLength,omitempty" mapstructure:"min_length"`
+	MaxLength   *int                   `json:"maxLength,omitempty" mapstructure:"max_length"`
+	MinItems    *int                   `json:"minItems,omitempty" mapstructure:"min_items"`
+	MaxItems    *int                   `json:"maxItems,omitempty" mapstructure:"max_items"`
+	Items       *JSONSchema            `json:"items,omitempty" mapstructure:"items"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty" mapstructure:"properties"`
+	Required    []string               `json:"required,omitempty" mapstructure:"required"`
+	Nullable    bool                   `json:"nullable,omitempty" mapstructure:"nullable"` // null is accepted too
+}
+
+// UnmarshalJSON reads a schema whose type may also be a list such as
+// ["integer", "null"]: the first type other than null, with Nullable set
+// when null is listed.
+func (s *JSONSchema) UnmarshalJSON(data []byte) error {
+	type plain JSONSchema
+	var raw struct {
+		plain
+		Type json.RawMessage `json:"type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = JSONSchema(raw.plain)
+	s.Type = ""
+	if len(raw.Type) == 0 {
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(raw.Type, &name); err == nil {
+		s.Type = name
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(raw.Type, &names); err != nil {
+		return fmt.Errorf("invalid JSON Schema type %s", raw.Type)
+	}
+	for _, name := range names {
+		if name == "null" {
+			s.Nullable = true
+		} else if s.Type == "" {
+			s.Type = name
+		}
+	}
+	return nil
 }
 
 // ConfigurableTool represents a tool defined in the configuration.
@@ -77,14 +184,120 @@ type ConfigurableTool struct {
 	Name            string         `mapstructure:"name"`
 	Description     string         `mapstructure:"description"`
 	CommandTemplate string         `mapstructure:"command_template"`
+	Endpoint        string         `mapstructure:"endpoint"` // URL the arguments are POSTed to as JSON, instead of running command_template
 	Arguments       []ToolArgument `mapstructure:"arguments"`
+	PostProcess     *PostProcess   `mapstructure:"post_process"` // Optional: trims the command output before it reaches the model
+}
+
+// PostProcess trims a tool's text result to the useful part. The steps run in
+// the order regex, json, head; unset steps are skipped.
+type PostProcess struct {
+	Regex string `mapstructure:"regex"` // Keep only the matches, one per line; the first capture group when the pattern has one
+	JSON  string `mapstructure:"json"`  // Parse the result as JSON and keep the value at this dotted path ("." keeps all of it)
+	Head  int    `mapstructure:"head"`  // Keep the first N lines
 }
 
+// PostProcessors maps tool names to the post-processor of their results.
+type PostProcessors map[string]PostProcess
+
 // Role represents an AI role defined in the configuration.
 type Role struct {
-	Provider string `mapstructure:"model_provider"` // e.g., "openai", "gemini", "ollama"
-	Model    string `mapstructure:"model_name"`     // e.g., "gpt-4", "gemini-pro"
-	Prompt   string `mapstructure:"prompt"`
+	Provider string      `mapstructure:"model_provider"` // e.g., "openai", "gemini", "anthropic", "ollama"
+	Model    string      `mapstructure:"model_name"`     // e.g., "gpt-4", "gemini-pro"
+	Prompt   string      `mapstructure:"prompt"`
+	Inputs   []RoleInput `mapstructure:"inputs"` // Optional descriptions/defaults for prompt variables
+
+	// MaxContinuations is how many follow-up requests are sent when a response
+	// is cut off at the output token limit (0 = DefaultMaxContinuations, -1 = off).
+	MaxContinuations int `mapstructure:"max_continuations"`
+
+	// PromptCache lets providers cache the static start of the prompt (its
+	// leading text and tools section) across calls; see README "Prompt caching".
+	PromptCache bool `mapstructure:"prompt_cache"`
+
+	// From is the registry reference the role was loaded from, e.g.
+	// registry://community/reviewer@v1; keys set next to it override the
+	// registry's definition.
+	From string `mapstructure:"from"`
+
+	// Conversation keeps the role's messages and tool results across the
+	// iterations of a chain step or session and sends them with every call.
+	Conversation bool `mapstructure:"conversation"`
+
+	// Extraction is the order tool calls are extracted from the role's
+	// responses in, overriding the extraction section; see README
+	// "Tool-call extraction".
+	Extraction []string `mapstructure:"extraction"`
+
+	// FinalAnswer is the JSON Schema the answer of the role's final_answer
+	// calls must match; any answer is accepted when nil.
+	FinalAnswer *JSONSchema `mapstructure:"final_answer"`
+}
+
+// ExtractionConfig orders the strategies tool calls are extracted from model
+// responses with: provider_native, json_recursive, json_block, inline_json,
+// yaml and regex:<pattern>.
+type ExtractionConfig struct {
+	Strategies []string            `mapstructure:"strategies"` // Default order for all providers
+	Providers  map[string][]string `mapstructure:"providers"`  // Order per provider, e.g. ollama
+}
+
+// For returns the strategies of roles of provider that configure none, or
+// nil for the built-in order.
+func (c ExtractionConfig) For(provider string) []string {
+	if strategies, ok := c.Providers[provider]; ok && len(strategies) > 0 {
+		return strategies
+	}
+	return c.Strategies
+}
+
+// DefaultMaxContinuations applies to roles that do not set max_continuations.
+const DefaultMaxContinuations = 2
+
+// RoleInput declares a prompt variable of a role.
+type RoleInput struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Required    bool   `mapstructure:"required"`
+	Default     string `mapstructure:"default"`
+	Type        string `mapstructure:"type"`    // "string" (default), "int", "number" or "bool"
+	Example     string `mapstructure:"example"` // Sample value shown by role --help-inputs
+}
+
+// Role input types.
+const (
+	InputTypeString = "string"
+	InputTypeInt    = "int"
+	InputTypeNumber = "number"
+	InputTypeBool   = "bool"
+)
+
+// ParseValue converts a raw string to the input's declared type.
+func (in RoleInput) ParseValue(raw string) (interface{}, error) {
+	switch in.Type {
+	case "", InputTypeString:
+		return raw, nil
+	case InputTypeInt:
+		v, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("input '%s' must be an int, got %q", in.Name, raw)
+		}
+		return v, nil
+	case InputTypeNumber:
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("input '%s' must be a number, got %q", in.Name, raw)
+		}
+		return v, nil
+	case InputTypeBool:
+		v, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("input '%s' must be a bool, got %q", in.Name, raw)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("input '%s' has unknown type '%s'", in.Name, in.Type)
+	}
 }
 
 // ChainRole represents a role within a chain.
@@ -93,19 +306,477 @@ type ChainRole struct {
 	Role          string                 `mapstructure:"role"`
 	Input         map[string]interface{} `mapstructure:"input"`
 	OutputKey     string                 `mapstructure:"output_key"`
-	Loop          bool                   `mapstructure:"loop"`           // If true, loop this role
-	LoopCount     int                    `mapstructure:"loop_count"`     // Number of times to loop (if Loop is true)
-	LoopCondition string                 `mapstructure:"loop_condition"` // Optional: loop until a condition is met (Go template, evaluated after each iteration)
+	OutputMode    string                 `mapstructure:"output_mode"`          // "replace" (default) or "append" to collect each iteration's output in a list
+	Loop          bool                   `mapstructure:"loop"`                 // If true, loop this role
+	LoopCount     int                    `mapstructure:"loop_count"`           // Number of times to loop (if Loop is true)
+	LoopCondition string                 `mapstructure:"loop_condition"`       // Optional: loop until a condition is met (Go template, evaluated after each iteration)
+	LoopScript    string                 `mapstructure:"loop_script"`          // Optional: script run after each iteration; setting stop to a true value ends the loop
+	InputScript   string                 `mapstructure:"input_script"`         // Optional: script that may rewrite the rendered input before each iteration
+	OutputScript  string                 `mapstructure:"output_script"`        // Optional: script that may rewrite each iteration's output before it is stored
+	Before        []StepHook             `mapstructure:"before"`               // Hooks run before the step's first iteration
+	After         []StepHook             `mapstructure:"after"`                // Hooks run after the step's last iteration
+	OnError       string                 `mapstructure:"on_error"`             // Policy when a hook fails: "continue" (default), "skip", "fail", or a step to jump to
+	When          string                 `mapstructure:"when"`                 // Optional: run the step only if this condition holds (a template, like loop_condition)
+	Goto          string                 `mapstructure:"goto"`                 // Optional: step to continue with after this one (a template; empty goes on in order, "end" ends the chain)
+	ExpectedLoops int                    `mapstructure:"expected_loops"`       // Optional: iterations a loop_condition step usually needs, used by run-chain --estimate
+	Cache         bool                   `mapstructure:"cache"`                // Reuse the output of an earlier successful run when the prompt and input are unchanged
+	Timeout       time.Duration          `mapstructure:"timeout"`              // Optional: limit on the whole step, iterations and hooks included; on_error applies when exceeded
+	Documents     []string               `mapstructure:"documents"`            // Saved documents loaded into the documents input before each iteration, by name
+	StopOnFinal   *bool                  `mapstructure:"stop_on_final_answer"` // End the loop when the role calls final_answer (default true)
+	Checkpoint    string                 `mapstructure:"checkpoint"`           // Optional: save a named checkpoint after the step, which runs branch can start from
+	Retrieve      *RetrieveStep          `mapstructure:"retrieve"`             // Optional: put the repository chunks closest to a query into the prompt
+}
+
+// RetrieveStep makes a chain step look up the indexed repository chunks most
+// similar to a query before each iteration. They are given to the prompt as
+// the retrieved input, and appended to prompts that do not use it.
+type RetrieveStep struct {
+	Query string `mapstructure:"query"` // Template rendered against the chain context; defaults to the step's input
+	TopK  int    `mapstructure:"top_k"` // Chunks to retrieve (default retrieval.top_k)
+}
+
+// StopsOnFinalAnswer reports whether a final_answer call ends the step's loop.
+func (c ChainRole) StopsOnFinalAnswer() bool {
+	return c.StopOnFinal == nil || *c.StopOnFinal
+}
+
+// Output modes for ChainRole.OutputMode.
+const (
+	OutputModeReplace = "replace"
+	OutputModeAppend  = "append"
+)
+
+// Step error policies for ChainRole.OnError.
+const (
+	OnErrorContinue = "continue"
+	OnErrorSkip     = "skip"
+	OnErrorFail     = "fail"
+)
+
+// GotoEnd as the target of ChainRole.Goto or OnError ends the chain.
+const GotoEnd = "end"
+
+// Key returns the name the step is known by: its name, or its role when
+// unnamed.
+func (c ChainRole) Key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Role
+}
+
+// ErrorTarget returns the step OnError jumps to, or "" when OnError is a
+// policy.
+func (c ChainRole) ErrorTarget() string {
+	switch c.OnError {
+	case "", OnErrorContinue, OnErrorSkip, OnErrorFail:
+		return ""
+	}
+	return c.OnError
+}
+
+// StepIndex returns the index of the step with key name, len(Steps) for
+// GotoEnd, or false when no step has that key.
+func (c RoleChain) StepIndex(name string) (int, bool) {
+	if name == GotoEnd {
+		return len(c.Steps), true
+	}
+	for i, step := range c.Steps {
+		if step.Key() == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// StepHook is a shell command, registered tool or script run before or after a chain step.
+type StepHook struct {
+	Command   string                 `mapstructure:"command"`   // Shell command to run
+	Tool      string                 `mapstructure:"tool"`      // Registered tool name (e.g. run_command, write_file)
+	Arguments map[string]interface{} `mapstructure:"arguments"` // Tool arguments
+	Script    string                 `mapstructure:"script"`    // Name of a chain script, or inline script source
 }
 
 // RoleChain represents a chain of AI roles defined in the configuration.
 type RoleChain struct {
-	Steps []ChainRole `mapstructure:"steps"`
+	Vars      map[string]interface{} `mapstructure:"vars"`    // Constants available to every step's input templates; initial input overrides them
+	Scripts   map[string]string      `mapstructure:"scripts"` // Named hook scripts, referenced by hooks and steps
+	Steps     []ChainRole            `mapstructure:"steps"`
+	OnSuccess *ChainHook             `mapstructure:"on_success"` // Optional: invoked after the chain completes successfully
+	Quota     ToolQuota              `mapstructure:"quota"`      // Optional: per-run tool limits, overriding the global quota
+	Strict    *bool                  `mapstructure:"strict"`     // Optional: fail on template references to missing keys (default true)
+	Timeout   time.Duration          `mapstructure:"timeout"`    // Optional: deadline for the whole chain; the run fails when exceeded
+	Env       EnvConfig              `mapstructure:"env"`        // Optional: tool environment, merged over the global env
+	From      string                 `mapstructure:"from"`       // Optional: registry reference the chain was loaded from
+}
+
+// StrictTemplates reports whether the chain's templates fail on missing keys
+// instead of rendering "<no value>". Strict mode is on unless disabled.
+func (c RoleChain) StrictTemplates() bool {
+	return c.Strict == nil || *c.Strict
+}
+
+// ToolQuota limits tool usage within a single run. Zero values mean unlimited.
+type ToolQuota struct {
+	MaxToolCalls    int   `mapstructure:"max_tool_calls"`
+	MaxFilesWritten int   `mapstructure:"max_files_written"`
+	MaxBytesWritten int64 `mapstructure:"max_bytes_written"`
+	MaxCommands     int   `mapstructure:"max_commands"`
+}
+
+// DedupConfig controls skipping of tool calls identical to one already executed.
+type DedupConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	Scope       string   `mapstructure:"scope"`        // "run" (default) or "step"
+	AllowRepeat []string `mapstructure:"allow_repeat"` // Tool names that are always re-executed
+	Mutating    bool     `mapstructure:"mutating"`     // Also skip repeated writes, commands and other mutating calls
+
+	// Similar also skips calls whose arguments are nearly the same as an earlier
+	// call's, answering with a reminder of the earlier result.
+	Similar SimilarDedupConfig `mapstructure:"similar"`
+}
+
+// SimilarDedupConfig configures embeddings-based detection of near-duplicate tool calls.
+type SimilarDedupConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Threshold float64  `mapstructure:"threshold"` // Minimum cosine similarity, e.g. 0.95
+	Tools     []string `mapstructure:"tools"`     // Tools checked (default read_file, list_dir)
+}
+
+// WorkspaceLockConfig serializes the file writes of runs sharing a workspace.
+type WorkspaceLockConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Path    string        `mapstructure:"path"` // Lock file (default .ai-team/workspace.lock)
+	Wait    time.Duration `mapstructure:"wait"` // How long a write waits for another run's write; 0 fails at once
+}
+
+// RetryConfig controls how provider requests that were throttled or failed
+// are sent again.
+type RetryConfig struct {
+	MaxAttempts  int            `mapstructure:"max_attempts"`  // Attempts per request, the first included (0 or 1 disables retries)
+	InitialDelay time.Duration  `mapstructure:"initial_delay"` // Wait before the first retry, doubled for each further one
+	MaxDelay     time.Duration  `mapstructure:"max_delay"`     // Longest wait; a longer Retry-After is not waited for
+	RateLimits   map[string]int `mapstructure:"rate_limits"`   // Optional: requests per minute, by provider
+}
+
+// HeartbeatConfig reports the progress of long-running chain steps.
+type HeartbeatConfig struct {
+	After      time.Duration `mapstructure:"after"`       // Steps running longer than this emit heartbeats (0 disables)
+	Interval   time.Duration `mapstructure:"interval"`    // Time between heartbeats
+	StallAfter time.Duration `mapstructure:"stall_after"` // A single model call, tool call or hook phase lasting this long is reported as a stall (0 = never)
+	Webhook    string        `mapstructure:"webhook"`     // Optional: URL receiving a JSON POST for each stall
+}
+
+// Step log output modes of LoggingConfig.StepOutput.
+const (
+	StepLogPrefix = "prefix" // Write each line as it comes, prefixed with the step
+	StepLogBuffer = "buffer" // Hold a step's lines and write them together when it ends
+	StepLogFiles  = "files"  // Write each step's lines to its own file
+)
+
+// LoggingConfig controls how the log lines of chain steps are written, so
+// steps running at the same time stay readable.
+type LoggingConfig struct {
+	StepOutput string `mapstructure:"step_output"` // StepLogPrefix (default), StepLogBuffer or StepLogFiles
+	Dir        string `mapstructure:"dir"`         // Directory of step log files, one subdirectory per run
+}
+
+// MCPServerConfig connects to a Model Context Protocol server whose tools are
+// offered alongside the built-in ones. Set Command for a server started as a
+// child process speaking over stdio, or URL for one reached over HTTP with
+// server-sent events.
+type MCPServerConfig struct {
+	Command string            `mapstructure:"command"` // stdio: program to start
+	Args    []string          `mapstructure:"args"`
+	Env     map[string]string `mapstructure:"env"`     // stdio: added to the program's environment
+	URL     string            `mapstructure:"url"`     // sse: the server's event stream endpoint
+	Headers map[string]string `mapstructure:"headers"` // sse: sent with every request, e.g. Authorization
+	Prefix  string            `mapstructure:"prefix"`  // Prepended to the tool names (default "<server>_")
+	Tools   []string          `mapstructure:"tools"`   // Register only these tools of the server (default all)
+	Timeout time.Duration     `mapstructure:"timeout"` // Per request (default 30s)
+}
+
+// MCPServers maps server names to their connection settings.
+type MCPServers map[string]MCPServerConfig
+
+// NotifyConfig announces finished chains and tool calls waiting for approval.
+type NotifyConfig struct {
+	OnComplete string        `mapstructure:"on_complete"` // off (default), bell or desktop
+	After      time.Duration `mapstructure:"after"`       // Chains finishing sooner than this are not announced
+}
+
+// ThemeConfig sets the colors of terminal output.
+type ThemeConfig struct {
+	Mode   string            `mapstructure:"mode"`   // auto (default), dark, light or none
+	Colors map[string]string `mapstructure:"colors"` // Overrides by element, e.g. diff_added: "bold green"
+}
+
+// JournalConfig configures the write-ahead journal of file modifications.
+type JournalConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // Journal file (default .ai-team/journal.jsonl)
+}
+
+// AutoApproveConfig bounds what an interactive session may do on its own when
+// run with --yes.
+type AutoApproveConfig struct {
+	MaxDestructive int      `mapstructure:"max_destructive"` // Auto-approved write/patch/command calls per session (0 = unlimited)
+	Snapshot       bool     `mapstructure:"snapshot"`        // Take a git snapshot before the first auto-approved destructive call
+	RefuseCommands []string `mapstructure:"refuse_commands"` // Regexes of commands that always need manual approval
+}
+
+// MaxIterationsConfig sets what an interactive session does when it reaches
+// --max-iterations with a tool call still pending.
+type MaxIterationsConfig struct {
+	Action     string `mapstructure:"action"`     // MaxIterationsAsk (default), MaxIterationsSummarize, MaxIterationsCheckpoint or MaxIterationsStop
+	ExtendBy   int    `mapstructure:"extend_by"`  // Iterations added each time the user extends the session (default 10)
+	Checkpoint string `mapstructure:"checkpoint"` // File the checkpoint is saved to; {run_id} is replaced by the session's run ID
+}
+
+// Actions of MaxIterationsConfig.
+const (
+	MaxIterationsAsk        = "ask"        // Ask whether to extend, summarize, save a checkpoint or stop
+	MaxIterationsSummarize  = "summarize"  // Ask the model to summarize its progress, then stop
+	MaxIterationsCheckpoint = "checkpoint" // Save the session with its pending tool call for --resume, then stop
+	MaxIterationsStop       = "stop"
+)
+
+// HTTPRequestConfig limits the http_request tool.
+type HTTPRequestConfig struct {
+	AllowedDomains []string      `mapstructure:"allowed_domains"` // Hosts requests may go to; *.example.com matches its subdomains. None refuses every request
+	MaxBytes       int64         `mapstructure:"max_bytes"`       // Response bodies are cut after this many bytes (default 1 MiB)
+	Timeout        time.Duration `mapstructure:"timeout"`         // Limit on each request (default 30s)
+}
+
+// SandboxConfig restricts the commands run_command may run. Allow and deny
+// patterns are checked against each part of a compound command.
+type SandboxConfig struct {
+	Enabled   bool             `mapstructure:"enabled"`
+	Allow     []string         `mapstructure:"allow"`     // Regexes; when set, every part of a command must match one
+	Deny      []string         `mapstructure:"deny"`      // Regexes of commands that are refused
+	Workdir   string           `mapstructure:"workdir"`   // Directory commands run in and may not reference paths outside of (default: the working directory)
+	ScrubEnv  []string         `mapstructure:"scrub_env"` // Globs of variable names removed from the environment of commands, e.g. *_TOKEN
+	Container SandboxContainer `mapstructure:"container"` // Run commands in a container
+	Chroot    string           `mapstructure:"chroot"`    // Run commands with this directory as root (needs root privileges)
+}
+
+// SandboxContainer runs sandboxed commands in a container with the workdir
+// mounted at the same path.
+type SandboxContainer struct {
+	Image   string   `mapstructure:"image"`   // Image to run commands in; empty runs them on the host
+	Runtime string   `mapstructure:"runtime"` // Container CLI: docker (default) or podman
+	Args    []string `mapstructure:"args"`    // Extra arguments of "run", e.g. --network=none
+}
+
+// GuardrailConfig names a reviewer role consulted before destructive tool
+// calls of unattended runs: chains and interactive sessions with --yes.
+type GuardrailConfig struct {
+	Role     string `mapstructure:"role"`      // Reviewer role; empty disables the guardrail
+	AuditLog string `mapstructure:"audit_log"` // JSONL file of the decisions (default .ai-team/audit.jsonl)
+}
+
+// ChangelogConfig sets up the change summary written to the record of each
+// chain run that changed files.
+type ChangelogConfig struct {
+	Role    string `mapstructure:"role"`     // Summarizer role; empty records the diffstat only
+	MaxDiff int    `mapstructure:"max_diff"` // Characters of the run's diffs given to the role (default 20000)
+}
+
+// MemoryConfig sets up the facts the remember and recall tools keep across
+// runs.
+type MemoryConfig struct {
+	Path     string   `mapstructure:"path"`      // File the facts are kept in (default .ai-team/memory.json)
+	Load     int      `mapstructure:"load"`      // Facts put in the memory key of every chain's context, most recent first; 0 loads none
+	Tags     []string `mapstructure:"tags"`      // When set, only facts with one of these tags are loaded
+	MaxFacts int      `mapstructure:"max_facts"` // Facts kept before the least recently updated are forgotten (default 1000)
+}
+
+// RetrievalConfig sets up the embeddings index of repository files that
+// chain steps with retrieve search.
+type RetrievalConfig struct {
+	Provider    string   `mapstructure:"provider"`      // Embeddings provider: openai (default), gemini or ollama
+	Model       string   `mapstructure:"model"`         // Embedding model; defaults to one of the provider
+	Index       string   `mapstructure:"index"`         // File the index is kept in (default .ai-team/index.json)
+	Paths       []string `mapstructure:"paths"`         // Globs of the files to index; empty indexes all files not ignored
+	Exclude     []string `mapstructure:"exclude"`       // Globs of files and directories to leave out
+	ChunkLines  int      `mapstructure:"chunk_lines"`   // Lines per embedded chunk (default 60)
+	MaxFileSize int64    `mapstructure:"max_file_size"` // Larger files are not indexed (default 100000 bytes)
+	TopK        int      `mapstructure:"top_k"`         // Chunks retrieved by steps that set no top_k (default 5)
+}
+
+// GuardrailDecision is the answer of the guardrail role about one tool call.
+type GuardrailDecision struct {
+	Time      time.Time              `json:"time"`
+	RunID     string                 `json:"run_id,omitempty"`
+	Step      string                 `json:"step,omitempty"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Role      string                 `json:"role"`     // The guardrail role
+	Decision  string                 `json:"decision"` // approve or deny
+	Reason    string                 `json:"reason"`
+}
+
+// AttributionConfig sends cost attribution metadata to an API gateway such as
+// LiteLLM. Headers and BodyFields map a header name or JSON body field
+// (dotted for nesting, e.g. "metadata.run_id") to one of the attributes
+// "user", "team" or "run_id".
+type AttributionConfig struct {
+	User       string            `mapstructure:"user"`
+	Team       string            `mapstructure:"team"`
+	Headers    map[string]string `mapstructure:"headers"`
+	BodyFields map[string]string `mapstructure:"body_fields"`
+	CostHeader string            `mapstructure:"cost_header"` // Response header with the call's cost (default x-litellm-response-cost)
+}
+
+// Attribution attribute names.
+var AttributionAttributes = []string{"user", "team", "run_id"}
+
+// PricingConfig prices provider calls from their token counts.
+type PricingConfig struct {
+	Currency string                `mapstructure:"currency"` // Shown with costs (default USD)
+	Models   map[string]ModelPrice `mapstructure:"models"`   // Keyed by "provider/model", e.g. "openai/gpt-4"
+}
+
+// ModelPrice is the price of a model in currency units per million tokens.
+type ModelPrice struct {
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
+}
+
+// Cost returns the price of input and output tokens.
+func (p ModelPrice) Cost(input, output int) float64 {
+	return (float64(input)*p.Input + float64(output)*p.Output) / 1e6
+}
+
+// CommandUsage is the resource use of a command run by a tool.
+type CommandUsage struct {
+	Command   string        `json:"command"`
+	Wall      time.Duration `json:"wall_ns"`
+	UserCPU   time.Duration `json:"user_cpu_ns"`
+	SystemCPU time.Duration `json:"system_cpu_ns"`
+	PeakRSS   int64         `json:"peak_rss_bytes,omitempty"` // Largest resident set of the command or its children; 0 where unknown
+	ExitCode  int           `json:"exit_code"`                // -1 when the command was killed by a signal
+}
+
+// CPU returns the user and system CPU time of the command.
+func (u CommandUsage) CPU() time.Duration {
+	return u.UserCPU + u.SystemCPU
+}
+
+// CostSummary is the token use and cost of a set of provider calls.
+type CostSummary struct {
+	Currency     string  `json:"currency,omitempty"`
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	Complete     bool    `json:"complete"` // False when some calls had neither a price nor a gateway-reported cost
+}
+
+// Add adds the counts and cost of o to s.
+func (s *CostSummary) Add(o CostSummary) {
+	s.Calls += o.Calls
+	s.InputTokens += o.InputTokens
+	s.OutputTokens += o.OutputTokens
+	s.Cost += o.Cost
+	s.Complete = s.Complete && o.Complete
+}
+
+// String formats the summary for display, e.g. "3 calls, 1200 in / 300 out tokens, 0.0420 USD".
+func (s CostSummary) String() string {
+	out := fmt.Sprintf("%d calls, %d in / %d out tokens", s.Calls, s.InputTokens, s.OutputTokens)
+	switch {
+	case s.Complete:
+		out += fmt.Sprintf(", %.4f %s", s.Cost, s.Currency)
+	case s.Cost > 0:
+		out += fmt.Sprintf(", at least %.4f %s (some models have no price)", s.Cost, s.Currency)
+	default:
+		out += ", cost unknown (no pricing configured)"
+	}
+	return out
+}
+
+// ResponseLimits bounds how much of a provider response is held in memory.
+type ResponseLimits struct {
+	MemoryBytes int64  `mapstructure:"memory_bytes"` // Bytes buffered in memory before spilling to disk (0 = default)
+	MaxBytes    int64  `mapstructure:"max_bytes"`    // Larger responses are rejected (0 = default)
+	SpillDir    string `mapstructure:"spill_dir"`    // Directory for spill files (default: system temp dir)
+}
+
+// SimulationConfig replaces selected tools with scripted results so chains can
+// be exercised against a pretend environment.
+type SimulationConfig struct {
+	Enabled bool                     `mapstructure:"enabled"`
+	Tools   map[string][]ToolFixture `mapstructure:"tools"` // Fixtures per tool name; unlisted tools run normally
+	// DryRun runs no tool: each call gets the result its Results mode says.
+	DryRun        bool              `mapstructure:"dry_run"`
+	Results       map[string]string `mapstructure:"results"`        // Dry-run result mode per tool name
+	DefaultResult string            `mapstructure:"default_result"` // Mode of tools neither in Results nor with fixtures (default skipped)
+}
+
+// Dry-run result modes of SimulationConfig.Results.
+const (
+	SimulateSkipped = "skipped" // A marker saying the call did not run
+	SimulateEcho    = "echo"    // The call's arguments
+	SimulateFixture = "fixture" // The tool's fixtures, as with Enabled
+	SimulateRun     = "run"     // Execute the tool, e.g. one that only reads
+)
+
+// ToolFixture is a scripted result for a simulated tool. Fixtures are tried in
+// order; the first whose Match patterns all match the call arguments is used.
+type ToolFixture struct {
+	Match  map[string]string `mapstructure:"match"`  // Argument name -> regular expression
+	Result interface{}       `mapstructure:"result"` // Value returned to the chain
+	Error  string            `mapstructure:"error"`  // If set, the call fails with this message
+	Times  int               `mapstructure:"times"`  // Max uses (0 = unlimited)
+}
+
+// Merge returns q with any non-zero limits from override applied.
+func (q ToolQuota) Merge(override ToolQuota) ToolQuota {
+	if override.MaxToolCalls != 0 {
+		q.MaxToolCalls = override.MaxToolCalls
+	}
+	if override.MaxFilesWritten != 0 {
+		q.MaxFilesWritten = override.MaxFilesWritten
+	}
+	if override.MaxBytesWritten != 0 {
+		q.MaxBytesWritten = override.MaxBytesWritten
+	}
+	if override.MaxCommands != 0 {
+		q.MaxCommands = override.MaxCommands
+	}
+	return q
+}
+
+// EnvConfig sets the environment of commands run by tools and hooks.
+type EnvConfig struct {
+	Inherit *bool    `mapstructure:"inherit"` // Pass the parent environment on (default true); when false only PATH, HOME and Vars are set
+	Vars    []string `mapstructure:"vars"`    // NAME=value entries; $VAR and ${VAR} in values are expanded from the parent environment
+}
+
+// Merge returns e with override applied: override's Inherit when set, and its
+// Vars after e's so they take precedence.
+func (e EnvConfig) Merge(override EnvConfig) EnvConfig {
+	if override.Inherit != nil {
+		e.Inherit = override.Inherit
+	}
+	e.Vars = append(append([]string{}, e.Vars...), override.Vars...)
+	return e
+}
+
+// ChainHook runs a role or a shell command with the run manifest (files changed,
+// commands run) after a chain finishes, e.g. to draft a commit message or PR description.
+type ChainHook struct {
+	Role       string `mapstructure:"role"`        // Role invoked with {{.manifest}} / {{.manifest_json}} plus the chain context
+	Command    string `mapstructure:"command"`     // Shell command; the manifest JSON is on stdin and its path in AI_TEAM_MANIFEST
+	OutputKey  string `mapstructure:"output_key"`  // Context key for the hook output (default "on_success_output")
+	OutputFile string `mapstructure:"output_file"` // Optional file the hook output is written to; {run_id} is replaced by the run ID
 }
 
 // RoleCallLogEntry represents a log entry for a single role call.
 type RoleCallLogEntry struct {
 	Timestamp string                 `json:"timestamp"`
+	RunID     string                 `json:"run_id,omitempty"`
 	RoleName  string                 `json:"role_name"`
 	Input     map[string]interface{} `json:"input"`
 	Output    string                 `json:"output"`
@@ -114,9 +785,96 @@ type RoleCallLogEntry struct {
 
 // Transcript represents a session transcript.
 type Transcript struct {
-	Role      string    `json:"role"`
-	StartedAt time.Time `json:"started_at"`
-	Steps     []Step    `json:"steps"`
+	RunID     string       `json:"run_id,omitempty"`
+	Role      string       `json:"role"`
+	ToolsHash string       `json:"tools_hash,omitempty"` // Hash of the tool definitions available to the session
+	StartedAt time.Time    `json:"started_at"`
+	Steps     []Step       `json:"steps"`
+	Cost      *CostSummary `json:"cost,omitempty"` // Provider usage of the session
+
+	// Conversation is the message history of roles with conversation: true.
+	Conversation []Message `json:"conversation,omitempty"`
+}
+
+// ChainTranscript records the decisions of an interactive chain run.
+type ChainTranscript struct {
+	RunID     string       `json:"run_id,omitempty"`
+	Chain     string       `json:"chain"`
+	StartedAt time.Time    `json:"started_at"`
+	Events    []ChainEvent `json:"events"`
+	Aborted   bool         `json:"aborted,omitempty"` // The run was stopped by the user
+}
+
+// Kinds of ChainEvent.
+const (
+	ChainEventContext  = "context"   // The context was edited before a step
+	ChainEventInput    = "input"     // The resolved input of a step was edited
+	ChainEventSkipStep = "skip_step" // A step was skipped
+	ChainEventToolCall = "tool_call" // A tool call was approved, edited or skipped
+	ChainEventOutput   = "output"    // A step iteration produced output
+	ChainEventAbort    = "abort"     // The run was aborted
+)
+
+// Decisions on the tool calls of ChainEvent.
+const (
+	ChainDecisionApprove = "approve"
+	ChainDecisionEdit    = "edit" // Edited, then approved
+	ChainDecisionSkip    = "skip"
+)
+
+// ChainEvent is one entry of a ChainTranscript.
+type ChainEvent struct {
+	Step      string                 `json:"step"`
+	Kind      string                 `json:"kind"`
+	Iteration int                    `json:"iteration,omitempty"`
+	ToolCall  *ToolCall              `json:"tool_call,omitempty"`
+	Decision  string                 `json:"decision,omitempty"`
+	Output    interface{}            `json:"output,omitempty"`
+	Context   map[string]interface{} `json:"context,omitempty"` // Changed keys and their new values; removed keys are null
+	Input     map[string]interface{} `json:"input,omitempty"`   // Likewise, for the step input
+}
+
+// Message roles of a Conversation.
+const (
+	MessageSystem    = "system"
+	MessageUser      = "user"
+	MessageAssistant = "assistant"
+	MessageTool      = "tool"
+)
+
+// Message is one message of a Conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Name    string `json:"name,omitempty"` // Tool whose result a tool message holds
+}
+
+// Conversation is the message history of a role across iterations. Methods
+// are no-ops on a nil Conversation.
+type Conversation struct {
+	Messages []Message `json:"messages"`
+}
+
+// Add appends a message.
+func (c *Conversation) Add(role, content string) {
+	if c != nil {
+		c.Messages = append(c.Messages, Message{Role: role, Content: content})
+	}
+}
+
+// AddToolResult appends the result of the named tool.
+func (c *Conversation) AddToolResult(name, content string) {
+	if c != nil {
+		c.Messages = append(c.Messages, Message{Role: MessageTool, Content: content, Name: name})
+	}
+}
+
+// History returns the messages so far, or nil.
+func (c *Conversation) History() []Message {
+	if c == nil {
+		return nil
+	}
+	return c.Messages
 }
 
 // Step represents a single step in a transcript.
@@ -0,0 +1,275 @@
+// Package usage accounts for the tokens and cost of provider calls. Calls are
+// summed per provider/model, per role and per chain, and for the whole
+// session (the process), priced from the configured token prices unless a
+// gateway reports the cost.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-team/pkg/ai"
+	"ai-team/pkg/types"
+)
+
+// Default collects the usage of all provider calls made by the process.
+var Default = NewTracker()
+
+// Usage is the usage recorded for one label: a provider/model, role or chain.
+type Usage struct {
+	Label        string  `json:"label"`
+	Calls        int     `json:"calls"`
+	CostReported int     `json:"cost_reported"` // Calls whose response carried a cost header
+	Cost         float64 `json:"cost"`          // Gateway-reported cost
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	PricedCalls  int     `json:"priced_calls"` // Calls priced from the configured token prices
+	PricedCost   float64 `json:"priced_cost"`
+}
+
+// EffectiveCost returns the gateway-reported cost when the gateway reported
+// any, else the cost computed from token prices. ok is false when neither is
+// known.
+func (u Usage) EffectiveCost() (cost float64, ok bool) {
+	if u.CostReported > 0 {
+		return u.Cost, true
+	}
+	return u.PricedCost, u.PricedCalls > 0
+}
+
+// Since returns the usage recorded after prev, a snapshot of the same label.
+func (u Usage) Since(prev Usage) Usage {
+	return Usage{
+		Label:        u.Label,
+		Calls:        u.Calls - prev.Calls,
+		CostReported: u.CostReported - prev.CostReported,
+		Cost:         u.Cost - prev.Cost,
+		InputTokens:  u.InputTokens - prev.InputTokens,
+		OutputTokens: u.OutputTokens - prev.OutputTokens,
+		PricedCalls:  u.PricedCalls - prev.PricedCalls,
+		PricedCost:   u.PricedCost - prev.PricedCost,
+	}
+}
+
+// CostSummary returns u as a cost summary in currency.
+func (u Usage) CostSummary(currency string) types.CostSummary {
+	cost, ok := u.EffectiveCost()
+	return types.CostSummary{
+		Currency:     currency,
+		Calls:        u.Calls,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		Cost:         cost,
+		Complete:     ok,
+	}
+}
+
+func (u *Usage) addCall(cost float64, reported bool) {
+	u.Calls++
+	if reported {
+		u.CostReported++
+		u.Cost += cost
+	}
+}
+
+func (u *Usage) addTokens(input, output int, price *types.ModelPrice) {
+	u.InputTokens += input
+	u.OutputTokens += output
+	if price != nil {
+		u.PricedCalls++
+		u.PricedCost += price.Cost(input, output)
+	}
+}
+
+// Scope names the role and chain a provider call is made for.
+type Scope struct {
+	Role  string
+	Chain string
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context whose provider calls are counted for scope.
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom returns the scope set by WithScope.
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(Scope)
+	return scope, ok
+}
+
+// Tracker counts provider calls, tokens and costs. It implements
+// ai.CallRecorder.
+type Tracker struct {
+	mu     sync.Mutex
+	models map[string]*Usage
+	roles  map[string]*Usage
+	chains map[string]*Usage
+	scope  Scope // Used for calls whose context has no scope
+}
+
+// NewTracker returns an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{models: map[string]*Usage{}, roles: map[string]*Usage{}, chains: map[string]*Usage{}}
+}
+
+// SetScope sets the scope of calls whose context names none, for processes
+// that run a single role such as interactive sessions.
+func (t *Tracker) SetScope(scope Scope) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scope = scope
+}
+
+// entries returns the usage of label and of the role and chain of ctx.
+// t.mu must be held.
+func (t *Tracker) entries(ctx context.Context, label string) []*Usage {
+	scope, ok := ScopeFrom(ctx)
+	if !ok {
+		scope = t.scope
+	}
+	out := []*Usage{entry(t.models, label)}
+	if scope.Role != "" {
+		out = append(out, entry(t.roles, scope.Role))
+	}
+	if scope.Chain != "" {
+		out = append(out, entry(t.chains, scope.Chain))
+	}
+	return out
+}
+
+func entry(m map[string]*Usage, label string) *Usage {
+	u, ok := m[label]
+	if !ok {
+		u = &Usage{Label: label}
+		m[label] = u
+	}
+	return u
+}
+
+// Record adds a call for label; cost counts only when reported is true.
+func (t *Tracker) Record(ctx context.Context, label string, cost float64, reported bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, u := range t.entries(ctx, label) {
+		u.addCall(cost, reported)
+	}
+}
+
+// RecordTokens adds the token counts of a call for label, priced with price
+// when it is not nil.
+func (t *Tracker) RecordTokens(ctx context.Context, label string, input, output int, price *types.ModelPrice) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, u := range t.entries(ctx, label) {
+		u.addTokens(input, output, price)
+	}
+}
+
+// Model returns the usage recorded for the provider/model label.
+func (t *Tracker) Model(label string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u, ok := t.models[label]; ok {
+		return *u
+	}
+	return Usage{Label: label}
+}
+
+// Summary returns usage per provider/model label, sorted by label.
+func (t *Tracker) Summary() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return sorted(t.models)
+}
+
+// Roles returns usage per role, sorted by role.
+func (t *Tracker) Roles() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return sorted(t.roles)
+}
+
+// Chains returns usage per chain, sorted by chain.
+func (t *Tracker) Chains() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return sorted(t.chains)
+}
+
+func sorted(m map[string]*Usage) []Usage {
+	out := make([]Usage, 0, len(m))
+	for _, u := range m {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+	return out
+}
+
+// TotalCost returns the sum of effective costs and whether any cost is known.
+func (t *Tracker) TotalCost() (float64, bool) {
+	total, known := 0.0, false
+	for _, u := range t.Summary() {
+		cost, ok := u.EffectiveCost()
+		total += cost
+		known = known || ok
+	}
+	return total, known
+}
+
+// Totals sums the usage of the session in currency.
+func (t *Tracker) Totals(currency string) types.CostSummary {
+	total := types.CostSummary{Currency: currency, Complete: true}
+	for _, u := range t.Summary() {
+		total.Add(u.CostSummary(currency))
+	}
+	return total
+}
+
+// Report formats the usage of the session by model, role and chain, e.g.
+//
+//	By model:
+//	  gemini/flash  3 calls, 1200 in / 300 out tokens, 0.0420 USD
+//	By role:
+//	  coder         3 calls, 1200 in / 300 out tokens, 0.0420 USD
+//
+// Sections without usage are left out; the total is not included.
+func (t *Tracker) Report(currency string) string {
+	var b strings.Builder
+	for _, section := range []struct {
+		title string
+		usage []Usage
+	}{{"By model:", t.Summary()}, {"By role:", t.Roles()}, {"By chain:", t.Chains()}} {
+		if len(section.usage) == 0 {
+			continue
+		}
+		width := 0
+		for _, u := range section.usage {
+			width = max(width, len(u.Label))
+		}
+		b.WriteString(section.title + "\n")
+		for _, u := range section.usage {
+			fmt.Fprintf(&b, "  %-*s  %s\n", width, u.Label, u.CostSummary(currency))
+		}
+	}
+	return b.String()
+}
+
+// Parse returns the token counts of a call from the raw response of provider,
+// estimating them from the prompt and response text when the provider
+// reports none. reported is false for estimates.
+func Parse(provider, prompt, raw string) (input, output int, reported bool) {
+	if input, output, ok := ai.TokenUsage(provider, raw); ok {
+		return input, output, true
+	}
+	text, _, found := ai.ResponseText(provider, raw)
+	if !found {
+		text = raw
+	}
+	return ai.EstimateTokens(prompt), ai.EstimateTokens(text), false
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ai-team/pkg/types"
+)
+
+func TestTrackerPricing(t *testing.T) {
+	u := NewTracker()
+	ctx := context.Background()
+	price := &types.ModelPrice{Input: 2, Output: 10}
+	u.Record(ctx, "openai/gpt", 0, false)
+	u.RecordTokens(ctx, "openai/gpt", 1000000, 100000, price)
+	u.Record(ctx, "ollama/llama", 0, false)
+	u.RecordTokens(ctx, "ollama/llama", 50, 50, nil)
+
+	if cost, ok := u.Model("openai/gpt").EffectiveCost(); !ok || cost != 3 {
+		t.Errorf("expected priced cost 3, got %v (%v)", cost, ok)
+	}
+	totals := u.Totals("EUR")
+	if totals.Calls != 2 || totals.InputTokens != 1000050 || totals.Cost != 3 || totals.Complete {
+		t.Errorf("unexpected totals: %+v", totals)
+	}
+
+	// A gateway-reported cost wins over the priced cost.
+	before := u.Model("openai/gpt")
+	u.Record(ctx, "openai/gpt", 0.5, true)
+	u.RecordTokens(ctx, "openai/gpt", 10, 10, price)
+	step := u.Model("openai/gpt").Since(before)
+	if cost, ok := step.EffectiveCost(); !ok || cost != 0.5 || step.Calls != 1 || step.InputTokens != 10 {
+		t.Errorf("unexpected usage since snapshot: %+v", step)
+	}
+}
+
+func TestTrackerScopes(t *testing.T) {
+	u := NewTracker()
+	price := &types.ModelPrice{Input: 1, Output: 1}
+	coder := WithScope(context.Background(), Scope{Role: "coder", Chain: "fix"})
+	reviewer := WithScope(context.Background(), Scope{Role: "reviewer", Chain: "fix"})
+	for _, ctx := range []context.Context{coder, coder, reviewer} {
+		u.Record(ctx, "gemini/flash", 0, false)
+		u.RecordTokens(ctx, "gemini/flash", 500000, 500000, price)
+	}
+	u.SetScope(Scope{Role: "chat"})
+	u.Record(context.Background(), "ollama/llama", 0, false)
+	u.RecordTokens(context.Background(), "ollama/llama", 10, 20, nil)
+
+	roles := u.Roles()
+	if len(roles) != 3 || roles[1].Label != "coder" || roles[1].Calls != 2 || roles[2].InputTokens != 500000 {
+		t.Errorf("unexpected usage by role %+v", roles)
+	}
+	if roles[0].Label != "chat" || roles[0].OutputTokens != 20 {
+		t.Errorf("expected calls without a scope counted for the tracker's scope, got %+v", roles[0])
+	}
+	chains := u.Chains()
+	if len(chains) != 1 || chains[0].Calls != 3 || chains[0].PricedCost != 3 {
+		t.Errorf("unexpected usage by chain %+v", chains)
+	}
+
+	report := u.Report("USD")
+	for _, want := range []string{
+		"By model:\n  gemini/flash  3 calls, 1500000 in / 1500000 out tokens, 3.0000 USD\n  ollama/llama  1 calls",
+		"By role:\n  chat      1 calls",
+		"  coder     2 calls, 1000000 in / 1000000 out tokens, 2.0000 USD\n",
+		"By chain:\n  fix  3 calls",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, report)
+		}
+	}
+	if NewTracker().Report("USD") != "" {
+		t.Error("expected an empty report without usage")
+	}
+}
+
+func TestParse(t *testing.T) {
+	if in, out, reported := Parse("openai", "prompt", `{"choices":[{"text":"ok"}],"usage":{"prompt_tokens":12,"completion_tokens":5}}`); !reported || in != 12 || out != 5 {
+		t.Errorf("expected the reported counts, got %d/%d (%v)", in, out, reported)
+	}
+	if in, out, reported := Parse("ollama", "12345678", `{"response":"1234"}`); reported || in != 2 || out != 1 {
+		t.Errorf("expected estimates from the prompt and response text, got %d/%d (%v)", in, out, reported)
+	}
+}